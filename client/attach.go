@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/pipeline"
+	"github.com/smnsjas/go-psrpcore/runspace"
+
+	"github.com/smnsjas/go-psrp/powershell"
+)
+
+// AttachPipeline reattaches to a command that is still running in the
+// shell the client reconnected to with Reconnect or ReconnectSession, and
+// streams the rest of its output as ExecuteStream does. Output the server
+// buffered while the shell was disconnected arrives first:
+//
+//	if err := c.Reconnect(ctx, shellID); err != nil {
+//	    return err
+//	}
+//	stream, err := c.AttachPipeline(ctx, commandID)
+//	if err != nil {
+//	    return err
+//	}
+//	for obj := range stream.Objects().Output { ... }
+//	err = stream.Wait()
+//
+// commandID is the ID returned by ExecuteAsync or reported by GetJob.
+// Cancelling the stream stops the command on the server. Use
+// RecoverPipelineOutput to collect the output as a Result instead.
+func (c *Client) AttachPipeline(ctx context.Context, commandID string) (sr *StreamResult, err error) {
+	c.beginUse(ctx)
+	defer func() {
+		if err != nil {
+			c.endUse(ctx)
+		}
+	}()
+
+	c.mu.Lock()
+	backend := c.backend
+	psrpPool := c.psrpPool
+	hasWSMan := c.wsman != nil
+	sem := c.semaphore
+	connected := c.connected
+	c.mu.Unlock()
+	if !connected || backend == nil || psrpPool == nil {
+		return nil, ErrNotConnected
+	}
+	if sem == nil {
+		return nil, errors.New("semaphore not initialized")
+	}
+
+	// The command occupies a runspace like any other
+	if err := sem.Acquire(ctx); err != nil {
+		return nil, fmt.Errorf("pool busy: %w", err)
+	}
+	pl, transport, err := c.adoptPipeline(ctx, backend, psrpPool, hasWSMan, strings.ToUpper(commandID))
+	if err != nil {
+		sem.Release()
+		return nil, err
+	}
+	failure := &pipelineFailure{}
+	if transport != nil {
+		go c.runPipelineReceive(ctx, transport, pl, failure)
+	}
+
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() { c.stopPipeline(ctx, pl) })
+	}
+	stopOnCancel := context.AfterFunc(ctx, stop)
+	var endUseOnce sync.Once
+
+	return &StreamResult{
+		pipeline:    pl,
+		ctx:         ctx,
+		failure:     failure,
+		failures:    failureReporter(backend),
+		serOpts:     c.config.Serialization,
+		dateTimes:   c.config.DateTimes,
+		Output:      pl.Output(),
+		Errors:      pl.Error(),
+		Warnings:    pl.Warning(),
+		Verbose:     pl.Verbose(),
+		Debug:       pl.Debug(),
+		Progress:    pl.Progress(),
+		Information: pl.Information(),
+		stop:        stop,
+		cleanup: func() {
+			stopOnCancel()
+			stopOnce.Do(func() {}) // finished; nothing left to stop
+			sem.Release()
+			endUseOnce.Do(func() { c.endUse(ctx) })
+		},
+	}, nil
+}
+
+// adoptPipeline registers a pipeline for a command running in the pool, so
+// its messages reach it. Over WSMan it also reconnects to the command and
+// returns the transport its output must be received from; other transports
+// deliver the output through the pool's dispatch loop.
+func (c *Client) adoptPipeline(ctx context.Context, backend powershell.RunspaceBackend, psrpPool *runspace.Pool, hasWSMan bool, commandID string) (*pipeline.Pipeline, io.Reader, error) {
+	cmdUUID, err := uuid.Parse(commandID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid command id: %w", err)
+	}
+
+	// Create new pipeline attached to pool with specific ID
+	pl := pipeline.NewWithID(psrpPool, c.poolID, cmdUUID)
+
+	// Register with pool (so handleMessage works)
+	if err := psrpPool.AdoptPipeline(pl); err != nil {
+		return nil, nil, fmt.Errorf("adopt pipeline: %w", err)
+	}
+
+	wsmanBackend, ok := backend.(*powershell.WSManBackend)
+	if !ok || !hasWSMan {
+		// Ensure dispatch loop is running (safe to call multiple times).
+		// For HvSocket reconnection, Reattach calls pool.Connect which starts it.
+		psrpPool.StartDispatchLoop()
+		return pl, nil, nil
+	}
+
+	// WSMan needs a Receive per command. Reattach to the command so the
+	// server releases its buffered output; older servers resume receives
+	// without it, so a failure is not fatal.
+	if err := wsmanBackend.ConnectCommand(ctx, commandID); err != nil {
+		c.logWarn("Connecting to command %s failed, receiving anyway: %v", commandID, err)
+	}
+	transport := wsmanBackend.NewCommandTransport(commandID)
+	transport.SetContext(ctx)
+	return pl, transport, nil
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClient_AttachPipeline_NotConnected(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Username, cfg.Password = "user", "pass"
+	c, err := New("testserver", cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := c.AttachPipeline(context.Background(), "2D6534D0-6B12-40E3-B773-CBA26459CFA8"); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("AttachPipeline() error = %v, want ErrNotConnected", err)
+	}
+	c.mu.Lock()
+	inUse := c.session.inUse
+	c.mu.Unlock()
+	if inUse != 0 {
+		t.Errorf("session in use %d times after a failed attach, want 0", inUse)
+	}
+}
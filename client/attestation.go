@@ -0,0 +1,171 @@
+package client
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrAttestationMismatch is returned by VerifyScriptAttestation when the
+// script or the signature does not match the attestation.
+var ErrAttestationMismatch = errors.New("client: script does not match its attestation")
+
+// AttestationKeyArgument is the ApplicationArguments key under which the
+// signer's public key (base64 PKIX DER) is sent, so the session can verify
+// script signatures as $PSSenderInfo.ApplicationArguments.PSRPAttestationKey.
+const AttestationKeyArgument = "PSRPAttestationKey"
+
+// attestationPrefix starts the comment line added ahead of attested scripts.
+const attestationPrefix = "# go-psrp-attestation "
+
+// ScriptAttestationConfig enables script attestation: the SHA-256 hash of
+// every script is recorded, and optionally signed, before it is run, so the
+// audit log shows exactly what code ran on each target.
+type ScriptAttestationConfig struct {
+	// Signer signs the hash of each script, e.g. an *ecdsa.PrivateKey, an
+	// *rsa.PrivateKey (PKCS #1 v1.5) or an ed25519.PrivateKey, which signs
+	// the hash itself. If nil, scripts are hashed but not signed.
+	Signer crypto.Signer
+
+	// AnnotateScript adds the attestation to the script as a leading
+	// comment line, so it also appears in the server's script block
+	// logging (event 4104). The hash covers the script without that line.
+	AnnotateScript bool
+}
+
+// ScriptAttestation is the attestation of one script.
+type ScriptAttestation struct {
+	// SHA256 is the hex SHA-256 hash of the script.
+	SHA256 string
+
+	// Signature is the signature of the hash, empty without a Signer.
+	Signature []byte
+
+	// Signer is the hex SHA-256 fingerprint of the signer's public key
+	// (PKIX DER), empty without a Signer.
+	Signer string
+}
+
+// attestScript hashes script and signs the hash with signer, if any.
+func attestScript(script string, signer crypto.Signer) (*ScriptAttestation, error) {
+	sum := sha256.Sum256([]byte(script))
+	att := &ScriptAttestation{SHA256: hex.EncodeToString(sum[:])}
+	if signer == nil {
+		return att, nil
+	}
+
+	fingerprint, err := keyFingerprint(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	var opts crypto.SignerOpts = crypto.SHA256
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		// Ed25519 signs messages, not prehashed digests
+		opts = crypto.Hash(0)
+	}
+	att.Signature, err = signer.Sign(rand.Reader, sum[:], opts)
+	if err != nil {
+		return nil, fmt.Errorf("sign script: %w", err)
+	}
+	att.Signer = fingerprint
+	return att, nil
+}
+
+// keyFingerprint returns the hex SHA-256 of the PKIX encoding of pub.
+func keyFingerprint(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("encode signer public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// annotate returns script with the attestation as a leading comment line.
+func (a *ScriptAttestation) annotate(script string) string {
+	line := attestationPrefix + "sha256=" + a.SHA256
+	if len(a.Signature) > 0 {
+		line += " signer=" + a.Signer + " sig=" + base64.StdEncoding.EncodeToString(a.Signature)
+	}
+	return line + "\n" + script
+}
+
+// details returns the attestation as security event details.
+func (a *ScriptAttestation) details() map[string]any {
+	details := map[string]any{"script_sha256": a.SHA256}
+	if len(a.Signature) > 0 {
+		details["signature"] = base64.StdEncoding.EncodeToString(a.Signature)
+		details["signer"] = a.Signer
+	}
+	return details
+}
+
+// VerifyScriptAttestation checks that script hashes to att.SHA256 and, if
+// att is signed, that att.Signature is pub's signature of the hash. A
+// script annotated with AnnotateScript is verified without its comment line.
+func VerifyScriptAttestation(script string, att ScriptAttestation, pub crypto.PublicKey) error {
+	if strings.HasPrefix(script, attestationPrefix) {
+		if _, rest, ok := strings.Cut(script, "\n"); ok {
+			script = rest
+		}
+	}
+	sum := sha256.Sum256([]byte(script))
+	if hex.EncodeToString(sum[:]) != strings.ToLower(att.SHA256) {
+		return fmt.Errorf("%w: hash differs", ErrAttestationMismatch)
+	}
+	if len(att.Signature) == 0 {
+		return nil
+	}
+	if pub == nil {
+		return errors.New("client: a public key is required to verify a signed attestation")
+	}
+
+	var ok bool
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(key, sum[:], att.Signature)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], att.Signature) == nil
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, sum[:], att.Signature)
+	default:
+		return fmt.Errorf("client: unsupported public key type %T", pub)
+	}
+	if !ok {
+		return fmt.Errorf("%w: invalid signature", ErrAttestationMismatch)
+	}
+	return nil
+}
+
+// applicationArguments returns the RunspacePool's ApplicationArguments:
+// ApplicationArguments, Tags if SendTagsToServer is set, the locales and
+// the attestation public key.
+func (c *Config) applicationArguments() (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(c.ApplicationArguments))
+	for k, v := range c.ApplicationArguments {
+		args[k] = v
+	}
+	if c.SendTagsToServer {
+		for k, v := range c.Tags {
+			args[k] = v
+		}
+	}
+	c.addCultureArguments(args)
+	if c.ScriptAttestation != nil && c.ScriptAttestation.Signer != nil {
+		der, err := x509.MarshalPKIXPublicKey(c.ScriptAttestation.Signer.Public())
+		if err != nil {
+			return nil, fmt.Errorf("encode attestation public key: %w", err)
+		}
+		args[AttestationKeyArgument] = base64.StdEncoding.EncodeToString(der)
+	}
+	return args, nil
+}
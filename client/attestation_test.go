@@ -0,0 +1,106 @@
+package client
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAttestScript_Verify(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const script = "Get-Service | Where-Object Status -eq Running"
+	for _, signer := range []crypto.Signer{nil, ecKey, edKey} {
+		var pub crypto.PublicKey
+		if signer != nil {
+			pub = signer.Public()
+		}
+		att, err := attestScript(script, signer)
+		if err != nil {
+			t.Fatalf("attestScript(%T) error = %v", signer, err)
+		}
+		if (signer != nil) != (len(att.Signature) > 0 && att.Signer != "") {
+			t.Errorf("attestScript(%T) signature = %x, signer = %q", signer, att.Signature, att.Signer)
+		}
+
+		if err := VerifyScriptAttestation(script, *att, pub); err != nil {
+			t.Errorf("VerifyScriptAttestation(%T) error = %v", signer, err)
+		}
+		if err := VerifyScriptAttestation(att.annotate(script), *att, pub); err != nil {
+			t.Errorf("VerifyScriptAttestation(%T) of annotated script error = %v", signer, err)
+		}
+		if err := VerifyScriptAttestation(script+"; Stop-Computer", *att, pub); !errors.Is(err, ErrAttestationMismatch) {
+			t.Errorf("VerifyScriptAttestation(%T) of tampered script error = %v, want ErrAttestationMismatch", signer, err)
+		}
+	}
+
+	att, err := attestScript(script, ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyScriptAttestation(script, *att, other.Public()); !errors.Is(err, ErrAttestationMismatch) {
+		t.Errorf("VerifyScriptAttestation() with another key error = %v, want ErrAttestationMismatch", err)
+	}
+}
+
+func TestScriptAttestation_Annotate(t *testing.T) {
+	att := &ScriptAttestation{SHA256: "ab12", Signature: []byte{1, 2}, Signer: "cd34"}
+	got := att.annotate("Get-Date")
+	want := "# go-psrp-attestation sha256=ab12 signer=cd34 sig=AQI=\nGet-Date"
+	if got != want {
+		t.Errorf("annotate() = %q, want %q", got, want)
+	}
+}
+
+func TestConfig_ApplicationArguments(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.Tags = map[string]string{"team": "ops"}
+
+	args, err := cfg.applicationArguments()
+	if err != nil || len(args) != 0 {
+		t.Errorf("applicationArguments() without SendTagsToServer = %v, %v", args, err)
+	}
+
+	cfg.SendTagsToServer = true
+	cfg.ScriptAttestation = &ScriptAttestationConfig{Signer: key}
+	args, err = cfg.applicationArguments()
+	if err != nil {
+		t.Fatalf("applicationArguments() error = %v", err)
+	}
+	if key, _ := args[AttestationKeyArgument].(string); args["team"] != "ops" || !strings.HasPrefix(key, "MFkw") {
+		t.Errorf("applicationArguments() = %v", args)
+	}
+
+	// Explicit arguments are kept, tags and the key win over them
+	cfg.ApplicationArguments = map[string]interface{}{"tenant": 42, "team": "dev", AttestationKeyArgument: "forged"}
+	args, err = cfg.applicationArguments()
+	if err != nil {
+		t.Fatalf("applicationArguments() error = %v", err)
+	}
+	if key, _ := args[AttestationKeyArgument].(string); args["tenant"] != 42 || args["team"] != "ops" || !strings.HasPrefix(key, "MFkw") {
+		t.Errorf("applicationArguments() = %v", args)
+	}
+	if cfg.ApplicationArguments["team"] != "dev" {
+		t.Error("applicationArguments() changed Config.ApplicationArguments")
+	}
+}
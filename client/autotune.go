@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/smnsjas/go-psrp/wsman"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// defaultAutoTuneThreshold is the smallest file that is auto-tuned.
+	// Smaller files finish before probing would pay off.
+	defaultAutoTuneThreshold = 16 * 1024 * 1024
+
+	// autoTuneProbeChunks is the number of chunks timed per chunk size.
+	autoTuneProbeChunks = 2
+
+	// autoTuneMinGain is the throughput gain a higher concurrency must bring
+	// to be preferred; probing stops at the first level below it.
+	autoTuneMinGain = 1.1
+)
+
+// WithAutoTune enables chunk size and concurrency auto-tuning for files of at
+// least threshold bytes (0 uses a 16MB default). The first chunks of the
+// transfer are sent with a few candidate settings and the fastest is used
+// for the rest, instead of the static per-transport defaults.
+func WithAutoTune(threshold int64) FileTransferOption {
+	return func(o *FileTransferOptions) {
+		o.AutoTune = true
+		o.AutoTuneThreshold = threshold
+	}
+}
+
+// shouldAutoTune reports whether a transfer of totalSize bytes is auto-tuned.
+func (o FileTransferOptions) shouldAutoTune(totalSize int64) bool {
+	if !o.AutoTune {
+		return false
+	}
+	threshold := o.AutoTuneThreshold
+	if threshold <= 0 {
+		threshold = defaultAutoTuneThreshold
+	}
+	return totalSize >= threshold
+}
+
+// autoTuneChunkSizes returns the chunk sizes to probe. WSMan is bounded by
+// MaxEnvelopeSizeKb (500KB by default), so its largest candidate is the
+// 256KB default; stream transports have no envelope limit.
+func autoTuneChunkSizes(transport TransportType) []int {
+	if transport.isStreamTransport() {
+		return []int{256 * 1024, 512 * 1024, 1024 * 1024}
+	}
+	return []int{64 * 1024, 128 * 1024, 256 * 1024}
+}
+
+// autoTuneConcurrency is the concurrency levels probed for uploads, in order.
+var autoTuneConcurrency = []int{2, 4, 8}
+
+// chunkTransfer transfers up to size bytes at offset and returns the number
+// of bytes transferred.
+type chunkTransfer func(ctx context.Context, offset int64, size int) (int, error)
+
+// transferTuner picks transfer settings by timing real chunks at the start
+// of a transfer, so no probe data is wasted.
+type transferTuner struct {
+	clock    Clock
+	transfer chunkTransfer
+}
+
+// tuneResult is the outcome of probing.
+type tuneResult struct {
+	ChunkSize   int
+	Concurrency int
+	// Offset is where the probes stopped; the transfer continues from here.
+	Offset int64
+	// Throughput of the chosen settings, in bytes per second.
+	Throughput float64
+}
+
+// tune probes each chunk size sequentially, then each concurrency level with
+// the fastest size. concurrency may be empty for sequential transfers.
+// Probing stops early if the file is exhausted.
+func (t *transferTuner) tune(ctx context.Context, totalSize int64, sizes, concurrency []int) (tuneResult, error) {
+	best := tuneResult{ChunkSize: sizes[0], Concurrency: 1}
+	offset := int64(0)
+
+sizes:
+	for _, size := range sizes {
+		if offset >= totalSize {
+			break
+		}
+		start := t.clock.Now()
+		var moved int64
+		for i := 0; i < autoTuneProbeChunks && offset < totalSize; i++ {
+			n, err := t.transfer(ctx, offset, size)
+			if err != nil {
+				// Sizes are ascending, so the larger ones do not fit either;
+				// the rejected chunk was not sent
+				if errors.Is(err, wsman.ErrEnvelopeTooLarge) && best.Throughput > 0 {
+					break sizes
+				}
+				return tuneResult{}, err
+			}
+			offset += int64(n)
+			moved += int64(n)
+		}
+		if rate := throughput(moved, t.clock.Now().Sub(start)); rate > best.Throughput {
+			best.ChunkSize, best.Throughput = size, rate
+		}
+	}
+
+	for _, level := range concurrency {
+		if offset >= totalSize {
+			break
+		}
+		start := t.clock.Now()
+		moved, next, err := t.transferConcurrently(ctx, offset, totalSize, best.ChunkSize, level)
+		if err != nil {
+			return tuneResult{}, err
+		}
+		offset = next
+		rate := throughput(moved, t.clock.Now().Sub(start))
+		if rate < best.Throughput*autoTuneMinGain {
+			break
+		}
+		best.Concurrency, best.Throughput = level, rate
+	}
+
+	best.Offset = offset
+	return best, nil
+}
+
+// transferConcurrently transfers level chunks of size bytes at once,
+// starting at offset. It returns the bytes moved and the next offset.
+func (t *transferTuner) transferConcurrently(ctx context.Context, offset, totalSize int64, size, level int) (moved, next int64, err error) {
+	g, gctx := errgroup.WithContext(ctx)
+	counts := make([]int, level)
+	next = offset
+	for i := 0; i < level && next < totalSize; i++ {
+		i, chunkOffset := i, next
+		g.Go(func() error {
+			n, err := t.transfer(gctx, chunkOffset, size)
+			counts[i] = n
+			return err
+		})
+		next += int64(size)
+	}
+	if err := g.Wait(); err != nil {
+		return 0, 0, err
+	}
+	for _, n := range counts {
+		moved += int64(n)
+	}
+	return moved, min(next, totalSize), nil
+}
+
+// throughput returns bytes per second, treating an unmeasurably short
+// interval as very fast rather than dividing by zero.
+func throughput(n int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		elapsed = time.Nanosecond
+	}
+	return float64(n) / elapsed.Seconds()
+}
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+// simulatedLink models a link where each chunk costs a fixed latency plus
+// size/bandwidth and up to maxStreams chunks overlap. It is also the tuner's
+// clock: the cost of the chunks sent during a measurement is charged when
+// the measurement ends. The first sequential measurements send one chunk at
+// a time; the rest send a concurrent batch.
+type simulatedLink struct {
+	clock      *mockClock
+	latency    time.Duration
+	bandwidth  float64 // bytes per second
+	maxStreams int
+	sequential int
+
+	nows  int
+	calls atomic.Int64
+	cost  atomic.Int64
+}
+
+func (l *simulatedLink) transfer(_ context.Context, _ int64, size int) (int, error) {
+	l.calls.Add(1)
+	l.cost.Add(int64(l.latency + time.Duration(float64(size)/l.bandwidth*float64(time.Second))))
+	return size, nil
+}
+
+func (l *simulatedLink) Now() time.Time {
+	l.nows++
+	if l.nows%2 == 0 {
+		calls, cost := l.calls.Swap(0), time.Duration(l.cost.Swap(0))
+		if measurement := l.nows/2 - 1; measurement >= l.sequential {
+			cost /= time.Duration(min(calls, int64(l.maxStreams)))
+		}
+		l.clock.Advance(cost)
+	}
+	return l.clock.Now()
+}
+
+func TestTransferTuner_PrefersLargerChunksOnHighLatency(t *testing.T) {
+	sizes := []int{64 << 10, 128 << 10, 256 << 10}
+	link := &simulatedLink{
+		clock:      newMockClock(time.Unix(0, 0)),
+		latency:    200 * time.Millisecond,
+		bandwidth:  10 << 20,
+		maxStreams: 1,
+		sequential: len(sizes),
+	}
+	tuner := &transferTuner{clock: link, transfer: link.transfer}
+
+	got, err := tuner.tune(context.Background(), 64<<20, sizes, nil)
+	if err != nil {
+		t.Fatalf("tune() error = %v", err)
+	}
+	if got.ChunkSize != 256<<10 {
+		t.Errorf("ChunkSize = %d, want %d", got.ChunkSize, 256<<10)
+	}
+	if got.Concurrency != 1 {
+		t.Errorf("Concurrency = %d, want 1 without concurrency probes", got.Concurrency)
+	}
+	wantOffset := int64(autoTuneProbeChunks * (64 + 128 + 256) << 10)
+	if got.Offset != wantOffset {
+		t.Errorf("Offset = %d, want %d", got.Offset, wantOffset)
+	}
+}
+
+func TestTransferTuner_Concurrency(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxStreams int
+		want       int
+	}{
+		{"saturated link", 1, 1},
+		{"four streams", 4, 4},
+		{"eight streams", 8, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := &simulatedLink{
+				clock:      newMockClock(time.Unix(0, 0)),
+				latency:    50 * time.Millisecond,
+				bandwidth:  10 << 20,
+				maxStreams: tt.maxStreams,
+				sequential: 1,
+			}
+			tuner := &transferTuner{clock: link, transfer: link.transfer}
+
+			got, err := tuner.tune(context.Background(), 1<<30, []int{256 << 10}, autoTuneConcurrency)
+			if err != nil {
+				t.Fatalf("tune() error = %v", err)
+			}
+			if got.Concurrency != tt.want {
+				t.Errorf("Concurrency = %d, want %d", got.Concurrency, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransferTuner_SmallFileStopsEarly(t *testing.T) {
+	clock := newMockClock(time.Unix(0, 0))
+	var offsets []int64
+	tuner := &transferTuner{clock: clock, transfer: func(_ context.Context, offset int64, size int) (int, error) {
+		offsets = append(offsets, offset)
+		clock.Advance(time.Millisecond)
+		return int(min(int64(size), 300-offset)), nil
+	}}
+
+	got, err := tuner.tune(context.Background(), 300, []int{100, 200, 400}, autoTuneConcurrency)
+	if err != nil {
+		t.Fatalf("tune() error = %v", err)
+	}
+	if got.Offset != 300 {
+		t.Errorf("Offset = %d, want 300", got.Offset)
+	}
+	// 100+100 with the first size, then 100 (the remainder) with the second.
+	if len(offsets) != 3 {
+		t.Errorf("transfers at %v, want 3", offsets)
+	}
+}
+
+func TestTransferTuner_Error(t *testing.T) {
+	wantErr := errors.New("link down")
+	tuner := &transferTuner{clock: realClock{}, transfer: func(context.Context, int64, int) (int, error) {
+		return 0, wantErr
+	}}
+	if _, err := tuner.tune(context.Background(), 1<<20, []int{1024}, nil); !errors.Is(err, wantErr) {
+		t.Errorf("tune() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestTransferTuner_EnvelopeTooLarge(t *testing.T) {
+	clock := newMockClock(time.Unix(0, 0))
+	tuner := &transferTuner{clock: clock, transfer: func(_ context.Context, _ int64, size int) (int, error) {
+		if size > 100 {
+			return 0, &wsman.EnvelopeTooLargeError{Size: size + 50, PayloadSize: size, Limit: 120}
+		}
+		clock.Advance(time.Millisecond)
+		return size, nil
+	}}
+
+	got, err := tuner.tune(context.Background(), 1000, []int{100, 200, 400}, nil)
+	if err != nil {
+		t.Fatalf("tune() error = %v, want the sizes that fit probed", err)
+	}
+	if got.ChunkSize != 100 || got.Offset != 200 {
+		t.Errorf("tune() = %+v, want ChunkSize 100 at offset 200", got)
+	}
+
+	// Without a size that fits, the error is returned
+	if _, err := tuner.tune(context.Background(), 1000, []int{200}, nil); !errors.Is(err, wsman.ErrEnvelopeTooLarge) {
+		t.Errorf("tune() error = %v, want ErrEnvelopeTooLarge", err)
+	}
+}
+
+func TestFileTransferOptions_ShouldAutoTune(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []FileTransferOption
+		size int64
+		want bool
+	}{
+		{"disabled", nil, 1 << 30, false},
+		{"default threshold below", []FileTransferOption{WithAutoTune(0)}, defaultAutoTuneThreshold - 1, false},
+		{"default threshold", []FileTransferOption{WithAutoTune(0)}, defaultAutoTuneThreshold, true},
+		{"custom threshold", []FileTransferOption{WithAutoTune(1024)}, 2048, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := DefaultFileTransferOptions()
+			for _, fn := range tt.opts {
+				fn(&opt)
+			}
+			if got := opt.shouldAutoTune(tt.size); got != tt.want {
+				t.Errorf("shouldAutoTune(%d) = %v, want %v", tt.size, got, tt.want)
+			}
+		})
+	}
+}
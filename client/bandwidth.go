@@ -0,0 +1,12 @@
+package client
+
+import "github.com/smnsjas/go-psrp/wsman/transport"
+
+// newRateLimiter returns the limiter for MaxBandwidthBytesPerSec, shared
+// by all the traffic of a client, or nil if there is no cap.
+func (c *Config) newRateLimiter() transport.RateLimiter {
+	if c.MaxBandwidthBytesPerSec <= 0 {
+		return nil
+	}
+	return transport.NewTokenBucket(float64(c.MaxBandwidthBytesPerSec), 0)
+}
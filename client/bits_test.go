@@ -0,0 +1,95 @@
+package client
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBITSOffload_ShouldOffload(t *testing.T) {
+	var none *BITSOffload
+	if none.shouldOffload(1 << 40) {
+		t.Error("nil BITSOffload offloads")
+	}
+	def := &BITSOffload{}
+	if def.shouldOffload(defaultBITSThreshold-1) || !def.shouldOffload(defaultBITSThreshold) {
+		t.Error("default threshold not applied")
+	}
+	custom := &BITSOffload{Threshold: 10}
+	if !custom.shouldOffload(10) || custom.shouldOffload(9) {
+		t.Error("custom threshold not applied")
+	}
+}
+
+func TestGenerateBITSScript(t *testing.T) {
+	script := generateBITSScript("https://10.0.0.5:4443/tok/f.iso", `C:\f.iso`, "abcdef", "tok", true, true, 45*time.Second)
+	for _, want := range []string{
+		"/create /download $job",
+		"'go-psrp-tok'",
+		"/setsecurityflags $job 30",
+		"TotalSeconds -gt 45",
+		"if ($hash -ne 'ABCDEF')",
+		"if ($true -and (Test-Path",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q", want)
+		}
+	}
+	if strings.Contains(generateBITSScript(`\\srv\share\f`, `C:\f`, "ab", "tok", false, false, time.Second), "setsecurityflags") {
+		t.Error("script skips certificate validation without a self-signed certificate")
+	}
+}
+
+func TestServeBITS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big file.bin")
+	content := strings.Repeat("payload", 1000)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var reported atomic.Int64
+	progress := &transferProgress{
+		totalBytes:       int64(len(content)),
+		progressCallback: func(done, _ int64) { reported.Store(done) },
+	}
+
+	c := &Client{}
+	source, stop, err := c.serveBITS(&BITSOffload{URLHost: "127.0.0.1", ListenAddr: "127.0.0.1:0"}, path, "token", int64(len(content)), progress)
+	if err != nil {
+		t.Fatalf("serveBITS() error = %v", err)
+	}
+	defer stop()
+
+	httpClient := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // #nosec G402 -- self-signed test endpoint
+	}}
+	for i := 0; i < 2; i++ {
+		resp, err := httpClient.Get(source)
+		if err != nil {
+			t.Fatalf("GET %s: %v", source, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != content {
+			t.Fatalf("served %d bytes, want %d", len(body), len(content))
+		}
+	}
+	if got := reported.Load(); got != int64(len(content)) {
+		t.Errorf("progress = %d, want %d", got, len(content))
+	}
+
+	other := source[:strings.Index(source, "/token/")] + "/guess/big%20file.bin"
+	resp, err := httpClient.Get(other)
+	if err != nil {
+		t.Fatalf("GET %s: %v", other, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET without the token = %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResultCache caches Execute results of read-only queries, keyed by session
+// (host, port, user and ConfigurationName), script and the ExecOptions that
+// change the result. A single cache
+// can be shared by many clients (e.g., a dashboard running the same inventory
+// query against many machines).
+//
+// Only calls made with ExecOptions.Cacheable are cached, and only results
+// without errors are stored. Cached results are shared between callers and
+// must be treated as read-only. Expired entries are removed when they are
+// looked up and swept from time to time when results are stored.
+type ResultCache struct {
+	mu sync.Mutex
+
+	ttl     time.Duration
+	clock   Clock
+	entries map[string]cacheEntry
+
+	// nextSweep is the number of entries at which Put sweeps expired ones.
+	nextSweep int
+
+	// OnInvalidate is called (synchronously, without the cache lock held) for
+	// every host whose entries are removed by Invalidate or InvalidateHost.
+	// An empty host means the whole cache was purged.
+	OnInvalidate func(host string)
+}
+
+type cacheEntry struct {
+	host    string
+	script  string
+	result  *Result
+	expires time.Time
+}
+
+// minCacheSweep is the least number of entries at which Put sweeps.
+const minCacheSweep = 64
+
+// cacheScope identifies the session a result comes from. The same script
+// can return other results through another listener, as another user or in
+// another (e.g., JEA) endpoint configuration.
+type cacheScope struct {
+	host              string
+	port              int
+	user              string
+	configurationName string
+}
+
+// NewResultCache creates a cache whose entries expire after ttl.
+func NewResultCache(ttl time.Duration) *ResultCache {
+	return &ResultCache{
+		ttl:     ttl,
+		clock:   realClock{},
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// cacheKey returns the cache key for a script run in scope with options,
+// the ExecOptions.cacheOptions it was run with.
+func cacheKey(scope cacheScope, script, options string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		strings.ToLower(scope.host),
+		strconv.Itoa(scope.port),
+		strings.ToLower(scope.user),
+		strings.ToLower(scope.configurationName),
+		options,
+		script,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// get returns the cached result of the script, as the caller wrote it, run
+// in scope with options, if present and not expired.
+func (rc *ResultCache) get(scope cacheScope, script, options string) (*Result, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	key := cacheKey(scope, script, options)
+	entry, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !rc.clock.Now().Before(entry.expires) {
+		delete(rc.entries, key)
+		return nil, false
+	}
+	return entry.result, true
+}
+
+// put stores the result of the script run in scope with options. The entry
+// is tagged with the script as the caller wrote it, for Invalidate.
+func (rc *ResultCache) put(scope cacheScope, script, options string, result *Result) {
+	if result == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	now := rc.clock.Now()
+	if len(rc.entries) >= rc.nextSweep {
+		rc.sweepLocked(now)
+		rc.nextSweep = max(2*len(rc.entries), minCacheSweep)
+	}
+	rc.entries[cacheKey(scope, script, options)] = cacheEntry{
+		host:    strings.ToLower(scope.host),
+		script:  script,
+		result:  result,
+		expires: now.Add(rc.ttl),
+	}
+}
+
+// sweepLocked removes the expired entries (caller must hold rc.mu).
+func (rc *ResultCache) sweepLocked(now time.Time) {
+	for key, entry := range rc.entries {
+		if !now.Before(entry.expires) {
+			delete(rc.entries, key)
+		}
+	}
+}
+
+// Invalidate removes the cached results of a script on a host, for every
+// port, user, configuration and ExecOptions it was run with. The script is
+// matched as passed to ExecuteWithOptions.
+func (rc *ResultCache) Invalidate(host, script string) {
+	lower := strings.ToLower(host)
+
+	rc.mu.Lock()
+	removed := false
+	for key, entry := range rc.entries {
+		if entry.host == lower && entry.script == script {
+			delete(rc.entries, key)
+			removed = true
+		}
+	}
+	hook := rc.OnInvalidate
+	rc.mu.Unlock()
+
+	if removed && hook != nil {
+		hook(host)
+	}
+}
+
+// InvalidateHost removes all cached results for a host, for every port, user
+// and configuration (e.g., after a change was made on that machine).
+func (rc *ResultCache) InvalidateHost(host string) {
+	host = strings.ToLower(host)
+
+	rc.mu.Lock()
+	removed := false
+	for key, entry := range rc.entries {
+		if entry.host == host {
+			delete(rc.entries, key)
+			removed = true
+		}
+	}
+	hook := rc.OnInvalidate
+	rc.mu.Unlock()
+
+	if removed && hook != nil {
+		hook(host)
+	}
+}
+
+// Purge removes all cached results.
+func (rc *ResultCache) Purge() {
+	rc.mu.Lock()
+	rc.entries = make(map[string]cacheEntry)
+	hook := rc.OnInvalidate
+	rc.mu.Unlock()
+
+	if hook != nil {
+		hook("")
+	}
+}
+
+// Len returns the number of cached entries, including expired ones not yet evicted.
+func (rc *ResultCache) Len() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.entries)
+}
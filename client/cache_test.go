@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// hostScope is the session of a client of host with the default settings.
+func hostScope(host string) cacheScope {
+	return cacheScope{host: host, port: 5985}
+}
+
+func TestResultCache_GetPut(t *testing.T) {
+	clock := newMockClock(time.Now())
+	rc := NewResultCache(time.Minute)
+	rc.clock = clock
+
+	if _, ok := rc.get(hostScope("server1"), "Get-Service", ""); ok {
+		t.Fatal("get() on empty cache returned a result")
+	}
+
+	want := &Result{Output: []interface{}{"svc"}}
+	rc.put(hostScope("server1"), "Get-Service", "", want)
+
+	got, ok := rc.get(hostScope("SERVER1"), "Get-Service", "")
+	if !ok || got != want {
+		t.Fatalf("get() = %v, %v; want cached result (host match is case-insensitive)", got, ok)
+	}
+
+	if _, ok := rc.get(hostScope("server2"), "Get-Service", ""); ok {
+		t.Error("get() returned a result for a different host")
+	}
+	if _, ok := rc.get(hostScope("server1"), "Get-Process", ""); ok {
+		t.Error("get() returned a result for a different script")
+	}
+
+	clock.Advance(time.Minute)
+	if _, ok := rc.get(hostScope("server1"), "Get-Service", ""); ok {
+		t.Error("get() returned an expired result")
+	}
+	if n := rc.Len(); n != 0 {
+		t.Errorf("Len() = %d after expiry, want 0", n)
+	}
+}
+
+func TestResultCache_Scope(t *testing.T) {
+	rc := NewResultCache(time.Hour)
+	base := cacheScope{host: "server1", port: 5985, user: `CORP\alice`, configurationName: "Microsoft.PowerShell"}
+	want := &Result{}
+	rc.put(base, "Get-Service", "", want)
+
+	if got, ok := rc.get(cacheScope{host: "SERVER1", port: 5985, user: `corp\ALICE`, configurationName: "microsoft.powershell"},
+		"Get-Service", ""); !ok || got != want {
+		t.Errorf("get() = %v, %v; want cached result (case-insensitive)", got, ok)
+	}
+
+	others := map[string]cacheScope{
+		"port":          {host: "server1", port: 5986, user: base.user, configurationName: base.configurationName},
+		"user":          {host: "server1", port: 5985, user: `CORP\bob`, configurationName: base.configurationName},
+		"configuration": {host: "server1", port: 5985, user: base.user, configurationName: "JEAMaintenance"},
+	}
+	for name, scope := range others {
+		if _, ok := rc.get(scope, "Get-Service", ""); ok {
+			t.Errorf("get() returned a result for a different %s", name)
+		}
+		rc.put(scope, "Get-Service", "", &Result{})
+	}
+	options := ExecOptions{OutputWidth: 80}.cacheOptions()
+	if _, ok := rc.get(base, "Get-Service", options); ok {
+		t.Error("get() returned a result for different options")
+	}
+	rc.put(base, "Get-Service", options, &Result{})
+
+	// Invalidate removes the script for every session and options of the host
+	rc.Invalidate("server1", "Get-Service")
+	if n := rc.Len(); n != 0 {
+		t.Errorf("Len() after Invalidate() = %d, want 0", n)
+	}
+}
+
+func TestResultCache_PutSweepsExpired(t *testing.T) {
+	clock := newMockClock(time.Now())
+	rc := NewResultCache(time.Minute)
+	rc.clock = clock
+
+	for i := 0; i < minCacheSweep; i++ {
+		rc.put(hostScope("server1"), strconv.Itoa(i), "", &Result{})
+	}
+	clock.Advance(time.Minute)
+
+	// The expired entries are never looked up again, but storing enough
+	// new ones removes them.
+	for i := 0; i < minCacheSweep; i++ {
+		rc.put(hostScope("server2"), strconv.Itoa(i), "", &Result{})
+	}
+	if n := rc.Len(); n > minCacheSweep {
+		t.Errorf("Len() = %d, want at most %d after the expired entries were swept", n, minCacheSweep)
+	}
+	if _, ok := rc.get(hostScope("server2"), "0", ""); !ok {
+		t.Error("sweep removed an entry that has not expired")
+	}
+}
+
+func TestResultCache_Invalidate(t *testing.T) {
+	rc := NewResultCache(time.Hour)
+	var invalidated []string
+	rc.OnInvalidate = func(host string) { invalidated = append(invalidated, host) }
+
+	rc.put(hostScope("server1"), "a", "", &Result{})
+	rc.put(hostScope("server1"), "b", "", &Result{})
+	rc.put(hostScope("server2"), "a", "", &Result{})
+
+	rc.Invalidate("server1", "a")
+	if _, ok := rc.get(hostScope("server1"), "a", ""); ok {
+		t.Error("Invalidate() did not remove entry")
+	}
+
+	rc.InvalidateHost("Server1")
+	if _, ok := rc.get(hostScope("server1"), "b", ""); ok {
+		t.Error("InvalidateHost() did not remove entry")
+	}
+	if _, ok := rc.get(hostScope("server2"), "a", ""); !ok {
+		t.Error("InvalidateHost() removed entry for another host")
+	}
+
+	// No-op invalidations do not fire the hook
+	rc.Invalidate("server1", "a")
+	rc.InvalidateHost("server3")
+
+	rc.Purge()
+	if n := rc.Len(); n != 0 {
+		t.Errorf("Len() after Purge() = %d, want 0", n)
+	}
+
+	want := []string{"server1", "server1", ""}
+	if len(invalidated) != len(want) {
+		t.Fatalf("OnInvalidate calls = %q, want %q", invalidated, want)
+	}
+	for i := range want {
+		if invalidated[i] != want[i] {
+			t.Errorf("OnInvalidate call %d = %q, want %q", i, invalidated[i], want[i])
+		}
+	}
+}
+
+func TestExecuteWithOptions_CachedByScriptAndOptions(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ResultCache = NewResultCache(time.Hour)
+	c := &Client{hostname: "server1", config: cfg}
+
+	opts := ExecOptions{Cacheable: true, OutputWidth: 80, SuppressProgress: true}
+	want := &Result{Output: []interface{}{"svc"}}
+	cfg.ResultCache.put(c.cacheScope(), "Get-Service", opts.cacheOptions(), want)
+
+	got, err := c.ExecuteWithOptions(context.Background(), "Get-Service", opts)
+	if err != nil || got != want {
+		t.Fatalf("ExecuteWithOptions() = %v, %v; want the cached result", got, err)
+	}
+	if _, ok := cfg.ResultCache.get(c.cacheScope(), "Get-Service", ExecOptions{Cacheable: true}.cacheOptions()); ok {
+		t.Error("result cached for other options")
+	}
+
+	// Invalidate takes the script as the caller wrote it
+	cfg.ResultCache.Invalidate("server1", "Get-Service")
+	if n := cfg.ResultCache.Len(); n != 0 {
+		t.Errorf("Len() after Invalidate() = %d, want 0", n)
+	}
+}
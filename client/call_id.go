@@ -1,11 +1,27 @@
 package client
 
 import (
+	"errors"
 	"sync/atomic"
+
+	"github.com/smnsjas/go-psrp/powershell"
 )
 
+// ErrMessageIDsExhausted is returned when a session has used every PSRP
+// object ID below the range reserved for host responses. Reconnect to start
+// a new session.
+var ErrMessageIDsExhausted = errors.New("client: PSRP message IDs exhausted")
+
+// maxCallID is the highest object ID the client assigns. IDs above it
+// belong to host responses (see powershell.HostObjectIDBase).
+const maxCallID = powershell.HostObjectIDBase - 1
+
 // callIDManager manages atomic generation of PSRP message IDs.
 // PSRP requires sequential, unique IDs for request tracking.
+//
+// All object IDs the client assigns for a session (CreatePipeline for
+// Execute, ExecuteStream, ExecuteAsync and the file transfer commands built
+// on them) come from the session's manager through Allocate.
 type callIDManager struct {
 	id atomic.Int64
 }
@@ -16,11 +32,27 @@ func newCallIDManager() *callIDManager {
 }
 
 // Next increments and returns the next ID.
-// This is thread-safe.
+// This is thread-safe. It does not check for overflow; use Allocate.
 func (m *callIDManager) Next() int64 {
 	return m.id.Add(1)
 }
 
+// Allocate returns the next ID, or ErrMessageIDsExhausted once maxCallID
+// has been used. This is thread-safe, and no two calls return the same ID
+// until the next Set.
+func (m *callIDManager) Allocate() (uint64, error) {
+	for {
+		cur := m.id.Load()
+		if cur >= maxCallID {
+			return 0, ErrMessageIDsExhausted
+		}
+		if m.id.CompareAndSwap(cur, cur+1) {
+			// #nosec G115 -- cur+1 is in (0, maxCallID]
+			return uint64(cur + 1), nil
+		}
+	}
+}
+
 // Current returns the current ID without incrementing.
 func (m *callIDManager) Current() int64 {
 	return m.id.Load()
@@ -28,6 +60,7 @@ func (m *callIDManager) Current() int64 {
 
 // Set sets the current ID explicitly (e.g., during state restoration).
 // It acts as a memory fence ensuring subsequent Load/Add see this value.
+// Negative values are stored as 0.
 func (m *callIDManager) Set(val int64) {
-	m.id.Store(val)
+	m.id.Store(max(val, 0))
 }
@@ -1,8 +1,11 @@
 package client
 
 import (
+	"errors"
 	"sync"
 	"testing"
+
+	"github.com/smnsjas/go-psrp/powershell"
 )
 
 func TestCallIDManager_Atomic(t *testing.T) {
@@ -47,3 +50,102 @@ func TestCallIDManager_Atomic(t *testing.T) {
 		t.Errorf("Concurrent increment failed: got %d, want %d", got, expected)
 	}
 }
+
+func TestCallIDManager_AllocateUnique(t *testing.T) {
+	m := newCallIDManager()
+	m.Set(2) // as after connect
+
+	const goroutines = 64
+	const iterations = 5000
+	ids := make([][]uint64, goroutines)
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range iterations {
+				id, err := m.Allocate()
+				if err != nil {
+					t.Errorf("Allocate() error = %v", err)
+					return
+				}
+				ids[g] = append(ids[g], id)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[uint64]bool, goroutines*iterations)
+	for _, list := range ids {
+		for _, id := range list {
+			if seen[id] {
+				t.Fatalf("ID %d allocated twice", id)
+			}
+			seen[id] = true
+		}
+	}
+	// The IDs are exactly 3..2+goroutines*iterations
+	for id := uint64(3); id <= 2+goroutines*iterations; id++ {
+		if !seen[id] {
+			t.Fatalf("ID %d never allocated", id)
+		}
+	}
+}
+
+func TestCallIDManager_AllocateExhausted(t *testing.T) {
+	m := newCallIDManager()
+	m.Set(maxCallID - 100)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allocated := make(map[uint64]bool)
+	var exhausted int
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				id, err := m.Allocate()
+				mu.Lock()
+				switch {
+				case errors.Is(err, ErrMessageIDsExhausted):
+					exhausted++
+				case err != nil:
+					t.Errorf("Allocate() error = %v", err)
+				case allocated[id]:
+					t.Errorf("ID %d allocated twice", id)
+				default:
+					allocated[id] = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(allocated) != 100 || exhausted != 400 {
+		t.Errorf("allocated %d, exhausted %d; want 100 and 400", len(allocated), exhausted)
+	}
+	for id := range allocated {
+		if id >= powershell.HostObjectIDBase {
+			t.Errorf("ID %d is in the host response range", id)
+		}
+	}
+	if got := m.Current(); got != maxCallID {
+		t.Errorf("Current() = %d, want %d", got, int64(maxCallID))
+	}
+
+	// A new session starts over
+	m.Set(2)
+	if id, err := m.Allocate(); err != nil || id != 3 {
+		t.Errorf("Allocate() after Set = %d, %v; want 3", id, err)
+	}
+}
+
+func TestCallIDManager_SetNegative(t *testing.T) {
+	m := newCallIDManager()
+	m.Set(-5)
+	if id, err := m.Allocate(); err != nil || id != 1 {
+		t.Errorf("Allocate() = %d, %v; want 1", id, err)
+	}
+}
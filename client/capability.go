@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/smnsjas/go-psrp/powershell"
+)
+
+// ErrIncompatibleVersion is returned when an operation requires a newer
+// protocol version than the one negotiated with the server.
+var ErrIncompatibleVersion = errors.New("operation not supported by the server's protocol version")
+
+// minDisconnectProtocolVersion is the first PSRP protocol version that supports
+// disconnecting from and reconnecting to a RunspacePool (PowerShell 3.0).
+const minDisconnectProtocolVersion = "2.2"
+
+// SessionCapability holds the versions the server sent in its
+// SESSION_CAPABILITY message when the RunspacePool was opened or reconnected.
+type SessionCapability struct {
+	// ProtocolVersion is the PSRP protocol version (e.g., "2.3").
+	ProtocolVersion string
+
+	// PSVersion is the PowerShell version the server declares for remoting
+	// (e.g., "2.0"). It is not the version of the PowerShell engine.
+	PSVersion string
+
+	// SerializationVersion is the CLIXML serialization version (e.g., "1.1.0.1").
+	SerializationVersion string
+}
+
+// ProtocolAtLeast reports whether the negotiated protocol version is at least minVersion.
+// Returns false if the protocol version is unknown or cannot be parsed.
+func (s *SessionCapability) ProtocolAtLeast(minVersion string) bool {
+	if s == nil || s.ProtocolVersion == "" {
+		return false
+	}
+	cmp, err := compareVersions(s.ProtocolVersion, minVersion)
+	if err != nil {
+		return false
+	}
+	return cmp >= 0
+}
+
+// SessionCapability returns the versions the server sent in its
+// SESSION_CAPABILITY message during the last Connect or Reconnect. It
+// connects first if the client is lazy. No command is run on the server.
+func (c *Client) SessionCapability(ctx context.Context) (*SessionCapability, error) {
+	if err := c.connectLazily(ctx); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.connected {
+		return nil, ErrNotConnected
+	}
+	if c.capability == nil {
+		return nil, errors.New("server sent no session capability")
+	}
+	capability := *c.capability
+	return &capability, nil
+}
+
+// recordCapabilityLocked takes the server's SESSION_CAPABILITY from the
+// backend after a handshake (caller must hold c.mu). Backends that do not
+// report it leave the capability unknown.
+func (c *Client) recordCapabilityLocked() {
+	c.capability = nil
+	reporter, ok := c.backend.(powershell.CapabilityReporter)
+	if !ok {
+		return
+	}
+	server, ok := reporter.ServerCapability()
+	if !ok {
+		return
+	}
+	c.capability = &SessionCapability{
+		ProtocolVersion:      server.ProtocolVersion,
+		PSVersion:            server.PSVersion,
+		SerializationVersion: server.SerializationVersion,
+	}
+	c.logInfoLocked("Server session capability: protocol=%s, PSVersion=%s, serialization=%s",
+		server.ProtocolVersion, server.PSVersion, server.SerializationVersion)
+
+	if c.endpointFacts != nil {
+		c.endpointFacts.observeCapability(c.capability)
+	}
+}
+
+// ProtocolVersion returns the PSRP protocol version of the server.
+// Returns an empty string if it is not known (e.g., before Connect).
+func (c *Client) ProtocolVersion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capability == nil {
+		return ""
+	}
+	return c.capability.ProtocolVersion
+}
+
+// PSVersion returns the PowerShell version reported by the server.
+// Returns an empty string if it is not known (e.g., before Connect).
+func (c *Client) PSVersion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capability == nil {
+		return ""
+	}
+	return c.capability.PSVersion
+}
+
+// SerializationVersion returns the CLIXML serialization version reported by the server.
+// Returns an empty string if it is not known (e.g., before Connect).
+func (c *Client) SerializationVersion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capability == nil {
+		return ""
+	}
+	return c.capability.SerializationVersion
+}
+
+// checkProtocolVersionLocked returns ErrIncompatibleVersion if the server's
+// protocol version is known and older than minVersion (caller must hold c.mu).
+// An unknown version is not treated as incompatible.
+func (c *Client) checkProtocolVersionLocked(operation, minVersion string) error {
+	if c.capability == nil || c.capability.ProtocolVersion == "" {
+		return nil
+	}
+	if c.capability.ProtocolAtLeast(minVersion) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s requires protocol %s, server has %s",
+		ErrIncompatibleVersion, operation, minVersion, c.capability.ProtocolVersion)
+}
+
+// compareVersions compares two dotted version strings numerically.
+// Missing components are treated as zero. Returns -1, 0 or 1.
+func compareVersions(a, b string) (int, error) {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	n := len(as)
+	if len(bs) > n {
+		n = len(bs)
+	}
+	for i := 0; i < n; i++ {
+		var av, bv int
+		var err error
+		if i < len(as) {
+			if av, err = strconv.Atoi(as[i]); err != nil {
+				return 0, fmt.Errorf("parse version %q: %w", a, err)
+			}
+		}
+		if i < len(bs) {
+			if bv, err = strconv.Atoi(bs[i]); err != nil {
+				return 0, fmt.Errorf("parse version %q: %w", b, err)
+			}
+		}
+		if av < bv {
+			return -1, nil
+		}
+		if av > bv {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/powershell"
+)
+
+// capabilityBackend is a MockBackend that reports a server capability.
+type capabilityBackend struct {
+	*MockBackend
+	capability powershell.SessionCapability
+	ok         bool
+}
+
+func (b *capabilityBackend) ServerCapability() (powershell.SessionCapability, bool) {
+	return b.capability, b.ok
+}
+
+func TestClient_RecordCapability(t *testing.T) {
+	backend := &capabilityBackend{
+		MockBackend: &MockBackend{},
+		capability: powershell.SessionCapability{
+			ProtocolVersion:      "2.3",
+			PSVersion:            "2.0",
+			SerializationVersion: "1.1.0.1",
+		},
+		ok: true,
+	}
+	c := &Client{config: DefaultConfig(), backend: backend, connected: true}
+
+	c.mu.Lock()
+	c.recordCapabilityLocked()
+	c.mu.Unlock()
+
+	got, err := c.SessionCapability(context.Background())
+	if err != nil {
+		t.Fatalf("SessionCapability() error = %v", err)
+	}
+	want := SessionCapability{ProtocolVersion: "2.3", PSVersion: "2.0", SerializationVersion: "1.1.0.1"}
+	if *got != want {
+		t.Errorf("SessionCapability() = %+v, want %+v", *got, want)
+	}
+
+	// A handshake without SESSION_CAPABILITY forgets the old one
+	backend.ok = false
+	c.mu.Lock()
+	c.recordCapabilityLocked()
+	c.mu.Unlock()
+	if _, err := c.SessionCapability(context.Background()); err == nil {
+		t.Error("SessionCapability() expected error without a server capability")
+	}
+	if v := c.ProtocolVersion(); v != "" {
+		t.Errorf("ProtocolVersion() = %q, want empty", v)
+	}
+
+	// Backends that do not report one leave it unknown
+	c.backend = &MockBackend{}
+	c.mu.Lock()
+	c.recordCapabilityLocked()
+	c.mu.Unlock()
+	if c.capability != nil {
+		t.Errorf("capability = %+v, want nil", c.capability)
+	}
+}
+
+func TestClient_SessionCapability_NotConnected(t *testing.T) {
+	c := &Client{config: DefaultConfig()}
+	if _, err := c.SessionCapability(context.Background()); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("SessionCapability() error = %v, want ErrNotConnected", err)
+	}
+}
+
+func TestSessionCapability_ProtocolAtLeast(t *testing.T) {
+	tests := []struct {
+		version string
+		min     string
+		want    bool
+	}{
+		{"2.3", "2.2", true},
+		{"2.2", "2.2", true},
+		{"2.1", "2.2", false},
+		{"2.10", "2.2", true},
+		{"3", "2.3", true},
+		{"", "2.2", false},
+		{"bogus", "2.2", false},
+	}
+
+	for _, tt := range tests {
+		s := &SessionCapability{ProtocolVersion: tt.version}
+		if got := s.ProtocolAtLeast(tt.min); got != tt.want {
+			t.Errorf("ProtocolAtLeast(%q >= %q) = %v, want %v", tt.version, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestClient_Disconnect_IncompatibleVersion(t *testing.T) {
+	c := &Client{
+		config:     DefaultConfig(),
+		capability: &SessionCapability{ProtocolVersion: "2.1"},
+	}
+
+	err := c.Disconnect(context.Background())
+	if !errors.Is(err, ErrIncompatibleVersion) {
+		t.Errorf("Disconnect() error = %v, want ErrIncompatibleVersion", err)
+	}
+
+	if v := c.ProtocolVersion(); v != "2.1" {
+		t.Errorf("ProtocolVersion() = %q, want %q", v, "2.1")
+	}
+	if v := c.PSVersion(); v != "" {
+		t.Errorf("PSVersion() = %q, want empty", v)
+	}
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrCertificateMismatch is returned when the server certificate does not
+// match PinnedCertSHA256 or the fingerprint recorded in KnownCertsFile.
+var ErrCertificateMismatch = errors.New("client: server certificate does not match the pinned fingerprint")
+
+// knownCertsMu serializes writes to known-certs files within the process.
+var knownCertsMu sync.Mutex
+
+// parseFingerprint decodes a SHA-256 fingerprint in hex, with or without
+// colons or spaces (as printed by openssl x509 -fingerprint -sha256).
+func parseFingerprint(s string) ([]byte, error) {
+	clean := strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(s))
+	fp, err := hex.DecodeString(clean)
+	if err != nil || len(fp) != sha256.Size {
+		return nil, fmt.Errorf("invalid SHA-256 fingerprint %q", s)
+	}
+	return fp, nil
+}
+
+// certFingerprint returns the SHA-256 fingerprint of the server certificate.
+func certFingerprint(cs tls.ConnectionState) ([]byte, error) {
+	if len(cs.PeerCertificates) == 0 {
+		return nil, errors.New("client: server sent no certificate")
+	}
+	sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+	return sum[:], nil
+}
+
+// certPinner verifies server certificates by fingerprint instead of by CA:
+// against the pins if there are any, else against the fingerprint recorded
+// for host in the known-certs file, recording it on first connect.
+type certPinner struct {
+	host string // host:port, the key in the known-certs file
+	pins [][]byte
+	file string
+}
+
+// newCertPinner returns the pinner for cfg, or nil if cfg pins nothing.
+func newCertPinner(cfg *Config, host string) (*certPinner, error) {
+	if len(cfg.PinnedCertSHA256) == 0 && cfg.KnownCertsFile == "" {
+		return nil, nil
+	}
+	p := &certPinner{host: strings.ToLower(host), file: cfg.KnownCertsFile}
+	for _, pin := range cfg.PinnedCertSHA256 {
+		fp, err := parseFingerprint(pin)
+		if err != nil {
+			return nil, fmt.Errorf("PinnedCertSHA256: %w", err)
+		}
+		p.pins = append(p.pins, fp)
+	}
+	return p, nil
+}
+
+// verify implements the TLS peer verification.
+func (p *certPinner) verify(cs tls.ConnectionState) error {
+	fp, err := certFingerprint(cs)
+	if err != nil {
+		return err
+	}
+
+	if len(p.pins) > 0 {
+		for _, pin := range p.pins {
+			if bytes.Equal(fp, pin) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s presented SHA-256 %s", ErrCertificateMismatch, p.host, formatFingerprint(fp))
+	}
+
+	knownCertsMu.Lock()
+	defer knownCertsMu.Unlock()
+
+	known, line, err := lookupKnownCert(p.file, p.host)
+	if err != nil {
+		return err
+	}
+	if known == nil {
+		// Trust on first use
+		return appendKnownCert(p.file, p.host, fp)
+	}
+	if !bytes.Equal(known, fp) {
+		return fmt.Errorf("%w: %s presented SHA-256 %s, but %s:%d records %s; if the certificate was renewed, remove that line",
+			ErrCertificateMismatch, p.host, formatFingerprint(fp), p.file, line, formatFingerprint(known))
+	}
+	return nil
+}
+
+// lookupKnownCert returns the fingerprint recorded for host in file, and
+// its line number, or nil if there is none. A missing file records nothing.
+func lookupKnownCert(file, host string) ([]byte, int, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("read known certs: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || !strings.EqualFold(fields[0], host) {
+			continue
+		}
+		fp, err := parseFingerprint(fields[1])
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s:%d: %w", file, n, err)
+		}
+		return fp, n, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("read known certs: %w", err)
+	}
+	return nil, 0, nil
+}
+
+// appendKnownCert records the fingerprint for host in file.
+func appendKnownCert(file, host string, fp []byte) error {
+	if dir := filepath.Dir(file); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("record known cert: %w", err)
+		}
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("record known cert: %w", err)
+	}
+	if _, err := fmt.Fprintf(f, "%s %s\n", host, hex.EncodeToString(fp)); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("record known cert: %w", err)
+	}
+	return f.Close()
+}
+
+// formatFingerprint formats a fingerprint like openssl: AB:CD:...
+func formatFingerprint(fp []byte) string {
+	parts := make([]string, len(fp))
+	for i, b := range fp {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
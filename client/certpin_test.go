@@ -0,0 +1,129 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serverCertState returns the connection state of a server presenting a
+// new self-signed certificate, and the certificate's fingerprint.
+func serverCertState(t *testing.T) (tls.ConnectionState, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "web01"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(der)
+	return tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}, hex.EncodeToString(sum[:])
+}
+
+func TestCertPinner_Pins(t *testing.T) {
+	cs, fp := serverCertState(t)
+	other, _ := serverCertState(t)
+
+	cfg := DefaultConfig()
+	cfg.PinnedCertSHA256 = []string{strings.Repeat("00", 32), formatFingerprint(mustFingerprint(t, fp))}
+	p, err := newCertPinner(&cfg, "web01:5986")
+	if err != nil {
+		t.Fatalf("newCertPinner() error = %v", err)
+	}
+	if err := p.verify(cs); err != nil {
+		t.Errorf("verify(pinned cert) error = %v", err)
+	}
+	if err := p.verify(other); !errors.Is(err, ErrCertificateMismatch) {
+		t.Errorf("verify(other cert) error = %v, want ErrCertificateMismatch", err)
+	}
+
+	cfg.PinnedCertSHA256 = []string{"not-hex"}
+	if _, err := newCertPinner(&cfg, "web01:5986"); err == nil {
+		t.Error("newCertPinner() with invalid pin: no error")
+	}
+}
+
+func TestCertPinner_TrustOnFirstUse(t *testing.T) {
+	cs, fp := serverCertState(t)
+	renewed, _ := serverCertState(t)
+
+	cfg := DefaultConfig()
+	cfg.KnownCertsFile = filepath.Join(t.TempDir(), "psrp", "known_certs")
+	p, err := newCertPinner(&cfg, "WEB01:5986")
+	if err != nil {
+		t.Fatalf("newCertPinner() error = %v", err)
+	}
+
+	// First connect records the certificate
+	if err := p.verify(cs); err != nil {
+		t.Fatalf("first verify error = %v", err)
+	}
+	data, err := os.ReadFile(cfg.KnownCertsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "web01:5986 "+fp+"\n" {
+		t.Errorf("known certs file = %q", got)
+	}
+
+	// Later connects must present the same certificate
+	if err := p.verify(cs); err != nil {
+		t.Errorf("second verify error = %v", err)
+	}
+	if err := p.verify(renewed); !errors.Is(err, ErrCertificateMismatch) {
+		t.Errorf("verify(changed cert) error = %v, want ErrCertificateMismatch", err)
+	}
+
+	// Other hosts are learned independently
+	p2, _ := newCertPinner(&cfg, "web02:5986")
+	if err := p2.verify(renewed); err != nil {
+		t.Errorf("verify for a new host error = %v", err)
+	}
+}
+
+func TestParseFingerprint(t *testing.T) {
+	hexFP := strings.Repeat("ab", 32)
+	for _, s := range []string{hexFP, strings.ToUpper(hexFP), formatFingerprint(mustFingerprint(t, hexFP)), " " + hexFP + " "} {
+		if _, err := parseFingerprint(s); err != nil {
+			t.Errorf("parseFingerprint(%q) error = %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "abcd", hexFP + "00", strings.Repeat("zz", 32)} {
+		if _, err := parseFingerprint(s); err == nil {
+			t.Errorf("parseFingerprint(%q): no error", s)
+		}
+	}
+}
+
+func mustFingerprint(t *testing.T, s string) []byte {
+	t.Helper()
+	fp, err := parseFingerprint(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fp
+}
@@ -4,22 +4,25 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
-	"unicode/utf16"
 
 	"github.com/google/uuid"
 	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrp/psquote"
 	"github.com/smnsjas/go-psrp/wsman"
 	"github.com/smnsjas/go-psrp/wsman/auth"
 	"github.com/smnsjas/go-psrp/wsman/transport"
@@ -60,15 +63,38 @@ const (
 	TransportWSMan TransportType = iota
 	// TransportHvSocket uses Hyper-V Socket (PowerShell Direct) transport.
 	TransportHvSocket
+	// TransportSSH uses the PowerShell SSH subsystem (PowerShell 7+ remoting).
+	TransportSSH
+	// TransportProcess starts a local PowerShell process in server mode and
+	// speaks OutOfProc PSRP over its stdin/stdout.
+	TransportProcess
+	// TransportNamedPipe attaches to a running local PowerShell process
+	// through its PSHost named pipe.
+	TransportNamedPipe
 )
 
 // Transport name string constants (for serialization/logging)
 const (
-	TransportNameWSMan    = "wsman"
-	TransportNameHvSocket = "hvsocket"
-	TransportNameUnknown  = "unknown"
+	TransportNameWSMan     = "wsman"
+	TransportNameHvSocket  = "hvsocket"
+	TransportNameSSH       = "ssh"
+	TransportNameProcess   = "process"
+	TransportNameNamedPipe = "namedpipe"
+	TransportNameUnknown   = "unknown"
 )
 
+// capabilities returns the capabilities of the backend for t.
+func (t TransportType) capabilities() powershell.Capabilities {
+	switch {
+	case t == TransportHvSocket:
+		return powershell.HvSocketCapabilities
+	case t.isStreamTransport():
+		return powershell.StreamCapabilities
+	default:
+		return powershell.WSManCapabilities
+	}
+}
+
 // String returns a string representation of the transport type.
 func (t TransportType) String() string {
 	switch t {
@@ -76,6 +102,12 @@ func (t TransportType) String() string {
 		return TransportNameWSMan
 	case TransportHvSocket:
 		return TransportNameHvSocket
+	case TransportSSH:
+		return TransportNameSSH
+	case TransportProcess:
+		return TransportNameProcess
+	case TransportNamedPipe:
+		return TransportNameNamedPipe
 	default:
 		return TransportNameUnknown
 	}
@@ -113,6 +145,13 @@ type ReconnectPolicy struct {
 	// Jitter adds randomness to delays to prevent thundering herd.
 	// Value between 0.0 (no jitter) and 1.0 (up to 100% jitter).
 	Jitter float64
+
+	// OnSessionLost is called when the server no longer has the session,
+	// e.g. after a WinRM service restart, and the client creates a new
+	// one. Variables, imported modules and running commands of the old
+	// session are gone; commands that failed with ErrSessionLost are not
+	// run again.
+	OnSessionLost func(cause error)
 }
 
 // DefaultReconnectPolicy returns a sensible default reconnection policy.
@@ -184,12 +223,44 @@ type Config struct {
 	// WARNING: Only use for testing.
 	InsecureSkipVerify bool
 
-	// Timeout is the operation timeout.
+	// TLS is the base TLS configuration for HTTPS, for private PKI and
+	// hardened environments: RootCAs, client Certificates, MinVersion and
+	// MaxVersion, CipherSuites, ServerName and VerifyPeerCertificate. It is
+	// cloned; InsecureSkipVerify, TLSServerName and TLSNextProtos are applied
+	// on top. MinVersion is raised to TLS 1.2 if lower. If nil, the system
+	// roots and Go's defaults are used. Only applies to WSMan transport.
+	TLS *tls.Config
+
+	// PinnedCertSHA256 lists the SHA-256 fingerprints (hex, colons allowed)
+	// of the server certificates to accept, instead of verifying them against
+	// a CA. Use it for self-signed WinRM listeners rather than
+	// InsecureSkipVerify. More than one allows for certificate renewal.
+	// Only applies to WSMan transport over HTTPS.
+	PinnedCertSHA256 []string
+
+	// KnownCertsFile is a known-hosts style file of certificate fingerprints
+	// ("host:port sha256hex" lines). Without PinnedCertSHA256, the first
+	// certificate seen for a host is trusted and recorded there, and later
+	// connections must present the same one. Only applies to WSMan transport
+	// over HTTPS.
+	KnownCertsFile string
+
+	// Timeout bounds each request to the server and each wait for a free
+	// runspace whose context has no deadline. A deadline on the context
+	// takes precedence, and WithOperationTimeout sets one per operation;
+	// WSMan requests then ask the server to give up before it (a Receive
+	// returns no output and is polled again).
 	Timeout time.Duration
 
 	// AuthType specifies the authentication type (Basic, NTLM, or Kerberos).
 	AuthType AuthType
 
+	// Authenticator, if set, authenticates WSMan requests instead of the
+	// one selected by AuthType, e.g. for a token-based gateway or a test
+	// fake. AuthType, the credentials and the Kerberos and SSPI settings
+	// are then not used for authentication. Only applies to WSMan transport.
+	Authenticator auth.Authenticator
+
 	// Username for authentication.
 	Username string
 
@@ -208,19 +279,78 @@ type Config struct {
 	KeytabPath string
 	// CCachePath is the path to the credential cache (optional).
 	CCachePath string
-
-	// TargetSPN is the Kerberos Service Principal Name (e.g., "HTTP/server.domain.com").
-	// If empty, defaults to "HTTP/<hostname>".
+	// KerberosDelegate requests Kerberos credential delegation, so commands
+	// in the remote session can reach other servers (file shares, SQL
+	// Server) as the user. The server must be trusted for delegation. Only
+	// supported on Windows (SSPI).
+	KerberosDelegate bool
+
+	// UseSSPI authenticates AuthNTLM and AuthNegotiate with native Windows
+	// SSPI instead of go-ntlmssp, so an empty Username uses the logged-on
+	// user's credentials (SSO). Only supported on Windows.
+	UseSSPI bool
+
+	// TargetSPN is the Kerberos Service Principal Name (e.g., "WSMAN/server.domain.com").
+	// If empty, defaults to "<SPNService>/<hostname>", using
+	// SPNHostnameOverride or the HostAliases name if any.
 	TargetSPN string
 
-	// Transport specifies the transport mechanism (WSMan or HvSocket).
+	// SPNService is the service class of the default SPN, e.g. "HTTP" for
+	// endpoints registered that way. If empty, "WSMAN" is used.
+	SPNService string
+
+	// SPNHostnameOverride is the host name of the default SPN, e.g. the
+	// cluster name of a load-balanced endpoint ("cluster.corp.com").
+	SPNHostnameOverride string
+
+	// SPNCanonicalize canonicalizes the host name of the default SPN
+	// (SPNStripPort, SPNUseFQDN, SPNReverseDNS). Not applied to
+	// SPNHostnameOverride. If 0, the name is used as given.
+	SPNCanonicalize SPNCanonicalization
+
+	// HostAliases maps a target as it is dialed (typically an IP address) to
+	// the host name the server is known by, e.g. {"10.0.0.5": "web01.corp.com"}.
+	// Connecting to a mapped target uses the name for the Kerberos SPN and
+	// as the TLS server name the certificate must match, unless TargetSPN or
+	// TLSServerName are set. Only applies to WSMan transport.
+	HostAliases map[string]string
+
+	// Transport specifies the transport mechanism (WSMan, HvSocket, SSH,
+	// Process or NamedPipe).
 	Transport TransportType
 
 	// VMID is the Hyper-V VM GUID (Required for TransportHvSocket).
 	VMID string
 
-	// ConfigurationName is the PowerShell configuration name (e.g., "Microsoft.Exchange").
-	// If empty, defaults to "Microsoft.PowerShell".
+	// HvSocketCompressors are offered to the guest to compress PSRP
+	// payloads over TransportHvSocket, in order of preference, e.g.
+	// powershell.DeflateCompressor{}. Stock PowerShell declines them and
+	// the session stays uncompressed; they take effect only with a guest
+	// endpoint that supports the negotiation.
+	HvSocketCompressors []powershell.Compressor
+
+	// SSH configures TransportSSH. If nil, defaults are used
+	// (port 22, ~/.ssh/known_hosts, "powershell" subsystem).
+	SSH *SSHOptions
+
+	// Process configures TransportProcess. If nil, pwsh is started with -s.
+	Process *ProcessOptions
+
+	// LoadProfile runs the PowerShell profile scripts in a local process
+	// session (TransportProcess). By default sessions run as with
+	// -NoProfile, so profile banners, prompts and modules such as
+	// PSReadLine cannot add to the output; -NoProfile is added to
+	// Process.Args if missing. Remote sessions never run profiles.
+	LoadProfile bool
+
+	// NamedPipe configures TransportNamedPipe (required).
+	NamedPipe *NamedPipeOptions
+
+	// ConfigurationName is the PowerShell session configuration to connect to
+	// (e.g., "Microsoft.Exchange", "PowerShell.7" or a JEA endpoint).
+	// If empty, defaults to "Microsoft.PowerShell". Over WSMan it selects the
+	// shell resource URI http://schemas.microsoft.com/powershell/<name>, also
+	// when reconnecting and reattaching; HvSocket passes it to the VM.
 	ConfigurationName string
 
 	// ResourceURI is the full WSMan resource URI (overrides ConfigurationName).
@@ -230,6 +360,7 @@ type Config struct {
 	// MaxRunspaces limits the number of concurrent pipeline executions.
 	// Default: 1 (safe). Set to > 1 to enable concurrent execution if server supports it.
 	// This replaces legacy MaxConcurrentCommands.
+	// With NegotiateWSManLimits it is capped at the server's per-user quotas.
 	MaxRunspaces int
 
 	// MaxConcurrentCommands is deprecated. Use MaxRunspaces instead.
@@ -241,14 +372,93 @@ type Config struct {
 
 	// KeepAliveInterval specifies the interval for sending PSRP keepalive messages
 	// (GET_AVAILABLE_RUNSPACES) to maintain session health and prevent timeouts.
-	// If 0, keepalive is disabled.
+	// If 0, keepalive is disabled. It is a shorthand for KeepAlive with
+	// only Interval set, and has no effect on WSMan.
 	KeepAliveInterval time.Duration
 
+	// KeepAlive configures the heartbeat of idle sessions, on any
+	// transport, with callbacks on missed heartbeats. It overrides
+	// KeepAliveInterval. See Client.KeepAlive.
+	KeepAlive *KeepAlivePolicy
+
+	// IdleClose closes the session after no command has run for this
+	// long, freeing its shell in the server's MaxShellsPerUser quota. The
+	// client stays usable: the next command opens a new session. Keepalive
+	// heartbeats do not count as use. If 0, sessions stay open until Close.
+	IdleClose time.Duration
+
+	// OnIdleClose, if set, is called with the usage of each session
+	// closed by IdleClose. The closure is also logged as a session_closed
+	// security event with reason "idle".
+	OnIdleClose func(SessionUsage)
+
+	// EndpointCacheFile, if set, keeps the facts learned about each
+	// endpoint (see EndpointFacts) in this file. A later client for the
+	// same endpoint uses the cached winrm/config limits instead of
+	// reading them again, and reports facts that changed in between as
+	// drift: a warning log, a connection configuration_drift security
+	// event and OnEndpointDrift.
+	EndpointCacheFile string
+
+	// OnEndpointDrift, if set, is called with the facts that differ from
+	// those in EndpointCacheFile.
+	OnEndpointDrift func(changes []FactChange)
+
 	// IdleTimeout specifies the WSMan shell idle timeout as an ISO8601 duration string (e.g., "PT1H").
 	// If empty, defaults to "PT30M" (30 minutes).
 	// Only applies to WSMan transport.
 	IdleTimeout string
 
+	// DisconnectBufferMode is what the server does with the output of
+	// running commands while the shell is disconnected: wsman.BufferModeBlock
+	// (default) pauses them when the buffer is full, wsman.BufferModeDrop
+	// keeps them running and discards the oldest output.
+	// Only applies to WSMan transport.
+	DisconnectBufferMode wsman.BufferMode
+
+	// CoalesceReceives makes the pipelines of the session share shell-level
+	// WSMan Receive requests instead of each long-polling for its own
+	// output, which cuts the request volume of many concurrent, mostly idle
+	// commands. Servers that do not return command output to shell-level
+	// receives are detected and fall back to per-command receives.
+	// Only applies to WSMan transport.
+	CoalesceReceives bool
+
+	// WSManOptions sets the MaxEnvelopeSize and the operation and Receive
+	// timeouts sent with WSMan requests. Zero values use WinRM's defaults
+	// (500KB, 60s, 1s). Only applies to WSMan transport.
+	WSManOptions wsman.ClientOptions
+
+	// Locale is the language of error and status messages from the
+	// server, e.g. "de-DE", and the UI culture of the session. It
+	// overrides WSManOptions.Locale. Default: "en-US".
+	Locale string
+
+	// DataLocale is the culture the session formats dates and numbers
+	// with, e.g. "de-DE". It overrides WSManOptions.DataLocale. Default:
+	// Locale.
+	//
+	// Locale and DataLocale are also sent as ApplicationArguments (see
+	// UICultureArgument and CultureArgument). Only applies to WSMan
+	// transport.
+	DataLocale string
+
+	// NegotiateWSManLimits reads the server's winrm/config on Connect and
+	// adopts its MaxEnvelopeSizekb, capping the timeouts at MaxTimeoutms.
+	// MaxRunspaces is capped at MaxConcurrentOperationsPerUser and
+	// MaxShellsPerUser, so that concurrent commands wait for a runspace
+	// instead of failing with a quota error; a warning is logged and a
+	// security event emitted when it is lowered. Reading the configuration
+	// needs administrator rights; if it fails, WSManOptions and MaxRunspaces
+	// are used as is. Only applies to WSMan transport.
+	NegotiateWSManLimits bool
+
+	// Telemetry enables OpenTelemetry spans and metrics, for example
+	// []TelemetryOption{WithTracerProvider(tp), WithMeterProvider(mp)}.
+	// The providers are also passed to the WSMan client unless
+	// WSManOptions sets its own.
+	Telemetry []TelemetryOption
+
 	// RunspaceOpenTimeout specifies the maximum time to wait for a runspace to open.
 	// If 0, defaults to 60 seconds.
 	RunspaceOpenTimeout time.Duration
@@ -256,9 +466,18 @@ type Config struct {
 	// EnableCBT enables Channel Binding Tokens (CBT) for NTLM authentication.
 	// When enabled, the client will include a CBT derived from the TLS server
 	// certificate in NTLM authentication, protecting against NTLM relay attacks.
-	// Requires HTTPS (UseTLS: true). Only applies to NTLM authentication.
+	// Requires HTTPS (UseTLS: true). Only applies to NTLM authentication;
+	// Kerberos always binds to the TLS channel over HTTPS.
 	EnableCBT bool
 
+	// LazyConnect defers Connect to the first Execute, ExecuteStream or
+	// ExecuteAsync, so a client can be created without reaching the
+	// server, as interactive tools that may never run a command prefer.
+	// The first command then pays for authentication and opening the
+	// runspace pool. Services that want this cost paid up front call
+	// WarmUp instead.
+	LazyConnect bool
+
 	// Reconnect configures automatic reconnection behavior.
 	// If Reconnect.Enabled is true, the client will attempt to reconnect
 	// when the pool is broken (e.g., connection lost).
@@ -278,12 +497,139 @@ type Config struct {
 	// This prevents resource exhaustion by stopping requests after a threshold of failures.
 	CircuitBreaker *CircuitBreakerPolicy
 
-	// ProxyURL is the HTTP/HTTPS proxy server URL (e.g., "http://proxy.corp.com:8080").
+	// ProxyURL is the proxy server URL: an HTTP/HTTPS proxy
+	// ("http://proxy.corp.com:8080") or a SOCKS5 proxy ("socks5://bastion:1080",
+	// or "socks5h://" to have the proxy resolve the target name). Connections
+	// are tunneled to the target, so NTLM and Kerberos work through the proxy.
 	// Special values:
 	//   - Empty string (default): uses environment variables (HTTP_PROXY, HTTPS_PROXY, NO_PROXY)
 	//   - "direct": bypasses proxy entirely, ignoring environment variables
 	// Only applies to WSMan transport.
 	ProxyURL string
+
+	// ProxyUsername and ProxyPassword authenticate to the proxy (Basic for
+	// HTTP proxies, username/password for SOCKS5), overriding credentials
+	// in ProxyURL. Only applies to WSMan transport.
+	ProxyUsername string
+	ProxyPassword string
+
+	// GatewayAddress ("host:port") is a TLS gateway or load balancer to
+	// connect to instead of the target. The target hostname is still sent
+	// as the TLS server name (SNI) and HTTP Host header, so the gateway can
+	// route to the right WinRM backend, and Kerberos still uses its SPN.
+	// Cannot be combined with ProxyURL. Only applies to WSMan transport.
+	GatewayAddress string
+
+	// TLSServerName overrides the TLS server name (SNI), which is also the
+	// name the server certificate must match. Use it when a gateway routes
+	// on a name other than the target hostname. Only applies to WSMan transport.
+	TLSServerName string
+
+	// TLSNextProtos sets the ALPN protocols offered in the TLS handshake,
+	// for gateways that route on or require ALPN (typically "http/1.1").
+	// WSMan runs over HTTP/1.1 only, so "h2" is rejected.
+	// Only applies to WSMan transport.
+	TLSNextProtos []string
+
+	// DialContext, if set, opens the TCP connections to the target (or to
+	// the proxy or gateway), e.g. over an SSH port forward or a WireGuard
+	// tunnel. TLS and authentication run on top of the connections it
+	// returns. Workers created for parallel execution use it too.
+	// Only applies to WSMan transport.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Serialization configures how Go values sent as pipeline input or as
+	// Invoke and ExecuteTemplate parameter values are converted before
+	// CLIXML serialization (depth limit, enum handling, custom type hook).
+	// If nil, pipeline input is passed to the serializer unchanged and
+	// parameter values use DefaultSerializationOptions.
+	Serialization *SerializationOptions
+
+	// DateTimes configures normalization of DateTime values in Execute results.
+	// If nil, DateTimes are returned exactly as deserialized.
+	DateTimes *DateTimeOptions
+
+	// Quota configures a client-side execution quota on Execute
+	// (e.g., "max N ops/minute per target"). If nil, no quota is enforced.
+	Quota *QuotaPolicy
+
+	// Maintenance restricts Execute to the target's maintenance windows;
+	// other calls are rejected or queued. ContextWithMaintenanceOverride
+	// lets calls run anyway. If nil, calls run at any time.
+	Maintenance *MaintenancePolicy
+
+	// ResultCache is an optional cache for read-only queries executed with
+	// ExecOptions.Cacheable. It may be shared between clients. If nil, nothing is cached.
+	ResultCache *ResultCache
+
+	// EndpointRecovery configures a WinRS fallback that diagnoses (and
+	// optionally repairs) the PSRP endpoint when shell creation keeps failing.
+	// Only applies to the WSMan transport. If nil, no recovery is attempted.
+	EndpointRecovery *EndpointRecoveryPolicy
+
+	// Host answers host calls from scripts (Read-Host, prompts for mandatory
+	// parameters, -Confirm choices) and receives Write-Host output.
+	// Use powershell.PromptPolicy for unattended automation.
+	// Only applies to the WSMan transport. If nil, the server has no host and
+	// fails commands that prompt.
+	Host powershell.HostInterface
+
+	// HostInfo is the console size, colors and title reported with Host,
+	// so scripts see a real $Host.UI.RawUI and output is formatted to
+	// HostInfo.BufferSize.Width columns. If nil, powershell.DefaultHostInfo
+	// (120 columns) is used. Ignored without Host.
+	HostInfo *powershell.HostInfo
+
+	// MaxBandwidthBytesPerSec caps the combined rate at which the client
+	// sends and receives, in bytes per second, so bulk operations such as
+	// file transfers do not saturate a WAN link shared with production
+	// traffic. It applies to all WSMan requests, and to the HvSocket and
+	// SSH streams. 0 means unlimited.
+	MaxBandwidthBytesPerSec int64
+
+	// Logger receives the logs of the client and the layers under it,
+	// each with a "component" attribute: "client", "psrp", "wsman",
+	// "transport", "auth" or "hvsock". If nil, the PSRP_LOG_LEVEL and
+	// PSRP_DEBUG environment variables enable logging to slog.Default()
+	// or stderr.
+	Logger *slog.Logger
+
+	// Tags are key/value metadata for the session (e.g., team, change
+	// ticket, purpose). They are added to every log record and security
+	// event so activity can be traced back to its origin.
+	Tags map[string]string
+
+	// SendTagsToServer also sends Tags as the RunspacePool's
+	// ApplicationArguments, available on the server as
+	// $PSSenderInfo.ApplicationArguments. Only applies to the WSMan transport.
+	SendTagsToServer bool
+
+	// ApplicationArguments are sent to the server when the RunspacePool is
+	// created, available there as $PSSenderInfo.ApplicationArguments, e.g.
+	// tenant or correlation IDs for a hosting endpoint. Values may be
+	// strings, booleans, numbers, time.Time, time.Duration, uuid.UUID,
+	// []byte, slices of these and nested maps (see
+	// powershell.ValidateApplicationArguments). Tags sent with
+	// SendTagsToServer take precedence over keys of the same name. Only
+	// applies to the WSMan transport.
+	ApplicationArguments map[string]interface{}
+
+	// ScriptAttestation, if set, hashes (and optionally signs) every script
+	// before it is run and records the attestation in the security log.
+	// With a Signer, the public key is sent to the server as
+	// ApplicationArguments (WSMan only). If nil, scripts are not attested.
+	ScriptAttestation *ScriptAttestationConfig
+
+	// PreExecHook, if set, is asked before every pipeline is started
+	// (including retries and the scripts behind file transfers and other
+	// helpers) whether its script may run, e.g. to enforce allow and deny
+	// lists or require a ticket reference. Refused scripts fail with
+	// ErrExecutionDenied without reaching the server.
+	PreExecHook PreExecHook
+
+	// TransferQueue configures the queue returned by Client.Transfers.
+	// If nil, queued transfers run one at a time without a bandwidth limit.
+	TransferQueue *TransferQueueConfig
 }
 
 // LogValue implements slog.LogValuer to redact sensitive fields.
@@ -304,10 +650,34 @@ func (c Config) LogValue() slog.Value {
 		slog.String("ResourceURI", c.ResourceURI),
 		slog.Int("MaxRunspaces", c.MaxRunspaces),
 	}
+	if len(c.Tags) > 0 {
+		attrs = append(attrs, slog.Any("Tags", c.Tags))
+	}
+	if c.ProxyUsername != "" {
+		attrs = append(attrs,
+			slog.String("ProxyUsername", c.ProxyUsername),
+			slog.String("ProxyPassword", "********"), // REDACTED
+		)
+	}
+	if c.GatewayAddress != "" {
+		attrs = append(attrs, slog.String("GatewayAddress", c.GatewayAddress))
+	}
+	if c.TLSServerName != "" {
+		attrs = append(attrs, slog.String("TLSServerName", c.TLSServerName))
+	}
 
 	return slog.GroupValue(attrs...)
 }
 
+// capabilities returns the capabilities of the active backend or, before
+// the backend is created, of the configured transport.
+func (c *Client) capabilities() powershell.Capabilities {
+	if c.backend != nil {
+		return c.backend.Capabilities()
+	}
+	return c.config.Transport.capabilities()
+}
+
 // IsHvSocket returns true if the client is using the HvSocket transport.
 // It checks both the configuration and the active backend implementation.
 func (c *Client) IsHvSocket() bool {
@@ -384,35 +754,8 @@ func DefaultConfig() Config {
 	}
 }
 
-// Validate checks that the configuration is valid.
-func (c *Config) Validate() error {
-	if c.Username == "" && !auth.SupportsSSO() {
-		return errors.New("username is required")
-	}
-
-	// For Kerberos and Negotiate auth, password is optional if ccache or keytab is provided
-	if c.AuthType == AuthKerberos || c.AuthType == AuthNegotiate {
-		// Password not required if ccache or keytab is available
-		if c.CCachePath != "" || c.KeytabPath != "" {
-			return nil
-		}
-	}
-
-	// Password required for Basic, NTLM, and Kerberos/Negotiate without ccache
-	// Exception: SSO mode (empty username on Windows) doesn't need password
-	if c.Password == "" && c.Username != "" {
-		return errors.New("password is required")
-	}
-	return nil
-}
-
 func encodePowerShellScript(script string) string {
-	u16 := utf16.Encode([]rune(script))
-	buf := make([]byte, len(u16)*2)
-	for i, u := range u16 {
-		binary.LittleEndian.PutUint16(buf[i*2:], u)
-	}
-	return base64.StdEncoding.EncodeToString(buf)
+	return psquote.EncodeCommand(script)
 }
 
 // Client is a high-level PSRP client for executing PowerShell commands.
@@ -424,6 +767,8 @@ type Client struct {
 	endpoint string
 
 	transport *transport.HTTPTransport
+	// authRT routes HTTP requests through the current authenticator (WSMan only).
+	authRT *authRoundTripper
 	// wsman is the underlying WSMan client (for WSMan transport)
 	wsman *wsman.Client
 
@@ -454,9 +799,29 @@ type Client struct {
 	// File-based recovery state
 	outputFiles map[string]string // Maps PipelineID to remote file path
 
-	// Keepalive management
-	keepAliveDone chan struct{}
-	keepAliveWg   sync.WaitGroup
+	// Keepalive management, created on first use
+	keepAlive *KeepAlive
+
+	// OpenTelemetry instruments; nil if Config.Telemetry is not set
+	tel *clientTelemetry
+
+	// Activity counters reported by Stats
+	stats clientStats
+
+	// Use of the current session, for Config.IdleClose
+	session sessionState
+
+	// Facts about the endpoint, for Config.EndpointCacheFile; nil without it
+	endpointFacts *endpointFacts
+
+	// Paces all traffic, for Config.MaxBandwidthBytesPerSec; nil without it
+	limiter transport.RateLimiter
+
+	// winrm/config read for Config.NegotiateWSManLimits; nil if not read
+	serverLimits *wsman.ServerConfig
+
+	// State and health change subscriptions, created on first use
+	events *stateEvents
 
 	// Automatic reconnection
 	reconnectMgr *reconnectManager
@@ -464,8 +829,28 @@ type Client struct {
 	// Circuit Breaker (Fail Fast)
 	circuitBreaker *CircuitBreaker
 
+	// Execution quota (nil if not configured)
+	quota *executionQuota
+
+	// Maintenance windows of this target (nil if unrestricted)
+	maintenance *maintenanceGate
+
 	// Security logging (NIST SP 800-92)
 	securityLogger *SecurityLogger
+
+	// capability is the server's SESSION_CAPABILITY from the last handshake
+	// (nil if unknown)
+	capability *SessionCapability
+
+	// endpointFailures counts consecutive shell creation failures for EndpointRecovery
+	endpointFailures int
+
+	// Transfer queue (created on first use by Transfers)
+	transferQueue     *TransferQueue
+	transferQueueOnce sync.Once
+
+	// checkpoint is the RunSteps progress saved with SaveState
+	checkpoint *RunCheckpoint
 }
 
 // SessionState represents the serialized state of a client session
@@ -479,25 +864,47 @@ type SessionState struct {
 	PipelineIDs []string `json:"pipeline_ids,omitempty"` // Active pipeline IDs
 
 	// WSMan specific
-	ShellID string `json:"shell_id,omitempty"`
+	ShellID        string `json:"shell_id,omitempty"`
+	WSManSessionID string `json:"wsman_session_id,omitempty"` // WS-Management SessionId header
 
 	// HvSocket specific
 	VMID        string            `json:"vm_id,omitempty"`
 	ServiceID   string            `json:"service_id,omitempty"`
 	OutputPaths map[string]string `json:"output_paths,omitempty"` // HvSocket file recovery paths
+
+	// Checkpoint is the progress of the last RunSteps, if any
+	Checkpoint *RunCheckpoint `json:"checkpoint,omitempty"`
 }
 
-// SetSlogLogger sets the structured logger for the client and underlying components.
+// SetSlogLogger sets the structured logger for the client and the PSRP
+// pool. The transport and authentication keep the logger they were
+// created with; set Config.Logger to reach them too.
 func (c *Client) SetSlogLogger(logger *slog.Logger) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.slogLogger = logger.With("component", "client")
+	c.config.Logger = logger
+	c.slogLogger = c.componentLogger("client")
 
 	// Propagate to pool if already exists
 	if c.psrpPool != nil {
 		// Ignore error - pool may already be opened, logger will just not be set
-		_ = c.psrpPool.SetSlogLogger(logger) //nolint:errcheck // Best-effort logging config
+		_ = c.psrpPool.SetSlogLogger(c.componentLogger("psrp")) //nolint:errcheck // Best-effort logging config
+	}
+}
+
+// componentLogger returns Config.Logger for a component of the client,
+// tagged with Config.Tags, or nil if there is no logger.
+func (c *Client) componentLogger(component string) *slog.Logger {
+	return c.config.componentLogger(component)
+}
+
+// componentLogger returns Logger for a component, tagged with Tags, or nil
+// if Logger is nil.
+func (c *Config) componentLogger(component string) *slog.Logger {
+	if c.Logger == nil {
+		return nil
 	}
+	return withTags(c.Logger.With("component", component), c.Tags)
 }
 
 // logf logs a debug message if a logger is configured.
@@ -511,12 +918,21 @@ func (c *Client) logf(format string, v ...interface{}) {
 	}
 }
 
-// ensureLogger initializes the logger from environment variables if not already set.
+// ensureLogger initializes the logger from Config.Logger, or from
+// environment variables, if not already set.
 func (c *Client) ensureLogger() {
 	if c.slogLogger != nil {
 		return
 	}
+	if c.config.Logger == nil {
+		c.config.Logger = loggerFromEnv()
+	}
+	c.slogLogger = c.componentLogger("client")
+}
 
+// loggerFromEnv returns the logger enabled by the PSRP_LOG_LEVEL and
+// PSRP_DEBUG environment variables, or nil if they are unset.
+func loggerFromEnv() *slog.Logger {
 	var level slog.Level
 	envLevel := os.Getenv("PSRP_LOG_LEVEL")
 	envDebug := os.Getenv("PSRP_DEBUG")
@@ -536,26 +952,25 @@ func (c *Client) ensureLogger() {
 			if envDebug != "" {
 				level = slog.LevelDebug
 			} else {
-				return
+				return nil
 			}
 		}
 	} else if envDebug != "" {
 		level = slog.LevelDebug
 	} else {
-		return
+		return nil
 	}
 
 	// Use default logger if already configured (respects -quiet, -logfile, etc.)
 	// Only create fallback if default has no handler configured
 	defaultLogger := slog.Default()
 	if defaultLogger.Enabled(context.Background(), level) {
-		c.slogLogger = defaultLogger
-	} else {
-		// Fallback: create minimal stderr logger (for library consumers without CLI)
-		c.slogLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-			Level: level,
-		}))
+		return defaultLogger
 	}
+	// Fallback: create minimal stderr logger (for library consumers without CLI)
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+	}))
 }
 
 // logfLocked logs a debug message assuming the client lock is already held.
@@ -779,6 +1194,12 @@ func (c *Client) ReconnectSession(ctx context.Context, state *SessionState) erro
 			serviceID,
 			c.poolID,
 		)
+		backend.SetCompression(c.config.HvSocketCompressors...)
+		c.ensureLogger()
+		if logger := c.componentLogger("hvsock"); logger != nil {
+			backend.SetLogger(logger)
+		}
+		backend.SetRateLimiter(c.limiter)
 		c.backend = backend
 
 		// Connect backend to establish transport
@@ -809,8 +1230,8 @@ func (c *Client) ReconnectSession(ctx context.Context, state *SessionState) erro
 				}
 			})
 			c.ensureLogger()
-			if c.slogLogger != nil {
-				_ = c.psrpPool.SetSlogLogger(c.slogLogger) //nolint:errcheck // Best-effort logging config
+			if logger := c.componentLogger("psrp"); logger != nil {
+				_ = c.psrpPool.SetSlogLogger(logger) //nolint:errcheck // Best-effort logging config
 			}
 		}
 
@@ -831,6 +1252,7 @@ func (c *Client) ReconnectSession(ctx context.Context, state *SessionState) erro
 			if err := backend.Reattach(ctx, c.psrpPool, ""); err != nil {
 				return fmt.Errorf("backend reattach: %w", err)
 			}
+			c.recordCapabilityLocked()
 		}
 
 		c.connected = true
@@ -854,8 +1276,18 @@ func (c *Client) ReconnectSession(ctx context.Context, state *SessionState) erro
 		if c.wsman == nil {
 			return fmt.Errorf("wsman client not initialized")
 		}
+		// The server ties a disconnected shell's commands to the WSMan session
+		if state.WSManSessionID != "" {
+			c.wsman.SetSessionID(state.WSManSessionID)
+		}
 		wsmanBackend := powershell.NewWSManBackend(c.wsman, powershell.NewWSManTransport(nil, nil, ""))
 		wsmanBackend.SetResourceURI(c.buildResourceURI())
+		if c.config.DisconnectBufferMode != "" {
+			wsmanBackend.SetBufferMode(c.config.DisconnectBufferMode)
+		}
+		if c.config.CoalesceReceives {
+			wsmanBackend.SetReceiveCoalescing(true)
+		}
 		c.backend = wsmanBackend
 
 		// Use the WSMan-specific Reconnect logic via Reattach
@@ -888,8 +1320,8 @@ func (c *Client) ReconnectSession(ctx context.Context, state *SessionState) erro
 				}
 			})
 			c.ensureLogger()
-			if c.slogLogger != nil {
-				_ = c.psrpPool.SetSlogLogger(c.slogLogger) //nolint:errcheck // Best-effort logging config
+			if logger := c.componentLogger("psrp"); logger != nil {
+				_ = c.psrpPool.SetSlogLogger(logger) //nolint:errcheck // Best-effort logging config
 			}
 		}
 
@@ -897,6 +1329,7 @@ func (c *Client) ReconnectSession(ctx context.Context, state *SessionState) erro
 			if err := wsmanBackend.Reattach(ctx, c.psrpPool, state.ShellID); err != nil {
 				return fmt.Errorf("backend reattach: %w", err)
 			}
+			c.recordCapabilityLocked()
 		}
 
 		c.connected = true
@@ -928,6 +1361,11 @@ func (c *Client) SaveState(path string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	// The server process (and its runspace) ends with the connection.
+	if c.config.Transport.isStreamTransport() {
+		return fmt.Errorf("session state is not supported on %s transport", c.config.Transport)
+	}
+
 	if !c.connected && !c.closed {
 		// If we are just initialized but disconnected, we might still want to save state
 		// if we have enough info (e.g. ShellID/PoolID from previous session)
@@ -940,6 +1378,7 @@ func (c *Client) SaveState(path string) error {
 		RunspaceID:  "",            // We don't track explicit Runspace ID yet, usually implied by Pool
 		PipelineIDs: []string{},    // pipelines are tracked in runspace pool
 		OutputPaths: c.outputFiles, // Save file recovery paths
+		Checkpoint:  c.checkpoint,
 	}
 
 	// Transport specific info
@@ -952,6 +1391,9 @@ func (c *Client) SaveState(path string) error {
 		if c.backend != nil {
 			state.ShellID = c.backend.ShellID()
 		}
+		if c.wsman != nil {
+			state.WSManSessionID = c.wsman.SessionID()
+		}
 	}
 
 	// Get active pipelines from PSRP pool if available
@@ -962,6 +1404,11 @@ func (c *Client) SaveState(path string) error {
 		}
 	}
 
+	return writeStateFile(path, state)
+}
+
+// writeStateFile writes state to path as JSON readable only by the owner.
+func writeStateFile(path string, state *SessionState) error {
 	// Serialize to JSON
 	// We use json.MarshalIndent for readability
 	// We write carefully to file
@@ -998,47 +1445,66 @@ func LoadState(path string) (*SessionState, error) {
 	return &state, nil
 }
 
-// New creates a new PSRP client.
-func New(hostname string, cfg Config) (*Client, error) {
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
+// newNTLMAuthenticator returns the NTLM authenticator for an endpoint.
+// Over plain HTTP, NTLM session security is used to seal WinRM messages
+// (multipart/encrypted), so servers with AllowUnencrypted=false (the default)
+// accept the connection. Over HTTPS, TLS protects the payload and go-ntlmssp
+// is used with optional CBT.
+func newNTLMAuthenticator(endpoint string, creds auth.Credentials, enableCBT bool) auth.Authenticator {
+	if strings.HasPrefix(strings.ToLower(endpoint), "http://") {
+		return auth.NewNegotiateAuth(auth.NewNTLMProvider(creds))
 	}
+	return auth.NewNTLMAuth(creds, auth.WithCBT(enableCBT))
+}
 
-	// Build endpoint URL
-	var endpoint string
-	if strings.HasPrefix(hostname, "http://") || strings.HasPrefix(hostname, "https://") {
-		endpoint = hostname
-	} else {
-		scheme := "http"
-		if cfg.UseTLS {
-			scheme = "https"
-		}
-		endpoint = fmt.Sprintf("%s://%s:%d/wsman", scheme, hostname, cfg.Port)
+// newAuthenticator returns cfg.Authenticator, or creates the authenticator
+// for cfg's credentials and AuthType.
+func newAuthenticator(hostname, endpoint string, cfg Config) (auth.Authenticator, error) {
+	if cfg.Authenticator != nil {
+		return cfg.Authenticator, nil
 	}
+	authenticator, err := newConfiguredAuthenticator(hostname, endpoint, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if logger := cfg.componentLogger("auth"); logger != nil {
+		auth.SetLogger(authenticator, logger)
+	}
+	return authenticator, nil
+}
 
-	// Create transport with auth
-	tr := transport.NewHTTPTransport(
-		transport.WithTimeout(cfg.Timeout),
-		transport.WithInsecureSkipVerify(cfg.InsecureSkipVerify),
-		transport.WithProxy(cfg.ProxyURL),
-	)
-
-	// Apply authentication
+// newConfiguredAuthenticator creates the authenticator for cfg's
+// credentials and AuthType.
+func newConfiguredAuthenticator(hostname, endpoint string, cfg Config) (auth.Authenticator, error) {
 	creds := auth.Credentials{
 		Username: cfg.Username,
 		Password: cfg.Password,
 		Domain:   cfg.Domain,
 	}
 
+	targetSPN := cfg.targetSPN(hostname)
+
+	if cfg.UseSSPI && (cfg.AuthType == AuthNTLM || cfg.AuthType == AuthNegotiate) {
+		pkg := auth.SSPIPackageNegotiate
+		if cfg.AuthType == AuthNTLM {
+			pkg = auth.SSPIPackageNTLM
+		}
+		provider, err := auth.NewSSPISecurityProvider(auth.SSPIProviderConfig{
+			Package:     pkg,
+			TargetSPN:   targetSPN,
+			Credentials: &creds,
+			Delegate:    cfg.KerberosDelegate,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create SSPI provider: %w", err)
+		}
+		return auth.NewNegotiateAuth(provider), nil
+	}
+
 	var authenticator auth.Authenticator
 	switch cfg.AuthType {
 	case AuthNegotiate:
 		// Try Kerberos first, fall back to NTLM if Kerberos unavailable
-		targetSPN := cfg.TargetSPN
-		if targetSPN == "" {
-			// WinRM uses WSMAN/ SPN, not HTTP/
-			targetSPN = fmt.Sprintf("WSMAN/%s", hostname)
-		}
 		krbCfg := auth.KerberosProviderConfig{
 			TargetSPN:    targetSPN,
 			Realm:        cfg.Realm,
@@ -1047,25 +1513,25 @@ func New(hostname string, cfg Config) (*Client, error) {
 			CCachePath:   cfg.CCachePath,
 			Credentials:  &creds,
 			UseSSO:       auth.SupportsSSO() && cfg.Username == "",
+			Delegate:     cfg.KerberosDelegate,
 		}
 
 		provider, err := auth.NewKerberosProvider(krbCfg)
+		if errors.Is(err, auth.ErrDelegationNotSupported) {
+			// NTLM cannot delegate either; do not silently lose the second hop
+			return nil, err
+		}
 		if err != nil {
 			// Kerberos unavailable, fall back to NTLM via Negotiate header
 			// go-ntlmssp Negotiator handles Negotiate header with NTLM
-			authenticator = auth.NewNTLMAuth(creds, auth.WithCBT(cfg.EnableCBT))
+			authenticator = newNTLMAuthenticator(endpoint, creds, cfg.EnableCBT)
 		} else {
 			authenticator = auth.NewNegotiateAuth(provider)
 		}
 	case AuthNTLM:
-		authenticator = auth.NewNTLMAuth(creds, auth.WithCBT(cfg.EnableCBT))
+		authenticator = newNTLMAuthenticator(endpoint, creds, cfg.EnableCBT)
 	case AuthKerberos:
 		// Kerberos only - no fallback
-		targetSPN := cfg.TargetSPN
-		if targetSPN == "" {
-			// WinRM uses WSMAN/ SPN, not HTTP/
-			targetSPN = fmt.Sprintf("WSMAN/%s", hostname)
-		}
 		krbCfg := auth.KerberosProviderConfig{
 			TargetSPN:    targetSPN,
 			Realm:        cfg.Realm,
@@ -1074,6 +1540,7 @@ func New(hostname string, cfg Config) (*Client, error) {
 			CCachePath:   cfg.CCachePath,
 			Credentials:  &creds,
 			UseSSO:       auth.SupportsSSO() && cfg.Username == "",
+			Delegate:     cfg.KerberosDelegate,
 		}
 
 		provider, err := auth.NewKerberosProvider(krbCfg)
@@ -1085,11 +1552,130 @@ func New(hostname string, cfg Config) (*Client, error) {
 		authenticator = auth.NewBasicAuth(creds)
 	default:
 		// Fallback to Negotiate (shouldn't reach here)
-		authenticator = auth.NewNTLMAuth(creds, auth.WithCBT(cfg.EnableCBT))
+		authenticator = newNTLMAuthenticator(endpoint, creds, cfg.EnableCBT)
 	}
+	return authenticator, nil
+}
 
-	// Wrap transport with auth
-	tr.Client().Transport = authenticator.Transport(tr.Client().Transport)
+// New creates a new PSRP client.
+func New(hostname string, cfg Config) (*Client, error) {
+	cfg.applyTelemetry()
+	if cfg.Logger == nil {
+		cfg.Logger = loggerFromEnv()
+	}
+	c, err := newClient(hostname, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.EndpointCacheFile != "" && c.endpointFacts == nil {
+		c.endpointFacts = newEndpointFacts(cfg.EndpointCacheFile, c.target())
+	}
+	c.tel = newClientTelemetry(c)
+	if c.authRT != nil && c.tel != nil {
+		c.authRT.setTelemetry(c.tel)
+	}
+	return c, nil
+}
+
+// newClient creates the client for New, without telemetry.
+func newClient(hostname string, cfg Config) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	limiter := cfg.newRateLimiter()
+
+	// SSH and the local transports open their own stream in Connect;
+	// no HTTP transport or WSMan client.
+	if cfg.Transport.isStreamTransport() {
+		return &Client{
+			hostname:       hostname,
+			config:         cfg,
+			semaphore:      newPoolSemaphore(cfg.MaxRunspaces, cfg.MaxQueueSize, cfg.Timeout),
+			outputFiles:    make(map[string]string),
+			callID:         newCallIDManager(),
+			circuitBreaker: NewCircuitBreaker(cfg.CircuitBreaker),
+			quota:          newExecutionQuota(cfg.Quota),
+			maintenance:    newMaintenanceGate(cfg.Maintenance, hostname),
+			limiter:        limiter,
+		}, nil
+	}
+
+	// Build endpoint URL
+	var endpoint string
+	if strings.HasPrefix(hostname, "http://") || strings.HasPrefix(hostname, "https://") {
+		endpoint = hostname
+	} else {
+		scheme := "http"
+		if cfg.UseTLS {
+			scheme = "https"
+		}
+		endpoint = fmt.Sprintf("%s://%s:%d/wsman", scheme, hostname, cfg.Port)
+	}
+
+	// A target dialed by IP is verified against its alias
+	serverName := cfg.TLSServerName
+	var tlsConfig *tls.Config
+	if cfg.TLS != nil {
+		tlsConfig = cfg.TLS.Clone()
+		if serverName == "" {
+			serverName = tlsConfig.ServerName
+		}
+	}
+	if serverName == "" {
+		serverName = cfg.hostAlias(hostname)
+	}
+
+	var verifyPeer func(tls.ConnectionState) error
+	if u, err := url.Parse(endpoint); err == nil {
+		pinner, err := newCertPinner(&cfg, u.Host)
+		if err != nil {
+			return nil, err
+		}
+		if pinner != nil {
+			verifyPeer = pinner.verify
+		}
+	}
+
+	authenticator, err := newAuthenticator(hostname, endpoint, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// NTLM session security seals with keys the server keeps for the
+	// connection that authenticated, so every request must share it
+	maxConns := 0
+	if auth.IsConnectionBound(authenticator) {
+		maxConns = 1
+	}
+
+	// Create transport with auth
+	tr := transport.NewHTTPTransport(
+		transport.WithTimeout(cfg.Timeout),
+		transport.WithTLSConfig(tlsConfig),
+		transport.WithInsecureSkipVerify(cfg.InsecureSkipVerify || (tlsConfig != nil && tlsConfig.InsecureSkipVerify)),
+		transport.WithProxy(cfg.ProxyURL),
+		transport.WithProxyAuth(cfg.ProxyUsername, cfg.ProxyPassword),
+		transport.WithServerName(serverName),
+		transport.WithNextProtos(cfg.TLSNextProtos),
+		transport.WithPeerVerifier(verifyPeer),
+		transport.WithDialAddress(cfg.GatewayAddress),
+		transport.WithDialContext(cfg.DialContext),
+		transport.WithLogger(cfg.componentLogger("transport")),
+		transport.WithRateLimiter(limiter),
+		transport.WithMaxConnsPerHost(maxConns),
+	)
+
+	// Record the certificate and auth schemes of the endpoint underneath auth
+	var facts *endpointFacts
+	if cfg.EndpointCacheFile != "" {
+		facts = newEndpointFacts(cfg.EndpointCacheFile, endpoint)
+		facts.watchTLS(tr)
+		tr.Client().Transport = &schemeRecorder{base: tr.Client().Transport, facts: facts}
+	}
+
+	// Wrap transport with auth. The authenticator can be replaced later by UpdateCredentials.
+	authRT := newAuthRoundTripper(tr.Client().Transport, authenticator)
+	tr.Client().Transport = authRT
 
 	switch cfg.Transport {
 	case TransportHvSocket:
@@ -1110,6 +1696,9 @@ func New(hostname string, cfg Config) (*Client, error) {
 			outputFiles:    make(map[string]string),
 			callID:         newCallIDManager(),
 			circuitBreaker: NewCircuitBreaker(cfg.CircuitBreaker),
+			quota:          newExecutionQuota(cfg.Quota),
+			maintenance:    newMaintenanceGate(cfg.Maintenance, hostname),
+			limiter:        limiter,
 		}, nil
 
 	default: // WSMan
@@ -1119,11 +1708,16 @@ func New(hostname string, cfg Config) (*Client, error) {
 			config:         cfg,
 			endpoint:       endpoint,
 			transport:      tr,
-			wsman:          wsman.NewClient(endpoint, tr),
+			authRT:         authRT,
+			wsman:          wsman.NewClientWithOptions(endpoint, tr, cfg.wsmanOptions()),
 			semaphore:      newPoolSemaphore(cfg.MaxRunspaces, cfg.MaxQueueSize, cfg.Timeout),
 			outputFiles:    make(map[string]string),
 			callID:         newCallIDManager(),
 			circuitBreaker: NewCircuitBreaker(cfg.CircuitBreaker),
+			quota:          newExecutionQuota(cfg.Quota),
+			maintenance:    newMaintenanceGate(cfg.Maintenance, hostname),
+			limiter:        limiter,
+			endpointFacts:  facts,
 		}, nil
 	}
 }
@@ -1167,23 +1761,117 @@ func (c *Client) Endpoint() string {
 
 // Connect establishes a connection to the remote server.
 func (c *Client) Connect(ctx context.Context) error {
+	ctx, cancelOp := operationContext(ctx)
+	defer cancelOp()
+
 	// Wrap connection logic in Circuit Breaker
 	// If the circuit is open, this will return ErrCircuitOpen immediately.
 	// We use c.circuitBreaker if it exists (it should, initialized in New).
 	// But check for nil just in case (e.g. malformed test setup).
-	if c.circuitBreaker == nil {
-		return c.connectInternal(ctx)
+	connect := c.connectInternal
+	if c.circuitBreaker != nil {
+		connect = func(ctx context.Context) error {
+			return c.circuitBreaker.Execute(func() error {
+				return c.connectInternal(ctx)
+			})
+		}
 	}
 
-	return c.circuitBreaker.Execute(func() error {
-		return c.connectInternal(ctx)
-	})
+	err := connect(ctx)
+	if err != nil && c.handleEndpointFailure(ctx, err) {
+		// Endpoint was repaired via WinRS, try once more
+		err = connect(ctx)
+	}
+	if err == nil {
+		c.mu.Lock()
+		c.endpointFailures = 0
+		c.mu.Unlock()
+		c.recordEndpointFacts()
+	}
+	return err
+}
+
+// target names the server in security logs: the host name, or a URL for
+// transports other than WSMan.
+func (c *Client) target() string {
+	switch c.config.Transport {
+	case TransportHvSocket:
+		return "hvsocket://" + c.config.VMID
+	case TransportSSH:
+		return "ssh://" + c.hostname
+	case TransportProcess, TransportNamedPipe:
+		return c.config.localTarget()
+	}
+	return c.hostname
 }
 
 // connectInternal performs the actual connection logic.
+// negotiateWSManLimits adopts the server's envelope size and timeout
+// limits from winrm/config. Failures are logged; the configured options
+// stay in effect. c.mu must be held.
+func (c *Client) negotiateWSManLimits(ctx context.Context) {
+	c.serverLimits = nil
+	if cached := c.cachedServerConfigLocked(); cached != nil {
+		c.wsman.AdoptServerConfig(cached)
+		c.serverLimits = cached
+		c.logInfoLocked("WSMan limits from endpoint cache: MaxEnvelopeSize=%d MaxTimeout=%s", cached.MaxEnvelopeSize, cached.MaxTimeout)
+		return
+	}
+	cfg, err := c.wsman.NegotiateOptions(ctx)
+	if err != nil {
+		if c.slogLogger != nil {
+			c.slogLogger.Warn("could not read server WSMan limits, using configured options", "error", err)
+		}
+		return
+	}
+	c.logInfoLocked("WSMan limits from server: MaxEnvelopeSize=%d MaxTimeout=%s", cfg.MaxEnvelopeSize, cfg.MaxTimeout)
+	c.serverLimits = cfg
+	if c.endpointFacts != nil {
+		c.endpointFacts.observeServerConfig(cfg)
+	}
+}
+
+// clampRunspacesLocked caps maxRunspaces at the per-user quotas of the
+// server's winrm/config: each running command is a WSMan operation, and a
+// client beyond the quota gets errors instead of waiting. It warns when the
+// configured value is lowered. c.mu must be held.
+func (c *Client) clampRunspacesLocked(maxRunspaces int) int {
+	limits := c.serverLimits
+	if limits == nil {
+		return maxRunspaces
+	}
+	quota := maxRunspaces
+	for _, n := range []int{limits.MaxConcurrentOperationsPerUser, limits.MaxShellsPerUser} {
+		if n > 0 {
+			quota = min(quota, n)
+		}
+	}
+	if quota == maxRunspaces {
+		return maxRunspaces
+	}
+
+	if c.slogLogger != nil {
+		c.slogLogger.Warn("MaxRunspaces exceeds the server's per-user quota, lowering it",
+			"configured", maxRunspaces,
+			"max_runspaces", quota,
+			"max_concurrent_operations_per_user", limits.MaxConcurrentOperationsPerUser,
+			"max_shells_per_user", limits.MaxShellsPerUser)
+	}
+	if c.securityLogger != nil {
+		c.securityLogger.LogConnection(SubtypeConnQuotaClamped, OutcomeSuccess, SeverityWarning, map[string]any{
+			"configured_max_runspaces":           maxRunspaces,
+			"max_runspaces":                      quota,
+			"max_concurrent_operations_per_user": limits.MaxConcurrentOperationsPerUser,
+			"max_shells_per_user":                limits.MaxShellsPerUser,
+		})
+	}
+	return quota
+}
+
 func (c *Client) connectInternal(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer c.notifyStateChangeLocked()
 
 	if c.closed {
 		return errors.New("client is closed")
@@ -1195,17 +1883,15 @@ func (c *Client) connectInternal(ctx context.Context) error {
 	// 1. Create Backend
 	// Note: We generate poolID here to pass to backend if needed (HvSocket needs it for Adapter)
 	c.poolID = uuid.New()
+	c.capability = nil // Renegotiated per session
 
 	// Ensure logger is initialized early
 	c.ensureLogger()
 	c.logInfoLocked("Initializing new session with PoolID %s", c.poolID)
 
 	// Initialize security logger (NIST SP 800-92)
-	target := c.hostname
-	if c.config.Transport == TransportHvSocket {
-		target = "hvsocket://" + c.config.VMID
-	}
-	c.securityLogger = NewSecurityLogger(c.slogLogger, c.config.Username, target)
+	c.securityLogger = NewSecurityLogger(c.slogLogger, c.config.Username, c.target())
+	c.securityLogger.tags = c.config.Tags
 	c.securityLogger.LogConnection(SubtypeConnEstablished, OutcomeSuccess, SeverityInfo, map[string]any{
 		"pool_id":   c.poolID.String(),
 		"transport": c.config.Transport.String(),
@@ -1225,7 +1911,7 @@ func (c *Client) connectInternal(ctx context.Context) error {
 			// New was creating Client. Config has string.
 			// We'll parse again.
 
-			c.backend = powershell.NewHvSocketBackend(
+			backend := powershell.NewHvSocketBackend(
 				vmID,
 				c.config.Domain,
 				c.config.Username,
@@ -1233,11 +1919,29 @@ func (c *Client) connectInternal(ctx context.Context) error {
 				c.config.ConfigurationName,
 				c.poolID,
 			)
+			backend.SetCompression(c.config.HvSocketCompressors...)
+			if logger := c.componentLogger("hvsock"); logger != nil {
+				backend.SetLogger(logger)
+			}
+			backend.SetRateLimiter(c.limiter)
+			c.backend = backend
+		case TransportSSH:
+			backend, err := c.newSSHBackend()
+			if err != nil {
+				return fmt.Errorf("create ssh backend: %w", err)
+			}
+			c.backend = backend
+		case TransportProcess, TransportNamedPipe:
+			c.backend = c.newLocalBackend()
 		case TransportWSMan:
 			// Ensure wsman client is set (it should be from New)
 			if c.wsman == nil {
 				return fmt.Errorf("wsman client not initialized")
 			}
+			if c.config.NegotiateWSManLimits {
+				c.negotiateWSManLimits(ctx)
+			}
+
 			// Create WSMan transport
 			wTransport := powershell.NewWSManTransport(c.wsman, nil, "") // EPR will be set by Init
 
@@ -1249,6 +1953,22 @@ func (c *Client) connectInternal(ctx context.Context) error {
 			if c.config.IdleTimeout != "" {
 				wsmanBackend.SetIdleTimeout(c.config.IdleTimeout)
 			}
+			if c.config.DisconnectBufferMode != "" {
+				wsmanBackend.SetBufferMode(c.config.DisconnectBufferMode)
+			}
+			if c.config.CoalesceReceives {
+				wsmanBackend.SetReceiveCoalescing(true)
+			}
+			args, err := c.config.applicationArguments()
+			if err != nil {
+				return err
+			}
+			if len(args) > 0 {
+				wsmanBackend.SetApplicationArguments(args)
+			}
+			if c.config.Host != nil {
+				wsmanBackend.SetHostInfo(c.config.hostInfo())
+			}
 
 			c.backend = wsmanBackend
 		default: // WSMan
@@ -1260,6 +1980,12 @@ func (c *Client) connectInternal(ctx context.Context) error {
 			if c.config.IdleTimeout != "" {
 				backend.SetIdleTimeout(c.config.IdleTimeout)
 			}
+			if c.config.DisconnectBufferMode != "" {
+				backend.SetBufferMode(c.config.DisconnectBufferMode)
+			}
+			if c.config.CoalesceReceives {
+				backend.SetReceiveCoalescing(true)
+			}
 			c.backend = backend
 		}
 	}
@@ -1288,11 +2014,8 @@ func (c *Client) connectInternal(ctx context.Context) error {
 	c.psrpPool = runspace.New(transport, c.poolID)
 
 	// Propagate logger if configured
-	if c.slogLogger != nil {
-		_ = c.psrpPool.SetSlogLogger(c.slogLogger) //nolint:errcheck // Best-effort logging config
-	} else if os.Getenv("PSRP_DEBUG") != "" || os.Getenv("PSRP_LOG_LEVEL") != "" {
-		// Enable debug logging if PSRP_DEBUG is set (legacy fallback) or PSRP_LOG_LEVEL is set
-		c.psrpPool.EnableDebugLogging()
+	if logger := c.componentLogger("psrp"); logger != nil {
+		_ = c.psrpPool.SetSlogLogger(logger) //nolint:errcheck // Best-effort logging config
 	}
 
 	// Configure pool size for concurrent execution
@@ -1305,6 +2028,7 @@ func (c *Client) connectInternal(ctx context.Context) error {
 	if maxRunspaces <= 0 {
 		maxRunspaces = 1
 	}
+	maxRunspaces = c.clampRunspacesLocked(maxRunspaces)
 
 	// Pool configuration errors can only occur if called after Open(), which hasn't happened yet
 	_ = c.psrpPool.SetMinRunspaces(1)            //nolint:errcheck // Called before Open()
@@ -1326,8 +2050,9 @@ func (c *Client) connectInternal(ctx context.Context) error {
 			"error": err.Error(),
 			"stage": "backend_init",
 		})
-		return fmt.Errorf("init backend: %w", err)
+		return backendInitError(err)
 	}
+	c.recordCapabilityLocked()
 	// Log successful session establishment
 	c.securityLogger.LogSession(SubtypeSessionOpened, OutcomeSuccess, SeverityInfo, map[string]any{
 		"pool_id":       c.poolID.String(),
@@ -1366,6 +2091,7 @@ func (c *Client) connectInternal(ctx context.Context) error {
 	}
 
 	c.connected = true
+	c.startSessionLocked()
 
 	// Initialize messageID counter.
 	// WSMan Shell creation sends messages 1 (SESSION_CAPABILITY) and 2 (INIT_RUNSPACEPOOL)
@@ -1384,9 +2110,9 @@ func (c *Client) connectInternal(ctx context.Context) error {
 	}
 
 	// Start keepalive loop if configured AND supported by the backend.
-	// WSMan uses WS-MAN level keepalive on Receive operations instead.
-	if c.config.KeepAliveInterval > 0 && c.backend.SupportsPSRPKeepalive() {
-		c.logInfoLocked("Starting keepalive loop (Interval: %v)", c.config.KeepAliveInterval)
+	// Without an explicit policy, WSMan relies on the WS-MAN level
+	// keepalive of its Receive operations instead.
+	if c.config.KeepAlive != nil || (c.config.KeepAliveInterval > 0 && c.backend.SupportsPSRPKeepalive()) {
 		c.startKeepaliveLocked()
 	} else if c.config.KeepAliveInterval > 0 {
 		c.logInfoLocked("PSRP keepalive disabled for this transport (using WS-MAN level keepalive)")
@@ -1395,7 +2121,7 @@ func (c *Client) connectInternal(ctx context.Context) error {
 	}
 
 	// Start automatic reconnection manager if enabled
-	if c.config.Reconnect.Enabled {
+	if c.config.Reconnect.Enabled && c.reconnectMgr == nil {
 		c.reconnectMgr = newReconnectManager(c, c.config.Reconnect)
 		c.reconnectMgr.start()
 		c.logInfoLocked("Automatic reconnection enabled (MaxAttempts: %d)", c.config.Reconnect.MaxAttempts)
@@ -1414,18 +2140,25 @@ func (c *Client) Close(ctx context.Context) error {
 
 // CloseWithStrategy closes the connection using the specified strategy.
 func (c *Client) CloseWithStrategy(ctx context.Context, strategy CloseStrategy) error {
+	ctx, cancelOp := operationContext(ctx)
+	defer cancelOp()
+
 	c.logInfo("CloseWithStrategy called (strategy: %v)", strategy)
+	defer c.notifyStateChange()
 	c.mu.Lock()
 	if c.closed {
 		c.mu.Unlock()
 		return nil
 	}
 	c.closed = true
+	c.tel.close()
+	c.stopIdleMonitorLocked()
+	usage := c.sessionUsageLocked()
 
 	// Stop keepalive loop (signal only)
-	if c.keepAliveDone != nil {
-		close(c.keepAliveDone)
-		c.keepAliveDone = nil
+	keepAlive := c.keepAlive
+	if keepAlive != nil {
+		keepAlive.signalStop()
 	}
 
 	// Stop reconnect manager
@@ -1439,7 +2172,9 @@ func (c *Client) CloseWithStrategy(ctx context.Context, strategy CloseStrategy)
 	c.mu.Unlock()
 
 	// Wait for keepalive goroutine to exit (outside lock)
-	c.keepAliveWg.Wait()
+	if keepAlive != nil {
+		keepAlive.Stop()
+	}
 
 	// Stop reconnect manager (outside lock to avoid deadlock)
 	if reconnectMgr != nil {
@@ -1454,6 +2189,11 @@ func (c *Client) CloseWithStrategy(ctx context.Context, strategy CloseStrategy)
 	// Our `Acquire` only watches context.
 	// It's acceptable for now.
 
+	// Release pipelines still waiting for a reconnect of a disconnected shell
+	if wsmanBackend, ok := backend.(*powershell.WSManBackend); ok && wsmanBackend.Disconnected() {
+		wsmanBackend.Abandon()
+	}
+
 	if strategy == CloseStrategyForce {
 		// For forced close, we just want to ensure local state is cleaned up if possible.
 		// We skip network calls.
@@ -1480,9 +2220,9 @@ func (c *Client) CloseWithStrategy(ctx context.Context, strategy CloseStrategy)
 	// Log session closed (NIST SP 800-92)
 	c.mu.Lock()
 	if c.securityLogger != nil {
-		c.securityLogger.LogSession(SubtypeSessionClosed, OutcomeSuccess, SeverityInfo, map[string]any{
-			"strategy": strategy,
-		})
+		details := usage.fields()
+		details["strategy"] = strategy
+		c.securityLogger.LogSession(SubtypeSessionClosed, OutcomeSuccess, SeverityInfo, details)
 		c.securityLogger.LogConnection(SubtypeConnClosed, OutcomeSuccess, SeverityInfo, nil)
 	}
 	c.mu.Unlock()
@@ -1511,18 +2251,23 @@ func (c *Client) State() runspace.State {
 type HealthStatus string
 
 const (
-	HealthHealthy   HealthStatus = "Healthy"
-	HealthDegraded  HealthStatus = "Degraded"  // Connected but busy or experiencing issues
-	HealthUnhealthy HealthStatus = "Unhealthy" // Disconnected, Broken, or Closed
-	HealthUnknown   HealthStatus = "Unknown"   // Initializing or unknown state
+	HealthHealthy      HealthStatus = "Healthy"
+	HealthDegraded     HealthStatus = "Degraded"     // Connected but busy or experiencing issues
+	HealthUnhealthy    HealthStatus = "Unhealthy"    // Disconnected, Broken, or Closed
+	HealthUnknown      HealthStatus = "Unknown"      // Initializing or unknown state
+	HealthReconnecting HealthStatus = "Reconnecting" // Automatic reconnection in progress
 )
 
 // Health returns the current high-level health status of the client.
 func (c *Client) Health() HealthStatus {
 	c.mu.Lock()
 	pool := c.psrpPool
+	rm := c.reconnectMgr
 	c.mu.Unlock()
 
+	if rm != nil && rm.reconnecting.Load() {
+		return HealthReconnecting
+	}
 	if pool == nil {
 		return HealthUnknown
 	}
@@ -1579,92 +2324,24 @@ func (c *Client) RunspaceUtilization() (available, total int) {
 
 // startKeepaliveLocked starts the keepalive goroutine (caller must hold c.mu).
 func (c *Client) startKeepaliveLocked() {
-	interval := c.config.KeepAliveInterval
-	if interval <= 0 {
+	k := c.keepAliveLocked()
+	k.mu.Lock()
+	policy := k.policy
+	k.mu.Unlock()
+	if policy.Interval <= 0 {
 		return
 	}
-
-	if c.keepAliveDone != nil {
-		return // Already running
-	}
-
-	c.keepAliveDone = make(chan struct{})
-	c.keepAliveWg.Add(1)
-
-	// Log must use locked version since we hold the lock
-	// But logInfoLocked expects to be called with lock held, which matches.
-	// Wait, logInfoLocked just calls slog which is thread safe?
-	// Let's check logInfoLocked implementation.
-	// It accesses c.slogLogger which is protected by mu?
-	// Yes.
-
-	// However, we want to log OUTSIDE the lock to avoid blocking logging?
-	// No, we are inside the lock. We should use logInfoLocked.
-	// But wait, the original startKeepalive logged "Starting keepalive loop" OUTSIDE the lock.
-	// We can log inside.
-	c.logInfoLocked("Starting keepalive loop logic (interval: %v)", interval)
-
-	go c.keepaliveLoop(interval)
-}
-
-// stopKeepalive stops the keepalive goroutine.
-func (c *Client) stopKeepalive() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if c.keepAliveDone != nil {
-		close(c.keepAliveDone)
-		c.keepAliveDone = nil
-	}
+	c.logInfoLocked("Starting keepalive loop (interval: %v, strategy: %v)", policy.Interval, policy.Strategy)
+	k.Start()
 }
 
 // stopKeepaliveAndWait stops the keepalive goroutine and waits for it to exit.
 func (c *Client) stopKeepaliveAndWait() {
-	c.stopKeepalive()
-	c.keepAliveWg.Wait()
-}
-
-// keepaliveLoop sends periodic GET_AVAILABLE_RUNSPACES messages.
-func (c *Client) keepaliveLoop(interval time.Duration) {
-	defer c.keepAliveWg.Done()
-
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for {
-		// handle stop signal
-		c.mu.Lock()
-		doneCh := c.keepAliveDone
-		pool := c.psrpPool
-
-		c.mu.Unlock()
-
-		if doneCh == nil {
-			return
-		}
-
-		select {
-		case <-doneCh:
-			return
-		case <-ticker.C:
-			// Send Keepalive
-			if pool == nil {
-				continue
-			}
-
-			// We need a context for the send
-			// We use a short timeout so we don't block forever
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-
-			// Send Keepalive (GET_AVAILABLE_RUNSPACES)
-			// This maintains the session and ensures connectivity.
-			c.logf("Sending Keepalive (GET_AVAILABLE_RUNSPACES)")
-			if err := pool.SendGetAvailableRunspaces(ctx); err != nil {
-				c.logWarn("Keepalive failed: %v", err)
-				// TODO: consider emitting an event or checking if connection is dead
-			}
-			cancel()
-		}
+	c.mu.Lock()
+	k := c.keepAlive
+	c.mu.Unlock()
+	if k != nil {
+		k.Stop()
 	}
 }
 
@@ -1678,6 +2355,7 @@ type Result struct {
 
 	// Errors contains deserialized ErrorRecord objects from the error stream.
 	// Populated when PowerShell writes to the error stream (non-terminating errors).
+	// ErrorRecords returns them as typed records.
 	Errors []interface{}
 
 	// Warnings contains deserialized warning messages from Write-Warning.
@@ -1690,26 +2368,92 @@ type Result struct {
 	Debug []interface{}
 
 	// Progress contains deserialized progress records from Write-Progress.
+	// ProgressRecords returns them as typed records.
 	Progress []interface{}
 
 	// Information contains deserialized information records from Write-Information.
+	// InformationRecords returns them as typed records.
 	Information []interface{}
 
 	// HadErrors is true if any error records were received or the pipeline failed.
 	HadErrors bool
+
+	// ExitCode is $LASTEXITCODE after the script: the exit code of the
+	// last native program it ran, or nil if it ran none. Only reported
+	// with ExecOptions.ReportExitCode.
+	ExitCode *int
+
+	// LastCommandSucceeded is $? after the script, the status of its
+	// last statement. Only reported with ExecOptions.ReportExitCode.
+	LastCommandSucceeded *bool
+
+	// TerminatingError is the error that stopped the script, such as a
+	// throw or a cmdlet failing under -ErrorAction Stop, from the state
+	// the pipeline failed with. Execute then returns it as the error too,
+	// along with the output written before.
+	TerminatingError *ErrorRecord
+}
+
+// outputString converts a deserialized output object to its string form.
+func outputString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case *serialization.PSObject:
+		return val.ToString
+	default:
+		return fmt.Sprintf("%v", val)
+	}
 }
 
 // Execute runs a PowerShell script on the remote server.
 // The script can be any valid PowerShell code.
 // Returns the output and any errors from execution.
 func (c *Client) Execute(ctx context.Context, script string) (*Result, error) {
+	return c.execute(ctx, script, ExecOptions{})
+}
+
+// execute implements Execute and ExecuteWithOptions.
+// script must already have the options applied (see ExecOptions.buildScript).
+func (c *Client) execute(ctx context.Context, script string, opts ExecOptions) (result *Result, err error) {
+	ctx, cancelOp := operationContext(ctx)
+	defer cancelOp()
+
 	c.logInfo("Execute called: '%s'", sanitizeScriptForLogging(script))
 
+	start := time.Now()
+	ctx, endSpan := c.tel.startExecute(ctx)
+	defer func() {
+		endSpan(result, err)
+		c.stats.recordExecute(start, err)
+	}()
+
+	// Enforce maintenance windows and the execution quota before any work is done
+	maintenanceFields, maintenanceErr := c.checkMaintenance(ctx, script)
+	if maintenanceErr != nil {
+		return nil, maintenanceErr
+	}
+	if c.quota != nil {
+		if err := c.quota.Wait(ctx); err != nil {
+			c.logWarn("Execute rejected by quota: %v", err)
+			if c.securityLogger != nil {
+				c.securityLogger.LogCommand(SubtypeCommandFailed, OutcomeDenied, SeverityWarning, map[string]any{
+					"script": sanitizeScriptForLogging(script),
+					"error":  err.Error(),
+					"reason": "quota",
+				})
+			}
+			return nil, err
+		}
+	}
+
 	// Security Logging (Start)
 	if c.securityLogger != nil {
-		c.securityLogger.LogCommand(SubtypeCommandExecute, OutcomeAttempt, SeverityInfo, map[string]any{
+		fields := map[string]any{
 			"script": sanitizeScriptForLogging(script),
-		})
+		}
+		maps.Copy(fields, maintenanceFields)
+		c.securityLogger.LogCommand(SubtypeCommandExecute, OutcomeAttempt, SeverityInfo, fields)
 	}
 
 	// Determine retry configuration
@@ -1728,7 +2472,17 @@ func (c *Client) Execute(ctx context.Context, script string) (*Result, error) {
 		}
 	}
 
-	var result *Result
+	// Have the script report $? and $LASTEXITCODE if asked to
+	statusCtx := ctx
+	if opts.ReportExitCode {
+		statusCtx = context.WithValue(ctx, execStatusKey{}, true)
+	}
+
+	// All attempts share the sinks, so that an attempt after output was
+	// written is not made
+	if opts.sinks == nil {
+		opts.sinks = newExecSinks(opts)
+	}
 
 	// Wrap execution logic in Circuit Breaker
 	operation := func() error {
@@ -1755,7 +2509,7 @@ func (c *Client) Execute(ctx context.Context, script string) (*Result, error) {
 			}
 
 			// Try execute with reconnection handling
-			res, err := c.executeWithReconnectHandling(ctx, script)
+			res, err := c.executeWithReconnectHandling(statusCtx, script, opts)
 			if err == nil {
 				// Security Logging (Success)
 				if c.securityLogger != nil {
@@ -1766,10 +2520,14 @@ func (c *Client) Execute(ctx context.Context, script string) (*Result, error) {
 						outcome = OutcomeFailure
 						severity = SeverityWarning
 					}
-					c.securityLogger.LogCommand(SubtypeCommandComplete, outcome, severity, map[string]any{
+					fields := map[string]any{
 						"had_errors": hadErrors,
 						"attempts":   attempt,
-					})
+					}
+					if res.ExitCode != nil {
+						fields["exit_code"] = *res.ExitCode
+					}
+					c.securityLogger.LogCommand(SubtypeCommandComplete, outcome, severity, fields)
 				}
 				result = res
 				return nil // Success
@@ -1779,7 +2537,8 @@ func (c *Client) Execute(ctx context.Context, script string) (*Result, error) {
 
 			// Check if error is retryable
 			// Note: executeWithReconnectHandling already handled pool-level ErrBroken
-			if !isRetryableError(err) {
+			// Output already written to ExecOptions.Stdout cannot be taken back
+			if !isRetryableError(err) || opts.sinks.written() {
 				c.logError("Execute failed (non-recoverable): %v", err)
 				// Security Logging (Failure)
 				if c.securityLogger != nil {
@@ -1843,16 +2602,19 @@ func (c *Client) Execute(ctx context.Context, script string) (*Result, error) {
 				return ctx.Err()
 			case <-time.After(delay):
 			}
+			c.tel.retried(ctx)
 		}
 		return lastErr
 	}
 
-	var err error
 	if c.circuitBreaker != nil {
 		err = c.circuitBreaker.Execute(operation)
 	} else {
 		err = operation()
 	}
+	if err == nil && result.TerminatingError != nil {
+		err = result.TerminatingError
+	}
 
 	return result, err
 }
@@ -1861,15 +2623,32 @@ func (c *Client) Execute(ctx context.Context, script string) (*Result, error) {
 //
 // If pool is broken and reconnection is enabled, waits for recovery and retries ONCE.
 // This is separate from the command retry loop above.
-func (c *Client) executeWithReconnectHandling(ctx context.Context, script string) (*Result, error) {
+func (c *Client) executeWithReconnectHandling(ctx context.Context, script string, opts ExecOptions) (*Result, error) {
+	c.mu.Lock()
+	poolID := c.poolID
+	c.mu.Unlock()
+
 	// Try execute
-	result, err := c.executeOnce(ctx, script)
+	result, err := c.executeOnce(ctx, script, opts)
+
+	// The server lost the session (e.g. WinRM restarted); the command is
+	// not retried, since its effects on the old session are unknown
+	if isSessionLostError(err) {
+		if c.config.Reconnect.Enabled {
+			if recreateErr := c.recreateSession(ctx, poolID, err); recreateErr != nil {
+				c.logError("Execute: %v", recreateErr)
+			} else {
+				c.stats.reconnects.Add(1)
+			}
+		}
+		return nil, fmt.Errorf("%w: %w", ErrSessionLost, err)
+	}
 
 	// Check if this is a pool broken error
 	isPoolBroken := c.isPoolBrokenError(err)
 
 	// If pool broken and reconnection enabled, wait for recovery
-	if isPoolBroken && c.config.Reconnect.Enabled {
+	if isPoolBroken && c.config.Reconnect.Enabled && !opts.sinks.written() {
 		c.logWarn("Execute: pool broken, waiting for reconnection...")
 
 		// Security Logging (Reconnection Start)
@@ -1893,7 +2672,7 @@ func (c *Client) executeWithReconnectHandling(ctx context.Context, script string
 			}
 
 			// Retry ONCE after reconnection
-			return c.executeOnce(ctx, script)
+			return c.executeOnce(ctx, script, opts)
 		}
 
 		c.logError("Execute: connection did not recover within timeout")
@@ -1904,12 +2683,19 @@ func (c *Client) executeWithReconnectHandling(ctx context.Context, script string
 }
 
 // executeOnce performs a single command execution attempt including waiting for results.
-func (c *Client) executeOnce(ctx context.Context, script string) (*Result, error) {
+func (c *Client) executeOnce(ctx context.Context, script string, opts ExecOptions) (*Result, error) {
 	streamResult, err := c.ExecuteStream(ctx, script)
 	if err != nil {
 		return nil, err
 	}
+	return c.collectResult(streamResult, opts, nil)
+}
 
+// collectResult consumes the streams of streamResult into a Result. If feed
+// is not nil, it runs once the streams are being consumed (e.g. to send
+// pipeline input); if it fails, the pipeline is cancelled and its error is
+// returned.
+func (c *Client) collectResult(streamResult *StreamResult, opts ExecOptions, feed func() error) (*Result, error) {
 	// Wait for results - consume streams into slices
 	var (
 		output      []interface{}
@@ -1921,55 +2707,104 @@ func (c *Client) executeOnce(ctx context.Context, script string) (*Result, error
 		information []interface{}
 	)
 
+	// ExecOptions.Stdout and Stderr get the objects as they arrive; a
+	// failed write cancels the pipeline
+	sinks := opts.sinks
+	if sinks == nil {
+		sinks = newExecSinks(opts)
+	}
+	var cancelOnce sync.Once
+
 	// Consumer loop
 	var wg sync.WaitGroup
 	wg.Add(7)
 
-	collect := func(ch <-chan *messages.Message, target *[]interface{}) {
+	collect := func(ch <-chan *messages.Message, target *[]interface{}, stream string) {
 		defer wg.Done()
 		for msg := range ch {
 			if msg == nil {
 				continue
 			}
-			deser := serialization.NewDeserializer()
-			results, err := deser.Deserialize(msg.Data)
+			results, err := deserializeCLIXML(msg.Data, c.config.DateTimes)
 			if err != nil {
 				continue
 			}
+			for _, obj := range results {
+				if sinks.writeObject(stream, obj) {
+					cancelOnce.Do(streamResult.Cancel)
+				}
+			}
 			*target = append(*target, results...)
 		}
 	}
 
-	go collect(streamResult.Output, &output)
-	go collect(streamResult.Errors, &errorsList)
-	go collect(streamResult.Warnings, &warnings)
-	go collect(streamResult.Verbose, &verbose)
-	go collect(streamResult.Debug, &debug)
-	go collect(streamResult.Progress, &progress)
-	go collect(streamResult.Information, &information)
+	// discard drains a suppressed stream without deserializing it
+	discard := func(ch <-chan *messages.Message) {
+		defer wg.Done()
+		for range ch {
+		}
+	}
+
+	collectOrDiscard := func(ch <-chan *messages.Message, target *[]interface{}, stream string, suppress bool) {
+		if suppress {
+			discard(ch)
+			return
+		}
+		collect(ch, target, stream)
+	}
+
+	go collect(streamResult.Output, &output, sinkOutput)
+	go collect(streamResult.Errors, &errorsList, sinkErrors)
+	go collect(streamResult.Warnings, &warnings, sinkWarnings)
+	go collectOrDiscard(streamResult.Verbose, &verbose, sinkVerbose, opts.SuppressVerbose)
+	go collectOrDiscard(streamResult.Debug, &debug, sinkDebug, opts.SuppressDebug)
+	go collectOrDiscard(streamResult.Progress, &progress, "", opts.SuppressProgress)
+	go collect(streamResult.Information, &information, sinkInformation)
+
+	var feedErr error
+	if feed != nil {
+		if feedErr = feed(); feedErr != nil {
+			streamResult.Cancel()
+		}
+	}
 
 	// Wait for pipeline to finish and streams to close
 	runErr := streamResult.Wait()
 	wg.Wait()
 
-	// If Wait() returned an error, propagate it for retry handling
-	if runErr != nil {
+	if feedErr != nil {
+		return nil, feedErr
+	}
+	if err := sinks.error(); err != nil {
+		return nil, err
+	}
+
+	// If Wait() returned an error, propagate it for retry handling, unless
+	// the script failed with a terminating error: that is its result
+	terminating := streamResult.TerminatingError()
+	if runErr != nil && terminating == nil {
 		return nil, runErr
 	}
 
 	// Check if there were errors
-	hadErrors := len(errorsList) > 0
+	hadErrors := len(errorsList) > 0 || terminating != nil
 
-	return &Result{
-		Output:      output,
-		Errors:      errorsList,
-		Warnings:    warnings,
-		Verbose:     verbose,
-		Debug:       debug,
-		Progress:    progress,
-		Information: information,
-		HadErrors:   hadErrors,
-	}, nil
+	result := &Result{
+		Output:           output,
+		Errors:           errorsList,
+		Warnings:         warnings,
+		Verbose:          verbose,
+		Debug:            debug,
+		Progress:         progress,
+		Information:      information,
+		HadErrors:        hadErrors,
+		TerminatingError: terminating,
+	}
+	result.applyExecStatus()
+	if sinks.writeTerminatingError(result.TerminatingError) {
+		return nil, sinks.error()
+	}
+	return result, nil
 }
 
 // startPipeline creates, prepares, and invokes a pipeline.
@@ -1988,8 +2823,39 @@ func (c *Client) startPipeline(ctx context.Context, script string) (*pipeline.Pi
 	psrpPool := c.psrpPool
 	backend := c.backend
 	callID := c.callID
+	host := c.config.Host
+	hostInfo := c.config.hostInfo()
+	hostCalls := c.capabilities().HostCalls
+	attestation := c.config.ScriptAttestation
+	securityLogger := c.securityLogger
 	c.mu.Unlock()
 
+	if err := c.checkPreExec(ctx, script); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Policy and attestation see the script as given; the status wrapper
+	// of Execute is added after them
+	var att *ScriptAttestation
+	if attestation != nil {
+		var err error
+		att, err = attestScript(script, attestation.Signer)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("attest script: %w", err)
+		}
+		if securityLogger != nil {
+			securityLogger.LogCommand(SubtypeCommandAttest, OutcomeSuccess, SeverityInfo, att.details())
+		}
+	}
+	// Invoke's command is sent as commands, which cannot be wrapped
+	cmd := commandFor(ctx, script)
+	if captureExecStatus(ctx) && cmd == nil {
+		script = withExecStatus(script)
+	}
+	if att != nil && attestation.AnnotateScript && cmd == nil {
+		script = att.annotate(script)
+	}
+
 	// DISABLED: Wait for available runspace before creating pipeline
 	// This was causing PowerShell Direct (HvSocket) to hang because many servers
 	// don't send RUNSPACE_AVAILABILITY messages. The semaphore already limits
@@ -2015,18 +2881,34 @@ func (c *Client) startPipeline(ctx context.Context, script string) (*pipeline.Pi
 	*/
 
 	// Create pipeline
-	psrpPipeline, err := psrpPool.CreatePipeline(script)
+	var psrpPipeline *pipeline.Pipeline
+	var err error
+	if cmd != nil {
+		psrpPipeline, err = c.commandPipeline(psrpPool, cmd)
+	} else {
+		psrpPipeline, err = psrpPool.CreatePipeline(script)
+	}
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("create pipeline: %w", err)
 	}
 
 	// Prepare payload
-	// #nosec G115 -- callID is always positive, starts at 0
-	msgID := uint64(callID.Next())
+	msgID, err := callID.Allocate()
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	createPipelineData, err := psrpPipeline.GetCreatePipelineDataWithID(msgID)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("get create pipeline data: %w", err)
 	}
+	if host != nil && hostCalls {
+		if createPipelineData, err = powershell.EnableHostInfo(createPipelineData); err != nil {
+			return nil, nil, nil, fmt.Errorf("enable host: %w", err)
+		}
+		if createPipelineData, err = powershell.EnableHostRawUI(createPipelineData, hostInfo); err != nil {
+			return nil, nil, nil, fmt.Errorf("enable host: %w", err)
+		}
+	}
 	payload := base64.StdEncoding.EncodeToString(createPipelineData)
 
 	// Prepare backend (retry loop for NTLM)
@@ -2058,10 +2940,35 @@ func (c *Client) startPipeline(ctx context.Context, script string) (*pipeline.Pi
 	return nil, nil, nil, fmt.Errorf("failed to start pipeline after retries due to transport error")
 }
 
+// stopPipelineTimeout bounds how long stopping a pipeline on the server may
+// take.
+const stopPipelineTimeout = 10 * time.Second
+
+// stopPipeline asks the server to stop p. It is best effort: ctx may already
+// be cancelled, so only its values are used, and failures are logged.
+func (c *Client) stopPipeline(ctx context.Context, p *pipeline.Pipeline) {
+	c.mu.Lock()
+	backend := c.backend
+	c.mu.Unlock()
+	if backend == nil || !backend.Capabilities().Signal {
+		return
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopPipelineTimeout)
+	defer cancel()
+	if err := backend.StopPipeline(stopCtx, p); err != nil {
+		c.logWarn("Stop pipeline %s: %v", p.ID(), err)
+	}
+}
+
 // ExecuteAsync starts a PowerShell script execution but returns immediately without waiting
 // for output. Returns the CommandID (PipelineID) for later recovery of output.
 // This is useful for starting long-running commands and then disconnecting.
 func (c *Client) ExecuteAsync(ctx context.Context, script string) (string, error) {
+	if err := c.connectLazily(ctx); err != nil {
+		return "", err
+	}
+
 	c.mu.Lock()
 	transportType := c.config.Transport
 	c.mu.Unlock()
@@ -2089,8 +2996,13 @@ func (c *Client) executeAsyncHvSocket(ctx context.Context, script string) (strin
 		return "", errors.New("client is closed")
 	}
 	psrpPool := c.psrpPool
+	callID := c.callID
 	c.mu.Unlock()
 
+	if err := c.checkPreExec(ctx, script); err != nil {
+		return "", err
+	}
+
 	fileID := uuid.New().String()
 	// We use $env:TEMP which resolves to the user's temp dir on the server.
 	hvSocketFile := fmt.Sprintf(`$env:TEMP\psrp_out_%s.xml`, fileID)
@@ -2151,8 +3063,10 @@ func (c *Client) executeAsyncHvSocket(ctx context.Context, script string) (strin
 
 	// Let's keep manual logic for HvSocket to avoid regressions, just extract it.
 
-	// #nosec G115 -- callID is always positive, starts at 0
-	msgID := uint64(c.callID.Next())
+	msgID, err := callID.Allocate()
+	if err != nil {
+		return "", err
+	}
 	createPipelineData, err := psrpPipeline.GetCreatePipelineDataWithID(msgID)
 	if err != nil {
 		return "", fmt.Errorf("get create pipeline data: %w", err)
@@ -2229,7 +3143,9 @@ func (c *Client) executeAsyncHvSocket(ctx context.Context, script string) (strin
 // runPipelineReceive runs a per-pipeline receive loop.
 // It reads PSRP fragments from the pipeline-specific transport and feeds them to the pipeline.
 // This is used by WSMan where each command has its own stdout stream.
-func (c *Client) runPipelineReceive(ctx context.Context, transport io.Reader, pl *pipeline.Pipeline) {
+// The ErrorRecord of a PIPELINE_STATE message that fails the pipeline goes
+// to failure, which may be nil.
+func (c *Client) runPipelineReceive(ctx context.Context, transport io.Reader, pl *pipeline.Pipeline, failure *pipelineFailure) {
 	// Read PSRP fragments from the transport and feed them to the pipeline
 	// Fragment format: ObjectId (8 bytes) + FragmentId (8 bytes) + Flags (1 byte) + BlobLength (4 bytes) + Blob
 	for {
@@ -2287,7 +3203,18 @@ func (c *Client) runPipelineReceive(ctx context.Context, transport io.Reader, pl
 				return
 			}
 
-			if err := pl.HandleMessage(msg); err != nil {
+			if msg.Type == messages.MessageTypePipelineState {
+				// go-psrpcore keeps only the text of the error
+				if rec, ok := powershell.ParsePipelineFailure(msg.Data); ok {
+					failure.set(rec)
+				}
+			}
+			if msg.Type == powershell.MessageTypePipelineHostCall {
+				if err := c.handleHostCall(ctx, transport, msg); err != nil {
+					pl.Fail(fmt.Errorf("host call: %w", err))
+					return
+				}
+			} else if err := pl.HandleMessage(msg); err != nil {
 				pl.Fail(fmt.Errorf("handle message: %w", err))
 				return
 			}
@@ -2297,6 +3224,15 @@ func (c *Client) runPipelineReceive(ctx context.Context, transport io.Reader, pl
 	}
 }
 
+// WSMan returns the WS-Management client, for operations outside
+// PowerShell such as querying WMI with Get and EnumerateAll. It is nil for
+// transports other than WSMan.
+func (c *Client) WSMan() *wsman.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.wsman
+}
+
 // ShellID returns the identifier of the underlying shell.
 // Returns empty string if not connected.
 func (c *Client) ShellID() string {
@@ -2312,9 +3248,21 @@ func (c *Client) ShellID() string {
 // The session remains running on the server and can be reconnected to later.
 // Note: This only works if the backend supports it (WSMan) or via dirty PSRP disconnect (HvSocket).
 func (c *Client) Disconnect(ctx context.Context) error {
+	ctx, cancelOp := operationContext(ctx)
+	defer cancelOp()
+
 	c.logInfo("Disconnect called")
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer c.notifyStateChangeLocked()
+
+	if c.config.Transport.isStreamTransport() {
+		return fmt.Errorf("disconnect not supported on %s transport", c.config.Transport)
+	}
+
+	if err := c.checkProtocolVersionLocked("disconnect", minDisconnectProtocolVersion); err != nil {
+		return err
+	}
 
 	// Check if backend supports Disconnect (WSMan)
 	if wsmanBackend, ok := c.backend.(*powershell.WSManBackend); ok {
@@ -2341,20 +3289,47 @@ func (c *Client) Disconnect(ctx context.Context) error {
 // Reconnect connects to an existing disconnected shell.
 // usage: client.Reconnect(ctx, shellID)
 func (c *Client) Reconnect(ctx context.Context, shellID string) error {
+	ctx, cancelOp := operationContext(ctx)
+	defer cancelOp()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer c.notifyStateChangeLocked()
+
+	if err := c.checkProtocolVersionLocked("reconnect", minDisconnectProtocolVersion); err != nil {
+		return err
+	}
+
+	// Same client, same shell: reconnect and resume the running pipelines.
+	// The pool state survives a WSMan disconnect, so no PSRP handshake is needed.
+	if wsmanBackend, ok := c.backend.(*powershell.WSManBackend); ok && c.psrpPool != nil &&
+		wsmanBackend.Disconnected() && strings.EqualFold(wsmanBackend.ShellID(), shellID) {
+		if err := wsmanBackend.Reconnect(ctx, shellID); err != nil {
+			return fmt.Errorf("backend reconnect: %w", err)
+		}
+		c.connected = true
+		return nil
+	}
 
 	// 1. Ensure backend is initialized (but not opened)
 	if c.backend == nil {
 		switch c.config.Transport {
 		case TransportHvSocket:
 			return fmt.Errorf("reconnect not supported on HvSocket transport")
+		case TransportSSH, TransportProcess, TransportNamedPipe:
+			return fmt.Errorf("reconnect not supported on %s transport", c.config.Transport)
 		default: // WSMan
 			if c.wsman == nil {
 				return fmt.Errorf("wsman client not initialized")
 			}
 			wsmanBackend := powershell.NewWSManBackend(c.wsman, powershell.NewWSManTransport(nil, nil, ""))
 			wsmanBackend.SetResourceURI(c.buildResourceURI())
+			if c.config.DisconnectBufferMode != "" {
+				wsmanBackend.SetBufferMode(c.config.DisconnectBufferMode)
+			}
+			if c.config.CoalesceReceives {
+				wsmanBackend.SetReceiveCoalescing(true)
+			}
 			c.backend = wsmanBackend
 		}
 	}
@@ -2374,10 +3349,8 @@ func (c *Client) Reconnect(ctx context.Context, shellID string) error {
 	c.psrpPool = runspace.New(transport, c.poolID)
 
 	// Configure logging
-	if c.slogLogger != nil {
-		_ = c.psrpPool.SetSlogLogger(c.slogLogger) //nolint:errcheck // Best-effort logging config
-	} else if os.Getenv("PSRP_DEBUG") != "" || os.Getenv("PSRP_LOG_LEVEL") != "" {
-		c.psrpPool.EnableDebugLogging()
+	if logger := c.componentLogger("psrp"); logger != nil {
+		_ = c.psrpPool.SetSlogLogger(logger) //nolint:errcheck // Best-effort logging config
 	}
 
 	// Configure pool size
@@ -2391,6 +3364,7 @@ func (c *Client) Reconnect(ctx context.Context, shellID string) error {
 	if err := c.backend.Reattach(ctx, c.psrpPool, shellID); err != nil {
 		return fmt.Errorf("backend reattach: %w", err)
 	}
+	c.recordCapabilityLocked()
 	c.connected = true
 
 	// Sync message ID (SessionCapability=1, ConnectRunspacePool=2 were sent)
@@ -2421,6 +3395,18 @@ func (c *Client) SetSessionID(sessionID string) {
 	}
 }
 
+// SessionID returns the WSMan SessionID, or "" on other transports.
+// A client that reconnects to this client's disconnected shell should use
+// the same SessionID (see SetSessionID and SessionState).
+func (c *Client) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.wsman == nil {
+		return ""
+	}
+	return c.wsman.SessionID()
+}
+
 // PoolID returns the PSRP RunspacePool ID.
 func (c *Client) PoolID() string {
 	c.mu.Lock()
@@ -2452,6 +3438,12 @@ type DisconnectedSession struct {
 	State     string
 	Owner     string
 	Pipelines []DisconnectedPipeline
+
+	// ResourceURI and ConfigurationName identify the session
+	// configuration of the shell. ConfigurationName is empty for a
+	// resource URI outside http://schemas.microsoft.com/powershell/.
+	ResourceURI       string
+	ConfigurationName string
 }
 
 // ListDisconnectedSessions queries the server for disconnected shells and their pipelines.
@@ -2485,10 +3477,12 @@ func (c *Client) ListDisconnectedSessions(ctx context.Context) ([]DisconnectedSe
 		}
 
 		session := DisconnectedSession{
-			ShellID: shell.ShellID,
-			Name:    shell.Name,
-			State:   shell.State,
-			Owner:   shell.Owner,
+			ShellID:           shell.ShellID,
+			Name:              shell.Name,
+			State:             shell.State,
+			Owner:             shell.Owner,
+			ResourceURI:       shell.ResourceURI,
+			ConfigurationName: configurationName(shell.ResourceURI),
 		}
 
 		// Get pipelines for this shell
@@ -2516,8 +3510,12 @@ func (c *Client) RemoveDisconnectedSession(ctx context.Context, session Disconne
 	}
 
 	// Construct EndpointReference for the session
+	resourceURI := session.ResourceURI
+	if resourceURI == "" {
+		resourceURI = wsman.ResourceURIPowerShell
+	}
 	epr := &wsman.EndpointReference{
-		ResourceURI: wsman.ResourceURIPowerShell, // Default to PowerShell URI
+		ResourceURI: resourceURI,
 		Selectors: []wsman.Selector{
 			{Name: "ShellId", Value: session.ShellID},
 		},
@@ -2530,8 +3528,7 @@ func (c *Client) RemoveDisconnectedSession(ctx context.Context, session Disconne
 	if err := wClient.Delete(ctx, epr); err != nil {
 		// If the shell is not found, it means it's already gone/closed (possibly by the Signal above).
 		// We treat this as success.
-		// Error code 2150858843 = "The request for the Windows Remote Shell with ShellId ... failed because the shell was not found"
-		if strings.Contains(err.Error(), "2150858843") || strings.Contains(err.Error(), "shell was not found") {
+		if f, ok := wsman.AsFault(err); ok && f.IsShellNotFound() {
 			return nil
 		}
 		return fmt.Errorf("delete session: %w", err)
@@ -2658,63 +3655,15 @@ func (c *Client) RecoverPipelineOutput(ctx context.Context, shellID, commandID s
 		}, nil
 	}
 
-	// 1. WSMan Specific Path
-	if wsmanBackend, ok := backend.(*powershell.WSManBackend); ok && wsmanClient != nil {
-		// Use standard PSRP pipeline logic but manually drive the transport receive loop
-		// because WSMan requires per-command receive requests.
-
-		cmdUUID, err := uuid.Parse(commandID)
-		if err != nil {
-			return nil, fmt.Errorf("invalid command id: %w", err)
-		}
-
-		// Create new pipeline
-		pl := pipeline.NewWithID(psrpPool, c.poolID, cmdUUID)
-
-		// Register with pool (so handleMessage works)
-		if err := psrpPool.AdoptPipeline(pl); err != nil {
-			return nil, fmt.Errorf("adopt pipeline: %w", err)
-		}
-
-		// Create Transport specifically for this recovered command
-		// Using the normalized IDs we ensured earlier
-		epr := wsmanBackend.EPR()
-		transport := powershell.NewWSManTransport(wsmanClient, epr, commandID)
-		transport.SetContext(ctx)
-
-		// Start the receive loop in background
-		// This reads fragments from WSMan, defragments them, and pushes Messages to the pipeline
-		go func() {
-			c.runPipelineReceive(ctx, transport, pl)
-			// Ensure pipeline is closed when transport is done
-			// pipeline doesn't have Close(), it checks for Done channel or Context
-
-		}()
-
-		// Wait for results
-		return collectResults(pl)
-	}
-
-	// 2. Generic PSRP Path (HvSocket / OutOfProc)
-	// Create a pipeline object with the specific ID
-	cmdUUID, err := uuid.Parse(commandID)
+	pl, transport, err := c.adoptPipeline(ctx, backend, psrpPool, wsmanClient != nil, commandID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid command id: %w", err)
+		return nil, err
 	}
-
-	// Create new pipeline attached to pool with specific ID
-	pl := pipeline.NewWithID(psrpPool, c.poolID, cmdUUID)
-
-	// Register with pool
-	if err := psrpPool.AdoptPipeline(pl); err != nil {
-		return nil, fmt.Errorf("adopt pipeline: %w", err)
+	if transport != nil {
+		// Receive this command's output in the background
+		go c.runPipelineReceive(ctx, transport, pl, nil)
 	}
 
-	// Ensure dispatch loop is running (safe to call multiple times)
-	// For HvSocket reconnection, Reattach calls pool.Connect which starts it.
-	// But just in case:
-	psrpPool.StartDispatchLoop()
-
-	// Wait/Drain
+	// Wait for results
 	return collectResults(pl)
 }
@@ -5,6 +5,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/smnsjas/go-psrp/powershell"
 )
 
 const (
@@ -300,3 +302,41 @@ func TestClient_IsConnected(t *testing.T) {
 		t.Error("expected IsConnected = false initially")
 	}
 }
+
+// TestClient_Capabilities verifies that capabilities come from the backend
+// once there is one, and from the configured transport before.
+func TestClient_Capabilities(t *testing.T) {
+	tests := []struct {
+		transport TransportType
+		want      powershell.Capabilities
+	}{
+		{TransportWSMan, powershell.WSManCapabilities},
+		{TransportHvSocket, powershell.HvSocketCapabilities},
+		{TransportSSH, powershell.StreamCapabilities},
+		{TransportProcess, powershell.StreamCapabilities},
+		{TransportNamedPipe, powershell.StreamCapabilities},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transport.String(), func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Transport = tt.transport
+			c := &Client{config: cfg}
+			if got := c.capabilities(); got != tt.want {
+				t.Errorf("capabilities() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	c := &Client{config: DefaultConfig()}
+	c.backend = &MockBackend{CapabilitiesFunc: func() powershell.Capabilities {
+		return powershell.HvSocketCapabilities
+	}}
+	if c.capabilities().ParallelPipelines {
+		t.Error("capabilities() ignored the backend")
+	}
+	plan := &TransferPlan{Size: 4 << 20}
+	c.planStrategy(plan, DefaultFileTransferOptions())
+	if plan.Strategy != "hvsocket-parallel" {
+		t.Errorf("Strategy = %q, want hvsocket-parallel for a backend without parallel pipelines", plan.Strategy)
+	}
+}
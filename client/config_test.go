@@ -56,3 +56,18 @@ func TestBuildResourceURI(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigurationName(t *testing.T) {
+	tests := map[string]string{
+		"http://schemas.microsoft.com/powershell/Microsoft.PowerShell": "Microsoft.PowerShell",
+		"http://schemas.microsoft.com/powershell/JEAMaintenance":       "JEAMaintenance",
+		"http://schemas.microsoft.com/wbem/wsman/1/windows/shell/cmd":  "",
+		"http://schemas.microsoft.com/powershell/":                     "",
+		"": "",
+	}
+	for uri, want := range tests {
+		if got := configurationName(uri); got != want {
+			t.Errorf("configurationName(%q) = %q, want %q", uri, got, want)
+		}
+	}
+}
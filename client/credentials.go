@@ -0,0 +1,152 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrp/wsman/auth"
+)
+
+// CredentialOption configures UpdateCredentials.
+type CredentialOption func(*Config)
+
+// WithKeytabPath sets the Kerberos keytab used for future handshakes.
+func WithKeytabPath(path string) CredentialOption {
+	return func(c *Config) {
+		c.KeytabPath = path
+	}
+}
+
+// WithCCachePath sets the Kerberos credential cache used for future handshakes.
+func WithCCachePath(path string) CredentialOption {
+	return func(c *Config) {
+		c.CCachePath = path
+	}
+}
+
+// UpdateCredentials replaces the credentials used for future authentication
+// without closing the session, so long-running services can follow secret
+// rotation policies. The new configuration is validated first; on error the
+// current credentials are kept.
+//
+// Over WSMan, the next request authenticates again on a new connection with
+// the new credentials; requests already in flight finish with the old ones.
+// The server-side RunspacePool is unaffected. For the other transports,
+// which authenticate once per connection, the credentials are used by the
+// next Connect or automatic reconnect. A custom Config.Authenticator is
+// kept as is; update the credentials it holds itself.
+func (c *Client) UpdateCredentials(creds auth.Credentials, opts ...CredentialOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cfg := c.config
+	cfg.Username = creds.Username
+	cfg.Password = creds.Password
+	cfg.Domain = creds.Domain
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("update credentials: %w", err)
+	}
+
+	if c.authRT != nil {
+		authenticator, err := newAuthenticator(c.hostname, c.endpoint, cfg)
+		if err != nil {
+			return fmt.Errorf("update credentials: %w", err)
+		}
+		c.authRT.setAuthenticator(authenticator)
+	}
+
+	switch backend := c.backend.(type) {
+	case *powershell.HvSocketBackend:
+		backend.SetCredentials(cfg.Domain, cfg.Username, cfg.Password)
+	case *powershell.SSHBackend:
+		sshConfig, err := sshClientConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("update credentials: %w", err)
+		}
+		backend.SetClientConfig(sshConfig)
+	}
+
+	c.config = cfg
+	if c.securityLogger != nil {
+		c.securityLogger.LogEvent(EventAuthentication, "credentials_updated", SeverityInfo, OutcomeSuccess, map[string]any{
+			"user": cfg.Username,
+		})
+	}
+	c.logInfoLocked("Credentials updated for user %s", cfg.Username)
+	return nil
+}
+
+// authRoundTripper sends requests through the current authenticator, which
+// UpdateCredentials replaces without recreating the HTTP client.
+type authRoundTripper struct {
+	base http.RoundTripper
+
+	mu            sync.RWMutex
+	authenticator auth.Authenticator
+	current       http.RoundTripper
+	tel           *clientTelemetry
+}
+
+func newAuthRoundTripper(base http.RoundTripper, authenticator auth.Authenticator) *authRoundTripper {
+	return &authRoundTripper{
+		base:          base,
+		authenticator: authenticator,
+		current:       authenticator.Transport(base),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (a *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	a.mu.RLock()
+	rt, scheme, tel := a.current, a.authenticator.Name(), a.tel
+	a.mu.RUnlock()
+	return tel.traceAuth(req, scheme, rt)
+}
+
+// setTelemetry records authentication handshakes with tel.
+func (a *authRoundTripper) setTelemetry(tel *clientTelemetry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tel = tel
+	a.base = &challengeCounter{base: a.base, tel: tel}
+	a.current = a.authenticator.Transport(a.base)
+}
+
+// setAuthenticator switches to a new authenticator and drops idle
+// connections, which may carry the old connection-based authentication.
+func (a *authRoundTripper) setAuthenticator(authenticator auth.Authenticator) {
+	rt := authenticator.Transport(a.base)
+	a.mu.Lock()
+	a.authenticator = authenticator
+	a.current = rt
+	a.mu.Unlock()
+	a.CloseIdleConnections()
+}
+
+// refreshCredentials refreshes expiring credentials of the current
+// authenticator, such as a Kerberos TGT, if it has any.
+func (a *authRoundTripper) refreshCredentials() error {
+	a.mu.RLock()
+	authenticator := a.authenticator
+	a.mu.RUnlock()
+	if r, ok := authenticator.(auth.CredentialRefresher); ok {
+		return r.RefreshCredentials()
+	}
+	return nil
+}
+
+// CloseIdleConnections closes idle connections of the base transport, so
+// http.Client.CloseIdleConnections reaches it through the auth wrapper.
+func (a *authRoundTripper) CloseIdleConnections() {
+	type idleCloser interface {
+		CloseIdleConnections()
+	}
+	if closer, ok := a.base.(idleCloser); ok {
+		closer.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/wsman/auth"
+)
+
+func TestClient_UpdateCredentials(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		mu.Lock()
+		seen = append(seen, user+":"+pass)
+		mu.Unlock()
+		_, _ = w.Write([]byte("<ok/>"))
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.AuthType = AuthBasic
+	cfg.Username = "svc"
+	cfg.Password = "old-secret"
+
+	c, err := New(server.URL, cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := c.transport.Post(ctx, server.URL, []byte("<a/>")); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if err := c.UpdateCredentials(auth.Credentials{Username: "svc", Password: "new-secret"}); err != nil {
+		t.Fatalf("UpdateCredentials() error = %v", err)
+	}
+	if _, err := c.transport.Post(ctx, server.URL, []byte("<a/>")); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"svc:old-secret", "svc:new-secret"}
+	if len(seen) != 2 || seen[0] != want[0] || seen[1] != want[1] {
+		t.Errorf("server saw %v, want %v", seen, want)
+	}
+	if c.config.Password != "new-secret" {
+		t.Error("config password not updated")
+	}
+}
+
+// tokenAuth authenticates with a bearer token, like a gateway would.
+type tokenAuth struct {
+	token string
+}
+
+func (a *tokenAuth) Name() string { return "Bearer" }
+
+func (a *tokenAuth) Transport(base http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+a.token)
+		return base.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestNew_CustomAuthenticator(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("<ok/>"))
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.Authenticator = &tokenAuth{token: "abc"}
+
+	c, err := New(server.URL, cfg)
+	if err != nil {
+		t.Fatalf("New() without credentials error = %v", err)
+	}
+	if _, err := c.transport.Post(context.Background(), server.URL, []byte("<a/>")); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if got != "Bearer abc" {
+		t.Errorf("Authorization = %q, want %q", got, "Bearer abc")
+	}
+}
+
+func TestNew_NTLMSealingSingleConnection(t *testing.T) {
+	for _, tt := range []struct {
+		useTLS bool
+		want   int
+	}{
+		{false, 1}, // sealed with NTLM session security
+		{true, 50},
+	} {
+		cfg := DefaultConfig()
+		cfg.AuthType = AuthNTLM
+		cfg.Username = "user"
+		cfg.Password = "pass"
+		cfg.UseTLS = tt.useTLS
+
+		c, err := New("server", cfg)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		rt, ok := c.transport.Client().Transport.(*authRoundTripper)
+		if !ok {
+			t.Fatalf("transport is %T, want *authRoundTripper", c.transport.Client().Transport)
+		}
+		base, ok := rt.base.(*http.Transport)
+		if !ok {
+			t.Fatalf("base transport is %T, want *http.Transport", rt.base)
+		}
+		if base.MaxConnsPerHost != tt.want {
+			t.Errorf("UseTLS=%v: MaxConnsPerHost = %d, want %d", tt.useTLS, base.MaxConnsPerHost, tt.want)
+		}
+	}
+}
+
+func TestClient_UpdateCredentials_Invalid(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AuthType = AuthBasic
+	cfg.Username = "svc"
+	cfg.Password = "old-secret"
+
+	c, err := New("testserver", cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if err := c.UpdateCredentials(auth.Credentials{Username: "svc"}); err == nil {
+		t.Fatal("UpdateCredentials() without password error = nil")
+	}
+	if c.config.Password != "old-secret" {
+		t.Error("failed update changed the credentials")
+	}
+
+	missing := t.TempDir() + "/missing.keytab"
+	err = c.UpdateCredentials(auth.Credentials{Username: "svc", Password: "x"}, WithKeytabPath(missing))
+	if err == nil {
+		t.Error("UpdateCredentials() with missing keytab error = nil")
+	}
+}
+
+// refreshingAuth is a Basic authenticator with expiring credentials.
+type refreshingAuth struct {
+	*auth.BasicAuth
+	refreshed int
+}
+
+func (a *refreshingAuth) RefreshCredentials() error {
+	a.refreshed++
+	return nil
+}
+
+func TestAuthRoundTripper_RefreshCredentials(t *testing.T) {
+	a := &refreshingAuth{BasicAuth: auth.NewBasicAuth(auth.Credentials{Username: "svc", Password: "x"})}
+	rt := newAuthRoundTripper(http.DefaultTransport, a)
+	if err := rt.refreshCredentials(); err != nil {
+		t.Fatalf("refreshCredentials() error = %v", err)
+	}
+	if a.refreshed != 1 {
+		t.Errorf("authenticator refreshed %d times, want 1", a.refreshed)
+	}
+
+	// Basic credentials do not expire
+	rt.setAuthenticator(auth.NewBasicAuth(auth.Credentials{Username: "svc", Password: "y"}))
+	if err := rt.refreshCredentials(); err != nil {
+		t.Errorf("refreshCredentials() after switch error = %v", err)
+	}
+	if a.refreshed != 1 {
+		t.Error("replaced authenticator was refreshed")
+	}
+}
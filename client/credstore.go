@@ -0,0 +1,134 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/smnsjas/go-psrp/wsman/auth"
+)
+
+var (
+	// ErrCredentialNotFound is returned by a CredentialStore that has no
+	// credentials for an endpoint.
+	ErrCredentialNotFound = errors.New("client: credentials not found")
+
+	// ErrCredentialStoreUnavailable is returned by SystemCredentialStore
+	// when the OS has no credential store it can use, e.g. on Linux
+	// without secret-tool.
+	ErrCredentialStoreUnavailable = errors.New("client: no system credential store")
+)
+
+// CredentialStore keeps credentials by endpoint, so that tools need not
+// take passwords from flags or the environment. Endpoints are host names;
+// they are case-insensitive.
+type CredentialStore interface {
+	// Get returns the credentials of endpoint, or ErrCredentialNotFound.
+	Get(endpoint string) (auth.Credentials, error)
+
+	// Set saves the credentials of endpoint, replacing any saved before.
+	Set(endpoint string, creds auth.Credentials) error
+
+	// Delete removes the credentials of endpoint. Deleting credentials
+	// that were never saved returns ErrCredentialNotFound.
+	Delete(endpoint string) error
+}
+
+// credentialService names the entries of SystemCredentialStore in the OS
+// store: "go-psrp:<endpoint>" on Windows, the service on macOS and
+// libsecret.
+const credentialService = "go-psrp"
+
+// SystemCredentialStore returns the credential store of the OS: Windows
+// Credential Manager, the macOS login keychain, or the Secret Service
+// (GNOME Keyring, KWallet) through libsecret's secret-tool elsewhere:
+//
+//	store, err := client.SystemCredentialStore()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	creds, err := store.Get("server01")
+//	if err == nil {
+//		cfg.Username, cfg.Password, cfg.Domain = creds.Username, creds.Password, creds.Domain
+//	}
+//
+// Entries hold the user name, domain and password of one endpoint.
+func SystemCredentialStore() (CredentialStore, error) {
+	kc, err := newSystemKeychain()
+	if err != nil {
+		return nil, err
+	}
+	return &systemCredentialStore{keychain: kc}, nil
+}
+
+// keychain keeps secrets by account in an OS credential store.
+type keychain interface {
+	get(account string) ([]byte, error)
+	set(account, label string, secret []byte) error
+	delete(account string) error
+}
+
+// systemCredentialStore is a CredentialStore on a keychain. The secret of
+// an endpoint is its credentials as JSON.
+type systemCredentialStore struct {
+	keychain keychain
+}
+
+// storedCredentials is the secret of an endpoint.
+type storedCredentials struct {
+	Username string `json:"username"`
+	Domain   string `json:"domain,omitempty"`
+	Password string `json:"password"`
+}
+
+func (s *systemCredentialStore) Get(endpoint string) (auth.Credentials, error) {
+	account, err := credentialAccount(endpoint)
+	if err != nil {
+		return auth.Credentials{}, err
+	}
+	secret, err := s.keychain.get(account)
+	if err != nil {
+		return auth.Credentials{}, err
+	}
+	var stored storedCredentials
+	if err := json.Unmarshal(secret, &stored); err != nil {
+		return auth.Credentials{}, fmt.Errorf("client: credentials of %s: %w", account, err)
+	}
+	return auth.Credentials{Username: stored.Username, Domain: stored.Domain, Password: stored.Password}, nil
+}
+
+func (s *systemCredentialStore) Set(endpoint string, creds auth.Credentials) error {
+	account, err := credentialAccount(endpoint)
+	if err != nil {
+		return err
+	}
+	secret, err := json.Marshal(storedCredentials{Username: creds.Username, Domain: creds.Domain, Password: creds.Password})
+	if err != nil {
+		return err
+	}
+	return s.keychain.set(account, fmt.Sprintf("PowerShell Remoting (%s)", account), secret)
+}
+
+func (s *systemCredentialStore) Delete(endpoint string) error {
+	account, err := credentialAccount(endpoint)
+	if err != nil {
+		return err
+	}
+	return s.keychain.delete(account)
+}
+
+// credentialAccount normalizes an endpoint into the account of its entry.
+// Quotes and control characters are rejected, since the macOS store takes
+// the account on a command line.
+func credentialAccount(endpoint string) (string, error) {
+	account := hostKey(endpoint)
+	if account == "" {
+		return "", errors.New("client: credentials need an endpoint")
+	}
+	for _, r := range account {
+		if r < ' ' || r == 0x7f || r == '"' || r == '\'' || r == '\\' {
+			return "", fmt.Errorf("client: invalid endpoint %q for a credential store", endpoint)
+		}
+	}
+	return account, nil
+}
@@ -0,0 +1,77 @@
+//go:build darwin
+
+package client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityItemNotFound is the exit status of security(1) for a missing
+// keychain item (errSecItemNotFound).
+const securityItemNotFound = 44
+
+// macKeychain keeps secrets as generic passwords of the login keychain,
+// with service "go-psrp" and the account, through security(1).
+type macKeychain struct {
+	path string
+}
+
+func newSystemKeychain() (keychain, error) {
+	path, err := exec.LookPath("security")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCredentialStoreUnavailable, err)
+	}
+	return macKeychain{path: path}, nil
+}
+
+func (k macKeychain) get(account string) ([]byte, error) {
+	// #nosec G204 -- the account is validated by credentialAccount
+	out, err := exec.Command(k.path, "find-generic-password", "-s", credentialService, "-a", account, "-w").Output()
+	if err != nil {
+		return nil, securityError("read", err)
+	}
+	return bytes.TrimSuffix(out, []byte("\n")), nil
+}
+
+func (k macKeychain) set(account, label string, secret []byte) error {
+	// The secret goes to an interactive security on stdin, as hex, so that
+	// it is never on a command line
+	cmd := exec.Command(k.path, "-i") // #nosec G204 -- fixed arguments
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a '%s' -l '%s' -X %s\n",
+		credentialService, account, strings.ReplaceAll(label, "'", ""), hex.EncodeToString(secret)))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("client: write keychain item: %s", msg)
+		}
+		return securityError("write", err)
+	}
+	return nil
+}
+
+func (k macKeychain) delete(account string) error {
+	// #nosec G204 -- the account is validated by credentialAccount
+	if err := exec.Command(k.path, "delete-generic-password", "-s", credentialService, "-a", account).Run(); err != nil {
+		return securityError("delete", err)
+	}
+	return nil
+}
+
+// securityError maps a failed security(1) run to ErrCredentialNotFound or
+// an error with its message.
+func securityError(op string, err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == securityItemNotFound {
+			return ErrCredentialNotFound
+		}
+		if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+			return fmt.Errorf("client: %s keychain item: %s", op, msg)
+		}
+	}
+	return fmt.Errorf("client: %s keychain item: %w", op, err)
+}
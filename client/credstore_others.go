@@ -0,0 +1,74 @@
+//go:build !windows && !darwin
+
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretService keeps secrets in the Secret Service (GNOME Keyring,
+// KWallet) through libsecret's secret-tool, with the attributes service
+// "go-psrp" and endpoint set to the account.
+type secretService struct {
+	path string
+}
+
+func newSystemKeychain() (keychain, error) {
+	path, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCredentialStoreUnavailable, err)
+	}
+	return secretService{path: path}, nil
+}
+
+func (s secretService) get(account string) ([]byte, error) {
+	// #nosec G204 -- the account is an argument, not a shell command
+	out, err := exec.Command(s.path, "lookup", "service", credentialService, "endpoint", account).Output()
+	if err != nil {
+		// lookup fails without a message when nothing matches
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) == 0 {
+			return nil, ErrCredentialNotFound
+		}
+		return nil, secretToolError("read", err)
+	}
+	return out, nil
+}
+
+func (s secretService) set(account, label string, secret []byte) error {
+	// store reads the secret from stdin
+	// #nosec G204 -- the account is an argument, not a shell command
+	cmd := exec.Command(s.path, "store", "--label", label, "service", credentialService, "endpoint", account)
+	cmd.Stdin = bytes.NewReader(secret)
+	if _, err := cmd.Output(); err != nil {
+		return secretToolError("write", err)
+	}
+	return nil
+}
+
+func (s secretService) delete(account string) error {
+	if _, err := s.get(account); err != nil {
+		return err
+	}
+	// #nosec G204 -- the account is an argument, not a shell command
+	if _, err := exec.Command(s.path, "clear", "service", credentialService, "endpoint", account).Output(); err != nil {
+		return secretToolError("delete", err)
+	}
+	return nil
+}
+
+// secretToolError returns an error with the message of a failed
+// secret-tool run.
+func secretToolError(op string, err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+			return fmt.Errorf("client: %s secret: %s", op, msg)
+		}
+	}
+	return fmt.Errorf("client: %s secret: %w", op, err)
+}
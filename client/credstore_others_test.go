@@ -0,0 +1,62 @@
+//go:build !windows && !darwin
+
+package client
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSecretTool is a secret-tool that keeps secrets in files named by
+// their attributes.
+const fakeSecretTool = `#!/bin/sh
+PATH=/bin:/usr/bin
+dir=$(dirname "$0")/secrets
+mkdir -p "$dir"
+cmd=$1; shift
+if [ "$cmd" = store ]; then shift 2; fi
+file="$dir/$2-$4"
+case $cmd in
+store) cat > "$file" ;;
+lookup) [ -f "$file" ] || exit 1; cat "$file" ;;
+clear) rm -f "$file" ;;
+esac
+`
+
+func TestSecretService(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte(fakeSecretTool), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	kc, err := newSystemKeychain()
+	if err != nil {
+		t.Fatalf("newSystemKeychain() error = %v", err)
+	}
+	if _, err := kc.get("server01"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("get() error = %v, want ErrCredentialNotFound", err)
+	}
+	if err := kc.set("server01", "label", []byte(`{"username":"admin"}`)); err != nil {
+		t.Fatalf("set() error = %v", err)
+	}
+	secret, err := kc.get("server01")
+	if err != nil || string(secret) != `{"username":"admin"}` {
+		t.Errorf("get() = %q, %v", secret, err)
+	}
+	if err := kc.delete("server01"); err != nil {
+		t.Fatalf("delete() error = %v", err)
+	}
+	if err := kc.delete("server01"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Errorf("second delete() error = %v, want ErrCredentialNotFound", err)
+	}
+}
+
+func TestSecretService_Unavailable(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if _, err := SystemCredentialStore(); !errors.Is(err, ErrCredentialStoreUnavailable) {
+		t.Errorf("SystemCredentialStore() error = %v, want ErrCredentialStoreUnavailable", err)
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/wsman/auth"
+)
+
+// memoryKeychain is a keychain in a map.
+type memoryKeychain map[string][]byte
+
+func (k memoryKeychain) get(account string) ([]byte, error) {
+	secret, ok := k[account]
+	if !ok {
+		return nil, ErrCredentialNotFound
+	}
+	return secret, nil
+}
+
+func (k memoryKeychain) set(account, _ string, secret []byte) error {
+	k[account] = secret
+	return nil
+}
+
+func (k memoryKeychain) delete(account string) error {
+	if _, ok := k[account]; !ok {
+		return ErrCredentialNotFound
+	}
+	delete(k, account)
+	return nil
+}
+
+func TestSystemCredentialStore(t *testing.T) {
+	kc := memoryKeychain{}
+	store := &systemCredentialStore{keychain: kc}
+
+	if _, err := store.Get("server01"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("Get() error = %v, want ErrCredentialNotFound", err)
+	}
+
+	want := auth.Credentials{Username: "admin", Domain: "CORP", Password: `p"ss:word`}
+	if err := store.Set(" Server01 ", want); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if _, ok := kc["server01"]; !ok {
+		t.Errorf("keychain accounts = %v, want server01", kc)
+	}
+	got, err := store.Get("SERVER01")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Get() = %+v, want %+v", got, want)
+	}
+
+	if err := store.Delete("server01"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Delete("server01"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Errorf("second Delete() error = %v, want ErrCredentialNotFound", err)
+	}
+}
+
+func TestCredentialAccount(t *testing.T) {
+	for _, endpoint := range []string{"", "  ", "host'name", "a\nb", `dom\host`} {
+		if account, err := credentialAccount(endpoint); err == nil {
+			t.Errorf("credentialAccount(%q) = %q, want an error", endpoint, account)
+		}
+	}
+	if account, err := credentialAccount("Web01.Corp.Example"); err != nil || account != "web01.corp.example" {
+		t.Errorf("credentialAccount() = %q, %v", account, err)
+	}
+}
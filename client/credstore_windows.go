@@ -0,0 +1,116 @@
+//go:build windows
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Windows Credential Manager, through the Cred* functions of advapi32.
+var (
+	modAdvapi32     = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = modAdvapi32.NewProc("CredReadW")
+	procCredWriteW  = modAdvapi32.NewProc("CredWriteW")
+	procCredDeleteW = modAdvapi32.NewProc("CredDeleteW")
+	procCredFree    = modAdvapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1 // CRED_TYPE_GENERIC
+	credPersistLocalMachine = 2 // CRED_PERSIST_LOCAL_MACHINE
+)
+
+// winCredential is CREDENTIALW.
+type winCredential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credManager keeps secrets as generic credentials named
+// "go-psrp:<account>", persisted for the user on this computer.
+type credManager struct{}
+
+func newSystemKeychain() (keychain, error) {
+	if err := procCredReadW.Find(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCredentialStoreUnavailable, err)
+	}
+	return credManager{}, nil
+}
+
+func credTarget(account string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(credentialService + ":" + account)
+}
+
+func (credManager) get(account string) ([]byte, error) {
+	target, err := credTarget(account)
+	if err != nil {
+		return nil, err
+	}
+	var cred *winCredential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, syscall.ERROR_NOT_FOUND) {
+			return nil, ErrCredentialNotFound
+		}
+		return nil, fmt.Errorf("client: read credential: %w", err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred))) //nolint:errcheck // CredFree returns nothing
+	if cred.CredentialBlobSize == 0 {
+		return nil, nil
+	}
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	return append([]byte(nil), blob...), nil
+}
+
+func (credManager) set(account, label string, secret []byte) error {
+	target, err := credTarget(account)
+	if err != nil {
+		return err
+	}
+	comment, err := syscall.UTF16PtrFromString(label)
+	if err != nil {
+		return err
+	}
+	cred := winCredential{
+		Type:       credTypeGeneric,
+		TargetName: target,
+		Comment:    comment,
+		Persist:    credPersistLocalMachine,
+	}
+	if len(secret) > 0 {
+		// #nosec G115 -- secrets are small JSON documents
+		cred.CredentialBlobSize = uint32(len(secret))
+		cred.CredentialBlob = &secret[0]
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return fmt.Errorf("client: write credential: %w", err)
+	}
+	return nil
+}
+
+func (credManager) delete(account string) error {
+	target, err := credTarget(account)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); r == 0 {
+		if errors.Is(err, syscall.ERROR_NOT_FOUND) {
+			return ErrCredentialNotFound
+		}
+		return fmt.Errorf("client: delete credential: %w", err)
+	}
+	return nil
+}
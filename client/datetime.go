@@ -0,0 +1,260 @@
+package client
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// DateTimeKind mirrors System.DateTimeKind.
+type DateTimeKind int
+
+const (
+	// DateTimeUnspecified is a DateTime without time zone information.
+	DateTimeUnspecified DateTimeKind = iota
+	// DateTimeUTC is a DateTime expressed in UTC.
+	DateTimeUTC
+	// DateTimeLocal is a DateTime expressed in the remote machine's local time zone.
+	DateTimeLocal
+)
+
+// String returns the .NET name of the kind.
+func (k DateTimeKind) String() string {
+	switch k {
+	case DateTimeUTC:
+		return "Utc"
+	case DateTimeLocal:
+		return "Local"
+	default:
+		return "Unspecified"
+	}
+}
+
+// DateTime is a deserialized DateTime together with its original kind and offset.
+// It is produced instead of time.Time when DateTimeOptions.PreserveKind is set,
+// and is converted back to the original representation when sent as input.
+type DateTime struct {
+	// Time is the instant, normalized to UTC.
+	Time time.Time
+
+	// Kind is the original DateTimeKind reported by the server.
+	Kind DateTimeKind
+
+	// Offset is the original UTC offset in seconds (remote local time zone for DateTimeLocal).
+	Offset int
+}
+
+// Original returns the time in its original offset, as the server reported it.
+func (d DateTime) Original() time.Time {
+	switch d.Kind {
+	case DateTimeUTC:
+		return d.Time.UTC()
+	default:
+		return d.Time.In(time.FixedZone("", d.Offset))
+	}
+}
+
+// DateTimeOptions configures normalization of DateTime values in results.
+type DateTimeOptions struct {
+	// RemoteLocation is the server's time zone. DateTimes without an offset
+	// (DateTimeUnspecified) are interpreted in this location.
+	// If nil, they are interpreted as UTC.
+	RemoteLocation *time.Location
+
+	// PreserveKind returns DateTime values instead of time.Time so the
+	// original kind and offset can be inspected and round-tripped.
+	PreserveKind bool
+}
+
+// deserializeCLIXML deserializes the CLIXML of a message. With DateTime
+// options, every DateTime is normalized to UTC (or to DateTime when
+// PreserveKind is set), its kind taken from its CLIXML text: a "Z" suffix
+// is DateTimeUTC, an explicit offset DateTimeLocal and neither
+// DateTimeUnspecified. A nil options pointer leaves the values as the
+// deserializer returns them.
+func deserializeCLIXML(data []byte, opts *DateTimeOptions) ([]interface{}, error) {
+	if opts == nil {
+		return serialization.NewDeserializer().Deserialize(data)
+	}
+	values, err := serialization.NewDeserializer().Deserialize(markDateTimes(data))
+	if err != nil {
+		return nil, err
+	}
+	normalizeDateTimes(values, opts)
+	return values, nil
+}
+
+// dateTimeMarker is the type name prefix of the objects markDateTimes
+// puts in place of DT elements; the kind name follows it.
+const dateTimeMarker = "GoPsrp.DateTime#"
+
+// markDateTimes rewrites every DT element of data as an object holding its
+// text, typed with the kind the text shows. The deserializer would parse
+// the text with time.Parse, which rejects a DateTime without an offset and
+// returns time.Local for an offset that happens to match this machine's.
+func markDateTimes(data []byte) []byte {
+	var out []byte
+	rest := data
+	for {
+		start := indexDateTime(rest)
+		if start < 0 {
+			break
+		}
+		tagEnd := bytes.IndexByte(rest[start:], '>')
+		if tagEnd < 0 {
+			break
+		}
+		tagEnd += start
+		attrs := rest[start+len("<DT") : tagEnd]
+		if bytes.HasSuffix(attrs, []byte("/")) {
+			out = append(out, rest[:tagEnd+1]...)
+			rest = rest[tagEnd+1:]
+			continue
+		}
+		textEnd := bytes.Index(rest[tagEnd:], []byte("</DT>"))
+		if textEnd < 0 {
+			break
+		}
+		textEnd += tagEnd
+		text := bytes.TrimSpace(rest[tagEnd+1 : textEnd])
+
+		out = append(out, rest[:start]...)
+		out = append(out, "<Obj"...)
+		out = append(out, attrs...)
+		out = append(out, "><TN><T>"+dateTimeMarker+dateTimeTextKind(text).String()+"</T></TN><ToString>"...)
+		out = append(out, text...)
+		out = append(out, "</ToString></Obj>"...)
+		rest = rest[textEnd+len("</DT>"):]
+	}
+	if out == nil {
+		return data
+	}
+	return append(out, rest...)
+}
+
+// indexDateTime returns the index of the first DT start tag in data, or -1.
+func indexDateTime(data []byte) int {
+	offset := 0
+	for {
+		i := bytes.Index(data[offset:], []byte("<DT"))
+		if i < 0 {
+			return -1
+		}
+		i += offset
+		if next := i + len("<DT"); next < len(data) && strings.IndexByte(" \t\r\n/>", data[next]) >= 0 {
+			return i
+		}
+		offset = i + len("<DT")
+	}
+}
+
+// dateTimeTextKind returns the kind of a serialized DateTime from its text.
+func dateTimeTextKind(text []byte) DateTimeKind {
+	if bytes.HasSuffix(text, []byte("Z")) {
+		return DateTimeUTC
+	}
+	// An offset follows the time of day: 2024-01-01T12:00:00+01:00
+	if t := bytes.IndexByte(text, 'T'); t >= 0 && bytes.ContainsAny(text[t:], "+-") {
+		return DateTimeLocal
+	}
+	return DateTimeUnspecified
+}
+
+// unspecifiedLayout parses the text of a DateTime without an offset.
+const unspecifiedLayout = "2006-01-02T15:04:05.999999999"
+
+// normalizeDateTimes walks deserialized values and replaces every object
+// put in place of a DateTime by markDateTimes. Slices, maps and PSObject
+// properties and values are updated in place.
+func normalizeDateTimes(values []interface{}, opts *DateTimeOptions) {
+	seen := make(map[*serialization.PSObject]bool)
+	for i, v := range values {
+		values[i] = normalizeDateTimeValue(v, opts, seen)
+	}
+}
+
+func normalizeDateTimeValue(v interface{}, opts *DateTimeOptions, seen map[*serialization.PSObject]bool) interface{} {
+	switch val := v.(type) {
+	case []interface{}:
+		for i, item := range val {
+			val[i] = normalizeDateTimeValue(item, opts, seen)
+		}
+		return val
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = normalizeDateTimeValue(item, opts, seen)
+		}
+		return val
+	case *serialization.PSObject:
+		if val == nil || seen[val] {
+			return val
+		}
+		if t, ok := markedDateTime(val, opts); ok {
+			return t
+		}
+		// Objects referenced twice, or by themselves, are walked once
+		seen[val] = true
+		for k, item := range val.Properties {
+			val.Properties[k] = normalizeDateTimeValue(item, opts, seen)
+		}
+		for k, item := range val.Members {
+			val.Members[k] = normalizeDateTimeValue(item, opts, seen)
+		}
+		val.Value = normalizeDateTimeValue(val.Value, opts, seen)
+		return val
+	default:
+		return v
+	}
+}
+
+// markedDateTime converts an object from markDateTimes to UTC, or to a
+// DateTime when PreserveKind is set. DateTimes without an offset are
+// interpreted in RemoteLocation. Text that does not parse is kept as a string.
+func markedDateTime(obj *serialization.PSObject, opts *DateTimeOptions) (interface{}, bool) {
+	if len(obj.TypeNames) != 1 || !strings.HasPrefix(obj.TypeNames[0], dateTimeMarker) {
+		return nil, false
+	}
+	var kind DateTimeKind
+	switch strings.TrimPrefix(obj.TypeNames[0], dateTimeMarker) {
+	case DateTimeUTC.String():
+		kind = DateTimeUTC
+	case DateTimeLocal.String():
+		kind = DateTimeLocal
+	}
+
+	var t time.Time
+	var err error
+	if kind == DateTimeUnspecified {
+		loc := opts.RemoteLocation
+		if loc == nil {
+			loc = time.UTC
+		}
+		t, err = time.ParseInLocation(unspecifiedLayout, obj.ToString, loc)
+	} else {
+		t, err = time.Parse(time.RFC3339Nano, obj.ToString)
+	}
+	if err != nil {
+		return obj.ToString, true
+	}
+
+	utc := t.UTC()
+	if !opts.PreserveKind {
+		return utc, true
+	}
+	_, offset := t.Zone()
+	return DateTime{Time: utc, Kind: kind, Offset: offset}, true
+}
+
+// parsedDateTime returns the DateTime of a time the deserializer parsed
+// from CLIXML without DateTimeOptions. It only parses text with a "Z"
+// suffix, which it returns in time.UTC, or with an explicit offset.
+func parsedDateTime(t time.Time) DateTime {
+	kind := DateTimeLocal
+	if t.Location() == time.UTC {
+		kind = DateTimeUTC
+	}
+	_, offset := t.Zone()
+	return DateTime{Time: t.UTC(), Kind: kind, Offset: offset}
+}
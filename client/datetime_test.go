@@ -0,0 +1,165 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// getDateCLIXML is Get-Date output as PowerShell serializes it: the
+// DateTime is the value of an object with a DisplayHint note property.
+const getDateCLIXML = `<Obj RefId="0"><DT>2024-03-01T10:00:00.1234567+02:00</DT>` +
+	`<MS><Obj N="DisplayHint" RefId="1"><TN RefId="0"><T>Microsoft.PowerShell.Commands.DisplayHintType</T>` +
+	`<T>System.Enum</T><T>System.ValueType</T><T>System.Object</T></TN><ToString>DateTime</ToString><I32>2</I32></Obj></MS></Obj>`
+
+// deserializeTest deserializes CLIXML as a pipeline output message.
+func deserializeTest(t *testing.T, clixml string, opts *DateTimeOptions) []interface{} {
+	t.Helper()
+	values, err := deserializeCLIXML([]byte(clixml), opts)
+	if err != nil {
+		t.Fatalf("deserializeCLIXML(%s) error = %v", clixml, err)
+	}
+	if len(values) != 1 {
+		t.Fatalf("deserializeCLIXML(%s) = %d values, want 1", clixml, len(values))
+	}
+	return values
+}
+
+func TestDeserializeCLIXML_NilOptions(t *testing.T) {
+	values := deserializeTest(t, `<DT>2024-03-01T10:00:00+02:00</DT>`, nil)
+
+	want := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	got, ok := values[0].(time.Time)
+	if !ok || !got.Equal(want) {
+		t.Fatalf("values[0] = %v, want %v as deserialized", values[0], want)
+	}
+	if _, offset := got.Zone(); offset != 2*3600 {
+		t.Errorf("offset = %d, want the deserialized +02:00", offset)
+	}
+}
+
+func TestDeserializeCLIXML_UTC(t *testing.T) {
+	clixml := `<Obj RefId="0"><MS>` +
+		`<DT N="Created">2024-03-01T10:00:00+02:00</DT>` +
+		`<S N="Text">&lt;DT&gt;not a date&lt;/DT&gt;</S>` +
+		`<Obj N="Dates" RefId="1"><TN RefId="0"><T>System.Object[]</T><T>System.Array</T><T>System.Object</T></TN>` +
+		`<LST><DT>2024-03-01T08:00:00Z</DT>` + getDateCLIXML + `</LST></Obj>` +
+		`</MS></Obj>`
+	values := deserializeTest(t, clixml, &DateTimeOptions{})
+
+	obj, ok := values[0].(*serialization.PSObject)
+	if !ok {
+		t.Fatalf("values[0] = %T, want *PSObject", values[0])
+	}
+	want := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	if got, ok := obj.Properties["Created"].(time.Time); !ok || !got.Equal(want) || got.Location() != time.UTC {
+		t.Errorf("Created = %v, want %v", obj.Properties["Created"], want)
+	}
+	if got := obj.Properties["Text"]; got != "<DT>not a date</DT>" {
+		t.Errorf("Text = %v, want the string unchanged", got)
+	}
+
+	dates, ok := obj.Properties["Dates"].([]interface{})
+	if !ok || len(dates) != 2 {
+		t.Fatalf("Dates = %#v, want 2 values", obj.Properties["Dates"])
+	}
+	if got, ok := dates[0].(time.Time); !ok || !got.Equal(want) {
+		t.Errorf("Dates[0] = %v, want %v", dates[0], want)
+	}
+	// The value of a PSObject is normalized too
+	getDate, ok := dates[1].(*serialization.PSObject)
+	if !ok {
+		t.Fatalf("Dates[1] = %T, want *PSObject", dates[1])
+	}
+	if got, ok := getDate.Value.(time.Time); !ok || !got.Equal(want.Add(123456700)) || got.Location() != time.UTC {
+		t.Errorf("Get-Date value = %v, want %v", getDate.Value, want.Add(123456700))
+	}
+}
+
+func TestDeserializeCLIXML_PreserveKind(t *testing.T) {
+	remote := time.FixedZone("remote", -5*3600)
+	tests := []struct {
+		name       string
+		text       string
+		wantKind   DateTimeKind
+		wantUTC    time.Time
+		wantOffset int
+	}{
+		{
+			name:     "UTC",
+			text:     "2024-01-01T12:00:00Z",
+			wantKind: DateTimeUTC,
+			wantUTC:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "Local",
+			text:       "2024-01-01T12:00:00+01:00",
+			wantKind:   DateTimeLocal,
+			wantUTC:    time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+			wantOffset: 3600,
+		},
+		{
+			// time.Parse returns time.Local when the offset is this machine's
+			name:     "LocalZeroOffset",
+			text:     "2024-01-01T12:00:00+00:00",
+			wantKind: DateTimeLocal,
+			wantUTC:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "UnspecifiedInRemoteZone",
+			text:       "2024-01-01T12:00:00.1234567",
+			wantKind:   DateTimeUnspecified,
+			wantUTC:    time.Date(2024, 1, 1, 17, 0, 0, 123456700, time.UTC),
+			wantOffset: -5 * 3600,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := deserializeTest(t, `<DT>`+tt.text+`</DT>`, &DateTimeOptions{RemoteLocation: remote, PreserveKind: true})
+
+			got, ok := values[0].(DateTime)
+			if !ok {
+				t.Fatalf("values[0] = %T, want DateTime", values[0])
+			}
+			if got.Kind != tt.wantKind {
+				t.Errorf("Kind = %v, want %v", got.Kind, tt.wantKind)
+			}
+			if !got.Time.Equal(tt.wantUTC) {
+				t.Errorf("Time = %v, want %v", got.Time, tt.wantUTC)
+			}
+			if got.Offset != tt.wantOffset {
+				t.Errorf("Offset = %d, want %d", got.Offset, tt.wantOffset)
+			}
+			if !got.Original().Equal(tt.wantUTC) {
+				t.Errorf("Original() = %v, not the same instant as %v", got.Original(), tt.wantUTC)
+			}
+		})
+	}
+}
+
+func TestDeserializeCLIXML_UnspecifiedWithoutLocation(t *testing.T) {
+	values := deserializeTest(t, `<DT>2024-01-01T12:00:00</DT>`, &DateTimeOptions{})
+
+	want := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if got, ok := values[0].(time.Time); !ok || !got.Equal(want) {
+		t.Errorf("values[0] = %v, want %v", values[0], want)
+	}
+}
+
+func TestNormalizeInput_DateTimeRoundTrip(t *testing.T) {
+	d := DateTime{
+		Time:   time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+		Kind:   DateTimeLocal,
+		Offset: 3600,
+	}
+
+	got, ok := normalizeInput(d, nil).(time.Time)
+	if !ok {
+		t.Fatalf("normalizeInput(DateTime) = %T, want time.Time", got)
+	}
+	if _, offset := got.Zone(); offset != 3600 || got.Hour() != 12 {
+		t.Errorf("normalizeInput(DateTime) = %v, want 12:00 +01:00", got)
+	}
+}
@@ -0,0 +1,357 @@
+package client
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+var (
+	// ErrNoOutput is returned by Result.Decode when there is no output object
+	// to decode into a non-slice value.
+	ErrNoOutput = errors.New("client: no output to decode")
+
+	// ErrScriptErrors is returned by ExecuteInto when the script wrote to the
+	// error stream. The output is still decoded.
+	ErrScriptErrors = errors.New("client: script wrote errors")
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Decode maps the output objects into v, which must be a non-nil pointer.
+//
+// If v points to a slice, every output object is decoded as an element.
+// Otherwise the output must contain exactly one object.
+//
+// PSObject properties are matched to struct fields case-insensitively by the
+// name in the `psrp` tag, then the `json` tag, then the field name; a tag of
+// "-" skips the field. Nested objects, arrays, hashtables (into maps) and
+// dates (into time.Time or DateTime) are decoded recursively. Enums decode
+// into strings by name, into integers by value, or through
+// encoding.TextUnmarshaler. A single object decodes into a slice as one
+// element, since PowerShell unrolls one-element arrays.
+//
+//	var procs []struct {
+//		Name string
+//		ID   int `psrp:"Id"`
+//	}
+//	err := result.Decode(&procs)
+func (r *Result) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("client: Decode requires a non-nil pointer, got %T", v)
+	}
+	dst := rv.Elem()
+
+	if dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() != reflect.Uint8 {
+		return decodeValue("Output", r.Output, dst)
+	}
+
+	switch len(r.Output) {
+	case 0:
+		return ErrNoOutput
+	case 1:
+		return decodeValue("Output[0]", r.Output[0], dst)
+	default:
+		return fmt.Errorf("client: decode: %d output objects, want 1 (decode into a slice to collect all)", len(r.Output))
+	}
+}
+
+// ExecuteInto runs a PowerShell script and decodes its output into v, as
+// described for Result.Decode. If the script wrote error records, the output
+// is still decoded and an error wrapping ErrScriptErrors is returned.
+func (c *Client) ExecuteInto(ctx context.Context, script string, v interface{}) error {
+	result, err := c.Execute(ctx, script)
+	if err != nil {
+		return err
+	}
+	if err := result.Decode(v); err != nil {
+		return err
+	}
+	if result.HadErrors {
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("%w: %s", ErrScriptErrors, outputString(result.Errors[0]))
+		}
+		return ErrScriptErrors
+	}
+	return nil
+}
+
+// decodeValue decodes a deserialized value into dst. path names the value in errors.
+func decodeValue(path string, src interface{}, dst reflect.Value) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	if dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return decodeValue(path, src, dst.Elem())
+	}
+
+	srcValue := reflect.ValueOf(src)
+	if srcValue.Type().AssignableTo(dst.Type()) {
+		dst.Set(srcValue)
+		return nil
+	}
+
+	// Enums and other types that parse their own text form
+	if dst.CanAddr() && reflect.PointerTo(dst.Type()).Implements(textUnmarshalerType) {
+		if text, ok := textOf(src); ok {
+			if err := dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); err != nil {
+				return fmt.Errorf("client: decode %s: %w", path, err)
+			}
+			return nil
+		}
+	}
+
+	if obj, ok := src.(*serialization.PSObject); ok {
+		return decodePSObject(path, obj, dst)
+	}
+
+	switch dst.Kind() {
+	case reflect.Struct:
+		switch dst.Type() {
+		case timeType:
+			if d, ok := src.(DateTime); ok {
+				dst.Set(reflect.ValueOf(d.Time))
+				return nil
+			}
+		case dateTimeType:
+			if t, ok := src.(time.Time); ok {
+				dst.Set(reflect.ValueOf(parsedDateTime(t)))
+				return nil
+			}
+		default:
+			if props, ok := src.(map[string]interface{}); ok {
+				return decodeStruct(path, props, dst)
+			}
+		}
+	case reflect.Map:
+		if props, ok := src.(map[string]interface{}); ok {
+			return decodeMap(path, props, dst)
+		}
+	case reflect.Slice:
+		if items, ok := src.([]interface{}); ok {
+			return decodeSlice(path, items, dst)
+		}
+		// PowerShell unrolls single-element arrays
+		if dst.Type().Elem().Kind() != reflect.Slice {
+			return decodeSlice(path, []interface{}{src}, dst)
+		}
+	case reflect.String:
+		switch s := src.(type) {
+		case time.Time, DateTime:
+			// No unambiguous text form; decode into time.Time instead
+		case fmt.Stringer:
+			dst.SetString(s.String())
+			return nil
+		default:
+			if k := srcValue.Kind(); k == reflect.String || k == reflect.Bool || isIntegerKind(k) ||
+				k == reflect.Float32 || k == reflect.Float64 {
+				dst.SetString(fmt.Sprint(src))
+				return nil
+			}
+		}
+	case reflect.Bool:
+		if srcValue.Kind() == reflect.Bool {
+			dst.SetBool(srcValue.Bool())
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := toInt64(srcValue); ok && !dst.OverflowInt(n) {
+			dst.SetInt(n)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := toInt64(srcValue); ok && n >= 0 && !dst.OverflowUint(uint64(n)) {
+			dst.SetUint(uint64(n))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := toFloat64(srcValue); ok {
+			dst.SetFloat(f)
+			return nil
+		}
+	case reflect.Interface:
+		if srcValue.Type().Implements(dst.Type()) {
+			dst.Set(srcValue)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("client: decode %s: cannot decode %T into %s", path, src, dst.Type())
+}
+
+// decodePSObject decodes a PSObject: complex objects by their properties,
+// primitives and enums by their value or string form.
+func decodePSObject(path string, obj *serialization.PSObject, dst reflect.Value) error {
+	switch dst.Kind() {
+	case reflect.Struct:
+		if dst.Type() != timeType && dst.Type() != dateTimeType {
+			return decodeStruct(path, obj.Properties, dst)
+		}
+	case reflect.Map:
+		if obj.Value == nil {
+			return decodeMap(path, obj.Properties, dst)
+		}
+	case reflect.Slice:
+		// A single object (not a collection) decodes as one element
+		elemKind := dst.Type().Elem().Kind()
+		if _, ok := obj.Value.([]interface{}); !ok && elemKind != reflect.Uint8 && elemKind != reflect.Slice {
+			return decodeSlice(path, []interface{}{obj}, dst)
+		}
+	case reflect.String:
+		// Enums carry their name in ToString and their number in Value
+		if obj.ToString != "" {
+			dst.SetString(obj.ToString)
+			return nil
+		}
+	}
+	if obj.Value != nil {
+		return decodeValue(path, obj.Value, dst)
+	}
+	if dst.Kind() == reflect.String {
+		dst.SetString(obj.ToString)
+		return nil
+	}
+	return fmt.Errorf("client: decode %s: cannot decode object %q into %s", path, obj.ToString, dst.Type())
+}
+
+// decodeStruct assigns properties to the matching fields of dst.
+// Properties without a matching field are ignored.
+func decodeStruct(path string, props map[string]interface{}, dst reflect.Value) error {
+	byName := make(map[string]interface{}, len(props))
+	for name, value := range props {
+		byName[strings.ToLower(name)] = value
+	}
+	return decodeFields(path, byName, dst)
+}
+
+func decodeFields(path string, props map[string]interface{}, dst reflect.Value) error {
+	rt := dst.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, ok := decodeFieldName(field)
+		if !ok {
+			continue
+		}
+		// Untagged embedded structs share the parent's properties
+		if field.Anonymous && name == field.Name && field.Type.Kind() == reflect.Struct {
+			if err := decodeFields(path, props, dst.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		value, ok := props[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		if err := decodeValue(path+"."+name, value, dst.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeFieldName returns the property name for a struct field, from the
+// psrp tag, then the json tag, then the field name. ok is false for "-".
+func decodeFieldName(field reflect.StructField) (name string, ok bool) {
+	for _, key := range []string{"psrp", "json"} {
+		if tag, found := field.Tag.Lookup(key); found {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				return "", false
+			}
+			if tagName != "" {
+				return tagName, true
+			}
+		}
+	}
+	return field.Name, true
+}
+
+func decodeMap(path string, props map[string]interface{}, dst reflect.Value) error {
+	if dst.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("client: decode %s: map key must be a string, got %s", path, dst.Type().Key())
+	}
+	out := reflect.MakeMapWithSize(dst.Type(), len(props))
+	for key, value := range props {
+		elem := reflect.New(dst.Type().Elem()).Elem()
+		if err := decodeValue(path+"["+key+"]", value, elem); err != nil {
+			return err
+		}
+		out.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), elem)
+	}
+	dst.Set(out)
+	return nil
+}
+
+func decodeSlice(path string, items []interface{}, dst reflect.Value) error {
+	out := reflect.MakeSlice(dst.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := decodeValue(fmt.Sprintf("%s[%d]", path, i), item, out.Index(i)); err != nil {
+			return err
+		}
+	}
+	dst.Set(out)
+	return nil
+}
+
+// textOf returns the text form of a string or enum object.
+func textOf(src interface{}) (string, bool) {
+	switch v := src.(type) {
+	case string:
+		return v, true
+	case *serialization.PSObject:
+		return v.ToString, v.ToString != ""
+	default:
+		return "", false
+	}
+}
+
+// toInt64 converts integer values, and floats without a fractional part.
+func toInt64(v reflect.Value) (int64, bool) {
+	switch {
+	case v.CanInt():
+		return v.Int(), true
+	case v.CanUint():
+		u := v.Uint()
+		if u > 1<<63-1 {
+			return 0, false
+		}
+		return int64(u), true
+	case v.CanFloat():
+		f := v.Float()
+		if f != float64(int64(f)) {
+			return 0, false
+		}
+		return int64(f), true
+	default:
+		return 0, false
+	}
+}
+
+func toFloat64(v reflect.Value) (float64, bool) {
+	switch {
+	case v.CanFloat():
+		return v.Float(), true
+	case v.CanInt():
+		return float64(v.Int()), true
+	case v.CanUint():
+		return float64(v.Uint()), true
+	default:
+		return 0, false
+	}
+}
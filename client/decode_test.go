@@ -0,0 +1,156 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+type testService struct {
+	Name      string
+	Status    string // enum by name
+	StartType int    `psrp:"StartMode"` // enum by value
+	Started   time.Time
+	PID       *uint32          `json:"ProcessId"`
+	Ignored   string           `psrp:"-"`
+	Deps      []testDependency `psrp:"DependentServices"`
+	Labels    map[string]string
+}
+
+type testDependency struct {
+	Name string
+}
+
+func enumObject(name string, value int32) *serialization.PSObject {
+	return &serialization.PSObject{ToString: name, Value: value}
+}
+
+func TestResult_Decode_Struct(t *testing.T) {
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	result := &Result{Output: []interface{}{
+		&serialization.PSObject{
+			ToString: "System.ServiceProcess.ServiceController",
+			Properties: map[string]interface{}{
+				"name":      "WinRM",
+				"Status":    enumObject("Running", 4),
+				"StartMode": enumObject("Automatic", 2),
+				"Started":   started,
+				"ProcessId": int32(1234),
+				"Ignored":   "x",
+				"DependentServices": []interface{}{
+					&serialization.PSObject{Properties: map[string]interface{}{"Name": "HTTP"}},
+					&serialization.PSObject{Properties: map[string]interface{}{"Name": "RPCSS"}},
+				},
+				"Labels": map[string]interface{}{"tier": "core"},
+			},
+		},
+	}}
+
+	var svc testService
+	if err := result.Decode(&svc); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if svc.Name != "WinRM" || svc.Status != "Running" || svc.StartType != 2 {
+		t.Errorf("Name, Status, StartType = %q, %q, %d", svc.Name, svc.Status, svc.StartType)
+	}
+	if !svc.Started.Equal(started) {
+		t.Errorf("Started = %v, want %v", svc.Started, started)
+	}
+	if svc.PID == nil || *svc.PID != 1234 {
+		t.Errorf("PID = %v, want 1234", svc.PID)
+	}
+	if svc.Ignored != "" {
+		t.Errorf("Ignored = %q, want empty", svc.Ignored)
+	}
+	if len(svc.Deps) != 2 || svc.Deps[1].Name != "RPCSS" {
+		t.Errorf("Deps = %+v", svc.Deps)
+	}
+	if svc.Labels["tier"] != "core" {
+		t.Errorf("Labels = %v", svc.Labels)
+	}
+}
+
+func TestResult_Decode_Slice(t *testing.T) {
+	result := &Result{Output: []interface{}{int32(1), int64(2), float64(3)}}
+
+	var nums []int
+	if err := result.Decode(&nums); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(nums) != 3 || nums[2] != 3 {
+		t.Errorf("nums = %v, want [1 2 3]", nums)
+	}
+
+	// One-element arrays are unrolled by PowerShell; a nested single value
+	// still decodes into a slice field.
+	var wrapper struct{ Items []string }
+	single := &Result{Output: []interface{}{map[string]interface{}{"Items": "only"}}}
+	if err := single.Decode(&wrapper); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(wrapper.Items) != 1 || wrapper.Items[0] != "only" {
+		t.Errorf("Items = %v, want [only]", wrapper.Items)
+	}
+}
+
+func TestResult_Decode_Scalars(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name   string
+		output interface{}
+		target interface{}
+		check  func(v interface{}) bool
+	}{
+		{"uuid", id, new(uuid.UUID), func(v interface{}) bool { return *v.(*uuid.UUID) == id }},
+		{"uuid from string", id.String(), new(uuid.UUID), func(v interface{}) bool { return *v.(*uuid.UUID) == id }},
+		{"int to string", int32(42), new(string), func(v interface{}) bool { return *v.(*string) == "42" }},
+		{"bool", true, new(bool), func(v interface{}) bool { return *v.(*bool) }},
+		{"duration", 90 * time.Second, new(time.Duration), func(v interface{}) bool { return *v.(*time.Duration) == 90*time.Second }},
+		{"any", "x", new(interface{}), func(v interface{}) bool { return *v.(*interface{}) == "x" }},
+		{
+			"datetime kind", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), new(DateTime),
+			func(v interface{}) bool { return v.(*DateTime).Kind == DateTimeUTC },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &Result{Output: []interface{}{tt.output}}
+			if err := result.Decode(tt.target); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !tt.check(tt.target) {
+				t.Errorf("Decode() decoded %v", tt.target)
+			}
+		})
+	}
+}
+
+func TestResult_Decode_Errors(t *testing.T) {
+	var n int
+	if err := (&Result{}).Decode(&n); !errors.Is(err, ErrNoOutput) {
+		t.Errorf("empty output error = %v, want ErrNoOutput", err)
+	}
+	if err := (&Result{Output: []interface{}{1, 2}}).Decode(&n); err == nil {
+		t.Error("two outputs into a scalar: error = nil")
+	}
+	if err := (&Result{Output: []interface{}{1}}).Decode(n); err == nil {
+		t.Error("non-pointer: error = nil")
+	}
+
+	var small int8
+	if err := (&Result{Output: []interface{}{int32(1000)}}).Decode(&small); err == nil {
+		t.Error("overflow: error = nil")
+	}
+
+	var svc testService
+	bad := &Result{Output: []interface{}{map[string]interface{}{"Started": "not a date"}}}
+	err := bad.Decode(&svc)
+	if err == nil || !strings.Contains(err.Error(), "Output[0].Started") {
+		t.Errorf("error = %v, want path Output[0].Started", err)
+	}
+}
@@ -0,0 +1,315 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+var (
+	// ErrDiffKeyMissing is returned by DiffObjects when an object lacks a
+	// key property.
+	ErrDiffKeyMissing = errors.New("client: object has no key property")
+
+	// ErrDiffDuplicateKey is returned by DiffObjects when two objects of
+	// the same set have the same key.
+	ErrDiffDuplicateKey = errors.New("client: duplicate object key")
+)
+
+// DiffOptions selects how DiffObjects matches and compares objects.
+type DiffOptions struct {
+	// Keys are the properties that identify an object across the two
+	// sets, e.g. "Name" for services. Matched case-insensitively. Without
+	// keys, objects are matched by their whole value, so they can only be
+	// added or removed, never changed.
+	Keys []string
+
+	// Properties limits the comparison to these properties. Default: all
+	// properties of either object.
+	Properties []string
+
+	// Ignore excludes properties from the comparison, such as counters
+	// or timestamps that change on every run.
+	Ignore []string
+}
+
+// PropertyChange is a property whose value differs.
+type PropertyChange struct {
+	Name   string
+	Before interface{}
+	After  interface{}
+}
+
+// ObjectChange is an object present in both sets with changed properties.
+type ObjectChange struct {
+	// Key is the text form of the key values, joined with ", ".
+	Key string
+
+	Before interface{}
+	After  interface{}
+
+	// Properties are the changes, sorted by name.
+	Properties []PropertyChange
+}
+
+// ObjectDiff is the difference between two object sets.
+type ObjectDiff struct {
+	// Added are the objects only in the after set, in its order.
+	Added []interface{}
+
+	// Removed are the objects only in the before set, in its order.
+	Removed []interface{}
+
+	// Changed are the objects in both sets that differ, in the order of
+	// the after set.
+	Changed []ObjectChange
+}
+
+// Empty reports whether the sets are the same.
+func (d *ObjectDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String returns a line per difference: "+ key" for added objects,
+// "- key" for removed ones and "~ key: Name: before -> after" for each
+// changed property.
+func (d *ObjectDiff) String() string {
+	var b strings.Builder
+	for _, obj := range d.Added {
+		fmt.Fprintf(&b, "+ %s\n", outputString(obj))
+	}
+	for _, obj := range d.Removed {
+		fmt.Fprintf(&b, "- %s\n", outputString(obj))
+	}
+	for _, change := range d.Changed {
+		for _, p := range change.Properties {
+			fmt.Fprintf(&b, "~ %s: %s: %s -> %s\n", change.Key, p.Name, diffText(p.Before), diffText(p.After))
+		}
+	}
+	return b.String()
+}
+
+// DiffResults compares the output of two results, as DiffObjects does,
+// for example the state before and after a change:
+//
+//	before, _ := c.Execute(ctx, "Get-Service")
+//	// ... apply the change ...
+//	after, _ := c.Execute(ctx, "Get-Service")
+//	diff, err := client.DiffResults(before, after, client.DiffOptions{
+//		Keys:       []string{"Name"},
+//		Properties: []string{"Status", "StartType"},
+//	})
+func DiffResults(before, after *Result, opts DiffOptions) (*ObjectDiff, error) {
+	var b, a []interface{}
+	if before != nil {
+		b = before.Output
+	}
+	if after != nil {
+		a = after.Output
+	}
+	return DiffObjects(b, a, opts)
+}
+
+// DiffObjects compares two object sets, such as Result.Output, matching
+// objects by opts.Keys. Objects are PSObjects, hashtables
+// (map[string]interface{}) or primitives; a primitive is its own key.
+func DiffObjects(before, after []interface{}, opts DiffOptions) (*ObjectDiff, error) {
+	beforeKeys, byKey, err := indexObjects("before", before, opts.Keys)
+	if err != nil {
+		return nil, err
+	}
+	afterKeys, afterByKey, err := indexObjects("after", after, opts.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ObjectDiff{}
+	for i, key := range beforeKeys {
+		if _, ok := afterByKey[key]; !ok {
+			diff.Removed = append(diff.Removed, before[i])
+		}
+	}
+	for i, key := range afterKeys {
+		old, ok := byKey[key]
+		if !ok {
+			diff.Added = append(diff.Added, after[i])
+			continue
+		}
+		if changes := diffProperties(old, after[i], opts); len(changes) > 0 {
+			diff.Changed = append(diff.Changed, ObjectChange{
+				Key:        key,
+				Before:     old,
+				After:      after[i],
+				Properties: changes,
+			})
+		}
+	}
+	return diff, nil
+}
+
+// indexObjects returns the key of each object and the objects by key.
+func indexObjects(set string, objects []interface{}, keys []string) ([]string, map[string]interface{}, error) {
+	list := make([]string, len(objects))
+	byKey := make(map[string]interface{}, len(objects))
+	for i, obj := range objects {
+		key, err := objectKey(obj, keys)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s[%d]: %v", ErrDiffKeyMissing, set, i, err)
+		}
+		if _, dup := byKey[key]; dup {
+			return nil, nil, fmt.Errorf("%w: %s: %s", ErrDiffDuplicateKey, set, key)
+		}
+		list[i] = key
+		byKey[key] = obj
+	}
+	return list, byKey, nil
+}
+
+// objectKey returns the text form of the key properties of obj.
+func objectKey(obj interface{}, keys []string) (string, error) {
+	props := objectProperties(obj)
+	if len(keys) == 0 || props == nil {
+		return diffText(obj), nil
+	}
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		value, ok := lookupProperty(props, key)
+		if !ok {
+			return "", fmt.Errorf("property %q not found", key)
+		}
+		values[i] = diffText(value)
+	}
+	return strings.Join(values, ", "), nil
+}
+
+// diffProperties returns the compared properties whose values differ.
+func diffProperties(before, after interface{}, opts DiffOptions) []PropertyChange {
+	beforeProps, afterProps := objectProperties(before), objectProperties(after)
+	if beforeProps == nil || afterProps == nil {
+		// Primitives are matched by value, so they cannot differ
+		return nil
+	}
+
+	names := opts.Properties
+	if len(names) == 0 {
+		seen := make(map[string]bool)
+		for _, props := range []map[string]interface{}{beforeProps, afterProps} {
+			for name := range props {
+				if !seen[strings.ToLower(name)] {
+					seen[strings.ToLower(name)] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+
+	var changes []PropertyChange
+	for _, name := range names {
+		if containsFold(opts.Ignore, name) {
+			continue
+		}
+		b, _ := lookupProperty(beforeProps, name)
+		a, _ := lookupProperty(afterProps, name)
+		if !valuesEqual(b, a) {
+			changes = append(changes, PropertyChange{Name: name, Before: b, After: a})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// objectProperties returns the properties of a PSObject or hashtable, or
+// nil for a primitive.
+func objectProperties(obj interface{}) map[string]interface{} {
+	switch v := obj.(type) {
+	case *serialization.PSObject:
+		if v.Value == nil && len(v.Properties) > 0 {
+			return v.Properties
+		}
+	case map[string]interface{}:
+		return v
+	}
+	return nil
+}
+
+// lookupProperty finds a property case-insensitively.
+func lookupProperty(props map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := props[name]; ok {
+		return value, true
+	}
+	for key, value := range props {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// valuesEqual compares deserialized values: objects by their properties,
+// enums and wrapped primitives by their value.
+func valuesEqual(a, b interface{}) bool {
+	if pa, ok := a.(*serialization.PSObject); ok && pa.Value != nil {
+		a = pa.Value
+	}
+	if pb, ok := b.(*serialization.PSObject); ok && pb.Value != nil {
+		b = pb.Value
+	}
+
+	propsA, propsB := objectProperties(a), objectProperties(b)
+	if propsA != nil || propsB != nil {
+		if len(propsA) != len(propsB) {
+			return false
+		}
+		for name, va := range propsA {
+			vb, ok := lookupProperty(propsB, name)
+			if !ok || !valuesEqual(va, vb) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if la, ok := a.([]interface{}); ok {
+		lb, ok := b.([]interface{})
+		if !ok || len(la) != len(lb) {
+			return false
+		}
+		for i := range la {
+			if !valuesEqual(la[i], lb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	oa, okA := a.(*serialization.PSObject)
+	ob, okB := b.(*serialization.PSObject)
+	if okA && okB {
+		return oa.ToString == ob.ToString
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// diffText is the text form of a key or property value.
+func diffText(v interface{}) string {
+	if v == nil {
+		return "$null"
+	}
+	if obj, ok := v.(*serialization.PSObject); ok && obj.ToString == "" && obj.Value != nil {
+		return outputString(obj.Value)
+	}
+	return outputString(v)
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+func serviceObject(name string, status *serialization.PSObject, pid int32) *serialization.PSObject {
+	return &serialization.PSObject{
+		ToString:   name,
+		Properties: map[string]interface{}{"Name": name, "Status": status, "PID": pid},
+	}
+}
+
+func TestDiffResults(t *testing.T) {
+	running, stopped := enumObject("Running", 4), enumObject("Stopped", 1)
+	before := &Result{Output: []interface{}{
+		serviceObject("WinRM", running, 100),
+		serviceObject("Spooler", running, 200),
+		serviceObject("W32Time", stopped, 0),
+	}}
+	after := &Result{Output: []interface{}{
+		serviceObject("WinRM", enumObject("Running", 4), 150),
+		serviceObject("W32Time", running, 300),
+		serviceObject("BITS", running, 400),
+	}}
+
+	diff, err := DiffResults(before, after, DiffOptions{Keys: []string{"name"}, Ignore: []string{"pid"}})
+	if err != nil {
+		t.Fatalf("DiffResults() error = %v", err)
+	}
+	if len(diff.Added) != 1 || outputString(diff.Added[0]) != "BITS" {
+		t.Errorf("Added = %v, want [BITS]", diff.Added)
+	}
+	if len(diff.Removed) != 1 || outputString(diff.Removed[0]) != "Spooler" {
+		t.Errorf("Removed = %v, want [Spooler]", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Key != "W32Time" {
+		t.Fatalf("Changed = %+v, want W32Time only", diff.Changed)
+	}
+	if props := diff.Changed[0].Properties; len(props) != 1 || props[0].Name != "Status" {
+		t.Errorf("Changed properties = %+v, want Status", props)
+	}
+
+	want := "+ BITS\n- Spooler\n~ W32Time: Status: Stopped -> Running\n"
+	if got := diff.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestDiffObjects_Properties(t *testing.T) {
+	before := []interface{}{map[string]interface{}{"Id": int32(1), "A": "x", "B": "y"}}
+	after := []interface{}{map[string]interface{}{"Id": int32(1), "A": "x2", "B": "y2"}}
+
+	diff, err := DiffObjects(before, after, DiffOptions{Keys: []string{"Id"}, Properties: []string{"B"}})
+	if err != nil {
+		t.Fatalf("DiffObjects() error = %v", err)
+	}
+	if len(diff.Changed) != 1 || len(diff.Changed[0].Properties) != 1 || diff.Changed[0].Properties[0].Name != "B" {
+		t.Errorf("Changed = %+v, want only B", diff.Changed)
+	}
+}
+
+func TestDiffObjects_Primitives(t *testing.T) {
+	diff, err := DiffObjects([]interface{}{"a", "b"}, []interface{}{"b", "c"}, DiffOptions{})
+	if err != nil {
+		t.Fatalf("DiffObjects() error = %v", err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0] != "c" || len(diff.Removed) != 1 || diff.Removed[0] != "a" {
+		t.Errorf("diff = %+v, want +c -a", diff)
+	}
+
+	same, _ := DiffObjects([]interface{}{"a"}, []interface{}{"a"}, DiffOptions{})
+	if !same.Empty() {
+		t.Errorf("Empty() = false for equal sets: %s", same)
+	}
+}
+
+func TestDiffObjects_Errors(t *testing.T) {
+	objs := []interface{}{
+		map[string]interface{}{"Name": "a"},
+		map[string]interface{}{"Name": "a"},
+	}
+	if _, err := DiffObjects(objs, nil, DiffOptions{Keys: []string{"Name"}}); !errors.Is(err, ErrDiffDuplicateKey) {
+		t.Errorf("duplicate keys: error = %v, want ErrDiffDuplicateKey", err)
+	}
+	_, err := DiffObjects(nil, objs[:1], DiffOptions{Keys: []string{"Id"}})
+	if !errors.Is(err, ErrDiffKeyMissing) || !strings.Contains(err.Error(), "after[0]") {
+		t.Errorf("missing key: error = %v, want ErrDiffKeyMissing for after[0]", err)
+	}
+}
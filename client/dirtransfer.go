@@ -0,0 +1,630 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// SkipMode selects how directory transfers detect unchanged files.
+type SkipMode int
+
+const (
+	// SkipNone transfers every file.
+	SkipNone SkipMode = iota
+	// SkipSizeModTime skips files whose destination has the same size and
+	// modification time (within a second). Combine with PreserveTimestamps,
+	// otherwise transferred files get a new modification time and are never
+	// skipped on the next run.
+	SkipSizeModTime
+	// SkipHash skips files whose destination has the same SHA256 hash.
+	SkipHash
+)
+
+// String returns the mode name.
+func (m SkipMode) String() string {
+	switch m {
+	case SkipNone:
+		return "none"
+	case SkipSizeModTime:
+		return "size-mtime"
+	case SkipHash:
+		return "hash"
+	default:
+		return fmt.Sprintf("SkipMode(%d)", int(m))
+	}
+}
+
+// modTimeTolerance absorbs timestamp precision differences between file
+// systems (NTFS stores 100ns, the listing script reports milliseconds).
+const modTimeTolerance = time.Second
+
+// defaultFileConcurrency is the number of files a directory transfer moves
+// at once when FileConcurrency is not set.
+const defaultFileConcurrency = 4
+
+// WithInclude limits directory transfers to files matching at least one glob
+// pattern. Patterns without a slash match the file name ("*.log"); patterns
+// with a slash match the path relative to the directory, where "**" matches
+// any number of directories ("logs/**/*.txt").
+func WithInclude(patterns ...string) FileTransferOption {
+	return func(o *FileTransferOptions) { o.Include = append(o.Include, patterns...) }
+}
+
+// WithExclude skips files matching any glob pattern in directory transfers.
+// A pattern that matches a directory skips everything below it. See
+// WithInclude for the pattern syntax.
+func WithExclude(patterns ...string) FileTransferOption {
+	return func(o *FileTransferOptions) { o.Exclude = append(o.Exclude, patterns...) }
+}
+
+// WithPreserveTimestamps copies each file's modification time in directory
+// transfers.
+func WithPreserveTimestamps(enabled bool) FileTransferOption {
+	return func(o *FileTransferOptions) { o.PreserveTimestamps = enabled }
+}
+
+// WithFileConcurrency sets how many files a directory transfer moves at once.
+func WithFileConcurrency(n int) FileTransferOption {
+	return func(o *FileTransferOptions) { o.FileConcurrency = n }
+}
+
+// WithSkipUnchanged skips files whose destination already matches the source.
+func WithSkipUnchanged(mode SkipMode) FileTransferOption {
+	return func(o *FileTransferOptions) { o.SkipUnchanged = mode }
+}
+
+// DirectoryTransferResult summarizes a directory transfer. Paths are
+// relative to the transferred directory and use forward slashes.
+type DirectoryTransferResult struct {
+	// Transferred lists the files that were copied.
+	Transferred []string
+	// Skipped lists the files left alone because they were unchanged.
+	Skipped []string
+	// Bytes is the total size of the transferred files.
+	Bytes int64
+	// Manifest is the verification report of FetchDirectory with
+	// WithManifestVerification, nil otherwise.
+	Manifest *ManifestReport
+}
+
+// dirEntry is a file in a directory transfer.
+type dirEntry struct {
+	Rel     string // slash-separated path relative to the directory root
+	Size    int64
+	ModTime time.Time
+	Hash    string // hex SHA256, only filled for SkipHash
+}
+
+// CopyDirectory uploads the files below localDir to remoteDir, creating
+// remote directories as needed. Only regular files are copied; symlinks and
+// other special files are skipped.
+//
+// Each file is uploaded with CopyFile and the same options, so per-file
+// options such as chunk size and checksum verification apply to every file
+// (ProgressCallback reports per-file progress). Directory options are
+// WithInclude, WithExclude, WithPreserveTimestamps, WithFileConcurrency and
+// WithSkipUnchanged.
+//
+// On error, the result lists the files transferred before the failure.
+func (c *Client) CopyDirectory(ctx context.Context, localDir, remoteDir string, opts ...FileTransferOption) (*DirectoryTransferResult, error) {
+	ctx, cancelOp := operationContext(ctx)
+	defer cancelOp()
+
+	opt := DefaultFileTransferOptionsForTransport(c.config.Transport)
+	for _, fn := range opts {
+		fn(&opt)
+	}
+	if err := validatePaths(localDir, remoteDir); err != nil {
+		return nil, fmt.Errorf("path validation failed: %w", err)
+	}
+	filter, err := newPathFilter(opt.Include, opt.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := listLocalDir(localDir, filter, opt.SkipUnchanged == SkipHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var remote map[string]dirEntry
+	if opt.SkipUnchanged != SkipNone {
+		entries, err := c.listRemoteDir(ctx, remoteDir, false, opt.SkipUnchanged == SkipHash)
+		if err != nil {
+			return nil, err
+		}
+		remote = indexEntries(entries)
+	}
+
+	result := &DirectoryTransferResult{}
+	var toCopy []dirEntry
+	var needed int64
+	for _, e := range local {
+		dst, ok := remote[e.Rel]
+		if ok && unchanged(e, dst, opt.SkipUnchanged) {
+			result.Skipped = append(result.Skipped, e.Rel)
+			continue
+		}
+		toCopy = append(toCopy, e)
+		// Overwritten files free their old space
+		needed += e.Size - dst.Size
+	}
+	if err := c.preflightDirectory(ctx, remoteDir, needed, opt); err != nil {
+		return nil, err
+	}
+
+	c.logSecurityEvent("DIRECTORY_TRANSFER_START", map[string]interface{}{
+		"operation":   "CopyDirectory",
+		"source":      localDir,
+		"destination": remoteDir,
+		"files":       len(toCopy),
+		"skipped":     len(result.Skipped),
+	})
+
+	if _, err := c.Execute(ctx, generateMkdirScript(remoteDir, parentDirs(toCopy))); err != nil {
+		return result, fmt.Errorf("create remote directories: %w", err)
+	}
+
+	err = c.transferEntries(ctx, toCopy, opt.FileConcurrency, result, func(ctx context.Context, e dirEntry) error {
+		localPath := filepath.Join(localDir, filepath.FromSlash(e.Rel))
+		remotePath := remoteJoin(remoteDir, e.Rel)
+		if err := c.CopyFile(ctx, localPath, remotePath, opts...); err != nil {
+			return err
+		}
+		if opt.PreserveTimestamps {
+			if _, err := c.Execute(ctx, generateSetModTimeScript(remotePath, e.ModTime)); err != nil {
+				return fmt.Errorf("set modification time: %w", err)
+			}
+		}
+		return nil
+	})
+	c.logDirectoryTransferComplete("CopyDirectory", remoteDir, result, err)
+	return result, err
+}
+
+// FetchDirectory downloads the files below remoteDir to localDir, creating
+// local directories as needed. It takes the same options as CopyDirectory.
+// Remote paths that would escape localDir are rejected.
+//
+// With WithManifestVerification, the remote files are hashed before the
+// download and every local file is checked against that manifest afterwards,
+// even if the download failed part-way. The report is in the result's
+// Manifest; differences return ErrManifestMismatch.
+//
+// On error, the result lists the files transferred before the failure.
+func (c *Client) FetchDirectory(ctx context.Context, remoteDir, localDir string, opts ...FileTransferOption) (*DirectoryTransferResult, error) {
+	ctx, cancelOp := operationContext(ctx)
+	defer cancelOp()
+
+	opt := DefaultFileTransferOptionsForTransport(c.config.Transport)
+	for _, fn := range opts {
+		fn(&opt)
+	}
+	if err := validatePaths(localDir, remoteDir); err != nil {
+		return nil, fmt.Errorf("path validation failed: %w", err)
+	}
+	filter, err := newPathFilter(opt.Include, opt.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	withHash := opt.SkipUnchanged == SkipHash || opt.VerifyManifest
+	entries, err := c.listRemoteDir(ctx, remoteDir, true, withHash)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DirectoryTransferResult{}
+	var toFetch, manifest []dirEntry
+	for _, e := range entries {
+		if !filepath.IsLocal(filepath.FromSlash(e.Rel)) {
+			return nil, fmt.Errorf("remote file %q escapes the destination directory", e.Rel)
+		}
+		if !filter.matchesFile(e.Rel) {
+			continue
+		}
+		manifest = append(manifest, e)
+		if opt.SkipUnchanged != SkipNone {
+			localPath := filepath.Join(localDir, filepath.FromSlash(e.Rel))
+			if dst, ok := statLocalFile(localPath, opt.SkipUnchanged == SkipHash); ok && unchanged(e, dst, opt.SkipUnchanged) {
+				result.Skipped = append(result.Skipped, e.Rel)
+				continue
+			}
+		}
+		toFetch = append(toFetch, e)
+	}
+
+	c.logSecurityEvent("DIRECTORY_TRANSFER_START", map[string]interface{}{
+		"operation":   "FetchDirectory",
+		"source":      remoteDir,
+		"destination": localDir,
+		"files":       len(toFetch),
+		"skipped":     len(result.Skipped),
+	})
+
+	err = c.transferEntries(ctx, toFetch, opt.FileConcurrency, result, func(ctx context.Context, e dirEntry) error {
+		localPath := filepath.Join(localDir, filepath.FromSlash(e.Rel))
+		if err := os.MkdirAll(filepath.Dir(localPath), 0o750); err != nil {
+			return fmt.Errorf("create local directory: %w", err)
+		}
+		if err := c.FetchFile(ctx, remoteJoin(remoteDir, e.Rel), localPath, opts...); err != nil {
+			return err
+		}
+		if opt.PreserveTimestamps {
+			if err := os.Chtimes(localPath, e.ModTime, e.ModTime); err != nil {
+				return fmt.Errorf("set modification time: %w", err)
+			}
+		}
+		return nil
+	})
+	if opt.VerifyManifest {
+		result.Manifest = verifyManifest(localDir, manifest)
+		c.logSecurityEvent("DIRECTORY_MANIFEST_VERIFIED", map[string]interface{}{
+			"operation": "FetchDirectory",
+			"source":    remoteDir,
+			"files":     len(result.Manifest.Files),
+			"verified":  len(result.Manifest.Verified),
+			"missing":   len(result.Manifest.Missing),
+			"corrupted": len(result.Manifest.Corrupted),
+		})
+		if err == nil && !result.Manifest.OK() {
+			err = fmt.Errorf("%w: %d missing, %d corrupted", ErrManifestMismatch, len(result.Manifest.Missing), len(result.Manifest.Corrupted))
+		}
+	}
+	c.logDirectoryTransferComplete("FetchDirectory", localDir, result, err)
+	return result, err
+}
+
+// transferEntries runs transfer for each entry, concurrency files at a time,
+// and records the transferred files in result. The first error cancels the
+// remaining transfers.
+func (c *Client) transferEntries(ctx context.Context, entries []dirEntry, concurrency int, result *DirectoryTransferResult, transfer func(context.Context, dirEntry) error) error {
+	if concurrency <= 0 {
+		concurrency = defaultFileConcurrency
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+
+	var mu sync.Mutex
+	for _, e := range entries {
+		g.Go(func() error {
+			if err := transfer(gctx, e); err != nil {
+				return fmt.Errorf("%s: %w", e.Rel, err)
+			}
+			mu.Lock()
+			result.Transferred = append(result.Transferred, e.Rel)
+			result.Bytes += e.Size
+			mu.Unlock()
+			return nil
+		})
+	}
+	err := g.Wait()
+	slices.Sort(result.Transferred)
+	return err
+}
+
+func (c *Client) logDirectoryTransferComplete(operation, destination string, result *DirectoryTransferResult, err error) {
+	details := map[string]interface{}{
+		"operation":   operation,
+		"destination": destination,
+		"files":       len(result.Transferred),
+		"skipped":     len(result.Skipped),
+		"bytes":       result.Bytes,
+		"status":      "success",
+	}
+	if err != nil {
+		details["status"] = "failed"
+		details["error"] = err.Error()
+		c.logSecurityEvent("DIRECTORY_TRANSFER_FAILED", details)
+		return
+	}
+	c.logSecurityEvent("DIRECTORY_TRANSFER_COMPLETE", details)
+	c.logInfo("%s: %d files transferred, %d skipped (%d bytes)", operation, len(result.Transferred), len(result.Skipped), result.Bytes)
+}
+
+// unchanged reports whether dst already matches src under mode.
+func unchanged(src, dst dirEntry, mode SkipMode) bool {
+	if src.Size != dst.Size {
+		return false
+	}
+	switch mode {
+	case SkipSizeModTime:
+		d := src.ModTime.Sub(dst.ModTime)
+		return d > -modTimeTolerance && d < modTimeTolerance
+	case SkipHash:
+		return src.Hash != "" && strings.EqualFold(src.Hash, dst.Hash)
+	default:
+		return false
+	}
+}
+
+func indexEntries(entries []dirEntry) map[string]dirEntry {
+	index := make(map[string]dirEntry, len(entries))
+	for _, e := range entries {
+		index[e.Rel] = e
+	}
+	return index
+}
+
+// parentDirs returns the distinct parent directories of entries, relative to
+// the root, parents before children.
+func parentDirs(entries []dirEntry) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, e := range entries {
+		for dir := path.Dir(e.Rel); dir != "." && !seen[dir]; dir = path.Dir(dir) {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	slices.Sort(dirs)
+	return dirs
+}
+
+// remoteJoin joins a slash-separated relative path onto a Windows directory.
+func remoteJoin(dir, rel string) string {
+	return strings.TrimRight(dir, `\/`) + `\` + strings.ReplaceAll(rel, "/", `\`)
+}
+
+// listLocalDir returns the regular files below root that pass filter,
+// sorted by path.
+func listLocalDir(root string, filter pathFilter, withHash bool) ([]dirEntry, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat local directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("source is not a directory: %s", root)
+	}
+
+	var entries []dirEntry
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if filter.excluded(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Security: only regular files (no symlinks or devices), as in CopyFile
+		if !d.Type().IsRegular() || !filter.matchesFile(rel) {
+			return nil
+		}
+		e, ok := statLocalFile(p, withHash)
+		if !ok {
+			return fmt.Errorf("failed to read local file: %s", p)
+		}
+		e.Rel = rel
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// statLocalFile describes the regular file at p. ok is false if it does not
+// exist, is not a regular file or cannot be hashed.
+func statLocalFile(p string, withHash bool) (dirEntry, bool) {
+	info, err := os.Lstat(p)
+	if err != nil || !info.Mode().IsRegular() {
+		return dirEntry{}, false
+	}
+	e := dirEntry{Size: info.Size(), ModTime: info.ModTime()}
+	if withHash {
+		if e.Hash, err = fileSHA256(p); err != nil {
+			return dirEntry{}, false
+		}
+	}
+	return e, true
+}
+
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p) // #nosec G304 -- path comes from a directory walk
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// listRemoteDir lists the files below remoteDir. If mustExist is false, a
+// missing directory is reported as empty.
+func (c *Client) listRemoteDir(ctx context.Context, remoteDir string, mustExist, withHash bool) ([]dirEntry, error) {
+	result, err := c.Execute(ctx, generateListDirScript(remoteDir, mustExist, withHash))
+	if err != nil {
+		if strings.Contains(err.Error(), "Cannot find path") {
+			return nil, fmt.Errorf("remote directory not found")
+		}
+		return nil, fmt.Errorf("failed to list remote directory: %w", err)
+	}
+	var entries []dirEntry
+	for _, out := range result.Output {
+		e, err := parseDirEntry(strings.TrimSpace(outputString(out)))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b dirEntry) int { return strings.Compare(a.Rel, b.Rel) })
+	return entries, nil
+}
+
+// parseDirEntry parses a "size|mtime-ms|hash|path" line from the listing
+// script. The path comes last since it may contain '|'.
+func parseDirEntry(line string) (dirEntry, error) {
+	fields := strings.SplitN(line, "|", 4)
+	if len(fields) != 4 || fields[3] == "" {
+		return dirEntry{}, fmt.Errorf("unexpected directory listing line: %q", line)
+	}
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return dirEntry{}, fmt.Errorf("invalid size in directory listing: %q", line)
+	}
+	ms, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return dirEntry{}, fmt.Errorf("invalid modification time in directory listing: %q", line)
+	}
+	return dirEntry{Rel: fields[3], Size: size, ModTime: time.UnixMilli(ms), Hash: fields[2]}, nil
+}
+
+// generateListDirScript creates a PowerShell script that prints one
+// "size|mtime-ms|hash|path" line per file below remoteDir.
+func generateListDirScript(remoteDir string, mustExist, withHash bool) string {
+	remoteDirB64 := base64.StdEncoding.EncodeToString([]byte(remoteDir))
+	return fmt.Sprintf(`
+		$ErrorActionPreference = 'Stop'
+		$pathBytes = [System.Convert]::FromBase64String('%s')
+		$root = [System.Text.Encoding]::UTF8.GetString($pathBytes)
+		if (-not (Test-Path -LiteralPath $root -PathType Container)) {
+			if ($%t) { throw "Cannot find path '$root'" }
+			return
+		}
+		$root = (Get-Item -LiteralPath $root -Force).FullName.TrimEnd('\')
+		Get-ChildItem -LiteralPath $root -Recurse -File -Force | ForEach-Object {
+			$rel = $_.FullName.Substring($root.Length + 1).Replace('\', '/')
+			$hash = ''
+			if ($%t) { $hash = (Get-FileHash -Algorithm SHA256 -LiteralPath $_.FullName).Hash }
+			$ms = ([DateTimeOffset]$_.LastWriteTimeUtc).ToUnixTimeMilliseconds()
+			'{0}|{1}|{2}|{3}' -f $_.Length, $ms, $hash, $rel
+		}
+	`, remoteDirB64, mustExist, withHash)
+}
+
+// generateMkdirScript creates a PowerShell script that creates remoteDir and
+// the given slash-separated subdirectories.
+func generateMkdirScript(remoteDir string, dirs []string) string {
+	remoteDirB64 := base64.StdEncoding.EncodeToString([]byte(remoteDir))
+	dirsB64 := base64.StdEncoding.EncodeToString([]byte(strings.Join(dirs, "\n")))
+	return fmt.Sprintf(`
+		$ErrorActionPreference = 'Stop'
+		try {
+			$root = [System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String('%s'))
+			$dirs = [System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String('%s'))
+			New-Item -ItemType Directory -Path $root -Force | Out-Null
+			foreach ($rel in ($dirs -split '\n' | Where-Object { $_ })) {
+				New-Item -ItemType Directory -Path (Join-Path $root $rel.Replace('/', '\')) -Force | Out-Null
+			}
+		} catch {
+			Write-Error "Failed to create directory: $_"
+			exit 1
+		}
+	`, remoteDirB64, dirsB64)
+}
+
+// generateSetModTimeScript creates a PowerShell script that sets the
+// modification time of remotePath.
+func generateSetModTimeScript(remotePath string, modTime time.Time) string {
+	remotePathB64 := base64.StdEncoding.EncodeToString([]byte(remotePath))
+	return fmt.Sprintf(`
+		$ErrorActionPreference = 'Stop'
+		try {
+			$pathBytes = [System.Convert]::FromBase64String('%s')
+			$path = [System.Text.Encoding]::UTF8.GetString($pathBytes)
+			(Get-Item -LiteralPath $path -Force).LastWriteTimeUtc = [DateTimeOffset]::FromUnixTimeMilliseconds(%d).UtcDateTime
+		} catch {
+			Write-Error "Failed to set modification time: $_"
+			exit 1
+		}
+	`, remotePathB64, modTime.UnixMilli())
+}
+
+// pathFilter applies include/exclude glob patterns to slash-separated paths
+// relative to a transferred directory.
+type pathFilter struct {
+	include []string
+	exclude []string
+}
+
+func newPathFilter(include, exclude []string) (pathFilter, error) {
+	for _, p := range slices.Concat(include, exclude) {
+		for _, seg := range strings.Split(p, "/") {
+			if _, err := path.Match(seg, ""); err != nil {
+				return pathFilter{}, fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+		}
+	}
+	return pathFilter{include: include, exclude: exclude}, nil
+}
+
+// excluded reports whether rel matches an exclude pattern.
+func (f pathFilter) excluded(rel string) bool {
+	return slices.ContainsFunc(f.exclude, func(p string) bool { return matchGlob(p, rel) })
+}
+
+// matchesFile reports whether the file rel should be transferred: it matches
+// an include pattern (if any) and neither it nor a parent directory is
+// excluded.
+func (f pathFilter) matchesFile(rel string) bool {
+	for dir := rel; dir != "."; dir = path.Dir(dir) {
+		if f.excluded(dir) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(f.include, func(p string) bool { return matchGlob(p, rel) })
+}
+
+// matchGlob matches a slash-separated path against pattern. A pattern
+// without a slash matches the last element; otherwise it matches the whole
+// path, with "**" matching zero or more elements.
+func matchGlob(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{"*.log", "app.log", true},
+		{"*.log", "logs/2024/app.log", true},
+		{"*.log", "app.txt", false},
+		{"logs/*.txt", "logs/a.txt", true},
+		{"logs/*.txt", "logs/sub/a.txt", false},
+		{"logs/**/*.txt", "logs/a.txt", true},
+		{"logs/**/*.txt", "logs/sub/deep/a.txt", true},
+		{"logs/**/*.txt", "other/a.txt", false},
+		{"**/bin", "src/app/bin", true},
+		{"node_modules", "web/node_modules", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestPathFilter(t *testing.T) {
+	f, err := newPathFilter([]string{"*.go", "docs/**/*.md"}, []string{"vendor", "*_test.go"})
+	if err != nil {
+		t.Fatalf("newPathFilter() error = %v", err)
+	}
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"main.go", true},
+		{"pkg/util.go", true},
+		{"pkg/util_test.go", false},
+		{"vendor/lib/lib.go", false},
+		{"docs/guide/intro.md", true},
+		{"README.md", false},
+	}
+	for _, tt := range tests {
+		if got := f.matchesFile(tt.rel); got != tt.want {
+			t.Errorf("matchesFile(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestNewPathFilter_InvalidPattern(t *testing.T) {
+	if _, err := newPathFilter(nil, []string{"logs/[a-"}); err == nil {
+		t.Error("newPathFilter() with malformed pattern error = nil")
+	}
+}
+
+func TestParseDirEntry(t *testing.T) {
+	e, err := parseDirEntry("1024|1700000000123|ABCD|logs/a|b.txt")
+	if err != nil {
+		t.Fatalf("parseDirEntry() error = %v", err)
+	}
+	want := dirEntry{Rel: "logs/a|b.txt", Size: 1024, ModTime: time.UnixMilli(1700000000123), Hash: "ABCD"}
+	if e != want {
+		t.Errorf("parseDirEntry() = %+v, want %+v", e, want)
+	}
+
+	for _, line := range []string{"", "1|2|3", "x|2||a.txt", "1|y||a.txt", "1|2||"} {
+		if _, err := parseDirEntry(line); err == nil {
+			t.Errorf("parseDirEntry(%q) error = nil", line)
+		}
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	src := dirEntry{Size: 10, ModTime: now, Hash: "abc"}
+	tests := []struct {
+		name string
+		dst  dirEntry
+		mode SkipMode
+		want bool
+	}{
+		{"none", src, SkipNone, false},
+		{"same size and time", dirEntry{Size: 10, ModTime: now.Add(300 * time.Millisecond)}, SkipSizeModTime, true},
+		{"newer", dirEntry{Size: 10, ModTime: now.Add(time.Hour)}, SkipSizeModTime, false},
+		{"size differs", dirEntry{Size: 11, ModTime: now}, SkipSizeModTime, false},
+		{"same hash", dirEntry{Size: 10, Hash: "ABC"}, SkipHash, true},
+		{"hash differs", dirEntry{Size: 10, Hash: "def"}, SkipHash, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unchanged(src, tt.dst, tt.mode); got != tt.want {
+				t.Errorf("unchanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParentDirs(t *testing.T) {
+	got := parentDirs([]dirEntry{{Rel: "a/b/c.txt"}, {Rel: "a/d.txt"}, {Rel: "e.txt"}, {Rel: "f/g.txt"}})
+	want := []string{"a", "a/b", "f"}
+	if !slices.Equal(got, want) {
+		t.Errorf("parentDirs() = %v, want %v", got, want)
+	}
+}
+
+func TestRemoteJoin(t *testing.T) {
+	if got := remoteJoin(`C:\Data\`, "logs/app.log"); got != `C:\Data\logs\app.log` {
+		t.Errorf("remoteJoin() = %q", got)
+	}
+}
+
+func TestListLocalDir(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{"a.txt", "b.log", "sub/c.txt", "skip/d.txt"} {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(rel), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "a.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	filter, err := newPathFilter([]string{"*.txt"}, []string{"skip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := listLocalDir(root, filter, true)
+	if err != nil {
+		t.Fatalf("listLocalDir() error = %v", err)
+	}
+
+	var rels []string
+	for _, e := range entries {
+		rels = append(rels, e.Rel)
+		if e.Size != int64(len(e.Rel)) || len(e.Hash) != 64 {
+			t.Errorf("entry %+v has wrong size or hash", e)
+		}
+	}
+	if want := []string{"a.txt", "sub/c.txt"}; !slices.Equal(rels, want) {
+		t.Errorf("listLocalDir() = %v, want %v", rels, want)
+	}
+}
+
+func TestGenerateListDirScript(t *testing.T) {
+	script := generateListDirScript(`C:\Data'; Remove-Item C:\`, true, false)
+	if strings.Contains(script, "Remove-Item") {
+		t.Error("remote path is not encoded in the listing script")
+	}
+	if !strings.Contains(script, "if ($true)") || !strings.Contains(script, "if ($false)") {
+		t.Error("listing script flags not rendered")
+	}
+}
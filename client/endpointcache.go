@@ -0,0 +1,390 @@
+package client
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smnsjas/go-psrp/wsman"
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+// endpointCacheMu serializes access to endpoint cache files within the
+// process.
+var endpointCacheMu sync.Mutex
+
+// EndpointFacts are facts about an endpoint learned while connecting. With
+// Config.EndpointCacheFile they are kept on disk, so that later clients
+// skip discovering them again and notice when they change.
+type EndpointFacts struct {
+	// MaxEnvelopeSize and MaxTimeout are the limits of the server's
+	// winrm/config, read with Config.NegotiateWSManLimits.
+	MaxEnvelopeSize int           `json:"max_envelope_size,omitempty"`
+	MaxTimeout      time.Duration `json:"max_timeout_ns,omitempty"`
+
+	// MaxConcurrentOperationsPerUser and MaxShellsPerUser are the per-user
+	// quotas of winrm/config that cap MaxRunspaces.
+	MaxConcurrentOperationsPerUser int `json:"max_concurrent_operations_per_user,omitempty"`
+	MaxShellsPerUser               int `json:"max_shells_per_user,omitempty"`
+
+	// AuthSchemes are the authentication schemes the server offered.
+	AuthSchemes []string `json:"auth_schemes,omitempty"`
+
+	// PSVersion and ProtocolVersion are the versions the server sent in
+	// its SESSION_CAPABILITY message.
+	PSVersion       string `json:"ps_version,omitempty"`
+	ProtocolVersion string `json:"protocol_version,omitempty"`
+
+	// TLSFingerprint is the SHA-256 fingerprint of the server
+	// certificate, in hex.
+	TLSFingerprint string `json:"tls_fingerprint,omitempty"`
+
+	// UpdatedAt is when the facts were last recorded.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FactChange is an endpoint fact that differs from the one cached by an
+// earlier run.
+type FactChange struct {
+	Name   string
+	Before string
+	After  string
+}
+
+// String returns "Name: before -> after".
+func (f FactChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", f.Name, f.Before, f.After)
+}
+
+// endpointFacts tracks the facts of a client's endpoint: those cached in
+// the file and those this client has seen.
+type endpointFacts struct {
+	file string
+	key  string
+
+	mu       sync.Mutex
+	loaded   bool
+	cached   *EndpointFacts
+	observed EndpointFacts
+}
+
+func newEndpointFacts(file, key string) *endpointFacts {
+	return &endpointFacts{file: file, key: strings.ToLower(key)}
+}
+
+// load reads the cached facts once. A missing file caches nothing.
+func (f *endpointFacts) load() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.loaded {
+		return nil
+	}
+	f.loaded = true
+
+	endpointCacheMu.Lock()
+	entries, err := readEndpointCache(f.file)
+	endpointCacheMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if facts, ok := entries[f.key]; ok {
+		f.cached = &facts
+	}
+	return nil
+}
+
+// facts returns the cached facts updated with the observed ones.
+func (f *endpointFacts) facts() EndpointFacts {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var merged EndpointFacts
+	if f.cached != nil {
+		merged = *f.cached
+	}
+	return mergeFacts(merged, f.observed)
+}
+
+// cachedServerConfig returns the cached winrm/config limits, or nil.
+func (f *endpointFacts) cachedServerConfig() *wsman.ServerConfig {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cached == nil || f.cached.MaxEnvelopeSize <= 0 {
+		return nil
+	}
+	return &wsman.ServerConfig{
+		MaxEnvelopeSize:                f.cached.MaxEnvelopeSize,
+		MaxTimeout:                     f.cached.MaxTimeout,
+		MaxConcurrentOperationsPerUser: f.cached.MaxConcurrentOperationsPerUser,
+		MaxShellsPerUser:               f.cached.MaxShellsPerUser,
+	}
+}
+
+func (f *endpointFacts) observeServerConfig(cfg *wsman.ServerConfig) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.observed.MaxEnvelopeSize = cfg.MaxEnvelopeSize
+	f.observed.MaxTimeout = cfg.MaxTimeout
+	f.observed.MaxConcurrentOperationsPerUser = cfg.MaxConcurrentOperationsPerUser
+	f.observed.MaxShellsPerUser = cfg.MaxShellsPerUser
+}
+
+func (f *endpointFacts) observeCapability(capability *SessionCapability) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.observed.PSVersion = capability.PSVersion
+	f.observed.ProtocolVersion = capability.ProtocolVersion
+}
+
+// observeTLS records the fingerprint of the server certificate. It is a
+// tls.Config.VerifyConnection that accepts every certificate, so it runs
+// after, not instead of, the usual verification.
+func (f *endpointFacts) observeTLS(cs tls.ConnectionState) error {
+	fp, err := certFingerprint(cs)
+	if err != nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.observed.TLSFingerprint = hex.EncodeToString(fp)
+	return nil
+}
+
+// watchTLS chains observeTLS into the TLS verification of tr.
+func (f *endpointFacts) watchTLS(tr *transport.HTTPTransport) {
+	ht, ok := tr.Client().Transport.(*http.Transport)
+	if !ok || ht.TLSClientConfig == nil {
+		return
+	}
+	next := ht.TLSClientConfig.VerifyConnection
+	ht.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		_ = f.observeTLS(cs)
+		if next != nil {
+			return next(cs)
+		}
+		return nil
+	}
+}
+
+// schemeRecorder records the authentication schemes of 401 responses.
+type schemeRecorder struct {
+	base  http.RoundTripper
+	facts *endpointFacts
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *schemeRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		if schemes := transport.AuthSchemes(resp.Header); len(schemes) > 0 {
+			r.facts.mu.Lock()
+			r.facts.observed.AuthSchemes = schemes
+			r.facts.mu.Unlock()
+		}
+	}
+	return resp, err
+}
+
+// CloseIdleConnections forwards to the base transport.
+func (r *schemeRecorder) CloseIdleConnections() {
+	if ci, ok := r.base.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// update compares the observed facts with the cached ones and records the
+// merged facts in the file. It returns the facts that changed.
+func (f *endpointFacts) update(now time.Time) ([]FactChange, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var merged EndpointFacts
+	var changes []FactChange
+	if f.cached != nil {
+		merged = *f.cached
+		changes = diffFacts(*f.cached, f.observed)
+	}
+	merged = mergeFacts(merged, f.observed)
+	merged.UpdatedAt = now
+
+	endpointCacheMu.Lock()
+	defer endpointCacheMu.Unlock()
+	entries, err := readEndpointCache(f.file)
+	if err != nil {
+		return changes, err
+	}
+	entries[f.key] = merged
+	if err := writeEndpointCache(f.file, entries); err != nil {
+		return changes, err
+	}
+	f.cached = &merged
+	return changes, nil
+}
+
+// mergeFacts returns base with the facts set in observed.
+func mergeFacts(base, observed EndpointFacts) EndpointFacts {
+	if observed.MaxEnvelopeSize > 0 {
+		base.MaxEnvelopeSize = observed.MaxEnvelopeSize
+		base.MaxTimeout = observed.MaxTimeout
+		base.MaxConcurrentOperationsPerUser = observed.MaxConcurrentOperationsPerUser
+		base.MaxShellsPerUser = observed.MaxShellsPerUser
+	}
+	if len(observed.AuthSchemes) > 0 {
+		base.AuthSchemes = observed.AuthSchemes
+	}
+	if observed.PSVersion != "" {
+		base.PSVersion = observed.PSVersion
+		base.ProtocolVersion = observed.ProtocolVersion
+	}
+	if observed.TLSFingerprint != "" {
+		base.TLSFingerprint = observed.TLSFingerprint
+	}
+	return base
+}
+
+// diffFacts returns the facts set in both that differ.
+func diffFacts(cached, observed EndpointFacts) []FactChange {
+	var changes []FactChange
+	add := func(name, before, after string) {
+		if before != "" && after != "" && before != after {
+			changes = append(changes, FactChange{Name: name, Before: before, After: after})
+		}
+	}
+	itoa := func(n int) string {
+		if n <= 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	add("MaxEnvelopeSize", itoa(cached.MaxEnvelopeSize), itoa(observed.MaxEnvelopeSize))
+	if cached.MaxTimeout > 0 && observed.MaxTimeout > 0 {
+		add("MaxTimeout", cached.MaxTimeout.String(), observed.MaxTimeout.String())
+	}
+	add("MaxConcurrentOperationsPerUser", itoa(cached.MaxConcurrentOperationsPerUser), itoa(observed.MaxConcurrentOperationsPerUser))
+	add("MaxShellsPerUser", itoa(cached.MaxShellsPerUser), itoa(observed.MaxShellsPerUser))
+	if len(observed.AuthSchemes) > 0 && !slices.Equal(cached.AuthSchemes, observed.AuthSchemes) {
+		add("AuthSchemes", strings.Join(cached.AuthSchemes, ", "), strings.Join(observed.AuthSchemes, ", "))
+	}
+	add("PSVersion", cached.PSVersion, observed.PSVersion)
+	add("ProtocolVersion", cached.ProtocolVersion, observed.ProtocolVersion)
+	add("TLSFingerprint", cached.TLSFingerprint, observed.TLSFingerprint)
+	return changes
+}
+
+// readEndpointCache reads the facts of all endpoints in file. A missing
+// file has none.
+func readEndpointCache(file string) (map[string]EndpointFacts, error) {
+	entries := make(map[string]EndpointFacts)
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read endpoint cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("read endpoint cache %s: %w", file, err)
+	}
+	return entries, nil
+}
+
+// writeEndpointCache replaces file with entries, readable only by the
+// owner. The file is replaced atomically, so a concurrent reader in
+// another process never sees it half written.
+func writeEndpointCache(file string, entries map[string]EndpointFacts) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("write endpoint cache: %w", err)
+	}
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("write endpoint cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write endpoint cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write endpoint cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write endpoint cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("write endpoint cache: %w", err)
+	}
+	return nil
+}
+
+// EndpointFacts returns what is known about the endpoint: the facts cached
+// in Config.EndpointCacheFile, updated with those this client has seen.
+// Without a cache file it is empty.
+func (c *Client) EndpointFacts() EndpointFacts {
+	if c.endpointFacts == nil {
+		return EndpointFacts{}
+	}
+	if err := c.endpointFacts.load(); err != nil {
+		c.logWarn("Endpoint cache: %v", err)
+	}
+	return c.endpointFacts.facts()
+}
+
+// cachedServerConfigLocked returns the winrm/config limits cached for the
+// endpoint, or nil (caller must hold c.mu).
+func (c *Client) cachedServerConfigLocked() *wsman.ServerConfig {
+	if c.endpointFacts == nil {
+		return nil
+	}
+	if err := c.endpointFacts.load(); err != nil {
+		c.logInfoLocked("Endpoint cache: %v", err)
+		return nil
+	}
+	return c.endpointFacts.cachedServerConfig()
+}
+
+// recordEndpointFacts records the facts seen so far and reports those that
+// changed since the last run.
+func (c *Client) recordEndpointFacts() {
+	if c.endpointFacts == nil {
+		return
+	}
+	if err := c.endpointFacts.load(); err != nil {
+		c.logWarn("Endpoint cache: %v", err)
+		return
+	}
+	changes, err := c.endpointFacts.update(time.Now())
+	if err != nil {
+		c.logWarn("Endpoint cache: %v", err)
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	securityLogger := c.securityLogger
+	onDrift := c.config.OnEndpointDrift
+	c.mu.Unlock()
+
+	details := make(map[string]any, len(changes))
+	for _, change := range changes {
+		c.logWarn("Endpoint configuration changed since the last run: %s", change)
+		details[change.Name] = change.Before + " -> " + change.After
+	}
+	if securityLogger != nil {
+		securityLogger.LogConnection(SubtypeConnDrift, OutcomeSuccess, SeverityWarning, details)
+	}
+	if onDrift != nil {
+		onDrift(changes)
+	}
+}
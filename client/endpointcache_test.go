@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+func TestEndpointFacts_RoundTrip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "endpoints.json")
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	first := newEndpointFacts(file, "https://Server01:5986/wsman")
+	if err := first.load(); err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if cfg := first.cachedServerConfig(); cfg != nil {
+		t.Fatalf("cachedServerConfig() = %+v before anything was cached", cfg)
+	}
+	first.observeServerConfig(&wsman.ServerConfig{MaxEnvelopeSize: 512000, MaxTimeout: time.Minute, MaxShellsPerUser: 30})
+	first.observeCapability(&SessionCapability{PSVersion: "5.1", ProtocolVersion: "2.3"})
+	first.observed.AuthSchemes = []string{"Negotiate", "Kerberos"}
+	first.observed.TLSFingerprint = "aa"
+	if changes, err := first.update(now); err != nil || len(changes) != 0 {
+		t.Fatalf("update() = %v, %v; want no changes on the first run", changes, err)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("cache file not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("cache file mode = %v, want 0600", perm)
+	}
+
+	second := newEndpointFacts(file, "https://server01:5986/wsman")
+	if err := second.load(); err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	cfg := second.cachedServerConfig()
+	if cfg == nil || cfg.MaxEnvelopeSize != 512000 || cfg.MaxTimeout != time.Minute || cfg.MaxShellsPerUser != 30 {
+		t.Fatalf("cachedServerConfig() = %+v, want the cached limits", cfg)
+	}
+
+	second.observeCapability(&SessionCapability{PSVersion: "7.4", ProtocolVersion: "2.3"})
+	second.observed.TLSFingerprint = "bb"
+	changes, err := second.update(now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+	want := []FactChange{
+		{Name: "PSVersion", Before: "5.1", After: "7.4"},
+		{Name: "TLSFingerprint", Before: "aa", After: "bb"},
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("update() changes = %v, want %v", changes, want)
+	}
+
+	facts := second.facts()
+	if facts.PSVersion != "7.4" || facts.MaxEnvelopeSize != 512000 || len(facts.AuthSchemes) != 2 {
+		t.Errorf("facts() = %+v, want cached facts updated with observed ones", facts)
+	}
+	if !facts.UpdatedAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("UpdatedAt = %v, want %v", facts.UpdatedAt, now.Add(time.Hour))
+	}
+}
+
+func TestEndpointFacts_KeepsOtherEndpoints(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "endpoints.json")
+
+	a := newEndpointFacts(file, "https://a:5986/wsman")
+	a.observed.PSVersion = "5.1"
+	b := newEndpointFacts(file, "https://b:5986/wsman")
+	b.observed.PSVersion = "7.4"
+	for _, f := range []*endpointFacts{a, b} {
+		if _, err := f.update(time.Now()); err != nil {
+			t.Fatalf("update() error = %v", err)
+		}
+	}
+
+	entries, err := readEndpointCache(file)
+	if err != nil {
+		t.Fatalf("readEndpointCache() error = %v", err)
+	}
+	if entries["https://a:5986/wsman"].PSVersion != "5.1" || entries["https://b:5986/wsman"].PSVersion != "7.4" {
+		t.Errorf("cache = %+v, want both endpoints", entries)
+	}
+}
+
+func TestEndpointFacts_CorruptFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "endpoints.json")
+	if err := os.WriteFile(file, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newEndpointFacts(file, "https://a:5986/wsman")
+	if err := f.load(); err == nil {
+		t.Error("load() error = nil for a corrupt file")
+	}
+	if cfg := f.cachedServerConfig(); cfg != nil {
+		t.Errorf("cachedServerConfig() = %+v from a corrupt file", cfg)
+	}
+}
+
+func TestDiffFacts_IgnoresUnknown(t *testing.T) {
+	cached := EndpointFacts{MaxEnvelopeSize: 153600, AuthSchemes: []string{"Negotiate"}}
+	observed := EndpointFacts{PSVersion: "5.1"}
+	if changes := diffFacts(cached, observed); len(changes) != 0 {
+		t.Errorf("diffFacts() = %v, want no changes for facts not seen in both", changes)
+	}
+}
+
+func TestClient_EndpointFactsObserved(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("WWW-Authenticate", "Negotiate")
+		w.Header().Add("WWW-Authenticate", "Kerberos")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.AuthType = AuthBasic
+	cfg.Username = "svc"
+	cfg.Password = "secret"
+	cfg.InsecureSkipVerify = true
+	cfg.EndpointCacheFile = filepath.Join(t.TempDir(), "endpoints.json")
+
+	c, err := New(server.URL, cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	_, _ = c.transport.Post(context.Background(), server.URL, []byte("<a/>"))
+
+	facts := c.EndpointFacts()
+	if !slices.Equal(facts.AuthSchemes, []string{"Negotiate", "Kerberos"}) {
+		t.Errorf("AuthSchemes = %v, want [Negotiate Kerberos]", facts.AuthSchemes)
+	}
+	sum := sha256.Sum256(server.Certificate().Raw)
+	if want := hex.EncodeToString(sum[:]); facts.TLSFingerprint != want {
+		t.Errorf("TLSFingerprint = %q, want %q", facts.TLSFingerprint, want)
+	}
+}
+
+func TestClient_EndpointFactsWithoutCache(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AuthType = AuthBasic
+	cfg.Username = "svc"
+	cfg.Password = "secret"
+
+	c, err := New("server01", cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if facts := c.EndpointFacts(); facts.PSVersion != "" || facts.MaxEnvelopeSize != 0 {
+		t.Errorf("EndpointFacts() = %+v without a cache file", facts)
+	}
+}
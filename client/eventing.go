@@ -26,13 +26,21 @@ type EventSubscription struct {
 	cancel       context.CancelFunc
 	ctx          context.Context
 	pollInterval time.Duration
+	expires      time.Duration
 }
 
 // SubscribeOptions contains options for the Subscribe operation.
 type SubscribeOptions struct {
 	ResourceURI  string        // Defaults to "http://schemas.microsoft.com/wbem/wsman/1/wmi/root/cimv2/*"
-	Expires      time.Duration // Defaults to 10 minutes
+	Expires      time.Duration // Defaults to 10 minutes; renewed at half-life until Close
 	PollInterval time.Duration // Defaults to 2 seconds
+
+	// Dialect is the query dialect, wsman.DialectWQL if empty.
+	Dialect string
+
+	// ReadExistingEvents also delivers events already in an event log
+	// channel (SubscribeEventLog).
+	ReadExistingEvents bool
 }
 
 // Subscribe subscribes to specific events using a WQL query.
@@ -55,6 +63,8 @@ func (c *Client) Subscribe(ctx context.Context, query string, opts ...SubscribeO
 		if opts[0].PollInterval > 0 {
 			opt.PollInterval = opts[0].PollInterval
 		}
+		opt.Dialect = opts[0].Dialect
+		opt.ReadExistingEvents = opts[0].ReadExistingEvents
 	}
 
 	// Validate inputs
@@ -71,7 +81,12 @@ func (c *Client) Subscribe(ctx context.Context, query string, opts ...SubscribeO
 		return nil, fmt.Errorf("wsman client not initialized")
 	}
 
-	sub, err := c.wsman.Subscribe(ctx, opt.ResourceURI, query)
+	sub, err := c.wsman.SubscribeWithOptions(ctx, opt.ResourceURI, wsman.SubscribeOptions{
+		Filter:             query,
+		Dialect:            opt.Dialect,
+		Expires:            isoDuration(opt.Expires),
+		ReadExistingEvents: opt.ReadExistingEvents,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("subscribe failed: %w", err)
 	}
@@ -88,6 +103,7 @@ func (c *Client) Subscribe(ctx context.Context, query string, opts ...SubscribeO
 		cancel:       cancel,
 		ctx:          ctx,
 		pollInterval: opt.PollInterval,
+		expires:      opt.Expires,
 	}
 
 	c.logInfo("Subscribed to events: query='%s', sub_id='%s'", query, sub.SubscriptionID)
@@ -102,6 +118,25 @@ func (c *Client) Subscribe(ctx context.Context, query string, opts ...SubscribeO
 	return es, nil
 }
 
+// SubscribeEventLog subscribes to the events of a Windows Event Log channel,
+// e.g. "Security", that match xpath, e.g. "*[System[(EventID=4625)]]"; an
+// empty xpath selects all events. Each event is delivered as <Event> XML.
+// ResourceURI and Dialect in opts are ignored.
+func (c *Client) SubscribeEventLog(ctx context.Context, channel, xpath string, opts ...SubscribeOptions) (*EventSubscription, error) {
+	var opt SubscribeOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	opt.ResourceURI = wsman.ResourceURIEventLog
+	opt.Dialect = wsman.DialectEventQuery
+	return c.Subscribe(ctx, wsman.EventLogQuery(channel, xpath), opt)
+}
+
+// isoDuration formats d as an ISO 8601 duration, rounded up to seconds.
+func isoDuration(d time.Duration) string {
+	return fmt.Sprintf("PT%dS", int64((d+time.Second-1)/time.Second))
+}
+
 // pollLoop handles the periodic Pull operations.
 func (es *EventSubscription) pollLoop() {
 	defer close(es.events)
@@ -110,15 +145,32 @@ func (es *EventSubscription) pollLoop() {
 	ticker := time.NewTicker(es.pollInterval)
 	defer ticker.Stop()
 
+	// Renew at half-life, so one failed attempt does not end the subscription
+	renew := time.NewTicker(max(es.expires/2, time.Millisecond))
+	defer renew.Stop()
+
 	enumContext := es.sub.EnumerationContext
 
 	for {
 		select {
 		case <-es.ctx.Done():
 			return
+		case <-renew.C:
+			renewCtx, cancel := context.WithTimeout(es.ctx, 45*time.Second)
+			err := es.client.Renew(renewCtx, es.sub, isoDuration(es.expires))
+			cancel()
+			if err != nil {
+				if es.logger != nil {
+					es.logger.Warn("Subscription renew failed", "error", err)
+				}
+				select {
+				case es.errors <- fmt.Errorf("renew error: %w", err):
+				default:
+				}
+			}
 		case <-ticker.C:
 			// Perform Pull
-			// We use a longer timeout than the server-side limits (MaxTime PT5S / OperationTimeout PT20S)
+			// We use a longer timeout than the server-side limits (MaxTime PT5S / the OperationTimeout)
 			pullCtx, cancel := context.WithTimeout(es.ctx, 45*time.Second)
 			resp, err := es.client.Pull(pullCtx, es.resourceURI, enumContext, 100)
 			cancel()
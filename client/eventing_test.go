@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/smnsjas/go-psrp/wsman"
 	"github.com/smnsjas/go-psrp/wsman/transport"
 )
 
@@ -155,3 +156,64 @@ func TestSubscribe_InputValidation(t *testing.T) {
 		t.Errorf("Expected 'query too long' error, got: %v", err)
 	}
 }
+
+func TestSubscribeEventLog_Renews(t *testing.T) {
+	requests := make(chan string, 100)
+	mock := &MockHTTPTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			bodyBytes, _ := io.ReadAll(req.Body)
+			body := string(bodyBytes)
+			select {
+			case requests <- body:
+			default:
+			}
+
+			resp := `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"/>`
+			switch {
+			case strings.Contains(body, wsman.ActionSubscribe):
+				resp = `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:wse="http://schemas.xmlsoap.org/ws/2004/08/eventing">
+  <s:Body><wse:SubscribeResponse><wse:EnumerationContext>ctx-1</wse:EnumerationContext>
+    <wse:SubscriptionManager><a:Address xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing">http://mgr</a:Address></wse:SubscriptionManager>
+  </wse:SubscribeResponse></s:Body></s:Envelope>`
+			case strings.Contains(body, wsman.ActionPull):
+				resp = `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:wsen="http://schemas.xmlsoap.org/ws/2004/09/enumeration">
+  <s:Body><wsen:PullResponse><wsen:EnumerationContext>ctx-1</wsen:EnumerationContext><wsen:Items/></wsen:PullResponse></s:Body></s:Envelope>`
+			}
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(resp))}, nil
+		},
+	}
+
+	c, err := New("http://server", Config{Username: "user", Password: "pass", AuthType: AuthBasic})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	tr := transport.NewHTTPTransport()
+	tr.Client().Transport = mock
+	c.wsman.SetTransport(tr)
+
+	sub, err := c.SubscribeEventLog(context.Background(), "Security", "*[System[(EventID=4625)]]", SubscribeOptions{
+		Expires:            20 * time.Millisecond,
+		PollInterval:       time.Hour,
+		ReadExistingEvents: true,
+	})
+	if err != nil {
+		t.Fatalf("SubscribeEventLog: %v", err)
+	}
+	defer sub.Close()
+
+	subscribe := <-requests
+	for _, want := range []string{wsman.ResourceURIEventLog, wsman.DialectEventQuery, `<Select Path="Security">`, "ReadExistingEvents"} {
+		if !strings.Contains(subscribe, want) {
+			t.Errorf("Subscribe request missing %s:\n%s", want, subscribe)
+		}
+	}
+
+	select {
+	case body := <-requests:
+		if !strings.Contains(body, wsman.ActionRenew) || !strings.Contains(body, "<wse:Expires>PT1S</wse:Expires>") {
+			t.Errorf("expected a Renew request, got:\n%s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for renew")
+	}
+}
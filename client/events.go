@@ -0,0 +1,174 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/smnsjas/go-psrpcore/runspace"
+)
+
+// statePollInterval is how often subscriptions check the state between
+// the transitions the client reports itself.
+const statePollInterval = 250 * time.Millisecond
+
+// stateEvents delivers state and health changes to subscribers. A single
+// goroutine runs while there are subscribers, so callbacks are called one
+// at a time and in order.
+type stateEvents struct {
+	client   *Client
+	interval time.Duration
+
+	mu       sync.Mutex
+	nextID   int
+	onState  map[int]func(old, new runspace.State)
+	onHealth map[int]func(old, new HealthStatus)
+	running  bool
+	kick     chan struct{}
+}
+
+func newStateEvents(c *Client) *stateEvents {
+	return &stateEvents{
+		client:   c,
+		interval: statePollInterval,
+		onState:  make(map[int]func(old, new runspace.State)),
+		onHealth: make(map[int]func(old, new HealthStatus)),
+		kick:     make(chan struct{}, 1),
+	}
+}
+
+// eventsLocked returns c.events, creating it if needed (caller must hold
+// c.mu).
+func (c *Client) eventsLocked() *stateEvents {
+	if c.events == nil {
+		c.events = newStateEvents(c)
+	}
+	return c.events
+}
+
+// OnStateChange registers fn to be called when the runspace pool state
+// changes, e.g. from Opened to Broken, so applications need not poll
+// State. It returns a function that removes the registration. Callbacks
+// run on a goroutine of the client, one at a time; they should return
+// quickly and must not register or remove callbacks.
+func (c *Client) OnStateChange(fn func(old, new runspace.State)) (remove func()) {
+	c.mu.Lock()
+	e := c.eventsLocked()
+	c.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	id := e.nextID
+	e.nextID++
+	e.onState[id] = fn
+	e.startLocked()
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		delete(e.onState, id)
+	}
+}
+
+// OnHealthChange registers fn to be called when Health changes, e.g. from
+// Healthy to Reconnecting and back. It returns a function that removes the
+// registration. Callbacks run as for OnStateChange.
+func (c *Client) OnHealthChange(fn func(old, new HealthStatus)) (remove func()) {
+	c.mu.Lock()
+	e := c.eventsLocked()
+	c.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	id := e.nextID
+	e.nextID++
+	e.onHealth[id] = fn
+	e.startLocked()
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		delete(e.onHealth, id)
+	}
+}
+
+// notifyStateChange makes subscriptions check the state now rather than
+// at the next poll. It does not block.
+func (c *Client) notifyStateChange() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notifyStateChangeLocked()
+}
+
+// notifyStateChangeLocked is notifyStateChange for callers holding c.mu.
+func (c *Client) notifyStateChangeLocked() {
+	if c.events != nil {
+		c.events.notify()
+	}
+}
+
+// notify wakes the watch loop.
+func (e *stateEvents) notify() {
+	select {
+	case e.kick <- struct{}{}:
+	default:
+	}
+}
+
+// startLocked starts the watch loop if it is not running (caller must
+// hold e.mu).
+func (e *stateEvents) startLocked() {
+	if e.running {
+		return
+	}
+	e.running = true
+	go e.watch(e.client.State(), e.client.Health())
+}
+
+// watch reports changes from the given state and health until there are
+// no subscribers left or the client is closed.
+func (e *stateEvents) watch(state runspace.State, health HealthStatus) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.kick:
+		}
+
+		newState, newHealth := e.client.State(), e.client.Health()
+
+		e.mu.Lock()
+		var stateFns []func(old, new runspace.State)
+		var healthFns []func(old, new HealthStatus)
+		if newState != state {
+			for _, fn := range e.onState {
+				stateFns = append(stateFns, fn)
+			}
+		}
+		if newHealth != health {
+			for _, fn := range e.onHealth {
+				healthFns = append(healthFns, fn)
+			}
+		}
+		e.mu.Unlock()
+
+		for _, fn := range stateFns {
+			fn(state, newState)
+		}
+		for _, fn := range healthFns {
+			fn(health, newHealth)
+		}
+		state, health = newState, newHealth
+
+		e.client.mu.Lock()
+		closed := e.client.closed
+		e.client.mu.Unlock()
+
+		e.mu.Lock()
+		if closed || len(e.onState)+len(e.onHealth) == 0 {
+			e.running = false
+			e.mu.Unlock()
+			return
+		}
+		e.mu.Unlock()
+	}
+}
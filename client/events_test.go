@@ -0,0 +1,52 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrpcore/runspace"
+)
+
+func TestClient_OnHealthChange(t *testing.T) {
+	c := &Client{reconnectMgr: &reconnectManager{}}
+	type change struct{ old, new HealthStatus }
+	changes := make(chan change, 4)
+	remove := c.OnHealthChange(func(old, new HealthStatus) { changes <- change{old, new} })
+	stateChanges := 0
+	removeState := c.OnStateChange(func(_, _ runspace.State) { stateChanges++ })
+	defer removeState()
+
+	c.reconnectMgr.reconnecting.Store(true)
+	c.notifyStateChange()
+	select {
+	case got := <-changes:
+		if got != (change{HealthUnknown, HealthReconnecting}) {
+			t.Errorf("change = %v, want Unknown -> Reconnecting", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no health change reported")
+	}
+
+	remove()
+	c.reconnectMgr.reconnecting.Store(false)
+	c.notifyStateChange()
+	select {
+	case got := <-changes:
+		t.Errorf("change %v reported after remove", got)
+	case <-time.After(2 * statePollInterval):
+	}
+	if stateChanges != 0 {
+		t.Errorf("state changes = %d, want none", stateChanges)
+	}
+}
+
+func TestHealth_Reconnecting(t *testing.T) {
+	c := &Client{reconnectMgr: &reconnectManager{}}
+	if got := c.Health(); got != HealthUnknown {
+		t.Errorf("Health() = %v, want Unknown", got)
+	}
+	c.reconnectMgr.reconnecting.Store(true)
+	if got := c.Health(); got != HealthReconnecting {
+		t.Errorf("Health() = %v, want Reconnecting", got)
+	}
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExecOptions configures a single command execution.
+// The zero value executes the script unchanged, like Execute.
+type ExecOptions struct {
+	// MergeErrorToOutput merges the error stream into the output stream on the
+	// server (2>&1), so error records are delivered in Result.Output in the
+	// order they were written. Result.Errors stays empty for merged records.
+	MergeErrorToOutput bool
+
+	// SuppressVerbose disables the Verbose stream: $VerbosePreference is set to
+	// SilentlyContinue on the server and any records that still arrive are dropped.
+	SuppressVerbose bool
+
+	// SuppressDebug disables the Debug stream ($DebugPreference = SilentlyContinue).
+	SuppressDebug bool
+
+	// SuppressProgress disables the Progress stream ($ProgressPreference = SilentlyContinue).
+	SuppressProgress bool
+
+	// OutputWidth formats the output as text on the server, one line per
+	// string in Result.Output, wrapped at OutputWidth columns (Out-String
+	// -Stream -Width), with trailing blanks trimmed. OutputWidthUnlimited
+	// does not wrap. 0 returns the output objects unchanged.
+	OutputWidth int
+
+	// ReportExitCode fills in Result.ExitCode ($LASTEXITCODE) and
+	// Result.LastCommandSucceeded ($?). PowerShell only keeps them in the
+	// session, so the script is wrapped to write them at its end: it runs
+	// dot-sourced after $global:LASTEXITCODE is reset, using statements at
+	// its start are moved ahead of the wrapper, and it cannot begin with a
+	// param block. ExecuteStreamWithOptions ignores it.
+	ReportExitCode bool
+
+	// Cacheable marks the script as a read-only query whose result may be
+	// served from and stored in Config.ResultCache. Ignored if no cache is configured.
+	Cacheable bool
+
+	// Stdout and Stderr receive the streams as text while the command runs,
+	// so output can go to a file or terminal without waiting for the whole
+	// Result. Stdout gets the output, one line per object, and information
+	// messages (Write-Host); Stderr gets errors, the terminating error and
+	// warning, verbose and debug messages with a "WARNING: " style prefix.
+	// Objects are written in their string form; with OutputWidth they are
+	// the lines PowerShell formats for a console. The Result is still
+	// returned in full. A failed write cancels the command, and once
+	// something was written the command is not retried. Either may be nil;
+	// ExecuteStreamWithOptions ignores both.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// sinks writes to Stdout and Stderr; set by execute for all attempts
+	sinks *execSinks
+}
+
+// OutputWidthUnlimited is the ExecOptions.OutputWidth that does not wrap
+// text output.
+const OutputWidthUnlimited = -1
+
+// unlimitedOutputWidth is the width used for OutputWidthUnlimited. Tables
+// without fixed column widths spread over the whole width, so it is not
+// math.MaxInt32, which would also have the server build huge lines.
+const unlimitedOutputWidth = 4096
+
+// buildScript applies the options to the user script.
+func (o ExecOptions) buildScript(script string) string {
+	if o.MergeErrorToOutput {
+		// Dot-source so the script runs in the current scope, exactly as it
+		// would without the redirection.
+		script = fmt.Sprintf(". {\n%s\n} 2>&1", script)
+	}
+	if o.OutputWidth != 0 {
+		width := o.OutputWidth
+		if width < 0 {
+			width = unlimitedOutputWidth
+		}
+		script = fmt.Sprintf(". {\n%s\n} | Out-String -Stream -Width %d | ForEach-Object { $_.TrimEnd() }", script, width)
+	}
+
+	// Preferences are set ahead of the script so they apply to everything it calls
+	var prefs []string
+	if o.SuppressVerbose {
+		prefs = append(prefs, "$VerbosePreference = 'SilentlyContinue'")
+	}
+	if o.SuppressDebug {
+		prefs = append(prefs, "$DebugPreference = 'SilentlyContinue'")
+	}
+	if o.SuppressProgress {
+		prefs = append(prefs, "$ProgressPreference = 'SilentlyContinue'")
+	}
+	if len(prefs) > 0 {
+		script = strings.Join(prefs, "; ") + "\n" + script
+	}
+	return script
+}
+
+// ExecuteWithOptions runs a PowerShell script on the remote server using the
+// given execution options. See Execute for retry and error semantics.
+func (c *Client) ExecuteWithOptions(ctx context.Context, script string, opts ExecOptions) (*Result, error) {
+	cache := c.config.ResultCache
+	if !opts.Cacheable || cache == nil {
+		return c.execute(ctx, opts.buildScript(script), opts)
+	}
+
+	// Results are cached under the script as written, so Invalidate finds them
+	options := opts.cacheOptions()
+	if result, ok := cache.get(c.cacheScope(), script, options); ok {
+		c.logf("Execute: serving cached result for '%s'", sanitizeScriptForLogging(script))
+		if sinks := newExecSinks(opts); sinks != nil {
+			if err := sinks.writeResult(result); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	result, err := c.execute(ctx, opts.buildScript(script), opts)
+	if err == nil && !result.HadErrors {
+		cache.put(c.cacheScope(), script, options, result)
+	}
+	return result, err
+}
+
+// cacheOptions returns the options that change the result of a script, for
+// the cache key.
+func (o ExecOptions) cacheOptions() string {
+	return fmt.Sprintf("merge=%t verbose=%t debug=%t progress=%t width=%d exitcode=%t",
+		o.MergeErrorToOutput, !o.SuppressVerbose, !o.SuppressDebug, !o.SuppressProgress,
+		o.OutputWidth, o.ReportExitCode)
+}
+
+// cacheScope returns the session the client's results are cached for.
+func (c *Client) cacheScope() cacheScope {
+	user := c.config.Username
+	if c.config.Domain != "" {
+		user = c.config.Domain + "\\" + user
+	}
+	return cacheScope{
+		host:              c.hostname,
+		port:              c.config.Port,
+		user:              user,
+		configurationName: c.config.ConfigurationName,
+	}
+}
+
+// ExecuteStreamWithOptions is like ExecuteStream but applies the given execution options.
+// Suppressed streams are disabled on the server; their channels are still
+// present on the StreamResult and must be drained as usual.
+func (c *Client) ExecuteStreamWithOptions(ctx context.Context, script string, opts ExecOptions) (*StreamResult, error) {
+	return c.ExecuteStream(ctx, opts.buildScript(script))
+}
@@ -0,0 +1,57 @@
+package client
+
+import "testing"
+
+func TestExecOptions_BuildScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   ExecOptions
+		script string
+		want   string
+	}{
+		{
+			name:   "ZeroValue",
+			opts:   ExecOptions{},
+			script: "Get-Item C:\\missing",
+			want:   "Get-Item C:\\missing",
+		},
+		{
+			name:   "MergeErrorToOutput",
+			opts:   ExecOptions{MergeErrorToOutput: true},
+			script: "Write-Error 'boom'; 'ok'",
+			want:   ". {\nWrite-Error 'boom'; 'ok'\n} 2>&1",
+		},
+		{
+			name:   "SuppressStreams",
+			opts:   ExecOptions{SuppressVerbose: true, SuppressProgress: true},
+			script: "Get-Service",
+			want:   "$VerbosePreference = 'SilentlyContinue'; $ProgressPreference = 'SilentlyContinue'\nGet-Service",
+		},
+		{
+			name:   "SuppressDebugWithMerge",
+			opts:   ExecOptions{SuppressDebug: true, MergeErrorToOutput: true},
+			script: "Get-Service",
+			want:   "$DebugPreference = 'SilentlyContinue'\n. {\nGet-Service\n} 2>&1",
+		},
+		{
+			name:   "OutputWidth",
+			opts:   ExecOptions{OutputWidth: 200},
+			script: "Get-Service",
+			want:   ". {\nGet-Service\n} | Out-String -Stream -Width 200 | ForEach-Object { $_.TrimEnd() }",
+		},
+		{
+			name:   "OutputWidthUnlimitedWithMerge",
+			opts:   ExecOptions{OutputWidth: OutputWidthUnlimited, MergeErrorToOutput: true},
+			script: "Get-Service",
+			want:   ". {\n. {\nGet-Service\n} 2>&1\n} | Out-String -Stream -Width 4096 | ForEach-Object { $_.TrimEnd() }",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.buildScript(tt.script); got != tt.want {
+				t.Errorf("buildScript() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+const (
+	clixmlHeader = `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04">`
+	clixmlFooter = `</Objs>`
+)
+
+var (
+	// objsPattern matches the Objs wrapper of a serialized object.
+	objsPattern = regexp.MustCompile(`^\s*(?:<\?xml[^>]*\?>\s*)?<Objs\b[^>]*>|</Objs>\s*$`)
+	// objRefPattern matches the object reference IDs of a CLIXML fragment.
+	objRefPattern = regexp.MustCompile(`(<(?:Obj|Ref)\b[^>]*?\bRefId=")(\d+)"`)
+	// typeRefPattern matches the type name reference IDs.
+	typeRefPattern = regexp.MustCompile(`(<(?:TN|TNRef)\b[^>]*?\bRefId=")(\d+)"`)
+)
+
+// WriteCLIXML writes the output objects to w as CLIXML, the format of
+// Export-Clixml. Import-Clixml reads them back as deserialized objects:
+//
+//	f, _ := os.Create("services.xml")
+//	defer f.Close()
+//	err := result.WriteCLIXML(f)
+//
+//	# PowerShell
+//	$services = Import-Clixml services.xml
+func (r *Result) WriteCLIXML(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(clixmlHeader)
+
+	// Each object is serialized on its own; their reference IDs are
+	// renumbered so that they stay unique in the file
+	var objBase, typeBase int
+	for i, obj := range r.Output {
+		data, err := serialization.NewSerializer().Serialize(exportValue(obj, nil))
+		if err != nil {
+			return fmt.Errorf("client: serialize Output[%d]: %w", i, err)
+		}
+		fragment := objsPattern.ReplaceAllString(string(data), "")
+		var objNext, typeNext int
+		fragment, objNext = renumberRefIDs(objRefPattern, fragment, objBase)
+		fragment, typeNext = renumberRefIDs(typeRefPattern, fragment, typeBase)
+		objBase, typeBase = objNext, typeNext
+		b.WriteString(fragment)
+	}
+
+	b.WriteString(clixmlFooter)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// renumberRefIDs adds base to the reference IDs matched by pattern and
+// returns the next free ID.
+func renumberRefIDs(pattern *regexp.Regexp, fragment string, base int) (string, int) {
+	next := base
+	out := pattern.ReplaceAllStringFunc(fragment, func(m string) string {
+		sub := pattern.FindStringSubmatch(m)
+		id, _ := strconv.Atoi(sub[2])
+		next = max(next, base+id+1)
+		return sub[1] + strconv.Itoa(base+id) + `"`
+	})
+	return out, next
+}
+
+// WriteJSON writes the output objects to w as an indented JSON array,
+// like ConvertTo-Json. Objects become JSON objects of their properties,
+// enums their numeric value and dates RFC 3339 strings.
+func (r *Result) WriteJSON(w io.Writer) error {
+	values := make([]interface{}, len(r.Output))
+	for i, obj := range r.Output {
+		values[i] = JSONValue(obj)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(values); err != nil {
+		return fmt.Errorf("client: encode output: %w", err)
+	}
+	return nil
+}
+
+// JSONValue returns an object of a Result as WriteJSON encodes it, for
+// encoding/json or other encoders: objects become maps of their
+// properties, enums their numeric value and dates RFC 3339 strings.
+func JSONValue(v interface{}) interface{} {
+	return jsonValue(exportValue(v, nil), nil)
+}
+
+// exportValue returns v with DateTime values converted back to the
+// time.Time the serializer understands. Objects are copied, not modified.
+// visiting guards against reference cycles.
+func exportValue(v interface{}, visiting map[*serialization.PSObject]bool) interface{} {
+	switch val := v.(type) {
+	case DateTime:
+		return val.Original()
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = exportValue(item, visiting)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = exportValue(item, visiting)
+		}
+		return out
+	case *serialization.PSObject:
+		if val == nil || visiting[val] {
+			return val
+		}
+		if visiting == nil {
+			visiting = make(map[*serialization.PSObject]bool)
+		}
+		visiting[val] = true
+		defer delete(visiting, val)
+
+		cp := *val
+		cp.Value = exportValue(val.Value, visiting)
+		if val.Properties != nil {
+			props, _ := exportValue(val.Properties, visiting).(map[string]interface{})
+			cp.Properties = props
+		}
+		return &cp
+	default:
+		return v
+	}
+}
+
+// jsonValue converts a deserialized value into the shape ConvertTo-Json
+// produces. An object already on the path (a cycle) becomes its string
+// form.
+func jsonValue(v interface{}, visiting map[*serialization.PSObject]bool) interface{} {
+	switch val := v.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = jsonValue(item, visiting)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = jsonValue(item, visiting)
+		}
+		return out
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case *serialization.PSObject:
+		if val == nil {
+			return nil
+		}
+		if visiting[val] {
+			return val.ToString
+		}
+		if val.Value != nil {
+			return jsonValue(val.Value, visiting)
+		}
+		if len(val.Properties) == 0 {
+			return val.ToString
+		}
+		if visiting == nil {
+			visiting = make(map[*serialization.PSObject]bool)
+		}
+		visiting[val] = true
+		defer delete(visiting, val)
+		return jsonValue(val.Properties, visiting)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+func TestResult_WriteJSON(t *testing.T) {
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	result := &Result{Output: []interface{}{
+		&serialization.PSObject{
+			ToString: "WinRM",
+			Properties: map[string]interface{}{
+				"Name":    "WinRM",
+				"Status":  enumObject("Running", 4),
+				"Started": DateTime{Time: started},
+				"Deps":    []interface{}{&serialization.PSObject{Properties: map[string]interface{}{"Name": "HTTP"}}},
+			},
+		},
+		"text",
+	}}
+
+	var buf bytes.Buffer
+	if err := result.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	var got []interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	obj, ok := got[0].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Output[0] = %T, want object", got[0])
+	}
+	if obj["Name"] != "WinRM" || obj["Status"] != float64(4) || obj["Started"] != "2026-01-02T03:04:05Z" {
+		t.Errorf("Output[0] = %v", obj)
+	}
+	if deps, _ := obj["Deps"].([]interface{}); len(deps) != 1 || deps[0].(map[string]interface{})["Name"] != "HTTP" {
+		t.Errorf("Deps = %v", obj["Deps"])
+	}
+	if got[1] != "text" {
+		t.Errorf("Output[1] = %v, want text", got[1])
+	}
+}
+
+func TestResult_WriteJSON_Cycle(t *testing.T) {
+	obj := &serialization.PSObject{ToString: "self", Properties: map[string]interface{}{}}
+	obj.Properties["Self"] = obj
+
+	var buf bytes.Buffer
+	if err := (&Result{Output: []interface{}{obj}}).WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), `"Self": "self"`) {
+		t.Errorf("cycle not replaced by its string form:\n%s", buf.String())
+	}
+}
+
+func TestJSONValue(t *testing.T) {
+	obj := &serialization.PSObject{
+		ToString:   "WinRM",
+		Properties: map[string]interface{}{"Name": "WinRM", "Status": enumObject("Running", 4)},
+	}
+	got, ok := JSONValue(obj).(map[string]interface{})
+	if !ok || got["Name"] != "WinRM" || got["Status"] != int32(4) {
+		t.Errorf("JSONValue(object) = %#v", JSONValue(obj))
+	}
+	if got := JSONValue("text"); got != "text" {
+		t.Errorf("JSONValue(string) = %#v", got)
+	}
+}
+
+func TestResult_WriteCLIXML(t *testing.T) {
+	result := &Result{Output: []interface{}{
+		&serialization.PSObject{Properties: map[string]interface{}{"Name": "a"}},
+		&serialization.PSObject{Properties: map[string]interface{}{"Name": "b"}},
+		int32(42),
+	}}
+
+	var buf bytes.Buffer
+	if err := result.WriteCLIXML(&buf); err != nil {
+		t.Fatalf("WriteCLIXML() error = %v", err)
+	}
+	xml := buf.String()
+	if !strings.HasPrefix(xml, clixmlHeader) || strings.Count(xml, "<Objs") != 1 {
+		t.Fatalf("not a single Objs document:\n%s", xml)
+	}
+
+	values, err := serialization.NewDeserializer().Deserialize(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Deserialize() error = %v", err)
+	}
+	if len(values) != 3 {
+		t.Fatalf("got %d objects, want 3", len(values))
+	}
+	var names []string
+	for _, v := range values[:2] {
+		names = append(names, v.(*serialization.PSObject).Properties["Name"].(string))
+	}
+	if strings.Join(names, ",") != "a,b" || values[2] != int32(42) {
+		t.Errorf("values = %v", values)
+	}
+}
+
+func TestRenumberRefIDs(t *testing.T) {
+	in := `<Obj RefId="0"><TN RefId="0"><T>X</T></TN><MS><Ref N="p" RefId="0" /><Obj N="q" RefId="1"><TNRef RefId="0" /></Obj></MS></Obj>`
+
+	out, next := renumberRefIDs(objRefPattern, in, 5)
+	if next != 7 {
+		t.Errorf("next object ID = %d, want 7", next)
+	}
+	out, nextType := renumberRefIDs(typeRefPattern, out, 2)
+	if nextType != 3 {
+		t.Errorf("next type ID = %d, want 3", nextType)
+	}
+	want := `<Obj RefId="5"><TN RefId="2"><T>X</T></TN><MS><Ref N="p" RefId="5" /><Obj N="q" RefId="6"><TNRef RefId="2" /></Obj></MS></Obj>`
+	if out != want {
+		t.Errorf("renumbered =\n%s\nwant\n%s", out, want)
+	}
+}
@@ -5,18 +5,20 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
-	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/smnsjas/go-psrp/psquote"
+	"github.com/smnsjas/go-psrp/wsman"
+	"github.com/smnsjas/go-psrp/wsman/transport"
 	"github.com/smnsjas/go-psrpcore/serialization"
-	"golang.org/x/sync/errgroup"
 )
 
 // Buffer pool for chunk allocation (zero-copy optimization)
@@ -56,7 +58,8 @@ type FileTransferOptions struct {
 	// ChunkTimeout is the timeout for each individual chunk operation.
 	// Default: 60 seconds. If a single chunk takes longer than this, the
 	// transfer fails. There is no overall transfer timeout - as long as
-	// chunks keep completing within ChunkTimeout, the transfer continues.
+	// chunks keep completing within ChunkTimeout, the transfer continues -
+	// unless the context has a deadline or WithOperationTimeout is set.
 	ChunkTimeout time.Duration
 
 	// MaxFileSize limits the total file size in bytes to prevent resource exhaustion.
@@ -66,6 +69,52 @@ type FileTransferOptions struct {
 	// NoOverwrite prevents overwriting an existing destination file.
 	// If true, the transfer fails if the file exists.
 	NoOverwrite bool
+
+	// AutoTune picks ChunkSize (and MaxConcurrency for WSMan uploads) by
+	// timing the first chunks of the transfer, instead of using the static
+	// per-transport defaults. See WithAutoTune.
+	AutoTune bool
+
+	// AutoTuneThreshold is the smallest file size that is auto-tuned.
+	// Default: 16MB.
+	AutoTuneThreshold int64
+
+	// Include limits CopyDirectory and FetchDirectory to files matching at
+	// least one glob pattern. See WithInclude.
+	Include []string
+
+	// Exclude skips files and directories matching any glob pattern in
+	// directory transfers. See WithExclude.
+	Exclude []string
+
+	// PreserveTimestamps copies each file's modification time in directory
+	// transfers.
+	PreserveTimestamps bool
+
+	// FileConcurrency is the number of files a directory transfer moves at
+	// once. Default: 4.
+	FileConcurrency int
+
+	// SkipUnchanged skips files in directory transfers whose destination
+	// already matches. Default: SkipNone.
+	SkipUnchanged SkipMode
+
+	// VerifyManifest makes FetchDirectory verify downloaded files against a
+	// manifest of the remote files. See WithManifestVerification.
+	VerifyManifest bool
+
+	// WhatIf makes CopyFile check the transfer and log its plan without
+	// writing anything. See WithWhatIf and PlanCopyFile.
+	WhatIf bool
+
+	// PreflightThreshold is the upload size from which CopyFile and
+	// CopyDirectory check remote free space and write permission before
+	// sending data. Default: 64MB; negative disables the check.
+	PreflightThreshold int64
+
+	// BITS makes CopyFile have the remote host pull large files with BITS,
+	// falling back to the PSRP transfer. See WithBITSOffload.
+	BITS *BITSOffload
 }
 
 // FileTransferOption is a functional option for configuring file transfers.
@@ -107,6 +156,19 @@ func WithMaxFileSize(bytes int64) FileTransferOption {
 	return func(o *FileTransferOptions) { o.MaxFileSize = bytes }
 }
 
+// maxFileSize returns the effective MaxFileSize: 1GB by default, 0 if the
+// limit is disabled with a negative value.
+func (o FileTransferOptions) maxFileSize() int64 {
+	switch {
+	case o.MaxFileSize > 0:
+		return o.MaxFileSize
+	case o.MaxFileSize < 0:
+		return 0 // Disabled
+	default:
+		return 1024 * 1024 * 1024 // Default 1GB safety limit
+	}
+}
+
 // WithChunkTimeout sets the timeout for each individual chunk operation.
 // Default: 60 seconds. As long as chunks complete within this timeout,
 // the transfer will continue indefinitely.
@@ -207,8 +269,7 @@ func validatePaths(localPath, remotePath string) error {
 
 // sanitizeForPowerShell escapes single quotes in strings for PowerShell script safety.
 func sanitizeForPowerShell(s string) string {
-	// In PowerShell single-quoted strings, single quotes are escaped by doubling them
-	return strings.ReplaceAll(s, "'", "''")
+	return psquote.Escape(s)
 }
 
 // generateInitScript creates the PowerShell script to initialize the destination file.
@@ -259,15 +320,41 @@ func generatePreallocateScript(remotePath string, size int64) string {
 // CopyFile uploads a local file to the remote host.
 // Files are transferred in chunks using Base64 encoding over PowerShell remoting.
 // For large files, consider enabling compression or adjusting the chunk size.
-func (c *Client) CopyFile(ctx context.Context, localPath, remotePath string, opts ...FileTransferOption) error {
+func (c *Client) CopyFile(ctx context.Context, localPath, remotePath string, opts ...FileTransferOption) (err error) {
+	ctx, cancelOp := operationContext(ctx)
+	defer cancelOp()
+
 	// Apply transport-aware defaults and user options
 	opt := DefaultFileTransferOptionsForTransport(c.config.Transport)
 	for _, fn := range opts {
 		fn(&opt)
 	}
+	if opt.WhatIf {
+		plan, err := c.PlanCopyFile(ctx, localPath, remotePath, opts...)
+		if err != nil {
+			return err
+		}
+		c.logInfo("CopyFile: What if: %s", plan)
+		c.logSecurityEvent("FILE_TRANSFER_WHATIF", map[string]interface{}{
+			"operation":   "CopyFile",
+			"source":      localPath,
+			"destination": remotePath,
+			"size_bytes":  plan.Size,
+			"problems":    plan.Problems,
+		})
+		if !plan.OK() {
+			return fmt.Errorf("%w: %s", ErrTransferBlocked, strings.Join(plan.Problems, "; "))
+		}
+		return nil
+	}
+
+	ctx, endSpan := c.tel.startTransfer(ctx, "CopyFile", remotePath)
+	defer func() { endSpan(c.stats.recordTransfer(localPath, true, err), err) }()
+
 	// HvSocket: 1MB chunks (no envelope limit)
-	if c.IsHvSocket() && opt.MaxConcurrency > 1 {
-		c.logInfo("CopyFile: HvSocket does not support parallel upload; forcing MaxConcurrency=1")
+	caps := c.capabilities()
+	if !caps.ParallelPipelines && opt.MaxConcurrency > 1 {
+		c.logInfo("CopyFile: transport does not support parallel upload; forcing MaxConcurrency=1")
 		opt.MaxConcurrency = 1
 	}
 
@@ -297,20 +384,26 @@ func (c *Client) CopyFile(ctx context.Context, localPath, remotePath string, opt
 	totalSize := stat.Size()
 
 	// Security: Enforce MaxFileSize limit (Resource Exhaustion protection)
-	maxSize := opt.MaxFileSize
-	if maxSize == 0 {
-		maxSize = 1024 * 1024 * 1024 // Default 1GB safety limit
+	if maxSize := opt.maxFileSize(); maxSize > 0 && totalSize > maxSize {
+		return fmt.Errorf("file too large: %d bytes (max allowed: %d)", totalSize, maxSize)
 	}
 
-	// Allow explicitly disabling limit with negative value, but default to safe 1GB
-	if opt.MaxFileSize > 0 {
-		maxSize = opt.MaxFileSize
-	} else if opt.MaxFileSize < 0 {
-		maxSize = 0 // Disabled
+	// Fail fast if a large upload cannot fit or be written
+	if err := c.preflightUpload(ctx, remotePath, totalSize, opt); err != nil {
+		return err
 	}
 
-	if maxSize > 0 && totalSize > maxSize {
-		return fmt.Errorf("file too large: %d bytes (max allowed: %d)", totalSize, maxSize)
+	// Offload huge files to BITS, falling back to the PSRP transfer
+	if opt.BITS.shouldOffload(totalSize) {
+		bitsProgress := &transferProgress{totalBytes: totalSize, progressCallback: opt.ProgressCallback}
+		err := c.copyFileBITS(ctx, localPath, remotePath, opt, totalSize, bitsProgress)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		c.logWarn("CopyFile: BITS offload failed, falling back to PSRP transfer: %v", err)
 	}
 
 	// Initialize progress tracking
@@ -333,19 +426,20 @@ func (c *Client) CopyFile(ctx context.Context, localPath, remotePath string, opt
 		"size_bytes":  totalSize,
 		"chunk_count": numChunks,
 		"parallel":    opt.MaxConcurrency > 1 && numChunks > 1,
+		"auto_tune":   opt.shouldAutoTune(totalSize),
 	})
 
 	// Determine optimization strategy
 	// If concurrency > 1 and file size > chunk size, use parallel upload
 	// Determine strategy
-	if c.IsHvSocket() {
+	if !caps.ParallelPipelines {
 		// FORCE SAFE PARALLELISM (2 Workers)
 		// 4 Workers caused crashes. 2 is stable and fast.
 		if opt.MaxConcurrency < 2 {
 			opt.MaxConcurrency = 2
 		}
 
-		c.logInfo("DEBUG: IsHvSocket=true, Concurrency=%d", opt.MaxConcurrency)
+		c.logInfo("DEBUG: ParallelPipelines=false, Concurrency=%d", opt.MaxConcurrency)
 
 		if opt.MaxConcurrency > 1 {
 			c.logInfo("CopyFile: Using Parallel Streaming for HvSocket (concurrency: %d)", opt.MaxConcurrency)
@@ -368,7 +462,8 @@ func (c *Client) CopyFile(ctx context.Context, localPath, remotePath string, opt
 		return nil
 	}
 
-	if opt.MaxConcurrency > 1 && numChunks > 1 {
+	// Auto-tuning also probes concurrency, which needs the parallel path.
+	if (opt.MaxConcurrency > 1 || opt.shouldAutoTune(totalSize)) && numChunks > 1 {
 		if err := c.copyFileParallel(ctx, file, remotePath, opt, totalSize, progress); err != nil {
 			return err
 		}
@@ -384,7 +479,6 @@ func (c *Client) CopyFile(ctx context.Context, localPath, remotePath string, opt
 	return nil
 }
 
-
 // copyFileStreaming uploads a file using a single streaming pipeline.
 // This is more efficient than chunked uploads as it avoids per-chunk overhead (pipeline creation).
 // It streams file chunks as pipeline input to a script that writes them to the destination.
@@ -496,7 +590,7 @@ func (c *Client) copyFileStreaming(ctx context.Context, file *os.File, remotePat
 			// 2ms Yield-Wait failed at 58%. 10ms was stable but slow.
 			// We settle on 5ms Yield-Wait. This provides enough drain time for stability
 			// while offering ~2.5x the throughput of the 10ms baseline.
-			if c.IsHvSocket() {
+			if !c.capabilities().ParallelPipelines {
 				start := time.Now()
 				target := 10 * time.Millisecond // Increased to 10ms for safety
 				for time.Since(start) < target {
@@ -652,13 +746,69 @@ func (c *Client) copyFileParallel(ctx context.Context, file *os.File, remotePath
 		return fmt.Errorf("initialization failed: remote operation error")
 	}
 
+	chunkTimeout := opt.ChunkTimeout
+	if chunkTimeout == 0 {
+		chunkTimeout = 60 * time.Second
+	}
+
+	// Result map for checksum, keyed by offset
+	type chunkResult struct {
+		index int64
+		data  []byte
+	}
+	chunkResults := make(map[int64]chunkResult)
+	var resultsMu sync.Mutex
+	recordChunk := func(offset int64, data []byte) {
+		if progress != nil {
+			progress.update(int64(len(data)))
+		}
+		if opt.VerifyChecksum {
+			// Make a copy of the chunk data because buffer is reused
+			dataCopy := make([]byte, len(data))
+			copy(dataCopy, data)
+
+			resultsMu.Lock()
+			chunkResults[offset] = chunkResult{index: offset, data: dataCopy}
+			resultsMu.Unlock()
+		}
+	}
+
+	// Optional: probe chunk sizes and concurrency with the first chunks of
+	// the file, then upload the rest with the fastest settings.
+	startOffset := int64(0)
+	if opt.shouldAutoTune(totalSize) {
+		tuner := &transferTuner{
+			clock: realClock{},
+			transfer: func(ctx context.Context, offset int64, size int) (int, error) {
+				data, err := c.uploadChunkAt(ctx, c, file, make([]byte, size), remotePath, offset, chunkTimeout)
+				if err != nil {
+					return 0, err
+				}
+				recordChunk(offset, data)
+				return len(data), nil
+			},
+		}
+		tuned, err := tuner.tune(ctx, totalSize, autoTuneChunkSizes(c.config.Transport), autoTuneConcurrency)
+		if err != nil {
+			return err
+		}
+		c.logInfo("CopyFile: Auto-tuned chunk size %d, concurrency %d (%.0f bytes/s)", tuned.ChunkSize, tuned.Concurrency, tuned.Throughput)
+		chunkSize = int64(tuned.ChunkSize)
+		opt.MaxConcurrency = tuned.Concurrency
+		startOffset = tuned.Offset
+		numChunks = (totalSize - startOffset + chunkSize - 1) / chunkSize
+	}
+
 	// Step 2: Upload chunks in parallel using a worker pool
 	// We use a fixed number of workers to prevent connection storms and excessive auth.
-	// Each worker maintains its own cloned client (and thus its own Authenticated Transport).
+	// Each worker is a client of its own (and thus has its own Authenticated Transport).
 	concurrency := opt.MaxConcurrency
 	if concurrency > int(numChunks) {
 		concurrency = int(numChunks)
 	}
+	if concurrency < 1 {
+		return nil
+	}
 
 	// Job channel
 	type chunkJob struct {
@@ -667,129 +817,42 @@ func (c *Client) copyFileParallel(ctx context.Context, file *os.File, remotePath
 	}
 	jobCh := make(chan chunkJob, numChunks)
 	for i := int64(0); i < numChunks; i++ {
-		jobCh <- chunkJob{index: i, offset: i * chunkSize}
+		jobCh <- chunkJob{index: i, offset: startOffset + i*chunkSize}
 	}
 	close(jobCh)
 
-	// Result map for checksum
-	type chunkResult struct {
-		index int64
-		data  []byte
+	workers, err := c.Workers(ctx, concurrency, nil)
+	if err != nil {
+		return err
 	}
-	chunkResults := make(map[int64]chunkResult)
-	var resultsMu sync.Mutex
-
-	g, ctx := errgroup.WithContext(ctx)
-
-	// Semaphore to limit concurrency (re-added for shared client)
-	sem := make(chan struct{}, opt.MaxConcurrency)
+	defer workers.Close(context.Background())
 
-	for w := 0; w < concurrency; w++ {
-		// Capture worker ID for logging/debugging
-		workerID := w
+	err = workers.Run(ctx, func(ctx context.Context, w *Worker) error {
+		// Reusable buffer for this worker
+		buf := make([]byte, chunkSize)
 
-		g.Go(func() error {
-			// Acquire semaphore to limit concurrency
-			select {
-			case sem <- struct{}{}:
-				defer func() { <-sem }()
-			case <-ctx.Done():
+		for job := range jobCh {
+			// Check cancellation
+			if ctx.Err() != nil {
 				return ctx.Err()
 			}
 
-			// Reusable buffer for this worker
-			buf := make([]byte, chunkSize)
-
-			// Create a worker for this goroutine
-			// This ensures each worker gets its own Authentication Context to avoid race conditions.
-			workerClient, err := c.CreateWorker()
+			chunkData, err := c.uploadChunkAt(ctx, w, file, buf, remotePath, job.offset, chunkTimeout)
 			if err != nil {
-				return fmt.Errorf("create worker %d: %w", workerID, err)
-			}
-			if err := workerClient.Connect(ctx); err != nil {
-				if err := workerClient.Close(context.Background()); err != nil {
-					slog.Warn("Failed to close worker client", "error", err)
-				}
-				return fmt.Errorf("connect worker %d: %w", workerID, err)
+				return fmt.Errorf("chunk %d (worker %d): %w", job.index, w.ID, err)
 			}
-			defer workerClient.Close(context.Background())
+			recordChunk(job.offset, chunkData)
 
-			for job := range jobCh {
-				// Check cancellation
-				if ctx.Err() != nil {
-					return ctx.Err()
-				}
-
-				// Read chunk
-				// Use ReadAt on the shared file handle (thread-safe on *os.File)
-				n, err := file.ReadAt(buf, job.offset)
-				if err != nil && err != io.EOF {
-					return fmt.Errorf("read chunk %d: %w", job.index, err)
-				}
-				if n == 0 {
-					continue
-				}
-				chunkData := buf[:n]
-
-				// Encode chunk
-				chunkB64 := base64.StdEncoding.EncodeToString(chunkData)
-
-				// Validate Base64 size
-				if len(chunkB64) > maxChunkBase64Size {
-					return fmt.Errorf("chunk %d too large after encoding: %d bytes (limit: %d)", job.index, len(chunkB64), maxChunkBase64Size)
-				}
-
-				// Write chunk at specific offset
-				script := generateOffsetWriteScript(remotePath, job.offset, chunkB64)
-
-				// Use per-chunk timeout - each chunk gets its own deadline
-				chunkTimeout := opt.ChunkTimeout
-				if chunkTimeout == 0 {
-					chunkTimeout = 60 * time.Second
-				}
-				chunkCtx, chunkCancel := context.WithTimeout(ctx, chunkTimeout)
-
-				// Execute using dedicated worker client
-				_, execErr := workerClient.Execute(chunkCtx, script)
-				chunkCancel()
-
-				if execErr != nil {
-					c.logSecurityEvent("FILE_TRANSFER_FAILED", map[string]interface{}{
-						"operation": "CopyFile",
-						"phase":     "upload_chunk",
-						"chunk":     job.index,
-						"error":     execErr.Error(),
-					})
-					return fmt.Errorf("failed to upload chunk %d (worker %d): %w", job.index, workerID, execErr)
-				}
-
-				// Store for later hash computation (in order)
-				if opt.VerifyChecksum {
-					// Make a copy of the chunk data because buffer is reused
-					dataCopy := make([]byte, len(chunkData))
-					copy(dataCopy, chunkData)
-
-					resultsMu.Lock()
-					chunkResults[job.index] = chunkResult{index: job.index, data: dataCopy}
-					resultsMu.Unlock()
-				}
-
-				// Update progress
-				if progress != nil {
-					progress.update(int64(n))
-				}
-
-				// Log progress (but not too often to avoid spam)
-				if (job.index+1)%10 == 0 || job.index == numChunks-1 {
-					c.logInfo("CopyFile: Uploaded chunk %d/%d", job.index+1, numChunks)
-				}
+			// Log progress (but not too often to avoid spam)
+			if (job.index+1)%10 == 0 || job.index == numChunks-1 {
+				c.logInfo("CopyFile: Uploaded chunk %d/%d", job.index+1, numChunks)
 			}
-			return nil
-		})
-	}
+		}
+		return nil
+	})
 
 	// Wait for all chunks to complete
-	if err := g.Wait(); err != nil {
+	if err != nil {
 		return err
 	}
 	// ... (rest of function omitted for brevity, logic continues below) ...
@@ -800,6 +863,52 @@ func (c *Client) copyFileParallel(ctx context.Context, file *os.File, remotePath
 	return nil
 }
 
+// chunkExecutor runs chunk scripts; both Client and its workers implement it.
+type chunkExecutor interface {
+	Execute(ctx context.Context, script string) (*Result, error)
+}
+
+// uploadChunkAt reads up to len(buf) bytes at offset and writes them to the
+// same offset of remotePath through exec. The returned data aliases buf.
+func (c *Client) uploadChunkAt(ctx context.Context, exec chunkExecutor, file *os.File, buf []byte, remotePath string, offset int64, chunkTimeout time.Duration) ([]byte, error) {
+	// Use ReadAt on the shared file handle (thread-safe on *os.File)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read chunk at offset %d: %w", offset, err)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	chunkData := buf[:n]
+
+	// Encode chunk
+	chunkB64 := base64.StdEncoding.EncodeToString(chunkData)
+
+	// Validate Base64 size
+	if len(chunkB64) > maxChunkBase64Size {
+		return nil, fmt.Errorf("chunk at offset %d too large after encoding: %d bytes (limit: %d)", offset, len(chunkB64), maxChunkBase64Size)
+	}
+
+	// Write chunk at specific offset, with a per-chunk deadline
+	script := generateOffsetWriteScript(remotePath, offset, chunkB64)
+	chunkCtx, chunkCancel := context.WithTimeout(ctx, chunkTimeout)
+	defer chunkCancel()
+
+	if _, execErr := exec.Execute(chunkCtx, script); execErr != nil {
+		c.logSecurityEvent("FILE_TRANSFER_FAILED", map[string]interface{}{
+			"operation": "CopyFile",
+			"phase":     "upload_chunk",
+			"offset":    offset,
+			"error":     execErr.Error(),
+		})
+		if errors.Is(execErr, wsman.ErrEnvelopeTooLarge) {
+			return nil, fmt.Errorf("chunk at offset %d: %d bytes do not fit the server's envelope size, lower the chunk size: %w", offset, n, execErr)
+		}
+		return nil, fmt.Errorf("failed to upload chunk at offset %d: %w", offset, execErr)
+	}
+	return chunkData, nil
+}
+
 // copyFileParallelHvSocket implements separated high-performance streaming for HvSocket.
 // It splits the file into N large segments and streams them concurrently using long-lived
 // pipelines (Runspaces), avoiding the overhead of per-chunk scripts while maximizing
@@ -824,7 +933,7 @@ func (c *Client) copyFileParallelHvSocket(ctx context.Context, file *os.File, re
 	// Fix: Capacity (65KB) = 1 Chunk + Epsilon.
 	// Rate: 4 MB/s.
 	// Result: Forces "Send 1 Chunk -> Wait" cycle. Rock stable.
-	globalLimiter := newTokenBucket(4*1024*1024, 65*1024)
+	globalLimiter := transport.NewTokenBucket(4*1024*1024, 65*1024)
 
 	// 2. Pre-allocate remote file (using FileShare.ReadWrite to allow concurrent writes)
 	// We use Create to overwrite/create, but Close immediately.
@@ -843,153 +952,125 @@ func (c *Client) copyFileParallelHvSocket(ctx context.Context, file *os.File, re
 	}
 
 	// 3. Launch Workers
-	g, ctx := errgroup.WithContext(ctx)
-
-	for i := 0; i < concurrency; i++ {
-		// Stagger worker startup to avoid auth storm (Token Auth Failure)
-		if i > 0 {
-			time.Sleep(500 * time.Millisecond)
-		}
+	// Connects are staggered and retried to avoid an auth storm (Token Auth Failure).
+	workers, err := c.Workers(ctx, concurrency, &WorkersConfig{
+		ConnectStagger:  500 * time.Millisecond,
+		ConnectAttempts: 3,
+	})
+	if err != nil {
+		return err
+	}
+	defer workers.Close(context.Background())
 
-		workerIndex := i
-		startOffset := int64(i) * segmentSize
+	err = workers.Run(ctx, func(ctx context.Context, w *Worker) error {
+		workerIndex := w.ID
+		startOffset := int64(workerIndex) * segmentSize
 		endOffset := startOffset + segmentSize
-		if i == concurrency-1 {
+		if workerIndex == concurrency-1 {
 			endOffset = totalSize // Last worker takes the rest
 		}
 		length := endOffset - startOffset
+		workerClient := w.Client
 
-		g.Go(func() error {
-			// Create dedicated worker client (new Runspace)
-			workerClient, err := c.CreateWorker()
-			if err != nil {
-				return fmt.Errorf("worker %d create failed: %w", workerIndex, err)
+		// Worker Script: Open Shared, Seek, Write from Input
+		// Note: FileShare.ReadWrite is critical here.
+		script := fmt.Sprintf(`
+			$ErrorActionPreference = 'Stop'
+			$path = [System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String('%s'))
+			$fs = [System.IO.File]::Open($path, [System.IO.FileMode]::Open, [System.IO.FileAccess]::Write, [System.IO.FileShare]::ReadWrite)
+			$fs.Seek(%d, [System.IO.SeekOrigin]::Begin) | Out-Null
+			try {
+				$input | ForEach-Object {
+					$fs.Write($_, 0, $_.Length)
+				}
+			} finally {
+				$fs.Close()
 			}
+		`, remotePathB64, startOffset)
 
-			// Retry Loop for Connection (Auth Storm Protection)
-			var connectErr error
-			for attempt := 1; attempt <= 3; attempt++ {
-				if attempt > 1 {
-					c.logInfo("Worker %d: Retrying connection (attempt %d/3)...", workerIndex, attempt)
-					time.Sleep(time.Duration(attempt) * time.Second)
-				}
+		// Start Stream
+		sr, err := workerClient.ExecuteStreamWithInput(ctx, script)
+		if err != nil {
+			return fmt.Errorf("worker %d start stream failed: %w", workerIndex, err)
+		}
 
-				if err := workerClient.Connect(ctx); err != nil {
-					connectErr = err
-					// Check for specific auth errors if possible, but retry generally helps
-					continue
-				}
-				connectErr = nil
-				break
+		// Output drainer (prevent blocking)
+		go func() {
+			for range sr.Output {
 			}
-
-			if connectErr != nil {
-				if err := workerClient.Close(context.Background()); err != nil {
-					slog.Warn("Failed to close worker client", "error", err)
-				}
-				return fmt.Errorf("worker %d connect failed after 3 attempts: %w", workerIndex, connectErr)
+			for range sr.Errors {
 			}
-			defer workerClient.Close(context.Background())
-
-			// Worker Script: Open Shared, Seek, Write from Input
-			// Note: FileShare.ReadWrite is critical here.
-			script := fmt.Sprintf(`
-				$ErrorActionPreference = 'Stop'
-				$path = [System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String('%s'))
-				$fs = [System.IO.File]::Open($path, [System.IO.FileMode]::Open, [System.IO.FileAccess]::Write, [System.IO.FileShare]::ReadWrite)
-				$fs.Seek(%d, [System.IO.SeekOrigin]::Begin) | Out-Null
-				try {
-					$input | ForEach-Object {
-						$fs.Write($_, 0, $_.Length)
-					}
-				} finally {
-					$fs.Close()
-				}
-			`, remotePathB64, startOffset)
-
-			// Start Stream
-			sr, err := workerClient.ExecuteStreamWithInput(ctx, script)
-			if err != nil {
-				return fmt.Errorf("worker %d start stream failed: %w", workerIndex, err)
+			for range sr.Verbose {
+			}
+			for range sr.Debug {
+			}
+			for range sr.Progress {
 			}
+			for range sr.Information {
+			}
+		}()
 
-			// Output drainer (prevent blocking)
-			go func() {
-				for range sr.Output {
-				}
-				for range sr.Errors {
+		// Send Loop
+		chunkSize := int64(64 * 1024) // 64KB chunks for efficiency
+		numChunks := (length + chunkSize - 1) / chunkSize
+		buf := make([]byte, chunkSize)
+
+		errSend := func() error {
+			defer sr.CloseInput(ctx)
+
+			for k := int64(0); k < numChunks; k++ {
+				if ctx.Err() != nil {
+					return ctx.Err()
 				}
-				for range sr.Verbose {
+
+				// Throttle BEFORE reading/sending
+				if err := globalLimiter.WaitN(ctx, int(chunkSize)); err != nil {
+					return err
 				}
-				for range sr.Debug {
+
+				// ReadAt is thread-safe on *os.File
+				readOffset := startOffset + (k * chunkSize)
+				// Calculate read size (clamp to length)
+				toRead := chunkSize
+				remaining := length - (k * chunkSize)
+				if toRead > remaining {
+					toRead = remaining
 				}
-				for range sr.Progress {
+
+				n, err := file.ReadAt(buf[:toRead], readOffset)
+				if err != nil && err != io.EOF {
+					return fmt.Errorf("worker %d read failed: %w", workerIndex, err)
 				}
-				for range sr.Information {
+				if n == 0 {
+					break
 				}
-			}()
-
-			// Send Loop
-			chunkSize := int64(64 * 1024) // 64KB chunks for efficiency
-			numChunks := (length + chunkSize - 1) / chunkSize
-			buf := make([]byte, chunkSize)
-
-			errSend := func() error {
-				defer sr.CloseInput(ctx)
-
-				for k := int64(0); k < numChunks; k++ {
-					if ctx.Err() != nil {
-						return ctx.Err()
-					}
-
-					// Throttle BEFORE reading/sending
-					globalLimiter.Wait(int(chunkSize))
-
-					// ReadAt is thread-safe on *os.File
-					readOffset := startOffset + (k * chunkSize)
-					// Calculate read size (clamp to length)
-					toRead := chunkSize
-					remaining := length - (k * chunkSize)
-					if toRead > remaining {
-						toRead = remaining
-					}
-
-					n, err := file.ReadAt(buf[:toRead], readOffset)
-					if err != nil && err != io.EOF {
-						return fmt.Errorf("worker %d read failed: %w", workerIndex, err)
-					}
-					if n == 0 {
-						break
-					}
-
-					// Send Input
-					if err := sr.SendInput(ctx, buf[:n]); err != nil {
-						return fmt.Errorf("worker %d send failed: %w", workerIndex, err)
-					}
-
-					// Throttling handled by globalLimiter.Wait() at top of loop.
-
-					if progress != nil {
-						progress.update(int64(n))
-					}
+
+				// Send Input
+				if err := sr.SendInput(ctx, buf[:n]); err != nil {
+					return fmt.Errorf("worker %d send failed: %w", workerIndex, err)
 				}
-				return nil
-			}()
 
-			if errSend != nil {
-				sr.Cancel()
-				return errSend
-			}
+				// Throttling handled by globalLimiter.WaitN() at top of loop.
 
-			// Wait for script completion
-			if err := sr.Wait(); err != nil {
-				return fmt.Errorf("worker %d script error: %w", workerIndex, err)
+				if progress != nil {
+					progress.update(int64(n))
+				}
 			}
 			return nil
-		})
-	}
+		}()
 
-	if err := g.Wait(); err != nil {
+		if errSend != nil {
+			sr.Cancel()
+			return errSend
+		}
+
+		// Wait for script completion
+		if err := sr.Wait(); err != nil {
+			return fmt.Errorf("worker %d script error: %w", workerIndex, err)
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
@@ -1032,13 +1113,19 @@ func (c *Client) copyFileParallelHvSocket(ctx context.Context, file *os.File, re
 // FetchFile downloads a remote file to the local host.
 // Files are transferred in chunks using Base64 encoding over PowerShell remoting.
 // For large files, consider enabling compression or adjusting the chunk size.
-func (c *Client) FetchFile(ctx context.Context, remotePath, localPath string, opts ...FileTransferOption) error {
+func (c *Client) FetchFile(ctx context.Context, remotePath, localPath string, opts ...FileTransferOption) (err error) {
+	ctx, cancelOp := operationContext(ctx)
+	defer cancelOp()
+
 	// Apply transport-aware defaults and user options
 	opt := DefaultFileTransferOptionsForTransport(c.config.Transport)
 	for _, fn := range opts {
 		fn(&opt)
 	}
 
+	ctx, endSpan := c.tel.startTransfer(ctx, "FetchFile", remotePath)
+	defer func() { endSpan(c.stats.recordTransfer(localPath, false, err), err) }()
+
 	// Validate paths
 	if err := validatePaths(localPath, remotePath); err != nil {
 		return fmt.Errorf("path validation failed: %w", err)
@@ -1107,17 +1194,7 @@ func (c *Client) FetchFile(ctx context.Context, remotePath, localPath string, op
 	}
 
 	// Security: Enforce MaxFileSize limit
-	maxSize := opt.MaxFileSize
-	if maxSize == 0 {
-		maxSize = 1024 * 1024 * 1024 // Default 1GB
-	}
-	if opt.MaxFileSize > 0 {
-		maxSize = opt.MaxFileSize
-	} else if opt.MaxFileSize < 0 {
-		maxSize = 0 // Disabled
-	}
-
-	if maxSize > 0 && totalSize > maxSize {
+	if maxSize := opt.maxFileSize(); maxSize > 0 && totalSize > maxSize {
 		return fmt.Errorf("remote file too large: %d bytes (max allowed: %d)", totalSize, maxSize)
 	}
 
@@ -1156,22 +1233,17 @@ func (c *Client) FetchFile(ctx context.Context, remotePath, localPath string, op
 		hasher = sha256.New()
 	}
 
-	c.logInfo("FetchFile: Downloading %d chunks (%d bytes)", numChunks, totalSize)
-
-	// Step 2: Download chunks sequentially
-	for i := int64(0); i < numChunks; i++ {
+	// fetchChunk downloads up to size bytes at offset and appends them to
+	// the local file. Chunks must be fetched in order.
+	fetchChunk := func(ctx context.Context, offset int64, size int) (int, error) {
 		// Check for context cancellation
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("transfer cancelled: %w", ctx.Err())
+			return 0, fmt.Errorf("transfer cancelled: %w", ctx.Err())
 		default:
 		}
 
-		offset := i * chunkSize
-		length := chunkSize
-		if offset+length > totalSize {
-			length = totalSize - offset
-		}
+		length := min(int64(size), totalSize-offset)
 
 		// Read chunk from remote as Base64
 		readScript := fmt.Sprintf(`
@@ -1198,10 +1270,10 @@ func (c *Client) FetchFile(ctx context.Context, remotePath, localPath string, op
 			c.logSecurityEvent("FILE_TRANSFER_FAILED", map[string]interface{}{
 				"operation": "FetchFile",
 				"phase":     "download_chunk",
-				"chunk":     i,
+				"offset":    offset,
 				"error":     chunkErr.Error(),
 			})
-			return fmt.Errorf("failed to download chunk %d/%d: remote operation error", i+1, numChunks)
+			return 0, fmt.Errorf("failed to download chunk at offset %d: remote operation error", offset)
 		}
 
 		// Extract Base64 string from output
@@ -1217,18 +1289,18 @@ func (c *Client) FetchFile(ctx context.Context, remotePath, localPath string, op
 		}
 
 		if b64Data == "" {
-			return fmt.Errorf("chunk %d returned empty data", i)
+			return 0, fmt.Errorf("chunk at offset %d returned empty data", offset)
 		}
 
 		// Decode Base64
 		chunkData, decodeErr := base64.StdEncoding.DecodeString(b64Data)
 		if decodeErr != nil {
-			return fmt.Errorf("failed to decode chunk %d: %w", i, decodeErr)
+			return 0, fmt.Errorf("failed to decode chunk at offset %d: %w", offset, decodeErr)
 		}
 
 		// Write to local file
 		if _, writeErr := file.Write(chunkData); writeErr != nil {
-			return fmt.Errorf("failed to write chunk %d: %w", i, writeErr)
+			return 0, fmt.Errorf("failed to write chunk at offset %d: %w", offset, writeErr)
 		}
 
 		// Update hash if verification is enabled
@@ -1240,6 +1312,33 @@ func (c *Client) FetchFile(ctx context.Context, remotePath, localPath string, op
 		if progress != nil {
 			progress.update(int64(len(chunkData)))
 		}
+		return len(chunkData), nil
+	}
+
+	// Optional: probe chunk sizes with the first chunks of the file.
+	// Downloads are sequential, so only the chunk size is tuned.
+	offset := int64(0)
+	if opt.shouldAutoTune(totalSize) {
+		tuner := &transferTuner{clock: realClock{}, transfer: fetchChunk}
+		tuned, tuneErr := tuner.tune(ctx, totalSize, autoTuneChunkSizes(c.config.Transport), nil)
+		if tuneErr != nil {
+			return tuneErr
+		}
+		c.logInfo("FetchFile: Auto-tuned chunk size %d (%.0f bytes/s)", tuned.ChunkSize, tuned.Throughput)
+		chunkSize = int64(tuned.ChunkSize)
+		offset = tuned.Offset
+		numChunks = (totalSize - offset + chunkSize - 1) / chunkSize
+	}
+
+	c.logInfo("FetchFile: Downloading %d chunks (%d bytes)", numChunks, totalSize-offset)
+
+	// Step 2: Download chunks sequentially
+	for i := int64(0); i < numChunks; i++ {
+		n, fetchErr := fetchChunk(ctx, offset, int(chunkSize))
+		if fetchErr != nil {
+			return fmt.Errorf("chunk %d/%d: %w", i+1, numChunks, fetchErr)
+		}
+		offset += int64(n)
 
 		// Log progress every 10 chunks
 		if (i+1)%10 == 0 || i == numChunks-1 {
@@ -1311,55 +1410,3 @@ func (c *Client) FetchFile(ctx context.Context, remotePath, localPath string, op
 	c.logInfo("FetchFile: Transfer complete (%d bytes)", totalSize)
 	return nil
 }
-
-// Custom safe rate limiter for HvSocket
-// Simple token bucket to avoid external dependencies.
-type tokenBucket struct {
-	rate       float64 // bytes per second
-	capacity   float64 // max burst bytes
-	tokens     float64
-	lastRefill time.Time
-	mu         sync.Mutex
-}
-
-func newTokenBucket(rate float64, capacity float64) *tokenBucket {
-	return &tokenBucket{
-		rate:       rate,
-		capacity:   capacity,
-		tokens:     0, // Start EMPTY (Strict Pacing / Slow Start)
-		lastRefill: time.Now(),
-	}
-}
-
-// Wait blocks until n bytes can be consumed
-func (tb *tokenBucket) Wait(n int) {
-	needed := float64(n)
-	tb.mu.Lock()
-	defer tb.mu.Unlock()
-
-	for {
-		now := time.Now()
-		elapsed := now.Sub(tb.lastRefill).Seconds()
-		tb.tokens += elapsed * tb.rate
-		if tb.tokens > tb.capacity {
-			tb.tokens = tb.capacity
-		}
-		tb.lastRefill = now
-
-		if tb.tokens >= needed {
-			tb.tokens -= needed
-			return
-		}
-
-		// Not enough tokens, sleep until we have enough
-		missing := needed - tb.tokens
-		waitSec := missing / tb.rate
-		wait := time.Duration(waitSec * float64(time.Second))
-		if wait < time.Millisecond {
-			wait = time.Millisecond
-		}
-		tb.mu.Unlock()
-		time.Sleep(wait)
-		tb.mu.Lock()
-	}
-}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrpcore/messages"
+)
+
+// priorityWriter is implemented by pipeline transports that can send host
+// responses (powershell.WSManTransport).
+type priorityWriter interface {
+	WritePriority(p []byte) (int, error)
+}
+
+// handleHostCall answers a PIPELINE_HOST_CALL with Config.Host and sends the
+// response back on the pipeline's priority stream. Methods without a result
+// (e.g., Write-Host) get no response.
+func (c *Client) handleHostCall(ctx context.Context, transport io.Reader, msg *messages.Message) error {
+	c.mu.Lock()
+	host := c.config.Host
+	c.mu.Unlock()
+	if host == nil {
+		return errors.New("server sent a host call but no host is configured")
+	}
+
+	call, err := powershell.DecodeHostCall(msg.Data)
+	if err != nil {
+		return err
+	}
+	c.logf("Host call %d: %s", call.CallID, call.Method)
+
+	response := powershell.HandleHostCall(ctx, host, call)
+	if response == nil {
+		return nil
+	}
+
+	writer, ok := transport.(priorityWriter)
+	if !ok {
+		return fmt.Errorf("transport %T cannot send host responses", transport)
+	}
+	data, err := powershell.EncodeHostResponse(msg, response)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.WritePriority(data); err != nil {
+		return fmt.Errorf("send host response: %w", err)
+	}
+	return nil
+}
+
+// hostInfo returns the console reported to the server with Host.
+func (c *Config) hostInfo() powershell.HostInfo {
+	if c.HostInfo != nil {
+		return *c.HostInfo
+	}
+	return powershell.DefaultHostInfo
+}
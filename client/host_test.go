@@ -0,0 +1,91 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrpcore/fragments"
+	"github.com/smnsjas/go-psrpcore/messages"
+)
+
+// scriptedHost answers every ReadLine with a fixed line.
+type scriptedHost struct {
+	line string
+}
+
+func (h *scriptedHost) ReadLine(_ context.Context) (string, error) { return h.line, nil }
+
+func (h *scriptedHost) Write(_ context.Context, _ powershell.HostOutput, _ string) {}
+
+func (h *scriptedHost) Prompt(_ context.Context, _, _ string, _ []powershell.FieldDescription) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (h *scriptedHost) PromptForChoice(_ context.Context, _, _ string, _ []powershell.ChoiceDescription, defaultChoice int) (int, error) {
+	return defaultChoice, nil
+}
+
+// priorityRecorder is a pipeline transport that records host responses.
+type priorityRecorder struct {
+	bytes.Buffer
+	priority bytes.Buffer
+}
+
+func (r *priorityRecorder) WritePriority(p []byte) (int, error) {
+	return r.priority.Write(p)
+}
+
+const readLineHostCall = `<Obj RefId="0"><MS><I64 N="ci">-100</I64>` +
+	`<Obj N="mi" RefId="1"><TN RefId="0"><T>System.Management.Automation.Remoting.RemoteHostMethodId</T>` +
+	`<T>System.Enum</T><T>System.ValueType</T><T>System.Object</T></TN><ToString>ReadLine</ToString><I32>11</I32></Obj>` +
+	`<Obj N="mp" RefId="2"><TN RefId="1"><T>System.Collections.ArrayList</T><T>System.Object</T></TN><LST /></Obj>` +
+	`</MS></Obj>`
+
+func TestClient_HandleHostCall(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Host = &scriptedHost{line: "yes"}
+	c := &Client{config: cfg}
+
+	call := &messages.Message{
+		Destination: messages.DestinationClient,
+		Type:        powershell.MessageTypePipelineHostCall,
+		RunspaceID:  uuid.New(),
+		PipelineID:  uuid.New(),
+		Data:        []byte(readLineHostCall),
+	}
+	rec := &priorityRecorder{}
+	if err := c.handleHostCall(context.Background(), rec, call); err != nil {
+		t.Fatalf("handleHostCall() error = %v", err)
+	}
+
+	frag, err := fragments.Decode(rec.priority.Bytes())
+	if err != nil {
+		t.Fatalf("decode fragment: %v", err)
+	}
+	resp, err := messages.Decode(frag.Data)
+	if err != nil {
+		t.Fatalf("decode message: %v", err)
+	}
+	if resp.Type != powershell.MessageTypePipelineHostResponse {
+		t.Errorf("response type = %#x, want PIPELINE_HOST_RESPONSE", resp.Type)
+	}
+	if resp.PipelineID != call.PipelineID {
+		t.Errorf("response pipeline = %s, want %s", resp.PipelineID, call.PipelineID)
+	}
+	data := string(resp.Data)
+	if !strings.Contains(data, `<I64 N="ci">-100</I64>`) || !strings.Contains(data, `<S N="mr">yes</S>`) {
+		t.Errorf("response data = %s", data)
+	}
+}
+
+func TestClient_HandleHostCall_NoHost(t *testing.T) {
+	c := &Client{config: DefaultConfig()}
+	call := &messages.Message{Type: powershell.MessageTypePipelineHostCall, Data: []byte(readLineHostCall)}
+	if err := c.handleHostCall(context.Background(), &priorityRecorder{}, call); err == nil {
+		t.Error("handleHostCall() without host error = nil")
+	}
+}
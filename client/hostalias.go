@@ -0,0 +1,33 @@
+package client
+
+import (
+	"net/url"
+	"strings"
+)
+
+// hostAlias returns the name the target is known by for Kerberos and TLS:
+// its HostAliases entry, or "" if it has none. hostname may be a bare host
+// or an endpoint URL.
+func (c *Config) hostAlias(hostname string) string {
+	if len(c.HostAliases) == 0 {
+		return ""
+	}
+	host := hostname
+	if strings.Contains(hostname, "://") {
+		if u, err := url.Parse(hostname); err == nil {
+			host = u.Hostname()
+		}
+	}
+	host = strings.Trim(host, "[]")
+
+	if alias, ok := c.HostAliases[host]; ok {
+		return alias
+	}
+	// Host names are case-insensitive
+	for from, alias := range c.HostAliases {
+		if strings.EqualFold(strings.Trim(from, "[]"), host) {
+			return alias
+		}
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+package client
+
+import "testing"
+
+func TestConfig_HostAlias(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.HostAliases = map[string]string{
+		"10.0.0.5":  "web01.corp.com",
+		"fd00::5":   "web02.corp.com",
+		"DR-WEB01":  "web01.dr.corp.com",
+		"127.0.0.1": "localhost.corp.com",
+	}
+
+	tests := []struct {
+		hostname string
+		want     string
+	}{
+		{"10.0.0.5", "web01.corp.com"},
+		{"https://10.0.0.5:5986/wsman", "web01.corp.com"},
+		{"[fd00::5]", "web02.corp.com"},
+		{"http://[fd00::5]:5985/wsman", "web02.corp.com"},
+		{"dr-web01", "web01.dr.corp.com"},
+		{"10.0.0.6", ""},
+		{"web01.corp.com", ""},
+	}
+	for _, tt := range tests {
+		if got := cfg.hostAlias(tt.hostname); got != tt.want {
+			t.Errorf("hostAlias(%q) = %q, want %q", tt.hostname, got, tt.want)
+		}
+	}
+
+	if got := cfg.spnHost("10.0.0.5"); got != "web01.corp.com" {
+		t.Errorf("spnHost(10.0.0.5) = %q", got)
+	}
+	if got := cfg.spnHost("10.0.0.6"); got != "10.0.0.6" {
+		t.Errorf("spnHost(10.0.0.6) = %q, want the hostname", got)
+	}
+}
@@ -0,0 +1,380 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned when using a Pool after Close.
+var ErrPoolClosed = errors.New("client: pool is closed")
+
+// PoolConfig configures a Pool.
+type PoolConfig struct {
+	// MaxPerHost caps the commands running at once on one host; further
+	// commands for that host wait. Default: base Config.MaxRunspaces, or 1.
+	MaxPerHost int
+
+	// MaxConcurrentHosts caps the hosts ExecuteAll works on at once.
+	// Default: 0 (all hosts at once).
+	MaxConcurrentHosts int
+
+	// HealthCheckInterval is how often idle connections are checked. Broken
+	// connections are closed, so the next command for the host reconnects.
+	// Default: 0 (connections are only checked when they are used).
+	HealthCheckInterval time.Duration
+
+	// IdleTimeout closes connections that were not used for this long.
+	// Default: 0 (connections stay open until Close or Evict).
+	IdleTimeout time.Duration
+}
+
+// HostResult is the outcome of a command on one host of a fan-out.
+type HostResult struct {
+	Host     string
+	Result   *Result
+	Err      error // connection or execution error; script errors are in Result
+	Duration time.Duration
+}
+
+// Pool manages connections to many hosts that share one configuration, for
+// inventory and configuration-management work:
+//
+//	pool := client.NewPool(cfg, &client.PoolConfig{MaxConcurrentHosts: 20})
+//	defer pool.Close(ctx)
+//	for _, r := range pool.ExecuteAll(ctx, hosts, "Get-HotFix | Select-Object -Last 1") {
+//		if r.Err != nil {
+//			log.Printf("%s: %v", r.Host, r.Err)
+//		}
+//	}
+//
+// Each host gets its own Client, created and connected on first use and
+// reused afterwards. Connections that fail to connect, or are found broken,
+// are dropped, so the next command for the host tries again. A Pool is safe
+// for concurrent use.
+type Pool struct {
+	base  Config
+	cfg   PoolConfig
+	clock Clock
+
+	// dial creates and connects a client. Overridable for tests.
+	dial func(ctx context.Context, host string, cfg Config) (*Client, error)
+	// exec runs a script on a client. Overridable for tests.
+	exec func(ctx context.Context, c *Client, script string) (*Result, error)
+
+	mu     sync.Mutex
+	closed bool
+	hosts  map[string]*hostEntry
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+type hostEntry struct {
+	key   string
+	host  string
+	ready chan struct{} // closed once client/err are set
+	slots chan struct{} // one per running command
+	inUse int
+	stale bool // removed from the pool; closed once no longer in use
+	used  time.Time
+
+	client *Client
+	err    error
+}
+
+// NewPool creates a pool of clients configured with base. cfg may be nil.
+func NewPool(base Config, cfg *PoolConfig) *Pool {
+	p := &Pool{
+		base:  base,
+		clock: realClock{},
+		hosts: make(map[string]*hostEntry),
+		dial: func(ctx context.Context, host string, cfg Config) (*Client, error) {
+			c, err := New(host, cfg)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.Connect(ctx); err != nil {
+				_ = c.Close(context.Background())
+				return nil, err
+			}
+			return c, nil
+		},
+		exec: func(ctx context.Context, c *Client, script string) (*Result, error) {
+			return c.Execute(ctx, script)
+		},
+	}
+	if cfg != nil {
+		p.cfg = *cfg
+	}
+	if p.cfg.MaxPerHost <= 0 {
+		p.cfg.MaxPerHost = max(base.MaxRunspaces, 1)
+	}
+
+	interval := p.cfg.HealthCheckInterval
+	if interval <= 0 && p.cfg.IdleTimeout > 0 {
+		interval = p.cfg.IdleTimeout / 2
+	}
+	if interval > 0 {
+		p.stop = make(chan struct{})
+		p.done = make(chan struct{})
+		go p.maintainLoop(interval)
+	}
+	return p
+}
+
+// hostKey normalizes a host name; host names are case-insensitive.
+func hostKey(host string) string {
+	return strings.ToLower(strings.TrimSpace(host))
+}
+
+// Execute runs a script on host.
+func (p *Pool) Execute(ctx context.Context, host, script string) (*Result, error) {
+	var result *Result
+	err := p.Do(ctx, host, func(c *Client) error {
+		var err error
+		result, err = p.exec(ctx, c, script)
+		return err
+	})
+	return result, err
+}
+
+// ExecuteAll runs a script on every host at once (up to MaxConcurrentHosts)
+// and returns one HostResult per host, in the order of hosts. A failing host
+// does not stop the others.
+func (p *Pool) ExecuteAll(ctx context.Context, hosts []string, script string) []HostResult {
+	results := make([]HostResult, len(hosts))
+	limit := p.cfg.MaxConcurrentHosts
+	if limit <= 0 || limit > len(hosts) {
+		limit = len(hosts)
+	}
+	sem := make(chan struct{}, max(limit, 1))
+
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].Host = host
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+			start := time.Now()
+			results[i].Result, results[i].Err = p.Execute(ctx, host, script)
+			results[i].Duration = time.Since(start)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Do calls fn with the client for host, connecting it if needed, once a
+// MaxPerHost slot is free. The client is not closed while fn runs.
+// fn must not retain the client after it returns.
+func (p *Pool) Do(ctx context.Context, host string, fn func(c *Client) error) error {
+	if hostKey(host) == "" {
+		return errors.New("client: pool host is required")
+	}
+
+	entry, err := p.acquire(ctx, host)
+	if err != nil {
+		return err
+	}
+	defer p.release(entry)
+
+	select {
+	case entry.slots <- struct{}{}:
+		defer func() { <-entry.slots }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return fn(entry.client)
+}
+
+// acquire returns a connected, healthy entry for host, marking it in use.
+func (p *Pool) acquire(ctx context.Context, host string) (*hostEntry, error) {
+	key := hostKey(host)
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		entry, ok := p.hosts[key]
+		if !ok {
+			entry = &hostEntry{
+				key:   key,
+				host:  host,
+				ready: make(chan struct{}),
+				slots: make(chan struct{}, p.cfg.MaxPerHost),
+			}
+			p.hosts[key] = entry
+		}
+		entry.inUse++
+		entry.used = p.clock.Now()
+		p.mu.Unlock()
+
+		if !ok {
+			c, err := p.dial(ctx, host, p.base)
+			p.mu.Lock()
+			entry.client, entry.err = c, err
+			p.mu.Unlock()
+			close(entry.ready)
+		}
+
+		select {
+		case <-entry.ready:
+		case <-ctx.Done():
+			p.release(entry)
+			return nil, ctx.Err()
+		}
+
+		if entry.err != nil {
+			// Failed clients are dropped so the next command retries.
+			p.mu.Lock()
+			p.removeLocked(entry)
+			p.mu.Unlock()
+			p.release(entry)
+			return nil, fmt.Errorf("connect %s: %w", host, entry.err)
+		}
+		if ok && entry.client.Health() == HealthUnhealthy {
+			// Broken since it was connected: replace it
+			p.mu.Lock()
+			p.removeLocked(entry)
+			p.mu.Unlock()
+			p.release(entry)
+			continue
+		}
+		return entry, nil
+	}
+}
+
+// release marks an entry as no longer in use, closing it if it was removed.
+func (p *Pool) release(entry *hostEntry) {
+	p.mu.Lock()
+	entry.inUse--
+	closeIt := entry.stale && entry.inUse == 0 && entry.client != nil
+	p.mu.Unlock()
+
+	if closeIt {
+		_ = entry.client.Close(context.Background())
+	}
+}
+
+// removeLocked removes an entry from the pool. Its client is closed by the
+// last release. p.mu must be held.
+func (p *Pool) removeLocked(entry *hostEntry) {
+	if p.hosts[entry.key] == entry {
+		delete(p.hosts, entry.key)
+	}
+	entry.stale = true
+}
+
+// maintainLoop runs maintain every interval until Close.
+func (p *Pool) maintainLoop(interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.maintain()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// maintain closes idle clients that are broken or past IdleTimeout.
+func (p *Pool) maintain() {
+	var victims []*Client
+
+	p.mu.Lock()
+	now := p.clock.Now()
+	for _, entry := range p.hosts {
+		if entry.inUse > 0 || entry.client == nil {
+			continue
+		}
+		expired := p.cfg.IdleTimeout > 0 && now.Sub(entry.used) > p.cfg.IdleTimeout
+		if expired || entry.client.Health() == HealthUnhealthy {
+			p.removeLocked(entry)
+			victims = append(victims, entry.client)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, c := range victims {
+		_ = c.Close(context.Background())
+	}
+}
+
+// Hosts returns the hosts that have a client in the pool, sorted.
+func (p *Pool) Hosts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	hosts := make([]string, 0, len(p.hosts))
+	for _, entry := range p.hosts {
+		hosts = append(hosts, entry.host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Evict closes and removes the client for host, if present (e.g., after the
+// host was rebooted). Commands currently running on the host fail.
+func (p *Pool) Evict(ctx context.Context, host string) error {
+	p.mu.Lock()
+	entry, ok := p.hosts[hostKey(host)]
+	if ok {
+		p.removeLocked(entry)
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	<-entry.ready
+	if entry.client == nil {
+		return nil
+	}
+	return entry.client.Close(ctx)
+}
+
+// Close closes all clients. The pool cannot be used afterwards.
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	entries := make([]*hostEntry, 0, len(p.hosts))
+	for _, entry := range p.hosts {
+		entries = append(entries, entry)
+	}
+	p.hosts = make(map[string]*hostEntry)
+	p.mu.Unlock()
+
+	if p.stop != nil {
+		close(p.stop)
+		<-p.done
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		<-entry.ready
+		if entry.client != nil {
+			if err := entry.client.Close(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("close %s: %w", entry.host, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestPool returns a pool whose clients are created without connecting
+// and whose scripts "run" by returning the host name.
+func newTestPool(t *testing.T, cfg *PoolConfig) (*Pool, *[]string) {
+	t.Helper()
+	p := NewPool(DefaultConfig(), cfg)
+	t.Cleanup(func() { _ = p.Close(context.Background()) })
+
+	var mu sync.Mutex
+	var dialed []string
+	p.dial = func(_ context.Context, host string, cfg Config) (*Client, error) {
+		mu.Lock()
+		dialed = append(dialed, host)
+		mu.Unlock()
+		if host == "down" {
+			return nil, errors.New("connection refused")
+		}
+		return &Client{hostname: host, config: cfg}, nil
+	}
+	p.exec = func(_ context.Context, c *Client, _ string) (*Result, error) {
+		return &Result{Output: []interface{}{c.hostname}}, nil
+	}
+	return p, &dialed
+}
+
+func TestPool_ExecuteAll(t *testing.T) {
+	p, dialed := newTestPool(t, nil)
+	ctx := context.Background()
+
+	hosts := []string{"web01", "down", "web02"}
+	results := p.ExecuteAll(ctx, hosts, "hostname")
+	if len(results) != len(hosts) {
+		t.Fatalf("got %d results, want %d", len(results), len(hosts))
+	}
+	for i, r := range results {
+		if r.Host != hosts[i] {
+			t.Errorf("results[%d].Host = %q, want %q", i, r.Host, hosts[i])
+		}
+		if r.Host == "down" {
+			if r.Err == nil {
+				t.Error("down host: no error")
+			}
+			continue
+		}
+		if r.Err != nil || r.Result == nil || r.Result.Output[0] != r.Host {
+			t.Errorf("host %s: result = %+v, err = %v", r.Host, r.Result, r.Err)
+		}
+	}
+
+	// Connected hosts are reused; the failed one is dialed again
+	p.ExecuteAll(ctx, hosts, "hostname")
+	if len(*dialed) != 4 {
+		t.Errorf("dialed %v, want web01, down, web02 once and down twice", *dialed)
+	}
+	if got := p.Hosts(); len(got) != 2 || got[0] != "web01" || got[1] != "web02" {
+		t.Errorf("Hosts() = %v", got)
+	}
+}
+
+func TestPool_Limits(t *testing.T) {
+	p, _ := newTestPool(t, &PoolConfig{MaxPerHost: 2, MaxConcurrentHosts: 3})
+
+	var running, peak atomic.Int32
+	var perHostMu sync.Mutex
+	perHost := make(map[string]int)
+	perHostPeak := make(map[string]int)
+	p.exec = func(_ context.Context, c *Client, _ string) (*Result, error) {
+		n := running.Add(1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		perHostMu.Lock()
+		perHost[c.hostname]++
+		perHostPeak[c.hostname] = max(perHostPeak[c.hostname], perHost[c.hostname])
+		perHostMu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		perHostMu.Lock()
+		perHost[c.hostname]--
+		perHostMu.Unlock()
+		running.Add(-1)
+		return &Result{}, nil
+	}
+
+	// ExecuteAll is capped at 3 hosts at once
+	p.ExecuteAll(context.Background(), []string{"a", "b", "c", "d", "e", "f"}, "x")
+	if got := peak.Load(); got > 3 {
+		t.Errorf("ExecuteAll peak concurrency = %d, want <= 3", got)
+	}
+
+	// Commands for one host are capped at MaxPerHost
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = p.Execute(context.Background(), "a", "x")
+		}()
+	}
+	wg.Wait()
+	if got := perHostPeak["a"]; got != 2 {
+		t.Errorf("per-host peak = %d, want 2", got)
+	}
+}
+
+func TestPool_IdleTimeout(t *testing.T) {
+	p, dialed := newTestPool(t, nil)
+	clock := &mockClock{current: time.Now()}
+	p.clock = clock
+	p.cfg.IdleTimeout = time.Minute
+	ctx := context.Background()
+
+	if _, err := p.Execute(ctx, "web01", "x"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	p.maintain()
+	if len(p.Hosts()) != 1 {
+		t.Fatal("client closed before IdleTimeout")
+	}
+
+	clock.Advance(2 * time.Minute)
+	p.maintain()
+	if len(p.Hosts()) != 0 {
+		t.Fatal("idle client not closed after IdleTimeout")
+	}
+	if _, err := p.Execute(ctx, "WEB01", "x"); err != nil {
+		t.Fatalf("Execute() after idle close error = %v", err)
+	}
+	if len(*dialed) != 2 {
+		t.Errorf("dialed %v, want a reconnect after idle close", *dialed)
+	}
+}
+
+func TestPool_Closed(t *testing.T) {
+	p, _ := newTestPool(t, nil)
+	ctx := context.Background()
+	if _, err := p.Execute(ctx, "web01", "x"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if err := p.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := p.Execute(ctx, "web01", "x"); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Execute() after Close error = %v, want ErrPoolClosed", err)
+	}
+	if _, err := p.Execute(ctx, "", "x"); err == nil {
+		t.Error("Execute() with empty host: no error")
+	}
+}
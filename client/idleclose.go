@@ -0,0 +1,212 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrpcore/runspace"
+)
+
+// SessionUsage is the resource usage of a session, reported when it is
+// closed.
+type SessionUsage struct {
+	// PoolID identifies the closed runspace pool.
+	PoolID string
+
+	// Uptime is the time since the session was opened.
+	Uptime time.Duration
+
+	// Commands is the number of pipelines run in the session, including
+	// those of file transfers but not keepalive heartbeats.
+	Commands int64
+
+	// BytesUploaded and BytesDownloaded are the sizes of the files
+	// transferred in the session by CopyFile and FetchFile.
+	BytesUploaded   int64
+	BytesDownloaded int64
+}
+
+// fields returns the usage as security log details.
+func (u SessionUsage) fields() map[string]any {
+	return map[string]any{
+		"pool_id":          u.PoolID,
+		"uptime":           u.Uptime.Round(time.Millisecond).String(),
+		"commands":         u.Commands,
+		"bytes_uploaded":   u.BytesUploaded,
+		"bytes_downloaded": u.BytesDownloaded,
+	}
+}
+
+// sessionState tracks the use of the current session. It is guarded by
+// Client.mu.
+type sessionState struct {
+	start    time.Time
+	lastUsed time.Time
+	inUse    int
+
+	// idleClosed is set when Config.IdleClose closed the session, so the
+	// next command opens a new one.
+	idleClosed bool
+
+	// Counters at the start of the session.
+	commands, uploaded, downloaded int64
+
+	// stopIdle stops the idle monitor.
+	stopIdle chan struct{}
+}
+
+// heartbeatKey marks the context of a keepalive heartbeat, which does not
+// count as use of the session.
+type heartbeatKey struct{}
+
+func isHeartbeat(ctx context.Context) bool {
+	v, _ := ctx.Value(heartbeatKey{}).(bool)
+	return v
+}
+
+// startSessionLocked resets the usage counters for a new session and
+// starts the idle monitor if Config.IdleClose is set (caller must hold
+// c.mu).
+func (c *Client) startSessionLocked() {
+	now := time.Now()
+	stop := c.session.stopIdle
+	c.session = sessionState{
+		start:      now,
+		lastUsed:   now,
+		inUse:      c.session.inUse,
+		commands:   c.stats.commands.Load(),
+		uploaded:   c.stats.bytesUploaded.Load(),
+		downloaded: c.stats.bytesDownloaded.Load(),
+		stopIdle:   stop,
+	}
+	if c.config.IdleClose > 0 && stop == nil {
+		c.session.stopIdle = make(chan struct{})
+		go c.idleLoop(c.config.IdleClose, c.session.stopIdle)
+	}
+}
+
+// sessionUsageLocked returns the usage of the current session (caller
+// must hold c.mu).
+func (c *Client) sessionUsageLocked() SessionUsage {
+	return SessionUsage{
+		PoolID:          c.poolID.String(),
+		Uptime:          time.Since(c.session.start),
+		Commands:        c.stats.commands.Load() - c.session.commands,
+		BytesUploaded:   c.stats.bytesUploaded.Load() - c.session.uploaded,
+		BytesDownloaded: c.stats.bytesDownloaded.Load() - c.session.downloaded,
+	}
+}
+
+// beginUse marks the start of a pipeline. Heartbeats keep the session
+// open while they run but do not reset its idle time.
+func (c *Client) beginUse(ctx context.Context) {
+	heartbeat := isHeartbeat(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session.inUse++
+	if !heartbeat {
+		c.session.lastUsed = time.Now()
+		c.stats.commands.Add(1)
+	}
+}
+
+// endUse marks the end of a pipeline started with beginUse.
+func (c *Client) endUse(ctx context.Context) {
+	heartbeat := isHeartbeat(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session.inUse--
+	if !heartbeat {
+		c.session.lastUsed = time.Now()
+	}
+}
+
+// stopIdleMonitorLocked stops the idle monitor (caller must hold c.mu).
+func (c *Client) stopIdleMonitorLocked() {
+	if c.session.stopIdle != nil {
+		close(c.session.stopIdle)
+		c.session.stopIdle = nil
+	}
+}
+
+// idleLoop closes the session once it has been unused for idle.
+func (c *Client) idleLoop(idle time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(min(max(idle/4, time.Millisecond), 30*time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.closeIfIdle()
+		}
+	}
+}
+
+// closeIfIdle closes the session if it is unused for Config.IdleClose.
+// The client stays open: the next command opens a new session.
+func (c *Client) closeIfIdle() {
+	c.mu.Lock()
+	pool, backend, usage, ok := c.detachIdleSessionLocked(time.Now())
+	keepAlive := c.keepAlive
+	timeout := c.config.Timeout
+	onIdleClose := c.config.OnIdleClose
+	securityLogger := c.securityLogger
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	// Heartbeats of a closed session would only be missed
+	if keepAlive != nil {
+		keepAlive.Stop()
+	}
+
+	c.logInfo("Closing session %s, idle for %v", usage.PoolID, c.config.IdleClose)
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if wsmanBackend, ok := backend.(*powershell.WSManBackend); ok && wsmanBackend.Disconnected() {
+		wsmanBackend.Abandon()
+	}
+	_ = pool.Close(ctx)
+	if err := backend.Close(ctx); err != nil {
+		c.logWarn("Close idle session: %v", err)
+	}
+
+	if securityLogger != nil {
+		details := usage.fields()
+		details["reason"] = "idle"
+		securityLogger.LogSession(SubtypeSessionClosed, OutcomeSuccess, SeverityInfo, details)
+	}
+	c.notifyStateChange()
+	if onIdleClose != nil {
+		onIdleClose(usage)
+	}
+}
+
+// detachIdleSessionLocked detaches the session from the client if no
+// command has used it for Config.IdleClose, and returns it for closing
+// (caller must hold c.mu). A command that starts later finds the client
+// disconnected and opens a new session.
+func (c *Client) detachIdleSessionLocked(now time.Time) (*runspace.Pool, powershell.RunspaceBackend, SessionUsage, bool) {
+	if c.closed || !c.connected || c.psrpPool == nil || c.backend == nil {
+		return nil, nil, SessionUsage{}, false
+	}
+	if c.session.inUse > 0 || now.Sub(c.session.lastUsed) < c.config.IdleClose {
+		return nil, nil, SessionUsage{}, false
+	}
+
+	usage := c.sessionUsageLocked()
+	pool, backend := c.psrpPool, c.backend
+	c.psrpPool = nil
+	c.connected = false
+	c.session.idleClosed = true
+	if c.keepAlive != nil {
+		c.keepAlive.signalStop()
+	}
+	return pool, backend, usage, true
+}
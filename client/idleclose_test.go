@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/runspace"
+)
+
+func newIdleTestClient(idle time.Duration) *Client {
+	c := &Client{
+		config:    Config{IdleClose: idle},
+		connected: true,
+		poolID:    uuid.New(),
+		psrpPool:  runspace.New(nil, uuid.New()),
+		backend:   &MockBackend{},
+	}
+	c.session = sessionState{start: time.Now(), lastUsed: time.Now()}
+	return c
+}
+
+func TestClient_DetachIdleSession(t *testing.T) {
+	c := newIdleTestClient(time.Minute)
+	now := time.Now()
+
+	if _, _, _, ok := c.detachIdleSessionLocked(now); ok {
+		t.Fatal("detached a session used just now")
+	}
+
+	c.beginUse(context.Background())
+	if _, _, _, ok := c.detachIdleSessionLocked(now.Add(time.Hour)); ok {
+		t.Fatal("detached a session with a running command")
+	}
+	c.endUse(context.Background())
+
+	pool, backend, usage, ok := c.detachIdleSessionLocked(time.Now().Add(2 * time.Minute))
+	if !ok {
+		t.Fatal("idle session not detached")
+	}
+	if pool == nil || backend == nil {
+		t.Error("detached session has no pool or backend")
+	}
+	if c.connected || c.psrpPool != nil || !c.session.idleClosed {
+		t.Errorf("client state after detach: connected=%v pool=%v idleClosed=%v",
+			c.connected, c.psrpPool, c.session.idleClosed)
+	}
+	if usage.Commands != 1 || usage.PoolID != c.poolID.String() {
+		t.Errorf("usage = %+v, want 1 command in pool %s", usage, c.poolID)
+	}
+
+	if _, _, _, ok := c.detachIdleSessionLocked(time.Now().Add(time.Hour)); ok {
+		t.Error("detached a session twice")
+	}
+}
+
+func TestClient_HeartbeatIsNotUse(t *testing.T) {
+	c := newIdleTestClient(time.Minute)
+	lastUsed := time.Now().Add(-time.Hour)
+	c.session.lastUsed = lastUsed
+
+	ctx := context.WithValue(context.Background(), heartbeatKey{}, true)
+	c.beginUse(ctx)
+	if c.session.inUse != 1 {
+		t.Errorf("inUse = %d during a heartbeat, want 1", c.session.inUse)
+	}
+	c.endUse(ctx)
+
+	if !c.session.lastUsed.Equal(lastUsed) {
+		t.Error("heartbeat reset the idle time")
+	}
+	if n := c.stats.commands.Load(); n != 0 {
+		t.Errorf("commands = %d, want heartbeats not counted", n)
+	}
+}
+
+func TestClient_SessionUsage(t *testing.T) {
+	c := newIdleTestClient(0)
+	c.stats.commands.Add(3)
+	c.stats.bytesUploaded.Add(100)
+	c.startSessionLocked()
+
+	c.stats.commands.Add(2)
+	c.stats.bytesUploaded.Add(50)
+	c.stats.bytesDownloaded.Add(10)
+
+	usage := c.sessionUsageLocked()
+	if usage.Commands != 2 || usage.BytesUploaded != 50 || usage.BytesDownloaded != 10 {
+		t.Errorf("usage = %+v, want only the current session counted", usage)
+	}
+	if c.session.stopIdle != nil {
+		t.Error("idle monitor started without IdleClose")
+	}
+
+	fields := usage.fields()
+	for _, key := range []string{"pool_id", "uptime", "commands", "bytes_uploaded", "bytes_downloaded"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("fields() missing %q", key)
+		}
+	}
+}
+
+func TestClient_IdleMonitorStops(t *testing.T) {
+	c := newIdleTestClient(time.Hour)
+	c.startSessionLocked()
+	if c.session.stopIdle == nil {
+		t.Fatal("idle monitor not started")
+	}
+	stop := c.session.stopIdle
+
+	// A new session keeps the running monitor
+	c.startSessionLocked()
+	if c.session.stopIdle != stop {
+		t.Error("new session started a second monitor")
+	}
+
+	c.stopIdleMonitorLocked()
+	select {
+	case <-stop:
+	default:
+		t.Error("stop channel not closed")
+	}
+}
+
+// openBreaker returns a circuit breaker that fails every Connect.
+func openBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		enabled:     true,
+		state:       StateOpen,
+		lastFailure: time.Now(),
+		timeout:     time.Hour,
+		clock:       realClock{},
+	}
+}
+
+func TestConnectLazily_AfterIdleClose(t *testing.T) {
+	c := &Client{circuitBreaker: openBreaker()}
+	if err := c.connectLazily(context.Background()); err != nil {
+		t.Fatalf("connectLazily() = %v, want no connect without LazyConnect", err)
+	}
+
+	c.session.idleClosed = true
+	err := c.connectLazily(context.Background())
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("connectLazily() error = %v, want a connect after the idle close", err)
+	}
+}
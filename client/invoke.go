@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrpcore/pipeline"
+	"github.com/smnsjas/go-psrpcore/runspace"
+)
+
+// Invoke runs a command built with powershell.NewCommand, so parameter values
+// never need to be escaped by hand:
+//
+//	cmd := powershell.NewCommand("Get-Service").AddParameter("Name", userInput)
+//	result, err := c.Invoke(ctx, cmd)
+//
+// The commands are sent as a PSRP command pipeline with real parameters, as
+// .NET's PowerShell.AddCommand does, not as script text. Parameter values are
+// converted like pipeline input, using Config.Serialization or
+// DefaultSerializationOptions if it is nil, so Go structs are passed as
+// hashtables. Logging and policies see the command as rendered by
+// Command.Script. Result.ExitCode and LastCommandSucceeded are not reported.
+// See Execute for retry and error semantics.
+func (c *Client) Invoke(ctx context.Context, cmd *powershell.Command) (*Result, error) {
+	script, err := c.commandScript(cmd)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, invokeCommandKey{}, invokedCommand{script: script, cmd: cmd})
+	return c.Execute(ctx, script)
+}
+
+// invokeCommandKey marks the context of an Invoke call, whose pipeline
+// startPipeline builds from the command rather than the script.
+type invokeCommandKey struct{}
+
+// invokedCommand is the command of an Invoke call and the script it was
+// rendered to.
+type invokedCommand struct {
+	script string
+	cmd    *powershell.Command
+}
+
+// commandFor returns the command Invoke is running as script, or nil if
+// script did not come from Invoke (e.g. a script Execute runs while
+// reconnecting under the same context).
+func commandFor(ctx context.Context, script string) *powershell.Command {
+	v, ok := ctx.Value(invokeCommandKey{}).(invokedCommand)
+	if !ok || v.script != script {
+		return nil
+	}
+	return v.cmd
+}
+
+// commandPipeline creates a pipeline in pool that runs cmd.
+func (c *Client) commandPipeline(pool *runspace.Pool, cmd *powershell.Command) (*pipeline.Pipeline, error) {
+	p, err := pool.CreatePipelineBuilder()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.AddTo(p, c.valueConverter()); err != nil {
+		p.Cancel()
+		return nil, err
+	}
+	return p, nil
+}
+
+// commandScript renders cmd with the client's serialization options.
+func (c *Client) commandScript(cmd *powershell.Command) (string, error) {
+	return cmd.ScriptWithConverter(c.valueConverter())
+}
+
+// valueConverter converts parameter values with Config.Serialization, or
+// DefaultSerializationOptions if it is nil.
+func (c *Client) valueConverter() func(interface{}) interface{} {
+	opts := c.config.Serialization
+	if opts == nil {
+		opts = DefaultSerializationOptions()
+	}
+	return func(v interface{}) interface{} {
+		return normalizeInput(v, opts)
+	}
+}
+
+// ExecuteTemplate renders tmpl with params and executes it, so values never
+// need to be escaped by hand:
+//
+//	tmpl := powershell.MustParseTemplate(`Get-ChildItem {{dir}} -Filter {{filter}}`)
+//	result, err := c.ExecuteTemplate(ctx, tmpl, map[string]interface{}{"dir": dir, "filter": "*.log"})
+//
+// Values are converted like Invoke parameters. See powershell.Template for
+// how they are bound, and Execute for retry and error semantics.
+func (c *Client) ExecuteTemplate(ctx context.Context, tmpl *powershell.Template, params map[string]interface{}) (*Result, error) {
+	script, err := tmpl.RenderWithConverter(params, c.valueConverter())
+	if err != nil {
+		return nil, err
+	}
+	return c.Execute(ctx, script)
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/powershell"
+)
+
+func TestClient_CommandScript(t *testing.T) {
+	c := &Client{config: DefaultConfig()}
+
+	type user struct {
+		Name  string `json:"name"`
+		Admin bool
+	}
+	cmd := powershell.NewCommand("New-Thing").
+		AddParameter("User", user{Name: "o'brien", Admin: true}).
+		AddSwitch("Force")
+
+	got, err := c.commandScript(cmd)
+	if err != nil {
+		t.Fatalf("commandScript() error = %v", err)
+	}
+	want := `& 'New-Thing' -User:@{'Admin' = $true; 'name' = 'o''brien'} -Force`
+	if got != want {
+		t.Errorf("commandScript() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestClient_Invoke_BuildError(t *testing.T) {
+	c := &Client{config: DefaultConfig()}
+
+	_, err := c.Invoke(context.Background(), powershell.NewCommand("Get-Item").AddParameter("-Path", "x"))
+	if err == nil {
+		t.Fatal("Invoke() error = nil, want invalid parameter name")
+	}
+}
+
+func TestCommandFor(t *testing.T) {
+	cmd := powershell.NewCommand("Get-Item").AddParameter("Path", "x")
+	script, err := cmd.Script()
+	if err != nil {
+		t.Fatalf("Script() error = %v", err)
+	}
+	ctx := context.WithValue(context.Background(), invokeCommandKey{}, invokedCommand{script: script, cmd: cmd})
+
+	if got := commandFor(ctx, script); got != cmd {
+		t.Errorf("commandFor(Invoke script) = %v, want the command", got)
+	}
+	// Other scripts run under the same context are sent as scripts
+	if got := commandFor(ctx, "$PSVersionTable"); got != nil {
+		t.Errorf("commandFor(other script) = %v, want nil", got)
+	}
+	if got := commandFor(context.Background(), script); got != nil {
+		t.Errorf("commandFor(Execute) = %v, want nil", got)
+	}
+}
+
+func TestClient_ExecuteTemplate_RenderError(t *testing.T) {
+	c := &Client{config: DefaultConfig()}
+	tmpl := powershell.MustParseTemplate(`Get-Item -Path {{path}}`)
+
+	_, err := c.ExecuteTemplate(context.Background(), tmpl, map[string]interface{}{"Path": "x"})
+	if err == nil {
+		t.Fatal("ExecuteTemplate() error = nil, want unknown placeholder")
+	}
+}
@@ -0,0 +1,241 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Job errors.
+var (
+	// ErrJobsNotSupported is returned on transports without disconnectable
+	// pipelines. Only WSMan supports jobs.
+	ErrJobsNotSupported = errors.New("client: jobs require the WSMan transport")
+	// ErrInvalidJobID is returned for a JobID that was not made by StartJob.
+	ErrInvalidJobID = errors.New("client: invalid job id")
+	// ErrJobNotFound is returned when the job's shell no longer exists on the
+	// server (it was received, stopped or timed out).
+	ErrJobNotFound = errors.New("client: job not found")
+)
+
+// JobID identifies a job started with StartJob. It is a plain string so it
+// can be printed, saved and passed to a later process.
+type JobID string
+
+// newJobID encodes what a later client needs to reattach to the job.
+func newJobID(shellID, commandID, poolID string) JobID {
+	return JobID(strings.ToUpper(shellID + ":" + commandID + ":" + poolID))
+}
+
+// jobRef is a parsed JobID.
+type jobRef struct {
+	shellID   string
+	commandID string
+	poolID    string
+}
+
+func (id JobID) parse() (jobRef, error) {
+	parts := strings.Split(string(id), ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return jobRef{}, fmt.Errorf("%w: %q", ErrInvalidJobID, id)
+	}
+	for _, p := range parts[1:] {
+		if _, err := uuid.Parse(p); err != nil {
+			return jobRef{}, fmt.Errorf("%w: %q", ErrInvalidJobID, id)
+		}
+	}
+	return jobRef{shellID: parts[0], commandID: parts[1], poolID: parts[2]}, nil
+}
+
+// JobInfo describes a job as seen by the server.
+type JobInfo struct {
+	ID JobID
+	// ShellState is the server's state of the job shell, usually
+	// "Disconnected". "Connected" means another client is receiving it.
+	ShellState string
+	// Owner is the user that started the job.
+	Owner string
+	// CommandActive reports whether the job's command is still listed by
+	// the server: it is running or holds output that was not received yet.
+	CommandActive bool
+}
+
+// StartJob runs script as a job that outlives this process. The script runs
+// in a new shell of its own, which is disconnected once the script has
+// started, so c stays free for other work and the returned JobID can be
+// handed to another process:
+//
+//	id, err := c.StartJob(ctx, `Start-Sleep 3600; Get-ChildItem C:\Backups`)
+//	// ... later, possibly from another process with the same credentials:
+//	info, err := c.GetJob(ctx, id)
+//	result, err := c.ReceiveJob(ctx, id)
+//
+// The server keeps a disconnected shell until its idle timeout
+// (Config.IdleTimeout, capped by the server's session configuration), so
+// set it to cover the job's run time. c does not need to be connected.
+func (c *Client) StartJob(ctx context.Context, script string) (JobID, error) {
+	if err := c.checkJobTransport(); err != nil {
+		return "", err
+	}
+	w, err := c.CreateWorker()
+	if err != nil {
+		return "", fmt.Errorf("create job client: %w", err)
+	}
+	if err := w.Connect(ctx); err != nil {
+		return "", fmt.Errorf("connect job shell: %w", err)
+	}
+	commandID, err := w.ExecuteAsync(ctx, script)
+	if err != nil {
+		_ = w.Close(ctx)
+		return "", fmt.Errorf("start job: %w", err)
+	}
+	id := newJobID(w.ShellID(), commandID, w.PoolID())
+	if err := w.Disconnect(ctx); err != nil {
+		// A shell we cannot disconnect would die with this process
+		_ = w.Close(ctx)
+		return "", fmt.Errorf("disconnect job shell: %w", err)
+	}
+	// Release local resources only; the shell stays on the server
+	_ = w.CloseWithStrategy(ctx, CloseStrategyForce)
+
+	c.logSecurityEvent("JOB_START", map[string]interface{}{
+		"job_id": string(id),
+		"script": sanitizeScriptForLogging(script),
+	})
+	return id, nil
+}
+
+// GetJob returns the server's view of a job, or ErrJobNotFound.
+// c does not need to be connected.
+func (c *Client) GetJob(ctx context.Context, id JobID) (*JobInfo, error) {
+	ref, err := id.parse()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkJobTransport(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	wClient := c.wsman
+	c.mu.Unlock()
+	if wClient == nil {
+		return nil, ErrNotConnected
+	}
+
+	shells, err := wClient.Enumerate(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("enumerate shells: %w", err)
+	}
+	for _, shell := range shells {
+		if !strings.EqualFold(shell.ShellID, ref.shellID) {
+			continue
+		}
+		commandIDs, err := wClient.EnumerateCommands(ctx, shell.ShellID)
+		if err != nil {
+			return nil, fmt.Errorf("enumerate commands: %w", err)
+		}
+		return &JobInfo{
+			ID:         id,
+			ShellState: shell.State,
+			Owner:      shell.Owner,
+			CommandActive: slices.ContainsFunc(commandIDs, func(cmd string) bool {
+				return strings.EqualFold(cmd, ref.commandID)
+			}),
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+}
+
+// ReceiveJob reattaches to a job, waits for it to finish and returns its
+// output. The job's shell is then removed, so a job can be received once.
+// If ctx ends first, the job is disconnected again and keeps running.
+func (c *Client) ReceiveJob(ctx context.Context, id JobID) (*Result, error) {
+	ref, err := id.parse()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkJobTransport(); err != nil {
+		return nil, err
+	}
+	w, err := c.CreateWorker()
+	if err != nil {
+		return nil, fmt.Errorf("create job client: %w", err)
+	}
+	if err := w.SetPoolID(ref.poolID); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJobID, err)
+	}
+
+	type recovered struct {
+		result *Result
+		err    error
+	}
+	done := make(chan recovered, 1)
+	go func() {
+		result, err := w.RecoverPipelineOutput(ctx, ref.shellID, ref.commandID)
+		done <- recovered{result, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			w.releaseJob(ctx)
+			return nil, fmt.Errorf("receive job %s: %w", id, r.err)
+		}
+		if err := w.Close(ctx); err != nil {
+			c.logWarn("Removing job shell %s failed: %v", ref.shellID, err)
+		}
+		c.logSecurityEvent("JOB_RECEIVE", map[string]interface{}{
+			"job_id":     string(id),
+			"had_errors": r.result.HadErrors,
+		})
+		return r.result, nil
+	case <-ctx.Done():
+		w.releaseJob(context.WithoutCancel(ctx))
+		return nil, ctx.Err()
+	}
+}
+
+// StopJob stops a job and removes its shell; output not yet received is
+// discarded. Stopping a job that no longer exists is not an error.
+// c does not need to be connected.
+func (c *Client) StopJob(ctx context.Context, id JobID) error {
+	ref, err := id.parse()
+	if err != nil {
+		return err
+	}
+	if err := c.checkJobTransport(); err != nil {
+		return err
+	}
+	if err := c.RemoveDisconnectedSession(ctx, DisconnectedSession{ShellID: ref.shellID}); err != nil {
+		return fmt.Errorf("stop job %s: %w", id, err)
+	}
+	c.logSecurityEvent("JOB_STOP", map[string]interface{}{
+		"job_id": string(id),
+	})
+	return nil
+}
+
+// checkJobTransport rejects transports without disconnectable pipelines.
+func (c *Client) checkJobTransport() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.config.Transport.capabilities().Disconnect {
+		return fmt.Errorf("%w (transport is %s)", ErrJobsNotSupported, c.config.Transport)
+	}
+	return nil
+}
+
+// releaseJob leaves a job worker's shell disconnected on the server and
+// frees the worker, so the job can be received again later.
+func (c *Client) releaseJob(ctx context.Context) {
+	if c.IsConnected() {
+		if err := c.Disconnect(ctx); err != nil {
+			c.logWarn("Disconnecting job shell failed: %v", err)
+		}
+	}
+	_ = c.CloseWithStrategy(ctx, CloseStrategyForce)
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestJobID_Parse(t *testing.T) {
+	const (
+		shell   = "3F2504E0-4F89-11D3-9A0C-0305E82C3301"
+		command = "7C9E6679-7425-40DE-944B-E07FC1F90AE7"
+		pool    = "550E8400-E29B-41D4-A716-446655440000"
+	)
+	id := newJobID("3f2504e0-4f89-11d3-9a0c-0305e82c3301", command, pool)
+	ref, err := id.parse()
+	if err != nil {
+		t.Fatalf("parse(%q) error = %v", id, err)
+	}
+	if ref.shellID != shell || ref.commandID != command || ref.poolID != pool {
+		t.Errorf("parse(%q) = %+v", id, ref)
+	}
+
+	for _, bad := range []JobID{
+		"",
+		"not-a-job",
+		JobID(shell + ":" + command),
+		JobID(":" + command + ":" + pool),
+		JobID(shell + ":nope:" + pool),
+		JobID(shell + ":" + command + ":" + pool + ":extra"),
+	} {
+		if _, err := bad.parse(); !errors.Is(err, ErrInvalidJobID) {
+			t.Errorf("parse(%q) error = %v, want ErrInvalidJobID", bad, err)
+		}
+	}
+}
+
+func TestJobs_RequireWSMan(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Transport = TransportSSH
+	c := &Client{config: cfg}
+	ctx := context.Background()
+	id := newJobID("3F2504E0-4F89-11D3-9A0C-0305E82C3301", "7C9E6679-7425-40DE-944B-E07FC1F90AE7", "550E8400-E29B-41D4-A716-446655440000")
+
+	if _, err := c.StartJob(ctx, "Get-Date"); !errors.Is(err, ErrJobsNotSupported) {
+		t.Errorf("StartJob() error = %v, want ErrJobsNotSupported", err)
+	}
+	if _, err := c.GetJob(ctx, id); !errors.Is(err, ErrJobsNotSupported) {
+		t.Errorf("GetJob() error = %v, want ErrJobsNotSupported", err)
+	}
+	if _, err := c.ReceiveJob(ctx, id); !errors.Is(err, ErrJobsNotSupported) {
+		t.Errorf("ReceiveJob() error = %v, want ErrJobsNotSupported", err)
+	}
+	if err := c.StopJob(ctx, id); !errors.Is(err, ErrJobsNotSupported) {
+		t.Errorf("StopJob() error = %v, want ErrJobsNotSupported", err)
+	}
+}
+
+func TestJobs_InvalidID(t *testing.T) {
+	c := &Client{config: DefaultConfig()}
+	ctx := context.Background()
+	if _, err := c.GetJob(ctx, "bogus"); !errors.Is(err, ErrInvalidJobID) {
+		t.Errorf("GetJob() error = %v, want ErrInvalidJobID", err)
+	}
+	if _, err := c.ReceiveJob(ctx, "bogus"); !errors.Is(err, ErrInvalidJobID) {
+		t.Errorf("ReceiveJob() error = %v, want ErrInvalidJobID", err)
+	}
+	if err := c.StopJob(ctx, "bogus"); !errors.Is(err, ErrInvalidJobID) {
+		t.Errorf("StopJob() error = %v, want ErrInvalidJobID", err)
+	}
+}
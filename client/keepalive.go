@@ -0,0 +1,311 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+// KeepAliveStrategy selects how KeepAlive checks the session.
+type KeepAliveStrategy int
+
+const (
+	// KeepAliveMessage sends a protocol message that runs nothing:
+	// GET_AVAILABLE_RUNSPACES on OutOfProc transports (HvSocket, SSH,
+	// process and named pipe), and a Get of the shell on WSMan. It detects
+	// a broken connection or a shell the server has dropped.
+	KeepAliveMessage KeepAliveStrategy = iota
+
+	// KeepAlivePipeline runs a trivial pipeline ($null). It also proves
+	// that a runspace can run commands, at the cost of occupying one
+	// briefly; when all runspaces are busy the heartbeat waits for one
+	// until Timeout.
+	KeepAlivePipeline
+)
+
+// String returns the string representation of the strategy.
+func (s KeepAliveStrategy) String() string {
+	switch s {
+	case KeepAliveMessage:
+		return "Message"
+	case KeepAlivePipeline:
+		return "Pipeline"
+	default:
+		return "Unknown"
+	}
+}
+
+// KeepAliveState is the connection health seen by KeepAlive.
+type KeepAliveState int
+
+const (
+	// KeepAliveHealthy means the last heartbeat succeeded.
+	KeepAliveHealthy KeepAliveState = iota
+	// KeepAliveDegraded means heartbeats are being missed, but fewer than
+	// KeepAlivePolicy.MaxMissed in a row.
+	KeepAliveDegraded
+	// KeepAliveLost means MaxMissed heartbeats in a row were missed.
+	// Heartbeats continue, so the state returns to Healthy if the
+	// connection recovers.
+	KeepAliveLost
+)
+
+// String returns the string representation of the state.
+func (s KeepAliveState) String() string {
+	switch s {
+	case KeepAliveHealthy:
+		return "Healthy"
+	case KeepAliveDegraded:
+		return "Degraded"
+	case KeepAliveLost:
+		return "Lost"
+	default:
+		return "Unknown"
+	}
+}
+
+// ErrKeepAliveUnsupported is returned by KeepAlive.Beat when the transport
+// has no way to send the configured heartbeat.
+var ErrKeepAliveUnsupported = errors.New("client: keepalive is not supported by this transport")
+
+// KeepAlivePolicy configures the heartbeat of an idle session.
+type KeepAlivePolicy struct {
+	// Interval is the time between heartbeats. Required to Start.
+	Interval time.Duration
+
+	// Strategy selects the heartbeat. Default: KeepAliveMessage.
+	Strategy KeepAliveStrategy
+
+	// Timeout bounds each heartbeat. Default: 10s.
+	Timeout time.Duration
+
+	// MaxMissed is the number of heartbeats missed in a row after which
+	// the connection is considered lost. Default: 3.
+	MaxMissed int
+
+	// OnMissed is called after each missed heartbeat with the number
+	// missed in a row and the error.
+	OnMissed func(missed int, err error)
+
+	// OnStateChange is called when the state changes, for example to
+	// show connection health in a UI.
+	OnStateChange func(from, to KeepAliveState)
+}
+
+// withDefaults returns p with zero values replaced by the defaults.
+func (p KeepAlivePolicy) withDefaults() KeepAlivePolicy {
+	if p.Timeout <= 0 {
+		p.Timeout = 10 * time.Second
+	}
+	if p.MaxMissed <= 0 {
+		p.MaxMissed = 3
+	}
+	return p
+}
+
+// KeepAlive sends heartbeats on an idle session and tracks their outcome.
+// The client starts it on Connect if Config.KeepAlive is set, or if
+// Config.KeepAliveInterval is set and the transport supports PSRP
+// keepalive messages; it stops on Close. Get it with Client.KeepAlive.
+type KeepAlive struct {
+	client *Client
+	clock  Clock
+
+	mu      sync.Mutex
+	policy  KeepAlivePolicy
+	state   KeepAliveState
+	missed  int
+	last    time.Time
+	lastErr error
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newKeepAlive(c *Client, policy KeepAlivePolicy) *KeepAlive {
+	return &KeepAlive{client: c, clock: realClock{}, policy: policy.withDefaults()}
+}
+
+// KeepAlive returns the client's keepalive subsystem.
+func (c *Client) KeepAlive() *KeepAlive {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.keepAliveLocked()
+}
+
+// keepAliveLocked returns c.keepAlive, creating it from the configuration
+// if needed (caller must hold c.mu).
+func (c *Client) keepAliveLocked() *KeepAlive {
+	if c.keepAlive == nil {
+		policy := KeepAlivePolicy{Interval: c.config.KeepAliveInterval}
+		if c.config.KeepAlive != nil {
+			policy = *c.config.KeepAlive
+		}
+		c.keepAlive = newKeepAlive(c, policy)
+	}
+	return c.keepAlive
+}
+
+// SetPolicy replaces the policy. A running loop picks up the new interval
+// after its next heartbeat.
+func (k *KeepAlive) SetPolicy(policy KeepAlivePolicy) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.policy = policy.withDefaults()
+}
+
+// Start starts sending heartbeats every Interval. It does nothing if the
+// loop is already running or Interval is not set.
+func (k *KeepAlive) Start() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.done != nil || k.policy.Interval <= 0 {
+		return
+	}
+	k.done = make(chan struct{})
+	k.wg.Add(1)
+	go k.loop(k.done)
+}
+
+// Stop stops the heartbeat loop and waits for it to exit.
+func (k *KeepAlive) Stop() {
+	k.signalStop()
+	k.wg.Wait()
+}
+
+// signalStop stops the loop without waiting for it.
+func (k *KeepAlive) signalStop() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.done != nil {
+		close(k.done)
+		k.done = nil
+	}
+}
+
+// Running reports whether the heartbeat loop is running.
+func (k *KeepAlive) Running() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.done != nil
+}
+
+// State returns the connection health seen by the last heartbeat.
+func (k *KeepAlive) State() KeepAliveState {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.state
+}
+
+// Missed returns the number of heartbeats missed in a row and the error
+// of the last one.
+func (k *KeepAlive) Missed() (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.missed, k.lastErr
+}
+
+// LastHeartbeat returns when the last heartbeat succeeded, or the zero
+// time if none has.
+func (k *KeepAlive) LastHeartbeat() time.Time {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.last
+}
+
+// Beat sends one heartbeat now and records its outcome, as the loop does.
+func (k *KeepAlive) Beat(ctx context.Context) error {
+	k.mu.Lock()
+	policy := k.policy
+	k.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, policy.Timeout)
+	defer cancel()
+	err := k.client.heartbeat(ctx, policy.Strategy)
+	k.record(policy, err)
+	return err
+}
+
+func (k *KeepAlive) loop(done chan struct{}) {
+	defer k.wg.Done()
+
+	for {
+		k.mu.Lock()
+		interval := k.policy.Interval
+		k.mu.Unlock()
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !k.client.IsConnected() {
+			continue
+		}
+		if err := k.Beat(context.Background()); err != nil {
+			k.client.logWarn("Keepalive failed: %v", err)
+		}
+	}
+}
+
+// record updates the state after a heartbeat and runs the callbacks.
+func (k *KeepAlive) record(policy KeepAlivePolicy, err error) {
+	k.mu.Lock()
+	from := k.state
+	if err == nil {
+		k.missed = 0
+		k.lastErr = nil
+		k.last = k.clock.Now()
+		k.state = KeepAliveHealthy
+	} else {
+		k.client.stats.keepAliveMissed.Add(1)
+		k.missed++
+		k.lastErr = err
+		k.state = KeepAliveDegraded
+		if k.missed >= policy.MaxMissed {
+			k.state = KeepAliveLost
+		}
+	}
+	to, missed := k.state, k.missed
+	k.mu.Unlock()
+
+	if err != nil && policy.OnMissed != nil {
+		policy.OnMissed(missed, err)
+	}
+	if from != to && policy.OnStateChange != nil {
+		policy.OnStateChange(from, to)
+	}
+}
+
+// heartbeat sends one heartbeat with strategy.
+func (c *Client) heartbeat(ctx context.Context, strategy KeepAliveStrategy) error {
+	if strategy == KeepAlivePipeline {
+		ctx = context.WithValue(ctx, heartbeatKey{}, true)
+		_, err := c.executeOnce(ctx, "$null", ExecOptions{})
+		return err
+	}
+
+	c.mu.Lock()
+	pool, backend, w := c.psrpPool, c.backend, c.wsman
+	c.mu.Unlock()
+	if pool == nil || backend == nil {
+		return ErrNotConnected
+	}
+
+	if backend.SupportsPSRPKeepalive() {
+		c.logf("Sending Keepalive (GET_AVAILABLE_RUNSPACES)")
+		return pool.SendGetAvailableRunspaces(ctx)
+	}
+	if w != nil {
+		// A Receive would wait up to OperationTimeout for output; a Get
+		// of the shell answers at once and fails if the shell is gone.
+		_, err := w.Get(ctx, wsman.NsShell, map[string]string{"ShellId": backend.ShellID()})
+		return err
+	}
+	return ErrKeepAliveUnsupported
+}
@@ -2,7 +2,9 @@ package client
 
 import (
 	"context"
+	"errors"
 	"io"
+	"strings"
 	"testing"
 	"time"
 
@@ -126,3 +128,69 @@ func TestClient_Keepalive(t *testing.T) {
 
 	c.stopKeepaliveAndWait()
 }
+
+func TestKeepAlive_StateTransitions(t *testing.T) {
+	var transitions []string
+	var missedCounts []int
+	k := newKeepAlive(&Client{}, KeepAlivePolicy{
+		Interval:  time.Second,
+		MaxMissed: 2,
+		OnMissed:  func(missed int, _ error) { missedCounts = append(missedCounts, missed) },
+		OnStateChange: func(from, to KeepAliveState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	k.clock = newMockClock(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+
+	failure := errors.New("connection reset")
+	for _, err := range []error{failure, failure, failure, nil} {
+		k.record(k.policy, err)
+	}
+
+	want := []string{"Healthy->Degraded", "Degraded->Lost", "Lost->Healthy"}
+	if strings.Join(transitions, ",") != strings.Join(want, ",") {
+		t.Errorf("transitions = %v, want %v", transitions, want)
+	}
+	if len(missedCounts) != 3 || missedCounts[2] != 3 {
+		t.Errorf("OnMissed counts = %v, want [1 2 3]", missedCounts)
+	}
+	if missed, err := k.Missed(); missed != 0 || err != nil {
+		t.Errorf("Missed() = %d, %v after a heartbeat, want 0, nil", missed, err)
+	}
+	if !k.LastHeartbeat().Equal(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("LastHeartbeat() = %v", k.LastHeartbeat())
+	}
+}
+
+func TestKeepAlive_BeatNotConnected(t *testing.T) {
+	c := &Client{config: Config{KeepAlive: &KeepAlivePolicy{Interval: time.Minute}}}
+	k := c.KeepAlive()
+
+	if err := k.Beat(context.Background()); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("Beat() error = %v, want ErrNotConnected", err)
+	}
+	if k.State() != KeepAliveDegraded {
+		t.Errorf("State() = %v, want Degraded", k.State())
+	}
+}
+
+func TestKeepAlive_StartStop(t *testing.T) {
+	c := &Client{}
+	k := c.KeepAlive()
+
+	// No interval: nothing to run
+	k.Start()
+	if k.Running() {
+		t.Fatal("Running() = true without an interval")
+	}
+
+	k.SetPolicy(KeepAlivePolicy{Interval: time.Hour})
+	k.Start()
+	if !k.Running() {
+		t.Fatal("Running() = false after Start")
+	}
+	k.Stop()
+	if k.Running() {
+		t.Error("Running() = true after Stop")
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/smnsjas/go-psrp/powershell"
+)
+
+// ProcessOptions configures the local process transport (TransportProcess).
+type ProcessOptions struct {
+	// Path is the PowerShell executable.
+	// Default: pwsh on PATH, or powershell.exe on Windows.
+	Path string
+
+	// Args are the command-line arguments. They must start PowerShell in
+	// server mode (-s). -NoProfile is added unless Config.LoadProfile is set.
+	// Default: -s -NoLogo -NoProfile.
+	Args []string
+}
+
+// NamedPipeOptions configures the named pipe transport (TransportNamedPipe),
+// which attaches to a running PowerShell process like Enter-PSHostProcess.
+type NamedPipeOptions struct {
+	// ProcessID is the ID of the PowerShell process to attach to.
+	ProcessID int
+
+	// PipeName is the pipe to connect to instead of the PSHost pipe of
+	// ProcessID, for processes started with -CustomPipeName.
+	PipeName string
+}
+
+// isStreamTransport reports whether t runs PSRP over a single OutOfProc
+// stream (SSH, local process, named pipe). The server-side runspace ends with
+// the stream, so there is no session to disconnect from or save.
+func (t TransportType) isStreamTransport() bool {
+	return t == TransportSSH || t == TransportProcess || t == TransportNamedPipe
+}
+
+// localTarget returns the security log target for the local transports.
+func (c *Config) localTarget() string {
+	switch c.Transport {
+	case TransportProcess:
+		path := ""
+		if c.Process != nil {
+			path = c.Process.Path
+		}
+		if path == "" {
+			path = "pwsh"
+		}
+		return "process://" + path
+	case TransportNamedPipe:
+		if c.NamedPipe == nil {
+			return "pipe://"
+		}
+		if c.NamedPipe.PipeName != "" {
+			return "pipe://" + c.NamedPipe.PipeName
+		}
+		return fmt.Sprintf("pipe://pid/%d", c.NamedPipe.ProcessID)
+	}
+	return ""
+}
+
+// preflightLocal checks the process and named pipe transport settings.
+func (c *Config) preflightLocal(p *preflight) {
+	switch c.Transport {
+	case TransportProcess:
+		if c.Process != nil && c.Process.Path != "" {
+			if _, err := exec.LookPath(c.Process.Path); err != nil {
+				p.error("Process.Path", fmt.Sprintf("%q is not an executable", c.Process.Path), "set Process.Path to pwsh or powershell.exe")
+			}
+		} else if _, err := powershell.DefaultProcessPath(); err != nil {
+			p.error("Process.Path", "pwsh was not found on PATH", "install PowerShell 7 or set Process.Path")
+		}
+	case TransportNamedPipe:
+		if c.NamedPipe == nil || (c.NamedPipe.ProcessID <= 0 && c.NamedPipe.PipeName == "") {
+			p.error("NamedPipe", "a process ID or pipe name is required", "set NamedPipe.ProcessID to the PowerShell process to attach to")
+		}
+	}
+
+	if c.Username != "" || c.Password != "" {
+		p.warn("Username", "credentials are ignored by local transports; PowerShell runs as the current user",
+			"clear Username and Password")
+	}
+}
+
+// newLocalBackend creates the backend for the process or named pipe transport.
+func (c *Client) newLocalBackend() powershell.RunspaceBackend {
+	if c.config.Transport == TransportNamedPipe {
+		opts := NamedPipeOptions{}
+		if c.config.NamedPipe != nil {
+			opts = *c.config.NamedPipe
+		}
+		return powershell.NewNamedPipeBackend(opts.ProcessID, opts.PipeName, c.poolID)
+	}
+
+	opts := ProcessOptions{}
+	if c.config.Process != nil {
+		opts = *c.config.Process
+	}
+	return powershell.NewProcessBackend(opts.Path, processArgs(opts.Args, c.config.LoadProfile), c.poolID)
+}
+
+// processArgs returns the arguments to start PowerShell with: args, or
+// DefaultProcessArgs if nil, with -NoProfile added if missing, or removed
+// if loadProfile is set.
+func processArgs(args []string, loadProfile bool) []string {
+	if args == nil {
+		args = powershell.DefaultProcessArgs
+	}
+	out := make([]string, 0, len(args)+1)
+	noProfile := false
+	for _, arg := range args {
+		if isNoProfileArg(arg) {
+			if loadProfile {
+				continue
+			}
+			noProfile = true
+		}
+		out = append(out, arg)
+	}
+	if !loadProfile && !noProfile {
+		// First, so it cannot end up among the arguments of a -Command
+		out = append([]string{"-NoProfile"}, out...)
+	}
+	return out
+}
+
+// isNoProfileArg reports whether arg is -NoProfile or one of the
+// abbreviations PowerShell accepts for it.
+func isNoProfileArg(arg string) bool {
+	name := strings.ToLower(strings.TrimLeft(arg, "-/"))
+	return len(arg) > len(name) && len(name) >= 3 && strings.HasPrefix("noprofile", name)
+}
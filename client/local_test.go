@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/powershell"
+)
+
+func TestConfig_Validate_Local(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{
+			name: "process with path",
+			cfg:  Config{Transport: TransportProcess, Process: &ProcessOptions{Path: exe}},
+		},
+		{
+			name:    "process with missing path",
+			cfg:     Config{Transport: TransportProcess, Process: &ProcessOptions{Path: exe + ".missing"}},
+			wantErr: true,
+		},
+		{
+			name: "named pipe by pid",
+			cfg:  Config{Transport: TransportNamedPipe, NamedPipe: &NamedPipeOptions{ProcessID: 4242}},
+		},
+		{
+			name: "named pipe by name",
+			cfg:  Config{Transport: TransportNamedPipe, NamedPipe: &NamedPipeOptions{PipeName: "mypipe"}},
+		},
+		{
+			name:    "named pipe without target",
+			cfg:     Config{Transport: TransportNamedPipe},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewClient_Local(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		transport string
+		target    string
+	}{
+		{
+			name:      "process",
+			cfg:       Config{Transport: TransportProcess, Process: &ProcessOptions{Path: exe}},
+			transport: TransportNameProcess,
+			target:    "process://" + exe,
+		},
+		{
+			name:      "named pipe",
+			cfg:       Config{Transport: TransportNamedPipe, NamedPipe: &NamedPipeOptions{ProcessID: 4242}},
+			transport: TransportNameNamedPipe,
+			target:    "pipe://pid/4242",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Transport = tt.cfg.Transport
+			cfg.Process = tt.cfg.Process
+			cfg.NamedPipe = tt.cfg.NamedPipe
+
+			c, err := New("", cfg)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			if c.wsman != nil || c.transport != nil {
+				t.Error("local client should not create a WSMan client")
+			}
+			if got := cfg.Transport.String(); got != tt.transport {
+				t.Errorf("Transport.String() = %q, want %q", got, tt.transport)
+			}
+			if got := cfg.localTarget(); got != tt.target {
+				t.Errorf("localTarget() = %q, want %q", got, tt.target)
+			}
+		})
+	}
+}
+
+func TestNewLocalBackend(t *testing.T) {
+	c := &Client{config: DefaultConfig()}
+
+	c.config.Transport = TransportProcess
+	if _, ok := c.newLocalBackend().(*powershell.ProcessBackend); !ok {
+		t.Error("TransportProcess did not create a ProcessBackend")
+	}
+
+	c.config.Transport = TransportNamedPipe
+	c.config.NamedPipe = &NamedPipeOptions{ProcessID: 4242}
+	if _, ok := c.newLocalBackend().(*powershell.NamedPipeBackend); !ok {
+		t.Error("TransportNamedPipe did not create a NamedPipeBackend")
+	}
+}
+
+func TestLocalTransport_NoSessionPersistence(t *testing.T) {
+	c := &Client{config: DefaultConfig()}
+	c.config.Transport = TransportProcess
+
+	if err := c.SaveState(t.TempDir() + "/state.json"); err == nil || !strings.Contains(err.Error(), "process") {
+		t.Errorf("SaveState() error = %v, want not supported on process transport", err)
+	}
+	if err := c.Disconnect(context.Background()); err == nil {
+		t.Error("Disconnect() error = nil, want not supported")
+	}
+}
+
+func TestProcessArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		loadProfile bool
+		want        []string
+	}{
+		{"defaults", nil, false, []string{"-s", "-NoLogo", "-NoProfile"}},
+		{"defaults with profile", nil, true, []string{"-s", "-NoLogo"}},
+		{"custom args", []string{"-s", "-NoLogo"}, false, []string{"-NoProfile", "-s", "-NoLogo"}},
+		{"abbreviated", []string{"-s", "-nop"}, false, []string{"-s", "-nop"}},
+		{"nologo is not noprofile", []string{"-s", "-nol"}, false, []string{"-NoProfile", "-s", "-nol"}},
+		{"custom args with profile", []string{"-s", "/NoProfile", "-NoLogo"}, true, []string{"-s", "-NoLogo"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := processArgs(tt.args, tt.loadProfile)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("processArgs(%q, %v) = %q, want %q", tt.args, tt.loadProfile, got, tt.want)
+			}
+		})
+	}
+
+	if len(powershell.DefaultProcessArgs) != 3 {
+		t.Errorf("processArgs modified DefaultProcessArgs: %q", powershell.DefaultProcessArgs)
+	}
+}
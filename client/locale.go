@@ -0,0 +1,67 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+// CultureArgument and UICultureArgument are the ApplicationArguments keys
+// under which Config.DataLocale and Config.Locale are sent, if set. A
+// session configuration's startup script can apply them to runspaces
+// that do not take the culture from the WSMan locales:
+//
+//	[CultureInfo]::CurrentCulture = $PSSenderInfo.ApplicationArguments.PSRPCulture
+const (
+	CultureArgument   = "PSRPCulture"
+	UICultureArgument = "PSRPUICulture"
+)
+
+// localePattern matches language tags such as "de", "de-DE" and
+// "sr-Latn-RS".
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// wsmanOptions returns WSManOptions with Locale, DataLocale and the
+// "wsman" component logger applied.
+func (c *Config) wsmanOptions() wsman.ClientOptions {
+	opts := c.WSManOptions
+	if c.Locale != "" {
+		opts.Locale = c.Locale
+	}
+	if c.DataLocale != "" {
+		opts.DataLocale = c.DataLocale
+	}
+	if opts.Logger == nil {
+		opts.Logger = c.componentLogger("wsman")
+	}
+	return opts
+}
+
+// addCultureArguments adds the locales that were set to args.
+func (c *Config) addCultureArguments(args map[string]interface{}) {
+	if c.Locale != "" {
+		args[UICultureArgument] = c.Locale
+	}
+	if dataLocale := c.DataLocale; dataLocale != "" || c.Locale != "" {
+		if dataLocale == "" {
+			dataLocale = c.Locale
+		}
+		args[CultureArgument] = dataLocale
+	}
+}
+
+// preflightLocale checks Locale and DataLocale.
+func (c *Config) preflightLocale(p *preflight) {
+	for _, f := range []struct{ field, value string }{
+		{"Locale", c.Locale},
+		{"DataLocale", c.DataLocale},
+	} {
+		if f.value != "" && !localePattern.MatchString(f.value) {
+			p.error(f.field, fmt.Sprintf("%q is not a language tag", f.value), `use a culture name such as "en-US" or "de-DE"`)
+		}
+	}
+	if (c.Locale != "" || c.DataLocale != "") && c.Transport != TransportWSMan {
+		p.warn("Locale", "locales are only sent to the server over WSMan", "set the culture in the script instead")
+	}
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+func TestConfig_Locales(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.WSManOptions = wsman.ClientOptions{Locale: "fr-FR", DataLocale: "fr-CA"}
+	if opts := cfg.wsmanOptions(); opts.Locale != "fr-FR" || opts.DataLocale != "fr-CA" {
+		t.Errorf("wsmanOptions() without Locale = %+v, want WSManOptions kept", opts)
+	}
+	args, _ := cfg.applicationArguments()
+	if len(args) != 0 {
+		t.Errorf("applicationArguments() without Locale = %v", args)
+	}
+
+	cfg.Locale = "de-DE"
+	if opts := cfg.wsmanOptions(); opts.Locale != "de-DE" || opts.DataLocale != "fr-CA" {
+		t.Errorf("wsmanOptions() = %+v, want Locale overridden", opts)
+	}
+	args, _ = cfg.applicationArguments()
+	if args[UICultureArgument] != "de-DE" || args[CultureArgument] != "de-DE" {
+		t.Errorf("applicationArguments() = %v, want DataLocale to default to Locale", args)
+	}
+
+	cfg.DataLocale = "de-CH"
+	args, _ = cfg.applicationArguments()
+	if args[UICultureArgument] != "de-DE" || args[CultureArgument] != "de-CH" {
+		t.Errorf("applicationArguments() = %v", args)
+	}
+}
+
+func TestConfig_Preflight_Locale(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Username, cfg.Password = "u", "p"
+	cfg.Locale, cfg.DataLocale = "sr-Latn-RS", "de_DE"
+
+	issues := cfg.Preflight()
+	if issue := findIssue(issues, "Locale"); issue != nil {
+		t.Errorf("valid Locale reported: %v", issue)
+	}
+	if issue := findIssue(issues, "DataLocale"); issue == nil || issue.Severity != IssueError {
+		t.Errorf("DataLocale issue = %v, want an error for de_DE", issue)
+	}
+
+	cfg.DataLocale = ""
+	cfg.Transport = TransportSSH
+	if issue := findIssue(cfg.Preflight(), "Locale"); issue == nil || issue.Severity != IssueWarning {
+		t.Errorf("Locale issue over SSH = %v, want a warning", issue)
+	}
+}
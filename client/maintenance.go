@@ -0,0 +1,205 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrOutsideMaintenanceWindow is returned by Execute outside the target's
+// maintenance windows when the policy rejects such calls.
+var ErrOutsideMaintenanceWindow = errors.New("client: outside maintenance window")
+
+// MaintenanceMode specifies what happens to calls made outside a
+// maintenance window.
+type MaintenanceMode int
+
+const (
+	// MaintenanceReject fails calls immediately with
+	// ErrOutsideMaintenanceWindow.
+	MaintenanceReject MaintenanceMode = iota
+	// MaintenanceQueue blocks calls until the next window opens or the
+	// context is done.
+	MaintenanceQueue
+)
+
+// MaintenanceWindow is a recurring period in which commands may run, e.g.
+// Saturdays from 22:00 for four hours.
+type MaintenanceWindow struct {
+	// Name identifies the window in security events (e.g., a change ID).
+	Name string
+
+	// Days are the weekdays the window opens on. Empty means every day.
+	Days []time.Weekday
+
+	// Start is the time of day the window opens, as the time since
+	// midnight.
+	Start time.Duration
+
+	// Duration is how long the window stays open. It may extend past
+	// midnight.
+	Duration time.Duration
+
+	// Location is the time zone of Days and Start. Default: UTC.
+	Location *time.Location
+}
+
+// MaintenancePolicy restricts Execute to maintenance windows, for
+// change-managed targets.
+type MaintenancePolicy struct {
+	// Enabled activates the policy.
+	Enabled bool
+
+	// Windows apply to every target without an entry in Targets.
+	Windows []MaintenanceWindow
+
+	// Targets holds the windows of individual targets, by host name (case
+	// insensitive). They replace Windows for that target.
+	Targets map[string][]MaintenanceWindow
+
+	// Mode selects reject or queue behavior outside the windows.
+	// Default: MaintenanceReject.
+	Mode MaintenanceMode
+}
+
+// windowsFor returns the windows that apply to target.
+func (p *MaintenancePolicy) windowsFor(target string) []MaintenanceWindow {
+	for host, windows := range p.Targets {
+		if strings.EqualFold(host, target) {
+			return windows
+		}
+	}
+	return p.Windows
+}
+
+type maintenanceOverrideKey struct{}
+
+// ContextWithMaintenanceOverride lets the calls made with ctx run outside
+// maintenance windows, e.g. to fix an incident. reason, such as an incident
+// number, is recorded in the security events of every call.
+func ContextWithMaintenanceOverride(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, maintenanceOverrideKey{}, reason)
+}
+
+// maintenanceOverride returns the override reason set on ctx, or "".
+func maintenanceOverride(ctx context.Context) string {
+	reason, _ := ctx.Value(maintenanceOverrideKey{}).(string)
+	return reason
+}
+
+// maintenanceGate admits calls during the maintenance windows of a target.
+type maintenanceGate struct {
+	windows []MaintenanceWindow
+	mode    MaintenanceMode
+	clock   Clock
+}
+
+// newMaintenanceGate creates the gate for target.
+// Returns nil if the policy is nil, disabled, or has no windows for target,
+// which leaves the target unrestricted.
+func newMaintenanceGate(policy *MaintenancePolicy, target string) *maintenanceGate {
+	if policy == nil || !policy.Enabled {
+		return nil
+	}
+	windows := policy.windowsFor(target)
+	if len(windows) == 0 {
+		return nil
+	}
+	return &maintenanceGate{windows: windows, mode: policy.Mode, clock: realClock{}}
+}
+
+// Wait returns the open window or, depending on the mode, rejects the call
+// or blocks until the next window opens.
+func (g *maintenanceGate) Wait(ctx context.Context) (*MaintenanceWindow, error) {
+	for {
+		now := g.clock.Now()
+		open, next := g.check(now)
+		if open != nil {
+			return open, nil
+		}
+		if next.IsZero() {
+			return nil, ErrOutsideMaintenanceWindow
+		}
+		if g.mode != MaintenanceQueue {
+			return nil, fmt.Errorf("%w: next window opens %s", ErrOutsideMaintenanceWindow, next.Format(time.RFC3339))
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// check returns the window open at now, or nil and when the next window
+// opens (zero if none ever does).
+func (g *maintenanceGate) check(now time.Time) (*MaintenanceWindow, time.Time) {
+	var next time.Time
+	for i := range g.windows {
+		w := &g.windows[i]
+		if w.Duration <= 0 {
+			continue
+		}
+		loc := w.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		local := now.In(loc)
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+		// Windows that opened on earlier days may still be open
+		back := int((w.Start + w.Duration - 1) / (24 * time.Hour))
+		for day := -back; day <= 7; day++ {
+			date := midnight.AddDate(0, 0, day)
+			if len(w.Days) > 0 && !slices.Contains(w.Days, date.Weekday()) {
+				continue
+			}
+			start := date.Add(w.Start)
+			if !now.Before(start) && now.Before(start.Add(w.Duration)) {
+				return w, time.Time{}
+			}
+			if start.After(now) {
+				if next.IsZero() || start.Before(next) {
+					next = start
+				}
+				break
+			}
+		}
+	}
+	return nil, next
+}
+
+// checkMaintenance admits a call made with ctx, and returns the fields that
+// tag its security events with the maintenance window or override.
+func (c *Client) checkMaintenance(ctx context.Context, script string) (map[string]any, error) {
+	if c.maintenance == nil {
+		return nil, nil
+	}
+	if reason := maintenanceOverride(ctx); reason != "" {
+		if window, _ := c.maintenance.check(c.maintenance.clock.Now()); window != nil {
+			return map[string]any{"maintenance_window": window.Name}, nil
+		}
+		c.logWarn("Execute outside maintenance windows by override: %s", reason)
+		return map[string]any{"maintenance_override": reason}, nil
+	}
+
+	window, err := c.maintenance.Wait(ctx)
+	if err != nil {
+		c.logWarn("Execute rejected by maintenance window: %v", err)
+		if c.securityLogger != nil {
+			c.securityLogger.LogCommand(SubtypeCommandFailed, OutcomeDenied, SeverityWarning, map[string]any{
+				"script": sanitizeScriptForLogging(script),
+				"error":  err.Error(),
+				"reason": "maintenance_window",
+			})
+		}
+		return nil, err
+	}
+	return map[string]any{"maintenance_window": window.Name}, nil
+}
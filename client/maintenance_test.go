@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// saturdayNight opens Saturdays at 22:00 UTC for four hours.
+var saturdayNight = MaintenanceWindow{
+	Name:     "CHG-1001",
+	Days:     []time.Weekday{time.Saturday},
+	Start:    22 * time.Hour,
+	Duration: 4 * time.Hour,
+}
+
+func TestNewMaintenanceGate_Unrestricted(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *MaintenancePolicy
+	}{
+		{"Nil", nil},
+		{"Disabled", &MaintenancePolicy{Windows: []MaintenanceWindow{saturdayNight}}},
+		{"NoWindowsForTarget", &MaintenancePolicy{
+			Enabled: true,
+			Targets: map[string][]MaintenanceWindow{"other": {saturdayNight}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if g := newMaintenanceGate(tt.policy, "server01"); g != nil {
+				t.Errorf("newMaintenanceGate() = %v, want nil", g)
+			}
+		})
+	}
+}
+
+func TestMaintenanceGate_Check(t *testing.T) {
+	g := newMaintenanceGate(&MaintenancePolicy{Enabled: true, Windows: []MaintenanceWindow{saturdayNight}}, "server01")
+
+	// 2026-10-17 is a Saturday
+	tests := []struct {
+		name     string
+		now      time.Time
+		wantOpen bool
+		wantNext time.Time
+	}{
+		{
+			name:     "before",
+			now:      time.Date(2026, 10, 17, 21, 0, 0, 0, time.UTC),
+			wantNext: time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "open",
+			now:      time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC),
+			wantOpen: true,
+		},
+		{
+			name:     "open past midnight",
+			now:      time.Date(2026, 10, 18, 1, 59, 0, 0, time.UTC),
+			wantOpen: true,
+		},
+		{
+			name:     "closed",
+			now:      time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC),
+			wantNext: time.Date(2026, 10, 24, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "other time zone",
+			now:      time.Date(2026, 10, 18, 0, 30, 0, 0, time.FixedZone("UTC+2", 2*3600)),
+			wantOpen: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open, next := g.check(tt.now)
+			if (open != nil) != tt.wantOpen {
+				t.Fatalf("check() open = %v, want %v", open, tt.wantOpen)
+			}
+			if open != nil && open.Name != "CHG-1001" {
+				t.Errorf("check() window = %q", open.Name)
+			}
+			if !next.Equal(tt.wantNext) {
+				t.Errorf("check() next = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestMaintenanceGate_PerTargetWindows(t *testing.T) {
+	daily := MaintenanceWindow{Name: "daily", Start: 3 * time.Hour, Duration: time.Hour}
+	policy := &MaintenancePolicy{
+		Enabled: true,
+		Windows: []MaintenanceWindow{saturdayNight},
+		Targets: map[string][]MaintenanceWindow{"DB01": {daily}},
+	}
+
+	g := newMaintenanceGate(policy, "db01")
+	open, _ := g.check(time.Date(2026, 10, 14, 3, 30, 0, 0, time.UTC))
+	if open == nil || open.Name != "daily" {
+		t.Errorf("db01 check() = %v, want the daily window", open)
+	}
+	if open, _ := newMaintenanceGate(policy, "web01").check(time.Date(2026, 10, 14, 3, 30, 0, 0, time.UTC)); open != nil {
+		t.Errorf("web01 check() = %v, want closed", open)
+	}
+}
+
+func TestMaintenanceGate_QueueContextCancelled(t *testing.T) {
+	g := newMaintenanceGate(&MaintenancePolicy{
+		Enabled: true,
+		Windows: []MaintenanceWindow{saturdayNight},
+		Mode:    MaintenanceQueue,
+	}, "server01")
+	g.clock = newMockClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := g.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestClient_Execute_OutsideMaintenanceWindow(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Maintenance = &MaintenancePolicy{Enabled: true, Windows: []MaintenanceWindow{saturdayNight}}
+	c := &Client{config: cfg, maintenance: newMaintenanceGate(cfg.Maintenance, "server01")}
+	c.maintenance.clock = newMockClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+
+	_, err := c.Execute(context.Background(), "Restart-Service Spooler")
+	if !errors.Is(err, ErrOutsideMaintenanceWindow) {
+		t.Fatalf("Execute() error = %v, want ErrOutsideMaintenanceWindow", err)
+	}
+
+	ctx := ContextWithMaintenanceOverride(context.Background(), "INC-42")
+	fields, err := c.checkMaintenance(ctx, "Restart-Service Spooler")
+	if err != nil || fields["maintenance_override"] != "INC-42" {
+		t.Errorf("checkMaintenance() with override = %v, %v", fields, err)
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrManifestMismatch is returned by FetchDirectory when downloaded files do
+// not match the remote manifest. The result's Manifest lists the differences.
+var ErrManifestMismatch = errors.New("client: downloaded files do not match remote manifest")
+
+// WithManifestVerification makes FetchDirectory record a manifest of the
+// remote files (paths, sizes and SHA256 hashes) before downloading, and
+// verify every local file against it afterwards.
+func WithManifestVerification(enabled bool) FileTransferOption {
+	return func(o *FileTransferOptions) { o.VerifyManifest = enabled }
+}
+
+// ManifestEntry describes a remote file as it was before download.
+type ManifestEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+}
+
+// ManifestMismatch is a downloaded file that differs from its manifest entry.
+type ManifestMismatch struct {
+	Path         string `json:"path"`
+	Size         int64  `json:"size"`
+	ExpectedSize int64  `json:"expected_size"`
+	SHA256       string `json:"sha256"`
+	Expected     string `json:"expected_sha256"`
+}
+
+// ManifestReport is the result of verifying a download against the remote
+// manifest.
+type ManifestReport struct {
+	// Files is the remote manifest, taken before the download started.
+	Files []ManifestEntry `json:"files"`
+	// Verified lists files that match the manifest.
+	Verified []string `json:"verified"`
+	// Missing lists manifest files that are not present locally.
+	Missing []string `json:"missing,omitempty"`
+	// Corrupted lists files whose size or hash differ from the manifest.
+	Corrupted []ManifestMismatch `json:"corrupted,omitempty"`
+}
+
+// OK reports whether every manifest file was downloaded intact.
+func (r *ManifestReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Corrupted) == 0
+}
+
+// String returns a diff-style summary listing missing and corrupted files.
+func (r *ManifestReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "manifest: %d files, %d verified, %d missing, %d corrupted",
+		len(r.Files), len(r.Verified), len(r.Missing), len(r.Corrupted))
+	for _, p := range r.Missing {
+		fmt.Fprintf(&b, "\n- %s (missing)", p)
+	}
+	for _, m := range r.Corrupted {
+		if m.Size != m.ExpectedSize {
+			fmt.Fprintf(&b, "\n! %s (size %d, want %d)", m.Path, m.Size, m.ExpectedSize)
+			continue
+		}
+		fmt.Fprintf(&b, "\n! %s (sha256 %s, want %s)", m.Path, m.SHA256, m.Expected)
+	}
+	return b.String()
+}
+
+// WriteJSON writes the report as indented JSON, e.g. for an evidence record.
+func (r *ManifestReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// verifyManifest hashes the local copy of every manifest entry below
+// localDir and compares it with the manifest.
+func verifyManifest(localDir string, manifest []dirEntry) *ManifestReport {
+	report := &ManifestReport{Files: make([]ManifestEntry, 0, len(manifest))}
+	for _, want := range manifest {
+		report.Files = append(report.Files, ManifestEntry{
+			Path:    want.Rel,
+			Size:    want.Size,
+			ModTime: want.ModTime.UTC(),
+			SHA256:  strings.ToLower(want.Hash),
+		})
+
+		got, ok := statLocalFile(filepath.Join(localDir, filepath.FromSlash(want.Rel)), true)
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, want.Rel)
+		case got.Size != want.Size || !strings.EqualFold(got.Hash, want.Hash):
+			report.Corrupted = append(report.Corrupted, ManifestMismatch{
+				Path:         want.Rel,
+				Size:         got.Size,
+				ExpectedSize: want.Size,
+				SHA256:       got.Hash,
+				Expected:     strings.ToLower(want.Hash),
+			})
+		default:
+			report.Verified = append(report.Verified, want.Rel)
+		}
+	}
+	return report
+}
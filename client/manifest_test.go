@@ -0,0 +1,91 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyManifest(t *testing.T) {
+	dir := t.TempDir()
+	write := func(rel, content string) {
+		t.Helper()
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("ok.txt", "hello")
+	write("logs/tampered.log", "HELLO")
+	write("short.bin", "hel")
+
+	// SHA256 of "hello", as Get-FileHash reports it (upper case).
+	const helloHash = "2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824"
+	manifest := []dirEntry{
+		{Rel: "ok.txt", Size: 5, ModTime: time.Unix(1700000000, 0), Hash: helloHash},
+		{Rel: "logs/tampered.log", Size: 5, Hash: helloHash},
+		{Rel: "short.bin", Size: 5, Hash: helloHash},
+		{Rel: "gone.txt", Size: 5, Hash: helloHash},
+	}
+
+	report := verifyManifest(dir, manifest)
+	if report.OK() {
+		t.Fatal("OK() = true for a download with missing and corrupted files")
+	}
+	if len(report.Files) != 4 || report.Files[0].SHA256 != strings.ToLower(helloHash) {
+		t.Errorf("Files = %+v", report.Files)
+	}
+	if want := []string{"ok.txt"}; !slices.Equal(report.Verified, want) {
+		t.Errorf("Verified = %v, want %v", report.Verified, want)
+	}
+	if want := []string{"gone.txt"}; !slices.Equal(report.Missing, want) {
+		t.Errorf("Missing = %v, want %v", report.Missing, want)
+	}
+	if len(report.Corrupted) != 2 {
+		t.Fatalf("Corrupted = %+v, want 2 entries", report.Corrupted)
+	}
+
+	summary := report.String()
+	for _, want := range []string{
+		"4 files, 1 verified, 1 missing, 2 corrupted",
+		"- gone.txt (missing)",
+		"! short.bin (size 3, want 5)",
+		"! logs/tampered.log (sha256 ",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("String() missing %q:\n%s", want, summary)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	var decoded ManifestReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJSON() output is not JSON: %v", err)
+	}
+	if len(decoded.Corrupted) != 2 || decoded.Missing[0] != "gone.txt" {
+		t.Errorf("decoded report = %+v", decoded)
+	}
+}
+
+func TestVerifyManifest_AllVerified(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	report := verifyManifest(dir, []dirEntry{{Rel: "a", Hash: emptyHash}})
+	if !report.OK() {
+		t.Errorf("OK() = false: %s", report)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"sync"
 
+	"github.com/smnsjas/go-psrp/powershell"
 	"github.com/smnsjas/go-psrpcore/pipeline"
 	"github.com/smnsjas/go-psrpcore/runspace"
 )
@@ -18,10 +19,12 @@ type MockBackend struct {
 	CloseFunc                 func(ctx context.Context) error
 	InitFunc                  func(ctx context.Context, pool *runspace.Pool) error
 	PrepareFunc               func(ctx context.Context, p *pipeline.Pipeline, payload string) (io.Reader, func(), error)
+	StopPipelineFunc          func(ctx context.Context, p *pipeline.Pipeline) error
 	ShellIDFunc               func() string
 	ReattachFunc              func(ctx context.Context, pool *runspace.Pool, shellID string) error
 	TransportFunc             func() io.ReadWriter
 	SupportsPSRPKeepaliveFunc func() bool
+	CapabilitiesFunc          func() powershell.Capabilities
 
 	// State
 	Connected bool
@@ -71,6 +74,13 @@ func (m *MockBackend) PreparePipeline(ctx context.Context, p *pipeline.Pipeline,
 	return nil, func() {}, nil
 }
 
+func (m *MockBackend) StopPipeline(ctx context.Context, p *pipeline.Pipeline) error {
+	if m.StopPipelineFunc != nil {
+		return m.StopPipelineFunc(ctx, p)
+	}
+	return nil
+}
+
 func (m *MockBackend) ShellID() string {
 	if m.ShellIDFunc != nil {
 		return m.ShellIDFunc()
@@ -92,6 +102,13 @@ func (m *MockBackend) SupportsPSRPKeepalive() bool {
 	return false
 }
 
+func (m *MockBackend) Capabilities() powershell.Capabilities {
+	if m.CapabilitiesFunc != nil {
+		return m.CapabilitiesFunc()
+	}
+	return powershell.WSManCapabilities
+}
+
 // noOpTransport implements io.ReadWriter but does nothing
 type noOpTransport struct{}
 
@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/smnsjas/go-psrp/psquote"
+)
+
+// Module requirement problems. They are wrapped in a *ModuleRequirementError.
+var (
+	ErrModuleMissing  = errors.New("client: module is not available")
+	ErrModuleOutdated = errors.New("client: module version is too old")
+)
+
+// ModuleRequirementError is returned by RequireModules when modules are
+// missing or too old on the target. Use errors.Is with ErrModuleMissing or
+// ErrModuleOutdated to find out why.
+type ModuleRequirementError struct {
+	Problems []error
+}
+
+func (e *ModuleRequirementError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return "module requirements not met: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the problems, so errors.Is matches any of them.
+func (e *ModuleRequirementError) Unwrap() []error {
+	return e.Problems
+}
+
+// ModuleOptions configures RequireModulesWithOptions.
+type ModuleOptions struct {
+	// InstallFrom is a local directory with one subdirectory per module,
+	// as written by Save-Module -Path. Missing or outdated modules found
+	// there are uploaded to InstallTo and checked again. Empty disables
+	// installation.
+	InstallFrom string
+
+	// InstallTo is the remote modules directory. Empty uses the all-users
+	// directory, $env:ProgramFiles\WindowsPowerShell\Modules, which is on
+	// the module path of both Windows PowerShell and PowerShell 7.
+	InstallTo string
+
+	// TransferOptions are passed to CopyDirectory for each upload.
+	TransferOptions []FileTransferOption
+}
+
+// ModuleStatus is the state of one required module on the target.
+type ModuleStatus struct {
+	Name string
+
+	// MinimumVersion is the required version, empty for any version.
+	MinimumVersion string
+
+	// Version and Path are the highest available version and its
+	// ModuleBase, both empty if the module was not found.
+	Version string
+	Path    string
+
+	// Installed reports whether RequireModules uploaded the module.
+	Installed bool
+}
+
+// problem returns why the module does not meet its requirement, or nil.
+func (s ModuleStatus) problem() error {
+	switch {
+	case s.Version == "":
+		return fmt.Errorf("%w: %s", ErrModuleMissing, s.Name)
+	case s.MinimumVersion != "" && !versionAtLeast(s.Version, s.MinimumVersion):
+		return fmt.Errorf("%w: %s %s, need %s", ErrModuleOutdated, s.Name, s.Version, s.MinimumVersion)
+	}
+	return nil
+}
+
+// OK reports whether the module is available in the required version.
+func (s ModuleStatus) OK() bool {
+	return s.problem() == nil
+}
+
+// ModuleReport is the result of RequireModules, one status per requested
+// module in request order.
+type ModuleReport struct {
+	Modules []ModuleStatus
+
+	// InstallDir is the remote directory modules are installed to.
+	InstallDir string
+}
+
+// OK reports whether every module is available in the required version.
+func (r *ModuleReport) OK() bool {
+	return r.err() == nil
+}
+
+// Missing returns the modules that are missing or too old.
+func (r *ModuleReport) Missing() []ModuleStatus {
+	var missing []ModuleStatus
+	for _, m := range r.Modules {
+		if !m.OK() {
+			missing = append(missing, m)
+		}
+	}
+	return missing
+}
+
+// err returns a *ModuleRequirementError for the unmet requirements, or nil.
+func (r *ModuleReport) err() error {
+	var problems []error
+	for _, m := range r.Modules {
+		if p := m.problem(); p != nil {
+			problems = append(problems, p)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ModuleRequirementError{Problems: problems}
+}
+
+// RequireModules checks that the named modules are available on the target,
+// so a script fails before it starts rather than with "command not found"
+// part-way through. A name may require a minimum version as "Name>=1.2.3".
+//
+// The report is returned even when requirements are not met; the error is
+// then a *ModuleRequirementError. Other errors mean the check itself failed.
+func (c *Client) RequireModules(ctx context.Context, names ...string) (*ModuleReport, error) {
+	return c.RequireModulesWithOptions(ctx, ModuleOptions{}, names...)
+}
+
+// RequireModulesWithOptions is RequireModules with options, e.g. to install
+// missing modules from a local directory.
+func (c *Client) RequireModulesWithOptions(ctx context.Context, opts ModuleOptions, names ...string) (*ModuleReport, error) {
+	if len(names) == 0 {
+		return &ModuleReport{}, nil
+	}
+	modules := make([]ModuleStatus, len(names))
+	for i, name := range names {
+		m, err := parseModuleRequirement(name)
+		if err != nil {
+			return nil, err
+		}
+		modules[i] = m
+	}
+
+	report, err := c.checkModules(ctx, modules, opts.InstallTo)
+	if err != nil {
+		return nil, err
+	}
+	if report.OK() || opts.InstallFrom == "" {
+		return report, report.err()
+	}
+
+	installed := make(map[string]bool)
+	for _, m := range report.Missing() {
+		localDir := filepath.Join(opts.InstallFrom, m.Name)
+		if info, err := os.Stat(localDir); err != nil || !info.IsDir() {
+			continue
+		}
+		remoteDir := strings.TrimRight(report.InstallDir, `\`) + `\` + m.Name
+		c.logInfo("installing module %s to %s", m.Name, remoteDir)
+		if _, err := c.CopyDirectory(ctx, localDir, remoteDir, opts.TransferOptions...); err != nil {
+			return report, fmt.Errorf("install module %s: %w", m.Name, err)
+		}
+		installed[strings.ToLower(m.Name)] = true
+	}
+	if len(installed) == 0 {
+		return report, report.err()
+	}
+
+	report, err = c.checkModules(ctx, modules, opts.InstallTo)
+	if err != nil {
+		return nil, err
+	}
+	for i := range report.Modules {
+		report.Modules[i].Installed = installed[strings.ToLower(report.Modules[i].Name)]
+	}
+	return report, report.err()
+}
+
+// checkModules runs the module check script and fills in the status of
+// each module.
+func (c *Client) checkModules(ctx context.Context, modules []ModuleStatus, installTo string) (*ModuleReport, error) {
+	names := make([]string, len(modules))
+	for i, m := range modules {
+		names[i] = m.Name
+	}
+	result, err := c.Execute(ctx, generateModuleCheckScript(names, installTo))
+	if err != nil {
+		return nil, fmt.Errorf("check remote modules: %w", err)
+	}
+	lines := make([]string, 0, len(modules)+1)
+	if result != nil {
+		for _, out := range result.Output {
+			lines = append(lines, strings.TrimSpace(outputString(out)))
+		}
+	}
+	return parseModuleCheck(lines, modules)
+}
+
+// versionAtLeast reports whether version is at least minimum. A version
+// that cannot be compared does not meet the requirement.
+func versionAtLeast(version, minimum string) bool {
+	cmp, err := compareVersions(version, minimum)
+	return err == nil && cmp >= 0
+}
+
+// parseModuleRequirement parses "Name" or "Name>=Version".
+func parseModuleRequirement(spec string) (ModuleStatus, error) {
+	name, version, _ := strings.Cut(spec, ">=")
+	m := ModuleStatus{Name: strings.TrimSpace(name), MinimumVersion: strings.TrimSpace(version)}
+	switch {
+	case m.Name == "":
+		return m, fmt.Errorf("client: empty module name in %q", spec)
+	case strings.ContainsAny(m.Name, `*?[]|\/`):
+		return m, fmt.Errorf("client: invalid module name %q", m.Name)
+	case strings.Contains(spec, ">=") && !validVersion(m.MinimumVersion):
+		return m, fmt.Errorf("client: invalid module version in %q", spec)
+	}
+	return m, nil
+}
+
+// validVersion reports whether v is a dotted version of up to four numbers.
+func validVersion(v string) bool {
+	parts := strings.Split(v, ".")
+	if len(parts) > 4 {
+		return false
+	}
+	for _, p := range parts {
+		if _, err := strconv.Atoi(p); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// parseModuleCheck parses the output of the module check script: one
+// "name|version|path" line per module, then the install directory.
+func parseModuleCheck(lines []string, modules []ModuleStatus) (*ModuleReport, error) {
+	if len(lines) != len(modules)+1 {
+		return nil, fmt.Errorf("unexpected remote module check output: %d lines for %d modules", len(lines), len(modules))
+	}
+	report := &ModuleReport{
+		Modules:    make([]ModuleStatus, len(modules)),
+		InstallDir: lines[len(modules)],
+	}
+	for i, m := range modules {
+		fields := strings.Split(lines[i], "|")
+		if len(fields) != 3 || !strings.EqualFold(fields[0], m.Name) {
+			return nil, fmt.Errorf("unexpected remote module check output: %q", lines[i])
+		}
+		m.Version, m.Path = fields[1], fields[2]
+		report.Modules[i] = m
+	}
+	return report, nil
+}
+
+// generateModuleCheckScript creates a PowerShell script that reports the
+// highest available version of each module, then the install directory.
+func generateModuleCheckScript(names []string, installTo string) string {
+	return psquote.Sprintf(`
+		$ErrorActionPreference = 'Stop'
+		foreach ($name in %s) {
+			$module = Get-Module -ListAvailable -Name $name -ErrorAction SilentlyContinue |
+				Sort-Object Version -Descending | Select-Object -First 1
+			if ($module) {
+				'{0}|{1}|{2}' -f $name, $module.Version, $module.ModuleBase
+			} else {
+				'{0}||' -f $name
+			}
+		}
+		$installTo = %s
+		if (-not $installTo) { $installTo = Join-Path $env:ProgramFiles 'WindowsPowerShell\Modules' }
+		$installTo
+	`, names, installTo)
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseModuleRequirement(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    ModuleStatus
+		wantErr bool
+	}{
+		{spec: "Az.Accounts", want: ModuleStatus{Name: "Az.Accounts"}},
+		{spec: "Pester >= 5.3", want: ModuleStatus{Name: "Pester", MinimumVersion: "5.3"}},
+		{spec: "", wantErr: true},
+		{spec: ">=1.0", wantErr: true},
+		{spec: "Az.*", wantErr: true},
+		{spec: `C:\Modules\Foo`, wantErr: true},
+		{spec: "Pester>=", wantErr: true},
+		{spec: "Pester>=5.x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseModuleRequirement(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseModuleRequirement(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseModuleRequirement(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version, minimum string
+		want             bool
+	}{
+		{"5.3.1", "5.3", true},
+		{"5.3", "5.3.0.0", true},
+		{"5.10", "5.9", true},
+		{"1.0", "2", false},
+		{"1.0-preview", "1.0", false},
+	}
+	for _, tt := range tests {
+		if got := versionAtLeast(tt.version, tt.minimum); got != tt.want {
+			t.Errorf("versionAtLeast(%q, %q) = %v, want %v", tt.version, tt.minimum, got, tt.want)
+		}
+	}
+}
+
+func TestParseModuleCheck(t *testing.T) {
+	modules := []ModuleStatus{
+		{Name: "Pester", MinimumVersion: "5.0"},
+		{Name: "PSReadLine", MinimumVersion: "2.2"},
+		{Name: "Az.Accounts"},
+	}
+	lines := []string{
+		`pester|5.5.0|C:\Program Files\WindowsPowerShell\Modules\Pester\5.5.0`,
+		`PSReadLine|2.0.0|C:\Program Files\WindowsPowerShell\Modules\PSReadLine\2.0.0`,
+		`Az.Accounts||`,
+		`C:\Program Files\WindowsPowerShell\Modules`,
+	}
+	report, err := parseModuleCheck(lines, modules)
+	if err != nil {
+		t.Fatalf("parseModuleCheck() error = %v", err)
+	}
+	if report.InstallDir != `C:\Program Files\WindowsPowerShell\Modules` {
+		t.Errorf("InstallDir = %q", report.InstallDir)
+	}
+	if !report.Modules[0].OK() || report.Modules[0].Version != "5.5.0" {
+		t.Errorf("Pester = %+v", report.Modules[0])
+	}
+	if report.OK() || len(report.Missing()) != 2 {
+		t.Fatalf("Missing() = %+v", report.Missing())
+	}
+
+	err = report.err()
+	var reqErr *ModuleRequirementError
+	if !errors.As(err, &reqErr) || len(reqErr.Problems) != 2 {
+		t.Fatalf("err() = %v, want *ModuleRequirementError with 2 problems", err)
+	}
+	if !errors.Is(err, ErrModuleOutdated) || !errors.Is(err, ErrModuleMissing) {
+		t.Errorf("err() = %v, want ErrModuleOutdated and ErrModuleMissing", err)
+	}
+
+	for _, bad := range [][]string{lines[:3], {"Other|1.0|x", lines[1], lines[2], lines[3]}, {"Pester|1.0", lines[1], lines[2], lines[3]}} {
+		if _, err := parseModuleCheck(bad, modules); err == nil {
+			t.Errorf("parseModuleCheck(%q) error = nil", bad)
+		}
+	}
+}
+
+func TestGenerateModuleCheckScript_QuotesNames(t *testing.T) {
+	script := generateModuleCheckScript([]string{"Pester", "x'; Remove-Item C:\\ -Recurse; '"}, "")
+	if !strings.Contains(script, "@('Pester', 'x''; Remove-Item C:\\ -Recurse; ''')") {
+		t.Errorf("module names are not quoted in the check script:\n%s", script)
+	}
+	if !strings.Contains(script, "$installTo = ''") {
+		t.Errorf("empty install directory is not an empty string:\n%s", script)
+	}
+}
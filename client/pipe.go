@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExecutePipe runs script with the lines read from r as pipeline input, so
+// the script sees each line as one string in $input (or as pipeline input of
+// its first command):
+//
+//	f, _ := os.Open("servers.csv")
+//	result, err := c.ExecutePipe(ctx, `$input | ConvertFrom-Csv | ForEach-Object { Test-Connection $_.Name -Count 1 }`, f)
+//
+// Lines are sent as they are read, one at a time, and the next line is only
+// read once the previous one was sent, so large inputs are never held in
+// memory. Line endings (\n or \r\n) are removed. Output is collected as by
+// Execute; use ExecuteStreamWithInput to consume output while input is
+// still being sent.
+//
+// Unlike Execute, ExecutePipe is never retried: the input has already been
+// consumed.
+func (c *Client) ExecutePipe(ctx context.Context, script string, r io.Reader) (*Result, error) {
+	if r == nil {
+		return nil, errors.New("client: ExecutePipe requires a reader")
+	}
+	stream, err := c.ExecuteStreamWithInput(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	return c.collectResult(stream, ExecOptions{}, func() error {
+		if err := sendLines(ctx, stream, r); err != nil {
+			return err
+		}
+		if err := stream.CloseInput(ctx); err != nil {
+			return fmt.Errorf("close pipeline input: %w", err)
+		}
+		return nil
+	})
+}
+
+// lineSender sends one pipeline input value.
+type lineSender interface {
+	SendInput(ctx context.Context, data interface{}) error
+}
+
+// sendLines sends each line of r to the pipeline. Lines of any length are
+// supported; a final line without a newline is sent too.
+func sendLines(ctx context.Context, sink lineSender, r io.Reader) error {
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, readErr := br.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("read pipeline input line %d: %w", n, readErr)
+		}
+		if line == "" && readErr == io.EOF {
+			return nil
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if err := sink.SendInput(ctx, line); err != nil {
+			return fmt.Errorf("send pipeline input line %d: %w", n, err)
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// recordingSink records pipeline input.
+type recordingSink struct {
+	lines []string
+	err   error
+}
+
+func (s *recordingSink) SendInput(_ context.Context, data interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.lines = append(s.lines, data.(string))
+	return nil
+}
+
+func TestSendLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty", "", nil},
+		{"trailing newline", "a\nb\n", []string{"a", "b"}},
+		{"no trailing newline", "a\nb", []string{"a", "b"}},
+		{"crlf", "a\r\nb\r\n", []string{"a", "b"}},
+		{"blank lines kept", "a\n\nb\n", []string{"a", "", "b"}},
+		{"long line", strings.Repeat("x", 1<<20) + "\n", []string{strings.Repeat("x", 1<<20)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			// OneByteReader checks that lines split across reads are joined.
+			if err := sendLines(context.Background(), sink, iotest.OneByteReader(strings.NewReader(tt.input))); err != nil {
+				t.Fatalf("sendLines() error = %v", err)
+			}
+			if !slices.Equal(sink.lines, tt.want) {
+				t.Errorf("sent %q, want %q", sink.lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendLines_Errors(t *testing.T) {
+	readErr := errors.New("disk gone")
+	if err := sendLines(context.Background(), &recordingSink{}, iotest.ErrReader(readErr)); !errors.Is(err, readErr) {
+		t.Errorf("sendLines() read error = %v, want %v", err, readErr)
+	}
+
+	sendErr := errors.New("pipeline stopped")
+	err := sendLines(context.Background(), &recordingSink{err: sendErr}, strings.NewReader("a\n"))
+	if !errors.Is(err, sendErr) || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("sendLines() send error = %v", err)
+	}
+}
+
+func TestExecutePipe_NilReader(t *testing.T) {
+	c := &Client{config: DefaultConfig()}
+	if _, err := c.ExecutePipe(context.Background(), "$input", nil); err == nil {
+		t.Error("ExecutePipe() with nil reader error = nil")
+	}
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrExecutionDenied is returned when Config.PreExecHook refuses a script.
+var ErrExecutionDenied = errors.New("client: execution denied by policy")
+
+// PreExecHook decides whether script may run on target (the hostname the
+// client was created with). It returns false and a reason to refuse it.
+type PreExecHook func(ctx context.Context, target, script string) (allow bool, reason string)
+
+// checkPreExec asks the PreExecHook, if any, whether script may run.
+func (c *Client) checkPreExec(ctx context.Context, script string) error {
+	c.mu.Lock()
+	hook := c.config.PreExecHook
+	securityLogger := c.securityLogger
+	c.mu.Unlock()
+	if hook == nil {
+		return nil
+	}
+
+	allow, reason := hook(ctx, c.hostname, script)
+	if allow {
+		return nil
+	}
+	if reason == "" {
+		reason = "no reason given"
+	}
+	c.logWarn("Execute denied by policy: %s", reason)
+	if securityLogger != nil {
+		securityLogger.LogCommand(SubtypeCommandFailed, OutcomeDenied, SeverityWarning, map[string]any{
+			"script":        sanitizeScriptForLogging(script),
+			"reason":        "policy",
+			"policy_reason": reason,
+		})
+	}
+	return fmt.Errorf("%w: %s", ErrExecutionDenied, reason)
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/pipeline"
+	"github.com/smnsjas/go-psrpcore/runspace"
+)
+
+func TestExecuteStream_PreExecHook(t *testing.T) {
+	prepared := 0
+	mockBackend := &MockBackend{
+		PrepareFunc: func(ctx context.Context, p *pipeline.Pipeline, payload string) (io.Reader, func(), error) {
+			prepared++
+			return &DummyReadWriter{}, func() {}, nil
+		},
+	}
+	cfg := DefaultConfig()
+	var gotTarget string
+	cfg.PreExecHook = func(ctx context.Context, target, script string) (bool, string) {
+		gotTarget = target
+		if strings.Contains(script, "Stop-Computer") {
+			return false, "Stop-Computer is not allowed"
+		}
+		return true, ""
+	}
+	c := &Client{
+		hostname:  "web01",
+		config:    cfg,
+		backend:   mockBackend,
+		connected: true,
+		psrpPool:  runspace.New(&DummyReadWriter{}, uuid.New()),
+		semaphore: newPoolSemaphore(1, 0, time.Second),
+		callID:    newCallIDManager(),
+	}
+	c.psrpPool.ResumeOpened()
+
+	_, err := c.ExecuteStream(context.Background(), "Stop-Computer -Force")
+	if !errors.Is(err, ErrExecutionDenied) || !strings.Contains(err.Error(), "Stop-Computer is not allowed") {
+		t.Fatalf("ExecuteStream() error = %v, want ErrExecutionDenied with the reason", err)
+	}
+	if gotTarget != "web01" {
+		t.Errorf("hook target = %q, want web01", gotTarget)
+	}
+	if prepared != 0 {
+		t.Error("denied script reached the backend")
+	}
+	if isRetryableError(err) {
+		t.Error("ErrExecutionDenied is retryable")
+	}
+}
+
+func TestClient_CheckPreExec(t *testing.T) {
+	c := &Client{config: DefaultConfig()}
+	if err := c.checkPreExec(context.Background(), "Get-Date"); err != nil {
+		t.Errorf("checkPreExec() without hook error = %v", err)
+	}
+
+	c.config.PreExecHook = func(ctx context.Context, target, script string) (bool, string) {
+		return script == "Get-Date", ""
+	}
+	if err := c.checkPreExec(context.Background(), "Get-Date"); err != nil {
+		t.Errorf("checkPreExec() of allowed script error = %v", err)
+	}
+	if err := c.checkPreExec(context.Background(), "Remove-Item C:\\"); !errors.Is(err, ErrExecutionDenied) {
+		t.Errorf("checkPreExec() of denied script error = %v, want ErrExecutionDenied", err)
+	}
+}
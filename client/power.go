@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smnsjas/go-psrp/winrs"
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+// withWinRSShell runs fn with a temporary WinRS shell that is closed afterwards.
+func (c *Client) withWinRSShell(ctx context.Context, fn func(shell *winrs.Shell) error) error {
+	if c.wsman == nil {
+		return fmt.Errorf("winrs: wsman client not initialized - ensure transport is WSMan")
+	}
+
+	shell, err := winrs.NewShell(ctx, c.wsman)
+	if err != nil {
+		return fmt.Errorf("winrs: create shell: %w", err)
+	}
+	defer func() {
+		if closeErr := shell.Close(ctx); closeErr != nil {
+			c.logWarn("winrs: failed to close shell: %v", closeErr)
+		}
+	}()
+
+	return fn(shell)
+}
+
+// Shutdown shuts down or restarts the remote computer via WinRS (shutdown.exe).
+// It does not use PSRP, so it works even when the PowerShell endpoint is broken.
+// Only a WSMan connection is required (see ConnectWSManOnly).
+func (c *Client) Shutdown(ctx context.Context, opts winrs.ShutdownOptions) error {
+	c.logInfo("Shutdown called (restart: %v, delay: %v, force: %v)", opts.Restart, opts.Delay, opts.Force)
+
+	err := c.withWinRSShell(ctx, func(shell *winrs.Shell) error {
+		return shell.Shutdown(ctx, opts)
+	})
+
+	if c.securityLogger != nil {
+		outcome := OutcomeSuccess
+		severity := SeverityWarning
+		details := map[string]any{
+			"restart": opts.Restart,
+			"delay":   opts.Delay.String(),
+			"force":   opts.Force,
+			"mode":    "winrs",
+		}
+		if err != nil {
+			outcome = OutcomeFailure
+			severity = SeverityError
+			details["error"] = err.Error()
+		}
+		c.securityLogger.LogCommand("shutdown", outcome, severity, details)
+	}
+
+	return err
+}
+
+// LastBootTime returns the time the remote computer was last started, via WinRS.
+func (c *Client) LastBootTime(ctx context.Context) (time.Time, error) {
+	var bootTime time.Time
+	err := c.withWinRSShell(ctx, func(shell *winrs.Shell) error {
+		var err error
+		bootTime, err = shell.LastBootTime(ctx)
+		return err
+	})
+	return bootTime, err
+}
+
+// WaitForBoot polls the remote computer every interval until it reports a
+// boot time after since, then returns the new boot time.
+// Errors while the computer is unreachable or WinRM is starting are
+// expected and ignored until the context is done. Errors that no restart
+// clears, such as a failing command or denied access, are returned at once.
+func (c *Client) WaitForBoot(ctx context.Context, since time.Time, interval time.Duration) (time.Time, error) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return time.Time{}, fmt.Errorf("wait for boot: %w", ctx.Err())
+		case <-ticker.C:
+		}
+
+		// Connections from before the restart are dead; don't reuse them
+		if c.transport != nil {
+			c.transport.CloseIdleConnections()
+		}
+
+		bootTime, err := c.LastBootTime(ctx)
+		if err != nil {
+			if !bootPending(err) {
+				return time.Time{}, fmt.Errorf("wait for boot: %w", err)
+			}
+			c.logf("WaitForBoot: host not ready: %v", err)
+			continue
+		}
+		if bootTime.After(since) {
+			c.logInfo("WaitForBoot: host booted at %v", bootTime)
+			return bootTime, nil
+		}
+	}
+}
+
+// bootPending reports whether err, from LastBootTime, may come from a
+// computer that is still restarting. A command that ran and failed, output
+// that does not parse and denied access fail the same way on every poll.
+func bootPending(err error) bool {
+	if errors.Is(err, winrs.ErrCommandFailed) || errors.Is(err, winrs.ErrUnexpectedOutput) {
+		return false
+	}
+	if f, ok := wsman.AsFault(err); ok && f.IsAccessDenied() {
+		return false
+	}
+	return true
+}
+
+// RestartAndWait restarts the remote computer via WinRS and blocks until it
+// is back up (its boot time has changed). It returns the new boot time.
+// The PSRP session does not survive a restart; reconnect afterwards if needed.
+func (c *Client) RestartAndWait(ctx context.Context, opts winrs.ShutdownOptions, interval time.Duration) (time.Time, error) {
+	before, err := c.LastBootTime(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read boot time: %w", err)
+	}
+
+	opts.Restart = true
+	if err := c.Shutdown(ctx, opts); err != nil {
+		return time.Time{}, fmt.Errorf("restart: %w", err)
+	}
+
+	return c.WaitForBoot(ctx, before, interval)
+}
@@ -0,0 +1,29 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/winrs"
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+func TestBootPending(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unreachable", errors.New("dial tcp 10.0.0.5:5985: connect: connection refused"), true},
+		{"shell creation", fmt.Errorf("winrs: create shell: %w", &wsman.Fault{Subcode: "w:InternalError"}), true},
+		{"command not found", fmt.Errorf("%w: powershell.exe exit code 1: 'powershell.exe' is not recognized", winrs.ErrCommandFailed), false},
+		{"unexpected output", fmt.Errorf("%w: LastBootUpTime \"\"", winrs.ErrUnexpectedOutput), false},
+		{"access denied", fmt.Errorf("winrs: create shell: %w", &wsman.Fault{Subcode: "w:AccessDenied"}), false},
+	}
+	for _, tt := range tests {
+		if got := bootPending(tt.err); got != tt.want {
+			t.Errorf("bootPending(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrp/wsman/auth"
+)
+
+// IssueSeverity classifies a configuration issue found by Preflight.
+type IssueSeverity int
+
+const (
+	// IssueWarning marks a setting that is ignored, insecure or likely to fail
+	// against a default server configuration, but may be intentional.
+	IssueWarning IssueSeverity = iota
+	// IssueError marks a configuration that cannot work. Validate fails on these.
+	IssueError
+)
+
+// String returns the severity name.
+func (s IssueSeverity) String() string {
+	if s == IssueError {
+		return "error"
+	}
+	return "warning"
+}
+
+// ConfigIssue describes one problem found in a Config, with a hint on how to fix it.
+type ConfigIssue struct {
+	Severity IssueSeverity
+	// Field is the Config field the issue refers to (e.g., "KeytabPath").
+	Field string
+	// Problem describes what is wrong.
+	Problem string
+	// Hint describes how to fix it.
+	Hint string
+}
+
+// Error implements error.
+func (i ConfigIssue) Error() string {
+	msg := i.Field + ": " + i.Problem
+	if i.Hint != "" {
+		msg += " (" + i.Hint + ")"
+	}
+	return msg
+}
+
+// ConfigError is returned by Validate and lists every error-severity issue.
+// Use errors.As to inspect the individual issues.
+type ConfigError struct {
+	Issues []ConfigIssue
+}
+
+// Error implements error.
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		msgs[i] = issue.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the individual issues.
+func (e *ConfigError) Unwrap() []error {
+	errs := make([]error, len(e.Issues))
+	for i, issue := range e.Issues {
+		errs[i] = issue
+	}
+	return errs
+}
+
+// Validate checks that the configuration can work. It returns a *ConfigError
+// listing every error found by Preflight; warnings are not returned.
+func (c *Config) Validate() error {
+	var errs []ConfigIssue
+	for _, issue := range c.Preflight() {
+		if issue.Severity == IssueError {
+			errs = append(errs, issue)
+		}
+	}
+	if len(errs) > 0 {
+		return &ConfigError{Issues: errs}
+	}
+	return nil
+}
+
+// Preflight inspects the configuration without connecting and reports missing
+// required settings, missing files and contradictory options, each with a
+// fix hint. It returns nil if nothing was found.
+func (c *Config) Preflight() []ConfigIssue {
+	var p preflight
+
+	if c.Host != nil && c.Transport != TransportWSMan {
+		p.warn("Host", "host calls are only supported over WSMan; prompting commands will fail", "use TransportWSMan, or avoid Read-Host and prompts")
+	}
+	if c.HostInfo != nil {
+		if c.HostInfo.BufferSize.Width <= 0 || c.HostInfo.WindowSize.Width <= 0 {
+			p.error("HostInfo", "BufferSize and WindowSize must have a positive width", "set both widths, e.g. 120, or leave HostInfo nil")
+		} else if c.Host == nil {
+			p.warn("HostInfo", "HostInfo is only sent with a Host", "set Host, or leave HostInfo nil")
+		}
+	}
+
+	for k := range c.Tags {
+		if k == "" {
+			p.error("Tags", "tag keys must not be empty", "remove the empty key from Tags")
+			break
+		}
+	}
+	if c.Authenticator != nil && c.Transport != TransportWSMan {
+		p.warn("Authenticator", "a custom authenticator is only used over WSMan", "use TransportWSMan, or clear Authenticator")
+	}
+	if c.LoadProfile && c.Transport != TransportProcess {
+		p.warn("LoadProfile", "profiles are only run by local process sessions", "dot-source the profile in the script instead")
+	}
+	if c.SendTagsToServer && c.Transport != TransportWSMan {
+		p.warn("SendTagsToServer", "tags are only sent to the server over WSMan", "use TransportWSMan, or clear SendTagsToServer")
+	}
+	if len(c.ApplicationArguments) > 0 {
+		if _, ok := c.ApplicationArguments[""]; ok {
+			p.error("ApplicationArguments", "keys must not be empty", "remove the empty key from ApplicationArguments")
+		}
+		if err := powershell.ValidateApplicationArguments(c.ApplicationArguments); err != nil {
+			p.error("ApplicationArguments", err.Error(), "convert the value to a string or number")
+		}
+		if c.Transport != TransportWSMan {
+			p.warn("ApplicationArguments", "application arguments are only sent to the server over WSMan", "use TransportWSMan, or clear ApplicationArguments")
+		}
+	}
+	if c.MaxBandwidthBytesPerSec < 0 {
+		p.error("MaxBandwidthBytesPerSec", "the bandwidth cap must not be negative", "use 0 for no cap")
+	} else if c.MaxBandwidthBytesPerSec > 0 && (c.Transport == TransportProcess || c.Transport == TransportNamedPipe) {
+		p.warn("MaxBandwidthBytesPerSec", "local sessions are not rate limited", "clear MaxBandwidthBytesPerSec")
+	}
+	c.preflightLocale(&p)
+
+	switch c.Transport {
+	case TransportSSH:
+		c.preflightSSH(&p)
+		return p.issues
+	case TransportProcess, TransportNamedPipe:
+		c.preflightLocal(&p)
+		return p.issues
+	case TransportHvSocket:
+		c.preflightHvSocket(&p)
+	default:
+		c.preflightWSMan(&p)
+	}
+	if c.Authenticator != nil && c.Transport == TransportWSMan {
+		// The authenticator brings its own credentials
+		return p.issues
+	}
+	c.preflightCredentials(&p)
+	c.preflightKerberos(&p)
+	c.preflightSPN(&p)
+	c.preflightSSPI(&p)
+	return p.issues
+}
+
+// preflight collects issues.
+type preflight struct {
+	issues []ConfigIssue
+}
+
+func (p *preflight) error(field, problem, hint string) {
+	p.issues = append(p.issues, ConfigIssue{Severity: IssueError, Field: field, Problem: problem, Hint: hint})
+}
+
+func (p *preflight) warn(field, problem, hint string) {
+	p.issues = append(p.issues, ConfigIssue{Severity: IssueWarning, Field: field, Problem: problem, Hint: hint})
+}
+
+// checkFile reports an error if path is set but cannot be read.
+func (p *preflight) checkFile(field, path, hint string) bool {
+	if path == "" {
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			p.error(field, fmt.Sprintf("file %q does not exist", path), hint)
+		} else {
+			p.error(field, fmt.Sprintf("file %q is not readable: %v", path, err), hint)
+		}
+		return false
+	}
+	_ = f.Close()
+	return true
+}
+
+func (c *Config) preflightCredentials(p *preflight) {
+	if c.Username == "" {
+		if !auth.SupportsSSO() {
+			p.error("Username", "username is required", "set Username (SSO with the logged-in user is only available on Windows)")
+		}
+		return
+	}
+
+	// For Kerberos and Negotiate auth, password is optional if ccache or keytab is provided
+	usesKerberos := c.AuthType == AuthKerberos || c.AuthType == AuthNegotiate
+	if usesKerberos && (c.CCachePath != "" || c.KeytabPath != "") {
+		return
+	}
+	if c.Password == "" {
+		hint := "set Password"
+		if usesKerberos {
+			hint = "set Password, or KeytabPath/CCachePath for Kerberos"
+		}
+		p.error("Password", "password is required", hint)
+	}
+}
+
+func (c *Config) preflightKerberos(p *preflight) {
+	p.checkFile("KeytabPath", c.KeytabPath, "check the path, or leave KeytabPath empty to use a password")
+	p.checkFile("CCachePath", c.CCachePath, "run kinit to create the cache, or leave CCachePath empty")
+	p.checkFile("Krb5ConfPath", c.Krb5ConfPath, "check the path, or leave it empty to use $KRB5_CONFIG or /etc/krb5.conf")
+
+	if c.AuthType != AuthKerberos && c.AuthType != AuthNegotiate {
+		if c.KeytabPath != "" || c.CCachePath != "" {
+			p.warn("AuthType", "keytab/ccache are only used with Kerberos", "set AuthType to AuthKerberos or AuthNegotiate")
+		}
+		if c.KerberosDelegate {
+			p.warn("KerberosDelegate", "delegation is only used with Kerberos", "set AuthType to AuthKerberos or AuthNegotiate")
+		}
+		return
+	}
+	if !auth.KerberosAvailable() {
+		if c.AuthType == AuthKerberos {
+			p.error("AuthType", "Kerberos is not available in this build (psrp_nokrb5)", "build without psrp_nokrb5, register a provider with auth.RegisterKerberosProvider, or use AuthNTLM")
+		} else {
+			p.warn("AuthType", "Kerberos is not available in this build (psrp_nokrb5); Negotiate uses NTLM", "build without psrp_nokrb5, or register a provider with auth.RegisterKerberosProvider")
+		}
+		return
+	}
+	if c.KerberosDelegate && runtime.GOOS != "windows" {
+		p.error("KerberosDelegate", "Kerberos credential delegation is only supported on Windows (SSPI)", "run from Windows, or use a second-hop alternative such as passing credentials to the remote command")
+	}
+
+	// Windows uses SSPI, which resolves the realm through the domain.
+	if runtime.GOOS == "windows" || c.Krb5ConfPath != "" {
+		return
+	}
+
+	if c.Realm == "" && !strings.Contains(c.Username, "@") && !defaultKrb5ConfExists() {
+		problem := "no realm: Realm is empty, Username has no @REALM and no krb5.conf was found"
+		hint := "set Realm or Krb5ConfPath"
+		if c.AuthType == AuthKerberos {
+			p.error("Realm", problem, hint)
+		} else {
+			p.warn("Realm", problem+"; Negotiate will fall back to NTLM", hint)
+		}
+	}
+}
+
+func (c *Config) preflightSPN(p *preflight) {
+	if strings.Contains(c.SPNService, "/") {
+		p.error("SPNService", fmt.Sprintf("service class %q contains a slash", c.SPNService), `set only the service class, e.g. "HTTP", or the whole SPN in TargetSPN`)
+	}
+	if c.TargetSPN != "" && (c.SPNService != "" || c.SPNHostnameOverride != "" || c.SPNCanonicalize != 0) {
+		p.warn("TargetSPN", "TargetSPN overrides SPNService, SPNHostnameOverride and SPNCanonicalize", "clear TargetSPN to build the SPN from the other settings")
+	}
+}
+
+func (c *Config) preflightSSPI(p *preflight) {
+	if !c.UseSSPI {
+		return
+	}
+	if runtime.GOOS != "windows" {
+		p.error("UseSSPI", "SSPI is only available on Windows", "clear UseSSPI to use go-ntlmssp/gokrb5 with explicit credentials")
+		return
+	}
+	if c.AuthType != AuthNTLM && c.AuthType != AuthNegotiate {
+		p.warn("UseSSPI", "UseSSPI only applies to NTLM and Negotiate", "set AuthType to AuthNTLM or AuthNegotiate; Kerberos always uses SSPI on Windows")
+	}
+}
+
+// defaultKrb5ConfExists reports whether $KRB5_CONFIG or /etc/krb5.conf exists.
+func defaultKrb5ConfExists() bool {
+	path := os.Getenv("KRB5_CONFIG")
+	if path == "" {
+		path = "/etc/krb5.conf"
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (c *Config) preflightWSMan(p *preflight) {
+	if c.VMID != "" {
+		p.warn("VMID", "VMID is ignored by the WSMan transport", "set Transport to TransportHvSocket, or clear VMID")
+	}
+
+	if c.AuthType == AuthBasic && !c.UseTLS {
+		p.warn("AuthType", "Basic sends the password unencrypted over HTTP and requires AllowUnencrypted=true on the server",
+			"set UseTLS=true, or use AuthNegotiate/AuthNTLM which encrypt messages over HTTP")
+	}
+	if c.EnableCBT && !c.UseTLS {
+		p.warn("EnableCBT", "channel binding requires HTTPS and has no effect over HTTP", "set UseTLS=true")
+	}
+	if c.EnableCBT && c.AuthType == AuthBasic {
+		p.warn("EnableCBT", "channel binding is not used with Basic authentication", "use AuthNTLM or AuthNegotiate")
+	}
+
+	switch {
+	case c.UseTLS && c.Port == 5985:
+		p.warn("Port", "5985 is the WinRM HTTP port but UseTLS is set", "set Port to 5986 (the HTTPS listener) or disable UseTLS")
+	case !c.UseTLS && c.Port == 5986:
+		p.warn("Port", "5986 is the WinRM HTTPS port but UseTLS is not set", "set UseTLS=true or Port to 5985")
+	case c.Port < 0 || c.Port > 65535:
+		p.error("Port", fmt.Sprintf("invalid port %d", c.Port), "use a port between 1 and 65535")
+	}
+
+	if c.ProxyURL != "" && c.ProxyURL != "direct" {
+		if u, err := url.Parse(c.ProxyURL); err != nil || u.Host == "" {
+			p.error("ProxyURL", fmt.Sprintf("invalid proxy URL %q", c.ProxyURL), "use the form http://proxy.example.com:8080")
+		} else if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" && u.Scheme != "socks5h" {
+			p.error("ProxyURL", fmt.Sprintf("unsupported proxy scheme %q", u.Scheme), "use http, https, socks5 or socks5h")
+		}
+	}
+	if c.ProxyUsername == "" && c.ProxyPassword != "" {
+		p.error("ProxyUsername", "ProxyPassword is set without ProxyUsername", "set ProxyUsername, or put the credentials in ProxyURL")
+	}
+	if c.ProxyUsername != "" && c.ProxyURL == "direct" {
+		p.warn("ProxyUsername", "proxy credentials are ignored when ProxyURL is \"direct\"", "remove ProxyUsername and ProxyPassword")
+	}
+
+	if c.GatewayAddress != "" {
+		if host, port, err := net.SplitHostPort(c.GatewayAddress); err != nil || host == "" || port == "" {
+			p.error("GatewayAddress", fmt.Sprintf("invalid gateway address %q", c.GatewayAddress), "use the form gateway.example.com:443")
+		}
+		if c.ProxyURL != "" && c.ProxyURL != "direct" {
+			p.error("GatewayAddress", "a gateway cannot be used through a proxy", "clear ProxyURL, or GatewayAddress")
+		}
+	}
+	for from, to := range c.HostAliases {
+		if strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+			p.error("HostAliases", "host alias entries must not be empty", "map each IP address to a host name")
+			break
+		}
+		if strings.ContainsAny(to, "/:") {
+			p.error("HostAliases", fmt.Sprintf("alias %q for %s is not a host name", to, from), "use the bare host name, e.g. web01.corp.com")
+			break
+		}
+	}
+	if c.TLS != nil {
+		c.preflightTLS(p)
+	}
+	if len(c.PinnedCertSHA256) > 0 || c.KnownCertsFile != "" {
+		c.preflightCertPins(p)
+	}
+	if (c.TLSServerName != "" || len(c.TLSNextProtos) > 0) && !c.UseTLS {
+		p.warn("TLSServerName", "SNI and ALPN settings are only used over HTTPS", "set UseTLS=true")
+	}
+	for _, proto := range c.TLSNextProtos {
+		if proto == "h2" {
+			p.error("TLSNextProtos", "WSMan does not support HTTP/2", `offer "http/1.1" instead of "h2"`)
+			break
+		}
+	}
+}
+
+func (c *Config) preflightTLS(p *preflight) {
+	t := c.TLS
+	if !c.UseTLS {
+		p.warn("TLS", "the TLS configuration is only used over HTTPS", "set UseTLS=true")
+	}
+	if t.MaxVersion != 0 && t.MaxVersion < tls.VersionTLS12 {
+		p.error("TLS", fmt.Sprintf("MaxVersion %s is below the TLS 1.2 minimum", tls.VersionName(t.MaxVersion)), "set MaxVersion to tls.VersionTLS12 or higher, or leave it 0")
+	} else if t.MaxVersion != 0 && t.MinVersion > t.MaxVersion {
+		p.error("TLS", "MinVersion is above MaxVersion", "lower MinVersion or raise MaxVersion")
+	}
+	if len(t.CipherSuites) > 0 {
+		for _, suite := range tls.InsecureCipherSuites() {
+			if slices.Contains(t.CipherSuites, suite.ID) {
+				p.warn("TLS", fmt.Sprintf("cipher suite %s is insecure", suite.Name), "remove it from CipherSuites")
+				break
+			}
+		}
+		if t.MaxVersion == 0 || t.MaxVersion > tls.VersionTLS12 {
+			p.warn("TLS", "CipherSuites only apply up to TLS 1.2; TLS 1.3 suites are not configurable", "set MaxVersion to tls.VersionTLS12 to enforce them")
+		}
+	}
+	if t.InsecureSkipVerify && t.VerifyPeerCertificate == nil {
+		p.warn("TLS", "certificate verification is disabled (TLS.InsecureSkipVerify)", "set RootCAs to the CA of the server certificates instead")
+	}
+}
+
+func (c *Config) preflightCertPins(p *preflight) {
+	if !c.UseTLS {
+		p.warn("PinnedCertSHA256", "certificate pinning is only used over HTTPS", "set UseTLS=true")
+	}
+	for _, pin := range c.PinnedCertSHA256 {
+		if _, err := parseFingerprint(pin); err != nil {
+			p.error("PinnedCertSHA256", err.Error(), "use the 64 hex digits of: openssl x509 -noout -fingerprint -sha256")
+			break
+		}
+	}
+	if len(c.PinnedCertSHA256) > 0 && c.KnownCertsFile != "" {
+		p.warn("KnownCertsFile", "KnownCertsFile is ignored when PinnedCertSHA256 is set", "remove one of them")
+	}
+	if c.InsecureSkipVerify {
+		p.warn("InsecureSkipVerify", "InsecureSkipVerify is not needed with certificate pinning", "set InsecureSkipVerify=false")
+	}
+}
+
+func (c *Config) preflightHvSocket(p *preflight) {
+	if c.VMID == "" {
+		p.error("VMID", "VMID is required for TransportHvSocket", "set VMID to the VM GUID (Get-VM | Select-Object Id)")
+	} else if _, err := uuid.Parse(c.VMID); err != nil {
+		p.error("VMID", fmt.Sprintf("invalid VM GUID %q", c.VMID), "set VMID to the VM GUID (Get-VM | Select-Object Id)")
+	}
+	if runtime.GOOS != "windows" {
+		p.error("Transport", "HvSocket is only supported on Windows", "use TransportWSMan, TransportSSH or TransportProcess")
+	}
+
+	// DefaultConfig sets Port to 5985, so only flag explicit changes.
+	if c.Port != 0 && c.Port != 5985 {
+		p.warn("Port", "Port is ignored by the HvSocket transport", "leave Port at its default")
+	}
+	if c.UseTLS || c.TLS != nil || len(c.PinnedCertSHA256) > 0 || c.KnownCertsFile != "" || c.EnableCBT || c.ProxyURL != "" || c.GatewayAddress != "" || c.DialContext != nil {
+		p.warn("UseTLS", "TLS, CBT, proxy, gateway and dialer settings are ignored by the HvSocket transport", "remove them from the HvSocket configuration")
+	}
+	if c.AuthType == AuthKerberos {
+		p.warn("AuthType", "HvSocket authenticates with username and password only", "leave AuthType at its default")
+	}
+}
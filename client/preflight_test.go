@@ -0,0 +1,595 @@
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrp/wsman/auth"
+)
+
+// findIssue returns the first issue for field, or nil.
+func findIssue(issues []ConfigIssue, field string) *ConfigIssue {
+	for i := range issues {
+		if issues[i].Field == field {
+			return &issues[i]
+		}
+	}
+	return nil
+}
+
+func TestConfig_Preflight(t *testing.T) {
+	dir := t.TempDir()
+	keytab := filepath.Join(dir, "user.keytab")
+	if err := os.WriteFile(keytab, []byte("keytab"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		name      string
+		cfg       func() Config
+		field     string
+		severity  IssueSeverity
+		wantIssue bool
+	}{
+		{
+			name: "defaults",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password = "u", "p"
+				return c
+			},
+			field: "AuthType",
+			// Negotiate falls back to NTLM in psrp_nokrb5 builds
+			severity:  IssueWarning,
+			wantIssue: !auth.KerberosAvailable(),
+		},
+		{
+			name: "basic without TLS",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.AuthType = "u", "p", AuthBasic
+				return c
+			},
+			field:     "AuthType",
+			severity:  IssueWarning,
+			wantIssue: true,
+		},
+		{
+			name: "TLS on HTTP port",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.UseTLS = "u", "p", true
+				return c
+			},
+			field:     "Port",
+			severity:  IssueWarning,
+			wantIssue: true,
+		},
+		{
+			name: "missing keytab",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.AuthType, c.KeytabPath = "u", AuthKerberos, missing
+				return c
+			},
+			field:     "KeytabPath",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "existing keytab",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.AuthType, c.KeytabPath, c.Realm = "u", AuthKerberos, keytab, "CORP.COM"
+				return c
+			},
+			field:     "KeytabPath",
+			wantIssue: false,
+		},
+		{
+			name: "delegation without Kerberos",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.AuthType, c.KerberosDelegate = "u", "p", AuthNTLM, true
+				return c
+			},
+			field:     "KerberosDelegate",
+			severity:  IssueWarning,
+			wantIssue: true,
+		},
+		{
+			name: "delegation with Kerberos",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.AuthType, c.KeytabPath, c.Realm = "u", AuthKerberos, keytab, "CORP.COM"
+				c.KerberosDelegate = true
+				return c
+			},
+			field:    "KerberosDelegate",
+			severity: IssueError,
+			// Without Kerberos, only AuthType is reported
+			wantIssue: runtime.GOOS != "windows" && auth.KerberosAvailable(),
+		},
+		{
+			name: "SSPI",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.AuthType, c.UseSSPI = "u", "p", AuthNTLM, true
+				return c
+			},
+			field:     "UseSSPI",
+			severity:  IssueError,
+			wantIssue: runtime.GOOS != "windows",
+		},
+		{
+			name: "custom authenticator without credentials",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Authenticator = auth.NewBasicAuth(auth.Credentials{Username: "u", Password: "p"})
+				return c
+			},
+			field:     "Username",
+			wantIssue: false,
+		},
+		{
+			name: "SPN service with slash",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.SPNService = "u", "p", "HTTP/web01"
+				return c
+			},
+			field:     "SPNService",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "TargetSPN with SPN options",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.TargetSPN, c.SPNHostnameOverride = "u", "p", "HTTP/web01", "cluster"
+				return c
+			},
+			field:     "TargetSPN",
+			severity:  IssueWarning,
+			wantIssue: true,
+		},
+		{
+			name: "missing krb5.conf",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.Krb5ConfPath = "u", "p", missing
+				return c
+			},
+			field:     "Krb5ConfPath",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "invalid proxy",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.ProxyURL = "u", "p", "proxy:8080"
+				return c
+			},
+			field:     "ProxyURL",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "SOCKS5 proxy",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.ProxyURL = "u", "p", "socks5h://bastion.example.com:1080"
+				c.ProxyUsername, c.ProxyPassword = "pu", "pp"
+				return c
+			},
+			field:     "ProxyURL",
+			wantIssue: false,
+		},
+		{
+			name: "unsupported proxy scheme",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.ProxyURL = "u", "p", "ftp://proxy.example.com"
+				return c
+			},
+			field:     "ProxyURL",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "proxy password without username",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.ProxyPassword = "u", "p", "pp"
+				return c
+			},
+			field:     "ProxyUsername",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "TLS config",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.UseTLS, c.Port = "u", "p", true, 5986
+				c.TLS = &tls.Config{MinVersion: tls.VersionTLS13}
+				return c
+			},
+			field:     "TLS",
+			wantIssue: false,
+		},
+		{
+			name: "TLS max version below 1.2",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.UseTLS, c.Port = "u", "p", true, 5986
+				c.TLS = &tls.Config{MaxVersion: tls.VersionTLS11}
+				return c
+			},
+			field:     "TLS",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "TLS insecure cipher suite",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.UseTLS, c.Port = "u", "p", true, 5986
+				c.TLS = &tls.Config{
+					MaxVersion:   tls.VersionTLS12,
+					CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_RC4_128_SHA},
+				}
+				return c
+			},
+			field:     "TLS",
+			severity:  IssueWarning,
+			wantIssue: true,
+		},
+		{
+			name: "pinned certificate",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.UseTLS, c.Port = "u", "p", true, 5986
+				c.PinnedCertSHA256 = []string{strings.Repeat("AB:", 31) + "AB"}
+				return c
+			},
+			field:     "PinnedCertSHA256",
+			wantIssue: false,
+		},
+		{
+			name: "invalid pinned certificate",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.UseTLS, c.Port = "u", "p", true, 5986
+				c.PinnedCertSHA256 = []string{"3A:7F"}
+				return c
+			},
+			field:     "PinnedCertSHA256",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "gateway",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.UseTLS, c.Port = "u", "p", true, 5986
+				c.GatewayAddress, c.TLSNextProtos = "gw.example.com:443", []string{"http/1.1"}
+				return c
+			},
+			field:     "GatewayAddress",
+			wantIssue: false,
+		},
+		{
+			name: "gateway without port",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.GatewayAddress = "u", "p", "gw.example.com"
+				return c
+			},
+			field:     "GatewayAddress",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "gateway through proxy",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.GatewayAddress = "u", "p", "gw.example.com:443"
+				c.ProxyURL = "http://proxy.example.com:8080"
+				return c
+			},
+			field:     "GatewayAddress",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "SNI without TLS",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.TLSServerName = "u", "p", "route-a.example.com"
+				return c
+			},
+			field:     "TLSServerName",
+			severity:  IssueWarning,
+			wantIssue: true,
+		},
+		{
+			name: "ALPN h2",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password, c.UseTLS, c.Port = "u", "p", true, 5986
+				c.TLSNextProtos = []string{"h2", "http/1.1"}
+				return c
+			},
+			field:     "TLSNextProtos",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "host alias",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password = "u", "p"
+				c.HostAliases = map[string]string{"10.0.0.5": "web01.corp.com"}
+				return c
+			},
+			field:     "HostAliases",
+			wantIssue: false,
+		},
+		{
+			name: "host alias with port",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password = "u", "p"
+				c.HostAliases = map[string]string{"10.0.0.5": "web01.corp.com:5986"}
+				return c
+			},
+			field:     "HostAliases",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "HvSocket invalid VMID",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Transport, c.Username, c.Password, c.VMID = TransportHvSocket, "u", "p", "not-a-guid"
+				return c
+			},
+			field:     "VMID",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "HvSocket with port",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Transport, c.Username, c.Password, c.Port = TransportHvSocket, "u", "p", 5986
+				c.VMID = "12345678-1234-1234-1234-123456789abc"
+				return c
+			},
+			field:     "Port",
+			severity:  IssueWarning,
+			wantIssue: true,
+		},
+		{
+			name: "host over HvSocket",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Transport, c.Username, c.Password = TransportHvSocket, "u", "p"
+				c.VMID = "12345678-1234-1234-1234-123456789abc"
+				c.Host = &scriptedHost{}
+				return c
+			},
+			field:     "Host",
+			severity:  IssueWarning,
+			wantIssue: true,
+		},
+		{
+			name: "empty tag key",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password = "u", "p"
+				c.Tags = map[string]string{"": "x"}
+				return c
+			},
+			field:     "Tags",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "host over WSMan",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password = "u", "p"
+				c.Host = &scriptedHost{}
+				return c
+			},
+			field:     "Host",
+			wantIssue: false,
+		},
+		{
+			name: "host info width zero",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password = "u", "p"
+				c.Host = &scriptedHost{}
+				c.HostInfo = &powershell.HostInfo{WindowSize: powershell.Size{Width: 80, Height: 25}}
+				return c
+			},
+			field:     "HostInfo",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "host info without host",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password = "u", "p"
+				info := powershell.DefaultHostInfo
+				c.HostInfo = &info
+				return c
+			},
+			field:     "HostInfo",
+			severity:  IssueWarning,
+			wantIssue: true,
+		},
+		{
+			name: "load profile on a remote session",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password = "u", "p"
+				c.LoadProfile = true
+				return c
+			},
+			field:     "LoadProfile",
+			severity:  IssueWarning,
+			wantIssue: true,
+		},
+		{
+			name: "load profile on a process session",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Transport = TransportProcess
+				c.LoadProfile = true
+				return c
+			},
+			field:     "LoadProfile",
+			wantIssue: false,
+		},
+		{
+			name: "negative bandwidth cap",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password = "u", "p"
+				c.MaxBandwidthBytesPerSec = -1
+				return c
+			},
+			field:     "MaxBandwidthBytesPerSec",
+			severity:  IssueError,
+			wantIssue: true,
+		},
+		{
+			name: "bandwidth cap on a process session",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Transport = TransportProcess
+				c.MaxBandwidthBytesPerSec = 1 << 20
+				return c
+			},
+			field:     "MaxBandwidthBytesPerSec",
+			severity:  IssueWarning,
+			wantIssue: true,
+		},
+		{
+			name: "bandwidth cap over WSMan",
+			cfg: func() Config {
+				c := DefaultConfig()
+				c.Username, c.Password = "u", "p"
+				c.MaxBandwidthBytesPerSec = 1 << 20
+				return c
+			},
+			field:     "MaxBandwidthBytesPerSec",
+			wantIssue: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg()
+			issue := findIssue(cfg.Preflight(), tt.field)
+			if (issue != nil) != tt.wantIssue {
+				t.Fatalf("issue for %s = %v, want issue %v", tt.field, issue, tt.wantIssue)
+			}
+			if issue == nil {
+				return
+			}
+			if issue.Severity != tt.severity {
+				t.Errorf("Severity = %v, want %v", issue.Severity, tt.severity)
+			}
+			if issue.Hint == "" {
+				t.Error("issue has no fix hint")
+			}
+		})
+	}
+}
+
+func TestConfig_Preflight_KerberosRealm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("realm is resolved by SSPI on Windows")
+	}
+	if !auth.KerberosAvailable() {
+		t.Skip("Kerberos is not available in this build")
+	}
+	t.Setenv("KRB5_CONFIG", filepath.Join(t.TempDir(), "missing.conf"))
+
+	cfg := DefaultConfig()
+	cfg.Username, cfg.Password = "user", "pass"
+
+	cfg.AuthType = AuthKerberos
+	if issue := findIssue(cfg.Preflight(), "Realm"); issue == nil || issue.Severity != IssueError {
+		t.Errorf("Kerberos without realm: issue = %v, want error", issue)
+	}
+
+	cfg.AuthType = AuthNegotiate
+	if issue := findIssue(cfg.Preflight(), "Realm"); issue == nil || issue.Severity != IssueWarning {
+		t.Errorf("Negotiate without realm: issue = %v, want warning", issue)
+	}
+
+	cfg.Username = "user@CORP.COM"
+	if issue := findIssue(cfg.Preflight(), "Realm"); issue != nil {
+		t.Errorf("realm from UPN: unexpected issue %v", issue)
+	}
+}
+
+func TestConfig_Validate_MultiError(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AuthType = AuthBasic
+	cfg.KeytabPath = filepath.Join(t.TempDir(), "missing.keytab")
+
+	err := cfg.Validate()
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("Validate() error = %v, want *ConfigError", err)
+	}
+	for _, issue := range cfgErr.Issues {
+		if issue.Severity != IssueError {
+			t.Errorf("Validate() returned a %v: %v", issue.Severity, issue)
+		}
+	}
+	if findIssue(cfgErr.Issues, "KeytabPath") == nil {
+		t.Error("missing keytab not reported")
+	}
+	if findIssue(cfgErr.Issues, "AuthType") != nil {
+		t.Error("Basic-over-HTTP warning returned as an error")
+	}
+
+	var issue ConfigIssue
+	if !errors.As(err, &issue) {
+		t.Error("errors.As(ConfigIssue) failed")
+	}
+	if !strings.Contains(err.Error(), "(") || !strings.Contains(err.Error(), "; ") {
+		t.Errorf("Error() = %q, want joined issues with hints", err.Error())
+	}
+}
+
+func TestConfig_Preflight_ApplicationArguments(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Username, cfg.Password = "u", "p"
+	cfg.ApplicationArguments = map[string]interface{}{"tenant": "contoso", "limits": []int{1, 2}}
+	if issue := findIssue(cfg.Preflight(), "ApplicationArguments"); issue != nil {
+		t.Errorf("valid ApplicationArguments reported: %v", issue)
+	}
+
+	cfg.ApplicationArguments["callback"] = func() {}
+	if issue := findIssue(cfg.Preflight(), "ApplicationArguments"); issue == nil || issue.Severity != IssueError {
+		t.Errorf("ApplicationArguments issue = %v, want an error for a func value", issue)
+	}
+}
@@ -0,0 +1,67 @@
+//go:build integration && !windows
+// +build integration,!windows
+
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrp/client"
+)
+
+// TestProcessSession_NoProfile runs a local pwsh session next to profile
+// scripts that print a banner and load PSReadLine, and checks that neither
+// reaches the output.
+func TestProcessSession_NoProfile(t *testing.T) {
+	if _, err := exec.LookPath("pwsh"); err != nil {
+		t.Skip("pwsh not found on PATH")
+	}
+
+	home := t.TempDir()
+	profileDir := filepath.Join(home, ".config", "powershell")
+	if err := os.MkdirAll(profileDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	profile := "Write-Output 'PROFILE BANNER'\nWrite-Host 'PROFILE BANNER'\nImport-Module PSReadLine\n"
+	for _, name := range []string{"profile.ps1", "Microsoft.PowerShell_profile.ps1"} {
+		if err := os.WriteFile(filepath.Join(profileDir, name), []byte(profile), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	cfg := client.DefaultConfig()
+	cfg.Transport = client.TransportProcess
+	// No -NoProfile: the client adds it.
+	cfg.Process = &client.ProcessOptions{Args: []string{"-s", "-NoLogo"}}
+
+	c, err := client.New("", cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer c.Close(ctx)
+
+	result, err := c.Execute(ctx, "'ok'; [bool](Get-Module PSReadLine)")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := fmt.Sprint(result.Output); got != "[ok false]" {
+		t.Errorf("Output = %s, want [ok false]", got)
+	}
+	if len(result.Information) > 0 || len(result.Warnings) > 0 || len(result.Errors) > 0 {
+		t.Errorf("unexpected streams: information %v, warnings %v, errors %v",
+			result.Information, result.Warnings, result.Errors)
+	}
+}
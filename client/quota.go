@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned when the execution quota is exhausted and the
+// quota is configured to reject calls.
+var ErrQuotaExceeded = errors.New("client: execution quota exceeded")
+
+// QuotaMode specifies what happens to calls made while the quota is exhausted.
+type QuotaMode int
+
+const (
+	// QuotaReject fails calls immediately with ErrQuotaExceeded.
+	QuotaReject QuotaMode = iota
+	// QuotaQueue blocks calls until the window frees a slot or the context is done.
+	QuotaQueue
+)
+
+// QuotaPolicy configures a client-side execution quota (e.g., "max N ops/minute per target").
+type QuotaPolicy struct {
+	// Enabled activates the quota.
+	Enabled bool
+
+	// MaxCommands is the maximum number of Execute calls allowed per Window.
+	MaxCommands int
+
+	// Window is the sliding time window for MaxCommands.
+	// Default: 1 minute.
+	Window time.Duration
+
+	// Mode selects reject or queue behavior when the quota is exhausted.
+	// Default: QuotaReject.
+	Mode QuotaMode
+}
+
+// QuotaStats reports execution quota counters.
+type QuotaStats struct {
+	// Allowed is the number of calls admitted by the quota.
+	Allowed uint64
+	// Rejected is the number of calls rejected with ErrQuotaExceeded
+	// (or abandoned while queued because the context was done).
+	Rejected uint64
+	// Queued is the number of calls that had to wait for a free slot.
+	Queued uint64
+}
+
+// executionQuota implements a sliding-window rate limit on command execution.
+type executionQuota struct {
+	mu sync.Mutex
+
+	max    int
+	window time.Duration
+	mode   QuotaMode
+	clock  Clock
+
+	// admitted holds the admission times within the current window (oldest first)
+	admitted []time.Time
+	stats    QuotaStats
+}
+
+// newExecutionQuota creates a quota from the policy.
+// Returns nil if the policy is nil, disabled, or has no limit.
+func newExecutionQuota(policy *QuotaPolicy) *executionQuota {
+	if policy == nil || !policy.Enabled || policy.MaxCommands <= 0 {
+		return nil
+	}
+	window := policy.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+	return &executionQuota{
+		max:    policy.MaxCommands,
+		window: window,
+		mode:   policy.Mode,
+		clock:  realClock{},
+	}
+}
+
+// Wait admits a call or, depending on the mode, rejects it or blocks until a slot frees up.
+func (q *executionQuota) Wait(ctx context.Context) error {
+	queued := false
+	for {
+		wait := q.reserve(queued)
+		if wait == 0 {
+			return nil
+		}
+
+		if q.mode != QuotaQueue {
+			q.mu.Lock()
+			q.stats.Rejected++
+			q.mu.Unlock()
+			return ErrQuotaExceeded
+		}
+		queued = true
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			q.mu.Lock()
+			q.stats.Rejected++
+			q.mu.Unlock()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve admits the call if a slot is free and returns 0.
+// Otherwise it returns how long until the oldest admission leaves the window.
+func (q *executionQuota) reserve(queued bool) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.clock.Now()
+	cutoff := now.Add(-q.window)
+	expired := 0
+	for expired < len(q.admitted) && !q.admitted[expired].After(cutoff) {
+		expired++
+	}
+	q.admitted = q.admitted[expired:]
+
+	if len(q.admitted) < q.max {
+		q.admitted = append(q.admitted, now)
+		q.stats.Allowed++
+		return 0
+	}
+
+	if !queued && q.mode == QuotaQueue {
+		q.stats.Queued++
+	}
+	wait := q.admitted[0].Add(q.window).Sub(now)
+	if wait <= 0 {
+		wait = time.Millisecond
+	}
+	return wait
+}
+
+// Stats returns a snapshot of the quota counters.
+func (q *executionQuota) Stats() QuotaStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stats
+}
+
+// QuotaStats returns the execution quota counters.
+// Returns zero stats if no quota is configured.
+func (c *Client) QuotaStats() QuotaStats {
+	if c.quota == nil {
+		return QuotaStats{}
+	}
+	return c.quota.Stats()
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewExecutionQuota_Disabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *QuotaPolicy
+	}{
+		{"Nil", nil},
+		{"Disabled", &QuotaPolicy{Enabled: false, MaxCommands: 5}},
+		{"NoLimit", &QuotaPolicy{Enabled: true, MaxCommands: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if q := newExecutionQuota(tt.policy); q != nil {
+				t.Errorf("newExecutionQuota() = %v, want nil", q)
+			}
+		})
+	}
+}
+
+func TestExecutionQuota_Reject(t *testing.T) {
+	clock := newMockClock(time.Now())
+	q := newExecutionQuota(&QuotaPolicy{Enabled: true, MaxCommands: 2, Window: time.Minute})
+	q.clock = clock
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := q.Wait(ctx); err != nil {
+			t.Fatalf("Wait() call %d error = %v", i+1, err)
+		}
+	}
+
+	if err := q.Wait(ctx); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Wait() over quota error = %v, want ErrQuotaExceeded", err)
+	}
+
+	// Slots free up once the window has passed
+	clock.Advance(time.Minute + time.Second)
+	if err := q.Wait(ctx); err != nil {
+		t.Fatalf("Wait() after window error = %v", err)
+	}
+
+	stats := q.Stats()
+	if stats.Allowed != 3 || stats.Rejected != 1 || stats.Queued != 0 {
+		t.Errorf("Stats() = %+v, want Allowed=3 Rejected=1 Queued=0", stats)
+	}
+}
+
+func TestExecutionQuota_Queue(t *testing.T) {
+	q := newExecutionQuota(&QuotaPolicy{
+		Enabled:     true,
+		MaxCommands: 1,
+		Window:      50 * time.Millisecond,
+		Mode:        QuotaQueue,
+	})
+	ctx := context.Background()
+
+	if err := q.Wait(ctx); err != nil {
+		t.Fatalf("first Wait() error = %v", err)
+	}
+
+	start := time.Now()
+	if err := q.Wait(ctx); err != nil {
+		t.Fatalf("queued Wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("queued Wait() returned after %v, expected to wait for the window", elapsed)
+	}
+
+	stats := q.Stats()
+	if stats.Allowed != 2 || stats.Queued != 1 {
+		t.Errorf("Stats() = %+v, want Allowed=2 Queued=1", stats)
+	}
+}
+
+func TestExecutionQuota_QueueContextCancelled(t *testing.T) {
+	q := newExecutionQuota(&QuotaPolicy{
+		Enabled:     true,
+		MaxCommands: 1,
+		Window:      time.Hour,
+		Mode:        QuotaQueue,
+	})
+
+	if err := q.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	if stats := q.Stats(); stats.Rejected != 1 {
+		t.Errorf("Stats().Rejected = %d, want 1", stats.Rejected)
+	}
+}
+
+func TestClient_Execute_QuotaExceeded(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Quota = &QuotaPolicy{Enabled: true, MaxCommands: 1}
+	c := &Client{config: cfg, quota: newExecutionQuota(cfg.Quota)}
+
+	// Consume the only slot
+	if err := c.quota.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	if _, err := c.Execute(context.Background(), "Get-Date"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Execute() error = %v, want ErrQuotaExceeded", err)
+	}
+	if stats := c.QuotaStats(); stats.Rejected != 1 {
+		t.Errorf("QuotaStats().Rejected = %d, want 1", stats.Rejected)
+	}
+}
@@ -3,12 +3,25 @@ package client
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
 	"math/rand/v2"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrp/wsman"
 )
 
+// ErrSessionLost is returned by commands that failed because the server no
+// longer has the session, typically after the WinRM service restarted.
+// With Reconnect.Enabled the client then creates a new session for later
+// commands and calls Reconnect.OnSessionLost.
+var ErrSessionLost = errors.New("client: session lost on the server")
+
 // reconnectManager handles automatic reconnection with exponential backoff.
 type reconnectManager struct {
 	client *Client
@@ -18,6 +31,10 @@ type reconnectManager struct {
 	running   bool
 	stopCh    chan struct{}
 	stoppedCh chan struct{}
+
+	// reconnecting is set while attempts are in progress; Health reports
+	// HealthReconnecting.
+	reconnecting atomic.Bool
 }
 
 // newReconnectManager creates a reconnect manager for the given client.
@@ -106,7 +123,11 @@ func (rm *reconnectManager) checkAndReconnect() {
 	ctx, cancel := context.WithTimeout(context.Background(), rm.client.config.Timeout)
 	defer cancel()
 
+	rm.reconnecting.Store(true)
+	rm.client.notifyStateChange()
 	err := rm.attemptReconnectWithBackoff(ctx)
+	rm.reconnecting.Store(false)
+	rm.client.notifyStateChange()
 	if err != nil {
 		rm.client.logError("Reconnect: all attempts failed: %v", err)
 		// Log exhausted (NIST SP 800-92)
@@ -119,6 +140,7 @@ func (rm *reconnectManager) checkAndReconnect() {
 		rm.client.mu.Unlock()
 	} else {
 		rm.client.logInfo("Reconnect: successfully reconnected")
+		rm.client.stats.reconnects.Add(1)
 		// Log success (NIST SP 800-92)
 		rm.client.mu.Lock()
 		if rm.client.securityLogger != nil {
@@ -154,6 +176,12 @@ func (rm *reconnectManager) attemptReconnectWithBackoff(ctx context.Context) err
 		lastErr = err
 		rm.client.logWarn("Reconnect: attempt %d failed: %v", attempt, err)
 
+		// Retrying cannot fix a denied login, nor a shell the server
+		// deleted that could not be replaced
+		if f, ok := wsman.AsFault(err); ok && (f.IsAccessDenied() || f.IsShellNotFound()) {
+			return err
+		}
+
 		// Don't wait after the last attempt
 		if rm.policy.MaxAttempts > 0 && attempt >= rm.policy.MaxAttempts {
 			break
@@ -188,12 +216,71 @@ func (rm *reconnectManager) attemptReconnect(ctx context.Context) error {
 	if c.backend != nil {
 		shellID = c.backend.ShellID()
 	}
+	poolID := c.poolID
+	authRT := c.authRT
 	c.mu.Unlock()
 
+	// The session may have dropped because the Kerberos ticket expired;
+	// reconnecting with it would fail again on every attempt
+	if authRT != nil {
+		if err := authRT.refreshCredentials(); err != nil {
+			c.logWarn("Reconnect: refresh credentials: %v", err)
+		}
+	}
+
 	// Always use Reconnect, not Connect.
 	// Connect() checks c.connected and returns nil if already connected,
 	// but Reconnect() properly resets the pool even when c.connected is true.
-	return c.Reconnect(ctx, shellID)
+	err := c.Reconnect(ctx, shellID)
+	if isSessionLostError(err) {
+		return c.recreateSession(ctx, poolID, err)
+	}
+	return err
+}
+
+// isSessionLostError reports whether err means the server no longer has
+// the shell, as after a WinRM service restart.
+func isSessionLostError(err error) bool {
+	f, ok := wsman.AsFault(err)
+	return ok && f.IsShellNotFound()
+}
+
+// recreateSession replaces a session the server no longer has with a new
+// one and reports the loss through Reconnect.OnSessionLost and the
+// security log. poolID is the session that failed: if it has already been
+// replaced, recreateSession does nothing.
+func (c *Client) recreateSession(ctx context.Context, poolID uuid.UUID, cause error) error {
+	c.mu.Lock()
+	if c.closed || c.poolID != poolID {
+		c.mu.Unlock()
+		return nil
+	}
+	if !c.connected {
+		// Another caller is already creating the new session
+		c.mu.Unlock()
+		return c.Connect(ctx)
+	}
+	if b, ok := c.backend.(*powershell.WSManBackend); ok {
+		b.Abandon()
+	}
+	c.connected = false
+	if c.securityLogger != nil {
+		c.securityLogger.LogReconnection(SubtypeReconnSessionLost, OutcomeFailure, SeverityWarning, map[string]any{
+			"pool_id": poolID.String(),
+			"error":   cause.Error(),
+		})
+	}
+	onLost := c.config.Reconnect.OnSessionLost
+	c.mu.Unlock()
+
+	c.logWarn("Session lost on the server, creating a new one: %v", cause)
+	if onLost != nil {
+		onLost(cause)
+	}
+	if err := c.Connect(ctx); err != nil {
+		return fmt.Errorf("recreate session: %w", err)
+	}
+	return nil
 }
 
 // calculateBackoff returns the delay with optional jitter.
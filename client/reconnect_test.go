@@ -1,8 +1,15 @@
 package client
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/wsman"
 )
 
 func TestDefaultReconnectPolicy(t *testing.T) {
@@ -124,3 +131,57 @@ type testError struct {
 func (e *testError) Error() string {
 	return e.msg
 }
+
+// shellGone is the fault WinRM returns for a shell it no longer has, as
+// after a service restart.
+var shellGone = fmt.Errorf("receive: %w", &wsman.Fault{
+	Subcode:   "w:InvalidSelectors",
+	WSManCode: wsman.ErrorInvalidSelectors,
+	Reason:    "The request for the Windows Remote Shell failed because the shell was not found on the server.",
+})
+
+func TestIsSessionLostError(t *testing.T) {
+	if !isSessionLostError(shellGone) {
+		t.Error("isSessionLostError(shell not found) = false")
+	}
+	if isSessionLostError(fmt.Errorf("receive: %w", &wsman.Fault{Subcode: "w:TimedOut"})) {
+		t.Error("isSessionLostError(timeout) = true")
+	}
+	if isSessionLostError(errors.New("connection reset")) {
+		t.Error("isSessionLostError(network error) = true")
+	}
+}
+
+func TestRecreateSession(t *testing.T) {
+	poolID := uuid.New()
+	var lost []error
+	newClient := func() *Client {
+		return &Client{
+			poolID:    poolID,
+			connected: true,
+			config: Config{Reconnect: ReconnectPolicy{
+				Enabled:       true,
+				OnSessionLost: func(cause error) { lost = append(lost, cause) },
+			}},
+		}
+	}
+
+	// Already replaced by another caller
+	c := newClient()
+	if err := c.recreateSession(context.Background(), uuid.New(), shellGone); err != nil || len(lost) != 0 {
+		t.Fatalf("recreateSession(old pool) = %v, callbacks %d; want nothing done", err, len(lost))
+	}
+
+	// The new session cannot be created: without a WSMan client Connect fails
+	c = newClient()
+	err := c.recreateSession(context.Background(), poolID, shellGone)
+	if err == nil || !strings.Contains(err.Error(), "recreate session") {
+		t.Errorf("recreateSession() error = %v, want the connect failure", err)
+	}
+	if len(lost) != 1 || !errors.Is(lost[0], shellGone) {
+		t.Errorf("OnSessionLost calls = %v, want one with the cause", lost)
+	}
+	if c.IsConnected() {
+		t.Error("IsConnected() = true after the session was lost")
+	}
+}
@@ -0,0 +1,29 @@
+package client
+
+import "github.com/smnsjas/go-psrp/powershell"
+
+// ErrorRecords returns the error stream as typed records. Objects that are
+// not error records are skipped.
+func (r *Result) ErrorRecords() []*powershell.ErrorRecord {
+	return parseRecords(r.Errors, powershell.ParseErrorRecord)
+}
+
+// ProgressRecords returns the progress stream as typed records.
+func (r *Result) ProgressRecords() []*powershell.ProgressRecord {
+	return parseRecords(r.Progress, powershell.ParseProgressRecord)
+}
+
+// InformationRecords returns the information stream as typed records.
+func (r *Result) InformationRecords() []*powershell.InformationRecord {
+	return parseRecords(r.Information, powershell.ParseInformationRecord)
+}
+
+func parseRecords[T any](objs []interface{}, parse func(interface{}) (*T, bool)) []*T {
+	var records []*T
+	for _, obj := range objs {
+		if rec, ok := parse(obj); ok {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
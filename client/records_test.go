@@ -0,0 +1,38 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+func TestResult_Records(t *testing.T) {
+	r := &Result{
+		Errors: []interface{}{
+			"not a record",
+			&serialization.PSObject{Properties: map[string]interface{}{
+				"FullyQualifiedErrorId": "boom",
+				"Exception":             &serialization.PSObject{Properties: map[string]interface{}{"Message": "boom"}},
+			}},
+		},
+		Progress: []interface{}{
+			&serialization.PSObject{Properties: map[string]interface{}{"Activity": "Copying", "PercentComplete": int32(50)}},
+		},
+		Information: []interface{}{
+			&serialization.PSObject{Properties: map[string]interface{}{"MessageData": "hi", "Source": "Write-Information"}},
+		},
+	}
+
+	errs := r.ErrorRecords()
+	if len(errs) != 1 || errs[0].Message != "boom" || errs[0].FullyQualifiedErrorID != "boom" {
+		t.Errorf("ErrorRecords() = %+v, want the one error record", errs)
+	}
+	progress := r.ProgressRecords()
+	if len(progress) != 1 || progress[0].Activity != "Copying" || progress[0].PercentComplete != 50 {
+		t.Errorf("ProgressRecords() = %+v", progress)
+	}
+	info := r.InformationRecords()
+	if len(info) != 1 || info[0].Message() != "hi" || info[0].Source != "Write-Information" {
+		t.Errorf("InformationRecords() = %+v", info)
+	}
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/smnsjas/go-psrp/winrs"
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+// EndpointRecoveryPolicy configures the WinRS fallback used when the PSRP
+// endpoint itself is broken (e.g., a corrupted session configuration makes
+// every shell creation fail) while WinRM is still reachable.
+type EndpointRecoveryPolicy struct {
+	// Enabled activates recovery handling in Connect.
+	Enabled bool
+
+	// FailureThreshold is the number of consecutive shell creation failures
+	// before recovery runs. Only faults that point at a broken or missing
+	// session configuration count; authentication failures, exceeded
+	// quotas, timeouts and cancellations do not.
+	// Default: 2.
+	FailureThreshold int
+
+	// AutoRepair re-registers the session configuration and restarts WinRM
+	// via WinRS, then retries Connect once. If false, only diagnostics are run.
+	AutoRepair bool
+
+	// SettleTime is how long to wait after a repair for WinRM to restart
+	// before Connect is retried.
+	// Default: 15s.
+	SettleTime time.Duration
+
+	// OnEndpointFailure is called with the WinRS diagnostics output
+	// (nil if diagnostics could not be run) and the Connect error.
+	OnEndpointFailure func(diagnostics *CmdResult, err error)
+}
+
+// endpointError marks a failure to create the PSRP shell/RunspacePool after the
+// transport connected, which points at a broken endpoint rather than the network.
+type endpointError struct {
+	err error
+}
+
+func (e *endpointError) Error() string { return e.err.Error() }
+func (e *endpointError) Unwrap() error { return e.err }
+
+// backendInitError returns the Connect error for a failed backend Init,
+// marked as an endpointError if it points at a broken endpoint.
+func backendInitError(err error) error {
+	if isEndpointFault(err) {
+		err = &endpointError{err: err}
+	}
+	return fmt.Errorf("init backend: %w", err)
+}
+
+// isEndpointFault reports whether err, from creating the shell, is a fault
+// of a broken or missing session configuration: the resource URI names no
+// configuration, or its PowerShell plugin failed. Denied access, exceeded
+// quotas and timeouts are faults of a working endpoint, and other errors,
+// such as a 401 or a canceled context, do not come from the endpoint.
+func isEndpointFault(err error) bool {
+	f, ok := wsman.AsFault(err)
+	if !ok || f.IsAccessDenied() || f.Temporary() || f.IsShellNotFound() {
+		return false
+	}
+	if f.StatusCode == http.StatusUnauthorized || f.StatusCode == http.StatusForbidden {
+		return false
+	}
+	if f.Provider != nil {
+		return true
+	}
+	if strings.Contains(f.Subcode, "InvalidResourceURI") || strings.Contains(f.Subcode, "DestinationUnreachable") {
+		return true
+	}
+	text := strings.ToLower(f.Reason + " " + f.Message)
+	return strings.Contains(text, "session configuration") || strings.Contains(text, "plug-in")
+}
+
+// diagnoseScript collects the state of the remoting endpoint.
+const diagnoseScript = `Get-Service WinRM | Format-List Name,Status,StartType
+Get-PSSessionConfiguration -ErrorAction Continue | Format-List Name,Enabled,Permission,PSVersion
+winrm enumerate winrm/config/listener`
+
+// ExecuteRecovery runs a PowerShell script through powershell.exe in a WinRS
+// shell instead of the PSRP endpoint. Use it for diagnostics and repair when
+// the endpoint is broken (e.g., Restart-Service WinRM,
+// Unregister-PSSessionConfiguration). Only a WSMan connection is required.
+func (c *Client) ExecuteRecovery(ctx context.Context, script string) (*CmdResult, error) {
+	c.logInfo("ExecuteRecovery called: '%s'", sanitizeScriptForLogging(script))
+
+	var result *CmdResult
+	err := c.withWinRSShell(ctx, func(shell *winrs.Shell) error {
+		proc, err := shell.Run(ctx, "powershell.exe", "-NoProfile", "-NonInteractive",
+			"-EncodedCommand", encodePowerShellScript(script))
+		if err != nil {
+			return err
+		}
+		result = &CmdResult{
+			Stdout:   string(proc.Stdout()),
+			Stderr:   string(proc.Stderr()),
+			ExitCode: proc.ExitCode(),
+		}
+		return nil
+	})
+
+	if c.securityLogger != nil {
+		outcome := OutcomeSuccess
+		details := map[string]any{
+			"script": sanitizeScriptForLogging(script),
+			"mode":   "winrs_recovery",
+		}
+		if err != nil {
+			outcome = OutcomeFailure
+			details["error"] = err.Error()
+		}
+		c.securityLogger.LogCommand("recovery_execute", outcome, SeverityWarning, details)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("recovery: %w", err)
+	}
+	return result, nil
+}
+
+// DiagnoseEndpoint reports the state of the WinRM service, the registered
+// session configurations and the WinRM listeners via WinRS.
+func (c *Client) DiagnoseEndpoint(ctx context.Context) (*CmdResult, error) {
+	return c.ExecuteRecovery(ctx, diagnoseScript)
+}
+
+// RepairEndpoint re-registers the session configuration used by this client
+// and schedules a WinRM restart via WinRS. The restart runs detached after a
+// short delay so this call can complete before the service goes down.
+// Existing sessions on the server are terminated by the restart.
+func (c *Client) RepairEndpoint(ctx context.Context) (*CmdResult, error) {
+	name := c.config.ConfigurationName
+	if name == "" {
+		name = "Microsoft.PowerShell"
+	}
+	if strings.ContainsAny(name, "'\"`$;&|\r\n") {
+		return nil, fmt.Errorf("recovery: invalid configuration name %q", name)
+	}
+
+	restart := encodePowerShellScript("Start-Sleep -Seconds 2; Restart-Service WinRM -Force")
+	script := fmt.Sprintf(`$ErrorActionPreference = 'Stop'
+Unregister-PSSessionConfiguration -Name '%[1]s' -Force -NoServiceRestart -ErrorAction SilentlyContinue
+Register-PSSessionConfiguration -Name '%[1]s' -Force -NoServiceRestart | Out-Null
+Start-Process powershell.exe -WindowStyle Hidden -ArgumentList '-NoProfile','-EncodedCommand','%[2]s'`,
+		name, restart)
+
+	result, err := c.ExecuteRecovery(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return result, fmt.Errorf("recovery: repair failed with exit code %d: %s",
+			result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return result, nil
+}
+
+// handleEndpointFailure runs the configured recovery after a Connect failure.
+// It returns true if a repair was performed and Connect should be retried.
+func (c *Client) handleEndpointFailure(ctx context.Context, connectErr error) bool {
+	policy := c.config.EndpointRecovery
+	if policy == nil || !policy.Enabled || c.config.Transport != TransportWSMan {
+		return false
+	}
+
+	var epErr *endpointError
+	if !errors.As(connectErr, &epErr) {
+		return false
+	}
+
+	threshold := policy.FailureThreshold
+	if threshold <= 0 {
+		threshold = 2
+	}
+
+	c.mu.Lock()
+	c.endpointFailures++
+	failures := c.endpointFailures
+	c.mu.Unlock()
+
+	if failures < threshold {
+		return false
+	}
+
+	c.logWarn("PSRP endpoint failed %d consecutive times, running WinRS recovery: %v", failures, connectErr)
+
+	diagnostics, diagErr := c.DiagnoseEndpoint(ctx)
+	if diagErr != nil {
+		c.logWarn("Endpoint diagnostics failed: %v", diagErr)
+	}
+	if policy.OnEndpointFailure != nil {
+		policy.OnEndpointFailure(diagnostics, connectErr)
+	}
+
+	if !policy.AutoRepair {
+		return false
+	}
+
+	if _, err := c.RepairEndpoint(ctx); err != nil {
+		c.logError("Endpoint repair failed: %v", err)
+		return false
+	}
+
+	c.mu.Lock()
+	c.endpointFailures = 0
+	c.mu.Unlock()
+
+	settle := policy.SettleTime
+	if settle <= 0 {
+		settle = 15 * time.Second
+	}
+	c.logInfo("Endpoint repaired, waiting %v for WinRM to restart", settle)
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(settle):
+	}
+
+	// The old connections died with the WinRM restart
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/wsman"
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+func TestEndpointError_PreservesMessage(t *testing.T) {
+	cause := errors.New("shell creation failed")
+	err := fmt.Errorf("init backend: %w", &endpointError{err: cause})
+
+	if got, want := err.Error(), "init backend: shell creation failed"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is(err, cause) = false, want true")
+	}
+}
+
+func TestHandleEndpointFailure(t *testing.T) {
+	endpointErr := fmt.Errorf("init backend: %w", &endpointError{err: errors.New("access denied")})
+
+	t.Run("Disabled", func(t *testing.T) {
+		c := &Client{config: DefaultConfig()}
+		if c.handleEndpointFailure(context.Background(), endpointErr) {
+			t.Error("handleEndpointFailure() = true, want false")
+		}
+	})
+
+	t.Run("IgnoresTransportErrors", func(t *testing.T) {
+		called := false
+		cfg := DefaultConfig()
+		cfg.EndpointRecovery = &EndpointRecoveryPolicy{
+			Enabled:           true,
+			FailureThreshold:  1,
+			OnEndpointFailure: func(*CmdResult, error) { called = true },
+		}
+		c := &Client{config: cfg}
+
+		c.handleEndpointFailure(context.Background(), errors.New("dial tcp: connection refused"))
+		if called || c.endpointFailures != 0 {
+			t.Errorf("recovery ran for transport error (called=%v, failures=%d)", called, c.endpointFailures)
+		}
+	})
+
+	t.Run("Threshold", func(t *testing.T) {
+		var calls int
+		var gotDiag *CmdResult
+		var gotErr error
+		cfg := DefaultConfig()
+		cfg.EndpointRecovery = &EndpointRecoveryPolicy{
+			Enabled:          true,
+			FailureThreshold: 2,
+			OnEndpointFailure: func(diag *CmdResult, err error) {
+				calls++
+				gotDiag, gotErr = diag, err
+			},
+		}
+		c := &Client{config: cfg}
+
+		if c.handleEndpointFailure(context.Background(), endpointErr) {
+			t.Error("first failure: handleEndpointFailure() = true, want false")
+		}
+		if calls != 0 {
+			t.Fatalf("callback called %d times before threshold", calls)
+		}
+
+		// No WSMan client, so diagnostics fail and no repair is attempted.
+		if c.handleEndpointFailure(context.Background(), endpointErr) {
+			t.Error("second failure: handleEndpointFailure() = true, want false")
+		}
+		if calls != 1 {
+			t.Fatalf("callback called %d times, want 1", calls)
+		}
+		if gotDiag != nil {
+			t.Errorf("diagnostics = %+v, want nil", gotDiag)
+		}
+		if !errors.Is(gotErr, endpointErr) {
+			t.Errorf("callback error = %v, want %v", gotErr, endpointErr)
+		}
+	})
+}
+
+// Shell creation failures that do and do not point at a broken endpoint.
+var (
+	endpointFaults = map[string]error{
+		"missing configuration": &wsman.Fault{
+			Code: "s:Sender", Subcode: "w:InvalidResourceURI", StatusCode: 500,
+			Reason: "The WS-Management service cannot process the request. Cannot find the Contoso session configuration in the WSMan: drive on the server computer.",
+		},
+		"plugin failure": &wsman.Fault{
+			Code: "s:Receiver", Subcode: "w:InternalError", StatusCode: 500,
+			Provider: &wsman.ProviderFault{Name: "microsoft.powershell", Message: "The type initializer threw an exception."},
+		},
+	}
+	otherFailures = map[string]error{
+		"unauthorized": &transport.TransportError{StatusCode: 401, Status: "401 Unauthorized"},
+		"access denied": &wsman.Fault{
+			Code: "s:Sender", Subcode: "w:AccessDenied", WSManCode: 5, StatusCode: 500,
+			Reason: "Access is denied.",
+		},
+		"max shells per user": &wsman.Fault{
+			Code: "s:Receiver", Subcode: "w:QuotaLimit", WSManCode: 2150859173, StatusCode: 500,
+			Reason: "The WS-Management service cannot process the request. This user is allowed a maximum number of 5 concurrent shells, which has been exceeded. Close existing shells or raise the quota for this user.",
+		},
+		"max concurrent users": &wsman.Fault{
+			Code: "s:Receiver", Subcode: "w:QuotaLimit", StatusCode: 500,
+			Provider: &wsman.ProviderFault{Name: "microsoft.powershell", Message: "The WS-Management service cannot process the request. The maximum number of concurrent users for this session configuration has been exceeded."},
+			Reason:   "The maximum number of concurrent users for the Microsoft.PowerShell plug-in has been exceeded.",
+		},
+		"timeout": &wsman.Fault{
+			Code: "s:Receiver", Subcode: "w:TimedOut", WSManCode: wsman.ErrorOperationTimedOut, StatusCode: 500,
+			Reason: "The WS-Management service cannot complete the operation within the time specified in OperationTimeout.",
+		},
+		"canceled":          context.Canceled,
+		"deadline exceeded": fmt.Errorf("create shell: %w", context.DeadlineExceeded),
+	}
+)
+
+func TestIsEndpointFault(t *testing.T) {
+	for name, err := range endpointFaults {
+		if !isEndpointFault(fmt.Errorf("create shell: %w", err)) {
+			t.Errorf("isEndpointFault(%s) = false, want true", name)
+		}
+	}
+	for name, err := range otherFailures {
+		if isEndpointFault(err) {
+			t.Errorf("isEndpointFault(%s) = true, want false", name)
+		}
+	}
+}
+
+func TestHandleEndpointFailure_NotEndpointErrors(t *testing.T) {
+	for name, err := range otherFailures {
+		t.Run(name, func(t *testing.T) {
+			called := false
+			cfg := DefaultConfig()
+			cfg.EndpointRecovery = &EndpointRecoveryPolicy{
+				Enabled:           true,
+				FailureThreshold:  1,
+				AutoRepair:        true,
+				OnEndpointFailure: func(*CmdResult, error) { called = true },
+			}
+			c := &Client{config: cfg}
+
+			connectErr := backendInitError(err)
+			if !errors.Is(connectErr, err) {
+				t.Errorf("backendInitError() = %v, does not wrap %v", connectErr, err)
+			}
+			for range 3 {
+				if c.handleEndpointFailure(context.Background(), connectErr) {
+					t.Fatal("handleEndpointFailure() = true, want false")
+				}
+			}
+			if called || c.endpointFailures != 0 {
+				t.Errorf("recovery ran (called=%v, failures=%d)", called, c.endpointFailures)
+			}
+		})
+	}
+
+	for name, err := range endpointFaults {
+		var epErr *endpointError
+		if !errors.As(backendInitError(err), &epErr) {
+			t.Errorf("backendInitError(%s) is not an endpointError", name)
+		}
+	}
+}
+
+func TestRepairEndpoint_InvalidConfigurationName(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ConfigurationName = "Microsoft.PowerShell'; Remove-Item C:\\ -Recurse; '"
+	c := &Client{config: cfg}
+
+	if _, err := c.RepairEndpoint(context.Background()); err == nil {
+		t.Error("RepairEndpoint() error = nil, want error")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/smnsjas/go-psrp/wsman"
 	"github.com/smnsjas/go-psrpcore/runspace"
 )
 
@@ -48,6 +49,12 @@ func isRetryableError(err error) bool {
 		return true
 	}
 
+	// WSMan faults: retryable only if they clear up on their own (timeouts,
+	// exceeded quotas); access denied or a missing shell will not
+	if f, ok := wsman.AsFault(err); ok {
+		return f.Temporary()
+	}
+
 	// Fallback: String matching for stdlib network errors
 	errStr := strings.ToLower(err.Error())
 	return strings.Contains(errStr, "connection reset") ||
@@ -3,10 +3,12 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"testing"
 	"time"
 
+	"github.com/smnsjas/go-psrp/wsman"
 	"github.com/smnsjas/go-psrpcore/runspace"
 )
 
@@ -66,6 +68,16 @@ func TestIsRetryableError(t *testing.T) {
 			err:      errors.New("read: connection reset by peer"),
 			expected: true,
 		},
+		{
+			name:     "WSMan quota fault",
+			err:      fmt.Errorf("receive: %w", &wsman.Fault{Subcode: "w:QuotaLimit"}),
+			expected: true,
+		},
+		{
+			name:     "WSMan access denied fault",
+			err:      fmt.Errorf("create: %w", &wsman.Fault{Subcode: "w:AccessDenied", Reason: "connection reset"}),
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
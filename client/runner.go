@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"time"
+)
+
+// ErrStepFailed is wrapped by a *StepError when a step's script reported
+// errors (Result.HadErrors) without failing outright.
+var ErrStepFailed = errors.New("client: step reported errors")
+
+// Step is one script in a RunSteps plan.
+type Step struct {
+	// Name identifies the step in results and logs.
+	Name string
+
+	// Script is the PowerShell script to run.
+	Script string
+
+	// ContinueOnError lets the run go on when this step fails, even with
+	// StopOnError.
+	ContinueOnError bool
+}
+
+// ErrorPolicy decides what RunSteps does after a failed step.
+type ErrorPolicy int
+
+const (
+	// StopOnError stops the run at the first failed step. The checkpoint
+	// points at that step, so a resumed run retries it.
+	StopOnError ErrorPolicy = iota
+
+	// ContinueOnError runs the remaining steps after a failure.
+	ContinueOnError
+)
+
+// RunOptions configures RunSteps.
+type RunOptions struct {
+	// CheckpointPath is the session state file progress is saved to after
+	// every step (see SaveState). If it holds a checkpoint of the same
+	// steps, the run resumes after the last completed step. Empty disables
+	// checkpointing.
+	CheckpointPath string
+
+	// Restart ignores an existing checkpoint and runs every step.
+	Restart bool
+
+	// OnError is the policy for failed steps; StopOnError by default.
+	OnError ErrorPolicy
+}
+
+// RunCheckpoint records how far RunSteps got through a list of steps.
+type RunCheckpoint struct {
+	// Plan is the SHA-256 of the step names and scripts, so a checkpoint
+	// is only resumed for the same steps.
+	Plan string `json:"plan"`
+
+	// Completed is the number of steps done; the run resumes at this index.
+	Completed int `json:"completed"`
+
+	// Failed lists the indexes of steps that failed and were passed over.
+	Failed []int `json:"failed,omitempty"`
+
+	// UpdatedAt is when the checkpoint was saved.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StepError is the failure of one step.
+type StepError struct {
+	Index int
+	Name  string
+	Err   error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %d (%s): %v", e.Index+1, e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// StepResult is the outcome of one step.
+type StepResult struct {
+	Name string
+
+	// Result is the script's result; nil for resumed steps and steps that
+	// failed to run.
+	Result *Result
+
+	// Err is the step's *StepError, or nil if it succeeded.
+	Err error
+
+	// Resumed reports that the step completed in an earlier run and was
+	// not run again.
+	Resumed bool
+
+	Duration time.Duration
+}
+
+// RunReport is the result of RunSteps, one entry per step reached.
+type RunReport struct {
+	Steps []StepResult
+
+	// Completed is the number of steps done, including resumed steps.
+	Completed int
+}
+
+// OK reports whether every step ran and none failed.
+func (r *RunReport) OK() bool {
+	return r.err() == nil && r.Completed == len(r.Steps)
+}
+
+// err joins the errors of the failed steps.
+func (r *RunReport) err() error {
+	var errs []error
+	for _, s := range r.Steps {
+		if s.Err != nil {
+			errs = append(errs, s.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RunSteps runs steps in order, one script at a time, and returns a result
+// per step. A step fails if its script cannot be run or reports errors
+// (ErrStepFailed); opts.OnError and Step.ContinueOnError decide whether the
+// run goes on.
+//
+// With opts.CheckpointPath, progress is saved after every step together
+// with the session state, so after a reconnect or a client restart the same
+// steps resume where they stopped: completed steps are reported as Resumed
+// and not run again. A completed plan is not rerun; set Restart to run it
+// again.
+//
+// The report is returned even on error; the error joins the *StepError of
+// every failed step, or is the context's error if ctx ended.
+func (c *Client) RunSteps(ctx context.Context, steps []Step, opts RunOptions) (*RunReport, error) {
+	return c.runSteps(ctx, steps, opts, c.Execute)
+}
+
+// runSteps implements RunSteps, running each script with execute.
+func (c *Client) runSteps(ctx context.Context, steps []Step, opts RunOptions,
+	execute func(context.Context, string) (*Result, error)) (*RunReport, error) {
+	checkpoint := &RunCheckpoint{Plan: planHash(steps)}
+	if opts.CheckpointPath != "" && !opts.Restart {
+		saved, err := c.loadCheckpoint(opts.CheckpointPath, checkpoint.Plan)
+		if err != nil {
+			return nil, err
+		}
+		if saved != nil {
+			checkpoint = saved
+			c.logInfo("resuming run of %d steps at step %d", len(steps), saved.Completed+1)
+		}
+	}
+
+	report := &RunReport{}
+	for i := 0; i < checkpoint.Completed && i < len(steps); i++ {
+		step := StepResult{Name: steps[i].Name, Resumed: true}
+		if slices.Contains(checkpoint.Failed, i) {
+			step.Err = &StepError{Index: i, Name: steps[i].Name, Err: errors.New("failed in an earlier run")}
+		}
+		report.Steps = append(report.Steps, step)
+		report.Completed++
+	}
+
+	for i := checkpoint.Completed; i < len(steps); i++ {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		step := steps[i]
+		start := time.Now()
+		result, err := execute(ctx, step.Script)
+		sr := StepResult{Name: step.Name, Result: result, Duration: time.Since(start)}
+		switch {
+		case err != nil:
+			sr.Err = &StepError{Index: i, Name: step.Name, Err: err}
+		case result != nil && result.HadErrors:
+			sr.Err = &StepError{Index: i, Name: step.Name, Err: ErrStepFailed}
+		}
+		report.Steps = append(report.Steps, sr)
+
+		if ctx.Err() != nil {
+			// Interrupted, not failed: leave the step to be run again.
+			return report, ctx.Err()
+		}
+		stop := sr.Err != nil && opts.OnError == StopOnError && !step.ContinueOnError
+		if !stop {
+			checkpoint.Completed = i + 1
+			report.Completed++
+			if sr.Err != nil {
+				checkpoint.Failed = append(checkpoint.Failed, i)
+			}
+		}
+		if opts.CheckpointPath != "" {
+			if err := c.saveCheckpoint(opts.CheckpointPath, checkpoint); err != nil {
+				return report, errors.Join(report.err(), err)
+			}
+		}
+		if sr.Err != nil {
+			c.logWarn("%v", sr.Err)
+		}
+		if stop {
+			break
+		}
+	}
+	return report, report.err()
+}
+
+// planHash returns the hex SHA-256 of the step names and scripts.
+func planHash(steps []Step) string {
+	h := sha256.New()
+	for _, s := range steps {
+		h.Write([]byte(s.Name))
+		h.Write([]byte{0})
+		h.Write([]byte(s.Script))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadCheckpoint returns the checkpoint saved at path for plan, or nil if
+// there is none or it is for other steps.
+func (c *Client) loadCheckpoint(path, plan string) (*RunCheckpoint, error) {
+	state, err := LoadState(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load checkpoint: %w", err)
+	}
+	if state.Checkpoint == nil || state.Checkpoint.Plan != plan {
+		return nil, nil
+	}
+	return state.Checkpoint, nil
+}
+
+// saveCheckpoint saves checkpoint with the session state to path. On
+// transports without session state, only the checkpoint is saved.
+func (c *Client) saveCheckpoint(path string, checkpoint *RunCheckpoint) error {
+	checkpoint.UpdatedAt = time.Now().UTC()
+	c.mu.Lock()
+	c.checkpoint = checkpoint
+	stream := c.config.Transport.isStreamTransport()
+	c.mu.Unlock()
+
+	if stream {
+		return writeStateFile(path, &SessionState{Transport: c.config.Transport.String(), Checkpoint: checkpoint})
+	}
+	return c.SaveState(path)
+}
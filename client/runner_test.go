@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// scriptRunner returns an execute func that records the scripts it runs and
+// fails the scripts in fail.
+func scriptRunner(ran *[]string, fail map[string]error) func(context.Context, string) (*Result, error) {
+	return func(_ context.Context, script string) (*Result, error) {
+		*ran = append(*ran, script)
+		if err, ok := fail[script]; ok {
+			if err == nil {
+				return &Result{HadErrors: true}, nil
+			}
+			return nil, err
+		}
+		return &Result{Output: []interface{}{script}}, nil
+	}
+}
+
+func TestRunSteps_StopOnErrorAndResume(t *testing.T) {
+	steps := []Step{{Name: "one", Script: "1"}, {Name: "two", Script: "2"}, {Name: "three", Script: "3"}}
+	path := filepath.Join(t.TempDir(), "state.json")
+	errBroken := errors.New("connection reset")
+
+	for _, transport := range []TransportType{TransportWSMan, TransportSSH} {
+		t.Run(transport.String(), func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Transport = transport
+			c := &Client{config: cfg, callID: newCallIDManager()}
+
+			var ran []string
+			report, err := c.runSteps(context.Background(), steps, RunOptions{CheckpointPath: path, Restart: true},
+				scriptRunner(&ran, map[string]error{"2": errBroken}))
+			var stepErr *StepError
+			if !errors.As(err, &stepErr) || stepErr.Index != 1 || !errors.Is(err, errBroken) {
+				t.Fatalf("runSteps() error = %v, want step 2 to fail", err)
+			}
+			if report.OK() || report.Completed != 1 || len(report.Steps) != 2 || len(ran) != 2 {
+				t.Fatalf("report = %+v, ran = %v", report, ran)
+			}
+
+			// A restarted client resumes at the failed step.
+			c = &Client{config: cfg, callID: newCallIDManager()}
+			ran = nil
+			report, err = c.runSteps(context.Background(), steps, RunOptions{CheckpointPath: path}, scriptRunner(&ran, nil))
+			if err != nil || !report.OK() {
+				t.Fatalf("resumed runSteps() = %+v, %v", report, err)
+			}
+			if len(ran) != 2 || ran[0] != "2" || !report.Steps[0].Resumed || report.Completed != 3 {
+				t.Errorf("resumed run ran %v, report = %+v", ran, report)
+			}
+
+			state, err := LoadState(path)
+			if err != nil || state.Checkpoint == nil || state.Checkpoint.Completed != 3 {
+				t.Errorf("saved checkpoint = %+v, %v", state, err)
+			}
+		})
+	}
+}
+
+func TestRunSteps_ContinueOnError(t *testing.T) {
+	steps := []Step{{Name: "one", Script: "1"}, {Name: "two", Script: "2"}, {Name: "three", Script: "3", ContinueOnError: true}, {Name: "four", Script: "4"}}
+	c := &Client{config: DefaultConfig(), callID: newCallIDManager()}
+
+	var ran []string
+	report, err := c.runSteps(context.Background(), steps, RunOptions{OnError: ContinueOnError},
+		scriptRunner(&ran, map[string]error{"2": nil}))
+	if !errors.Is(err, ErrStepFailed) || len(ran) != 4 || report.Completed != 4 {
+		t.Errorf("ContinueOnError: error = %v, ran = %v, report = %+v", err, ran, report)
+	}
+
+	// Step.ContinueOnError overrides StopOnError for that step only.
+	ran = nil
+	_, err = c.runSteps(context.Background(), steps, RunOptions{}, scriptRunner(&ran, map[string]error{"3": nil, "4": nil}))
+	if !errors.Is(err, ErrStepFailed) || len(ran) != 4 {
+		t.Errorf("Step.ContinueOnError: error = %v, ran = %v", err, ran)
+	}
+}
+
+func TestRunSteps_OtherPlanStartsOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	c := &Client{config: DefaultConfig(), callID: newCallIDManager()}
+
+	var ran []string
+	if _, err := c.runSteps(context.Background(), []Step{{Name: "a", Script: "a"}}, RunOptions{CheckpointPath: path}, scriptRunner(&ran, nil)); err != nil {
+		t.Fatal(err)
+	}
+	ran = nil
+	if _, err := c.runSteps(context.Background(), []Step{{Name: "a", Script: "a2"}}, RunOptions{CheckpointPath: path}, scriptRunner(&ran, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 1 {
+		t.Errorf("changed steps ran %v, want a fresh run", ran)
+	}
+}
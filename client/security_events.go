@@ -22,6 +22,7 @@ const (
 	SubtypeConnEstablished = "established"
 	SubtypeConnClosed      = "closed"
 	SubtypeConnFailed      = "failed"
+	SubtypeConnDrift       = "configuration_drift"
 	SubtypeAuthAttempt     = "attempt"
 	SubtypeAuthSuccess     = "success"
 	SubtypeAuthFailure     = "failure"
@@ -31,11 +32,16 @@ const (
 	SubtypeCommandExecute  = "execute"
 	SubtypeCommandComplete = "complete"
 	SubtypeCommandFailed   = "failed"
+	SubtypeCommandAttest   = "attest"
 
 	// Reconnection subtypes
-	SubtypeReconnAttempt   = "attempt"
-	SubtypeReconnSuccess   = "success"
-	SubtypeReconnExhausted = "exhausted"
+	SubtypeReconnAttempt     = "attempt"
+	SubtypeReconnSuccess     = "success"
+	SubtypeReconnExhausted   = "exhausted"
+	SubtypeReconnSessionLost = "session_lost"
+
+	// Connection subtype when MaxRunspaces is lowered to the server's quota
+	SubtypeConnQuotaClamped = "quota_clamped"
 )
 
 // Security event outcomes
@@ -63,10 +69,11 @@ type SecurityEvent struct {
 	Severity  string `json:"severity"`   // INFO, WARN, ERROR
 
 	// Identity & Context
-	User          string `json:"user,omitempty"`
-	Source        string `json:"source"`         // "go-psrp" client
-	Target        string `json:"target"`         // server/endpoint
-	CorrelationID string `json:"correlation_id"` // Session-scoped UUID
+	User          string            `json:"user,omitempty"`
+	Source        string            `json:"source"`         // "go-psrp" client
+	Target        string            `json:"target"`         // server/endpoint
+	CorrelationID string            `json:"correlation_id"` // Session-scoped UUID
+	Tags          map[string]string `json:"tags,omitempty"` // Config.Tags
 
 	// Operation Details
 	Action  string         `json:"action"`            // e.g., "NegotiateAuth", "CreatePipeline"
@@ -80,6 +87,7 @@ type SecurityLogger struct {
 	user          string
 	target        string
 	correlationID string
+	tags          map[string]string
 }
 
 // NewSecurityLogger creates a new logger for a session.
@@ -108,6 +116,7 @@ func (l *SecurityLogger) LogEvent(eventType, subtype, severity, outcome string,
 		Source:        "go-psrp",
 		Target:        l.target,
 		CorrelationID: l.correlationID,
+		Tags:          l.tags,
 		Action:        subtype, // Default action to subtype if not clearer
 		Outcome:       outcome,
 		Details:       details,
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync/atomic"
 	"time"
 )
@@ -63,25 +64,28 @@ func (ps *poolSemaphore) Acquire(ctx context.Context) error {
 		return ErrQueueFull
 	}
 
-	// Determine timeout for this specific acquisition
-	timeout := ps.timeout
-	if timeout == 0 {
-		timeout = 60 * time.Second // Default fallback
+	// A deadline on ctx replaces the configured timeout
+	var timeoutC <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := ps.timeout
+		if timeout == 0 {
+			timeout = 60 * time.Second // Default fallback
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
 	}
 
-	// Use a timer for the acquire timeout
-	// Note: We don't defer timer.Stop() efficiently here if we exit early via semaphore,
-	// but for simplicity it's acceptable. For high-perf, we might optimize.
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
-
 	select {
 	case ps.sem <- struct{}{}:
 		// Token acquired
 		return nil
 	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%w: %w", ErrAcquireTimeout, ctx.Err())
+		}
 		return ctx.Err()
-	case <-timer.C:
+	case <-timeoutC:
 		return ErrAcquireTimeout
 	}
 }
@@ -1,10 +1,16 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/smnsjas/go-psrp/wsman"
 )
 
 func TestPoolSemaphore_AcquireRelease(t *testing.T) {
@@ -105,6 +111,30 @@ func TestPoolSemaphore_Timeout(t *testing.T) {
 	}
 }
 
+func TestPoolSemaphore_DeadlineReplacesTimeout(t *testing.T) {
+	sem := newPoolSemaphore(1, -1, 20*time.Millisecond)
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		sem.Release()
+	}()
+
+	// The deadline outlasts the configured timeout, so the slot is awaited
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sem.Acquire(ctx); err != nil {
+		t.Fatalf("Acquire with deadline failed: %v", err)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelShort()
+	if err := sem.Acquire(short); !errors.Is(err, ErrAcquireTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire past deadline = %v, want ErrAcquireTimeout and DeadlineExceeded", err)
+	}
+}
+
 func TestPoolSemaphore_ContextCancel(t *testing.T) {
 	sem := newPoolSemaphore(1, -1, time.Second)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -194,3 +224,46 @@ func TestPoolSemaphore_ZeroQueue(t *testing.T) {
 		t.Errorf("Expected ErrQueueFull, got %v", err)
 	}
 }
+
+func TestClient_ClampRunspacesToServerQuota(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	c := &Client{slogLogger: logger, securityLogger: NewSecurityLogger(logger, "user", "server")}
+
+	if got := c.clampRunspacesLocked(8); got != 8 {
+		t.Errorf("clampRunspacesLocked(8) = %d without server limits, want 8", got)
+	}
+
+	c.serverLimits = &wsman.ServerConfig{MaxConcurrentOperationsPerUser: 1500, MaxShellsPerUser: 4}
+	if got := c.clampRunspacesLocked(3); got != 3 || buf.Len() != 0 {
+		t.Errorf("clampRunspacesLocked(3) = %d, logged %q; want 3 and no warning", got, buf.String())
+	}
+	if got := c.clampRunspacesLocked(8); got != 4 {
+		t.Errorf("clampRunspacesLocked(8) = %d, want 4", got)
+	}
+
+	var event *SecurityEvent
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var record struct {
+			Event *SecurityEvent `json:"event"`
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("unmarshal log record: %v", err)
+		}
+		if record.Event != nil {
+			event = record.Event
+		}
+	}
+	if event == nil || event.Subtype != SubtypeConnQuotaClamped || event.Severity != SeverityWarning {
+		t.Fatalf("security event = %+v, want a %s warning", event, SubtypeConnQuotaClamped)
+	}
+	if event.Details["max_runspaces"] != float64(4) || event.Details["configured_max_runspaces"] != float64(8) {
+		t.Errorf("event details = %v", event.Details)
+	}
+
+	// An unlimited operations quota with no shell quota leaves it as is
+	c.serverLimits = &wsman.ServerConfig{MaxConcurrentOperationsPerUser: 4294967295}
+	if got := c.clampRunspacesLocked(8); got != 8 {
+		t.Errorf("clampRunspacesLocked(8) = %d, want 8", got)
+	}
+}
@@ -0,0 +1,300 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// EnumMode controls how enum-like Go values are serialized.
+// A value is treated as an enum when its type implements fmt.Stringer
+// and has an integer underlying kind.
+type EnumMode int
+
+const (
+	// EnumAsInt serializes enums as their integer value (ConvertTo-Json default).
+	EnumAsInt EnumMode = iota
+	// EnumAsString serializes enums using their String() method
+	// (ConvertTo-Json -EnumsAsStrings).
+	EnumAsString
+)
+
+// SerializationOptions configures how Go values are converted before being
+// serialized to CLIXML: pipeline input (StreamResult.SendInput) and the
+// parameter values of Invoke and ExecuteTemplate.
+// The semantics mirror ConvertTo-Json so that round-trips are predictable.
+// A value that contains itself is expanded until it repeats; the repeated
+// reference is sent as $null.
+type SerializationOptions struct {
+	// MaxDepth limits how many levels of nested structs, maps and slices are
+	// expanded. Values nested deeper are replaced by their string form, like
+	// ConvertTo-Json -Depth. A negative value means no limit.
+	// Default (zero): 2.
+	MaxDepth int
+
+	// EnumMode selects integer or string serialization for enums.
+	// Default: EnumAsInt.
+	EnumMode EnumMode
+
+	// Converter is an optional hook for custom types. It is called for every
+	// value before the built-in conversion. If it returns ok=true, the returned
+	// value is used as-is and not expanded further.
+	Converter func(v interface{}) (converted interface{}, ok bool)
+}
+
+// DefaultSerializationOptions returns options matching ConvertTo-Json defaults.
+func DefaultSerializationOptions() *SerializationOptions {
+	return &SerializationOptions{
+		MaxDepth: 2,
+		EnumMode: EnumAsInt,
+	}
+}
+
+// defaultMaxDepth is the MaxDepth used when it is zero, as in ConvertTo-Json.
+const defaultMaxDepth = 2
+
+var (
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+	dateTimeType = reflect.TypeOf(DateTime{})
+)
+
+// normalizeInput converts a Go value into the primitive, []interface{} and
+// map[string]interface{} shapes understood by the CLIXML serializer.
+// A nil options pointer returns the value unchanged, except that DateTime
+// values are always converted back to time.Time.
+func normalizeInput(v interface{}, opts *SerializationOptions) interface{} {
+	if d, ok := v.(DateTime); ok {
+		return d.Original()
+	}
+	if opts == nil || v == nil {
+		return v
+	}
+	n := &normalizer{opts: opts, maxDepth: opts.MaxDepth, path: make(map[visit]bool)}
+	if n.maxDepth == 0 {
+		n.maxDepth = defaultMaxDepth
+	}
+	return n.value(reflect.ValueOf(v), 0)
+}
+
+// visit identifies a pointer, map or slice by its address, type and (for
+// slices) length.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// visitOf returns the visit of rv, or false if rv cannot refer to itself.
+func visitOf(rv reflect.Value) (visit, bool) {
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map:
+		if rv.IsNil() {
+			return visit{}, false
+		}
+		return visit{ptr: rv.Pointer(), typ: rv.Type()}, true
+	case reflect.Slice:
+		if rv.IsNil() {
+			return visit{}, false
+		}
+		return visit{ptr: rv.Pointer(), typ: rv.Type(), len: rv.Len()}, true
+	default:
+		return visit{}, false
+	}
+}
+
+// normalizer converts one value. path holds the pointers, maps and slices
+// being expanded, to stop at a value that contains itself.
+type normalizer struct {
+	opts     *SerializationOptions
+	maxDepth int
+	path     map[visit]bool
+}
+
+// cutOff reports whether values at depth are replaced by their string form.
+func (n *normalizer) cutOff(depth int) bool {
+	return n.maxDepth > 0 && depth >= n.maxDepth
+}
+
+// enter adds rv to the path. It returns false if rv is already on it; the
+// caller must call leave otherwise.
+func (n *normalizer) enter(rv reflect.Value) bool {
+	key, ok := visitOf(rv)
+	if !ok {
+		return true
+	}
+	if n.path[key] {
+		return false
+	}
+	n.path[key] = true
+	return true
+}
+
+func (n *normalizer) leave(rv reflect.Value) {
+	if key, ok := visitOf(rv); ok {
+		delete(n.path, key)
+	}
+}
+
+// str returns the string form of a value that is not expanded. fmt does not
+// detect cycles in maps and slices, so a value that contains itself is
+// given by its type instead.
+func (n *normalizer) str(rv reflect.Value) string {
+	if cyclic(rv, make(map[visit]bool)) {
+		return rv.Type().String()
+	}
+	return fmt.Sprint(rv.Interface())
+}
+
+// cyclic reports whether rv contains itself. path holds the values being
+// searched.
+func cyclic(rv reflect.Value, path map[visit]bool) bool {
+	if key, ok := visitOf(rv); ok {
+		if path[key] {
+			return true
+		}
+		path[key] = true
+		defer delete(path, key)
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return !rv.IsNil() && cyclic(rv.Elem(), path)
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			if cyclic(rv.Field(i), path) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			if cyclic(iter.Value(), path) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if cyclic(rv.Index(i), path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *normalizer) value(rv reflect.Value, depth int) interface{} {
+	if !rv.IsValid() {
+		return nil
+	}
+	opts := n.opts
+
+	if opts.Converter != nil && rv.CanInterface() {
+		if converted, ok := opts.Converter(rv.Interface()); ok {
+			return converted
+		}
+	}
+
+	// Enums: integer kinds with a String() method
+	if isIntegerKind(rv.Kind()) && rv.Type().Implements(stringerType) {
+		if opts.EnumMode == EnumAsString {
+			return rv.Interface().(fmt.Stringer).String()
+		}
+		if rv.CanInt() {
+			return rv.Int()
+		}
+		return int64(rv.Uint()) // #nosec G115 -- enum values fit in int64
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		if !n.enter(rv) {
+			return nil
+		}
+		defer n.leave(rv)
+		return n.value(rv.Elem(), depth)
+	case reflect.Struct:
+		if rv.Type() == timeType {
+			return rv.Interface()
+		}
+		if rv.Type() == dateTimeType {
+			return rv.Interface().(DateTime).Original()
+		}
+		if n.cutOff(depth) {
+			return n.str(rv)
+		}
+		out := make(map[string]interface{}, rv.NumField())
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			field := rt.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := field.Name
+			if tag, ok := field.Tag.Lookup("json"); ok {
+				tagName := strings.Split(tag, ",")[0]
+				if tagName == "-" {
+					continue
+				}
+				if tagName != "" {
+					name = tagName
+				}
+			}
+			out[name] = n.value(rv.Field(i), depth+1)
+		}
+		return out
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+		if n.cutOff(depth) {
+			return n.str(rv)
+		}
+		if !n.enter(rv) {
+			return nil
+		}
+		defer n.leave(rv)
+		out := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = n.value(iter.Value(), depth+1)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		// Byte slices are serialized natively as byte arrays
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Interface()
+		}
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		if n.cutOff(depth) {
+			return n.str(rv)
+		}
+		if !n.enter(rv) {
+			return nil
+		}
+		defer n.leave(rv)
+		out := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			out[i] = n.value(rv.Index(i), depth+1)
+		}
+		return out
+	default:
+		return rv.Interface()
+	}
+}
+
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,206 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testColor int
+
+const (
+	testColorRed testColor = iota
+	testColorGreen
+)
+
+func (c testColor) String() string {
+	switch c {
+	case testColorRed:
+		return "Red"
+	case testColorGreen:
+		return "Green"
+	default:
+		return "Unknown"
+	}
+}
+
+type testInner struct {
+	Name  string
+	Color testColor `json:"color"`
+}
+
+type testOuter struct {
+	ID     int
+	Inner  testInner
+	Hidden string `json:"-"`
+	secret string
+}
+
+func TestNormalizeInput_NilOptions(t *testing.T) {
+	in := testOuter{ID: 1}
+	if got := normalizeInput(in, nil); !reflect.DeepEqual(got, in) {
+		t.Errorf("normalizeInput(nil opts) = %#v, want unchanged", got)
+	}
+}
+
+func TestNormalizeInput_Enums(t *testing.T) {
+	tests := []struct {
+		name string
+		mode EnumMode
+		want interface{}
+	}{
+		{"AsInt", EnumAsInt, int64(1)},
+		{"AsString", EnumAsString, "Green"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeInput(testColorGreen, &SerializationOptions{EnumMode: tt.mode})
+			if got != tt.want {
+				t.Errorf("normalizeInput() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeInput_Struct(t *testing.T) {
+	in := testOuter{
+		ID:     7,
+		Inner:  testInner{Name: "x", Color: testColorRed},
+		Hidden: "h",
+		secret: "s",
+	}
+
+	got := normalizeInput(&in, &SerializationOptions{EnumMode: EnumAsString})
+	want := map[string]interface{}{
+		"ID": 7,
+		"Inner": map[string]interface{}{
+			"Name":  "x",
+			"color": "Red",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeInput() = %#v, want %#v", got, want)
+	}
+}
+
+func TestNormalizeInput_MaxDepth(t *testing.T) {
+	in := map[string]interface{}{
+		"level1": map[string]interface{}{
+			"level2": []int{1, 2},
+		},
+	}
+
+	got := normalizeInput(in, &SerializationOptions{MaxDepth: 2})
+	want := map[string]interface{}{
+		"level1": map[string]interface{}{
+			"level2": "[1 2]",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeInput() = %#v, want %#v", got, want)
+	}
+}
+
+func TestNormalizeInput_MaxDepthDefault(t *testing.T) {
+	in := map[string]interface{}{
+		"level1": map[string]interface{}{
+			"level2": []int{1, 2},
+		},
+	}
+
+	// Zero is the default depth of 2
+	got := normalizeInput(in, &SerializationOptions{})
+	want := map[string]interface{}{
+		"level1": map[string]interface{}{
+			"level2": "[1 2]",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeInput(MaxDepth: 0) = %#v, want %#v", got, want)
+	}
+
+	// Negative means no limit
+	got = normalizeInput(in, &SerializationOptions{MaxDepth: -1})
+	want = map[string]interface{}{
+		"level1": map[string]interface{}{
+			"level2": []interface{}{1, 2},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeInput(MaxDepth: -1) = %#v, want %#v", got, want)
+	}
+}
+
+type testNode struct {
+	Name string
+	Next *testNode
+}
+
+func TestNormalizeInput_Cycle(t *testing.T) {
+	node := &testNode{Name: "a"}
+	node.Next = node
+	got := normalizeInput(node, &SerializationOptions{MaxDepth: -1})
+	want := map[string]interface{}{"Name": "a", "Next": nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeInput(pointer cycle) = %#v, want %#v", got, want)
+	}
+
+	self := map[string]interface{}{"name": "m"}
+	self["self"] = self
+	got = normalizeInput(self, &SerializationOptions{MaxDepth: -1})
+	want = map[string]interface{}{"name": "m", "self": nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeInput(map cycle) = %#v, want %#v", got, want)
+	}
+
+	// A cycle below the depth limit is not expanded by fmt either
+	in := map[string]interface{}{"level1": map[string]interface{}{"level2": self}}
+	got = normalizeInput(in, &SerializationOptions{MaxDepth: 2})
+	want = map[string]interface{}{
+		"level1": map[string]interface{}{"level2": "map[string]interface {}"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeInput(cycle at depth limit) = %#v, want %#v", got, want)
+	}
+
+	// The same value twice is not a cycle
+	shared := []int{1}
+	got = normalizeInput([]interface{}{shared, shared}, &SerializationOptions{MaxDepth: -1})
+	if want := []interface{}{[]interface{}{1}, []interface{}{1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeInput(shared) = %#v, want %#v", got, want)
+	}
+}
+
+func TestNormalizeInput_PassThrough(t *testing.T) {
+	opts := DefaultSerializationOptions()
+	now := time.Now()
+	data := []byte{1, 2, 3}
+
+	if got := normalizeInput(now, opts); got != now {
+		t.Errorf("time.Time was converted: %#v", got)
+	}
+	if got := normalizeInput(data, opts); !reflect.DeepEqual(got, data) {
+		t.Errorf("[]byte was converted: %#v", got)
+	}
+	if got := normalizeInput("s", opts); got != "s" {
+		t.Errorf("string was converted: %#v", got)
+	}
+}
+
+func TestNormalizeInput_Converter(t *testing.T) {
+	opts := &SerializationOptions{
+		Converter: func(v interface{}) (interface{}, bool) {
+			if inner, ok := v.(testInner); ok {
+				return "inner:" + inner.Name, true
+			}
+			return nil, false
+		},
+	}
+
+	got := normalizeInput([]testInner{{Name: "a"}}, opts)
+	want := []interface{}{"inner:a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeInput() = %#v, want %#v", got, want)
+	}
+}
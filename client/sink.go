@@ -0,0 +1,170 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/smnsjas/go-psrp/powershell"
+)
+
+// execSinks writes the streams of a command to ExecOptions.Stdout and
+// Stderr as the objects arrive. The streams are collected concurrently, so
+// writes are serialized; the first failed write stops all further writes.
+type execSinks struct {
+	stdout io.Writer
+	stderr io.Writer
+
+	mu      sync.Mutex
+	started bool
+	err     error
+}
+
+// newExecSinks returns the sinks of opts, or nil if it has none.
+func newExecSinks(opts ExecOptions) *execSinks {
+	if opts.Stdout == nil && opts.Stderr == nil {
+		return nil
+	}
+	return &execSinks{stdout: opts.Stdout, stderr: opts.Stderr}
+}
+
+// Streams written by execSinks, named like the fields of Result.
+const (
+	sinkOutput      = "Output"
+	sinkErrors      = "Errors"
+	sinkWarnings    = "Warnings"
+	sinkVerbose     = "Verbose"
+	sinkDebug       = "Debug"
+	sinkInformation = "Information"
+)
+
+// writeObject writes an object of the named stream, formatted like the
+// console: output and information to Stdout, errors, warnings, verbose and
+// debug messages to Stderr, the latter three with a "WARNING: " style
+// prefix. It reports whether the write failed.
+func (s *execSinks) writeObject(stream string, v interface{}) bool {
+	if s == nil {
+		return false
+	}
+	switch stream {
+	case sinkOutput:
+		if isExecStatus(v) {
+			return false
+		}
+		return s.write(false, outputString(v))
+	case sinkInformation:
+		if rec, ok := powershell.ParseInformationRecord(v); ok {
+			return s.write(false, rec.Message())
+		}
+		return s.write(false, outputString(v))
+	case sinkErrors:
+		return s.write(true, errorRecordText(v))
+	case sinkWarnings:
+		return s.write(true, "WARNING: "+outputString(v))
+	case sinkVerbose:
+		return s.write(true, "VERBOSE: "+outputString(v))
+	case sinkDebug:
+		return s.write(true, "DEBUG: "+outputString(v))
+	}
+	return false
+}
+
+// writeResult writes a whole Result, for one served from
+// Config.ResultCache.
+func (s *execSinks) writeResult(r *Result) error {
+	for _, stream := range []struct {
+		name    string
+		objects []interface{}
+	}{
+		{sinkOutput, r.Output},
+		{sinkInformation, r.Information},
+		{sinkWarnings, r.Warnings},
+		{sinkVerbose, r.Verbose},
+		{sinkDebug, r.Debug},
+		{sinkErrors, r.Errors},
+	} {
+		for _, obj := range stream.objects {
+			if s.writeObject(stream.name, obj) {
+				return s.error()
+			}
+		}
+	}
+	return nil
+}
+
+// writeTerminatingError writes the error that ended the script, as the
+// console shows it after the output.
+func (s *execSinks) writeTerminatingError(e *ErrorRecord) bool {
+	if s == nil || e == nil {
+		return false
+	}
+	if e.Record != nil {
+		return s.write(true, errorRecordText(e.Record))
+	}
+	return s.write(true, e.Message)
+}
+
+// write writes text as a line to Stderr or Stdout, if set.
+func (s *execSinks) write(stderr bool, text string) bool {
+	w, name := s.stdout, "stdout"
+	if stderr {
+		w, name = s.stderr, "stderr"
+	}
+	if w == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return true
+	}
+	s.started = true
+	if _, err := io.WriteString(w, strings.TrimSuffix(text, "\n")+"\n"); err != nil {
+		s.err = fmt.Errorf("client: write %s: %w", name, err)
+		return true
+	}
+	return false
+}
+
+// error returns the first failed write, if any.
+func (s *execSinks) error() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// written reports whether anything was written, after which the command
+// is not retried: the output cannot be taken back.
+func (s *execSinks) written() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.started
+}
+
+// errorRecordText formats an error record like the console does: the
+// position, the message and the category and error ID.
+func errorRecordText(v interface{}) string {
+	rec, ok := powershell.ParseErrorRecord(v)
+	if !ok {
+		return outputString(v)
+	}
+	var b strings.Builder
+	if rec.PositionMessage != "" {
+		b.WriteString(rec.PositionMessage + "\n")
+	}
+	b.WriteString(rec.Message)
+	if rec.CategoryInfo.Message != "" {
+		b.WriteString("\n    + CategoryInfo          : " + rec.CategoryInfo.Message)
+	}
+	if rec.FullyQualifiedErrorID != "" {
+		b.WriteString("\n    + FullyQualifiedErrorId : " + rec.FullyQualifiedErrorID)
+	}
+	return b.String()
+}
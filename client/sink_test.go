@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+func TestExecSinks_WriteObject(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	sinks := newExecSinks(ExecOptions{Stdout: &stdout, Stderr: &stderr})
+
+	status := map[string]interface{}{execStatusMarker: true, "Success": true}
+	record := &serialization.PSObject{Properties: map[string]interface{}{
+		"Exception":                      &serialization.PSObject{Properties: map[string]interface{}{"Message": "Cannot find path."}},
+		"FullyQualifiedErrorId":          "PathNotFound",
+		"ErrorCategory_Message":          "ObjectNotFound: (C:\\missing:String) [], ItemNotFoundException",
+		"InvocationInfo_PositionMessage": "At line:1 char:1\n+ Get-Item C:\\missing\n",
+	}}
+	for _, w := range []struct {
+		stream string
+		v      interface{}
+	}{
+		{sinkOutput, "line 1"},
+		{sinkOutput, &serialization.PSObject{ToString: "obj"}},
+		{sinkOutput, int32(42)},
+		{sinkOutput, status},
+		{sinkWarnings, &serialization.PSObject{ToString: "low disk"}},
+		{sinkVerbose, "step"},
+		{sinkErrors, record},
+	} {
+		if sinks.writeObject(w.stream, w.v) {
+			t.Fatalf("writeObject(%s, %v) failed: %v", w.stream, w.v, sinks.error())
+		}
+	}
+
+	if want := "line 1\nobj\n42\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+	wantErr := "WARNING: low disk\nVERBOSE: step\n" +
+		"At line:1 char:1\n+ Get-Item C:\\missing\nCannot find path.\n" +
+		"    + CategoryInfo          : ObjectNotFound: (C:\\missing:String) [], ItemNotFoundException\n" +
+		"    + FullyQualifiedErrorId : PathNotFound\n"
+	if stderr.String() != wantErr {
+		t.Errorf("stderr = %q, want %q", stderr.String(), wantErr)
+	}
+	if !sinks.written() {
+		t.Error("written() = false after writes")
+	}
+}
+
+func TestExecSinks_NilAndPartial(t *testing.T) {
+	if sinks := newExecSinks(ExecOptions{}); sinks != nil {
+		t.Fatalf("newExecSinks() = %v without writers, want nil", sinks)
+	}
+	var none *execSinks
+	if none.writeObject(sinkOutput, "x") || none.written() || none.error() != nil {
+		t.Error("nil sinks wrote or failed")
+	}
+
+	var stdout bytes.Buffer
+	sinks := newExecSinks(ExecOptions{Stdout: &stdout})
+	if sinks.writeObject(sinkErrors, "boom") || sinks.written() {
+		t.Error("error written without Stderr")
+	}
+	if sinks.writeTerminatingError(&ErrorRecord{Message: "boom"}) || stdout.Len() != 0 {
+		t.Errorf("terminating error written to stdout: %q", stdout.String())
+	}
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.n++
+	return 0, errors.New("broken pipe")
+}
+
+func TestExecSinks_WriteFailure(t *testing.T) {
+	w := &failingWriter{}
+	sinks := newExecSinks(ExecOptions{Stdout: w})
+	if !sinks.writeObject(sinkOutput, "a") {
+		t.Fatal("writeObject() = false for a failed write")
+	}
+	if !sinks.writeObject(sinkOutput, "b") || w.n != 1 {
+		t.Errorf("write after a failure: %d writes, want 1", w.n)
+	}
+	if err := sinks.error(); err == nil || !strings.Contains(err.Error(), "write stdout: broken pipe") {
+		t.Errorf("error() = %v", err)
+	}
+}
+
+func TestExecSinks_WriteResult(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	sinks := newExecSinks(ExecOptions{Stdout: &stdout, Stderr: &stderr})
+	err := sinks.writeResult(&Result{
+		Output:   []interface{}{"a", "b"},
+		Warnings: []interface{}{"careful"},
+	})
+	if err != nil {
+		t.Fatalf("writeResult() error = %v", err)
+	}
+	if stdout.String() != "a\nb\n" || stderr.String() != "WARNING: careful\n" {
+		t.Errorf("stdout = %q, stderr = %q", stdout.String(), stderr.String())
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SPNCanonicalization selects how the host name in the default Kerberos SPN
+// is canonicalized. Values can be combined.
+type SPNCanonicalization int
+
+const (
+	// SPNStripPort removes the port from the host name.
+	SPNStripPort SPNCanonicalization = 1 << iota
+
+	// SPNUseFQDN replaces the host name with its canonical name from DNS
+	// (following CNAMEs), e.g. a short name or a load balancer alias.
+	SPNUseFQDN
+
+	// SPNReverseDNS replaces the host name with the name its address
+	// resolves back to (PTR), like rdns = true in krb5.conf. It also turns
+	// an IP address into a name.
+	SPNReverseDNS
+)
+
+// defaultSPNService is the SPN service class WinRM registers.
+const defaultSPNService = "WSMAN"
+
+// spnLookupTimeout bounds each DNS lookup made to canonicalize the SPN.
+const spnLookupTimeout = 5 * time.Second
+
+// DNS lookups for SPN canonicalization, replaced in tests.
+var (
+	lookupCNAME = net.DefaultResolver.LookupCNAME
+	lookupHost  = net.DefaultResolver.LookupHost
+	lookupAddr  = net.DefaultResolver.LookupAddr
+)
+
+// targetSPN returns the Kerberos SPN for hostname: TargetSPN if set, else
+// "<SPNService>/<spnHost>".
+func (c *Config) targetSPN(hostname string) string {
+	if c.TargetSPN != "" {
+		return c.TargetSPN
+	}
+	service := c.SPNService
+	if service == "" {
+		service = defaultSPNService
+	}
+	return service + "/" + c.spnHost(hostname)
+}
+
+// spnHost returns the host name for the default Kerberos SPN:
+// SPNHostnameOverride, the HostAliases name or hostname, canonicalized as
+// SPNCanonicalize asks. A failed lookup leaves the name as it is.
+func (c *Config) spnHost(hostname string) string {
+	if c.SPNHostnameOverride != "" {
+		return c.SPNHostnameOverride
+	}
+	host := hostname
+	if alias := c.hostAlias(hostname); alias != "" {
+		host = alias
+	} else if strings.Contains(hostname, "://") {
+		if u, err := url.Parse(hostname); err == nil {
+			host = u.Host
+		}
+	}
+
+	if c.SPNCanonicalize&SPNStripPort != 0 {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+	}
+	if c.SPNCanonicalize&SPNUseFQDN != 0 {
+		host = canonicalName(host)
+	}
+	if c.SPNCanonicalize&SPNReverseDNS != 0 {
+		host = reverseName(host)
+	}
+	return host
+}
+
+// canonicalName returns the canonical DNS name of host, or host.
+func canonicalName(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), spnLookupTimeout)
+	defer cancel()
+	name, err := lookupCNAME(ctx, host)
+	if err != nil || name == "" {
+		return host
+	}
+	return strings.TrimSuffix(name, ".")
+}
+
+// reverseName returns the name the address of host resolves back to, or host.
+func reverseName(host string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), spnLookupTimeout)
+	defer cancel()
+	addr := host
+	if net.ParseIP(host) == nil {
+		addrs, err := lookupHost(ctx, host)
+		if err != nil || len(addrs) == 0 {
+			return host
+		}
+		addr = addrs[0]
+	}
+	names, err := lookupAddr(ctx, addr)
+	if err != nil || len(names) == 0 {
+		return host
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestConfig_TargetSPN(t *testing.T) {
+	origCNAME, origHost, origAddr := lookupCNAME, lookupHost, lookupAddr
+	t.Cleanup(func() { lookupCNAME, lookupHost, lookupAddr = origCNAME, origHost, origAddr })
+	lookupCNAME = func(_ context.Context, host string) (string, error) {
+		if host == "winrm" {
+			return "winrm-lb.corp.com.", nil
+		}
+		return "", errors.New("no such host")
+	}
+	lookupHost = func(_ context.Context, host string) ([]string, error) {
+		if host == "winrm-lb.corp.com" {
+			return []string{"10.0.0.7"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	lookupAddr = func(_ context.Context, addr string) ([]string, error) {
+		if addr == "10.0.0.7" {
+			return []string{"node1.corp.com."}, nil
+		}
+		return nil, errors.New("no PTR record")
+	}
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		hostname string
+		want     string
+	}{
+		{"Default", Config{}, "web01.corp.com", "WSMAN/web01.corp.com"},
+		{"TargetSPN", Config{TargetSPN: "HTTP/x", SPNService: "HOST"}, "web01", "HTTP/x"},
+		{"Service", Config{SPNService: "HTTP"}, "web01", "HTTP/web01"},
+		{"Override", Config{SPNHostnameOverride: "cluster.corp.com", SPNCanonicalize: SPNUseFQDN}, "node1", "WSMAN/cluster.corp.com"},
+		{"Alias", Config{HostAliases: map[string]string{"10.0.0.5": "web01.corp.com"}}, "10.0.0.5", "WSMAN/web01.corp.com"},
+		{"URL", Config{}, "https://web01:5986/wsman", "WSMAN/web01:5986"},
+		{"StripPort", Config{SPNCanonicalize: SPNStripPort}, "https://web01:5986/wsman", "WSMAN/web01"},
+		{"StripPortIPv6", Config{SPNCanonicalize: SPNStripPort}, "[fe80::1]:5985", "WSMAN/fe80::1"},
+		{"FQDN", Config{SPNCanonicalize: SPNUseFQDN}, "winrm", "WSMAN/winrm-lb.corp.com"},
+		{"FQDNLookupFails", Config{SPNCanonicalize: SPNUseFQDN}, "unknown", "WSMAN/unknown"},
+		{"ReverseDNS", Config{SPNCanonicalize: SPNUseFQDN | SPNReverseDNS}, "winrm", "WSMAN/node1.corp.com"},
+		{"ReverseDNSAddress", Config{SPNCanonicalize: SPNReverseDNS}, "10.0.0.7", "WSMAN/node1.corp.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.targetSPN(tt.hostname); got != tt.want {
+				t.Errorf("targetSPN(%q) = %q, want %q", tt.hostname, got, tt.want)
+			}
+		})
+	}
+}
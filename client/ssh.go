@@ -0,0 +1,150 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/smnsjas/go-psrp/powershell"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHOptions configures the SSH transport (TransportSSH).
+// The server must register the PowerShell subsystem in sshd_config, e.g.:
+//
+//	Subsystem powershell c:/progra~1/powershell/7/pwsh.exe -sshs -NoLogo
+type SSHOptions struct {
+	// Port is the SSH port.
+	// Default: 22.
+	Port int
+
+	// PrivateKeyPath is an unencrypted private key used for public key
+	// authentication. Config.Password is used as well if set.
+	PrivateKeyPath string
+
+	// KnownHostsPath is the known_hosts file used to verify the server.
+	// Default: ~/.ssh/known_hosts.
+	KnownHostsPath string
+
+	// HostKeyCallback verifies the server host key. Overrides KnownHostsPath.
+	HostKeyCallback ssh.HostKeyCallback
+
+	// Subsystem is the SSH subsystem running PowerShell.
+	// Default: "powershell".
+	Subsystem string
+}
+
+// sshOptions returns the configured SSH options, or defaults.
+func (c *Config) sshOptions() SSHOptions {
+	var opts SSHOptions
+	if c.SSH != nil {
+		opts = *c.SSH
+	}
+	if opts.Port == 0 {
+		opts.Port = 22
+	}
+	return opts
+}
+
+// sshClientConfig builds the SSH client configuration from Config.
+func sshClientConfig(cfg Config) (*ssh.ClientConfig, error) {
+	opts := cfg.sshOptions()
+
+	var methods []ssh.AuthMethod
+	if opts.PrivateKeyPath != "" {
+		key, err := os.ReadFile(opts.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("read ssh private key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse ssh private key: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		password := cfg.Password
+		methods = append(methods,
+			ssh.Password(password),
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}),
+		)
+	}
+
+	hostKeyCallback := opts.HostKeyCallback
+	if hostKeyCallback == nil {
+		if cfg.InsecureSkipVerify {
+			hostKeyCallback = ssh.InsecureIgnoreHostKey() // #nosec G106 -- explicit opt-in for testing
+		} else {
+			path := opts.KnownHostsPath
+			if path == "" {
+				home, err := os.UserHomeDir()
+				if err != nil {
+					return nil, fmt.Errorf("locate known_hosts: %w", err)
+				}
+				path = filepath.Join(home, ".ssh", "known_hosts")
+			}
+			cb, err := knownhosts.New(path)
+			if err != nil {
+				return nil, fmt.Errorf("load known_hosts: %w", err)
+			}
+			hostKeyCallback = cb
+		}
+	}
+
+	user := cfg.Username
+	if cfg.Domain != "" {
+		user = cfg.Domain + "\\" + cfg.Username
+	}
+
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            methods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         cfg.Timeout,
+	}, nil
+}
+
+// preflightSSH checks the SSH transport settings. WSMan-only options are
+// reported as ignored.
+func (c *Config) preflightSSH(p *preflight) {
+	if c.Username == "" {
+		p.error("Username", "username is required", "set Username to the SSH login name")
+	}
+	keyPath := ""
+	if c.SSH != nil {
+		keyPath = c.SSH.PrivateKeyPath
+		p.checkFile("SSH.PrivateKeyPath", keyPath, "check the path to the private key")
+		p.checkFile("SSH.KnownHostsPath", c.SSH.KnownHostsPath, "check the path, or leave it empty to use ~/.ssh/known_hosts")
+	}
+	if c.Password == "" && keyPath == "" {
+		p.error("Password", "password or SSH private key is required", "set Password or SSH.PrivateKeyPath")
+	}
+	if c.AuthType != AuthNegotiate || c.UseTLS || c.EnableCBT {
+		p.warn("AuthType", "AuthType, UseTLS and EnableCBT are ignored by the SSH transport", "remove them from the SSH configuration")
+	}
+	if c.Port != 0 && c.Port != 5985 {
+		p.warn("Port", "Port is ignored by the SSH transport", "set SSH.Port instead")
+	}
+}
+
+// newSSHBackend creates the SSH backend for the client's host.
+func (c *Client) newSSHBackend() (*powershell.SSHBackend, error) {
+	sshConfig, err := sshClientConfig(c.config)
+	if err != nil {
+		return nil, err
+	}
+	opts := c.config.sshOptions()
+	addr := net.JoinHostPort(c.hostname, strconv.Itoa(opts.Port))
+	backend := powershell.NewSSHBackend(addr, sshConfig, opts.Subsystem, c.poolID)
+	backend.SetRateLimiter(c.limiter)
+	return backend, nil
+}
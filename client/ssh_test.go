@@ -0,0 +1,131 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig_Validate_SSH(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, []byte("key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{
+			name: "password",
+			cfg:  Config{Transport: TransportSSH, Username: "user", Password: "pass"},
+		},
+		{
+			name: "private key without password",
+			cfg: Config{
+				Transport: TransportSSH,
+				Username:  "user",
+				SSH:       &SSHOptions{PrivateKeyPath: keyPath},
+			},
+		},
+		{
+			name: "missing private key file",
+			cfg: Config{
+				Transport: TransportSSH,
+				Username:  "user",
+				SSH:       &SSHOptions{PrivateKeyPath: keyPath + ".missing"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no credentials",
+			cfg:     Config{Transport: TransportSSH, Username: "user"},
+			wantErr: true,
+		},
+		{
+			name:    "missing username",
+			cfg:     Config{Transport: TransportSSH, Password: "pass"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSSHClientConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Transport = TransportSSH
+	cfg.Username = testUsername
+	cfg.Password = testPassword
+	cfg.Domain = "CORP"
+	cfg.InsecureSkipVerify = true
+
+	sshCfg, err := sshClientConfig(cfg)
+	if err != nil {
+		t.Fatalf("sshClientConfig() error = %v", err)
+	}
+	if sshCfg.User != `CORP\`+testUsername {
+		t.Errorf("User = %q, want %q", sshCfg.User, `CORP\`+testUsername)
+	}
+	if len(sshCfg.Auth) != 2 {
+		t.Errorf("len(Auth) = %d, want 2 (password, keyboard-interactive)", len(sshCfg.Auth))
+	}
+	if sshCfg.HostKeyCallback == nil {
+		t.Error("HostKeyCallback is nil")
+	}
+}
+
+func TestSSHClientConfig_Errors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	tests := []struct {
+		name string
+		opts SSHOptions
+	}{
+		{name: "missing known_hosts", opts: SSHOptions{KnownHostsPath: missing}},
+		{name: "missing private key", opts: SSHOptions{PrivateKeyPath: missing}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Transport = TransportSSH
+			cfg.Username = testUsername
+			cfg.Password = testPassword
+			cfg.SSH = &tt.opts
+
+			if _, err := sshClientConfig(cfg); err == nil {
+				t.Error("sshClientConfig() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestNewClient_SSH(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Transport = TransportSSH
+	cfg.Username = testUsername
+	cfg.Password = testPassword
+
+	c, err := New("testserver", cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if c.wsman != nil || c.Endpoint() != "" {
+		t.Error("SSH client should not create a WSMan client")
+	}
+	if got := cfg.Transport.String(); got != TransportNameSSH {
+		t.Errorf("Transport.String() = %q, want %q", got, TransportNameSSH)
+	}
+	if opts := cfg.sshOptions(); opts.Port != 22 {
+		t.Errorf("default SSH port = %d, want 22", opts.Port)
+	}
+}
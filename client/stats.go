@@ -0,0 +1,182 @@
+package client
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the client's activity since New. It is meant for
+// monitoring without OpenTelemetry: publish it with PublishExpvar or
+// serve it with MetricsHandler.
+type Stats struct {
+	// Executes is the number of Execute calls (including ExecuteWithOptions
+	// and ExecuteInto); ExecuteFailures the number that returned an error.
+	Executes        int64 `json:"executes"`
+	ExecuteFailures int64 `json:"execute_failures"`
+
+	// ExecuteDuration is the total time spent in Execute calls, including
+	// queueing and retries; MeanExecuteDuration is its mean.
+	ExecuteDuration     time.Duration `json:"execute_duration_ns"`
+	MeanExecuteDuration time.Duration `json:"mean_execute_duration_ns"`
+
+	// Reconnects is the number of successful automatic reconnections,
+	// including sessions recreated after a server restart.
+	Reconnects int64 `json:"reconnects"`
+
+	// KeepAliveMissed is the number of failed keepalive heartbeats.
+	KeepAliveMissed int64 `json:"keepalive_missed"`
+
+	// BytesUploaded and BytesDownloaded are the sizes of the files
+	// transferred by CopyFile and FetchFile.
+	BytesUploaded   int64 `json:"bytes_uploaded"`
+	BytesDownloaded int64 `json:"bytes_downloaded"`
+
+	// ActiveRunspaces is the number of commands running, QueueDepth the
+	// number waiting for a runspace and MaxRunspaces the limit.
+	ActiveRunspaces int `json:"active_runspaces"`
+	QueueDepth      int `json:"queue_depth"`
+	MaxRunspaces    int `json:"max_runspaces"`
+}
+
+// clientStats holds the counters behind Stats. The zero value is ready
+// to use.
+type clientStats struct {
+	executes        atomic.Int64
+	executeFailures atomic.Int64
+	executeNanos    atomic.Int64
+	reconnects      atomic.Int64
+	keepAliveMissed atomic.Int64
+	commands        atomic.Int64
+	bytesUploaded   atomic.Int64
+	bytesDownloaded atomic.Int64
+}
+
+// recordExecute counts an Execute call that started at start.
+func (s *clientStats) recordExecute(start time.Time, err error) {
+	s.executes.Add(1)
+	s.executeNanos.Add(int64(time.Since(start)))
+	if err != nil {
+		s.executeFailures.Add(1)
+	}
+}
+
+// recordTransfer counts the size of localPath as transferred if err is
+// nil, and returns it.
+func (s *clientStats) recordTransfer(localPath string, upload bool, err error) int64 {
+	if err != nil {
+		return 0
+	}
+	info, statErr := os.Stat(localPath)
+	if statErr != nil {
+		return 0
+	}
+	if upload {
+		s.bytesUploaded.Add(info.Size())
+	} else {
+		s.bytesDownloaded.Add(info.Size())
+	}
+	return info.Size()
+}
+
+// Stats returns a snapshot of the client's activity counters.
+func (c *Client) Stats() Stats {
+	st := Stats{
+		Executes:        c.stats.executes.Load(),
+		ExecuteFailures: c.stats.executeFailures.Load(),
+		ExecuteDuration: time.Duration(c.stats.executeNanos.Load()),
+		Reconnects:      c.stats.reconnects.Load(),
+		KeepAliveMissed: c.stats.keepAliveMissed.Load(),
+		BytesUploaded:   c.stats.bytesUploaded.Load(),
+		BytesDownloaded: c.stats.bytesDownloaded.Load(),
+	}
+	if st.Executes > 0 {
+		st.MeanExecuteDuration = st.ExecuteDuration / time.Duration(st.Executes)
+	}
+
+	c.mu.Lock()
+	sem := c.semaphore
+	c.mu.Unlock()
+	if sem != nil {
+		st.ActiveRunspaces, st.QueueDepth, st.MaxRunspaces = sem.Stats()
+	}
+	return st
+}
+
+// PublishExpvar publishes Stats as the expvar variable name, served as
+// JSON on /debug/vars. Like expvar.Publish, it panics if name is already
+// in use, so call it once per client.
+func (c *Client) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return c.Stats() }))
+}
+
+// MetricsHandler returns an HTTP handler that serves Stats in the
+// Prometheus text exposition format, labeled with the server name:
+//
+//	http.Handle("/metrics", c.MetricsHandler())
+//
+// To serve several clients on one endpoint, use WriteMetrics.
+func (c *Client) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WriteMetrics(w, c)
+	})
+}
+
+// metricDef describes one metric of WriteMetrics.
+type metricDef struct {
+	name, kind, help string
+	value            func(Stats) float64
+}
+
+var statsMetrics = []metricDef{
+	{"psrp_client_executes_total", "counter", "Execute calls.",
+		func(s Stats) float64 { return float64(s.Executes) }},
+	{"psrp_client_execute_failures_total", "counter", "Execute calls that returned an error.",
+		func(s Stats) float64 { return float64(s.ExecuteFailures) }},
+	{"psrp_client_execute_duration_seconds_total", "counter", "Total duration of Execute calls.",
+		func(s Stats) float64 { return s.ExecuteDuration.Seconds() }},
+	{"psrp_client_reconnects_total", "counter", "Successful automatic reconnections.",
+		func(s Stats) float64 { return float64(s.Reconnects) }},
+	{"psrp_client_keepalive_missed_total", "counter", "Failed keepalive heartbeats.",
+		func(s Stats) float64 { return float64(s.KeepAliveMissed) }},
+	{"psrp_client_uploaded_bytes_total", "counter", "File bytes uploaded by CopyFile.",
+		func(s Stats) float64 { return float64(s.BytesUploaded) }},
+	{"psrp_client_downloaded_bytes_total", "counter", "File bytes downloaded by FetchFile.",
+		func(s Stats) float64 { return float64(s.BytesDownloaded) }},
+	{"psrp_client_active_runspaces", "gauge", "Commands running.",
+		func(s Stats) float64 { return float64(s.ActiveRunspaces) }},
+	{"psrp_client_queue_depth", "gauge", "Commands waiting for a runspace.",
+		func(s Stats) float64 { return float64(s.QueueDepth) }},
+	{"psrp_client_max_runspaces", "gauge", "Limit of concurrent commands.",
+		func(s Stats) float64 { return float64(s.MaxRunspaces) }},
+}
+
+// WriteMetrics writes the Stats of clients to w in the Prometheus text
+// exposition format, one series per client labeled with its server.
+func WriteMetrics(w io.Writer, clients ...*Client) error {
+	stats := make([]Stats, len(clients))
+	for i, c := range clients {
+		stats[i] = c.Stats()
+	}
+
+	var b strings.Builder
+	for _, m := range statsMetrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for i, c := range clients {
+			fmt.Fprintf(&b, "%s{server=\"%s\"} %g\n", m.name, escapeLabel(c.hostname), m.value(stats[i]))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabel escapes a Prometheus label value.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
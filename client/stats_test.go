@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Stats(t *testing.T) {
+	c := &Client{hostname: "server", semaphore: newPoolSemaphore(4, 10, time.Second)}
+
+	c.stats.recordExecute(time.Now().Add(-2*time.Second), nil)
+	c.stats.recordExecute(time.Now(), errors.New("boom"))
+	c.stats.reconnects.Add(1)
+
+	file := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(file, make([]byte, 1000), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.stats.recordTransfer(file, true, nil); n != 1000 {
+		t.Errorf("recordTransfer() = %d, want 1000", n)
+	}
+	c.stats.recordTransfer(file, false, errors.New("failed"))
+
+	st := c.Stats()
+	if st.Executes != 2 || st.ExecuteFailures != 1 || st.Reconnects != 1 {
+		t.Errorf("Stats() = %+v", st)
+	}
+	if st.MeanExecuteDuration < time.Second {
+		t.Errorf("MeanExecuteDuration = %v, want about 1s", st.MeanExecuteDuration)
+	}
+	if st.BytesUploaded != 1000 || st.BytesDownloaded != 0 {
+		t.Errorf("bytes = %d up, %d down; want 1000 up", st.BytesUploaded, st.BytesDownloaded)
+	}
+	if st.MaxRunspaces != 4 {
+		t.Errorf("MaxRunspaces = %d, want 4", st.MaxRunspaces)
+	}
+}
+
+func TestClient_MetricsHandler(t *testing.T) {
+	c := &Client{hostname: `web"01`}
+	c.stats.recordExecute(time.Now(), nil)
+
+	rec := httptest.NewRecorder()
+	c.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE psrp_client_executes_total counter\n",
+		`psrp_client_executes_total{server="web\"01"} 1` + "\n",
+		"# TYPE psrp_client_queue_depth gauge\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
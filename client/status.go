@@ -0,0 +1,251 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// execStatusMarker identifies the status object that execute appends to
+// the output of a script for ExecOptions.ReportExitCode.
+const execStatusMarker = "PSRPExecStatus-5f1d8c2e"
+
+// execStatusKey marks the context of a pipeline whose script is wrapped
+// with withExecStatus.
+type execStatusKey struct{}
+
+func captureExecStatus(ctx context.Context) bool {
+	v, _ := ctx.Value(execStatusKey{}).(bool)
+	return v
+}
+
+// ErrorRecord is a PowerShell ErrorRecord, such as the terminating error
+// of a script.
+type ErrorRecord struct {
+	// Message is the message of the exception.
+	Message string
+
+	// ExceptionType is the full .NET type name of the exception, e.g.
+	// "System.Management.Automation.RuntimeException".
+	ExceptionType string
+
+	// FullyQualifiedErrorID identifies the error, e.g.
+	// "PathNotFound,Microsoft.PowerShell.Commands.GetChildItemCommand".
+	FullyQualifiedErrorID string
+
+	// Category is the error category, e.g.
+	// "ObjectNotFound: (C:\missing:String) [Get-ChildItem], ItemNotFoundException".
+	Category string
+
+	// ScriptStackTrace and PositionMessage locate the error in the script.
+	ScriptStackTrace string
+	PositionMessage  string
+
+	// Record is the deserialized ErrorRecord.
+	Record interface{}
+}
+
+// Error returns the message, so an ErrorRecord can be returned as an
+// error.
+func (e *ErrorRecord) Error() string {
+	if e.ExceptionType == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (%s)", e.Message, e.ExceptionType)
+}
+
+// Succeeded reports whether the script ran without errors: it did not
+// fail with a terminating error, wrote no error records, $? was true at
+// its end and the last native command, if any, exited with 0.
+func (r *Result) Succeeded() bool {
+	if r.TerminatingError != nil || r.HadErrors {
+		return false
+	}
+	if r.LastCommandSucceeded != nil && !*r.LastCommandSucceeded {
+		return false
+	}
+	return r.ExitCode == nil || *r.ExitCode == 0
+}
+
+// withExecStatus wraps script so that it ends by writing a status object
+// with $? and $LASTEXITCODE, for ExecOptions.ReportExitCode. The script is
+// dot-sourced so it runs in the same scope as without the wrapper. using
+// statements, which must come first in a script, are kept ahead of the
+// wrapper. A terminating error still fails the pipeline after the status
+// object is written.
+func withExecStatus(script string) string {
+	usings, body := splitUsingStatements(script)
+	return fmt.Sprintf(`%s$global:LASTEXITCODE = $null
+$__psrpOk = $false
+try {
+. {
+%s
+}
+$__psrpOk = $?
+} finally {
+@{
+'%s' = $true
+Success = $__psrpOk
+ExitCode = $global:LASTEXITCODE
+}
+Remove-Variable -Name __psrpOk -ErrorAction SilentlyContinue
+}`, usings, body, execStatusMarker)
+}
+
+// splitUsingStatements splits the using statements at the start of
+// script, with the blank and comment lines between them, from the rest.
+func splitUsingStatements(script string) (usings, body string) {
+	end, found := 0, false
+	for end < len(script) {
+		line, _, _ := strings.Cut(script[end:], "\n")
+		trimmed := strings.TrimSpace(line)
+		if isUsingStatement(trimmed) {
+			found = true
+		} else if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		end += len(line) + 1
+	}
+	if !found {
+		return "", script
+	}
+	end = min(end, len(script))
+	usings = script[:end]
+	if !strings.HasSuffix(usings, "\n") {
+		usings += "\n"
+	}
+	return usings, script[end:]
+}
+
+// isUsingStatement reports whether line is a using namespace, module or
+// assembly statement.
+func isUsingStatement(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "using") {
+		return false
+	}
+	switch strings.ToLower(fields[1]) {
+	case "namespace", "module", "assembly":
+		return true
+	}
+	return false
+}
+
+// applyExecStatus removes the status object written by withExecStatus
+// from the output and fills in the status fields. A result without it is
+// left unchanged.
+func (r *Result) applyExecStatus() {
+	if len(r.Output) == 0 {
+		return
+	}
+	status := objectProperties(unwrapStatus(r.Output[len(r.Output)-1]))
+	if status == nil || statusValue(status, execStatusMarker) != true {
+		return
+	}
+	r.Output = r.Output[:len(r.Output)-1]
+
+	if ok, isBool := statusValue(status, "Success").(bool); isBool {
+		r.LastCommandSucceeded = &ok
+	}
+	if code, isInt := statusInt(statusValue(status, "ExitCode")); isInt {
+		r.ExitCode = &code
+	}
+}
+
+// terminatingError converts the ErrorRecord of a failed pipeline's
+// PIPELINE_STATE message.
+func terminatingError(rec *powershell.ErrorRecord) *ErrorRecord {
+	if rec == nil {
+		return nil
+	}
+	category := rec.CategoryInfo.Message
+	if category == "" {
+		category = rec.CategoryInfo.Category
+	}
+	return &ErrorRecord{
+		Message:               rec.Message,
+		ExceptionType:         exceptionType(rec.Exception),
+		FullyQualifiedErrorID: rec.FullyQualifiedErrorID,
+		Category:              category,
+		ScriptStackTrace:      rec.ScriptStackTrace,
+		PositionMessage:       rec.PositionMessage,
+		Record:                rec.Record,
+	}
+}
+
+// exceptionType returns the .NET type name of a deserialized exception.
+func exceptionType(exception interface{}) string {
+	obj, ok := exception.(*serialization.PSObject)
+	if !ok || len(obj.TypeNames) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(obj.TypeNames[0], "Deserialized.")
+}
+
+// pipelineFailure holds the terminating error of a pipeline, taken from
+// its PIPELINE_STATE message. A nil *pipelineFailure holds nothing.
+type pipelineFailure struct {
+	mu     sync.Mutex
+	record *ErrorRecord
+}
+
+func (f *pipelineFailure) set(rec *powershell.ErrorRecord) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record = terminatingError(rec)
+}
+
+func (f *pipelineFailure) get() *ErrorRecord {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.record
+}
+
+// isExecStatus reports whether v is the status object written by
+// withExecStatus.
+func isExecStatus(v interface{}) bool {
+	status := objectProperties(unwrapStatus(v))
+	return status != nil && statusValue(status, execStatusMarker) == true
+}
+
+// unwrapStatus returns the hashtable of a status object the deserializer
+// kept in a PSObject.
+func unwrapStatus(v interface{}) interface{} {
+	if obj, ok := v.(*serialization.PSObject); ok && obj.Value != nil {
+		return obj.Value
+	}
+	return v
+}
+
+// statusValue returns a property of the status object, unwrapping
+// primitives the deserializer kept in a PSObject.
+func statusValue(props map[string]interface{}, name string) interface{} {
+	v, _ := lookupProperty(props, name)
+	return unwrapStatus(v)
+}
+
+// statusInt converts an exit code, an Int32 unless a script assigned
+// $LASTEXITCODE another number.
+func statusInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case int:
+		return n, true
+	case uint32:
+		return int(n), true
+	}
+	return 0, false
+}
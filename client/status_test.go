@@ -0,0 +1,322 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrpcore/fragments"
+	"github.com/smnsjas/go-psrpcore/messages"
+	"github.com/smnsjas/go-psrpcore/pipeline"
+	"github.com/smnsjas/go-psrpcore/runspace"
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+func TestWithExecStatus(t *testing.T) {
+	script := withExecStatus("Get-Date")
+
+	for _, want := range []string{"\nGet-Date\n", execStatusMarker, "$global:LASTEXITCODE", "finally {"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("wrapped script missing %q:\n%s", want, script)
+		}
+	}
+	if !strings.HasPrefix(script, "$global:LASTEXITCODE = $null") {
+		t.Error("wrapped script does not reset $LASTEXITCODE first")
+	}
+	// Terminating errors fail the pipeline as without the wrapper
+	if strings.Contains(script, "catch") {
+		t.Errorf("wrapped script catches errors:\n%s", script)
+	}
+
+	script = withExecStatus("using namespace System.IO\nusing module MyModule\n[Path]::GetFileName('a\\b.txt')")
+	if !strings.HasPrefix(script, "using namespace System.IO\nusing module MyModule\n$global:LASTEXITCODE = $null") {
+		t.Errorf("using statements not kept first:\n%s", script)
+	}
+	if !strings.Contains(script, ". {\n[Path]::GetFileName('a\\b.txt')\n}") {
+		t.Errorf("script body not wrapped:\n%s", script)
+	}
+}
+
+func TestSplitUsingStatements(t *testing.T) {
+	tests := []struct {
+		name, script, usings, body string
+	}{
+		{"none", "Get-Date", "", "Get-Date"},
+		{"namespace", "using namespace System.IO\n[Path]::X", "using namespace System.IO\n", "[Path]::X"},
+		{"comments and blanks", "# header\n\nUSING Assembly System.Web\n  using module Foo\nGet-Foo", "# header\n\nUSING Assembly System.Web\n  using module Foo\n", "Get-Foo"},
+		{"only usings", "using namespace System.IO", "using namespace System.IO\n", ""},
+		{"not first", "Get-Date\nusing namespace System.IO", "", "Get-Date\nusing namespace System.IO"},
+		{"variable named using", "$using = 1", "", "$using = 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usings, body := splitUsingStatements(tt.script)
+			if usings != tt.usings || body != tt.body {
+				t.Errorf("splitUsingStatements() = %q, %q; want %q, %q", usings, body, tt.usings, tt.body)
+			}
+		})
+	}
+}
+
+func TestCaptureExecStatus(t *testing.T) {
+	if captureExecStatus(context.Background()) {
+		t.Error("captureExecStatus() = true without the key")
+	}
+	if !captureExecStatus(context.WithValue(context.Background(), execStatusKey{}, true)) {
+		t.Error("captureExecStatus() = false with the key")
+	}
+}
+
+func TestResult_ApplyExecStatus(t *testing.T) {
+	t.Run("exit code", func(t *testing.T) {
+		r := &Result{Output: []interface{}{"out", map[string]interface{}{
+			execStatusMarker: true,
+			"Success":        false,
+			"ExitCode":       int32(3),
+			"Error":          nil,
+		}}}
+		r.applyExecStatus()
+
+		if len(r.Output) != 1 || r.Output[0] != "out" {
+			t.Errorf("Output = %v, want the status removed", r.Output)
+		}
+		if r.ExitCode == nil || *r.ExitCode != 3 {
+			t.Errorf("ExitCode = %v, want 3", r.ExitCode)
+		}
+		if r.LastCommandSucceeded == nil || *r.LastCommandSucceeded {
+			t.Errorf("LastCommandSucceeded = %v, want false", r.LastCommandSucceeded)
+		}
+		if r.TerminatingError != nil || r.HadErrors {
+			t.Errorf("TerminatingError = %v, HadErrors = %v; want none", r.TerminatingError, r.HadErrors)
+		}
+		if r.Succeeded() {
+			t.Error("Succeeded() = true with exit code 3")
+		}
+	})
+
+	t.Run("no status", func(t *testing.T) {
+		r := &Result{Output: []interface{}{map[string]interface{}{"Name": "x"}}}
+		r.applyExecStatus()
+
+		if len(r.Output) != 1 || r.ExitCode != nil || r.LastCommandSucceeded != nil {
+			t.Errorf("result changed without a status object: %+v", r)
+		}
+		if !r.Succeeded() {
+			t.Error("Succeeded() = false for a result without errors")
+		}
+	})
+}
+
+func TestResult_Succeeded(t *testing.T) {
+	zero, one := 0, 1
+	ok, failed := true, false
+
+	tests := []struct {
+		name   string
+		result Result
+		want   bool
+	}{
+		{"empty", Result{}, true},
+		{"exit 0", Result{ExitCode: &zero, LastCommandSucceeded: &ok}, true},
+		{"exit 1", Result{ExitCode: &one}, false},
+		{"$? false", Result{LastCommandSucceeded: &failed}, false},
+		{"error records", Result{HadErrors: true}, false},
+		{"terminating", Result{TerminatingError: &ErrorRecord{Message: "x"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.Succeeded(); got != tt.want {
+				t.Errorf("Succeeded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTerminatingError(t *testing.T) {
+	exception := &serialization.PSObject{TypeNames: []string{
+		"Deserialized.System.Management.Automation.RuntimeException",
+		"Deserialized.System.Exception",
+	}}
+	te := terminatingError(&powershell.ErrorRecord{
+		Message:               "boom",
+		FullyQualifiedErrorID: "boom",
+		CategoryInfo: powershell.CategoryInfo{
+			Category: "OperationStopped",
+			Message:  "OperationStopped: (boom:String) [], RuntimeException",
+		},
+		PositionMessage: "At line:5 char:1\n+ throw 'boom'",
+		Exception:       exception,
+	})
+
+	if te.Message != "boom" || te.FullyQualifiedErrorID != "boom" || te.PositionMessage != "At line:5 char:1\n+ throw 'boom'" {
+		t.Errorf("terminatingError() = %+v", te)
+	}
+	if te.Category != "OperationStopped: (boom:String) [], RuntimeException" {
+		t.Errorf("Category = %q, want the category message", te.Category)
+	}
+	if got, want := te.Error(), "boom (System.Management.Automation.RuntimeException)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if terminatingError(nil) != nil {
+		t.Error("terminatingError(nil) != nil")
+	}
+}
+
+// testFailedState is the PIPELINE_STATE of a pipeline that failed with a
+// throw, as PowerShell sends it.
+const testFailedState = `<Obj RefId="0"><MS><I32 N="PipelineState">5</I32>` +
+	`<Obj N="ExceptionAsErrorRecord" RefId="1"><TN RefId="0"><T>System.Management.Automation.ErrorRecord</T><T>System.Object</T></TN>` +
+	`<ToString>boom</ToString><MS>` +
+	`<Obj N="Exception" RefId="2"><TN RefId="1"><T>System.Management.Automation.RuntimeException</T><T>System.SystemException</T><T>System.Exception</T><T>System.Object</T></TN>` +
+	`<ToString>boom</ToString><Props><S N="Message">boom</S></Props></Obj>` +
+	`<S N="FullyQualifiedErrorId">boom</S>` +
+	`<I32 N="ErrorCategory_Category">14</I32>` +
+	`<S N="ErrorCategory_Message">OperationStopped: (boom:String) [], RuntimeException</S>` +
+	`<S N="InvocationInfo_PositionMessage">At line:2 char:1&#xA;+ throw 'boom'</S>` +
+	`</MS></Obj></MS></Obj>`
+
+// runMockExecute runs script with opts on a client whose pipeline receives
+// what send writes, and returns the command the server was sent.
+func runMockExecute(t *testing.T, script string, opts ExecOptions, send func(w io.Writer)) (string, *Result, error) {
+	t.Helper()
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	var command string
+	mockBackend := &MockBackend{
+		PrepareFunc: func(ctx context.Context, p *pipeline.Pipeline, payload string) (io.Reader, func(), error) {
+			command = createPipelineCommand(t, payload)
+			return pr, func() { pr.Close() }, nil
+		},
+	}
+	c := &Client{
+		config:    DefaultConfig(),
+		backend:   mockBackend,
+		connected: true,
+		psrpPool:  runspace.New(&DummyReadWriter{}, uuid.New()),
+		semaphore: newPoolSemaphore(1, 0, time.Second),
+		callID:    newCallIDManager(),
+	}
+	c.psrpPool.ResumeOpened()
+
+	go func() {
+		defer pw.Close()
+		send(pw)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := c.ExecuteWithOptions(ctx, script, opts)
+	return command, result, err
+}
+
+// createPipelineCommand returns the script of the CREATE_PIPELINE message
+// in a PreparePipeline payload.
+func createPipelineCommand(t *testing.T, payload string) string {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	frag, err := fragments.Decode(data)
+	if err != nil {
+		t.Fatalf("decode fragment: %v", err)
+	}
+	msg, err := messages.Decode(frag.Data)
+	if err != nil {
+		t.Fatalf("decode message: %v", err)
+	}
+	m := regexp.MustCompile(`<S N="Cmd">([^<]*)</S>`).FindSubmatch(msg.Data)
+	if m == nil {
+		t.Fatalf("no command in %s", msg.Data)
+	}
+	return html.UnescapeString(string(m[1]))
+}
+
+// TestClient_Execute_TerminatingError verifies that the error a pipeline
+// fails with is taken from its PIPELINE_STATE and the output before it is
+// kept.
+func TestClient_Execute_TerminatingError(t *testing.T) {
+	script := "'before'\nthrow 'boom'"
+	command, result, err := runMockExecute(t, script, ExecOptions{}, func(w io.Writer) {
+		sendState(t, w, messages.RunspacePoolStateOpened, messages.PipelineStateRunning, nil)
+		sendOutput(t, w, "before")
+		sendMsg(t, w, &messages.Message{
+			Destination: messages.DestinationClient,
+			Type:        messages.MessageTypePipelineState,
+			Data:        []byte(testFailedState),
+		})
+	})
+
+	if command != script {
+		t.Errorf("command = %q, want the script unchanged", command)
+	}
+	var rec *ErrorRecord
+	if !errors.As(err, &rec) {
+		t.Fatalf("Execute() error = %v, want an *ErrorRecord", err)
+	}
+	if result == nil || result.TerminatingError != rec {
+		t.Fatalf("Execute() result = %+v, want the error as TerminatingError", result)
+	}
+	if rec.Message != "boom" || rec.ExceptionType != "System.Management.Automation.RuntimeException" ||
+		rec.PositionMessage != "At line:2 char:1\n+ throw 'boom'" {
+		t.Errorf("TerminatingError = %+v", rec)
+	}
+	if len(result.Output) != 1 || result.Output[0] != "before" {
+		t.Errorf("Output = %v, want [before]", result.Output)
+	}
+	if !result.HadErrors || result.Succeeded() {
+		t.Errorf("HadErrors = %v, Succeeded() = %v; want a failure", result.HadErrors, result.Succeeded())
+	}
+}
+
+// TestClient_Execute_UsingNamespace verifies that a script starting with
+// using statements is sent as it is, and that ReportExitCode keeps them
+// first.
+func TestClient_Execute_UsingNamespace(t *testing.T) {
+	script := "using namespace System.IO\n[Path]::GetFileName('C:\\temp\\a.txt')"
+	completed := func(w io.Writer, output ...interface{}) {
+		sendState(t, w, messages.RunspacePoolStateOpened, messages.PipelineStateRunning, nil)
+		for _, v := range output {
+			sendOutput(t, w, v)
+		}
+		sendState(t, w, messages.RunspacePoolStateOpened, messages.PipelineStateCompleted, nil)
+	}
+
+	command, result, err := runMockExecute(t, script, ExecOptions{}, func(w io.Writer) {
+		completed(w, "a.txt")
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if command != script {
+		t.Errorf("command = %q, want the script unchanged", command)
+	}
+	if len(result.Output) != 1 || result.Output[0] != "a.txt" || result.ExitCode != nil {
+		t.Errorf("result = %+v", result)
+	}
+
+	command, result, err = runMockExecute(t, script, ExecOptions{ReportExitCode: true}, func(w io.Writer) {
+		completed(w, "a.txt", map[string]interface{}{execStatusMarker: true, "Success": true, "ExitCode": int32(3)})
+	})
+	if err != nil {
+		t.Fatalf("ExecuteWithOptions() error = %v", err)
+	}
+	if !strings.HasPrefix(command, "using namespace System.IO\n$global:LASTEXITCODE") {
+		t.Errorf("command = %q, want the using statement first", command)
+	}
+	if len(result.Output) != 1 || result.Output[0] != "a.txt" {
+		t.Errorf("Output = %v, want the status object removed", result.Output)
+	}
+	if result.ExitCode == nil || *result.ExitCode != 3 {
+		t.Errorf("ExitCode = %v, want 3", result.ExitCode)
+	}
+}
@@ -4,17 +4,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
+	"github.com/smnsjas/go-psrp/powershell"
 	"github.com/smnsjas/go-psrpcore/messages"
 	"github.com/smnsjas/go-psrpcore/pipeline"
 )
 
 // StreamResult represents the streaming result of a PowerShell command execution.
 // Use Wait() to block until completion or consume channels directly.
+// Objects() gives the same streams as deserialized values.
 type StreamResult struct {
-	pipeline *pipeline.Pipeline
-	ctx      context.Context
-	cleanup  func()
+	pipeline  *pipeline.Pipeline
+	ctx       context.Context
+	cleanup   func()
+	stop      func() // stops the pipeline on the server, at most once
+	serOpts   *SerializationOptions
+	dateTimes *DateTimeOptions
+
+	objectsOnce sync.Once
+	objects     *ObjectStream
+
+	// failure is the terminating error of the pipeline; failures records
+	// it on transports whose pipelines are read by the dispatch loop
+	failure  *pipelineFailure
+	failures powershell.PipelineFailureReporter
 
 	// Output streams - consume these channels to get output as it arrives
 	Output      <-chan *messages.Message
@@ -30,15 +44,39 @@ type StreamResult struct {
 // After Wait returns, all channels are closed.
 func (sr *StreamResult) Wait() error {
 	err := sr.pipeline.Wait()
+	if err != nil && sr.failures != nil {
+		if rec, ok := sr.failures.PipelineFailure(sr.pipeline.ID()); ok {
+			sr.failure.set(rec)
+		}
+	}
 	sr.cleanup()
 	return err
 }
 
-// Cancel cancels the pipeline execution.
+// TerminatingError returns the error that failed the pipeline, such as a
+// throw or a cmdlet failing under -ErrorAction Stop, as the server
+// reported it. It is nil until Wait returns, and if the pipeline did not
+// fail with an ErrorRecord.
+func (sr *StreamResult) TerminatingError() *ErrorRecord {
+	return sr.failure.get()
+}
+
+// Cancel stops the pipeline on the server, as Ctrl+C does, and cancels it
+// locally. On transports without signals it only cancels locally.
 func (sr *StreamResult) Cancel() {
+	if sr.stop != nil {
+		sr.stop()
+	}
 	sr.pipeline.Cancel()
 }
 
+// failureReporter returns backend as a PipelineFailureReporter, or nil if
+// its pipelines are not read by the dispatch loop.
+func failureReporter(backend powershell.RunspaceBackend) powershell.PipelineFailureReporter {
+	reporter, _ := backend.(powershell.PipelineFailureReporter)
+	return reporter
+}
+
 // ExecuteStream runs a PowerShell script asynchronously and returns a StreamResult
 // that provides access to output as it is produced.
 // The caller is responsible for consuming the output channels and calling Wait().
@@ -52,7 +90,23 @@ func (c *Client) ExecuteStreamWithInput(ctx context.Context, script string) (*St
 	return c.executeStreamInternal(ctx, script, false)
 }
 
-func (c *Client) executeStreamInternal(ctx context.Context, script string, closeInput bool) (*StreamResult, error) {
+func (c *Client) executeStreamInternal(ctx context.Context, script string, closeInput bool) (sr *StreamResult, err error) {
+	// Marked in use before connecting, so an idle close cannot detach the
+	// session in between
+	c.beginUse(ctx)
+	defer func() {
+		if err != nil {
+			c.endUse(ctx)
+		}
+	}()
+
+	// Heartbeats must not open a session closed for being idle
+	if !isHeartbeat(ctx) {
+		if err := c.connectLazily(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	// Acquire semaphore first
 	c.mu.Lock()
 	if c.semaphore == nil {
@@ -72,9 +126,14 @@ func (c *Client) executeStreamInternal(ctx context.Context, script string, close
 		return nil, err
 	}
 
+	c.mu.Lock()
+	failures := failureReporter(c.backend)
+	c.mu.Unlock()
+
 	// Start per-pipeline receive loop (for WSMan) or use dispatch loop (for HvSocket)
+	failure := &pipelineFailure{}
 	if pipelineTransport != nil {
-		go c.runPipelineReceive(ctx, pipelineTransport, psrpPipeline)
+		go c.runPipelineReceive(ctx, pipelineTransport, psrpPipeline, failure)
 	}
 	// For HvSocket: dispatch loop was started in Connect()
 
@@ -83,9 +142,22 @@ func (c *Client) executeStreamInternal(ctx context.Context, script string, close
 		_ = psrpPipeline.CloseInput(ctx)
 	}
 
-	sr := &StreamResult{
+	// Stop the pipeline on the server too when ctx ends; otherwise only the
+	// local side gives up and the command keeps running remotely.
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() { c.stopPipeline(ctx, psrpPipeline) })
+	}
+	stopOnCancel := context.AfterFunc(ctx, stop)
+	var endUseOnce sync.Once
+
+	sr = &StreamResult{
 		pipeline:    psrpPipeline,
 		ctx:         ctx,
+		failure:     failure,
+		failures:    failures,
+		serOpts:     c.config.Serialization,
+		dateTimes:   c.config.DateTimes,
 		Output:      psrpPipeline.Output(),
 		Errors:      psrpPipeline.Error(),
 		Warnings:    psrpPipeline.Warning(),
@@ -93,11 +165,15 @@ func (c *Client) executeStreamInternal(ctx context.Context, script string, close
 		Debug:       psrpPipeline.Debug(),
 		Progress:    psrpPipeline.Progress(),
 		Information: psrpPipeline.Information(),
+		stop:        stop,
 		cleanup: func() {
+			stopOnCancel()
+			stopOnce.Do(func() {}) // finished; nothing left to stop
 			if cleanupBackend != nil {
 				cleanupBackend()
 			}
 			sem.Release() // Release semaphore
+			endUseOnce.Do(func() { c.endUse(ctx) })
 		},
 	}
 
@@ -105,11 +181,89 @@ func (c *Client) executeStreamInternal(ctx context.Context, script string, close
 }
 
 // SendInput sends data to the pipeline input stream.
+// Values are converted according to Config.Serialization before being sent.
 func (sr *StreamResult) SendInput(ctx context.Context, data interface{}) error {
-	return sr.pipeline.SendInput(ctx, data)
+	return sr.pipeline.SendInput(ctx, normalizeInput(data, sr.serOpts))
 }
 
 // CloseInput closes the pipeline input stream.
 func (sr *StreamResult) CloseInput(ctx context.Context) error {
 	return sr.pipeline.CloseInput(ctx)
 }
+
+// ObjectStream carries the deserialized objects of each stream of a
+// StreamResult, one value per object, as the server sends them. Values have
+// the same types as in Result: primitives, or *serialization.PSObject for
+// complex objects such as ErrorRecords and ProgressRecords.
+type ObjectStream struct {
+	Output      <-chan interface{}
+	Errors      <-chan interface{}
+	Warnings    <-chan interface{}
+	Verbose     <-chan interface{}
+	Debug       <-chan interface{}
+	Progress    <-chan interface{}
+	Information <-chan interface{}
+}
+
+// Objects returns the streams of sr as deserialized objects:
+//
+//	stream, err := c.ExecuteStream(ctx, "Get-Process | Select-Object -First 3")
+//	if err != nil {
+//	    return err
+//	}
+//	objs := stream.Objects()
+//	for _, ch := range []<-chan interface{}{objs.Errors, objs.Warnings, objs.Verbose, objs.Debug, objs.Progress, objs.Information} {
+//	    go func() { for range ch {} }() // drain streams you don't need
+//	}
+//	for obj := range objs.Output {
+//	    fmt.Println(obj)
+//	}
+//	err = stream.Wait()
+//
+// The first call takes over the message channels (Output, Errors, ...);
+// use either Objects or the message channels, not both. As with the
+// message channels, every stream must be drained for the pipeline to
+// finish, unless the context passed to ExecuteStream is cancelled.
+func (sr *StreamResult) Objects() *ObjectStream {
+	sr.objectsOnce.Do(func() {
+		sr.objects = &ObjectStream{
+			Output:      sr.deserializeStream(sr.Output),
+			Errors:      sr.deserializeStream(sr.Errors),
+			Warnings:    sr.deserializeStream(sr.Warnings),
+			Verbose:     sr.deserializeStream(sr.Verbose),
+			Debug:       sr.deserializeStream(sr.Debug),
+			Progress:    sr.deserializeStream(sr.Progress),
+			Information: sr.deserializeStream(sr.Information),
+		}
+	})
+	return sr.objects
+}
+
+// deserializeStream converts messages from in to objects until in closes.
+// If the context ends, it stops sending and drains in, so an abandoned
+// consumer does not block the pipeline.
+func (sr *StreamResult) deserializeStream(in <-chan *messages.Message) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for msg := range in {
+			if msg == nil {
+				continue
+			}
+			values, err := deserializeCLIXML(msg.Data, sr.dateTimes)
+			if err != nil {
+				continue
+			}
+			for _, v := range values {
+				select {
+				case out <- v:
+				case <-sr.ctx.Done():
+					for range in {
+					}
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
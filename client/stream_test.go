@@ -11,6 +11,8 @@ import (
 	"github.com/smnsjas/go-psrpcore/messages"
 	"github.com/smnsjas/go-psrpcore/pipeline"
 	"github.com/smnsjas/go-psrpcore/runspace"
+
+	"github.com/smnsjas/go-psrp/powershell"
 )
 
 // TestExecuteStream_Streaming verifies that output is received as it is produced,
@@ -105,3 +107,145 @@ func TestExecuteStream_Streaming(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+// TestStreamResult_Objects verifies that Objects delivers deserialized
+// values as they arrive.
+func TestStreamResult_Objects(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	mockBackend := &MockBackend{
+		PrepareFunc: func(ctx context.Context, p *pipeline.Pipeline, payload string) (io.Reader, func(), error) {
+			return pr, func() { pr.Close() }, nil
+		},
+	}
+
+	c := &Client{
+		config:    DefaultConfig(),
+		backend:   mockBackend,
+		connected: true,
+		psrpPool:  runspace.New(&DummyReadWriter{}, uuid.New()),
+		semaphore: newPoolSemaphore(1, 0, time.Second),
+		callID:    newCallIDManager(),
+	}
+	c.psrpPool.ResumeOpened()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := c.ExecuteStream(ctx, "1; 2")
+	if err != nil {
+		t.Fatalf("ExecuteStream failed: %v", err)
+	}
+	objs := stream.Objects()
+	if stream.Objects() != objs {
+		t.Error("Objects() returned a different stream on the second call")
+	}
+	for _, ch := range []<-chan interface{}{objs.Errors, objs.Warnings, objs.Verbose, objs.Debug, objs.Progress, objs.Information} {
+		go func() {
+			for range ch {
+			}
+		}()
+	}
+
+	firstReceived := make(chan struct{})
+	go func() {
+		defer pw.Close()
+		sendOutput(t, pw, int32(1))
+		// Only send the rest once the first object was delivered
+		select {
+		case <-firstReceived:
+		case <-ctx.Done():
+			return
+		}
+		sendOutput(t, pw, int32(2))
+		sendState(t, pw, messages.RunspacePoolStateOpened, messages.PipelineStateCompleted, nil)
+	}()
+
+	var got []interface{}
+	for obj := range objs.Output {
+		got = append(got, obj)
+		if len(got) == 1 {
+			close(firstReceived)
+		}
+	}
+	if err := stream.Wait(); err != nil {
+		t.Errorf("Wait() failed: %v", err)
+	}
+	if len(got) != 2 || got[0] != int32(1) || got[1] != int32(2) {
+		t.Errorf("Output objects = %v, want [1 2]", got)
+	}
+}
+
+// TestExecuteStream_CancelStopsPipeline verifies that cancelling the
+// context stops the pipeline on the server, and that a finished pipeline is
+// not stopped.
+func TestExecuteStream_CancelStopsPipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		caps     powershell.Capabilities
+		cancel   bool
+		wantStop bool
+	}{
+		{"cancelled", powershell.WSManCapabilities, true, true},
+		{"cancelled without signal support", powershell.Capabilities{}, true, false},
+		{"finished", powershell.WSManCapabilities, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stopped := make(chan uuid.UUID, 1)
+			mockBackend := &MockBackend{
+				CapabilitiesFunc: func() powershell.Capabilities { return tt.caps },
+				StopPipelineFunc: func(ctx context.Context, p *pipeline.Pipeline) error {
+					if ctx.Err() != nil {
+						t.Errorf("StopPipeline ctx already done: %v", ctx.Err())
+					}
+					stopped <- p.ID()
+					return nil
+				},
+			}
+			c := &Client{
+				config:    DefaultConfig(),
+				backend:   mockBackend,
+				connected: true,
+				psrpPool:  runspace.New(&DummyReadWriter{}, uuid.New()),
+				semaphore: newPoolSemaphore(1, 0, time.Second),
+				callID:    newCallIDManager(),
+			}
+			c.psrpPool.ResumeOpened()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stream, err := c.ExecuteStream(ctx, "Start-Sleep 60")
+			if err != nil {
+				t.Fatalf("ExecuteStream failed: %v", err)
+			}
+			if !tt.cancel {
+				stream.cleanup()
+			}
+			cancel()
+
+			select {
+			case id := <-stopped:
+				if !tt.wantStop {
+					t.Fatal("StopPipeline called, want not called")
+				}
+				if id != stream.pipeline.ID() {
+					t.Errorf("StopPipeline pipeline = %s, want %s", id, stream.pipeline.ID())
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.wantStop {
+					t.Fatal("StopPipeline not called after cancel")
+				}
+			}
+
+			// Cancel after the context stop must not signal again
+			stream.Cancel()
+			select {
+			case <-stopped:
+				t.Error("StopPipeline called twice")
+			default:
+			}
+		})
+	}
+}
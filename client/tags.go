@@ -0,0 +1,33 @@
+package client
+
+import (
+	"log/slog"
+	"maps"
+	"sort"
+)
+
+// Tags returns a copy of the session tags (Config.Tags), for example to label
+// application metrics with the same values that appear in the logs.
+func (c *Client) Tags() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.config.Tags)
+}
+
+// withTags adds tags to every record of logger as a "tags" group.
+func withTags(logger *slog.Logger, tags map[string]string) *slog.Logger {
+	if logger == nil || len(tags) == 0 {
+		return logger
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]any, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slog.String(k, tags[k]))
+	}
+	return logger.With(slog.Group("tags", attrs...))
+}
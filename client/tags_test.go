@@ -0,0 +1,64 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestWithTags(t *testing.T) {
+	var buf bytes.Buffer
+	logger := withTags(slog.New(slog.NewJSONHandler(&buf, nil)), map[string]string{
+		"team":   "platform",
+		"ticket": "CHG-1234",
+	})
+	logger.Info("connected")
+
+	var record struct {
+		Tags map[string]string `json:"tags"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal log record: %v", err)
+	}
+	if record.Tags["team"] != "platform" || record.Tags["ticket"] != "CHG-1234" {
+		t.Errorf("tags = %v", record.Tags)
+	}
+
+	if withTags(nil, map[string]string{"a": "b"}) != nil {
+		t.Error("withTags(nil) should return nil")
+	}
+	base := slog.Default()
+	if withTags(base, nil) != base {
+		t.Error("withTags without tags should return the logger unchanged")
+	}
+}
+
+func TestSecurityLogger_Tags(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSecurityLogger(slog.New(slog.NewJSONHandler(&buf, nil)), "user", "server")
+	l.tags = map[string]string{"purpose": "patching"}
+	l.LogEvent(EventCommand, SubtypeCommandExecute, SeverityInfo, OutcomeSuccess, nil)
+
+	var record struct {
+		Event SecurityEvent `json:"event"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal log record: %v", err)
+	}
+	if record.Event.Tags["purpose"] != "patching" {
+		t.Errorf("event tags = %v", record.Event.Tags)
+	}
+}
+
+func TestClient_Tags(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Tags = map[string]string{"team": "platform"}
+	c := &Client{config: cfg}
+
+	tags := c.Tags()
+	tags["team"] = "changed"
+	if c.config.Tags["team"] != "platform" {
+		t.Error("Tags() returned the config map instead of a copy")
+	}
+}
@@ -0,0 +1,243 @@
+//go:build !psrp_nootel
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// InstrumentationName is the OpenTelemetry instrumentation scope of the
+// spans and metrics of this package. WSMan requests are instrumented
+// under wsman.InstrumentationName.
+const InstrumentationName = "github.com/smnsjas/go-psrp/client"
+
+// clientTelemetry holds the OpenTelemetry instruments of a Client.
+type clientTelemetry struct {
+	tracer        trace.Tracer
+	attrs         []attribute.KeyValue
+	execDuration  metric.Float64Histogram
+	execRetries   metric.Int64Counter
+	transferBytes metric.Int64Counter
+	authDuration  metric.Float64Histogram
+	authChallenge metric.Int64Counter
+	registration  metric.Registration
+}
+
+// newClientTelemetry returns the instruments of c, or nil if no provider
+// is configured.
+func newClientTelemetry(c *Client) *clientTelemetry {
+	opts := c.config.telemetryOptions()
+	if opts.TracerProvider == nil && opts.MeterProvider == nil {
+		return nil
+	}
+	tp := opts.TracerProvider
+	if tp == nil {
+		tp = tracenoop.NewTracerProvider()
+	}
+	mp := opts.MeterProvider
+	if mp == nil {
+		mp = metricnoop.NewMeterProvider()
+	}
+
+	t := &clientTelemetry{
+		tracer: tp.Tracer(InstrumentationName),
+		attrs: []attribute.KeyValue{
+			attribute.String("server.address", c.hostname),
+			attribute.String("psrp.transport", c.config.Transport.String()),
+		},
+	}
+
+	// Instrument errors only occur for invalid names; the instruments are
+	// then no-ops
+	meter := mp.Meter(InstrumentationName)
+	t.execDuration, _ = meter.Float64Histogram("psrp.client.execute.duration",
+		metric.WithDescription("Duration of Execute calls, including retries"), metric.WithUnit("s"))
+	t.execRetries, _ = meter.Int64Counter("psrp.client.execute.retries",
+		metric.WithDescription("Retries of failed Execute attempts"))
+	t.transferBytes, _ = meter.Int64Counter("psrp.client.transfer.bytes",
+		metric.WithDescription("File bytes transferred"), metric.WithUnit("By"))
+	t.authDuration, _ = meter.Float64Histogram("psrp.client.auth.duration",
+		metric.WithDescription("Duration of authentication handshakes"), metric.WithUnit("s"))
+	t.authChallenge, _ = meter.Int64Counter("psrp.client.auth.challenges",
+		metric.WithDescription("HTTP 401 challenges received"))
+
+	active, err := meter.Int64ObservableGauge("psrp.client.runspaces.active",
+		metric.WithDescription("Runspaces running a command"))
+	if err == nil {
+		t.registration, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+			c.mu.Lock()
+			sem := c.semaphore
+			c.mu.Unlock()
+			if sem != nil {
+				n, _, _ := sem.Stats()
+				o.ObserveInt64(active, int64(n), metric.WithAttributes(t.attrs...))
+			}
+			return nil
+		}, active)
+	}
+	return t
+}
+
+// close unregisters the runspace gauge.
+func (t *clientTelemetry) close() {
+	if t != nil && t.registration != nil {
+		_ = t.registration.Unregister()
+	}
+}
+
+// startExecute starts the span of an Execute call. The script is not
+// recorded, since it may contain secrets. end finishes the span and
+// records the duration.
+func (t *clientTelemetry) startExecute(ctx context.Context) (context.Context, func(*Result, error)) {
+	if t == nil {
+		return ctx, func(*Result, error) {}
+	}
+	ctx, span := t.tracer.Start(ctx, "PSRP Execute",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(t.attrs...))
+
+	start := time.Now()
+	return ctx, func(result *Result, err error) {
+		attrs := t.attrs
+		if result != nil {
+			span.SetAttributes(attribute.Bool("psrp.had_errors", result.HadErrors))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			attrs = append(attrs[:len(attrs):len(attrs)], attribute.String("error.type", executeErrorType(err)))
+		}
+		span.End()
+		t.execDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+	}
+}
+
+// executeErrorType is the low-cardinality error.type attribute of an
+// Execute error.
+func executeErrorType(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, ErrSessionLost):
+		return "session_lost"
+	case errors.Is(err, ErrQueueFull), errors.Is(err, ErrAcquireTimeout):
+		return "queue"
+	case isRetryableError(err):
+		return "transient"
+	}
+	return "error"
+}
+
+// retried records a retry of an Execute attempt.
+func (t *clientTelemetry) retried(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.execRetries.Add(ctx, 1, metric.WithAttributes(t.attrs...))
+}
+
+// startTransfer starts the span of a file transfer. end finishes it and
+// counts size bytes as transferred if err is nil.
+func (t *clientTelemetry) startTransfer(ctx context.Context, operation, remotePath string) (context.Context, func(size int64, err error)) {
+	if t == nil {
+		return ctx, func(int64, error) {}
+	}
+	direction := "upload"
+	if operation == "FetchFile" {
+		direction = "download"
+	}
+	ctx, span := t.tracer.Start(ctx, "PSRP "+operation,
+		trace.WithAttributes(t.attrs...),
+		trace.WithAttributes(
+			attribute.String("psrp.transfer.direction", direction),
+			attribute.String("psrp.transfer.remote_path", remotePath)))
+
+	return ctx, func(size int64, err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(attribute.Int64("psrp.transfer.bytes", size))
+			attrs := append(t.attrs[:len(t.attrs):len(t.attrs)], attribute.String("psrp.transfer.direction", direction))
+			t.transferBytes.Add(ctx, size, metric.WithAttributes(attrs...))
+		}
+		span.End()
+	}
+}
+
+// challengeCountKey is the context key of the 401 counter of a request.
+type challengeCountKey struct{}
+
+// traceAuth runs rt for req and, if the server challenged it, records
+// the handshake as a span from the start of the request.
+func (t *clientTelemetry) traceAuth(req *http.Request, scheme string, rt http.RoundTripper) (*http.Response, error) {
+	if t == nil {
+		return rt.RoundTrip(req)
+	}
+
+	var challenges atomic.Int32
+	start := time.Now()
+	resp, err := rt.RoundTrip(req.WithContext(context.WithValue(req.Context(), challengeCountKey{}, &challenges)))
+	n := challenges.Load()
+	if n == 0 {
+		return resp, err
+	}
+
+	attrs := append(t.attrs[:len(t.attrs):len(t.attrs)], attribute.String("auth.scheme", strings.ToLower(scheme)))
+	_, span := t.tracer.Start(req.Context(), "Auth "+scheme,
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(attribute.Int("auth.challenges", int(n))))
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		attrs = append(attrs, attribute.String("error.type", "network"))
+	case resp.StatusCode == http.StatusUnauthorized:
+		span.SetStatus(codes.Error, "authentication rejected")
+		attrs = append(attrs, attribute.String("error.type", "rejected"))
+	}
+	span.End()
+	t.authDuration.Record(req.Context(), time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+	return resp, err
+}
+
+// challengeCounter counts the 401 responses of the requests an
+// authenticator sends for one request.
+type challengeCounter struct {
+	base http.RoundTripper
+	tel  *clientTelemetry
+}
+
+// RoundTrip implements http.RoundTripper.
+func (cc *challengeCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := cc.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		if n, ok := req.Context().Value(challengeCountKey{}).(*atomic.Int32); ok {
+			n.Add(1)
+		}
+		cc.tel.authChallenge.Add(req.Context(), 1, metric.WithAttributes(cc.tel.attrs...))
+	}
+	return resp, err
+}
+
+// CloseIdleConnections forwards to the base transport.
+func (cc *challengeCounter) CloseIdleConnections() {
+	if ci, ok := cc.base.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
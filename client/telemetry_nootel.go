@@ -0,0 +1,49 @@
+//go:build psrp_nootel
+
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// clientTelemetry records nothing in programs built with psrp_nootel.
+type clientTelemetry struct{}
+
+func newClientTelemetry(*Client) *clientTelemetry {
+	return nil
+}
+
+func (t *clientTelemetry) close() {}
+
+func (t *clientTelemetry) startExecute(ctx context.Context) (context.Context, func(*Result, error)) {
+	return ctx, func(*Result, error) {}
+}
+
+func (t *clientTelemetry) retried(context.Context) {}
+
+func (t *clientTelemetry) startTransfer(ctx context.Context, _, _ string) (context.Context, func(size int64, err error)) {
+	return ctx, func(int64, error) {}
+}
+
+func (t *clientTelemetry) traceAuth(req *http.Request, _ string, rt http.RoundTripper) (*http.Response, error) {
+	return rt.RoundTrip(req)
+}
+
+// challengeCounter forwards to base in programs built with psrp_nootel.
+type challengeCounter struct {
+	base http.RoundTripper
+	tel  *clientTelemetry
+}
+
+// RoundTrip implements http.RoundTripper.
+func (cc *challengeCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	return cc.base.RoundTrip(req)
+}
+
+// CloseIdleConnections forwards to the base transport.
+func (cc *challengeCounter) CloseIdleConnections() {
+	if ci, ok := cc.base.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,59 @@
+package client
+
+import "github.com/smnsjas/go-psrp/wsman"
+
+// TelemetryOptions configures OpenTelemetry instrumentation.
+type TelemetryOptions struct {
+	// TracerProvider creates the spans of Execute, file transfers, auth
+	// handshakes and WSMan requests. Nil disables tracing, as does
+	// building with psrp_nootel.
+	TracerProvider wsman.TracerProvider
+
+	// MeterProvider creates the metrics. Nil disables metrics.
+	MeterProvider wsman.MeterProvider
+
+	// PropagateTraceContext adds a W3C traceparent SOAP header to WSMan
+	// requests. WinRM ignores it; it lets a proxy or endpoint that logs
+	// SOAP headers correlate requests with the client's traces.
+	PropagateTraceContext bool
+}
+
+// TelemetryOption configures TelemetryOptions.
+type TelemetryOption func(*TelemetryOptions)
+
+// WithTracerProvider sets the TracerProvider of the spans.
+func WithTracerProvider(tp wsman.TracerProvider) TelemetryOption {
+	return func(o *TelemetryOptions) { o.TracerProvider = tp }
+}
+
+// WithMeterProvider sets the MeterProvider of the metrics.
+func WithMeterProvider(mp wsman.MeterProvider) TelemetryOption {
+	return func(o *TelemetryOptions) { o.MeterProvider = mp }
+}
+
+// WithTraceContextPropagation enables the traceparent SOAP header.
+func WithTraceContextPropagation(enabled bool) TelemetryOption {
+	return func(o *TelemetryOptions) { o.PropagateTraceContext = enabled }
+}
+
+// telemetryOptions returns the options set by cfg.Telemetry.
+func (cfg *Config) telemetryOptions() TelemetryOptions {
+	var opts TelemetryOptions
+	for _, fn := range cfg.Telemetry {
+		fn(&opts)
+	}
+	return opts
+}
+
+// applyTelemetry passes the providers to the WSMan client, unless
+// WSManOptions already has its own.
+func (cfg *Config) applyTelemetry() {
+	opts := cfg.telemetryOptions()
+	if cfg.WSManOptions.TracerProvider == nil {
+		cfg.WSManOptions.TracerProvider = opts.TracerProvider
+	}
+	if cfg.WSManOptions.MeterProvider == nil {
+		cfg.WSManOptions.MeterProvider = opts.MeterProvider
+	}
+	cfg.WSManOptions.PropagateTraceContext = cfg.WSManOptions.PropagateTraceContext || opts.PropagateTraceContext
+}
@@ -0,0 +1,122 @@
+//go:build !psrp_nootel
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestNew_TelemetryOptions(t *testing.T) {
+	tp := tracenoop.NewTracerProvider()
+	mp := metricnoop.NewMeterProvider()
+
+	cfg := DefaultConfig()
+	cfg.AuthType = AuthBasic
+	cfg.Username = "svc"
+	cfg.Password = "secret"
+	cfg.Telemetry = []TelemetryOption{WithTracerProvider(tp), WithMeterProvider(mp), WithTraceContextPropagation(true)}
+
+	c, err := New("server", cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if c.tel == nil {
+		t.Fatal("telemetry not enabled")
+	}
+	opts := c.config.WSManOptions
+	if opts.TracerProvider != tp || opts.MeterProvider != mp || !opts.PropagateTraceContext {
+		t.Errorf("WSManOptions = %+v, want the telemetry providers", opts)
+	}
+
+	cfg.Telemetry = nil
+	cfg.WSManOptions = DefaultConfig().WSManOptions
+	c, err = New("server", cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if c.tel != nil {
+		t.Error("telemetry enabled without providers")
+	}
+}
+
+func TestAuthRoundTripper_CountsChallenges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); !ok {
+			w.Header().Set("WWW-Authenticate", "Basic")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("<ok/>"))
+	}))
+	defer server.Close()
+
+	tel := &clientTelemetry{tracer: tracenoop.NewTracerProvider().Tracer("test")}
+	meter := metricnoop.NewMeterProvider().Meter("test")
+	tel.authDuration, _ = meter.Float64Histogram("d")
+	tel.authChallenge, _ = meter.Int64Counter("c")
+
+	var challenges int32
+	rt := &challengeCounter{base: http.DefaultTransport, tel: tel}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := tel.traceAuth(req, "Basic", roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := rt.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		resp.Body.Close()
+		// traceAuth passes the counter that challengeCounter increments
+		if n, ok := req.Context().Value(challengeCountKey{}).(*atomic.Int32); ok {
+			challenges = n.Load()
+		}
+		retry := req.Clone(req.Context())
+		retry.SetBasicAuth("svc", "secret")
+		return rt.RoundTrip(retry)
+	}))
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || challenges != 1 {
+		t.Errorf("status = %d, challenges = %d; want 200 after 1 challenge", resp.StatusCode, challenges)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestExecuteErrorType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{context.Canceled, "canceled"},
+		{fmt.Errorf("wait: %w", context.DeadlineExceeded), "timeout"},
+		{fmt.Errorf("%w: gone", ErrSessionLost), "session_lost"},
+		{ErrQueueFull, "queue"},
+		{ErrScriptErrors, "error"},
+	}
+	for _, tt := range tests {
+		if got := executeErrorType(tt.err); got != tt.want {
+			t.Errorf("executeErrorType(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestClientTelemetry_NilIsNoop(t *testing.T) {
+	var tel *clientTelemetry
+	ctx, end := tel.startExecute(context.Background())
+	end(nil, nil)
+	_, endTransfer := tel.startTransfer(ctx, "CopyFile", "b")
+	endTransfer(0, nil)
+	tel.retried(ctx)
+	tel.close()
+}
@@ -0,0 +1,289 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/smnsjas/go-psrp/wsman/auth"
+)
+
+// ErrTenantPoolClosed is returned when using a TenantPool after Close.
+var ErrTenantPoolClosed = errors.New("client: tenant pool is closed")
+
+// TenantPool runs requests as different users against one host, for gateway
+// services that execute on behalf of many callers.
+//
+// Each distinct set of credentials gets its own Client (and therefore its own
+// HTTP transport, authenticated connections, WSMan shell and RunspacePool).
+// Nothing that carries an identity is shared between tenants:
+//
+//   - Transports are never shared. NTLM and Kerberos authenticate the TCP
+//     connection (and, over HTTP, seal messages with per-context keys), so a
+//     shared connection would let one tenant's request run as another.
+//   - Sub-clients are keyed by a hash of domain, username AND password. A
+//     request with the right username but a wrong password never reuses an
+//     authenticated session; it creates a new client and fails to authenticate.
+//   - Config.ResultCache is not applied to sub-clients, because the cache is
+//     keyed by host and script only and would serve one tenant's results to another.
+//   - Runspace state (variables, imported modules, the current location) is
+//     per tenant but persists across that tenant's requests.
+//
+// Idle sub-clients are closed in least-recently-used order once MaxClients is
+// exceeded. A TenantPool is safe for concurrent use.
+type TenantPool struct {
+	hostname string
+	base     Config
+
+	// MaxClients caps the number of open sub-clients. When exceeded, the least
+	// recently used client that is not executing is closed.
+	// Default: 0 (unlimited).
+	MaxClients int
+
+	// dial creates and connects a sub-client. Overridable for tests.
+	dial func(ctx context.Context, cfg Config) (*Client, error)
+
+	mu      sync.Mutex
+	closed  bool
+	tenants map[string]*tenantEntry
+	lru     *list.List // of *tenantEntry, most recently used at the front
+}
+
+type tenantEntry struct {
+	key   string
+	elem  *list.Element
+	ready chan struct{} // closed once client/err are set
+	inUse int
+
+	client *Client
+	err    error
+}
+
+// NewTenantPool creates a pool of per-credential clients for hostname.
+// base supplies every setting except the credentials; its Username, Password,
+// Domain, KeytabPath, CCachePath, Authenticator, SSH.PrivateKeyPath and the
+// client certificates in TLS are ignored, and UseSSPI is turned off so no
+// tenant runs as the process's logged-on user.
+func NewTenantPool(hostname string, base Config) *TenantPool {
+	base.Username = ""
+	base.Password = ""
+	base.Domain = ""
+	base.KeytabPath = ""
+	base.CCachePath = ""
+	base.Authenticator = nil
+	base.UseSSPI = false
+	if base.TLS != nil {
+		base.TLS = base.TLS.Clone()
+		base.TLS.Certificates = nil
+		base.TLS.GetClientCertificate = nil
+	}
+	if base.SSH != nil {
+		ssh := *base.SSH
+		ssh.PrivateKeyPath = ""
+		base.SSH = &ssh
+	}
+	base.ResultCache = nil
+
+	return &TenantPool{
+		hostname: hostname,
+		base:     base,
+		tenants:  make(map[string]*tenantEntry),
+		lru:      list.New(),
+		dial: func(ctx context.Context, cfg Config) (*Client, error) {
+			c, err := New(hostname, cfg)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.Connect(ctx); err != nil {
+				_ = c.Close(context.Background())
+				return nil, err
+			}
+			return c, nil
+		},
+	}
+}
+
+// tenantKey derives the sub-client key for a set of credentials.
+// The password is included so only callers presenting the same secret share a client.
+func tenantKey(creds auth.Credentials) string {
+	sum := sha256.Sum256([]byte(creds.Domain + "\x00" + creds.Username + "\x00" + creds.Password))
+	return hex.EncodeToString(sum[:])
+}
+
+// Execute runs a script as the given user.
+func (p *TenantPool) Execute(ctx context.Context, creds auth.Credentials, script string) (*Result, error) {
+	var result *Result
+	err := p.Do(ctx, creds, func(c *Client) error {
+		var err error
+		result, err = c.Execute(ctx, script)
+		return err
+	})
+	return result, err
+}
+
+// Do calls fn with the sub-client for the given user, creating and connecting
+// it if needed. The client is not closed or evicted while fn runs.
+// fn must not retain the client after it returns.
+func (p *TenantPool) Do(ctx context.Context, creds auth.Credentials, fn func(c *Client) error) error {
+	if creds.Username == "" {
+		return errors.New("client: tenant username is required")
+	}
+
+	entry, err := p.acquire(ctx, creds)
+	if err != nil {
+		return err
+	}
+	defer p.release(entry)
+
+	return fn(entry.client)
+}
+
+// acquire returns a connected entry for creds, marking it in use.
+func (p *TenantPool) acquire(ctx context.Context, creds auth.Credentials) (*tenantEntry, error) {
+	key := tenantKey(creds)
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrTenantPoolClosed
+	}
+	entry, ok := p.tenants[key]
+	if !ok {
+		entry = &tenantEntry{key: key, ready: make(chan struct{})}
+		entry.elem = p.lru.PushFront(entry)
+		p.tenants[key] = entry
+	} else {
+		p.lru.MoveToFront(entry.elem)
+	}
+	entry.inUse++
+	p.mu.Unlock()
+
+	if !ok {
+		cfg := p.base
+		cfg.Username = creds.Username
+		cfg.Password = creds.Password
+		cfg.Domain = creds.Domain
+		c, err := p.dial(ctx, cfg)
+		p.mu.Lock()
+		entry.client, entry.err = c, err
+		p.mu.Unlock()
+		close(entry.ready)
+		if entry.err == nil {
+			p.evictIdle()
+		}
+	}
+
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		p.release(entry)
+		return nil, ctx.Err()
+	}
+
+	if entry.err != nil {
+		// Failed clients are dropped so the next request retries.
+		p.mu.Lock()
+		p.removeLocked(entry)
+		p.mu.Unlock()
+		p.release(entry)
+		return nil, fmt.Errorf("connect tenant %s: %w", creds.Username, entry.err)
+	}
+	return entry, nil
+}
+
+// release marks an entry as no longer in use.
+func (p *TenantPool) release(entry *tenantEntry) {
+	p.mu.Lock()
+	entry.inUse--
+	p.mu.Unlock()
+	p.evictIdle()
+}
+
+// removeLocked removes an entry from the pool. p.mu must be held.
+func (p *TenantPool) removeLocked(entry *tenantEntry) {
+	if p.tenants[entry.key] == entry {
+		delete(p.tenants, entry.key)
+		p.lru.Remove(entry.elem)
+	}
+}
+
+// evictIdle closes least recently used idle clients while over MaxClients.
+func (p *TenantPool) evictIdle() {
+	var victims []*Client
+
+	p.mu.Lock()
+	for e := p.lru.Back(); e != nil && p.MaxClients > 0 && p.lru.Len() > p.MaxClients; {
+		prev := e.Prev()
+		entry := e.Value.(*tenantEntry)
+		if entry.inUse == 0 && entry.client != nil {
+			p.removeLocked(entry)
+			victims = append(victims, entry.client)
+		}
+		e = prev
+	}
+	p.mu.Unlock()
+
+	for _, c := range victims {
+		_ = c.Close(context.Background())
+	}
+}
+
+// Evict closes and removes the sub-client for the given user, if present
+// (e.g., after the user's credentials were revoked). Requests currently
+// running as that user fail.
+func (p *TenantPool) Evict(ctx context.Context, creds auth.Credentials) error {
+	p.mu.Lock()
+	entry, ok := p.tenants[tenantKey(creds)]
+	if ok {
+		p.removeLocked(entry)
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	<-entry.ready
+	if entry.client == nil {
+		return nil
+	}
+	return entry.client.Close(ctx)
+}
+
+// Len returns the number of sub-clients in the pool.
+func (p *TenantPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.tenants)
+}
+
+// Close closes all sub-clients. The pool cannot be used afterwards.
+func (p *TenantPool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	entries := make([]*tenantEntry, 0, len(p.tenants))
+	for _, entry := range p.tenants {
+		entries = append(entries, entry)
+	}
+	p.tenants = make(map[string]*tenantEntry)
+	p.lru.Init()
+	p.mu.Unlock()
+
+	var errs []error
+	for _, entry := range entries {
+		<-entry.ready
+		if entry.client != nil {
+			if err := entry.client.Close(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/wsman/auth"
+)
+
+// newTestTenantPool returns a pool whose sub-clients are created without connecting.
+func newTestTenantPool(t *testing.T) (*TenantPool, *[]Config) {
+	t.Helper()
+	base := DefaultConfig()
+	base.Username = "gateway"
+	base.Password = "gateway-secret"
+	base.KeytabPath = "/etc/gateway.keytab"
+	base.CCachePath = "/tmp/krb5cc_gateway"
+	base.UseSSPI = true
+	base.SSH = &SSHOptions{PrivateKeyPath: "/home/gateway/.ssh/id_ed25519"}
+	base.TLS = &tls.Config{Certificates: []tls.Certificate{{}}}
+	base.ResultCache = NewResultCache(0)
+
+	p := NewTenantPool("server", base)
+	var mu sync.Mutex
+	var dialed []Config
+	p.dial = func(_ context.Context, cfg Config) (*Client, error) {
+		mu.Lock()
+		dialed = append(dialed, cfg)
+		mu.Unlock()
+		if cfg.Password == "bad" {
+			return nil, errors.New("401 Unauthorized")
+		}
+		return &Client{hostname: "server", config: cfg}, nil
+	}
+	return p, &dialed
+}
+
+func TestTenantPool_Isolation(t *testing.T) {
+	p, dialed := newTestTenantPool(t)
+	ctx := context.Background()
+
+	alice := auth.Credentials{Username: "alice", Password: "a-secret", Domain: "CORP"}
+	bob := auth.Credentials{Username: "bob", Password: "b-secret", Domain: "CORP"}
+
+	var clients []*Client
+	for _, creds := range []auth.Credentials{alice, bob, alice} {
+		err := p.Do(ctx, creds, func(c *Client) error {
+			clients = append(clients, c)
+			if c.config.Username != creds.Username || c.config.Password != creds.Password {
+				t.Errorf("client for %s has credentials of %s", creds.Username, c.config.Username)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Do(%s) error = %v", creds.Username, err)
+		}
+	}
+
+	if clients[0] == clients[1] {
+		t.Error("alice and bob share a client")
+	}
+	if clients[0] != clients[2] {
+		t.Error("alice's second request did not reuse her client")
+	}
+	if len(*dialed) != 2 {
+		t.Errorf("dialed %d clients; want 2", len(*dialed))
+	}
+	for _, cfg := range *dialed {
+		if cfg.ResultCache != nil {
+			t.Error("sub-client config has a shared ResultCache")
+		}
+		if cfg.KeytabPath != "" || cfg.CCachePath != "" {
+			t.Errorf("sub-client config has the gateway's Kerberos credentials (keytab %q, ccache %q)", cfg.KeytabPath, cfg.CCachePath)
+		}
+		if cfg.UseSSPI {
+			t.Error("sub-client config uses SSPI with the process's logon session")
+		}
+		if cfg.SSH.PrivateKeyPath != "" {
+			t.Errorf("sub-client config has the gateway's SSH key %q", cfg.SSH.PrivateKeyPath)
+		}
+		if len(cfg.TLS.Certificates) != 0 {
+			t.Error("sub-client config has the gateway's TLS client certificate")
+		}
+	}
+}
+
+func TestTenantPool_IgnoresBaseAuthenticator(t *testing.T) {
+	base := DefaultConfig()
+	base.Authenticator = auth.NewBasicAuth(auth.Credentials{Username: "gateway", Password: "gateway-secret"})
+
+	p := NewTenantPool("server", base)
+	var dialed Config
+	p.dial = func(_ context.Context, cfg Config) (*Client, error) {
+		dialed = cfg
+		return &Client{hostname: "server", config: cfg}, nil
+	}
+
+	alice := auth.Credentials{Username: "alice", Password: "a-secret"}
+	if err := p.Do(context.Background(), alice, func(*Client) error { return nil }); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if dialed.Authenticator != nil {
+		t.Error("sub-client config has the base Authenticator; alice would run as the gateway")
+	}
+	if dialed.Username != alice.Username {
+		t.Errorf("sub-client Username = %q; want %q", dialed.Username, alice.Username)
+	}
+}
+
+func TestTenantPool_WrongPasswordDoesNotReuseSession(t *testing.T) {
+	p, dialed := newTestTenantPool(t)
+	ctx := context.Background()
+
+	good := auth.Credentials{Username: "alice", Password: "a-secret"}
+	bad := auth.Credentials{Username: "alice", Password: "bad"}
+
+	if err := p.Do(ctx, good, func(*Client) error { return nil }); err != nil {
+		t.Fatalf("Do(good) error = %v", err)
+	}
+	err := p.Do(ctx, bad, func(*Client) error {
+		t.Error("fn called with wrong password")
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Do(bad) error = nil; want authentication failure")
+	}
+	if len(*dialed) != 2 {
+		t.Errorf("dialed %d clients; want 2", len(*dialed))
+	}
+	if p.Len() != 1 {
+		t.Errorf("Len() = %d; want 1 (failed client dropped)", p.Len())
+	}
+}
+
+func TestTenantPool_MaxClients(t *testing.T) {
+	p, _ := newTestTenantPool(t)
+	p.MaxClients = 2
+	ctx := context.Background()
+
+	var first *Client
+	for i, user := range []string{"u1", "u2", "u3"} {
+		err := p.Do(ctx, auth.Credentials{Username: user, Password: "x"}, func(c *Client) error {
+			if i == 0 {
+				first = c
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Do(%s) error = %v", user, err)
+		}
+	}
+
+	if p.Len() != 2 {
+		t.Errorf("Len() = %d; want 2", p.Len())
+	}
+	if !first.closed {
+		t.Error("least recently used client was not closed")
+	}
+}
+
+func TestTenantPool_MaxClientsKeepsInUse(t *testing.T) {
+	p, _ := newTestTenantPool(t)
+	p.MaxClients = 1
+	ctx := context.Background()
+
+	err := p.Do(ctx, auth.Credentials{Username: "u1", Password: "x"}, func(busy *Client) error {
+		if err := p.Do(ctx, auth.Credentials{Username: "u2", Password: "x"}, func(*Client) error { return nil }); err != nil {
+			return err
+		}
+		if busy.closed {
+			t.Error("client closed while in use")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if p.Len() != 1 {
+		t.Errorf("Len() = %d; want 1", p.Len())
+	}
+}
+
+func TestTenantPool_EvictAndClose(t *testing.T) {
+	p, _ := newTestTenantPool(t)
+	ctx := context.Background()
+	alice := auth.Credentials{Username: "alice", Password: "a-secret"}
+	bob := auth.Credentials{Username: "bob", Password: "b-secret"}
+
+	var aliceClient, bobClient *Client
+	_ = p.Do(ctx, alice, func(c *Client) error { aliceClient = c; return nil })
+	_ = p.Do(ctx, bob, func(c *Client) error { bobClient = c; return nil })
+
+	if err := p.Evict(ctx, alice); err != nil {
+		t.Fatalf("Evict() error = %v", err)
+	}
+	if !aliceClient.closed || p.Len() != 1 {
+		t.Errorf("after Evict: closed = %v, Len() = %d", aliceClient.closed, p.Len())
+	}
+
+	if err := p.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !bobClient.closed {
+		t.Error("Close() did not close sub-clients")
+	}
+	if err := p.Do(ctx, bob, func(*Client) error { return nil }); !errors.Is(err, ErrTenantPoolClosed) {
+		t.Errorf("Do() after Close error = %v; want ErrTenantPoolClosed", err)
+	}
+}
+
+func TestTenantPool_RequiresUsername(t *testing.T) {
+	p, _ := newTestTenantPool(t)
+	if err := p.Do(context.Background(), auth.Credentials{}, func(*Client) error { return nil }); err == nil {
+		t.Error("Do() with empty username error = nil; want error")
+	}
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// operationTimeoutKey is the context key of WithOperationTimeout.
+type operationTimeoutKey struct{}
+
+// WithOperationTimeout returns a context that limits each client operation
+// started with it to d, counted from the start of that operation. Unlike
+// context.WithTimeout, the same context can be reused for many operations,
+// each getting the full d:
+//
+//	ctx := client.WithOperationTimeout(ctx, 30*time.Second)
+//	c.Execute(ctx, "Get-Service") // up to 30s
+//	c.Execute(ctx, "Get-Process") // another 30s
+//
+// It applies to Connect, Execute (and its variants that return a Result),
+// CopyFile, FetchFile, CopyDirectory, FetchDirectory, Disconnect, Reconnect
+// and Close. A deadline or cancellation of ctx still ends the operation
+// earlier. Operations nested in another, such as the chunks of a file
+// transfer, share the outer operation's deadline.
+func WithOperationTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, operationTimeoutKey{}, d)
+}
+
+// OperationTimeout returns the timeout set on ctx by WithOperationTimeout.
+func OperationTimeout(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(operationTimeoutKey{}).(time.Duration)
+	return d, ok && d > 0
+}
+
+// operationContext applies the operation timeout of ctx, if any. The
+// returned context no longer carries it, so nested operations do not
+// restart it.
+func operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	d, ok := OperationTimeout(ctx)
+	if !ok {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithTimeout(ctx, d)
+	return context.WithValue(ctx, operationTimeoutKey{}, time.Duration(0)), cancel
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOperationContext(t *testing.T) {
+	base := context.Background()
+	ctx, cancel := operationContext(base)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("deadline set without WithOperationTimeout")
+	}
+
+	opCtx := WithOperationTimeout(base, time.Minute)
+	if d, ok := OperationTimeout(opCtx); !ok || d != time.Minute {
+		t.Errorf("OperationTimeout() = %v, %v; want 1m", d, ok)
+	}
+
+	ctx, cancel = operationContext(opCtx)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > time.Minute || time.Until(deadline) < 59*time.Second {
+		t.Errorf("deadline = %v, %v; want about 1m from now", deadline, ok)
+	}
+
+	// A nested operation keeps the outer deadline
+	nested, cancelNested := operationContext(ctx)
+	defer cancelNested()
+	if d, _ := nested.Deadline(); !d.Equal(deadline) {
+		t.Errorf("nested deadline = %v, want %v", d, deadline)
+	}
+	if _, ok := OperationTimeout(ctx); ok {
+		t.Error("operation timeout still set inside the operation")
+	}
+}
+
+func TestOperationContext_ParentDeadlineWins(t *testing.T) {
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Second)
+	defer cancelParent()
+
+	ctx, cancel := operationContext(WithOperationTimeout(parent, time.Hour))
+	defer cancel()
+	if d, _ := ctx.Deadline(); time.Until(d) > time.Second {
+		t.Errorf("deadline %v later than the parent's", d)
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// defaultPreflightThreshold is the upload size from which CopyFile and
+// CopyDirectory check the destination before sending data.
+const defaultPreflightThreshold = 64 * 1024 * 1024
+
+// Transfer preflight problems. They are wrapped in a *TransferPreflightError.
+var (
+	ErrRemoteDirMissing       = errors.New("client: remote directory does not exist")
+	ErrDestinationNotWritable = errors.New("client: remote destination is not writable")
+	ErrDestinationExists      = errors.New("client: remote file exists and NoOverwrite is set")
+	ErrInsufficientDiskSpace  = errors.New("client: insufficient remote disk space")
+)
+
+// TransferPreflightError is returned when the destination check before a
+// large upload finds that the transfer would fail. Use errors.Is with the
+// Err* preflight errors to find out why.
+type TransferPreflightError struct {
+	Destination string
+	Problems    []error
+}
+
+func (e *TransferPreflightError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return fmt.Sprintf("transfer preflight failed for %s: %s", e.Destination, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the problems, so errors.Is matches any of them.
+func (e *TransferPreflightError) Unwrap() []error {
+	return e.Problems
+}
+
+// WithPreflightThreshold sets the upload size from which the destination is
+// checked for free space and write permission before any data is sent.
+// 0 uses the 64MB default; a negative value disables the check.
+func WithPreflightThreshold(bytes int64) FileTransferOption {
+	return func(o *FileTransferOptions) { o.PreflightThreshold = bytes }
+}
+
+// shouldPreflight reports whether an upload of size bytes is checked first.
+func (o FileTransferOptions) shouldPreflight(size int64) bool {
+	switch {
+	case o.PreflightThreshold < 0:
+		return false
+	case o.PreflightThreshold == 0:
+		return size >= defaultPreflightThreshold
+	default:
+		return size >= o.PreflightThreshold
+	}
+}
+
+// checkDestination inspects remotePath on the server without modifying it.
+func (c *Client) checkDestination(ctx context.Context, remotePath string) (whatIfCheck, error) {
+	result, err := c.Execute(ctx, generateWhatIfScript(remotePath))
+	if err != nil {
+		return whatIfCheck{}, fmt.Errorf("check remote destination: %w", err)
+	}
+	if result == nil || len(result.Output) == 0 {
+		return whatIfCheck{}, errors.New("check remote destination: no output")
+	}
+	return parseWhatIfCheck(strings.TrimSpace(outputString(result.Output[0])))
+}
+
+// problems returns why an upload of size bytes to the checked destination
+// would fail. The space of an overwritten file is counted as free.
+func (chk whatIfCheck) problems(size int64, noOverwrite bool) []error {
+	var problems []error
+	switch {
+	case !chk.parentExists:
+		problems = append(problems, ErrRemoteDirMissing)
+	case !chk.writable:
+		problems = append(problems, ErrDestinationNotWritable)
+	}
+	if chk.exists && noOverwrite {
+		problems = append(problems, ErrDestinationExists)
+	}
+	if needed := size - chk.size; chk.free >= 0 && needed > chk.free {
+		problems = append(problems, fmt.Errorf("%w: need %d bytes, %d free", ErrInsufficientDiskSpace, needed, chk.free))
+	}
+	return problems
+}
+
+// preflightUpload checks the destination of a large upload and returns a
+// *TransferPreflightError if it would fail. If the check itself cannot run,
+// the upload goes ahead; the check is an early warning, not a gate.
+func (c *Client) preflightUpload(ctx context.Context, remotePath string, size int64, opt FileTransferOptions) error {
+	if !opt.shouldPreflight(size) {
+		return nil
+	}
+	chk, err := c.checkDestination(ctx, remotePath)
+	if err != nil {
+		c.logWarn("Transfer preflight for %s skipped: %v", remotePath, err)
+		return nil
+	}
+	if problems := chk.problems(size, opt.NoOverwrite); len(problems) > 0 {
+		c.logSecurityEvent("FILE_TRANSFER_FAILED", map[string]interface{}{
+			"operation":   "CopyFile",
+			"phase":       "preflight",
+			"destination": remotePath,
+			"error":       errors.Join(problems...).Error(),
+		})
+		return &TransferPreflightError{Destination: remotePath, Problems: problems}
+	}
+	return nil
+}
+
+// preflightDirectory checks free space for a directory upload of size bytes.
+// The directory is created by the upload, so only its volume is checked.
+func (c *Client) preflightDirectory(ctx context.Context, remoteDir string, size int64, opt FileTransferOptions) error {
+	if !opt.shouldPreflight(size) {
+		return nil
+	}
+	chk, err := c.checkDestination(ctx, remoteDir)
+	if err != nil {
+		c.logWarn("Transfer preflight for %s skipped: %v", remoteDir, err)
+		return nil
+	}
+	if chk.free >= 0 && size > chk.free {
+		return &TransferPreflightError{
+			Destination: remoteDir,
+			Problems:    []error{fmt.Errorf("%w: need %d bytes, %d free", ErrInsufficientDiskSpace, size, chk.free)},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFileTransferOptions_ShouldPreflight(t *testing.T) {
+	tests := []struct {
+		threshold int64
+		size      int64
+		want      bool
+	}{
+		{0, defaultPreflightThreshold - 1, false},
+		{0, defaultPreflightThreshold, true},
+		{1024, 1024, true},
+		{1024, 1023, false},
+		{-1, 1 << 40, false},
+	}
+	for _, tt := range tests {
+		opt := DefaultFileTransferOptions()
+		WithPreflightThreshold(tt.threshold)(&opt)
+		if got := opt.shouldPreflight(tt.size); got != tt.want {
+			t.Errorf("threshold %d: shouldPreflight(%d) = %v, want %v", tt.threshold, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestWhatIfCheck_Problems(t *testing.T) {
+	tests := []struct {
+		name        string
+		check       whatIfCheck
+		size        int64
+		noOverwrite bool
+		want        []error
+	}{
+		{"ok", whatIfCheck{parentExists: true, writable: true, free: 100}, 100, false, nil},
+		{"unknown free space", whatIfCheck{parentExists: true, writable: true, free: -1}, 1 << 40, false, nil},
+		{"missing dir", whatIfCheck{free: 100}, 10, false, []error{ErrRemoteDirMissing}},
+		{"read-only", whatIfCheck{parentExists: true, free: 100}, 10, false, []error{ErrDestinationNotWritable}},
+		{"no space", whatIfCheck{parentExists: true, writable: true, free: 10}, 11, false, []error{ErrInsufficientDiskSpace}},
+		{"overwrite frees space", whatIfCheck{exists: true, size: 5, parentExists: true, writable: true, free: 10}, 15, false, nil},
+		{"exists", whatIfCheck{exists: true, parentExists: true, writable: true, free: 10}, 1, true, []error{ErrDestinationExists}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.check.problems(tt.size, tt.noOverwrite)
+			if len(got) != len(tt.want) {
+				t.Fatalf("problems() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !errors.Is(got[i], tt.want[i]) {
+					t.Errorf("problems()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTransferPreflightError(t *testing.T) {
+	var err error = &TransferPreflightError{
+		Destination: `C:\data\big.iso`,
+		Problems:    []error{ErrDestinationNotWritable, ErrInsufficientDiskSpace},
+	}
+	if !errors.Is(err, ErrInsufficientDiskSpace) || !errors.Is(err, ErrDestinationNotWritable) {
+		t.Error("errors.Is does not match the wrapped problems")
+	}
+	if errors.Is(err, ErrRemoteDirMissing) {
+		t.Error("errors.Is matches a problem that is not present")
+	}
+	var pe *TransferPreflightError
+	if !errors.As(err, &pe) || pe.Destination != `C:\data\big.iso` {
+		t.Errorf("errors.As() = %v", pe)
+	}
+	if !strings.Contains(err.Error(), "not writable") || !strings.Contains(err.Error(), "disk space") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
@@ -0,0 +1,402 @@
+package client
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTransferCancelled is the error of a transfer cancelled with
+// Transfer.Cancel or TransferQueue.CancelAll.
+var ErrTransferCancelled = errors.New("client: transfer cancelled")
+
+// TransferQueueConfig configures a client's transfer queue.
+type TransferQueueConfig struct {
+	// MaxActive is the number of transfers that run at once. The rest wait
+	// in priority order. Default: 1 (transfers run one after another).
+	MaxActive int
+
+	// BandwidthLimit caps the combined throughput of all queued transfers in
+	// bytes per second. 0 means unlimited. See TransferQueue.SetBandwidthLimit.
+	BandwidthLimit int64
+}
+
+// TransferDirection is the direction of a queued transfer.
+type TransferDirection int
+
+const (
+	// TransferUpload copies a local file to the remote host (CopyFile).
+	TransferUpload TransferDirection = iota
+	// TransferDownload copies a remote file to the local host (FetchFile).
+	TransferDownload
+)
+
+// String returns the direction name.
+func (d TransferDirection) String() string {
+	if d == TransferDownload {
+		return "download"
+	}
+	return "upload"
+}
+
+// TransferState is the lifecycle state of a queued transfer.
+type TransferState int
+
+const (
+	// TransferQueued means the transfer waits for a free slot.
+	TransferQueued TransferState = iota
+	// TransferRunning means the transfer is in progress.
+	TransferRunning
+	// TransferCompleted means the transfer succeeded.
+	TransferCompleted
+	// TransferFailed means the transfer returned an error.
+	TransferFailed
+	// TransferCancelled means the transfer was cancelled.
+	TransferCancelled
+)
+
+// String returns the state name.
+func (s TransferState) String() string {
+	switch s {
+	case TransferQueued:
+		return "queued"
+	case TransferRunning:
+		return "running"
+	case TransferCompleted:
+		return "completed"
+	case TransferFailed:
+		return "failed"
+	case TransferCancelled:
+		return "cancelled"
+	default:
+		return fmt.Sprintf("TransferState(%d)", int(s))
+	}
+}
+
+// TransferStatus is a snapshot of a queued transfer.
+type TransferStatus struct {
+	ID          uint64
+	Direction   TransferDirection
+	Source      string
+	Destination string
+	Priority    int
+	State       TransferState
+
+	BytesTransferred int64
+	TotalBytes       int64
+
+	QueuedAt   time.Time
+	StartedAt  time.Time // zero while queued
+	FinishedAt time.Time // zero until done
+
+	// Err is the transfer error for TransferFailed and TransferCancelled.
+	Err error
+}
+
+// Transfer is a handle to a queued file transfer.
+type Transfer struct {
+	queue *TransferQueue
+	seq   uint64
+	opts  []FileTransferOption
+	done  chan struct{}
+
+	// Guarded by queue.mu
+	status TransferStatus
+	cancel context.CancelFunc
+	index  int // position in the pending heap, -1 once dequeued
+}
+
+// Status returns a snapshot of the transfer.
+func (t *Transfer) Status() TransferStatus {
+	t.queue.mu.Lock()
+	defer t.queue.mu.Unlock()
+	return t.status
+}
+
+// Done is closed when the transfer finishes, fails or is cancelled.
+func (t *Transfer) Done() <-chan struct{} {
+	return t.done
+}
+
+// Wait blocks until the transfer finishes and returns its error, or returns
+// ctx's error if ctx ends first (the transfer keeps going).
+func (t *Transfer) Wait(ctx context.Context) error {
+	select {
+	case <-t.done:
+		return t.Status().Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Cancel removes a queued transfer from the queue, or stops a running one.
+// It has no effect on a finished transfer.
+func (t *Transfer) Cancel() {
+	q := t.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cancelLocked(t)
+}
+
+// TransferQueue runs file transfers for a client, a few at a time in
+// priority order, within a shared bandwidth budget. Concurrent CopyFile and
+// FetchFile calls compete for the same connection; queueing them instead
+// keeps each transfer at full speed and makes their order predictable.
+type TransferQueue struct {
+	c   *Client
+	run func(ctx context.Context, t *Transfer) error // test hook
+
+	budget bandwidthBudget
+
+	mu        sync.Mutex
+	maxActive int
+	active    int
+	nextSeq   uint64
+	pending   transferHeap
+	transfers []*Transfer
+}
+
+// newTransferQueue creates a queue for c. A nil cfg uses the defaults.
+func newTransferQueue(c *Client, cfg *TransferQueueConfig) *TransferQueue {
+	q := &TransferQueue{c: c, maxActive: 1}
+	q.run = q.runTransfer
+	if cfg != nil {
+		if cfg.MaxActive > 0 {
+			q.maxActive = cfg.MaxActive
+		}
+		q.budget.setRate(cfg.BandwidthLimit)
+	}
+	return q
+}
+
+// Transfers returns the client's transfer queue, configured by
+// Config.TransferQueue.
+func (c *Client) Transfers() *TransferQueue {
+	c.transferQueueOnce.Do(func() {
+		c.transferQueue = newTransferQueue(c, c.config.TransferQueue)
+	})
+	return c.transferQueue
+}
+
+// Upload queues CopyFile(localPath, remotePath, opts...). Transfers with a
+// higher priority start first; equal priorities start in the order queued.
+func (q *TransferQueue) Upload(localPath, remotePath string, priority int, opts ...FileTransferOption) *Transfer {
+	return q.enqueue(TransferUpload, localPath, remotePath, priority, opts)
+}
+
+// Download queues FetchFile(remotePath, localPath, opts...). See Upload.
+func (q *TransferQueue) Download(remotePath, localPath string, priority int, opts ...FileTransferOption) *Transfer {
+	return q.enqueue(TransferDownload, remotePath, localPath, priority, opts)
+}
+
+// List returns the status of every transfer queued so far, in queue order.
+func (q *TransferQueue) List() []TransferStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	statuses := make([]TransferStatus, len(q.transfers))
+	for i, t := range q.transfers {
+		statuses[i] = t.status
+	}
+	return statuses
+}
+
+// SetBandwidthLimit changes the combined bandwidth budget in bytes per
+// second; 0 removes the limit. It applies to running transfers too.
+func (q *TransferQueue) SetBandwidthLimit(bytesPerSecond int64) {
+	q.budget.setRate(bytesPerSecond)
+}
+
+// CancelAll cancels every queued and running transfer.
+func (q *TransferQueue) CancelAll() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, t := range q.transfers {
+		q.cancelLocked(t)
+	}
+}
+
+func (q *TransferQueue) enqueue(dir TransferDirection, src, dst string, priority int, opts []FileTransferOption) *Transfer {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextSeq++
+	t := &Transfer{
+		queue: q,
+		seq:   q.nextSeq,
+		opts:  opts,
+		done:  make(chan struct{}),
+		status: TransferStatus{
+			ID:          q.nextSeq,
+			Direction:   dir,
+			Source:      src,
+			Destination: dst,
+			Priority:    priority,
+			State:       TransferQueued,
+			QueuedAt:    time.Now(),
+		},
+	}
+	q.transfers = append(q.transfers, t)
+	heap.Push(&q.pending, t)
+	q.dispatchLocked()
+	return t
+}
+
+// dispatchLocked starts pending transfers while slots are free.
+func (q *TransferQueue) dispatchLocked() {
+	for q.active < q.maxActive && q.pending.Len() > 0 {
+		t := heap.Pop(&q.pending).(*Transfer)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.cancel = cancel
+		t.status.State = TransferRunning
+		t.status.StartedAt = time.Now()
+		q.active++
+		go q.execute(ctx, t)
+	}
+}
+
+func (q *TransferQueue) execute(ctx context.Context, t *Transfer) {
+	err := q.run(ctx, t)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t.cancel()
+	q.active--
+	t.status.FinishedAt = time.Now()
+	switch {
+	case t.status.State == TransferCancelled:
+		// Cancel already recorded the outcome
+	case err != nil:
+		t.status.State = TransferFailed
+		t.status.Err = err
+	default:
+		t.status.State = TransferCompleted
+	}
+	close(t.done)
+	q.dispatchLocked()
+}
+
+// cancelLocked cancels t if it has not finished.
+func (q *TransferQueue) cancelLocked(t *Transfer) {
+	switch t.status.State {
+	case TransferQueued:
+		heap.Remove(&q.pending, t.index)
+		t.status.State = TransferCancelled
+		t.status.Err = ErrTransferCancelled
+		t.status.FinishedAt = time.Now()
+		close(t.done)
+	case TransferRunning:
+		// execute closes done once the transfer has stopped
+		t.status.State = TransferCancelled
+		t.status.Err = ErrTransferCancelled
+		t.cancel()
+	}
+}
+
+// runTransfer performs t with the client, tracking progress and charging
+// the bandwidth budget as chunks complete.
+func (q *TransferQueue) runTransfer(ctx context.Context, t *Transfer) error {
+	var user FileTransferOptions
+	for _, fn := range t.opts {
+		fn(&user)
+	}
+	var last int64
+	opts := append(append([]FileTransferOption(nil), t.opts...), WithProgressCallback(func(done, total int64) {
+		q.recordProgress(t, done, total)
+		if user.ProgressCallback != nil {
+			user.ProgressCallback(done, total)
+		}
+		// Progress is reported after each chunk; pausing here delays the
+		// next one. Callbacks of one transfer are serialized.
+		_ = q.budget.consume(ctx, done-last)
+		last = done
+	}))
+
+	if t.status.Direction == TransferDownload {
+		return q.c.FetchFile(ctx, t.status.Source, t.status.Destination, opts...)
+	}
+	return q.c.CopyFile(ctx, t.status.Source, t.status.Destination, opts...)
+}
+
+func (q *TransferQueue) recordProgress(t *Transfer, done, total int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t.status.BytesTransferred = done
+	t.status.TotalBytes = total
+}
+
+// transferHeap orders pending transfers by priority, then queue order.
+type transferHeap []*Transfer
+
+func (h transferHeap) Len() int { return len(h) }
+
+func (h transferHeap) Less(i, j int) bool {
+	if h[i].status.Priority != h[j].status.Priority {
+		return h[i].status.Priority > h[j].status.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h transferHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *transferHeap) Push(x any) {
+	t := x.(*Transfer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *transferHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
+
+// bandwidthBudget spreads a byte rate across concurrent consumers. Each
+// consume books its bytes on a shared timeline and waits until the booking
+// is due, so the combined rate stays at the limit however many transfers
+// run.
+type bandwidthBudget struct {
+	mu   sync.Mutex
+	rate float64   // bytes per second; 0 is unlimited
+	next time.Time // when the budget is free again
+}
+
+func (b *bandwidthBudget) setRate(bytesPerSecond int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = float64(max(bytesPerSecond, 0))
+}
+
+// consume books n bytes and waits until the budget allows them, or until
+// ctx ends.
+func (b *bandwidthBudget) consume(ctx context.Context, n int64) error {
+	b.mu.Lock()
+	if b.rate <= 0 || n <= 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	b.next = b.next.Add(time.Duration(float64(n) / b.rate * float64(time.Second)))
+	wait := b.next.Sub(now)
+	b.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedRunner replaces TransferQueue.run. Each transfer blocks until it is
+// released (or cancelled) and records the order transfers started in.
+type gatedRunner struct {
+	mu      sync.Mutex
+	started []string
+	running int
+	peak    int
+	release map[string]chan error
+	start   chan string
+}
+
+func newGatedRunner() *gatedRunner {
+	return &gatedRunner{release: make(map[string]chan error), start: make(chan string, 16)}
+}
+
+func (g *gatedRunner) gate(name string) chan error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ch, ok := g.release[name]
+	if !ok {
+		ch = make(chan error, 1)
+		g.release[name] = ch
+	}
+	return ch
+}
+
+func (g *gatedRunner) run(ctx context.Context, t *Transfer) error {
+	name := t.Status().Source
+	g.mu.Lock()
+	g.started = append(g.started, name)
+	g.running++
+	g.peak = max(g.peak, g.running)
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		g.running--
+		g.mu.Unlock()
+	}()
+	g.start <- name
+
+	select {
+	case err := <-g.gate(name):
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *gatedRunner) finish(name string, err error) {
+	g.gate(name) <- err
+}
+
+func (g *gatedRunner) waitStart(t *testing.T) string {
+	t.Helper()
+	select {
+	case name := <-g.start:
+		return name
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a transfer to start")
+		return ""
+	}
+}
+
+func newTestQueue(cfg *TransferQueueConfig) (*TransferQueue, *gatedRunner) {
+	g := newGatedRunner()
+	q := newTransferQueue(&Client{config: DefaultConfig()}, cfg)
+	q.run = g.run
+	return q, g
+}
+
+func waitTransfer(t *testing.T, tr *Transfer) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := tr.Wait(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("transfer %d did not finish", tr.Status().ID)
+	}
+	return err
+}
+
+func TestTransferQueue_PriorityOrder(t *testing.T) {
+	q, g := newTestQueue(nil)
+
+	first := q.Upload("first", `C:\first`, 0)
+	if got := g.waitStart(t); got != "first" {
+		t.Fatalf("started %q, want first", got)
+	}
+	// Queued behind "first": higher priority goes first, FIFO within a priority
+	low := q.Upload("low", `C:\low`, 1)
+	highA := q.Download(`C:\highA`, "highA", 5)
+	highB := q.Upload("highB", `C:\highB`, 5)
+	if s := low.Status(); s.State != TransferQueued {
+		t.Errorf("low state = %v, want queued", s.State)
+	}
+
+	for _, tr := range []*Transfer{first, highA, highB, low} {
+		g.finish(tr.Status().Source, nil)
+		if err := waitTransfer(t, tr); err != nil {
+			t.Fatalf("transfer %s error = %v", tr.Status().Source, err)
+		}
+		if tr != low {
+			g.waitStart(t)
+		}
+	}
+
+	want := []string{"first", `C:\highA`, "highB", "low"}
+	if !slices.Equal(g.started, want) {
+		t.Errorf("start order = %q, want %q", g.started, want)
+	}
+	for _, s := range q.List() {
+		if s.State != TransferCompleted || s.StartedAt.IsZero() || s.FinishedAt.IsZero() {
+			t.Errorf("transfer %d status = %+v", s.ID, s)
+		}
+	}
+}
+
+func TestTransferQueue_MaxActive(t *testing.T) {
+	q, g := newTestQueue(&TransferQueueConfig{MaxActive: 2})
+
+	var transfers []*Transfer
+	for _, name := range []string{"a", "b", "c", "d"} {
+		transfers = append(transfers, q.Upload(name, name, 0))
+	}
+	g.waitStart(t)
+	g.waitStart(t)
+	if s := transfers[2].Status(); s.State != TransferQueued {
+		t.Errorf("third transfer state = %v, want queued", s.State)
+	}
+
+	for i, tr := range transfers {
+		g.finish(tr.Status().Source, nil)
+		if err := waitTransfer(t, tr); err != nil {
+			t.Fatalf("transfer %d error = %v", i, err)
+		}
+		if i < 2 {
+			g.waitStart(t)
+		}
+	}
+	if g.peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", g.peak)
+	}
+}
+
+func TestTransferQueue_Cancel(t *testing.T) {
+	q, g := newTestQueue(nil)
+
+	running := q.Upload("running", "r", 0)
+	g.waitStart(t)
+	queued := q.Upload("queued", "q", 0)
+	next := q.Upload("next", "n", 0)
+
+	queued.Cancel()
+	if err := waitTransfer(t, queued); !errors.Is(err, ErrTransferCancelled) {
+		t.Errorf("queued Wait() = %v, want ErrTransferCancelled", err)
+	}
+
+	running.Cancel()
+	if err := waitTransfer(t, running); !errors.Is(err, ErrTransferCancelled) {
+		t.Errorf("running Wait() = %v, want ErrTransferCancelled", err)
+	}
+	if s := running.Status(); s.State != TransferCancelled {
+		t.Errorf("running state = %v, want cancelled", s.State)
+	}
+
+	// The cancelled queued transfer never starts; the next one does
+	if got := g.waitStart(t); got != "next" {
+		t.Errorf("started %q after cancel, want next", got)
+	}
+	g.finish("next", nil)
+	if err := waitTransfer(t, next); err != nil {
+		t.Errorf("next Wait() = %v", err)
+	}
+
+	// Cancelling a finished transfer changes nothing
+	next.Cancel()
+	if s := next.Status(); s.State != TransferCompleted || s.Err != nil {
+		t.Errorf("next status after Cancel = %v, %v", s.State, s.Err)
+	}
+}
+
+func TestTransferQueue_CancelAll(t *testing.T) {
+	q, g := newTestQueue(nil)
+	a := q.Upload("a", "a", 0)
+	g.waitStart(t)
+	b := q.Upload("b", "b", 0)
+
+	q.CancelAll()
+	for _, tr := range []*Transfer{a, b} {
+		if err := waitTransfer(t, tr); !errors.Is(err, ErrTransferCancelled) {
+			t.Errorf("transfer %s Wait() = %v, want ErrTransferCancelled", tr.Status().Source, err)
+		}
+	}
+}
+
+func TestTransferQueue_Failed(t *testing.T) {
+	q, g := newTestQueue(nil)
+	boom := errors.New("boom")
+	tr := q.Upload("a", "a", 0)
+	g.waitStart(t)
+	g.finish("a", boom)
+	if err := waitTransfer(t, tr); !errors.Is(err, boom) {
+		t.Errorf("Wait() = %v, want %v", err, boom)
+	}
+	if s := tr.Status(); s.State != TransferFailed {
+		t.Errorf("state = %v, want failed", s.State)
+	}
+}
+
+func TestBandwidthBudget(t *testing.T) {
+	var b bandwidthBudget
+	ctx := context.Background()
+
+	// Unlimited
+	start := time.Now()
+	if err := b.consume(ctx, 1<<30); err != nil || time.Since(start) > 100*time.Millisecond {
+		t.Fatalf("unlimited consume() = %v after %v", err, time.Since(start))
+	}
+
+	// Two consumers share 1000 B/s: 100 bytes each take 200ms in total
+	b.setRate(1000)
+	start = time.Now()
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = b.consume(ctx, 100)
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("shared budget took %v, want >= 200ms", elapsed)
+	}
+
+	// A cancelled context stops the wait
+	b.setRate(1)
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := b.consume(cctx, 1000); !errors.Is(err, context.Canceled) {
+		t.Errorf("consume() with cancelled ctx = %v", err)
+	}
+}
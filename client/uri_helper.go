@@ -4,6 +4,10 @@ import (
 	"strings"
 )
 
+// resourceURIBase is the prefix of the resource URIs of PowerShell
+// session configurations.
+const resourceURIBase = "http://schemas.microsoft.com/powershell/"
+
 // buildResourceURI constructs the WSMan ResourceURI from config.
 // It prioritizes explicit ResourceURI overrides, then constructs from ConfigurationName.
 func (c *Client) buildResourceURI() string {
@@ -12,16 +16,24 @@ func (c *Client) buildResourceURI() string {
 		return c.config.ResourceURI
 	}
 
-	const base = "http://schemas.microsoft.com/powershell/"
 	if c.config.ConfigurationName != "" {
 		// Validate ConfigurationName (no path separators/special chars logic if needed)
 		// For now, we trust it but ensure it's not trying to escape protocol scheme
 		if strings.Contains(c.config.ConfigurationName, "/") || strings.Contains(c.config.ConfigurationName, "\\") {
 			c.logWarn("ConfigurationName contains path separators, using default (name=%s)", c.config.ConfigurationName)
-			return base + "Microsoft.PowerShell"
+			return resourceURIBase + "Microsoft.PowerShell"
 		}
-		return base + c.config.ConfigurationName
+		return resourceURIBase + c.config.ConfigurationName
 	}
 
-	return base + "Microsoft.PowerShell"
+	return resourceURIBase + "Microsoft.PowerShell"
+}
+
+// configurationName returns the session configuration name of a
+// PowerShell resource URI, or "" for another resource URI.
+func configurationName(resourceURI string) string {
+	if len(resourceURI) <= len(resourceURIBase) || !strings.EqualFold(resourceURI[:len(resourceURIBase)], resourceURIBase) {
+		return ""
+	}
+	return resourceURI[len(resourceURIBase):]
 }
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// connectLazily connects on the first command if Config.LazyConnect is
+// set, and on the first command after Config.IdleClose closed the
+// session. A client that was connected before, e.g. one that is now
+// disconnected, is left alone: only Reconnect resumes its session. On a
+// closed client the connect fails, so the command does too.
+func (c *Client) connectLazily(ctx context.Context) error {
+	c.mu.Lock()
+	pending := (c.config.LazyConnect || c.session.idleClosed) && !c.connected && c.psrpPool == nil
+	c.mu.Unlock()
+	if !pending {
+		return nil
+	}
+	c.logInfo("Lazy connect on first command")
+	if err := c.Connect(ctx); err != nil {
+		return fmt.Errorf("lazy connect: %w", err)
+	}
+	return nil
+}
+
+// WarmUp connects if needed and then runs n trivial pipelines at once, so
+// the server opens n runspaces and, on WSMan, n authenticated HTTP
+// connections are ready for reuse. Later commands then start without the
+// cost of authentication or runspace creation. n is capped at
+// Config.MaxRunspaces, as lowered to the server's quota; n <= 0 only
+// connects.
+func (c *Client) WarmUp(ctx context.Context, n int) error {
+	if err := c.Connect(ctx); err != nil {
+		return fmt.Errorf("warm up: %w", err)
+	}
+
+	c.mu.Lock()
+	maxRunspaces := c.config.MaxRunspaces
+	if c.semaphore != nil {
+		_, _, maxRunspaces = c.semaphore.Stats()
+	}
+	c.mu.Unlock()
+	if maxRunspaces > 0 {
+		n = min(n, maxRunspaces)
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	for range n {
+		g.Go(func() error {
+			_, err := c.executeOnce(gctx, "$null", ExecOptions{})
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("warm up: %w", err)
+	}
+	c.logInfo("Warmed up %d runspaces", n)
+	return nil
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/runspace"
+)
+
+func TestConnectLazily(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *Client
+		wantErr bool
+	}{
+		{
+			name:   "disabled",
+			client: &Client{closed: true},
+		},
+		{
+			name:    "connects",
+			client:  &Client{config: Config{LazyConnect: true}, closed: true},
+			wantErr: true, // Connect fails on a closed client
+		},
+		{
+			name:   "already connected",
+			client: &Client{config: Config{LazyConnect: true}, connected: true},
+		},
+		{
+			name: "disconnected session",
+			client: &Client{
+				config:   Config{LazyConnect: true},
+				psrpPool: runspace.New(nil, uuid.New()),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.client.connectLazily(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("connectLazily() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "lazy connect") {
+				t.Errorf("connectLazily() error = %v, want it to mention the lazy connect", err)
+			}
+		})
+	}
+}
+
+func TestClient_ExecuteStream_LazyConnect(t *testing.T) {
+	c := &Client{config: Config{LazyConnect: true}, closed: true}
+
+	_, err := c.ExecuteStream(context.Background(), "Get-Date")
+	if err == nil || !strings.Contains(err.Error(), "lazy connect: client is closed") {
+		t.Errorf("ExecuteStream() error = %v, want the lazy connect failure", err)
+	}
+}
+
+func TestClient_WarmUp_ConnectFails(t *testing.T) {
+	c := &Client{closed: true}
+
+	if err := c.WarmUp(context.Background(), 2); err == nil || !strings.Contains(err.Error(), "warm up") {
+		t.Errorf("WarmUp() error = %v, want the connect failure", err)
+	}
+}
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrTransferBlocked is returned by CopyFile with WhatIf when the transfer
+// would fail. The message lists the problems.
+var ErrTransferBlocked = errors.New("client: transfer would fail")
+
+// WithWhatIf makes CopyFile check the transfer without writing anything:
+// it logs the plan from PlanCopyFile and returns ErrTransferBlocked if the
+// plan has problems.
+func WithWhatIf(enabled bool) FileTransferOption {
+	return func(o *FileTransferOptions) { o.WhatIf = enabled }
+}
+
+// TransferPlan describes what CopyFile would do. Problems lists the reasons
+// it would fail; an empty list means the transfer is expected to succeed.
+type TransferPlan struct {
+	Source      string
+	Destination string
+	Size        int64
+
+	// Strategy is the upload method: "parallel", "streaming" or
+	// "hvsocket-parallel".
+	Strategy    string
+	ChunkSize   int
+	Chunks      int64
+	Concurrency int
+
+	// DestinationExists reports whether the remote file already exists, and
+	// ExistingSize its size. An existing file is overwritten unless
+	// NoOverwrite is set.
+	DestinationExists bool
+	ExistingSize      int64
+
+	// FreeSpace is the free space on the remote volume in bytes, or -1 if
+	// it could not be determined (e.g. UNC paths).
+	FreeSpace int64
+
+	Problems []string
+}
+
+// OK reports whether the plan has no problems.
+func (p *TransferPlan) OK() bool {
+	return len(p.Problems) == 0
+}
+
+// String returns a one-line summary followed by any problems.
+func (p *TransferPlan) String() string {
+	var b strings.Builder
+	action := "create"
+	if p.DestinationExists {
+		action = "overwrite"
+	}
+	fmt.Fprintf(&b, "%s %s -> %s (%d bytes, %s, %d chunks of %d, concurrency %d)",
+		action, p.Source, p.Destination, p.Size, p.Strategy, p.Chunks, p.ChunkSize, p.Concurrency)
+	for _, problem := range p.Problems {
+		fmt.Fprintf(&b, "\n  problem: %s", problem)
+	}
+	return b.String()
+}
+
+// PlanCopyFile checks an upload without writing anything, locally or
+// remotely. It validates the paths and local file, and checks on the server
+// that the destination directory exists and is writable, whether the file
+// exists (a problem with NoOverwrite), and that the volume has enough free
+// space. An error is returned only if the checks themselves fail.
+func (c *Client) PlanCopyFile(ctx context.Context, localPath, remotePath string, opts ...FileTransferOption) (*TransferPlan, error) {
+	opt := DefaultFileTransferOptionsForTransport(c.config.Transport)
+	for _, fn := range opts {
+		fn(&opt)
+	}
+
+	plan := &TransferPlan{Source: localPath, Destination: remotePath, FreeSpace: -1}
+	if err := validatePaths(localPath, remotePath); err != nil {
+		plan.Problems = append(plan.Problems, err.Error())
+		return plan, nil
+	}
+
+	stat, err := os.Stat(localPath)
+	switch {
+	case err != nil:
+		plan.Problems = append(plan.Problems, fmt.Sprintf("cannot read local file: %v", err))
+	case !stat.Mode().IsRegular():
+		plan.Problems = append(plan.Problems, fmt.Sprintf("source is not a regular file (mode: %s)", stat.Mode()))
+	default:
+		plan.Size = stat.Size()
+		if maxSize := opt.maxFileSize(); maxSize > 0 && plan.Size > maxSize {
+			plan.Problems = append(plan.Problems, fmt.Sprintf("file too large: %d bytes (max allowed: %d)", plan.Size, maxSize))
+		}
+	}
+	c.planStrategy(plan, opt)
+
+	check, err := c.checkDestination(ctx, remotePath)
+	if err != nil {
+		return nil, err
+	}
+	plan.DestinationExists = check.exists
+	plan.ExistingSize = check.size
+	plan.FreeSpace = check.free
+	for _, problem := range check.problems(plan.Size, opt.NoOverwrite) {
+		plan.Problems = append(plan.Problems, problem.Error())
+	}
+	return plan, nil
+}
+
+// planStrategy fills in the upload strategy CopyFile would choose.
+func (c *Client) planStrategy(plan *TransferPlan, opt FileTransferOptions) {
+	plan.ChunkSize = opt.ChunkSize
+	plan.Concurrency = opt.MaxConcurrency
+	switch {
+	case !c.capabilities().ParallelPipelines:
+		// CopyFile always uses two paced parallel streams on HvSocket.
+		plan.Strategy, plan.Concurrency = "hvsocket-parallel", 2
+	case (opt.MaxConcurrency > 1 || opt.shouldAutoTune(plan.Size)) && plan.Size > int64(opt.ChunkSize):
+		plan.Strategy = "parallel"
+	default:
+		plan.Strategy, plan.Concurrency = "streaming", 1
+	}
+	if opt.ChunkSize > 0 {
+		plan.Chunks = (plan.Size + int64(opt.ChunkSize) - 1) / int64(opt.ChunkSize)
+	}
+}
+
+// whatIfCheck is the remote state reported by the what-if script.
+type whatIfCheck struct {
+	exists       bool
+	size         int64
+	parentExists bool
+	writable     bool
+	free         int64
+}
+
+// parseWhatIfCheck parses the "exists|size|parentExists|writable|free" line
+// of the what-if script.
+func parseWhatIfCheck(line string) (whatIfCheck, error) {
+	fields := strings.Split(line, "|")
+	if len(fields) != 5 {
+		return whatIfCheck{}, fmt.Errorf("unexpected remote check output: %q", line)
+	}
+	size, err1 := strconv.ParseInt(fields[1], 10, 64)
+	free, err2 := strconv.ParseInt(fields[4], 10, 64)
+	if err := errors.Join(err1, err2); err != nil {
+		return whatIfCheck{}, fmt.Errorf("unexpected remote check output %q: %w", line, err)
+	}
+	return whatIfCheck{
+		exists:       fields[0] == "1",
+		size:         size,
+		parentExists: fields[2] == "1",
+		writable:     fields[3] == "1",
+		free:         free,
+	}, nil
+}
+
+// generateWhatIfScript creates a PowerShell script that inspects the upload
+// destination without modifying it. An existing file is opened for writing
+// and closed untouched; for a new file, the directory ACL is checked for
+// CreateFiles access by the current identity.
+func generateWhatIfScript(remotePath string) string {
+	remotePathB64 := base64.StdEncoding.EncodeToString([]byte(remotePath))
+	return fmt.Sprintf(`
+		$ErrorActionPreference = 'Stop'
+		$pathBytes = [System.Convert]::FromBase64String('%s')
+		$path = [System.Text.Encoding]::UTF8.GetString($pathBytes)
+		$parent = Split-Path -Parent $path
+		$exists = Test-Path -LiteralPath $path -PathType Leaf
+		$parentExists = $parent -and (Test-Path -LiteralPath $parent -PathType Container)
+		$size = 0
+		$writable = $false
+		if ($exists) {
+			$size = (Get-Item -LiteralPath $path -Force).Length
+			try {
+				$stream = [IO.File]::Open($path, [IO.FileMode]::Open, [IO.FileAccess]::Write, [IO.FileShare]::ReadWrite)
+				$stream.Close()
+				$writable = $true
+			} catch {}
+		} elseif ($parentExists) {
+			try {
+				$id = [Security.Principal.WindowsIdentity]::GetCurrent()
+				$sids = @($id.User) + @($id.Groups)
+				$allow = $false
+				$deny = $false
+				$rules = (Get-Acl -LiteralPath $parent).GetAccessRules($true, $true, [Security.Principal.SecurityIdentifier])
+				foreach ($rule in $rules) {
+					if (($sids -contains $rule.IdentityReference) -and ($rule.FileSystemRights -band [Security.AccessControl.FileSystemRights]::CreateFiles)) {
+						if ($rule.AccessControlType -eq 'Deny') { $deny = $true } else { $allow = $true }
+					}
+				}
+				$writable = $allow -and -not $deny
+			} catch {}
+		}
+		$free = -1
+		try {
+			$root = [IO.Path]::GetPathRoot($path)
+			if ($root -notlike '\\*') { $free = ([IO.DriveInfo]::new($root)).AvailableFreeSpace }
+		} catch {}
+		'{0}|{1}|{2}|{3}|{4}' -f [int]$exists, $size, [int][bool]$parentExists, [int]$writable, $free
+	`, remotePathB64)
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWhatIfCheck(t *testing.T) {
+	got, err := parseWhatIfCheck("1|2048|1|0|1073741824")
+	if err != nil {
+		t.Fatalf("parseWhatIfCheck() error = %v", err)
+	}
+	want := whatIfCheck{exists: true, size: 2048, parentExists: true, writable: false, free: 1 << 30}
+	if got != want {
+		t.Errorf("parseWhatIfCheck() = %+v, want %+v", got, want)
+	}
+
+	for _, line := range []string{"", "1|2|1|1", "1|x|1|1|-1", "1|2|1|1|y"} {
+		if _, err := parseWhatIfCheck(line); err == nil {
+			t.Errorf("parseWhatIfCheck(%q) error = nil", line)
+		}
+	}
+}
+
+func TestPlanStrategy(t *testing.T) {
+	tests := []struct {
+		name        string
+		transport   TransportType
+		opts        []FileTransferOption
+		size        int64
+		strategy    string
+		concurrency int
+		chunks      int64
+	}{
+		{"small file streams", TransportWSMan, nil, 1024, "streaming", 1, 1},
+		{"large file parallel", TransportWSMan, nil, 1 << 20, "parallel", 4, 4},
+		{"serial", TransportWSMan, []FileTransferOption{WithMaxConcurrency(1)}, 1 << 20, "streaming", 1, 4},
+		{"hvsocket", TransportHvSocket, nil, 4 << 20, "hvsocket-parallel", 2, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Transport = tt.transport
+			c := &Client{config: cfg}
+
+			opt := DefaultFileTransferOptionsForTransport(tt.transport)
+			for _, fn := range tt.opts {
+				fn(&opt)
+			}
+			plan := &TransferPlan{Size: tt.size}
+			c.planStrategy(plan, opt)
+			if plan.Strategy != tt.strategy || plan.Concurrency != tt.concurrency || plan.Chunks != tt.chunks {
+				t.Errorf("plan = %s/%d/%d chunks, want %s/%d/%d chunks",
+					plan.Strategy, plan.Concurrency, plan.Chunks, tt.strategy, tt.concurrency, tt.chunks)
+			}
+		})
+	}
+}
+
+func TestTransferPlan_String(t *testing.T) {
+	plan := &TransferPlan{
+		Source:            "a.bin",
+		Destination:       `C:\a.bin`,
+		Size:              10,
+		Strategy:          "streaming",
+		DestinationExists: true,
+		Problems:          []string{"remote file exists and NoOverwrite is set"},
+	}
+	if plan.OK() {
+		t.Error("OK() = true with problems")
+	}
+	s := plan.String()
+	if !strings.HasPrefix(s, `overwrite a.bin -> C:\a.bin`) || !strings.Contains(s, "problem: remote file exists") {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestGenerateWhatIfScript_EncodesPath(t *testing.T) {
+	script := generateWhatIfScript(`C:\x'; Remove-Item C:\ -Recurse; '`)
+	if strings.Contains(script, "Remove-Item") {
+		t.Error("remote path is not encoded in the what-if script")
+	}
+}
@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrWorkersClosed is returned when using Workers after Close.
+var ErrWorkersClosed = errors.New("client: workers are closed")
+
+// workerRetryDelay is the delay before the second connect attempt of a
+// worker; each further attempt waits one more delay.
+const workerRetryDelay = time.Second
+
+// WorkersConfig configures Workers.
+type WorkersConfig struct {
+	// ConnectStagger delays the connect of each worker by this much more
+	// than the previous one, so that servers and KDCs are not hit by n
+	// authentications at once. Default: 0 (all workers connect at once).
+	ConnectStagger time.Duration
+
+	// ConnectAttempts is how often a worker tries to connect before
+	// Workers fails. Default: 1.
+	ConnectAttempts int
+
+	// BandwidthLimit caps the combined throughput of the workers in bytes
+	// per second, as booked with Worker.Throttle. 0 means unlimited.
+	BandwidthLimit int64
+
+	// OpsLimit caps the combined rate of Worker.Execute calls per second.
+	// 0 means unlimited.
+	OpsLimit float64
+}
+
+// Workers is a set of independent clients for one host, each with its own
+// connection, authentication context and RunspacePool, for work that
+// needs more throughput than one session gives:
+//
+//	workers, err := c.Workers(ctx, 4, &client.WorkersConfig{ConnectStagger: 250 * time.Millisecond})
+//	if err != nil {
+//		return err
+//	}
+//	defer workers.Close(ctx)
+//	err = workers.Run(ctx, func(ctx context.Context, w *client.Worker) error {
+//		_, err := w.Execute(ctx, fmt.Sprintf("Invoke-Shard -Index %d", w.ID))
+//		return err
+//	})
+//
+// The workers share the bandwidth and operations budgets of the set.
+// Workers is safe for concurrent use.
+type Workers struct {
+	cfg     WorkersConfig
+	workers []*Worker
+
+	bandwidth bandwidthBudget
+	ops       bandwidthBudget
+
+	// exec runs a script on a client. Overridable for tests.
+	exec func(ctx context.Context, c *Client, script string) (*Result, error)
+	// release closes a client. Overridable for tests.
+	release func(ctx context.Context, c *Client) error
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// Worker is one client of a Workers set.
+type Worker struct {
+	// ID is the index of the worker in the set, from 0.
+	ID int
+
+	// Client is the worker's own client. Commands run on it directly do
+	// not count against the budgets of the set.
+	Client *Client
+
+	set *Workers
+}
+
+// Workers creates n clients with c's configuration and connects them. If
+// any of them fails to connect, the others are closed and the error is
+// returned. cfg may be nil.
+func (c *Client) Workers(ctx context.Context, n int, cfg *WorkersConfig) (*Workers, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("client: workers: n must be positive, got %d", n)
+	}
+	s := newWorkers(n, cfg)
+	err := s.start(ctx, func(ctx context.Context) (*Client, error) {
+		w, err := c.CreateWorker()
+		if err != nil {
+			return nil, err
+		}
+		if err := w.Connect(ctx); err != nil {
+			_ = w.Close(context.Background())
+			return nil, err
+		}
+		return w, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newWorkers returns a set of n workers that are not connected yet.
+func newWorkers(n int, cfg *WorkersConfig) *Workers {
+	s := &Workers{
+		workers: make([]*Worker, n),
+		exec: func(ctx context.Context, c *Client, script string) (*Result, error) {
+			return c.Execute(ctx, script)
+		},
+		release: func(ctx context.Context, c *Client) error {
+			return c.Close(ctx)
+		},
+	}
+	if cfg != nil {
+		s.cfg = *cfg
+	}
+	if s.cfg.ConnectAttempts <= 0 {
+		s.cfg.ConnectAttempts = 1
+	}
+	s.bandwidth.setRate(s.cfg.BandwidthLimit)
+	s.ops.rate = max(s.cfg.OpsLimit, 0)
+	return s
+}
+
+// start connects the workers with dial. If one fails, the workers that
+// have not dialed yet give up and those that connected are closed.
+func (s *Workers) start(ctx context.Context, dial func(context.Context) (*Client, error)) error {
+	g, gctx := errgroup.WithContext(ctx)
+	for i := range s.workers {
+		g.Go(func() error {
+			if err := sleepContext(gctx, time.Duration(i)*s.cfg.ConnectStagger); err != nil {
+				return err
+			}
+			c, err := s.connect(gctx, dial)
+			if err != nil {
+				return fmt.Errorf("connect worker %d: %w", i, err)
+			}
+			s.workers[i] = &Worker{ID: i, Client: c, set: s}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		_ = s.Close(context.Background())
+		return err
+	}
+	return nil
+}
+
+// connect dials a worker, retrying up to ConnectAttempts times.
+func (s *Workers) connect(ctx context.Context, dial func(context.Context) (*Client, error)) (*Client, error) {
+	var err error
+	for attempt := 1; attempt <= s.cfg.ConnectAttempts; attempt++ {
+		if attempt > 1 {
+			if err := sleepContext(ctx, time.Duration(attempt-1)*workerRetryDelay); err != nil {
+				return nil, err
+			}
+		}
+		var c *Client
+		if c, err = dial(ctx); err == nil {
+			return c, nil
+		}
+	}
+	return nil, err
+}
+
+// sleepContext waits for d or until ctx ends.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Len returns the number of workers.
+func (s *Workers) Len() int {
+	return len(s.workers)
+}
+
+// Worker returns the worker with the given ID.
+func (s *Workers) Worker(id int) *Worker {
+	return s.workers[id]
+}
+
+// Run calls fn once for each worker, all at once, and waits for them. The
+// context passed to fn is cancelled when one of them fails; Run returns
+// the first error.
+func (s *Workers) Run(ctx context.Context, fn func(ctx context.Context, w *Worker) error) error {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return ErrWorkersClosed
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	for _, w := range s.workers {
+		g.Go(func() error {
+			return fn(ctx, w)
+		})
+	}
+	return g.Wait()
+}
+
+// SetBandwidthLimit changes the combined bandwidth budget in bytes per
+// second; 0 removes the limit.
+func (s *Workers) SetBandwidthLimit(bytesPerSecond int64) {
+	s.bandwidth.setRate(bytesPerSecond)
+}
+
+// Close closes all workers. It is safe to call more than once.
+func (s *Workers) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	var errs []error
+	for _, w := range s.workers {
+		if w == nil {
+			continue
+		}
+		if err := s.release(ctx, w.Client); err != nil {
+			errs = append(errs, fmt.Errorf("close worker %d: %w", w.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Execute runs script on the worker once the operations budget of the set
+// allows it.
+func (w *Worker) Execute(ctx context.Context, script string) (*Result, error) {
+	if err := w.set.ops.consume(ctx, 1); err != nil {
+		return nil, err
+	}
+	return w.set.exec(ctx, w.Client, script)
+}
+
+// Throttle books n bytes on the bandwidth budget of the set and waits
+// until they are due, or until ctx ends.
+func (w *Worker) Throttle(ctx context.Context, n int64) error {
+	return w.set.bandwidth.consume(ctx, n)
+}
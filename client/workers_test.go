@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeWorkers returns a set whose clients are placeholders, recording the
+// clients it closes.
+func fakeWorkers(n int, cfg *WorkersConfig, closed *atomic.Int32) *Workers {
+	s := newWorkers(n, cfg)
+	s.release = func(context.Context, *Client) error {
+		closed.Add(1)
+		return nil
+	}
+	s.exec = func(context.Context, *Client, string) (*Result, error) {
+		return &Result{}, nil
+	}
+	return s
+}
+
+func TestWorkers_StaggeredConnect(t *testing.T) {
+	var closed atomic.Int32
+	s := fakeWorkers(3, &WorkersConfig{ConnectStagger: 30 * time.Millisecond}, &closed)
+
+	var mu sync.Mutex
+	var dialed []time.Time
+	start := time.Now()
+	err := s.start(context.Background(), func(context.Context) (*Client, error) {
+		mu.Lock()
+		dialed = append(dialed, time.Now())
+		mu.Unlock()
+		return &Client{}, nil
+	})
+	if err != nil {
+		t.Fatalf("start() error = %v", err)
+	}
+	if s.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", s.Len())
+	}
+	for i := 0; i < s.Len(); i++ {
+		if w := s.Worker(i); w == nil || w.ID != i || w.Client == nil {
+			t.Errorf("Worker(%d) = %+v", i, w)
+		}
+	}
+	if last := dialed[len(dialed)-1].Sub(start); last < 60*time.Millisecond {
+		t.Errorf("last worker connected after %v, want the connects staggered", last)
+	}
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+	if closed.Load() != 3 {
+		t.Errorf("closed %d clients, want 3", closed.Load())
+	}
+	if err := s.Run(context.Background(), func(context.Context, *Worker) error { return nil }); !errors.Is(err, ErrWorkersClosed) {
+		t.Errorf("Run() after Close error = %v, want ErrWorkersClosed", err)
+	}
+}
+
+func TestWorkers_ConnectFailureClosesOthers(t *testing.T) {
+	var closed atomic.Int32
+	s := fakeWorkers(3, nil, &closed)
+
+	// The failure cancels the dials that have not started, so how many
+	// connect depends on scheduling; all that did must be closed
+	var calls, connected atomic.Int32
+	wantErr := errors.New("auth storm")
+	err := s.start(context.Background(), func(context.Context) (*Client, error) {
+		if calls.Add(1) == 2 {
+			return nil, wantErr
+		}
+		connected.Add(1)
+		return &Client{}, nil
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("start() error = %v, want %v", err, wantErr)
+	}
+	if connected.Load() == 0 {
+		t.Fatal("no worker connected")
+	}
+	if closed.Load() != connected.Load() {
+		t.Errorf("closed %d clients, want the %d that connected", closed.Load(), connected.Load())
+	}
+}
+
+func TestWorkers_ConnectRetries(t *testing.T) {
+	var closed atomic.Int32
+	s := fakeWorkers(1, &WorkersConfig{ConnectAttempts: 2}, &closed)
+
+	var calls atomic.Int32
+	err := s.start(context.Background(), func(context.Context) (*Client, error) {
+		if calls.Add(1) == 1 {
+			return nil, errors.New("transient")
+		}
+		return &Client{}, nil
+	})
+	if err != nil {
+		t.Fatalf("start() error = %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("dialed %d times, want 2", calls.Load())
+	}
+}
+
+func TestWorkers_RunAndBudgets(t *testing.T) {
+	var closed atomic.Int32
+	s := fakeWorkers(2, &WorkersConfig{OpsLimit: 100, BandwidthLimit: 1000}, &closed)
+	if err := s.start(context.Background(), func(context.Context) (*Client, error) {
+		return &Client{}, nil
+	}); err != nil {
+		t.Fatalf("start() error = %v", err)
+	}
+	defer s.Close(context.Background())
+
+	// 2 workers x 5 ops at 100/s and 2 x 50 bytes at 1000 B/s both take
+	// about 100ms combined.
+	start := time.Now()
+	var ran atomic.Int32
+	err := s.Run(context.Background(), func(ctx context.Context, w *Worker) error {
+		for i := 0; i < 5; i++ {
+			if _, err := w.Execute(ctx, "1"); err != nil {
+				return err
+			}
+		}
+		ran.Add(1)
+		return w.Throttle(ctx, 50)
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if ran.Load() != 2 {
+		t.Errorf("fn ran for %d workers, want 2", ran.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Run() took %v, want the shared budgets to pace it", elapsed)
+	}
+
+	wantErr := errors.New("boom")
+	err = s.Run(context.Background(), func(ctx context.Context, w *Worker) error {
+		if w.ID == 0 {
+			return wantErr
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("Run() error = %v, want %v", err, wantErr)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/smnsjas/go-psrp/client"
+)
+
+// command is a subcommand of psrp-client. All subcommands take the same
+// connection flags; their positional arguments stand for the flags that
+// select what to do, e.g. "exec <script>" for -script.
+type command struct {
+	name    string
+	args    string
+	summary string
+
+	// define adds the flags of this command only. It may be nil.
+	define func(fs *flag.FlagSet)
+
+	// preset returns the flag values that the positional arguments stand
+	// for.
+	preset func(fs *flag.FlagSet, args []string) (map[string]string, error)
+
+	// action runs a command that does not connect, with its own flags,
+	// instead of the connection flags and preset. It may be nil.
+	action func(cmd *command, args []string)
+}
+
+var commands = []*command{
+	{
+		name:    "exec",
+		args:    "<script>",
+		summary: "Run a PowerShell script and print its result",
+		preset: func(_ *flag.FlagSet, args []string) (map[string]string, error) {
+			script := strings.Join(args, " ")
+			if script == "" {
+				return nil, errors.New("missing script")
+			}
+			return map[string]string{"script": script}, nil
+		},
+	},
+	{
+		name:    "copy",
+		args:    "<local> <remote>",
+		summary: "Upload a file, or a directory recursively",
+		preset: func(_ *flag.FlagSet, args []string) (map[string]string, error) {
+			if len(args) != 2 {
+				return nil, errors.New("want a local and a remote path")
+			}
+			info, err := os.Stat(args[0])
+			if err != nil {
+				return nil, err
+			}
+			if info.IsDir() {
+				return map[string]string{"copy-dir": args[0] + "=>" + args[1]}, nil
+			}
+			return map[string]string{"copy": args[0] + "=>" + args[1]}, nil
+		},
+	},
+	{
+		name:    "fetch",
+		args:    "[-r] <remote> <local>",
+		summary: "Download a file, or a directory with -r",
+		define: func(fs *flag.FlagSet) {
+			fs.Bool("r", false, "Fetch a directory recursively")
+		},
+		preset: func(fs *flag.FlagSet, args []string) (map[string]string, error) {
+			if len(args) != 2 {
+				return nil, errors.New("want a remote and a local path")
+			}
+			if fs.Lookup("r").Value.String() == "true" {
+				return map[string]string{"fetch-dir": args[0] + "=>" + args[1]}, nil
+			}
+			return map[string]string{"fetch": args[0] + "=>" + args[1]}, nil
+		},
+	},
+	{
+		name:    "shell",
+		summary: "Open an interactive session, like Enter-PSSession",
+		preset: func(_ *flag.FlagSet, args []string) (map[string]string, error) {
+			if len(args) > 0 {
+				return nil, fmt.Errorf("unexpected arguments %q", args)
+			}
+			return map[string]string{"interactive": "true"}, nil
+		},
+	},
+	{
+		name:    "winrs",
+		args:    "<command>",
+		summary: "Run a cmd.exe command through WinRS",
+		preset: func(_ *flag.FlagSet, args []string) (map[string]string, error) {
+			command := strings.Join(args, " ")
+			if command == "" {
+				return nil, errors.New("missing command")
+			}
+			return map[string]string{"cmd": "true", "script": command}, nil
+		},
+	},
+	{
+		name:    "identify",
+		summary: "Show the protocol and product version of the WinRM service",
+		preset: func(_ *flag.FlagSet, args []string) (map[string]string, error) {
+			if len(args) > 0 {
+				return nil, fmt.Errorf("unexpected arguments %q", args)
+			}
+			return map[string]string{"identify": "true"}, nil
+		},
+	},
+	{
+		name:    "login",
+		args:    "<host>",
+		summary: "Save the credentials of a host in the system credential store",
+		action:  login,
+	},
+	{
+		name:    "logout",
+		args:    "<host>",
+		summary: "Remove the saved credentials of a host",
+		action:  logout,
+	},
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			if cmd := findCommand(args[1]); cmd != nil {
+				runCommand(cmd, []string{"-h"})
+				return
+			}
+		}
+		usage(os.Stdout)
+		return
+	}
+	if cmd := findCommand(args[0]); cmd != nil {
+		runCommand(cmd, args[1:])
+		return
+	}
+	if !strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	// Flags only, as before subcommands: -script, -copy, -interactive, ...
+	run(nil, args)
+}
+
+// runCommand runs cmd with the arguments after its name.
+func runCommand(cmd *command, args []string) {
+	if cmd.action != nil {
+		cmd.action(cmd, args)
+		return
+	}
+	run(cmd, args)
+}
+
+// usage prints the commands.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: psrp-client <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	_ = tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The commands that connect take the connection flags (-server, -user, -tls,")
+	fmt.Fprintln(w, "...), which can also come from a profile: -config psrp.yaml [-profile name].")
+	fmt.Fprintln(w, "Credentials saved with login are used when no password is given. Flags go")
+	fmt.Fprintln(w, "before the arguments. Run 'psrp-client help <command>' for the flags.")
+}
+
+// newFlagSet returns the flag set of cmd, or of the flags-only invocation
+// if cmd is nil, with the flags of the command added.
+func newFlagSet(cmd *command) *flag.FlagSet {
+	name := "psrp-client"
+	if cmd != nil {
+		name += " " + cmd.name
+	}
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		if cmd != nil {
+			fmt.Fprintf(out, "Usage: %s [flags] %s\n\n%s.\n\nFlags:\n", name, cmd.args, cmd.summary)
+		} else {
+			fmt.Fprintf(out, "Usage: %s [flags]\n\nFlags:\n", name)
+		}
+		fs.PrintDefaults()
+	}
+	if cmd != nil && cmd.define != nil {
+		cmd.define(fs)
+	}
+	return fs
+}
+
+// runIdentify prints the WS-Management Identify response of the server.
+func runIdentify(ctx context.Context, psrp *client.Client, format string) {
+	w := psrp.WSMan()
+	if w == nil {
+		fmt.Fprintln(os.Stderr, "Error: identify needs the WSMan transport")
+		os.Exit(1)
+	}
+	id, err := w.Identify(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error identifying server: %v\n", err)
+		os.Exit(1)
+	}
+
+	if format == outputJSON || format == outputYAML {
+		if err := writeDocument(os.Stdout, id, format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing result: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "ProtocolVersion\t: %s\n", id.ProtocolVersion)
+	fmt.Fprintf(tw, "ProductVendor\t: %s\n", id.ProductVendor)
+	fmt.Fprintf(tw, "ProductVersion\t: %s\n", id.ProductVersion)
+	if len(id.SecurityProfiles) > 0 {
+		fmt.Fprintf(tw, "SecurityProfiles\t: %s\n", strings.Join(id.SecurityProfiles, ", "))
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/smnsjas/go-psrp/powershell"
+)
+
+// terminalHost answers remote host calls from the terminal.
+type terminalHost struct {
+	mu     sync.Mutex
+	in     *bufio.Reader
+	out    io.Writer
+	errOut io.Writer
+}
+
+func newTerminalHost(in io.Reader, out, errOut io.Writer) *terminalHost {
+	return &terminalHost{in: bufio.NewReader(in), out: out, errOut: errOut}
+}
+
+// ReadLine reads a line from the terminal (Read-Host).
+func (h *terminalHost) ReadLine(_ context.Context) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.readLine()
+}
+
+func (h *terminalHost) readLine() (string, error) {
+	line, err := h.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Write prints host output; errors and warnings go to stderr.
+func (h *terminalHost) Write(_ context.Context, kind powershell.HostOutput, text string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch kind {
+	case powershell.HostOutputError:
+		_, _ = fmt.Fprint(h.errOut, text)
+	case powershell.HostOutputWarning:
+		_, _ = fmt.Fprint(h.errOut, "WARNING: "+text)
+	case powershell.HostOutputVerbose:
+		_, _ = fmt.Fprint(h.errOut, "VERBOSE: "+text)
+	case powershell.HostOutputDebug:
+		_, _ = fmt.Fprint(h.errOut, "DEBUG: "+text)
+	default:
+		_, _ = fmt.Fprint(h.out, text)
+	}
+}
+
+// Prompt asks for each field in turn, like the PowerShell console host.
+func (h *terminalHost) Prompt(_ context.Context, caption, message string, fields []powershell.FieldDescription) (map[string]interface{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.printHeader(caption, message)
+	values := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		label := f.Label
+		if label == "" {
+			label = f.Name
+		}
+		_, _ = fmt.Fprintf(h.out, "%s: ", label)
+		line, err := h.readLine()
+		if err != nil {
+			return nil, err
+		}
+		values[f.Name] = line
+	}
+	return values, nil
+}
+
+// PromptForChoice lists the choices with their hotkeys and reads a selection.
+// An empty answer selects the default.
+func (h *terminalHost) PromptForChoice(_ context.Context, caption, message string, choices []powershell.ChoiceDescription, defaultChoice int) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.printHeader(caption, message)
+	hotkeys := make([]string, len(choices))
+	labels := make([]string, len(choices))
+	for i, c := range choices {
+		hotkeys[i], labels[i] = choiceHotkey(c.Label, i)
+	}
+
+	for {
+		for i := range choices {
+			_, _ = fmt.Fprintf(h.out, "[%s] %s  ", hotkeys[i], labels[i])
+		}
+		if defaultChoice >= 0 && defaultChoice < len(choices) {
+			_, _ = fmt.Fprintf(h.out, "(default is %q): ", hotkeys[defaultChoice])
+		}
+
+		line, err := h.readLine()
+		if err != nil {
+			return 0, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" && defaultChoice >= 0 && defaultChoice < len(choices) {
+			return defaultChoice, nil
+		}
+		for i, key := range hotkeys {
+			if strings.EqualFold(line, key) || strings.EqualFold(line, labels[i]) {
+				return i, nil
+			}
+		}
+	}
+}
+
+func (h *terminalHost) printHeader(caption, message string) {
+	if caption != "" {
+		_, _ = fmt.Fprintln(h.out, caption)
+	}
+	if message != "" {
+		_, _ = fmt.Fprintln(h.out, message)
+	}
+}
+
+// choiceHotkey splits a label such as "&Yes" into its hotkey ("Y") and text
+// ("Yes"). Labels without a hotkey are selected by number.
+func choiceHotkey(label string, index int) (hotkey, text string) {
+	i := strings.IndexByte(label, '&')
+	if i < 0 || i == len(label)-1 {
+		return strconv.Itoa(index), label
+	}
+	text = label[:i] + label[i+1:]
+	return strings.ToUpper(label[i+1 : i+2]), text
+}
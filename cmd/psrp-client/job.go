@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/smnsjas/go-psrp/client"
+)
+
+// runJob handles -start-job, -get-job, -receive-job and -stop-job. Jobs run
+// in shells of their own, so none of these needs a connected client.
+func runJob(ctx context.Context, psrp *client.Client, start bool, script, getID, receiveID, stopID string) {
+	switch {
+	case start:
+		if script == "" {
+			fmt.Fprintln(os.Stderr, "Error: -start-job requires -script")
+			os.Exit(1)
+		}
+		id, err := psrp.StartJob(ctx, script)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting job: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Job started; it keeps running after this process exits.")
+		fmt.Printf("JobID: %s\n", id)
+		fmt.Println("To collect its output later, run:")
+		fmt.Printf("  ./psrp-client ... -receive-job %s\n", id)
+
+	case getID != "":
+		info, err := psrp.GetJob(ctx, client.JobID(getID))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error querying job: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("JobID: %s\n", info.ID)
+		fmt.Printf("  Shell state: %s\n", info.ShellState)
+		fmt.Printf("  Owner: %s\n", info.Owner)
+		fmt.Printf("  Command active: %t\n", info.CommandActive)
+
+	case receiveID != "":
+		fmt.Printf("Waiting for job %s...\n", receiveID)
+		result, err := psrp.ReceiveJob(ctx, client.JobID(receiveID))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error receiving job: %v\n", err)
+			os.Exit(1)
+		}
+		for _, obj := range result.Output {
+			fmt.Println(formatObject(obj))
+		}
+		if result.HadErrors {
+			fmt.Fprintln(os.Stderr, "Errors:")
+			for _, obj := range result.Errors {
+				fmt.Fprintln(os.Stderr, formatObject(obj))
+			}
+			os.Exit(1)
+		}
+
+	case stopID != "":
+		if err := psrp.StopJob(ctx, client.JobID(stopID)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error stopping job: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Job %s stopped.\n", stopID)
+	}
+}
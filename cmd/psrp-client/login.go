@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/smnsjas/go-psrp/client"
+	"github.com/smnsjas/go-psrp/wsman/auth"
+)
+
+// login saves the credentials of a host in the system credential store.
+// Later commands for the host use them when neither -pass nor
+// PSRP_PASSWORD is set, and -user only if it is not.
+func login(cmd *command, args []string) {
+	fs := newFlagSet(cmd)
+	username := fs.String("user", "", "Username (prompted for if not set)")
+	_ = fs.Parse(args) // ExitOnError
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	host := fs.Arg(0)
+	store := openCredentialStore()
+
+	if *username == "" {
+		fmt.Fprint(os.Stderr, "Username: ")
+		*username = readLine()
+		if *username == "" {
+			fmt.Fprintln(os.Stderr, "Error: a username is required")
+			os.Exit(1)
+		}
+	}
+	pass := getPassword("")
+	if pass == "" {
+		fmt.Fprintln(os.Stderr, "Error: a password is required")
+		os.Exit(1)
+	}
+
+	if err := store.Set(host, auth.Credentials{Username: *username, Password: pass}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving credentials: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Saved credentials of %s for %s\n", *username, host)
+}
+
+// logout removes the saved credentials of a host.
+func logout(cmd *command, args []string) {
+	fs := newFlagSet(cmd)
+	_ = fs.Parse(args) // ExitOnError
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	host := fs.Arg(0)
+
+	err := openCredentialStore().Delete(host)
+	if errors.Is(err, client.ErrCredentialNotFound) {
+		fmt.Fprintf(os.Stderr, "No saved credentials for %s\n", host)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error removing credentials: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed the credentials for %s\n", host)
+}
+
+func openCredentialStore() client.CredentialStore {
+	store, err := client.SystemCredentialStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return store
+}
+
+// savedCredentials returns the credentials saved with login for server,
+// if there are any for user; an empty user takes any.
+func savedCredentials(server, user string) (auth.Credentials, bool) {
+	if server == "" {
+		return auth.Credentials{}, false
+	}
+	store, err := client.SystemCredentialStore()
+	if err != nil {
+		return auth.Credentials{}, false
+	}
+	creds, err := store.Get(server)
+	if err != nil {
+		if !errors.Is(err, client.ErrCredentialNotFound) {
+			fmt.Fprintf(os.Stderr, "Warning: reading saved credentials: %v\n", err)
+		}
+		return auth.Credentials{}, false
+	}
+	if user != "" && !strings.EqualFold(user, creds.Username) {
+		return auth.Credentials{}, false
+	}
+	return creds, true
+}
+
+// readLine reads a line from stdin a byte at a time, so that nothing after
+// it is consumed before getPassword reads the password.
+func readLine() string {
+	var b strings.Builder
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			b.WriteByte(buf[0])
+		}
+		if err != nil {
+			break
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -3,17 +3,28 @@
 // Password can be provided via:
 //   - -pass flag (least secure, visible in process list)
 //   - PSRP_PASSWORD environment variable (recommended)
-//   - stdin prompt (if neither flag nor env var is set)
+//   - the system credential store, after 'psrp-client login <host>'
+//   - stdin prompt (if none of the above is set)
 //
 // Usage:
 //
+//	psrp-client <command> [flags] [arguments]
+//
+// The commands are exec, copy, fetch, shell, winrs and identify; all take
+// the same connection flags, which can also come from a -config profile
+// file. login and logout save and remove the credentials of a host.
+// Without a command, the flags alone select what to do, e.g.
+//
 //	psrp-client -server <hostname> -user <username> -script <command>
 //
 // Examples:
 //
 //	# Using environment variable (recommended)
 //	export PSRP_PASSWORD='secret'
-//	psrp-client -server myserver -user admin -script "Get-Process"
+//	psrp-client exec -server myserver -user admin "Get-Process"
+//
+//	# Settings from a profile, result as JSON
+//	psrp-client exec -config psrp.yaml -profile lab -output json "Get-Service"
 //
 //	# Using stdin prompt
 //	psrp-client -server myserver -user admin -script "Get-Process"
@@ -26,18 +37,22 @@ package main
 import (
 	"bufio"
 	"context"
-	"flag"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/smnsjas/go-psrp/client"
 	"github.com/smnsjas/go-psrp/internal/log"
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrp/wsman"
 	"github.com/smnsjas/go-psrp/wsman/auth"
 	"github.com/smnsjas/go-psrpcore/serialization"
 	"golang.org/x/term"
@@ -63,79 +78,172 @@ func formatBytes(bytes int64) string {
 	}
 }
 
-func main() {
+// run runs the client with the command-line flags in args. cmd, if not
+// nil, is the subcommand whose positional arguments select what to do.
+func run(cmd *command, args []string) {
 	// Parse command line flags
-	server := flag.String("server", "", "WinRM server hostname")
-	username := flag.String("user", "", "Username for authentication")
-	password := flag.String("pass", "", "Password (use PSRP_PASSWORD env var instead)")
-	script := flag.String("script", "", "PowerShell script to execute")
-	useTLS := flag.Bool("tls", false, "Use HTTPS (port 5986)")
-	port := flag.Int("port", 0, "WinRM port (default: 5985 for HTTP, 5986 for HTTPS)")
-	insecure := flag.Bool("insecure", false, "Skip TLS certificate verification")
-	timeout := flag.Duration("timeout", 120*time.Second, "Operation timeout")
-	useNTLM := flag.Bool("ntlm", false, "Use NTLM authentication")
-	useKerberos := flag.Bool("kerberos", false, "Use Kerberos authentication")
-	realm := flag.String("realm", "", "Kerberos realm (e.g., EXAMPLE.COM)")
-	krb5Conf := flag.String("krb5conf", "", "Path to krb5.conf file")
-	ccache := flag.String("ccache", "", "Path to Kerberos credential cache (e.g. /tmp/krb5cc_1000)")
-	spn := flag.String("spn", "", "Service Principal Name for Kerberos (e.g., HTTP/server.domain.com)")
+	fs := newFlagSet(cmd)
+	server := fs.String("server", "", "WinRM server hostname")
+	username := fs.String("user", "", "Username for authentication")
+	password := fs.String("pass", "", "Password (use PSRP_PASSWORD env var instead)")
+	script := fs.String("script", "", "PowerShell script to execute")
+	useTLS := fs.Bool("tls", false, "Use HTTPS (port 5986)")
+	port := fs.Int("port", 0, "WinRM port (default: 5985 for HTTP, 5986 for HTTPS)")
+	insecure := fs.Bool("insecure", false, "Skip TLS certificate verification")
+	timeout := fs.Duration("timeout", 120*time.Second, "Operation timeout")
+	useNTLM := fs.Bool("ntlm", false, "Use NTLM authentication")
+	useKerberos := fs.Bool("kerberos", false, "Use Kerberos authentication")
+	realm := fs.String("realm", "", "Kerberos realm (e.g., EXAMPLE.COM)")
+	krb5Conf := fs.String("krb5conf", "", "Path to krb5.conf file")
+	ccache := fs.String("ccache", "", "Path to Kerberos credential cache (e.g. /tmp/krb5cc_1000)")
+	spn := fs.String("spn", "", "Service Principal Name for Kerberos (e.g., HTTP/server.domain.com)")
+	delegate := fs.Bool("delegate", false, "Delegate Kerberos credentials for second-hop access (Windows only)")
+	spnService := fs.String("spn-service", "", "Service class of the default SPN (default WSMAN; e.g., HTTP)")
+	spnHost := fs.String("spn-host", "", "Host name of the default SPN (e.g., a load balancer's cluster name)")
+	spnCanonicalize := fs.String("spn-canonicalize", "", "Comma-separated SPN host canonicalization: strip-port, fqdn, rdns")
+	useSSPI := fs.Bool("sspi", false, "Use Windows SSPI for NTLM/Negotiate (SSO without -user; Windows only)")
 
 	// HvSocket (PowerShell Direct) flags
-	useHvSocket := flag.Bool("hvsocket", false, "Use Hyper-V Socket (PowerShell Direct) transport")
-	vmID := flag.String("vmid", "", "VM GUID for HvSocket connection")
+	useHvSocket := fs.Bool("hvsocket", false, "Use Hyper-V Socket (PowerShell Direct) transport")
+	vmID := fs.String("vmid", "", "VM GUID for HvSocket connection")
+
+	// SSH (PowerShell 7 remoting) flags
+	useSSH := fs.Bool("ssh", false, "Use SSH transport (PowerShell SSH subsystem)")
+	sshKey := fs.String("ssh-key", "", "Private key file for SSH public key authentication")
+	sshKnownHosts := fs.String("ssh-known-hosts", "", "known_hosts file for SSH host key verification (default ~/.ssh/known_hosts)")
+
+	// Local (no WinRM) flags
+	useLocal := fs.Bool("local", false, "Run a local PowerShell process in server mode (no WinRM)")
+	pwshPath := fs.String("pwsh", "", "PowerShell executable for -local (default pwsh on PATH)")
+	attachPID := fs.Int("attach-pid", 0, "Attach to a running local PowerShell process by PID (like Enter-PSHostProcess)")
 	var configName string
-	flag.StringVar(&configName, "configname", "", "PowerShell configuration name (e.g. Microsoft.Exchange)")
-
-	subscribe := flag.String("subscribe", "", "WQL query to subscribe to (e.g. 'SELECT * FROM Win32_ProcessStartTrace')")
-	domain := flag.String("domain", ".", "Domain for HvSocket auth (use '.' for local accounts)")
+	fs.StringVar(&configName, "configname", "", "PowerShell configuration name (e.g. Microsoft.Exchange)")
+
+	subscribe := fs.String("subscribe", "", "WQL query to subscribe to (e.g. 'SELECT * FROM Win32_ProcessStartTrace')")
+	domain := fs.String("domain", ".", "Domain for HvSocket auth (use '.' for local accounts)")
+	pipeStdin := fs.Bool("stdin", false, "Send standard input lines to -script as pipeline input ($input)")
+	exportPath := fs.String("export", "", "Write the -script output to this file: JSON for *.json, otherwise CLIXML for Import-Clixml")
+	interactive := fs.Bool("interactive", false, "Open an interactive session like Enter-PSSession, or with -script answer its prompts; Read-Host and confirmation prompts are answered from the terminal (WSMan only)")
+	tags := map[string]string{}
+	fs.Func("tag", "Session tag key=value added to logs and security events (repeatable)", func(s string) error {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("want key=value, got %q", s)
+		}
+		tags[k] = v
+		return nil
+	})
+	sendTags := fs.Bool("send-tags", false, "Also send -tag values to the server as ApplicationArguments (WSMan only)")
 
 	// Session persistence flags
-	doDisconnect := flag.Bool("disconnect", false, "Disconnect from shell after execution (instead of closing)")
-	reconnectShellID := flag.String("reconnect", "", "Reconnect to existing ShellID")
-	sessionID := flag.String("sessionid", "", "Explicit SessionID (uuid:...) for testing persistence")
-	poolID := flag.String("poolid", "", "Explicit PoolID (uuid:...) for reconnection")
-	listSessions := flag.Bool("list-sessions", false, "List disconnected sessions on server")
-	cleanupSessions := flag.Bool("cleanup", false, "Cleanup (remove) disconnected sessions (used with -list-sessions)")
-	recoverCommandID := flag.String("recover", "", "Recover output from pipeline with CommandID (requires -reconnect)")
-	asyncExec := flag.Bool("async", false, "Start command and disconnect immediately (fire-and-forget)")
-	saveSession := flag.String("save-session", "", "Save session state to file on disconnect/exit")
-	restoreSession := flag.String("restore-session", "", "Restore session state from file")
-	logLevel := flag.String("loglevel", "", "Log level: debug, info, warn, error (empty = no logging)")
-	keepAlive := flag.Duration("keepalive", 0, "Keepalive interval (e.g. 30s). 0 to disable.")
-	idleTimeout := flag.String("idle-timeout", "", "WSMan shell idle timeout (ISO8601 duration, e.g. PT1H, PT30M)")
-	enableCBT := flag.Bool("cbt", false, "Enable Channel Binding Tokens (CBT) for NTLM (Extended Protection)")
-	testConcurrency := flag.Int("test-concurrency", 0, "Test semaphore: spawn N concurrent commands (requires -script)")
-	maxRunspaces := flag.Int("max-runspaces", 1, "Max concurrent pipelines (default: 1)")
+	doDisconnect := fs.Bool("disconnect", false, "Disconnect from shell after execution (instead of closing)")
+	reconnectShellID := fs.String("reconnect", "", "Reconnect to existing ShellID")
+	sessionID := fs.String("sessionid", "", "Explicit SessionID (uuid:...) for testing persistence")
+	poolID := fs.String("poolid", "", "Explicit PoolID (uuid:...) for reconnection")
+	listSessions := fs.Bool("list-sessions", false, "List disconnected sessions on server")
+	cleanupSessions := fs.Bool("cleanup", false, "Cleanup (remove) disconnected sessions (used with -list-sessions)")
+	recoverCommandID := fs.String("recover", "", "Recover output from pipeline with CommandID (requires -reconnect)")
+	asyncExec := fs.Bool("async", false, "Start command and disconnect immediately (fire-and-forget)")
+	startJob := fs.Bool("start-job", false, "Start -script as a job that keeps running after exit and print its JobID (WSMan only)")
+	getJob := fs.String("get-job", "", "Show the server state of a job by JobID")
+	receiveJob := fs.String("receive-job", "", "Wait for a job by JobID, print its output and remove it")
+	stopJob := fs.String("stop-job", "", "Stop a job by JobID and discard its output")
+	saveSession := fs.String("save-session", "", "Save session state to file on disconnect/exit")
+	restoreSession := fs.String("restore-session", "", "Restore session state from file")
+	logLevel := fs.String("loglevel", "", "Log level: debug, info, warn, error (empty = no logging)")
+	keepAlive := fs.Duration("keepalive", 0, "Keepalive interval (e.g. 30s). 0 to disable.")
+	keepAliveStrategy := fs.String("keepalive-strategy", "", "Keepalive heartbeat on any transport: message or pipeline (needs -keepalive)")
+	idleTimeout := fs.String("idle-timeout", "", "WSMan shell idle timeout (ISO8601 duration, e.g. PT1H, PT30M)")
+	operationTimeout := fs.Duration("operation-timeout", 0, "WSMan operation timeout (default: 60s)")
+	maxEnvelopeKB := fs.Int("max-envelope-kb", 0, "WSMan MaxEnvelopeSize in KB (default: 500)")
+	locale := fs.String("locale", "", "Language of server messages, e.g. de-DE (default: en-US)")
+	dataLocale := fs.String("data-locale", "", "Culture for formatting dates and numbers (default: -locale)")
+	negotiateLimits := fs.Bool("negotiate-limits", false, "Read envelope size and timeout limits from the server's winrm/config (needs admin)")
+	wsmanRetries := fs.Int("wsman-retries", 0, "Attempts for idempotent WSMan operations (Receive, Signal, Delete) on transient errors (0 = no retry)")
+	maxBandwidthKB := fs.Int("max-bandwidth-kb", 0, "Cap the client's combined send and receive rate in KB/s, e.g. on shared WAN links (0 = unlimited)")
+	traceWSMan := fs.Bool("trace-wsman", false, "Log every WSMan request and response, credentials redacted (needs -loglevel debug)")
+	enableCBT := fs.Bool("cbt", false, "Enable Channel Binding Tokens (CBT) for NTLM (Extended Protection)")
+	testConcurrency := fs.Int("test-concurrency", 0, "Test semaphore: spawn N concurrent commands (requires -script)")
+	maxRunspaces := fs.Int("max-runspaces", 1, "Max concurrent pipelines (default: 1)")
 	// Retry flags
-	retryAttempts := flag.Int("retry-attempts", 0, "Max command retry attempts (default: 0 = disabled)")
-	retryDelay := flag.Duration("retry-delay", 100*time.Millisecond, "Initial retry delay")
-	retryMaxDelay := flag.Duration("retry-max-delay", 5*time.Second, "Max retry delay")
-	retryMaxDuration := flag.Duration("retry-max-duration", 0, "Max total retry duration (0 = unlimited)")
+	retryAttempts := fs.Int("retry-attempts", 0, "Max command retry attempts (default: 0 = disabled)")
+	retryDelay := fs.Duration("retry-delay", 100*time.Millisecond, "Initial retry delay")
+	retryMaxDelay := fs.Duration("retry-max-delay", 5*time.Second, "Max retry delay")
+	retryMaxDuration := fs.Duration("retry-max-duration", 0, "Max total retry duration (0 = unlimited)")
 
 	// Circuit Breaker flags
-	breakerThreshold := flag.Int("breaker-threshold", 5, "Circuit Breaker failure threshold (0 to disable)")
-	breakerTimeout := flag.Duration("breaker-timeout", 30*time.Second, "Circuit Breaker reset timeout")
+	breakerThreshold := fs.Int("breaker-threshold", 5, "Circuit Breaker failure threshold (0 to disable)")
+	breakerTimeout := fs.Duration("breaker-timeout", 30*time.Second, "Circuit Breaker reset timeout")
 
 	// File transfer flags
-	copyFile := flag.String("copy", "", "Copy local file to remote (format: local=>remote, e.g. /tmp/file.txt=>C:\\Temp\\file.txt)")
-	fetchFile := flag.String("fetch", "", "Fetch remote file to local (format: remote=>local, e.g. C:\\Temp\\file.txt=>/tmp/file.txt)")
-	verifyChecksum := flag.Bool("verify", false, "Verify file transfer with SHA256 checksum")
-	chunkSize := flag.Int("chunk-size", 0, "File transfer chunk size in bytes (0 = auto-detect based on transport: 350KB for WSMan, 1MB for HvSocket)")
-	noOverwrite := flag.Bool("no-overwrite", false, "Fail if destination file already exists")
-	concurrency := flag.Int("concurrency", 0, "Max concurrency for file transfers (0 = default: 4)")
-
-	autoReconnect := flag.Bool("auto-reconnect", false, "Enable automatic reconnection on failures")
-	useCmd := flag.Bool("cmd", false, "Use WinRS (cmd.exe) instead of PowerShell for command execution")
-	proxyURL := flag.String("proxy", "", "HTTP proxy URL (e.g., http://proxy:8080). Use 'direct' to bypass proxy.")
+	copyFile := fs.String("copy", "", "Copy local file to remote (format: local=>remote, e.g. /tmp/file.txt=>C:\\Temp\\file.txt)")
+	fetchFile := fs.String("fetch", "", "Fetch remote file to local (format: remote=>local, e.g. C:\\Temp\\file.txt=>/tmp/file.txt)")
+	verifyChecksum := fs.Bool("verify", false, "Verify file transfer with SHA256 checksum")
+	chunkSize := fs.Int("chunk-size", 0, "File transfer chunk size in bytes (0 = auto-detect based on transport: 350KB for WSMan, 1MB for HvSocket)")
+	noOverwrite := fs.Bool("no-overwrite", false, "Fail if destination file already exists")
+	concurrency := fs.Int("concurrency", 0, "Max concurrency for file transfers (0 = default: 4)")
+	whatIf := fs.Bool("whatif", false, "With -copy, check the upload (paths, permissions, free space, overwrite) and print the plan without writing")
+	autoTune := fs.Bool("auto-tune", false, "Probe chunk size and concurrency at the start of large file transfers (16MB+)")
+	bits := fs.Bool("bits", false, "With -copy, have the server pull files of 512MB+ with BITS from a temporary HTTPS endpoint here, lifting the 1GB size limit; falls back to the PSRP transfer")
+	copyDir := fs.String("copy-dir", "", "Copy local directory to remote recursively (format: local=>remote)")
+	fetchDir := fs.String("fetch-dir", "", "Fetch remote directory to local recursively (format: remote=>local)")
+	var includes, excludes []string
+	fs.Func("include", "Glob of files to include in -copy-dir/-fetch-dir, e.g. '*.log' (repeatable)", func(s string) error {
+		includes = append(includes, s)
+		return nil
+	})
+	fs.Func("exclude", "Glob of files or directories to exclude from -copy-dir/-fetch-dir (repeatable)", func(s string) error {
+		excludes = append(excludes, s)
+		return nil
+	})
+	preserveTimes := fs.Bool("preserve-times", false, "Preserve file modification times in -copy-dir/-fetch-dir")
+	manifestPath := fs.String("manifest", "", "With -fetch-dir, verify files against a remote manifest and write the JSON report to this file")
+	skipUnchanged := fs.String("skip-unchanged", "none", "Skip unchanged files in -copy-dir/-fetch-dir: none, size-mtime or hash")
+
+	warmUp := fs.Int("warmup", 0, "Open this many runspaces before running -script (capped at -max-runspaces)")
+	autoReconnect := fs.Bool("auto-reconnect", false, "Enable automatic reconnection on failures")
+	useCmd := fs.Bool("cmd", false, "Use WinRS (cmd.exe) instead of PowerShell for command execution")
+	proxyURL := fs.String("proxy", "", "Proxy URL: http://proxy:8080 or socks5://bastion:1080. Use 'direct' to bypass proxy.")
+	proxyUser := fs.String("proxy-user", "", "Proxy username (password from PSRP_PROXY_PASSWORD env var)")
+	gateway := fs.String("gateway", "", "TLS gateway host:port to connect to; -server is still sent as SNI and Host")
+	tlsServerName := fs.String("tls-server-name", "", "Override the TLS server name (SNI) and certificate name")
+	caFile := fs.String("ca-file", "", "PEM file with the CA certificates to trust instead of the system roots")
+	certFile := fs.String("cert-file", "", "PEM client certificate (chain) for mutual TLS; needs -key-file")
+	keyFile := fs.String("key-file", "", "PEM private key for -cert-file")
+	tlsMinVersion := fs.String("tls-min-version", "", "Minimum TLS version: 1.2 (default) or 1.3")
+	pinSHA256 := fs.String("pin-sha256", "", "Comma-separated SHA-256 fingerprints of the server certificates to accept (instead of CA verification)")
+	knownCerts := fs.String("known-certs", "", "Trust-on-first-use file of server certificate fingerprints")
+	alpn := fs.String("alpn", "", "Comma-separated ALPN protocols to offer (e.g., http/1.1)")
+	hostAliases := fs.String("host-alias", "", "Comma-separated ip=hostname pairs used for the Kerberos SPN and TLS name (e.g., 10.0.0.5=web01.corp.com)")
 
 	// Enhanced logging flags
-	logFile := flag.String("logfile", "", "Write logs to file (in addition to stderr unless -quiet)")
-	logFormat := flag.String("logformat", "text", "Log output format: text or json")
-	quiet := flag.Bool("quiet", false, "Suppress stderr logging (only log to file)")
-	logRotateMaxSize := flag.Int("logrotate-max-size", 10, "Max size per log file in MB")
-	logRotateMaxFiles := flag.Int("logrotate-max-files", 5, "Max log backups to keep")
-
-	flag.Parse()
+	logFile := fs.String("logfile", "", "Write logs to file (in addition to stderr unless -quiet)")
+	logFormat := fs.String("logformat", "text", "Log output format: text or json")
+	quiet := fs.Bool("quiet", false, "Suppress stderr logging (only log to file)")
+	logRotateMaxSize := fs.Int("logrotate-max-size", 10, "Max size per log file in MB")
+	logRotateMaxFiles := fs.Int("logrotate-max-files", 5, "Max log backups to keep")
+
+	// Profile and output flags
+	configFile := fs.String("config", "", "YAML or TOML file of flag values; flags on the command line take precedence")
+	profileName := fs.String("profile", "", "Section of the -config file whose values are used over its top-level ones")
+	outputFormat := fs.String("output", outputText, "Result format: text, table, json or yaml")
+	identify := fs.Bool("identify", false, "Show the protocol and product version of the WinRM service (WS-Management Identify)")
+
+	_ = fs.Parse(args)
+	if err := applyArguments(fs, cmd, *configFile, *profileName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if !validOutputFormat(*outputFormat) {
+		fmt.Fprintf(os.Stderr, "Error: -output must be text, table, json or yaml, got %q\n", *outputFormat)
+		os.Exit(2)
+	}
+	// Status messages go to stderr when stdout carries a json or yaml document
+	status := io.Writer(os.Stdout)
+	if *outputFormat == outputJSON || *outputFormat == outputYAML {
+		status = os.Stderr
+	}
+	isLocal := *useLocal || *attachPID != 0
 
 	if *logLevel != "" {
 		_ = os.Setenv("PSRP_DEBUG", "1") // Enable legacy debug as well
@@ -198,34 +306,42 @@ func main() {
 		// If creating client later, we'll set it there too, but setting default covers global usage
 	}
 
-	fmt.Println("PSRP Client - Codebase Fix v15 (Cleanup)")
+	fmt.Fprintln(status, "PSRP Client - Codebase Fix v15 (Cleanup)")
 
 	// Validate required flags
 	// If restoring session, we don't need server or vmid flags as they come from the state file
 	if *restoreSession == "" {
-		if *server == "" && !*useHvSocket {
+		if *server == "" && !*useHvSocket && !isLocal {
 			fmt.Fprintln(os.Stderr, "Error: -server is required (or use -hvsocket with -vmid)")
-			flag.Usage()
+			fs.Usage()
 			os.Exit(1)
 		}
 		if *useHvSocket && *vmID == "" {
 			fmt.Fprintln(os.Stderr, "Error: -vmid is required when using -hvsocket")
-			flag.Usage()
+			fs.Usage()
 			os.Exit(1)
 		}
 	}
+	// Credentials saved with 'psrp-client login' stand in for -user and the
+	// password prompt
+	var pass string
+	if !isLocal && *password == "" && os.Getenv("PSRP_PASSWORD") == "" {
+		if creds, ok := savedCredentials(*server, *username); ok {
+			*username = creds.Username
+			pass = creds.Password
+		}
+	}
+
 	// Validate flags
 	// Username is required unless the platform supports SSO (e.g. Windows)
-	if *username == "" && !auth.SupportsSSO() {
+	if *username == "" && !auth.SupportsSSO() && !isLocal {
 		fmt.Fprintln(os.Stderr,
 			"Error: -user is required (SSO not supported on this platform)")
-		flag.Usage()
+		fs.Usage()
 		os.Exit(1)
 	}
 
 	// Check for Kerberos cred cache first (SSO)
-	var pass string
-
 	// Auto-detect Kerberos cache on macOS if -kerberos is set and no cache specified
 	detectedCache := *ccache
 	if *useKerberos && detectedCache == "" && os.Getenv("KRB5CCNAME") == "" {
@@ -293,8 +409,8 @@ func main() {
 
 	// Get password only if username is provided and no cache (or strict NTLM usage)
 	// For SSO (no username), password is not needed
-	if *username != "" && !hasCache {
-		// Get password from: flag > env var > stdin prompt
+	if *username != "" && !hasCache && pass == "" {
+		// Get password from: flag > env var > saved > stdin prompt
 		pass = getPassword(*password)
 	}
 
@@ -303,7 +419,7 @@ func main() {
 	// If SSO (no username), password is not required
 	// But if strictly HvSocket (which often needs creds) or restoring session where we need creds to reconnect:
 	needCreds := *username != "" || (*restoreSession != "" && !hasCache && !auth.SupportsSSO())
-	if needCreds && pass == "" && !hasCache {
+	if needCreds && pass == "" && !hasCache && !(*useSSH && *sshKey != "") {
 		fmt.Fprintln(os.Stderr, "Error: password is required (use -pass, PSRP_PASSWORD env, or stdin)")
 		os.Exit(1)
 	}
@@ -316,11 +432,79 @@ func main() {
 	cfg.InsecureSkipVerify = *insecure
 	cfg.Timeout = *timeout
 	cfg.KeepAliveInterval = *keepAlive
+	if *keepAliveStrategy != "" {
+		policy := &client.KeepAlivePolicy{
+			Interval: *keepAlive,
+			OnStateChange: func(from, to client.KeepAliveState) {
+				fmt.Fprintf(os.Stderr, "Connection health: %v -> %v\n", from, to)
+			},
+		}
+		switch *keepAliveStrategy {
+		case "message":
+			policy.Strategy = client.KeepAliveMessage
+		case "pipeline":
+			policy.Strategy = client.KeepAlivePipeline
+		default:
+			fmt.Fprintf(os.Stderr, "Error: invalid -keepalive-strategy %q: use message or pipeline\n", *keepAliveStrategy)
+			os.Exit(1)
+		}
+		cfg.KeepAlive = policy
+	}
 	cfg.IdleTimeout = *idleTimeout
+	cfg.WSManOptions.OperationTimeout = *operationTimeout
+	cfg.WSManOptions.MaxEnvelopeSize = *maxEnvelopeKB * 1024
+	cfg.MaxBandwidthBytesPerSec = int64(*maxBandwidthKB) * 1024
+	cfg.Locale = *locale
+	cfg.DataLocale = *dataLocale
+	cfg.NegotiateWSManLimits = *negotiateLimits
+	if *wsmanRetries > 1 {
+		cfg.WSManOptions.Retry = &wsman.RetryPolicy{MaxAttempts: *wsmanRetries}
+	}
+	if *traceWSMan {
+		cfg.WSManOptions.Tracer = wsman.NewSlogTracer(slog.Default())
+	}
 	cfg.EnableCBT = *enableCBT
 	cfg.MaxRunspaces = *maxRunspaces
+	// Interactive session: one runspace keeps variables and functions
+	// from one command to the next
+	replMode := *interactive && *script == "" && !*useCmd
+	if replMode {
+		cfg.MaxRunspaces = 1
+	}
 	cfg.Reconnect.Enabled = *autoReconnect
 	cfg.ProxyURL = *proxyURL
+	cfg.ProxyUsername = *proxyUser
+	if *proxyUser != "" {
+		cfg.ProxyPassword = os.Getenv("PSRP_PROXY_PASSWORD")
+	}
+	cfg.GatewayAddress = *gateway
+	cfg.TLSServerName = *tlsServerName
+	if *caFile != "" || *certFile != "" || *keyFile != "" || *tlsMinVersion != "" {
+		tlsConfig, err := loadTLSConfig(*caFile, *certFile, *keyFile, *tlsMinVersion)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.TLS = tlsConfig
+	}
+	if *pinSHA256 != "" {
+		cfg.PinnedCertSHA256 = strings.Split(*pinSHA256, ",")
+	}
+	cfg.KnownCertsFile = *knownCerts
+	if *alpn != "" {
+		cfg.TLSNextProtos = strings.Split(*alpn, ",")
+	}
+	if *hostAliases != "" {
+		cfg.HostAliases = make(map[string]string)
+		for _, pair := range strings.Split(*hostAliases, ",") {
+			from, to, ok := strings.Cut(pair, "=")
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Error: invalid -host-alias %q: use ip=hostname\n", pair)
+				os.Exit(1)
+			}
+			cfg.HostAliases[strings.TrimSpace(from)] = strings.TrimSpace(to)
+		}
+	}
 
 	// Configure Retry Policy
 	if *retryAttempts > 0 {
@@ -366,6 +550,8 @@ func main() {
 	// Kerberos settings apply to both AuthNegotiate (default) and explicit -kerberos
 	cfg.Realm = *realm
 	cfg.Krb5ConfPath = *krb5Conf
+	cfg.KerberosDelegate = *delegate
+	cfg.UseSSPI = *useSSPI
 	cfg.CCachePath = detectedCache // Use auto-detected cache if available
 	// Default to environment variables if not set
 	if cfg.CCachePath == "" {
@@ -378,6 +564,23 @@ func main() {
 		cfg.Krb5ConfPath = os.Getenv("KRB5_CONFIG")
 	}
 	cfg.TargetSPN = *spn
+	cfg.SPNService = *spnService
+	cfg.SPNHostnameOverride = *spnHost
+	if *spnCanonicalize != "" {
+		for _, opt := range strings.Split(*spnCanonicalize, ",") {
+			switch strings.TrimSpace(opt) {
+			case "strip-port":
+				cfg.SPNCanonicalize |= client.SPNStripPort
+			case "fqdn":
+				cfg.SPNCanonicalize |= client.SPNUseFQDN
+			case "rdns":
+				cfg.SPNCanonicalize |= client.SPNReverseDNS
+			default:
+				fmt.Fprintf(os.Stderr, "Error: invalid -spn-canonicalize %q: use strip-port, fqdn or rdns\n", opt)
+				os.Exit(1)
+			}
+		}
+	}
 
 	// Override auth type if explicit flag set
 	if *useKerberos {
@@ -401,11 +604,55 @@ func main() {
 		cfg.Domain = *domain
 	}
 
+	// SSH transport
+	if *useSSH {
+		cfg.Transport = client.TransportSSH
+		cfg.SSH = &client.SSHOptions{
+			Port:           *port, // 0 = default SSH port
+			PrivateKeyPath: *sshKey,
+			KnownHostsPath: *sshKnownHosts,
+		}
+	}
+
+	// Local transports
+	if *attachPID != 0 {
+		cfg.Transport = client.TransportNamedPipe
+		cfg.NamedPipe = &client.NamedPipeOptions{ProcessID: *attachPID}
+	} else if *useLocal {
+		cfg.Transport = client.TransportProcess
+		cfg.Process = &client.ProcessOptions{Path: *pwshPath}
+	}
+
+	// Session tags
+	if len(tags) > 0 {
+		cfg.Tags = tags
+		cfg.SendTagsToServer = *sendTags
+	}
+
+	// Remote host prompts
+	var host *terminalHost
+	if *interactive {
+		host = newTerminalHost(os.Stdin, os.Stdout, os.Stderr)
+		cfg.Host = host
+		// Format remote output to the terminal width
+		if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+			info := powershell.DefaultHostInfo
+			info.BufferSize.Width = width
+			info.WindowSize = powershell.Size{Width: width, Height: height}
+			cfg.HostInfo = &info
+		}
+	}
+
 	// Apply ConfigurationName if provided (applies to both WSMan and HvSocket)
 	if configName != "" {
 		cfg.ConfigurationName = configName
 	}
 
+	// Configure structured logging if requested - use the logger already set via slog.SetDefault()
+	if *logLevel != "" {
+		cfg.Logger = slog.Default()
+	}
+
 	// Create client
 	psrp, err := client.New(*server, cfg)
 	if err != nil {
@@ -413,11 +660,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Configure structured logging if requested - use the logger already set via slog.SetDefault()
-	if *logLevel != "" {
-		psrp.SetSlogLogger(slog.Default())
-	}
-
 	if *sessionID != "" {
 		psrp.SetSessionID(*sessionID)
 	}
@@ -428,11 +670,31 @@ func main() {
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	// The interactive session lasts until exit; -timeout still bounds
+	// each request
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if replMode {
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithTimeout(context.Background(), *timeout)
+	}
 	defer cancel()
 
 	// Connect to server (or reconnect)
-	fmt.Printf("Connecting to %s...\n", psrp.Endpoint())
+	// Identify needs no shell
+	if *identify {
+		runIdentify(ctx, psrp, *outputFormat)
+		return
+	}
+
+	fmt.Fprintf(status, "Connecting to %s...\n", psrp.Endpoint())
+
+	// Handle jobs (each job has its own shell; no connection needed)
+	if *startJob || *getJob != "" || *receiveJob != "" || *stopJob != "" {
+		runJob(ctx, psrp, *startJob, *script, *getJob, *receiveJob, *stopJob)
+		return
+	}
 
 	// Handle list-sessions mode (doesn't require full connection)
 	if *listSessions {
@@ -544,6 +806,12 @@ func main() {
 				fmt.Fprintf(os.Stderr, "Error connecting: %v\n", err)
 				os.Exit(1)
 			}
+			if *warmUp > 0 {
+				if err := psrp.WarmUp(ctx, *warmUp); err != nil {
+					fmt.Fprintf(os.Stderr, "Error warming up: %v\n", err)
+					os.Exit(1)
+				}
+			}
 		}
 	}
 
@@ -552,11 +820,11 @@ func main() {
 		defer psrp.Close(ctx)
 	}
 
-	fmt.Println("Connected!")
+	fmt.Fprintln(status, "Connected!")
 	if !*useCmd {
 		// Only show PSRP state for PowerShell mode
-		fmt.Printf("State: %s\n", psrp.State())
-		fmt.Printf("Health: %s\n", psrp.Health())
+		fmt.Fprintf(status, "State: %s\n", psrp.State())
+		fmt.Fprintf(status, "Health: %s\n", psrp.Health())
 	}
 
 	// Handle recovery
@@ -632,6 +900,42 @@ func main() {
 		return
 	}
 
+	// Handle directory copy/fetch
+	if *copyDir != "" || *fetchDir != "" {
+		mode, err := parseSkipMode(*skipUnchanged)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -skip-unchanged: %v\n", err)
+			os.Exit(1)
+		}
+		opts := []client.FileTransferOption{
+			client.WithInclude(includes...),
+			client.WithExclude(excludes...),
+			client.WithPreserveTimestamps(*preserveTimes),
+			client.WithSkipUnchanged(mode),
+		}
+		if *verifyChecksum {
+			opts = append(opts, client.WithChecksumVerification(true))
+		}
+		if *chunkSize > 0 {
+			opts = append(opts, client.WithChunkSize(*chunkSize))
+		}
+		if *concurrency > 0 {
+			opts = append(opts, client.WithFileConcurrency(*concurrency))
+		}
+		if *autoTune {
+			opts = append(opts, client.WithAutoTune(0))
+		}
+		if *copyDir != "" {
+			runDirectoryTransfer(psrp, *copyDir, true, opts, "")
+		} else {
+			if *manifestPath != "" {
+				opts = append(opts, client.WithManifestVerification(true))
+			}
+			runDirectoryTransfer(psrp, *fetchDir, false, opts, *manifestPath)
+		}
+		return
+	}
+
 	// Handle file copy (upload)
 	if *copyFile != "" {
 		parts := strings.SplitN(*copyFile, "=>", 2)
@@ -664,6 +968,24 @@ func main() {
 		if *concurrency > 0 {
 			opts = append(opts, client.WithMaxConcurrency(*concurrency))
 		}
+		if *autoTune {
+			opts = append(opts, client.WithAutoTune(0))
+		}
+		if *bits {
+			opts = append(opts, client.WithBITSOffload(client.BITSOffload{}), client.WithMaxFileSize(-1))
+		}
+		if *whatIf {
+			plan, err := psrp.PlanCopyFile(context.Background(), localPath, remotePath, opts...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error checking copy: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("What if: %s\n", plan)
+			if !plan.OK() {
+				os.Exit(1)
+			}
+			return
+		}
 		opts = append(opts, client.WithProgressCallback(newProgressPrinter(os.Stderr)))
 
 		// Track duration
@@ -708,6 +1030,9 @@ func main() {
 		if *chunkSize > 0 {
 			opts = append(opts, client.WithChunkSize(*chunkSize))
 		}
+		if *autoTune {
+			opts = append(opts, client.WithAutoTune(0))
+		}
 
 		// Track duration
 		startTime := time.Now()
@@ -814,10 +1139,19 @@ func main() {
 		}
 	}
 
+	// Interactive session
+	if replMode {
+		session := newREPL(psrp, *server, host, cfg.Transport == client.TransportWSMan)
+		if err := session.run(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Normal Execution Mode
 	if *script != "" {
-		fmt.Printf("Executing: %s\n", *script)
-		fmt.Println("---")
+		fmt.Fprintf(status, "Executing: %s\n", *script)
+		fmt.Fprintln(status, "---")
 
 		if *useCmd {
 			// WinRS (cmd.exe) execution
@@ -847,55 +1181,30 @@ func main() {
 			}
 		} else {
 			// PowerShell (PSRP) execution
-			result, err := psrp.Execute(ctx, *script)
+			var result *client.Result
+			if *pipeStdin {
+				result, err = psrp.ExecutePipe(ctx, *script, os.Stdin)
+			} else {
+				// The exit code goes into the json and yaml output
+				result, err = psrp.ExecuteWithOptions(ctx, *script, client.ExecOptions{ReportExitCode: true})
+			}
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error executing script: %v\n", err)
 				os.Exit(1)
 			}
 
-			// Print output - format each object for display
-			fmt.Println("Output:")
-			for _, obj := range result.Output {
-				fmt.Println(formatObject(obj))
-			}
-
-			// Print information stream (Write-Host output)
-			if len(result.Information) > 0 {
-				fmt.Println("Information:")
-				for _, obj := range result.Information {
-					fmt.Println(formatObject(obj))
-				}
-			}
-
-			// Print warnings
-			if len(result.Warnings) > 0 {
-				fmt.Println("Warnings:")
-				for _, obj := range result.Warnings {
-					fmt.Println(formatObject(obj))
-				}
-			}
-
-			// Print verbose
-			if len(result.Verbose) > 0 {
-				fmt.Println("Verbose:")
-				for _, obj := range result.Verbose {
-					fmt.Println(formatObject(obj))
+			if *exportPath != "" {
+				if err := exportResult(result, *exportPath); err != nil {
+					fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+					os.Exit(1)
 				}
 			}
 
-			// Print debug
-			if len(result.Debug) > 0 {
-				fmt.Println("Debug:")
-				for _, obj := range result.Debug {
-					fmt.Println(formatObject(obj))
-				}
+			if err := writeResult(os.Stdout, os.Stderr, result, *outputFormat); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing result: %v\n", err)
+				os.Exit(1)
 			}
-
 			if result.HadErrors {
-				fmt.Fprintln(os.Stderr, "Errors:")
-				for _, obj := range result.Errors {
-					fmt.Fprintln(os.Stderr, formatObject(obj))
-				}
 				os.Exit(1)
 			}
 		}
@@ -964,6 +1273,42 @@ func newProgressPrinter(w io.Writer) func(int64, int64) {
 	}
 }
 
+// loadTLSConfig builds the TLS configuration for the -ca-file, -cert-file,
+// -key-file and -tls-min-version flags.
+func loadTLSConfig(caFile, certFile, keyFile, minVersion string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	switch minVersion {
+	case "", "1.2":
+	case "1.3":
+		cfg.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("invalid -tls-min-version %q: use 1.2 or 1.3", minVersion)
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read -ca-file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("-ca-file %s contains no PEM certificates", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("-cert-file and -key-file must be used together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
 // getPassword returns password from flag, env var, or prompts for it.
 func getPassword(flagValue string) string {
 	// 1. Check flag
@@ -1043,3 +1388,21 @@ func formatObject(v interface{}) string {
 		return fmt.Sprintf("%v", v)
 	}
 }
+
+// exportResult writes the output of result to path, as JSON if the name
+// ends in .json and as CLIXML otherwise.
+func exportResult(result *client.Result, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	write := result.WriteCLIXML
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		write = result.WriteJSON
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/smnsjas/go-psrp/client"
+	"github.com/smnsjas/go-psrp/powershell"
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// Result formats of -output.
+const (
+	outputText  = "text"
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+func validOutputFormat(format string) bool {
+	switch format {
+	case outputText, outputTable, outputJSON, outputYAML:
+		return true
+	}
+	return false
+}
+
+// writeResult prints result in format. The json and yaml formats write one
+// document with all streams to out; text and table print errors to errOut.
+func writeResult(out, errOut io.Writer, result *client.Result, format string) error {
+	switch format {
+	case outputJSON, outputYAML:
+		return writeDocument(out, newResultDocument(result), format)
+	case outputTable:
+		writeTable(out, result.Output)
+	default:
+		fmt.Fprintln(out, "Output:")
+		for _, obj := range result.Output {
+			fmt.Fprintln(out, formatObject(obj))
+		}
+	}
+
+	for _, s := range []struct {
+		title   string
+		objects []interface{}
+	}{
+		{"Information", result.Information},
+		{"Warnings", result.Warnings},
+		{"Verbose", result.Verbose},
+		{"Debug", result.Debug},
+	} {
+		if len(s.objects) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "%s:\n", s.title)
+		for _, obj := range s.objects {
+			fmt.Fprintln(out, formatObject(obj))
+		}
+	}
+	if result.HadErrors {
+		fmt.Fprintln(errOut, "Errors:")
+		for _, obj := range result.Errors {
+			fmt.Fprintln(errOut, formatObject(obj))
+		}
+	}
+	return nil
+}
+
+// writeDocument writes v as indented JSON or as YAML.
+func writeDocument(out io.Writer, v interface{}, format string) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if format == outputYAML {
+		return writeYAML(out, data)
+	}
+	_, err = fmt.Fprintf(out, "%s\n", data)
+	return err
+}
+
+// resultDocument is the json and yaml form of a Result.
+type resultDocument struct {
+	Output      []interface{}   `json:"output"`
+	Errors      []errorDocument `json:"errors"`
+	Warnings    []string        `json:"warnings"`
+	Verbose     []string        `json:"verbose"`
+	Debug       []string        `json:"debug"`
+	Information []string        `json:"information"`
+	HadErrors   bool            `json:"hadErrors"`
+	ExitCode    *int            `json:"exitCode,omitempty"`
+}
+
+// errorDocument is the json and yaml form of an error record.
+type errorDocument struct {
+	Message  string `json:"message"`
+	ErrorID  string `json:"errorId,omitempty"`
+	Category string `json:"category,omitempty"`
+	Position string `json:"position,omitempty"`
+}
+
+func newResultDocument(result *client.Result) *resultDocument {
+	doc := &resultDocument{
+		Output:      make([]interface{}, 0, len(result.Output)),
+		Errors:      make([]errorDocument, 0, len(result.Errors)),
+		Warnings:    formatObjects(result.Warnings),
+		Verbose:     formatObjects(result.Verbose),
+		Debug:       formatObjects(result.Debug),
+		Information: make([]string, 0, len(result.Information)),
+		HadErrors:   result.HadErrors,
+		ExitCode:    result.ExitCode,
+	}
+	for _, obj := range result.Output {
+		doc.Output = append(doc.Output, client.JSONValue(obj))
+	}
+	for _, obj := range result.Errors {
+		rec, ok := powershell.ParseErrorRecord(obj)
+		if !ok {
+			doc.Errors = append(doc.Errors, errorDocument{Message: formatObject(obj)})
+			continue
+		}
+		doc.Errors = append(doc.Errors, errorDocument{
+			Message:  rec.Message,
+			ErrorID:  rec.FullyQualifiedErrorID,
+			Category: rec.CategoryInfo.Category,
+			Position: rec.PositionMessage,
+		})
+	}
+	for _, obj := range result.Information {
+		if rec, ok := powershell.ParseInformationRecord(obj); ok {
+			doc.Information = append(doc.Information, rec.Message())
+		} else {
+			doc.Information = append(doc.Information, formatObject(obj))
+		}
+	}
+	return doc
+}
+
+func formatObjects(objects []interface{}) []string {
+	out := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		out = append(out, formatObject(obj))
+	}
+	return out
+}
+
+// writeTable prints objects with properties as a table, one row per
+// object and one column per property, like Format-Table. Output with other
+// values is printed one value per line.
+func writeTable(out io.Writer, objects []interface{}) {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, obj := range objects {
+		pso, ok := obj.(*serialization.PSObject)
+		if !ok || pso.Value != nil || len(pso.Properties) == 0 {
+			for _, obj := range objects {
+				fmt.Fprintln(out, formatObject(obj))
+			}
+			return
+		}
+		for _, name := range slices.Sorted(maps.Keys(pso.Properties)) {
+			if !seen[name] {
+				seen[name] = true
+				columns = append(columns, name)
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	rule := make([]string, len(columns))
+	for i, name := range columns {
+		rule[i] = strings.Repeat("-", len(name))
+	}
+	fmt.Fprintln(tw, strings.Join(columns, "\t"))
+	fmt.Fprintln(tw, strings.Join(rule, "\t"))
+	for _, obj := range objects {
+		props := obj.(*serialization.PSObject).Properties
+		cells := make([]string, len(columns))
+		for i, name := range columns {
+			if v, ok := props[name]; ok && v != nil {
+				cells[i] = strings.Join(strings.Fields(formatObject(v)), " ")
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	_ = tw.Flush()
+}
+
+// writeYAML writes a JSON document as YAML, keeping the order of object
+// keys.
+func writeYAML(out io.Writer, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeOrdered(dec)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	writeYAMLValue(&b, v, 0)
+	_, err = io.WriteString(out, b.String())
+	return err
+}
+
+// orderedObject is a JSON object with its keys in document order.
+type orderedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+// decodeOrdered decodes the next JSON value: objects as *orderedObject,
+// arrays as []interface{}, numbers as json.Number.
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+	switch delim {
+	case '{':
+		obj := &orderedObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, key.(string))
+			obj.values = append(obj.values, v)
+		}
+		_, err = dec.Token()
+		return obj, err
+	default:
+		arr := []interface{}{}
+		for dec.More() {
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err = dec.Token()
+		return arr, err
+	}
+}
+
+// writeYAMLValue writes v as a YAML block at the given indent. The caller
+// has written the key or list marker it belongs to.
+func writeYAMLValue(b *strings.Builder, v interface{}, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch val := v.(type) {
+	case *orderedObject:
+		for i, key := range val.keys {
+			b.WriteString(pad + yamlScalar(key) + ":")
+			writeYAMLChild(b, val.values[i], indent+1)
+		}
+	case []interface{}:
+		for _, item := range val {
+			if obj, ok := item.(*orderedObject); ok && len(obj.keys) > 0 {
+				// The first key goes on the line of the list marker
+				var nested strings.Builder
+				writeYAMLValue(&nested, obj, indent+1)
+				b.WriteString(pad + "- " + strings.TrimPrefix(nested.String(), pad+"  "))
+				continue
+			}
+			b.WriteString(pad + "-")
+			writeYAMLChild(b, item, indent+1)
+		}
+	default:
+		b.WriteString(pad + yamlScalar(v) + "\n")
+	}
+}
+
+// writeYAMLChild writes the value of a key or list item: scalars and empty
+// collections on the same line, others as a nested block.
+func writeYAMLChild(b *strings.Builder, v interface{}, indent int) {
+	switch val := v.(type) {
+	case *orderedObject:
+		if len(val.keys) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+	case []interface{}:
+		if len(val) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+	default:
+		b.WriteString(" " + yamlScalar(v) + "\n")
+		return
+	}
+	b.WriteString("\n")
+	writeYAMLValue(b, v, indent)
+}
+
+// yamlScalar formats a JSON scalar for YAML, quoting strings that would
+// otherwise read as another type or break the syntax.
+func yamlScalar(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(val)
+	case json.Number:
+		return val.String()
+	case string:
+		if yamlPlain(val) {
+			return val
+		}
+		return strconv.Quote(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// yamlPlain reports whether s can be written unquoted.
+func yamlPlain(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`~") {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+		return false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return false
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return false
+		}
+	}
+	return !strings.Contains(s, ": ") && !strings.Contains(s, " #") && !strings.HasSuffix(s, ":")
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// profileSections are the values of a -config file by section; "" holds
+// the top-level values. Each key is a flag name; repeatable flags such as
+// tag may have several values.
+type profileSections map[string]map[string][]string
+
+// loadProfile reads a -config file and returns its top-level values
+// overridden by those of the named section, if any. The format follows the
+// extension: .toml, or .yaml and .yml. Both support flat keys, one level of
+// sections and lists:
+//
+//	# psrp.toml
+//	server = "web01.corp.example"
+//	tls = true
+//	tag = ["team=ops", "ticket=CHG-1234"]
+//
+//	[lab]
+//	server = "lab01"
+//	insecure = true
+//
+//	# psrp.yaml
+//	server: web01.corp.example
+//	tls: true
+//	tag:
+//	  - team=ops
+//	lab:
+//	  server: lab01
+func loadProfile(path, section string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sections profileSections
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		sections, err = parseTOMLProfile(string(data))
+	case ".yaml", ".yml":
+		sections, err = parseYAMLProfile(string(data))
+	default:
+		return nil, fmt.Errorf("%s: want a .toml, .yaml or .yml file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := maps.Clone(sections[""])
+	if section != "" {
+		overrides, ok := sections[section]
+		if !ok {
+			return nil, fmt.Errorf("%s: no profile %q", path, section)
+		}
+		maps.Copy(values, overrides)
+	}
+	return values, nil
+}
+
+// applyArguments sets the flags that the positional arguments of cmd stand
+// for, then the values of the -config file for flags still unset.
+func applyArguments(fs *flag.FlagSet, cmd *command, configFile, profileName string) error {
+	if cmd != nil {
+		values, err := cmd.preset(fs, fs.Args())
+		if err != nil {
+			return fmt.Errorf("%s: %w", cmd.name, err)
+		}
+		for _, name := range slices.Sorted(maps.Keys(values)) {
+			if err := fs.Set(name, values[name]); err != nil {
+				return fmt.Errorf("%s: %w", cmd.name, err)
+			}
+		}
+	}
+
+	if configFile == "" {
+		if profileName != "" {
+			return errors.New("-profile needs -config")
+		}
+		return nil
+	}
+	values, err := loadProfile(configFile, profileName)
+	if err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if name == "config" || name == "profile" {
+			return fmt.Errorf("%s: %s cannot be set in a profile", configFile, name)
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown flag %q", configFile, name)
+		}
+		if set[name] {
+			continue
+		}
+		for _, v := range values[name] {
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("%s: %s: %w", configFile, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseTOMLProfile parses the subset of TOML used by profiles: key = value
+// lines with strings, booleans, numbers and single-line arrays, under
+// optional [section] headers.
+func parseTOMLProfile(src string) (profileSections, error) {
+	sections := profileSections{"": {}}
+	section := ""
+	for n, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(stripComment(line, false))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", n+1)
+			}
+			name, err := profileScalar(strings.TrimSpace(line[1 : len(line)-1]))
+			if err != nil || name == "" {
+				return nil, fmt.Errorf("line %d: invalid section name", n+1)
+			}
+			section = name
+			if sections[section] == nil {
+				sections[section] = make(map[string][]string)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key = value", n+1)
+		}
+		name, err := profileScalar(strings.TrimSpace(key))
+		if err != nil || name == "" {
+			return nil, fmt.Errorf("line %d: invalid key", n+1)
+		}
+		values, err := profileValues(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		sections[section][name] = values
+	}
+	return sections, nil
+}
+
+// parseYAMLProfile parses the subset of YAML used by profiles: key: value
+// lines, block and flow lists, and sections as mappings one level deep.
+func parseYAMLProfile(src string) (profileSections, error) {
+	sections := profileSections{"": {}}
+	var (
+		section     string // section of indented keys, "" at top level
+		openKey     string // top-level key without a value: a section or a list
+		listSection string // section and key that "- item" lines belong to
+		listKey     string
+	)
+	for n, raw := range strings.Split(src, "\n") {
+		line := strings.TrimRight(stripComment(raw, true), " \t\r")
+		text := strings.TrimSpace(line)
+		if text == "" || text == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if strings.Contains(line[:indent], "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot indent YAML", n+1)
+		}
+
+		if text == "-" || strings.HasPrefix(text, "- ") {
+			if listKey == "" {
+				return nil, fmt.Errorf("line %d: list item without a key", n+1)
+			}
+			v, err := profileScalar(strings.TrimSpace(text[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			sections[listSection][listKey] = append(sections[listSection][listKey], v)
+			openKey = ""
+			continue
+		}
+
+		key, value, ok := cutYAMLKey(text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key: value", n+1)
+		}
+		name, err := profileScalar(key)
+		if err != nil || name == "" {
+			return nil, fmt.Errorf("line %d: invalid key", n+1)
+		}
+		if indent == 0 {
+			section, openKey, listKey = "", "", ""
+		} else {
+			if openKey != "" {
+				// The open top-level key is a section
+				section, openKey = openKey, ""
+				if sections[section] == nil {
+					sections[section] = make(map[string][]string)
+				}
+			}
+			if section == "" {
+				return nil, fmt.Errorf("line %d: unexpected indentation", n+1)
+			}
+		}
+
+		if value == "" {
+			listSection, listKey = section, name
+			if indent == 0 {
+				openKey = name
+			}
+			continue
+		}
+		listKey = ""
+		values, err := profileValues(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		sections[section][name] = values
+	}
+	return sections, nil
+}
+
+// cutYAMLKey splits "key: value" or "key:" at the colon after the key.
+func cutYAMLKey(text string) (key, value string, ok bool) {
+	if strings.HasSuffix(text, ":") && !strings.Contains(text[:len(text)-1], ": ") {
+		return strings.TrimSpace(text[:len(text)-1]), "", true
+	}
+	key, value, ok = strings.Cut(text, ": ")
+	return strings.TrimSpace(key), strings.TrimSpace(value), ok
+}
+
+// profileValues parses a scalar or a flow list such as ["a", "b"].
+func profileValues(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "[") {
+		v, err := profileScalar(value)
+		if err != nil {
+			return nil, err
+		}
+		return []string{v}, nil
+	}
+	if !strings.HasSuffix(value, "]") {
+		return nil, errors.New("unterminated list")
+	}
+	values := []string{}
+	for _, item := range splitList(value[1 : len(value)-1]) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		v, err := profileScalar(item)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// profileScalar returns the text of a quoted or bare scalar.
+func profileScalar(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		return v, nil
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'"):
+		return "", fmt.Errorf("unterminated string %s", s)
+	}
+	return s, nil
+}
+
+// splitList splits the items of a flow list at commas outside quotes.
+func splitList(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// stripComment removes a # comment outside quotes. In YAML a comment must
+// start the line or follow a space, so that values like a#b stay whole.
+func stripComment(line string, yaml bool) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (!yaml || i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/smnsjas/go-psrp/client"
+	"github.com/smnsjas/go-psrp/powershell"
+)
+
+// errInterrupted is returned by a lineReader when Ctrl+C abandons the line.
+var errInterrupted = errors.New("interrupted")
+
+// continuationPrompt is shown while a statement spans several lines.
+const continuationPrompt = ">> "
+
+// repl is an interactive session on the remote runspace, like
+// Enter-PSSession: it reads statements from the terminal, runs them one at a
+// time and streams their output.
+type repl struct {
+	psrp   *client.Client
+	server string
+	in     lineReader
+	out    io.Writer
+	errOut io.Writer
+
+	// hostWrites is set if Write-Host output arrives as host calls, so the
+	// copies in the information stream are not printed again.
+	hostWrites bool
+}
+
+// newREPL returns a session on psrp that reads from the terminal, or from
+// host's reader if stdin is not a terminal.
+func newREPL(psrp *client.Client, server string, host *terminalHost, hostWrites bool) *repl {
+	var in lineReader
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		in = newTTYReader(fd, os.Stdin, os.Stdout)
+	} else {
+		in = &plainReader{in: host.in, out: os.Stdout}
+	}
+	return &repl{
+		psrp:       psrp,
+		server:     server,
+		in:         in,
+		out:        os.Stdout,
+		errOut:     os.Stderr,
+		hostWrites: hostWrites,
+	}
+}
+
+// run reads and runs statements until exit, Exit-PSSession or end of input.
+func (r *repl) run(ctx context.Context) error {
+	_, _ = fmt.Fprintln(r.out, "Type 'exit' to end the session.")
+	for {
+		script, err := r.readStatement(ctx)
+		if errors.Is(err, io.EOF) {
+			_, _ = fmt.Fprintln(r.out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(strings.TrimSpace(script)) {
+		case "":
+			continue
+		case "exit", "exit-pssession":
+			return nil
+		}
+		r.execute(ctx, script)
+	}
+}
+
+// readStatement reads lines until they form a complete statement. An empty
+// line ends an incomplete one, as in the PowerShell console.
+func (r *repl) readStatement(ctx context.Context) (string, error) {
+	prompt := r.prompt(ctx)
+	var lines []string
+	for {
+		line, err := r.in.ReadLine(prompt)
+		if errors.Is(err, errInterrupted) {
+			lines = nil
+			prompt = r.prompt(ctx)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if len(lines) > 0 && strings.TrimSpace(line) == "" {
+			break
+		}
+		lines = append(lines, line)
+		if !incompleteInput(strings.Join(lines, "\n")) {
+			break
+		}
+		prompt = continuationPrompt
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// prompt returns the output of the remote prompt function, prefixed with the
+// server name.
+func (r *repl) prompt(ctx context.Context) string {
+	prompt := "PS> "
+	if result, err := r.psrp.Execute(ctx, "prompt"); err == nil && len(result.Output) > 0 {
+		if s := formatObject(result.Output[0]); s != "" {
+			prompt = s
+		}
+	}
+	if r.server != "" {
+		prompt = "[" + r.server + "]: " + prompt
+	}
+	return prompt
+}
+
+// execute runs script and prints its streams as they arrive. Ctrl+C stops
+// the command, not the session.
+func (r *repl) execute(ctx context.Context, script string) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	width := powershell.DefaultHostInfo.BufferSize.Width
+	if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 {
+		width = w
+	}
+	stream, err := r.psrp.ExecuteStreamWithOptions(ctx, script, client.ExecOptions{OutputWidth: width})
+	if err != nil {
+		_, _ = fmt.Fprintf(r.errOut, "Error: %v\n", err)
+		return
+	}
+
+	var stopped atomic.Bool
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case <-sigs:
+			stopped.Store(true)
+			stream.Cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	p := &replPrinter{out: r.out, errOut: r.errOut}
+	if fd := int(os.Stderr.Fd()); term.IsTerminal(fd) {
+		if w, _, err := term.GetSize(fd); err == nil && w > 0 {
+			p.progressWidth = w
+		}
+	}
+
+	objs := stream.Objects()
+	var wg sync.WaitGroup
+	drain := func(ch <-chan interface{}, print func(interface{})) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range ch {
+				print(v)
+			}
+		}()
+	}
+	drain(objs.Output, func(v interface{}) { p.line(r.out, formatObject(v)) })
+	drain(objs.Errors, func(v interface{}) { p.line(r.errOut, formatErrorRecord(v)) })
+	drain(objs.Warnings, func(v interface{}) { p.line(r.errOut, "WARNING: "+formatObject(v)) })
+	drain(objs.Verbose, func(v interface{}) { p.line(r.errOut, "VERBOSE: "+formatObject(v)) })
+	drain(objs.Debug, func(v interface{}) { p.line(r.errOut, "DEBUG: "+formatObject(v)) })
+	drain(objs.Progress, p.progress)
+	drain(objs.Information, func(v interface{}) {
+		rec, ok := powershell.ParseInformationRecord(v)
+		if !ok {
+			p.line(r.out, formatObject(v))
+			return
+		}
+		if r.hostWrites && hasTag(rec.Tags, "PSHOST") {
+			return
+		}
+		p.line(r.out, rec.Message())
+	})
+	wg.Wait()
+	p.clear()
+
+	if err := stream.Wait(); err != nil && !stopped.Load() {
+		_, _ = fmt.Fprintf(r.errOut, "Error: %v\n", err)
+	}
+}
+
+// hasTag reports whether tags contains tag.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatErrorRecord formats an object of the error stream the way the
+// PowerShell console does.
+func formatErrorRecord(v interface{}) string {
+	rec, ok := powershell.ParseErrorRecord(v)
+	if !ok {
+		return formatObject(v)
+	}
+	var b strings.Builder
+	if rec.PositionMessage != "" {
+		b.WriteString(rec.PositionMessage)
+		b.WriteString("\n")
+	}
+	b.WriteString(rec.Message)
+	if rec.CategoryInfo.Message != "" {
+		b.WriteString("\n    + CategoryInfo          : " + rec.CategoryInfo.Message)
+	}
+	if rec.FullyQualifiedErrorID != "" {
+		b.WriteString("\n    + FullyQualifiedErrorId : " + rec.FullyQualifiedErrorID)
+	}
+	return b.String()
+}
+
+// replPrinter writes the streams of a command to the terminal, keeping the
+// current progress on a line of its own below the output.
+type replPrinter struct {
+	mu     sync.Mutex
+	out    io.Writer
+	errOut io.Writer
+
+	// progressWidth is the width of the progress line; 0 hides progress.
+	progressWidth int
+	progressLine  string
+}
+
+// line prints text on w.
+func (p *replPrinter) line(w io.Writer, text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.eraseProgress()
+	_, _ = fmt.Fprintln(w, text)
+	if p.progressLine != "" {
+		_, _ = fmt.Fprint(p.errOut, p.progressLine)
+	}
+}
+
+// progress shows a progress record, or removes the line when the activity
+// completes.
+func (p *replPrinter) progress(v interface{}) {
+	rec, ok := powershell.ParseProgressRecord(v)
+	if !ok || p.progressWidth == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.eraseProgress()
+	p.progressLine = ""
+	if rec.Completed {
+		return
+	}
+	p.progressLine = truncateRunes(progressText(rec), p.progressWidth-1)
+	_, _ = fmt.Fprint(p.errOut, p.progressLine)
+}
+
+// clear removes the progress line.
+func (p *replPrinter) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.eraseProgress()
+	p.progressLine = ""
+}
+
+func (p *replPrinter) eraseProgress() {
+	if p.progressLine != "" {
+		_, _ = fmt.Fprint(p.errOut, "\r"+strings.Repeat(" ", utf8.RuneCountInString(p.progressLine))+"\r")
+	}
+}
+
+// progressText formats a progress record as one line, e.g.
+// "Copying: file.txt [40%] (12s remaining)".
+func progressText(rec *powershell.ProgressRecord) string {
+	text := rec.Activity
+	if rec.StatusDescription != "" {
+		text += ": " + rec.StatusDescription
+	}
+	if rec.PercentComplete >= 0 {
+		text += fmt.Sprintf(" [%d%%]", rec.PercentComplete)
+	}
+	if rec.SecondsRemaining > 0 {
+		text += fmt.Sprintf(" (%s remaining)", time.Duration(rec.SecondsRemaining)*time.Second)
+	}
+	return text
+}
+
+// truncateRunes shortens s to at most n runes.
+func truncateRunes(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// lineReader reads the lines of a statement.
+type lineReader interface {
+	// ReadLine shows prompt and reads a line. It returns errInterrupted
+	// if Ctrl+C abandons the line and io.EOF at the end of input.
+	ReadLine(prompt string) (string, error)
+}
+
+// plainReader reads lines from a pipe or file.
+type plainReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	_, _ = fmt.Fprint(r.out, prompt)
+	line, err := r.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ttyReader edits lines on a terminal, with history. The terminal is in raw
+// mode only while a line is read, so commands, Read-Host and Ctrl+C work as
+// usual in between.
+type ttyReader struct {
+	fd  int
+	in  *interruptReader
+	out io.Writer
+	t   *term.Terminal
+}
+
+func newTTYReader(fd int, in io.Reader, out io.Writer) *ttyReader {
+	r := &ttyReader{fd: fd, in: &interruptReader{r: in}, out: out}
+	r.t = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{r.in, out}, "")
+	return r
+}
+
+func (r *ttyReader) ReadLine(prompt string) (string, error) {
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = term.Restore(r.fd, state) }()
+
+	if w, h, err := term.GetSize(r.fd); err == nil {
+		_ = r.t.SetSize(w, h)
+	}
+	r.t.SetPrompt(prompt)
+	r.in.interrupted = false
+	line, err := r.t.ReadLine()
+	if errors.Is(err, io.EOF) && r.in.interrupted {
+		// The terminal keeps the abandoned line, so start a new one with
+		// the same history.
+		history := r.t.History
+		r.t = term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{r.in, r.out}, "")
+		r.t.History = history
+		_, _ = fmt.Fprint(r.out, "^C\r\n")
+		return "", errInterrupted
+	}
+	return line, err
+}
+
+// interruptReader notes whether Ctrl+C was read, which term.Terminal
+// reports as io.EOF like Ctrl+D.
+type interruptReader struct {
+	r           io.Reader
+	interrupted bool
+}
+
+func (r *interruptReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if bytes.IndexByte(p[:n], 3) >= 0 {
+		r.interrupted = true
+	}
+	return n, err
+}
+
+// incompleteInput reports whether src needs more lines to be a complete
+// statement: it has unclosed brackets, strings, here-strings or block
+// comments, or ends with a line continuation (`) or a pipe.
+func incompleteInput(src string) bool {
+	var (
+		depth int
+		last  rune // last rune outside strings and comments
+	)
+	for i := 0; i < len(src); {
+		c, size := utf8.DecodeRuneInString(src[i:])
+		rest := src[i+size:]
+		switch {
+		case c == '`':
+			if rest == "" {
+				return true
+			}
+			_, n := utf8.DecodeRuneInString(rest)
+			i += size + n
+			last = 'x'
+			continue
+		case c == '<' && strings.HasPrefix(rest, "#"):
+			end := strings.Index(rest[1:], "#>")
+			if end < 0 {
+				return true
+			}
+			i += size + 1 + end + 2
+			continue
+		case c == '#' && isTokenBoundary(src[:i]):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += size + end
+			}
+			continue
+		case c == '@' && (strings.HasPrefix(rest, "'\n") || strings.HasPrefix(rest, "\"\n") ||
+			strings.HasPrefix(rest, "'\r\n") || strings.HasPrefix(rest, "\"\r\n")):
+			terminator := "\n" + rest[:1] + "@"
+			end := strings.Index(rest, terminator)
+			if end < 0 {
+				return true
+			}
+			i += size + end + len(terminator)
+			last = 'x'
+			continue
+		case c == '\'' || c == '"':
+			end := stringEnd(rest, byte(c))
+			if end < 0 {
+				return true
+			}
+			i += size + end + 1
+			last = 'x'
+			continue
+		case c == '(' || c == '{' || c == '[':
+			depth++
+		case c == ')' || c == '}' || c == ']':
+			depth--
+		}
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			last = c
+		}
+		i += size
+	}
+	return depth > 0 || last == '|'
+}
+
+// isTokenBoundary reports whether a token starts after before, so that a #
+// there starts a comment rather than being part of a word such as a#b.
+func isTokenBoundary(before string) bool {
+	if before == "" {
+		return true
+	}
+	c, _ := utf8.DecodeLastRuneInString(before)
+	return strings.ContainsRune(" \t\r\n;(){}|&", c)
+}
+
+// stringEnd returns the index of the quote that closes a string of the
+// given quote character in s, or -1. Doubled quotes are escapes, and so is
+// a backtick in double-quoted strings.
+func stringEnd(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '`':
+			i++
+		case s[i] == quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
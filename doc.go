@@ -85,6 +85,13 @@
 //	    log.Printf("Command failed: %v", err)
 //	}
 //
+// The channels carry raw PSRP messages. Objects returns the same streams as
+// deserialized values, delivered as each Receive response arrives:
+//
+//	for obj := range stream.Objects().Output {
+//	    fmt.Println(obj)
+//	}
+//
 // # Resilience: Reconnect
 //
 // You can disconnect a session without closing it on the server (saving state),
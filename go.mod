@@ -11,16 +11,23 @@ require (
 	github.com/Microsoft/go-winio v0.6.2
 	github.com/alexbrainman/sspi v0.0.0-20250919150558-7d374ff0d59e
 	github.com/go-krb5/krb5 v0.0.0-20251226122733-d0288459fc25
+	github.com/go-krb5/x v0.3.0
 	github.com/smnsjas/go-ntlm-cbt v0.0.0-20260107203125-46149984fac0
 	github.com/smnsjas/go-psrpcore v0.0.0-20260129221240-693b4b10e7ba
+	go.opentelemetry.io/otel v1.44.0
+	go.opentelemetry.io/otel/metric v1.44.0
+	go.opentelemetry.io/otel/trace v1.44.0
+	golang.org/x/crypto v0.46.0
 	golang.org/x/sync v0.19.0
 	golang.org/x/term v0.38.0
 )
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/go-crypt/x v0.4.10 // indirect
-	github.com/go-krb5/x v0.3.0 // indirect
-	golang.org/x/crypto v0.46.0 // indirect
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
 	golang.org/x/net v0.48.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
 )
@@ -8,9 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
-	"os"
 	"strings"
 	"time"
 	"unicode/utf16"
@@ -36,37 +34,36 @@ const (
 	maxTokenResponseLen = 16 * 1024
 )
 
-// Verbose enables debug logging
-var Verbose = os.Getenv("PSRP_DEBUG") != ""
-
-func debugf(format string, args ...interface{}) {
-	if Verbose {
-		log.Printf("[hvsock] "+format, args...)
-	}
-}
-
 // dialServiceWithTimeout connects to a specific HvSocket service with a timeout
-func dialServiceWithTimeout(ctx context.Context, vmID, serviceID uuid.UUID, timeout time.Duration) (net.Conn, error) {
+func dialServiceWithTimeout(ctx context.Context, l handshakeLogger, vmID, serviceID uuid.UUID, timeout time.Duration) (net.Conn, error) {
 	dialCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	return DialService(dialCtx, vmID, serviceID)
+	l.debugf("Dialing HvSocket: VM=%s Service=%s", vmID, serviceID)
+	conn, err := DialService(dialCtx, vmID, serviceID)
+	if err != nil {
+		l.debugf("Dial failed: %v", err)
+		return nil, err
+	}
+	l.debugf("Dial succeeded")
+	return conn, nil
 }
 
 // ConnectAndAuthenticate performs the full two-stage PowerShell Direct connection:
 // 1. Connect to broker, exchange credentials, get token
 // 2. Connect to PS process, exchange token
 // Returns the final connection ready for PSRP protocol.
-func ConnectAndAuthenticate(ctx context.Context, vmID uuid.UUID, domain, user, pass, configName string) (net.Conn, error) {
-	debugf("=== Stage 1: Connecting to Broker ===")
+func ConnectAndAuthenticate(ctx context.Context, vmID uuid.UUID, domain, user, pass, configName string, opts ...Option) (net.Conn, error) {
+	l := newHandshakeLogger(opts)
+	l.debugf("=== Stage 1: Connecting to Broker ===")
 
 	// Stage 1: Connect to broker service (vmicvmsession)
-	brokerConn, err := dialServiceWithTimeout(ctx, vmID, PsrpBrokerServiceID, defaultAuthTimeout)
+	brokerConn, err := dialServiceWithTimeout(ctx, l, vmID, PsrpBrokerServiceID, defaultAuthTimeout)
 	if err != nil {
 		return nil, fmt.Errorf("dial broker: %w", err)
 	}
 
 	// Authenticate with broker and get token
-	token, err := authenticateWithBroker(brokerConn, domain, user, pass, configName)
+	token, err := authenticateWithBroker(l, brokerConn, domain, user, pass, configName)
 	if err != nil {
 		brokerConn.Close()
 		return nil, fmt.Errorf("broker auth: %w", err)
@@ -74,7 +71,7 @@ func ConnectAndAuthenticate(ctx context.Context, vmID uuid.UUID, domain, user, p
 
 	// Close broker connection - we need to make a new connection to the PS process
 	brokerConn.Close()
-	debugf("Broker connection closed. Token: [REDACTED len=%d]", len(token))
+	l.debugf("Broker connection closed. Token: [REDACTED len=%d]", len(token))
 
 	if token == "" {
 		return nil, fmt.Errorf("no token received from broker (legacy mode not supported)")
@@ -83,7 +80,7 @@ func ConnectAndAuthenticate(ctx context.Context, vmID uuid.UUID, domain, user, p
 	// Stage 2: Retry connection to PS process
 	// The vmicvmsession service needs to spawn pwsh.exe and it needs to bind to the socket.
 	// This can take varying amounts of time depending on VM load.
-	debugf("=== Stage 2: Connecting to PowerShell Process ===")
+	l.debugf("=== Stage 2: Connecting to PowerShell Process ===")
 
 	const (
 		maxRetries   = 10
@@ -102,18 +99,18 @@ func ConnectAndAuthenticate(ctx context.Context, vmID uuid.UUID, domain, user, p
 			time.Sleep(500 * time.Millisecond)
 		}
 
-		debugf("Stage 2 attempt %d/%d", attempt, maxRetries)
+		l.debugf("Stage 2 attempt %d/%d", attempt, maxRetries)
 
-		psConn, lastErr = dialServiceWithTimeout(ctx, vmID, PsrpServerServiceID, dialTimeout)
+		psConn, lastErr = dialServiceWithTimeout(ctx, l, vmID, PsrpServerServiceID, dialTimeout)
 		if lastErr == nil {
-			debugf("Stage 2 connection succeeded on attempt %d", attempt)
+			l.debugf("Stage 2 connection succeeded on attempt %d", attempt)
 			break
 		}
 
-		debugf("Stage 2 attempt %d failed: %v", attempt, lastErr)
+		l.debugf("Stage 2 attempt %d failed: %v", attempt, lastErr)
 
 		if attempt < maxRetries {
-			debugf("Waiting %v before retry...", delay)
+			l.debugf("Waiting %v before retry...", delay)
 			select {
 			case <-time.After(delay):
 			case <-ctx.Done():
@@ -133,100 +130,100 @@ func ConnectAndAuthenticate(ctx context.Context, vmID uuid.UUID, domain, user, p
 	}
 
 	// Authenticate with PS process using the token
-	if err := authenticateWithToken(psConn, token); err != nil {
+	if err := authenticateWithToken(l, psConn, token); err != nil {
 		psConn.Close()
 		return nil, fmt.Errorf("ps auth: %w", err)
 	}
 
-	debugf("=== Both stages complete - connection ready for PSRP ===")
+	l.debugf("=== Both stages complete - connection ready for PSRP ===")
 	return psConn, nil
 }
 
 // authenticateWithBroker handles the first-stage authentication (credential exchange)
 // Returns the authentication token for the second stage
-func authenticateWithBroker(conn net.Conn, domain, user, pass, configName string) (string, error) {
+func authenticateWithBroker(l handshakeLogger, conn net.Conn, domain, user, pass, configName string) (string, error) {
 	// Default domain to localhost if empty
 	if domain == "" || domain == "." {
 		domain = "localhost"
 	}
 
-	debugf("Starting broker authentication: domain=%q, user=[REDACTED], configName=%q", domain, configName)
+	l.debugf("Starting broker authentication: domain=%q, user=[REDACTED], configName=%q", domain, configName)
 
 	// Step 1: Version Exchange
-	debugf("Sending VERSION request...")
+	l.debugf("Sending VERSION request...")
 	if _, err := conn.Write([]byte(versionRequest)); err != nil {
 		return "", fmt.Errorf("send version request: %w", err)
 	}
 
-	versionResp, err := receiveASCII(conn, 16)
+	versionResp, err := receiveASCII(l, conn, 16)
 	if err != nil {
 		return "", fmt.Errorf("read version response: %w", err)
 	}
-	debugf("Received version response: %q", versionResp)
+	l.debugf("Received version response: %q", versionResp)
 
 	if !strings.HasPrefix(versionResp, versionPrefix) {
 		return "", fmt.Errorf("server uses legacy protocol (got %q), VERSION_2+ required", versionResp)
 	}
 
-	debugf("Sending client version: %q", clientVersion)
+	l.debugf("Sending client version: %q", clientVersion)
 	if _, err := conn.Write([]byte(clientVersion)); err != nil {
 		return "", fmt.Errorf("send client version: %w", err)
 	}
 
-	ack, err := receiveASCII(conn, 4)
+	ack, err := receiveASCII(l, conn, 4)
 	if err != nil {
 		return "", fmt.Errorf("read version ack: %w", err)
 	}
-	debugf("Received version ack: %q", ack)
+	l.debugf("Received version ack: %q", ack)
 	if ack != "PASS" {
 		return "", fmt.Errorf("version negotiation failed: %s", ack)
 	}
 
 	// Step 2: Send Domain
-	debugf("Sending domain: %q", domain)
+	l.debugf("Sending domain: %q", domain)
 	if _, err := conn.Write(encodeUTF16LE(domain)); err != nil {
 		return "", fmt.Errorf("send domain: %w", err)
 	}
-	if _, err := receiveASCII(conn, 4); err != nil {
+	if _, err := receiveASCII(l, conn, 4); err != nil {
 		return "", fmt.Errorf("read domain ack: %w", err)
 	}
 
 	// Step 3: Send Username
-	debugf("Sending username: %q", user)
+	l.debugf("Sending username: %q", user)
 	if _, err := conn.Write(encodeUTF16LE(user)); err != nil {
 		return "", fmt.Errorf("send user: %w", err)
 	}
-	if _, err := receiveASCII(conn, 4); err != nil {
+	if _, err := receiveASCII(l, conn, 4); err != nil {
 		return "", fmt.Errorf("read user ack: %w", err)
 	}
 
 	// Step 4: Send Password
 	emptyPassword := pass == ""
 	if emptyPassword {
-		debugf("Sending EMPTYPW")
+		l.debugf("Sending EMPTYPW")
 		if _, err := conn.Write([]byte("EMPTYPW")); err != nil {
 			return "", fmt.Errorf("send emptypw: %w", err)
 		}
 	} else {
-		debugf("Sending NONEMPTYPW")
+		l.debugf("Sending NONEMPTYPW")
 		if _, err := conn.Write([]byte("NONEMPTYPW")); err != nil {
 			return "", fmt.Errorf("send nonemptypw: %w", err)
 		}
-		if _, err := receiveASCII(conn, 4); err != nil {
+		if _, err := receiveASCII(l, conn, 4); err != nil {
 			return "", fmt.Errorf("read password prompt ack: %w", err)
 		}
-		debugf("Sending password")
+		l.debugf("Sending password")
 		if _, err := conn.Write(encodeUTF16LE(pass)); err != nil {
 			return "", fmt.Errorf("send password: %w", err)
 		}
 	}
 
 	// Step 5: Receive credential response
-	credResp, err := receiveASCII(conn, 4)
+	credResp, err := receiveASCII(l, conn, 4)
 	if err != nil {
 		return "", fmt.Errorf("read credential response: %w", err)
 	}
-	debugf("Received credential response: %q", credResp)
+	l.debugf("Received credential response: %q", credResp)
 
 	switch credResp {
 	case "FAIL":
@@ -241,16 +238,16 @@ func authenticateWithBroker(conn net.Conn, domain, user, pass, configName string
 	case "CONF":
 		emptyConfig := configName == ""
 		if emptyConfig {
-			debugf("Sending EMPTYCF")
+			l.debugf("Sending EMPTYCF")
 			if _, err := conn.Write([]byte("EMPTYCF")); err != nil {
 				return "", fmt.Errorf("send emptycf: %w", err)
 			}
 		} else {
-			debugf("Sending NONEMPTYCF")
+			l.debugf("Sending NONEMPTYCF")
 			if _, err := conn.Write([]byte("NONEMPTYCF")); err != nil {
 				return "", fmt.Errorf("send nonemptycf: %w", err)
 			}
-			if _, err := receiveASCII(conn, 4); err != nil {
+			if _, err := receiveASCII(l, conn, 4); err != nil {
 				return "", fmt.Errorf("read config prompt ack: %w", err)
 			}
 			if _, err := conn.Write(encodeUTF16LE(configName)); err != nil {
@@ -259,11 +256,11 @@ func authenticateWithBroker(conn net.Conn, domain, user, pass, configName string
 		}
 
 		// Receive token
-		tokenResp, err := receiveASCIIWithTimeout(conn, maxTokenResponseLen, defaultAuthTimeout)
+		tokenResp, err := receiveASCIIWithTimeout(l, conn, maxTokenResponseLen, defaultAuthTimeout)
 		if err != nil {
 			return "", fmt.Errorf("read token: %w", err)
 		}
-			debugf("Received token response: [REDACTED len=%d]", len(tokenResp))
+		l.debugf("Received token response: [REDACTED len=%d]", len(tokenResp))
 
 		if !strings.HasPrefix(tokenResp, "TOKEN ") {
 			return "", fmt.Errorf("expected token, got: %q", tokenResp)
@@ -271,10 +268,10 @@ func authenticateWithBroker(conn net.Conn, domain, user, pass, configName string
 
 		token := strings.TrimPrefix(tokenResp, "TOKEN ")
 		token = strings.TrimSpace(token)
-		debugf("Parsed token length: %d", len(token))
+		l.debugf("Parsed token length: %d", len(token))
 
 		// Acknowledge token
-		debugf("Sending token ack (PASS)")
+		l.debugf("Sending token ack (PASS)")
 		if _, err := conn.Write([]byte("PASS")); err != nil {
 			return "", fmt.Errorf("send token ack: %w", err)
 		}
@@ -287,85 +284,86 @@ func authenticateWithBroker(conn net.Conn, domain, user, pass, configName string
 }
 
 // authenticateWithToken handles the second-stage authentication (token exchange)
-func authenticateWithToken(conn net.Conn, token string) error {
-	debugf("Starting token authentication with token: [REDACTED len=%d]", len(token))
+func authenticateWithToken(l handshakeLogger, conn net.Conn, token string) error {
+	l.debugf("Starting token authentication with token: [REDACTED len=%d]", len(token))
 
 	// Step 1: Version Exchange
-	debugf("Sending VERSION request...")
+	l.debugf("Sending VERSION request...")
 	if _, err := conn.Write([]byte(versionRequest)); err != nil {
 		return fmt.Errorf("send version request: %w", err)
 	}
 
-	versionResp, err := receiveASCII(conn, 16)
+	versionResp, err := receiveASCII(l, conn, 16)
 	if err != nil {
 		return fmt.Errorf("read version response: %w", err)
 	}
-	debugf("Received version response: %q", versionResp)
+	l.debugf("Received version response: %q", versionResp)
 
 	if !strings.HasPrefix(versionResp, versionPrefix) {
 		return fmt.Errorf("server uses legacy protocol (got %q)", versionResp)
 	}
 
-	debugf("Sending client version: %q", clientVersion)
+	l.debugf("Sending client version: %q", clientVersion)
 	if _, err := conn.Write([]byte(clientVersion)); err != nil {
 		return fmt.Errorf("send client version: %w", err)
 	}
 
-	ack, err := receiveASCII(conn, 4)
+	ack, err := receiveASCII(l, conn, 4)
 	if err != nil {
 		return fmt.Errorf("read version ack: %w", err)
 	}
-	debugf("Received version ack: %q", ack)
+	l.debugf("Received version ack: %q", ack)
 	if ack != "PASS" {
 		return fmt.Errorf("version negotiation failed: %s", ack)
 	}
 
 	// Step 2: Send token
 	tokenMsg := "TOKEN " + token
-	debugf("Sending token: [REDACTED]")
+	l.debugf("Sending token: [REDACTED]")
 	if _, err := conn.Write([]byte(tokenMsg)); err != nil {
 		return fmt.Errorf("send token: %w", err)
 	}
 
 	// Receive token response
-	tokenResp, err := receiveASCII(conn, 256)
+	tokenResp, err := receiveASCII(l, conn, 256)
 	if err != nil {
 		return fmt.Errorf("read token response: %w", err)
 	}
-	debugf("Received token response: [REDACTED len=%d]", len(tokenResp))
+	l.debugf("Received token response: [REDACTED len=%d]", len(tokenResp))
 
 	if tokenResp != "PASS" {
 		return fmt.Errorf("token authentication failed: %s", tokenResp)
 	}
 
-	debugf("Token authentication successful!")
+	l.debugf("Token authentication successful!")
 	return nil
 }
 
 // Authenticate is the legacy single-stage function. For new code, use ConnectAndAuthenticate.
 // This continues to work for the current single-connection approach during testing.
-func Authenticate(conn net.Conn, domain, user, pass, configName string) error {
-	token, err := authenticateWithBroker(conn, domain, user, pass, configName)
+func Authenticate(conn net.Conn, domain, user, pass, configName string, opts ...Option) error {
+	l := newHandshakeLogger(opts)
+	token, err := authenticateWithBroker(l, conn, domain, user, pass, configName)
 	if err != nil {
 		return err
 	}
 	// If we got a token, we're in VERSION_2 mode and need a second connection
 	// But for now, we'll continue on the same connection (which won't work but allows testing)
 	if token != "" {
-		debugf("NOTE: Got token but continuing on same connection (this won't work for real use)")
+		l.debugf("NOTE: Got token but continuing on same connection (this won't work for real use)")
 	}
 	return nil
 }
 
 // receiveASCII reads up to maxLen bytes and returns as ASCII string with default timeout
-func receiveASCII(conn net.Conn, maxLen int) (string, error) {
-	return receiveASCIIWithTimeout(conn, maxLen, defaultAuthTimeout)
+func receiveASCII(l handshakeLogger, conn net.Conn, maxLen int) (string, error) {
+	return receiveASCIIWithTimeout(l, conn, maxLen, defaultAuthTimeout)
 }
 
 // receiveASCIIWithTimeout reads up to maxLen bytes with specified timeout
-func receiveASCIIWithTimeout(conn net.Conn, maxLen int, timeout time.Duration) (string, error) {
+func receiveASCIIWithTimeout(l handshakeLogger, conn net.Conn, maxLen int, timeout time.Duration) (string, error) {
 	buf := make([]byte, maxLen)
-	debugf("  receiveASCII: waiting for up to %d bytes (timeout: %v)...", maxLen, timeout)
+	l.debugf("  receiveASCII: waiting for up to %d bytes (timeout: %v)...", maxLen, timeout)
 
 	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
 		return "", fmt.Errorf("set read deadline: %w", err)
@@ -375,19 +373,19 @@ func receiveASCIIWithTimeout(conn net.Conn, maxLen int, timeout time.Duration) (
 	n, err := conn.Read(buf)
 	if err != nil {
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			debugf("  receiveASCII: timeout after %v", timeout)
+			l.debugf("  receiveASCII: timeout after %v", timeout)
 			return "", fmt.Errorf("read timeout: server did not respond within %v", timeout)
 		}
-		debugf("  receiveASCII: error: %v", err)
+		l.debugf("  receiveASCII: error: %v", err)
 		return "", err
 	}
 
 	if n == 0 {
-		debugf("  receiveASCII: got 0 bytes (EOF)")
+		l.debugf("  receiveASCII: got 0 bytes (EOF)")
 		return "", io.EOF
 	}
 
-	debugf("  receiveASCII: got %d bytes: %x", n, buf[:n])
+	l.debugf("  receiveASCII: got %d bytes: %x", n, buf[:n])
 	result := string(buf[:n])
 	result = strings.TrimRight(result, "\x00")
 	return result, nil
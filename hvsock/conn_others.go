@@ -28,6 +28,6 @@ func DialService(_ context.Context, _, _ uuid.UUID) (net.Conn, error) {
 }
 
 // ConnectAndAuthenticate is a stub for non-Windows platforms.
-func ConnectAndAuthenticate(_ context.Context, _ uuid.UUID, _, _, _, _ string) (net.Conn, error) {
+func ConnectAndAuthenticate(_ context.Context, _ uuid.UUID, _, _, _, _ string, _ ...Option) (net.Conn, error) {
 	return nil, ErrNotSupported
 }
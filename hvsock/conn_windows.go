@@ -6,8 +6,6 @@ import (
 	"context"
 	"net"
 
-	"github.com/Microsoft/go-winio"
-	"github.com/Microsoft/go-winio/pkg/guid"
 	"github.com/google/uuid"
 )
 
@@ -19,29 +17,10 @@ func Dial(ctx context.Context, vmID uuid.UUID) (net.Conn, error) {
 
 // DialService connects to a specific Hyper-V socket service on the specified VM.
 func DialService(ctx context.Context, vmID, serviceID uuid.UUID) (net.Conn, error) {
-	vmGuid := uuidToGUID(vmID)
-	svcGuid := uuidToGUID(serviceID)
-
-	addr := &winio.HvsockAddr{
-		VMID:      vmGuid,
-		ServiceID: svcGuid,
-	}
-
-	debugf("Dialing HvSocket: VM=%s Service=%s", vmID, serviceID)
-	conn, err := winio.Dial(ctx, addr)
-	if err != nil {
-		debugf("Dial failed: %v", err)
-		return nil, err
+	dial := serviceDialer()
+	if dial == nil {
+		return nil, ErrNoDialer
 	}
-	debugf("Dial succeeded")
-	return conn, nil
-}
 
-// uuidToGUID converts a google/uuid.UUID to go-winio's guid.GUID.
-// uuid.UUID is stored as big-endian bytes per RFC 4122.
-// go-winio's guid.FromArray expects big-endian bytes and handles conversion.
-func uuidToGUID(u uuid.UUID) guid.GUID {
-	var arr [16]byte
-	copy(arr[:], u[:])
-	return guid.FromArray(arr)
+	return dial(ctx, vmID, serviceID)
 }
@@ -0,0 +1,34 @@
+//go:build windows && !psrp_nowinio
+
+package hvsock
+
+import (
+	"context"
+	"net"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/Microsoft/go-winio/pkg/guid"
+	"github.com/google/uuid"
+)
+
+func init() {
+	builtinDialer = dialWinio
+}
+
+// dialWinio connects to a Hyper-V socket service with go-winio.
+func dialWinio(ctx context.Context, vmID, serviceID uuid.UUID) (net.Conn, error) {
+	addr := &winio.HvsockAddr{
+		VMID:      uuidToGUID(vmID),
+		ServiceID: uuidToGUID(serviceID),
+	}
+	return winio.Dial(ctx, addr)
+}
+
+// uuidToGUID converts a google/uuid.UUID to go-winio's guid.GUID.
+// uuid.UUID is stored as big-endian bytes per RFC 4122.
+// go-winio's guid.FromArray expects big-endian bytes and handles conversion.
+func uuidToGUID(u uuid.UUID) guid.GUID {
+	var arr [16]byte
+	copy(arr[:], u[:])
+	return guid.FromArray(arr)
+}
@@ -0,0 +1,46 @@
+package hvsock
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// ServiceDialer connects to a Hyper-V socket service of a VM.
+type ServiceDialer func(ctx context.Context, vmID, serviceID uuid.UUID) (net.Conn, error)
+
+// ErrNoDialer is returned by DialService when the program was built with
+// the psrp_nowinio tag and no dialer was registered with RegisterDialer.
+var ErrNoDialer = errors.New("hvsock: no dialer (built with psrp_nowinio and no dialer registered)")
+
+var (
+	dialerMu sync.RWMutex
+	// registeredDialer is set by RegisterDialer.
+	registeredDialer ServiceDialer
+	// builtinDialer uses go-winio, unless built with psrp_nowinio.
+	builtinDialer ServiceDialer
+)
+
+// RegisterDialer makes d the dialer of DialService, in place of go-winio.
+// It lets programs built with psrp_nowinio plug in another
+// implementation. A nil d restores the built-in dialer. Hyper-V sockets
+// are only supported on Windows.
+func RegisterDialer(d ServiceDialer) {
+	dialerMu.Lock()
+	defer dialerMu.Unlock()
+	registeredDialer = d
+}
+
+// serviceDialer returns the registered dialer, else the built-in one, or
+// nil.
+func serviceDialer() ServiceDialer {
+	dialerMu.RLock()
+	defer dialerMu.RUnlock()
+	if registeredDialer != nil {
+		return registeredDialer
+	}
+	return builtinDialer
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package hvsock
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRegisterDialer(t *testing.T) {
+	defer RegisterDialer(nil)
+
+	vmID := uuid.New()
+	client, server := net.Pipe()
+	defer server.Close()
+	var gotVM, gotService uuid.UUID
+	RegisterDialer(func(_ context.Context, vm, service uuid.UUID) (net.Conn, error) {
+		gotVM, gotService = vm, service
+		return client, nil
+	})
+
+	conn, err := Dial(context.Background(), vmID)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	if conn != client || gotVM != vmID || gotService != PsrpBrokerServiceID {
+		t.Errorf("Dial() used %s/%s, want the registered dialer with the broker service", gotVM, gotService)
+	}
+}
@@ -0,0 +1,54 @@
+package hvsock
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Verbose enables debug logging to stderr for connections without a
+// logger (see WithLogger). Clients pass their logger, which PSRP_DEBUG
+// and PSRP_LOG_LEVEL configure, so this is only for direct use.
+var Verbose bool
+
+// Option configures ConnectAndAuthenticate and Authenticate.
+type Option func(*options)
+
+type options struct {
+	logger *slog.Logger
+}
+
+// WithLogger sets the logger that receives the steps of the handshake at
+// debug level. Secrets are never logged. Default: slog.Default(), or a
+// debug logger on stderr if Verbose is set.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// handshakeLogger logs the steps of a handshake.
+type handshakeLogger struct {
+	*slog.Logger
+}
+
+// newHandshakeLogger returns the logger configured by opts.
+func newHandshakeLogger(opts []Option) handshakeLogger {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	switch {
+	case o.logger != nil:
+		return handshakeLogger{o.logger}
+	case Verbose:
+		return handshakeLogger{slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+	default:
+		return handshakeLogger{slog.Default()}
+	}
+}
+
+// debugf logs a formatted debug message.
+func (l handshakeLogger) debugf(format string, args ...interface{}) {
+	l.Debug(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,95 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrp/client"
+)
+
+// connectTestServer connects to PSRP_SERVER as TestStreams does.
+func connectTestServer(ctx context.Context, t *testing.T) *client.Client {
+	t.Helper()
+	host := os.Getenv("PSRP_SERVER")
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	c, err := client.New(host, client.Config{
+		Port:               5985,
+		InsecureSkipVerify: true,
+		Timeout:            30 * time.Second,
+		AuthType:           client.AuthNTLM,
+		Username:           "testuser",
+		Password:           "REDACTED_PASSWORD",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		c.Close(closeCtx)
+	})
+	return c
+}
+
+// TestExecute_UsingNamespace runs a script that must start with its using
+// statement, with and without ReportExitCode.
+func TestExecute_UsingNamespace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	c := connectTestServer(ctx, t)
+
+	script := "using namespace System.IO\n[Path]::GetFileName('C:\\temp\\a.txt')"
+	for _, opts := range []client.ExecOptions{{}, {ReportExitCode: true}} {
+		result, err := c.ExecuteWithOptions(ctx, script, opts)
+		if err != nil {
+			t.Fatalf("ExecuteWithOptions(%+v) error = %v", opts, err)
+		}
+		if len(result.Output) != 1 || result.Output[0] != "a.txt" {
+			t.Errorf("ExecuteWithOptions(%+v) output = %v, want [a.txt]", opts, result.Output)
+		}
+		if !result.Succeeded() {
+			t.Errorf("ExecuteWithOptions(%+v) did not succeed: %+v", opts, result)
+		}
+	}
+}
+
+// TestExecute_TerminatingError checks the error a real pipeline fails with.
+func TestExecute_TerminatingError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	c := connectTestServer(ctx, t)
+
+	result, err := c.Execute(ctx, "'before'\nthrow 'boom'")
+	var rec *client.ErrorRecord
+	if !errors.As(err, &rec) {
+		t.Fatalf("Execute() error = %v, want an *ErrorRecord", err)
+	}
+	if rec.Message != "boom" || rec.FullyQualifiedErrorID != "boom" {
+		t.Errorf("TerminatingError = %+v", rec)
+	}
+	if result == nil || len(result.Output) != 1 || result.Output[0] != "before" {
+		t.Errorf("result = %+v, want the output before the error", result)
+	}
+
+	// The session is left as it was: no wrapper variables, $LASTEXITCODE kept
+	if _, err := c.Execute(ctx, "$global:LASTEXITCODE = 7"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	result, err = c.Execute(ctx, "[string]$global:LASTEXITCODE; [bool](Get-Variable __psrp* -ErrorAction SilentlyContinue)")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(result.Output) != 2 || result.Output[0] != "7" || result.Output[1] != false {
+		t.Errorf("session state = %v, want [7 false]", result.Output)
+	}
+}
@@ -57,7 +57,7 @@ func (a *Adapter) Write(p []byte) (int, error) {
 	ctx := a.ctx
 	a.mu.Unlock()
 
-	err := a.client.Send(ctx, a.epr, a.commandID, "stdin", p)
+	err := a.client.Send(ctx, a.epr, a.commandID, wsman.StreamStdin, p)
 	if err != nil {
 		return 0, err
 	}
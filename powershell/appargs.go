@@ -0,0 +1,182 @@
+package powershell
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/messages"
+)
+
+// nilApplicationArguments matches an empty ApplicationArguments property.
+var nilApplicationArguments = regexp.MustCompile(`<Nil N="ApplicationArguments"\s*/>`)
+
+// withApplicationArguments sets the ApplicationArguments of the
+// INIT_RUNSPACEPOOL message in handshake fragments. The server exposes them
+// to the session as $PSSenderInfo.ApplicationArguments.
+func withApplicationArguments(data []byte, args map[string]interface{}) ([]byte, error) {
+	dictXML, err := applicationArgumentsXML(args)
+	if err != nil {
+		return nil, err
+	}
+	out, found, err := rewriteMessages(data, func(msg *messages.Message) bool {
+		if msg.Type != messages.MessageTypeInitRunspacePool {
+			return false
+		}
+		dict := []byte(dictXML)
+		if loc := nilApplicationArguments.FindIndex(msg.Data); loc != nil {
+			msg.Data = append(append(append([]byte{}, msg.Data[:loc[0]]...), dict...), msg.Data[loc[1]:]...)
+			return true
+		}
+		// Property omitted: add it to the end of the message object
+		i := bytes.LastIndex(msg.Data, []byte("</MS>"))
+		if i < 0 {
+			return false
+		}
+		msg.Data = append(append(append([]byte{}, msg.Data[:i]...), dict...), msg.Data[i:]...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("powershell: handshake has no INIT_RUNSPACEPOOL message")
+	}
+	return out, nil
+}
+
+// ValidateApplicationArguments reports an error if args cannot be sent as a
+// PSPrimitiveDictionary. Values may be nil, strings, booleans, integers,
+// floats, time.Time, time.Duration, uuid.UUID, *url.URL, []byte, slices of
+// these, and nested map[string]interface{} or map[string]string.
+func ValidateApplicationArguments(args map[string]interface{}) error {
+	_, err := applicationArgumentsXML(args)
+	return err
+}
+
+// applicationArgumentsXML renders args as a PSPrimitiveDictionary. The RefIds
+// are chosen well above those go-psrpcore uses in the same message.
+func applicationArgumentsXML(args map[string]interface{}) (string, error) {
+	refs := 1000
+	return primitiveDictionaryXML("ApplicationArguments", args, "", &refs)
+}
+
+// primitiveDictionaryXML renders a PSPrimitiveDictionary; path names it in
+// errors.
+func primitiveDictionaryXML(name string, dict map[string]interface{}, path string, refs *int) (string, error) {
+	keys := make([]string, 0, len(dict))
+	for k := range dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Objects and types are numbered apart, so both take the same RefId
+	ref := nextRef(refs)
+	var sb strings.Builder
+	sb.WriteString(`<Obj` + nameAttr(name) + ` RefId="` + ref + `"><TN RefId="` + ref + `">` +
+		`<T>System.Management.Automation.PSPrimitiveDictionary</T><T>System.Collections.Hashtable</T><T>System.Object</T></TN><DCT>`)
+	for _, k := range keys {
+		value, err := primitiveXML("Value", dict[k], path+k, refs)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(`<En>` + clixmlString(k)("Key", refs) + value + `</En>`)
+	}
+	sb.WriteString(`</DCT></Obj>`)
+	return sb.String(), nil
+}
+
+// primitiveXML renders a value of a PSPrimitiveDictionary.
+func primitiveXML(name string, v interface{}, path string, refs *int) (string, error) {
+	elem := func(tag, text string) (string, error) {
+		return `<` + tag + nameAttr(name) + `>` + text + `</` + tag + `>`, nil
+	}
+	switch val := v.(type) {
+	case nil:
+		return `<Nil` + nameAttr(name) + ` />`, nil
+	case string:
+		return elem("S", escapeCLIXML(val))
+	case bool:
+		return elem("B", strconv.FormatBool(val))
+	case int:
+		if val >= math.MinInt32 && val <= math.MaxInt32 {
+			return elem("I32", strconv.Itoa(val))
+		}
+		return elem("I64", strconv.Itoa(val))
+	case int8:
+		return elem("SB", strconv.FormatInt(int64(val), 10))
+	case int16:
+		return elem("I16", strconv.FormatInt(int64(val), 10))
+	case int32:
+		return elem("I32", strconv.FormatInt(int64(val), 10))
+	case int64:
+		return elem("I64", strconv.FormatInt(val, 10))
+	case uint8:
+		return elem("By", strconv.FormatUint(uint64(val), 10))
+	case uint16:
+		return elem("U16", strconv.FormatUint(uint64(val), 10))
+	case uint32:
+		return elem("U32", strconv.FormatUint(uint64(val), 10))
+	case uint64:
+		return elem("U64", strconv.FormatUint(val, 10))
+	case float32:
+		return elem("Sg", strconv.FormatFloat(float64(val), 'G', -1, 32))
+	case float64:
+		return elem("Db", strconv.FormatFloat(val, 'G', -1, 64))
+	case time.Time:
+		return elem("DT", val.Format(time.RFC3339Nano))
+	case time.Duration:
+		return elem("TS", clixmlDuration(val))
+	case uuid.UUID:
+		return elem("G", val.String())
+	case *url.URL:
+		return elem("URI", escapeCLIXML(val.String()))
+	case []byte:
+		return elem("BA", base64.StdEncoding.EncodeToString(val))
+	case map[string]interface{}:
+		return primitiveDictionaryXML(name, val, path+".", refs)
+	case map[string]string:
+		dict := make(map[string]interface{}, len(val))
+		for k, s := range val {
+			dict[k] = s
+		}
+		return primitiveDictionaryXML(name, dict, path+".", refs)
+	}
+
+	list := reflect.ValueOf(v)
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return "", fmt.Errorf("powershell: application argument %s: unsupported type %T", path, v)
+	}
+	ref := nextRef(refs)
+	var sb strings.Builder
+	sb.WriteString(`<Obj` + nameAttr(name) + ` RefId="` + ref + `"><TN RefId="` + ref + `">` +
+		`<T>System.Object[]</T><T>System.Array</T><T>System.Object</T></TN><LST>`)
+	for i := 0; i < list.Len(); i++ {
+		item, err := primitiveXML("", list.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i), refs)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(item)
+	}
+	sb.WriteString(`</LST></Obj>`)
+	return sb.String(), nil
+}
+
+// clixmlDuration formats d as an xs:duration such as "PT1.5S" or "-PT30S".
+func clixmlDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	return sign + "PT" + strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+}
@@ -0,0 +1,142 @@
+package powershell
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/fragments"
+	"github.com/smnsjas/go-psrpcore/messages"
+)
+
+func TestApplicationArgumentsXML(t *testing.T) {
+	got, err := applicationArgumentsXML(map[string]interface{}{"ticket": "CHG<1>", "team": "ops"})
+	if err != nil {
+		t.Fatalf("applicationArgumentsXML() error = %v", err)
+	}
+	want := `<Obj N="ApplicationArguments" RefId="1000"><TN RefId="1000">` +
+		`<T>System.Management.Automation.PSPrimitiveDictionary</T><T>System.Collections.Hashtable</T><T>System.Object</T></TN><DCT>` +
+		`<En><S N="Key">team</S><S N="Value">ops</S></En>` +
+		`<En><S N="Key">ticket</S><S N="Value">CHG&lt;1&gt;</S></En>` +
+		`</DCT></Obj>`
+	if got != want {
+		t.Errorf("applicationArgumentsXML() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestApplicationArgumentsXML_Types(t *testing.T) {
+	id := uuid.MustParse("b6a7c1e2-4f0d-4b8e-9a3c-2d1e0f9a8b7c")
+	endpoint, _ := url.Parse("https://tenant.example.com/api?a=1&b=2")
+	got, err := applicationArgumentsXML(map[string]interface{}{
+		"count":    42,
+		"big":      int64(1) << 40,
+		"enabled":  true,
+		"ratio":    0.5,
+		"started":  time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC),
+		"timeout":  90 * time.Second,
+		"tenant":   id,
+		"endpoint": endpoint,
+		"blob":     []byte("hi"),
+		"regions":  []string{"eu", "us"},
+		"none":     nil,
+		"nested":   map[string]interface{}{"depth": int32(2), "tags": map[string]string{"k": "v"}},
+	})
+	if err != nil {
+		t.Fatalf("applicationArgumentsXML() error = %v", err)
+	}
+	for _, want := range []string{
+		`<En><S N="Key">count</S><I32 N="Value">42</I32></En>`,
+		`<En><S N="Key">big</S><I64 N="Value">1099511627776</I64></En>`,
+		`<En><S N="Key">enabled</S><B N="Value">true</B></En>`,
+		`<En><S N="Key">ratio</S><Db N="Value">0.5</Db></En>`,
+		`<En><S N="Key">started</S><DT N="Value">2026-10-16T08:30:00Z</DT></En>`,
+		`<En><S N="Key">timeout</S><TS N="Value">PT90S</TS></En>`,
+		`<En><S N="Key">tenant</S><G N="Value">b6a7c1e2-4f0d-4b8e-9a3c-2d1e0f9a8b7c</G></En>`,
+		`<En><S N="Key">endpoint</S><URI N="Value">https://tenant.example.com/api?a=1&amp;b=2</URI></En>`,
+		`<En><S N="Key">blob</S><BA N="Value">aGk=</BA></En>`,
+		`<En><S N="Key">none</S><Nil N="Value" /></En>`,
+		`<T>System.Object[]</T><T>System.Array</T><T>System.Object</T></TN><LST><S>eu</S><S>us</S></LST></Obj>`,
+		`<En><S N="Key">depth</S><I32 N="Value">2</I32></En>`,
+		`<En><S N="Key">k</S><S N="Value">v</S></En>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("applicationArgumentsXML() lacks %s in\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "PSPrimitiveDictionary"); n != 3 {
+		t.Errorf("got %d dictionaries, want 3 (top level and two nested)", n)
+	}
+
+	err = ValidateApplicationArguments(map[string]interface{}{"nested": map[string]interface{}{"list": []interface{}{"ok", struct{}{}}}})
+	if err == nil || !strings.Contains(err.Error(), "nested.list[1]") {
+		t.Errorf("ValidateApplicationArguments() error = %v, want the path of the unsupported value", err)
+	}
+	if clixmlDuration(-1500*time.Millisecond) != "-PT1.5S" {
+		t.Errorf("clixmlDuration(-1.5s) = %s", clixmlDuration(-1500*time.Millisecond))
+	}
+}
+
+func TestWithApplicationArguments(t *testing.T) {
+	poolID := uuid.New()
+	encode := func(objectID uint64, msg *messages.Message) []byte {
+		msg.Destination = messages.DestinationServer
+		msg.RunspaceID = poolID
+		blob, err := msg.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		frags, err := fragmentMessage(objectID, blob, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return frags
+	}
+
+	handshake := append(
+		encode(1, &messages.Message{
+			Type: messages.MessageTypeSessionCapability,
+			Data: []byte(`<Obj RefId="0"><MS><Version N="protocolversion">2.3</Version></MS></Obj>`),
+		}),
+		encode(2, &messages.Message{
+			Type: messages.MessageTypeInitRunspacePool,
+			Data: []byte(`<Obj RefId="0"><MS><I32 N="MinRunspaces">1</I32><Nil N="ApplicationArguments" /></MS></Obj>`),
+		})...)
+
+	out, err := withApplicationArguments(handshake, map[string]interface{}{"team": "ops"})
+	if err != nil {
+		t.Fatalf("withApplicationArguments() error = %v", err)
+	}
+
+	var got []*messages.Message
+	var blob []byte
+	for rest := out; len(rest) > 0; {
+		frag, err := fragments.Decode(rest)
+		if err != nil {
+			t.Fatalf("decode fragment: %v", err)
+		}
+		rest = rest[fragments.HeaderSize+len(frag.Data):]
+		blob = append(blob, frag.Data...)
+		if frag.End {
+			msg, err := messages.Decode(blob)
+			if err != nil {
+				t.Fatalf("decode message: %v", err)
+			}
+			got = append(got, msg)
+			blob = nil
+		}
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d messages, want 2", len(got))
+	}
+	initData := string(got[1].Data)
+	if strings.Contains(initData, `<Nil N="ApplicationArguments"`) ||
+		!strings.Contains(initData, `<En><S N="Key">team</S><S N="Value">ops</S></En>`) {
+		t.Errorf("INIT_RUNSPACEPOOL data = %s", initData)
+	}
+	if string(got[0].Data) != `<Obj RefId="0"><MS><Version N="protocolversion">2.3</Version></MS></Obj>` {
+		t.Errorf("SESSION_CAPABILITY changed: %s", got[0].Data)
+	}
+}
@@ -2,6 +2,7 @@ package powershell
 
 import (
 	"context"
+	"errors"
 	"io"
 
 	"github.com/smnsjas/go-psrpcore/pipeline"
@@ -35,6 +36,11 @@ type RunspaceBackend interface {
 	// - error: any error during setup
 	PreparePipeline(ctx context.Context, p *pipeline.Pipeline, payload string) (io.Reader, func(), error)
 
+	// StopPipeline asks the server to stop a running pipeline, as Ctrl+C
+	// does in an interactive session. It returns once the server has
+	// accepted the request; the pipeline then ends in the Stopped state.
+	StopPipeline(ctx context.Context, p *pipeline.Pipeline) error
+
 	// ShellID returns the identifier of the underlying shell/runspace.
 	ShellID() string
 
@@ -47,4 +53,75 @@ type RunspaceBackend interface {
 	// WS-MAN level keepalive via WSMAN_CMDSHELL_OPTION_KEEPALIVE on Receive operations.
 	// HvSocket and OutOfProc transports DO support PSRP-level keepalive.
 	SupportsPSRPKeepalive() bool
+
+	// Capabilities reports what the backend supports, so callers can choose
+	// a strategy without checking for a particular backend type.
+	Capabilities() Capabilities
+}
+
+// Capabilities describes the features and limits of a RunspaceBackend.
+type Capabilities struct {
+	// Disconnect reports whether the session can be disconnected and later
+	// reconnected with its pipelines still running on the server.
+	Disconnect bool
+
+	// ParallelPipelines reports whether several pipelines can send input
+	// at full rate at the same time. HvSocket connections fail under
+	// concurrent bursts, so input there must be paced.
+	ParallelPipelines bool
+
+	// MaxPayloadSize is the largest request the transport accepts, in
+	// bytes, or 0 if there is no limit.
+	MaxPayloadSize int
+
+	// Signal reports whether pipelines can be stopped and shells
+	// terminated with a transport-level signal.
+	Signal bool
+
+	// HostCalls reports whether the server can send host calls (Read-Host,
+	// prompts) to the client.
+	HostCalls bool
+}
+
+// Capabilities of the built-in backends.
+var (
+	// WSManCapabilities are the capabilities of WSManBackend.
+	WSManCapabilities = Capabilities{
+		Disconnect:        true,
+		ParallelPipelines: true,
+		MaxPayloadSize:    512000, // default MaxEnvelopeSizekb of 500
+		Signal:            true,
+		HostCalls:         true,
+	}
+
+	// HvSocketCapabilities are the capabilities of HvSocketBackend.
+	HvSocketCapabilities = Capabilities{
+		Signal: true,
+	}
+
+	// StreamCapabilities are the capabilities of the backends that run
+	// over a single OutOfProc stream: SSH, local process and named pipe.
+	StreamCapabilities = Capabilities{
+		ParallelPipelines: true,
+		Signal:            true,
+	}
+
+	// LoopbackCapabilities are the capabilities of LoopbackBackend.
+	LoopbackCapabilities = Capabilities{
+		ParallelPipelines: true,
+		Signal:            true,
+	}
+)
+
+// errNotConnected is returned by StopPipeline when the backend has no
+// connection to signal over.
+var errNotConnected = errors.New("backend not connected")
+
+// stopOutOfProcPipeline sends the OutOfProc Signal for p over adapter and
+// waits for the server's SignalAck.
+func stopOutOfProcPipeline(ctx context.Context, adapter hvTransportAdapter, p *pipeline.Pipeline) error {
+	if adapter == nil {
+		return errNotConnected
+	}
+	return adapter.Signal(ctx, p.ID())
 }
@@ -7,8 +7,10 @@ import (
 	"context"
 	"errors"
 	"io"
+	"log/slog"
 
 	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/wsman/transport"
 	"github.com/smnsjas/go-psrpcore/pipeline"
 	"github.com/smnsjas/go-psrpcore/runspace"
 )
@@ -21,6 +23,23 @@ func NewHvSocketBackend(_ uuid.UUID, _, _, _, _ string, _ uuid.UUID) *HvSocketBa
 	return &HvSocketBackend{}
 }
 
+// SetCredentials is a no-op on non-Windows platforms.
+func (b *HvSocketBackend) SetCredentials(_, _, _ string) {}
+
+// SetLogger is a no-op on non-Windows platforms.
+func (b *HvSocketBackend) SetLogger(_ *slog.Logger) {}
+
+// SetRateLimiter is a no-op on non-Windows platforms.
+func (b *HvSocketBackend) SetRateLimiter(_ transport.RateLimiter) {}
+
+// SetCompression is a no-op on non-Windows platforms.
+func (b *HvSocketBackend) SetCompression(_ ...Compressor) {}
+
+// Compression returns "" on non-Windows platforms.
+func (b *HvSocketBackend) Compression() string {
+	return ""
+}
+
 // Connect returns an error on non-Windows platforms.
 func (b *HvSocketBackend) Connect(_ context.Context) error {
 	return errors.New("hvsock is only supported on windows")
@@ -41,6 +60,11 @@ func (b *HvSocketBackend) PreparePipeline(ctx context.Context, p *pipeline.Pipel
 	return nil, nil, errors.New("hvsock is only supported on windows")
 }
 
+// StopPipeline returns an error on non-Windows platforms.
+func (b *HvSocketBackend) StopPipeline(_ context.Context, _ *pipeline.Pipeline) error {
+	return errors.New("hvsock is only supported on windows")
+}
+
 // Close is a no-op on non-Windows platforms.
 func (b *HvSocketBackend) Close(_ context.Context) error {
 	return nil
@@ -60,3 +84,8 @@ func (b *HvSocketBackend) Reattach(_ context.Context, _ *runspace.Pool, _ string
 func (b *HvSocketBackend) SupportsPSRPKeepalive() bool {
 	return false
 }
+
+// Capabilities returns HvSocketCapabilities.
+func (b *HvSocketBackend) Capabilities() Capabilities {
+	return HvSocketCapabilities
+}
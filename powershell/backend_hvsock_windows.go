@@ -7,7 +7,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"os"
 	"strings"
@@ -16,23 +16,21 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/smnsjas/go-psrp/hvsock"
+	"github.com/smnsjas/go-psrp/wsman/transport"
 	"github.com/smnsjas/go-psrpcore/outofproc"
 	"github.com/smnsjas/go-psrpcore/pipeline"
 	"github.com/smnsjas/go-psrpcore/runspace"
 )
 
-var hvDebug = os.Getenv("PSRP_DEBUG") != ""
 var hvTrace = os.Getenv("PSRP_HV_TRACE") != ""
 
-func hvDebugf(format string, args ...interface{}) {
-	if hvDebug {
-		log.Printf("[hvsock-backend] "+format, args...)
-	}
-}
+// hvStderrLogger logs at debug level to stderr, for PSRP_HV_TRACE.
+var hvStderrLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})).
+	With("component", "hvsock")
 
 func hvTracef(format string, args ...interface{}) {
 	if hvTrace {
-		log.Printf("[hvsock-backend] "+format, args...)
+		hvStderrLogger.Debug(fmt.Sprintf(format, args...))
 	}
 }
 
@@ -51,16 +49,25 @@ type HvSocketBackend struct {
 
 	connected bool
 	closed    bool
-}
 
-type hvTransportAdapter interface {
-	io.ReadWriter
-	SendCommand(pipelineGUID uuid.UUID) error
-	SendPipelineData(pipelineGUID uuid.UUID, data []byte) error
-	SendSignal(pipelineGUID uuid.UUID) error
-	Close() error
+	compressors []Compressor
+	compression Compressor // negotiated on Connect, nil for none
+
+	logger      *slog.Logger          // set by SetLogger
+	limiter     transport.RateLimiter // set by SetRateLimiter
+	stopLimiter context.CancelFunc    // ends the limiter waits of conn
+
+	// capability records the server's SESSION_CAPABILITY.
+	capability capabilityWatch
+
+	// failures records the terminating errors of failed pipelines.
+	failures failureWatch
 }
 
+// compressionProbeTimeout bounds the compression probe, so a server that
+// does not acknowledge it only delays Connect briefly.
+const compressionProbeTimeout = 2 * time.Second
+
 type hvPacketReadWriter struct {
 	r          io.Reader
 	w          io.Writer
@@ -218,6 +225,64 @@ func NewHvSocketBackend(vmID uuid.UUID, domain, username, password, configName s
 	}
 }
 
+// SetCredentials replaces the credentials used by the next Connect or Reattach.
+func (b *HvSocketBackend) SetCredentials(domain, username, password string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.domain = domain
+	b.username = username
+	b.password = password
+}
+
+// SetLogger sets the logger of the backend and its HvSocket handshake.
+// Without one, the backend logs to slog.Default(). The client passes its
+// "hvsock" component logger, which PSRP_DEBUG and PSRP_LOG_LEVEL configure.
+func (b *HvSocketBackend) SetLogger(logger *slog.Logger) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.logger = logger
+}
+
+// log returns the logger of the backend.
+func (b *HvSocketBackend) log() *slog.Logger {
+	if b.logger != nil {
+		return b.logger
+	}
+	return slog.Default()
+}
+
+// debugf logs a formatted debug message.
+func (b *HvSocketBackend) debugf(format string, args ...interface{}) {
+	b.log().Debug(fmt.Sprintf(format, args...))
+}
+
+// SetRateLimiter paces the socket's traffic through limiter from the next
+// Connect or Reattach. Nil removes the limit.
+func (b *HvSocketBackend) SetRateLimiter(limiter transport.RateLimiter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limiter = limiter
+}
+
+// SetCompression offers compressors, in order of preference, to the server
+// on the next Connect or Reattach. See Compressor.
+func (b *HvSocketBackend) SetCompression(compressors ...Compressor) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.compressors = compressors
+}
+
+// Compression returns the name of the compressor negotiated with the
+// server, or "" if the channel is not compressed.
+func (b *HvSocketBackend) Compression() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.compression == nil {
+		return ""
+	}
+	return b.compression.Name()
+}
+
 func (b *HvSocketBackend) Connect(ctx context.Context) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -229,28 +294,44 @@ func (b *HvSocketBackend) Connect(ctx context.Context) error {
 		return ErrPoolClosed
 	}
 
-	hvDebugf("Connecting to VM %s", b.vmID)
-	conn, err := hvsock.ConnectAndAuthenticate(ctx, b.vmID, b.domain, b.username, b.password, b.configName)
+	b.debugf("Connecting to VM %s", b.vmID)
+	conn, err := hvsock.ConnectAndAuthenticate(ctx, b.vmID, b.domain, b.username, b.password, b.configName,
+		hvsock.WithLogger(b.log()))
 	if err != nil {
 		return fmt.Errorf("hvsock connect: %w", err)
 	}
 
-	// Wrap connection with debug logging if PSRP_DEBUG is set
-	if hvDebug {
-		conn = &debugConn{conn, "wire"}
+	// Wrap connection with wire logging if the logger shows debug messages
+	if logger := b.log(); logger.Enabled(ctx, slog.LevelDebug) {
+		conn = &debugConn{Conn: conn, prefix: "wire", logger: logger}
 	}
 	b.conn = conn
-	hvDebugf("Connection established")
+	b.debugf("Connection established")
 
 	// Clear deadline for PSRP operations
 	_ = conn.SetDeadline(time.Time{})
 
 	// Create OutOfProc Adapter
-	hvDebugf("Creating OutOfProc transport and adapter (poolID=%s)", b.poolID)
-	rw := &writeDeadlineReadWriter{conn: conn, writeTimeout: 30 * time.Second}
+	b.debugf("Creating OutOfProc transport and adapter (poolID=%s)", b.poolID)
+	limitCtx, stopLimiter := context.WithCancel(context.WithoutCancel(ctx))
+	b.stopLimiter = stopLimiter
+	rw := rateLimited(limitCtx, &writeDeadlineReadWriter{conn: conn, writeTimeout: 30 * time.Second}, b.limiter)
 	transport := outofproc.NewTransportFromReadWriter(&hvPacketReadWriter{r: rw, w: rw})
-	b.adapter = newHvOutOfProcAdapter(transport, b.poolID, 5*time.Minute)
-	hvDebugf("Adapter created")
+	adapter := newHvOutOfProcAdapter(transport, b.poolID, 5*time.Minute)
+	b.adapter = adapter
+	b.debugf("Adapter created")
+
+	b.compression = nil
+	if len(b.compressors) > 0 {
+		probeCtx, cancel := context.WithTimeout(ctx, compressionProbeTimeout)
+		b.compression, err = adapter.negotiateCompression(probeCtx, b.compressors)
+		cancel()
+		if err != nil {
+			// Not fatal: the channel works uncompressed
+			b.debugf("Compression probe failed: %v", err)
+		}
+		b.debugf("Compression: %v", b.compression)
+	}
 
 	b.connected = true
 	return nil
@@ -270,16 +351,29 @@ func (b *HvSocketBackend) Init(ctx context.Context, pool *runspace.Pool) error {
 	}
 	b.mu.Unlock()
 
-	hvDebugf("Opening PSRP pool...")
+	b.debugf("Opening PSRP pool...")
+	pool.SetTransport(b.failures.watch(b.capability.watch(b.Transport())))
 	err := pool.Open(ctx)
 	if err != nil {
-		hvDebugf("Pool.Open failed: %v", err)
+		b.debugf("Pool.Open failed: %v", err)
 		return err
 	}
-	hvDebugf("Pool.Open succeeded")
+	b.debugf("Pool.Open succeeded")
 	return nil
 }
 
+// ServerCapability returns the server's SESSION_CAPABILITY from the last
+// Init or Reattach.
+func (b *HvSocketBackend) ServerCapability() (SessionCapability, bool) {
+	return b.capability.ServerCapability()
+}
+
+// PipelineFailure returns the terminating error of the failed pipeline id,
+// once.
+func (b *HvSocketBackend) PipelineFailure(id uuid.UUID) (*ErrorRecord, bool) {
+	return b.failures.PipelineFailure(id)
+}
+
 // ShellID returns the implementation identifier.
 func (b *HvSocketBackend) ShellID() string {
 	return b.poolID.String()
@@ -293,7 +387,8 @@ func (b *HvSocketBackend) Reattach(ctx context.Context, pool *runspace.Pool, _ s
 	// We proactively reset the connection to ensure we get a fresh socket.
 	b.mu.Lock()
 	if b.connected {
-		hvDebugf("[reattach] Resetting existing connection to force fresh socket")
+		b.debugf("[reattach] Resetting existing connection to force fresh socket")
+		b.stopLimiterLocked()
 		if b.conn != nil {
 			_ = b.conn.Close()
 		}
@@ -310,18 +405,18 @@ func (b *HvSocketBackend) Reattach(ctx context.Context, pool *runspace.Pool, _ s
 	// Update the pool's transport to use the new adapter!
 	// This is critical because the pool was likely created with a nil or stale adapter
 	// in Client.Reconnect.
-	pool.SetTransport(b.adapter)
+	pool.SetTransport(b.failures.watch(b.capability.watch(b.adapter)))
 
 	// 2. Perform PSRP handshake
 	// For HvSocket, the server-side session is typically destroyed when the connection breaks
 	// (e.g., VM pause). We need to create a NEW session using Open(), not reconnect to an
 	// existing one using Connect(). Connect() sends CONNECT_RUNSPACEPOOL which the server
 	// won't recognize if the old session is gone.
-	hvDebugf("Performing PSRP session establishment (Open)...")
+	b.debugf("Performing PSRP session establishment (Open)...")
 	if err := pool.Open(ctx); err != nil {
 		return fmt.Errorf("pool open: %w", err)
 	}
-	hvDebugf("PSRP session established successfully")
+	b.debugf("PSRP session established successfully")
 
 	return nil
 }
@@ -332,12 +427,25 @@ func (b *HvSocketBackend) PreparePipeline(ctx context.Context, p *pipeline.Pipel
 	return nil, func() {}, nil
 }
 
+// StopPipeline sends an OutOfProc Signal for the pipeline over the socket.
+func (b *HvSocketBackend) StopPipeline(ctx context.Context, p *pipeline.Pipeline) error {
+	b.mu.Lock()
+	adapter := b.adapter
+	b.mu.Unlock()
+	return stopOutOfProcPipeline(ctx, adapter, p)
+}
+
 // SupportsPSRPKeepalive returns true for HvSocket.
 // HvSocket uses OUT-OF-PROC transport which supports PSRP-level keepalive messages.
 func (b *HvSocketBackend) SupportsPSRPKeepalive() bool {
 	return true
 }
 
+// Capabilities returns HvSocketCapabilities.
+func (b *HvSocketBackend) Capabilities() Capabilities {
+	return HvSocketCapabilities
+}
+
 func (b *HvSocketBackend) Close(ctx context.Context) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -346,12 +454,13 @@ func (b *HvSocketBackend) Close(ctx context.Context) error {
 		return nil
 	}
 	b.closed = true
+	b.stopLimiterLocked()
 
-	hvDebugf("=== Starting backend close sequence ===")
+	b.debugf("=== Starting backend close sequence ===")
 
 	// Step 1: Close the adapter (sends protocol-level Close)
 	if b.adapter != nil {
-		hvDebugf("Step 1: Closing adapter...")
+		b.debugf("Step 1: Closing adapter...")
 		closeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 		defer cancel()
 
@@ -363,21 +472,21 @@ func (b *HvSocketBackend) Close(ctx context.Context) error {
 
 		select {
 		case <-done:
-			hvDebugf("Adapter closed successfully")
+			b.debugf("Adapter closed successfully")
 		case <-closeCtx.Done():
-			hvDebugf("Adapter close timed out")
+			b.debugf("Adapter close timed out")
 		}
 	}
 
 	// Step 2: Close the socket connection
 	if b.conn != nil {
-		hvDebugf("Step 2: Closing socket...")
+		b.debugf("Step 2: Closing socket...")
 
 		// Set deadline to prevent hanging
 		b.conn.SetDeadline(time.Now().Add(2 * time.Second))
 
 		if err := b.conn.Close(); err != nil {
-			hvDebugf("Socket close error (expected): %v", err)
+			b.debugf("Socket close error (expected): %v", err)
 		}
 	}
 
@@ -386,28 +495,42 @@ func (b *HvSocketBackend) Close(ctx context.Context) error {
 	// - Terminate the PowerShell process
 	// - Release the HvSocket endpoint binding
 	// - Clean up internal state
-	hvDebugf("Step 3: Waiting for guest cleanup (1.5s)...")
+	b.debugf("Step 3: Waiting for guest cleanup (1.5s)...")
 	time.Sleep(1500 * time.Millisecond)
 
-	hvDebugf("=== Backend close sequence complete ===")
+	b.debugf("=== Backend close sequence complete ===")
 	return nil
 }
 
+// stopLimiterLocked ends the limiter waits of the connection. b.mu must be held.
+func (b *HvSocketBackend) stopLimiterLocked() {
+	if b.stopLimiter != nil {
+		b.stopLimiter()
+		b.stopLimiter = nil
+	}
+}
+
 // debugConn wraps a net.Conn with read/write logging
 type debugConn struct {
 	net.Conn
 	prefix string
+	logger *slog.Logger
+}
+
+// debugf logs a formatted debug message.
+func (c *debugConn) debugf(format string, args ...interface{}) {
+	c.logger.Debug(fmt.Sprintf(format, args...))
 }
 
 func (c *debugConn) Read(p []byte) (n int, err error) {
-	hvDebugf("[%s] READ waiting...", c.prefix)
+	c.debugf("[%s] READ waiting...", c.prefix)
 	n, err = c.Conn.Read(p)
 	if n > 0 {
 		data := p[:n]
 		if len(data) > 200 {
-			hvDebugf("[%s] READ %d bytes: %s... (truncated)", c.prefix, n, string(data[:200]))
+			c.debugf("[%s] READ %d bytes: %s... (truncated)", c.prefix, n, string(data[:200]))
 		} else {
-			hvDebugf("[%s] READ %d bytes: %s", c.prefix, n, string(data))
+			c.debugf("[%s] READ %d bytes: %s", c.prefix, n, string(data))
 		}
 	}
 	if err != nil {
@@ -418,9 +541,9 @@ func (c *debugConn) Read(p []byte) (n int, err error) {
 			strings.Contains(msg, "closed network connection") ||
 			strings.Contains(msg, "connection was aborted") ||
 			strings.Contains(msg, "connection reset") {
-			hvDebugf("[%s] READ closed (expected)", c.prefix)
+			c.debugf("[%s] READ closed (expected)", c.prefix)
 		} else {
-			hvDebugf("[%s] READ error: %v", c.prefix, err)
+			c.debugf("[%s] READ error: %v", c.prefix, err)
 		}
 	}
 	return n, err
@@ -431,13 +554,13 @@ func (c *debugConn) Write(p []byte) (n int, err error) {
 	if n > 0 {
 		data := p[:n]
 		if len(data) > 200 {
-			hvDebugf("[%s] WRITE %d bytes: %s... (truncated)", c.prefix, n, string(data[:200]))
+			c.debugf("[%s] WRITE %d bytes: %s... (truncated)", c.prefix, n, string(data[:200]))
 		} else {
-			hvDebugf("[%s] WRITE %d bytes: %s", c.prefix, n, string(data))
+			c.debugf("[%s] WRITE %d bytes: %s", c.prefix, n, string(data))
 		}
 	}
 	if err != nil {
-		hvDebugf("[%s] WRITE error: %v", c.prefix, err)
+		c.debugf("[%s] WRITE error: %v", c.prefix, err)
 	}
 	return n, err
 }
@@ -0,0 +1,92 @@
+package powershell
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestIsPSHostPipe(t *testing.T) {
+	tests := []struct {
+		name string
+		pid  int
+		want bool
+	}{
+		{"PSHost.133742516350000000.4242.DefaultAppDomain.pwsh", 4242, true},
+		{"PSHost.133742516350000000.4242.DefaultAppDomain.pwsh", 424, false},
+		{"PSHost.133742516350000000.14242.DefaultAppDomain.pwsh", 4242, false},
+		{"OtherPipe.1.4242.x.y", 4242, false},
+		{"PSHost.1.4242", 4242, false},
+	}
+
+	for _, tt := range tests {
+		if got := isPSHostPipe(tt.name, tt.pid); got != tt.want {
+			t.Errorf("isPSHostPipe(%q, %d) = %v, want %v", tt.name, tt.pid, got, tt.want)
+		}
+	}
+}
+
+func TestFindPSHostPipe(t *testing.T) {
+	dir := t.TempDir()
+	oldDir := pipeDir
+	pipeDir = dir
+	t.Cleanup(func() { pipeDir = oldDir })
+
+	want := "PSHost.133742516350000000.4242.DefaultAppDomain.pwsh"
+	for _, name := range []string{"PSHost.1.99.DefaultAppDomain.pwsh", want} {
+		if err := os.WriteFile(filepath.Join(dir, pipePrefix+name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := FindPSHostPipe(4242)
+	if err != nil {
+		t.Fatalf("FindPSHostPipe() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("FindPSHostPipe() = %q, want %q", got, want)
+	}
+
+	if _, err := FindPSHostPipe(7); err == nil {
+		t.Error("FindPSHostPipe(7) error = nil, want error")
+	}
+}
+
+func TestProcessBackend_ConnectClose(t *testing.T) {
+	// cat echoes stdin and exits when it is closed, like a server-mode process.
+	path, err := exec.LookPath("cat")
+	if err != nil {
+		t.Skip("cat not available")
+	}
+
+	b := NewProcessBackend(path, []string{}, uuid.New())
+	ctx := context.Background()
+	if err := b.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if b.Transport() == nil {
+		t.Error("Transport() = nil after Connect")
+	}
+	stream := b.stream.(*processCloser)
+
+	if err := b.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if stream.cmd.ProcessState == nil {
+		t.Error("process not reaped after Close")
+	}
+	if err := b.Connect(ctx); err != ErrPoolClosed {
+		t.Errorf("Connect() after Close error = %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestProcessBackend_MissingExecutable(t *testing.T) {
+	b := NewProcessBackend(filepath.Join(t.TempDir(), "missing-pwsh"), nil, uuid.New())
+	if err := b.Connect(context.Background()); err == nil {
+		t.Error("Connect() error = nil, want error")
+	}
+}
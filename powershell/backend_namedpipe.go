@@ -0,0 +1,74 @@
+package powershell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// NamedPipeBackend implements RunspaceBackend for an existing local PowerShell
+// process, attached through the PSHost named pipe every PowerShell process
+// listens on (the transport behind Enter-PSHostProcess).
+//
+// On Windows the pipe is \\.\pipe\<name>; elsewhere .NET exposes it as the
+// Unix domain socket $TMPDIR/CoreFxPipe_<name>.
+type NamedPipeBackend struct {
+	streamBackend
+
+	pid      int
+	pipeName string
+}
+
+// NewNamedPipeBackend creates a backend that attaches to a PowerShell process.
+// If pipeName is empty, the PSHost pipe of process pid is used; pipeName is
+// set for processes started with -CustomPipeName.
+func NewNamedPipeBackend(pid int, pipeName string, poolID uuid.UUID) *NamedPipeBackend {
+	b := &NamedPipeBackend{pid: pid, pipeName: pipeName}
+	b.poolID = poolID
+	b.open = b.dial
+	return b
+}
+
+// dial connects to the process's pipe.
+func (b *NamedPipeBackend) dial(ctx context.Context) (io.ReadWriter, io.Closer, error) {
+	name := b.pipeName
+	if name == "" {
+		var err error
+		if name, err = FindPSHostPipe(b.pid); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	conn, err := dialPipe(ctx, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("named pipe dial %s: %w", name, err)
+	}
+	return conn, conn, nil
+}
+
+// FindPSHostPipe returns the name of the PSHost pipe of PowerShell process pid.
+// PowerShell names the pipe PSHost.<StartTime>.<PID>.<AppDomain>.<ProcessName>.
+func FindPSHostPipe(pid int) (string, error) {
+	entries, err := os.ReadDir(pipeDir)
+	if err != nil {
+		return "", fmt.Errorf("list named pipes: %w", err)
+	}
+	for _, entry := range entries {
+		name := strings.TrimPrefix(entry.Name(), pipePrefix)
+		if isPSHostPipe(name, pid) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no PowerShell host pipe found for process %d", pid)
+}
+
+// isPSHostPipe reports whether name is the PSHost pipe of process pid.
+func isPSHostPipe(name string, pid int) bool {
+	parts := strings.SplitN(name, ".", 4)
+	return len(parts) == 4 && parts[0] == "PSHost" && parts[2] == strconv.Itoa(pid)
+}
@@ -0,0 +1,107 @@
+package powershell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// processExitTimeout is how long Close waits for PowerShell to exit after its
+// stdin is closed before killing it.
+const processExitTimeout = 5 * time.Second
+
+// ProcessBackend implements RunspaceBackend for a local PowerShell process
+// started in server mode (-s). It speaks the OutOfProc protocol over the
+// process's stdin/stdout, like Start-Job and PowerShell's own local sessions.
+type ProcessBackend struct {
+	streamBackend
+
+	path string
+	args []string
+}
+
+// DefaultProcessArgs are the arguments used to start PowerShell in server mode.
+var DefaultProcessArgs = []string{"-s", "-NoLogo", "-NoProfile"}
+
+// NewProcessBackend creates a backend that starts path with args.
+// If path is empty, DefaultProcessPath is used. If args is nil, DefaultProcessArgs is used.
+func NewProcessBackend(path string, args []string, poolID uuid.UUID) *ProcessBackend {
+	if args == nil {
+		args = DefaultProcessArgs
+	}
+	b := &ProcessBackend{path: path, args: args}
+	b.poolID = poolID
+	b.open = b.start
+	return b
+}
+
+// DefaultProcessPath returns the PowerShell executable to run: pwsh if it is
+// on PATH, otherwise powershell.exe on Windows.
+func DefaultProcessPath() (string, error) {
+	if path, err := exec.LookPath("pwsh"); err == nil {
+		return path, nil
+	}
+	if runtime.GOOS == "windows" {
+		if path, err := exec.LookPath("powershell.exe"); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New("process: pwsh not found on PATH")
+}
+
+// start launches the PowerShell process.
+func (b *ProcessBackend) start(_ context.Context) (io.ReadWriter, io.Closer, error) {
+	path := b.path
+	if path == "" {
+		var err error
+		if path, err = DefaultProcessPath(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// The process outlives Connect's context, so it is not started with
+	// exec.CommandContext; Close stops it.
+	cmd := exec.Command(path, b.args...) // #nosec G204 -- path and args come from the caller's configuration
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("process stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("process stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("start %s: %w", path, err)
+	}
+
+	return &readWriter{Reader: stdout, Writer: stdin}, &processCloser{cmd: cmd, stdin: stdin}, nil
+}
+
+// processCloser stops a PowerShell server-mode process.
+type processCloser struct {
+	cmd   *exec.Cmd
+	stdin io.Closer
+}
+
+// Close closes stdin, which ends the server loop, and kills the process if it
+// has not exited within processExitTimeout.
+func (p *processCloser) Close() error {
+	_ = p.stdin.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- p.cmd.Wait() }()
+
+	select {
+	case <-done:
+	case <-time.After(processExitTimeout):
+		_ = p.cmd.Process.Kill()
+		<-done
+	}
+	return nil
+}
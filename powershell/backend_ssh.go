@@ -0,0 +1,264 @@
+package powershell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/wsman/transport"
+	"github.com/smnsjas/go-psrpcore/outofproc"
+	"github.com/smnsjas/go-psrpcore/pipeline"
+	"github.com/smnsjas/go-psrpcore/runspace"
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultSSHSubsystem is the SSH subsystem registered for PowerShell remoting
+// (Subsystem powershell ... -sshs in sshd_config).
+const DefaultSSHSubsystem = "powershell"
+
+// SSHBackend implements RunspaceBackend for PowerShell remoting over SSH.
+// It starts the PowerShell SSH subsystem and speaks the OutOfProc protocol
+// over the session's stdin/stdout, like the HvSocket backend does over a socket.
+type SSHBackend struct {
+	mu sync.Mutex
+
+	addr      string
+	config    *ssh.ClientConfig
+	subsystem string
+	poolID    uuid.UUID
+
+	client  *ssh.Client
+	session *ssh.Session
+	adapter hvTransportAdapter
+
+	connected bool
+	closed    bool
+
+	limiter     transport.RateLimiter // set by SetRateLimiter
+	stopLimiter context.CancelFunc    // ends the limiter waits of the session
+
+	// capability records the server's SESSION_CAPABILITY.
+	capability capabilityWatch
+
+	// failures records the terminating errors of failed pipelines.
+	failures failureWatch
+}
+
+// sshReadWriter joins an SSH session's stdout and stdin.
+type sshReadWriter struct {
+	io.Reader
+	io.Writer
+}
+
+// NewSSHBackend creates a backend that connects to addr (host:port).
+// If subsystem is empty, DefaultSSHSubsystem is used.
+func NewSSHBackend(addr string, config *ssh.ClientConfig, subsystem string, poolID uuid.UUID) *SSHBackend {
+	if subsystem == "" {
+		subsystem = DefaultSSHSubsystem
+	}
+	return &SSHBackend{
+		addr:      addr,
+		config:    config,
+		subsystem: subsystem,
+		poolID:    poolID,
+	}
+}
+
+// SetClientConfig replaces the SSH client configuration (credentials and host
+// key verification) used by the next Connect or Reattach.
+func (b *SSHBackend) SetClientConfig(config *ssh.ClientConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.config = config
+}
+
+// SetRateLimiter paces the session's traffic through limiter from the next
+// Connect or Reattach. Nil removes the limit.
+func (b *SSHBackend) SetRateLimiter(limiter transport.RateLimiter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limiter = limiter
+}
+
+// Connect dials the SSH server and starts the PowerShell subsystem.
+func (b *SSHBackend) Connect(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.connected {
+		return nil
+	}
+	if b.closed {
+		return ErrPoolClosed
+	}
+	if b.config == nil {
+		return errors.New("ssh: client config is required")
+	}
+
+	dialer := net.Dialer{Timeout: b.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", b.addr)
+	if err != nil {
+		return fmt.Errorf("ssh dial: %w", err)
+	}
+
+	// Bound the SSH handshake by the context deadline.
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, b.addr, b.config)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("ssh handshake: %w", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	session, err := client.NewSession()
+	if err != nil {
+		_ = client.Close()
+		return fmt.Errorf("ssh session: %w", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		_ = client.Close()
+		return fmt.Errorf("ssh stdin: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		_ = client.Close()
+		return fmt.Errorf("ssh stdout: %w", err)
+	}
+	if err := session.RequestSubsystem(b.subsystem); err != nil {
+		_ = client.Close()
+		return fmt.Errorf("ssh subsystem %q: %w", b.subsystem, err)
+	}
+
+	limitCtx, stopLimiter := context.WithCancel(context.WithoutCancel(ctx))
+	transport := outofproc.NewTransportFromReadWriter(rateLimited(limitCtx, &sshReadWriter{Reader: stdout, Writer: stdin}, b.limiter))
+	b.stopLimiter = stopLimiter
+	b.client = client
+	b.session = session
+	b.adapter = newHvOutOfProcAdapter(transport, b.poolID, 5*time.Minute)
+	b.connected = true
+	return nil
+}
+
+// Transport returns the OutOfProc adapter.
+func (b *SSHBackend) Transport() io.ReadWriter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.adapter
+}
+
+// Init opens the RunspacePool over the subsystem.
+func (b *SSHBackend) Init(ctx context.Context, pool *runspace.Pool) error {
+	b.mu.Lock()
+	connected := b.connected
+	b.mu.Unlock()
+	if !connected {
+		return fmt.Errorf("backend not connected")
+	}
+	pool.SetTransport(b.failures.watch(b.capability.watch(b.Transport())))
+	return pool.Open(ctx)
+}
+
+// ServerCapability returns the server's SESSION_CAPABILITY from the last
+// Init or Reattach.
+func (b *SSHBackend) ServerCapability() (SessionCapability, bool) {
+	return b.capability.ServerCapability()
+}
+
+// PipelineFailure returns the terminating error of the failed pipeline id,
+// once.
+func (b *SSHBackend) PipelineFailure(id uuid.UUID) (*ErrorRecord, bool) {
+	return b.failures.PipelineFailure(id)
+}
+
+// PreparePipeline returns no per-pipeline transport; all pipelines share the
+// subsystem's OutOfProc stream.
+func (b *SSHBackend) PreparePipeline(_ context.Context, _ *pipeline.Pipeline, _ string) (io.Reader, func(), error) {
+	return nil, func() {}, nil
+}
+
+// StopPipeline sends an OutOfProc Signal for the pipeline.
+func (b *SSHBackend) StopPipeline(ctx context.Context, p *pipeline.Pipeline) error {
+	b.mu.Lock()
+	adapter := b.adapter
+	b.mu.Unlock()
+	return stopOutOfProcPipeline(ctx, adapter, p)
+}
+
+// ShellID returns the RunspacePool ID.
+func (b *SSHBackend) ShellID() string {
+	return b.poolID.String()
+}
+
+// Reattach opens a new session on a fresh SSH connection.
+// The server-side runspace ends with the subsystem process, so there is no
+// existing pool to reconnect to; shellID is ignored.
+func (b *SSHBackend) Reattach(ctx context.Context, pool *runspace.Pool, _ string) error {
+	b.mu.Lock()
+	if b.connected {
+		b.closeConnLocked()
+		b.connected = false
+	}
+	b.mu.Unlock()
+
+	if err := b.Connect(ctx); err != nil {
+		return err
+	}
+	pool.SetTransport(b.failures.watch(b.capability.watch(b.Transport())))
+
+	if err := pool.Open(ctx); err != nil {
+		return fmt.Errorf("pool open: %w", err)
+	}
+	return nil
+}
+
+// SupportsPSRPKeepalive returns true; OutOfProc supports PSRP-level keepalive.
+func (b *SSHBackend) SupportsPSRPKeepalive() bool {
+	return true
+}
+
+// Capabilities returns StreamCapabilities.
+func (b *SSHBackend) Capabilities() Capabilities {
+	return StreamCapabilities
+}
+
+// Close stops the adapter and closes the SSH session and connection.
+func (b *SSHBackend) Close(_ context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.closeConnLocked()
+	return nil
+}
+
+// closeConnLocked tears down the adapter, session and connection. b.mu must be held.
+func (b *SSHBackend) closeConnLocked() {
+	if b.stopLimiter != nil {
+		b.stopLimiter()
+		b.stopLimiter = nil
+	}
+	if b.adapter != nil {
+		_ = b.adapter.Close()
+		b.adapter = nil
+	}
+	if b.session != nil {
+		_ = b.session.Close()
+		b.session = nil
+	}
+	if b.client != nil {
+		_ = b.client.Close()
+		b.client = nil
+	}
+}
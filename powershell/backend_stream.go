@@ -0,0 +1,177 @@
+package powershell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/outofproc"
+	"github.com/smnsjas/go-psrpcore/pipeline"
+	"github.com/smnsjas/go-psrpcore/runspace"
+)
+
+// streamOpener opens a byte stream to a PowerShell OutOfProc server.
+// The returned closer tears the stream down.
+type streamOpener func(ctx context.Context) (io.ReadWriter, io.Closer, error)
+
+// streamBackend implements RunspaceBackend over a single OutOfProc stream.
+// It is shared by the process and named pipe backends, which differ only in
+// how the stream is opened.
+type streamBackend struct {
+	mu sync.Mutex
+
+	open   streamOpener
+	poolID uuid.UUID
+
+	stream  io.Closer
+	adapter hvTransportAdapter
+
+	connected bool
+	closed    bool
+
+	// capability records the server's SESSION_CAPABILITY.
+	capability capabilityWatch
+
+	// failures records the terminating errors of failed pipelines.
+	failures failureWatch
+}
+
+// Connect opens the stream and creates the OutOfProc adapter.
+func (b *streamBackend) Connect(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrPoolClosed
+	}
+	if b.connected {
+		return nil
+	}
+
+	rw, stream, err := b.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	b.stream = stream
+	b.adapter = newHvOutOfProcAdapter(outofproc.NewTransportFromReadWriter(rw), b.poolID, 5*time.Minute)
+	b.connected = true
+	return nil
+}
+
+// Transport returns the OutOfProc adapter.
+func (b *streamBackend) Transport() io.ReadWriter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.adapter
+}
+
+// Init opens the RunspacePool over the stream.
+func (b *streamBackend) Init(ctx context.Context, pool *runspace.Pool) error {
+	b.mu.Lock()
+	connected := b.connected
+	b.mu.Unlock()
+	if !connected {
+		return fmt.Errorf("backend not connected")
+	}
+	pool.SetTransport(b.failures.watch(b.capability.watch(b.Transport())))
+	return pool.Open(ctx)
+}
+
+// ServerCapability returns the server's SESSION_CAPABILITY from the last
+// Init or Reattach.
+func (b *streamBackend) ServerCapability() (SessionCapability, bool) {
+	return b.capability.ServerCapability()
+}
+
+// PipelineFailure returns the terminating error of the failed pipeline id,
+// once.
+func (b *streamBackend) PipelineFailure(id uuid.UUID) (*ErrorRecord, bool) {
+	return b.failures.PipelineFailure(id)
+}
+
+// PreparePipeline returns no per-pipeline transport; all pipelines share the
+// OutOfProc stream.
+func (b *streamBackend) PreparePipeline(_ context.Context, _ *pipeline.Pipeline, _ string) (io.Reader, func(), error) {
+	return nil, func() {}, nil
+}
+
+// StopPipeline sends an OutOfProc Signal for the pipeline.
+func (b *streamBackend) StopPipeline(ctx context.Context, p *pipeline.Pipeline) error {
+	b.mu.Lock()
+	adapter := b.adapter
+	b.mu.Unlock()
+	return stopOutOfProcPipeline(ctx, adapter, p)
+}
+
+// ShellID returns the RunspacePool ID.
+func (b *streamBackend) ShellID() string {
+	return b.poolID.String()
+}
+
+// Reattach opens a new stream and a new RunspacePool on it.
+// The server-side runspace ends with the stream, so shellID is ignored.
+func (b *streamBackend) Reattach(ctx context.Context, pool *runspace.Pool, _ string) error {
+	b.mu.Lock()
+	if b.connected {
+		b.closeStreamLocked()
+		b.connected = false
+	}
+	b.mu.Unlock()
+
+	if err := b.Connect(ctx); err != nil {
+		return err
+	}
+	pool.SetTransport(b.failures.watch(b.capability.watch(b.Transport())))
+
+	if err := pool.Open(ctx); err != nil {
+		return fmt.Errorf("pool open: %w", err)
+	}
+	return nil
+}
+
+// SupportsPSRPKeepalive returns true; OutOfProc supports PSRP-level keepalive.
+func (b *streamBackend) SupportsPSRPKeepalive() bool {
+	return true
+}
+
+// Capabilities returns StreamCapabilities.
+func (b *streamBackend) Capabilities() Capabilities {
+	return StreamCapabilities
+}
+
+// Close stops the adapter and closes the stream.
+func (b *streamBackend) Close(_ context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.connected = false
+	b.closeStreamLocked()
+	return nil
+}
+
+// closeStreamLocked tears down the adapter and stream. b.mu must be held.
+func (b *streamBackend) closeStreamLocked() {
+	if b.adapter != nil {
+		_ = b.adapter.Close()
+		b.adapter = nil
+	}
+	if b.stream != nil {
+		_ = b.stream.Close()
+		b.stream = nil
+	}
+}
+
+// readWriter joins a separate reader and writer, such as a child process's
+// stdout and stdin.
+type readWriter struct {
+	io.Reader
+	io.Writer
+}
@@ -0,0 +1,210 @@
+package powershell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/fragments"
+	"github.com/smnsjas/go-psrpcore/messages"
+	"github.com/smnsjas/go-psrpcore/runspace"
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// maxCapabilityWatch bounds how much of what the server sends is searched
+// for its SESSION_CAPABILITY, in case it never comes.
+const maxCapabilityWatch = 1 << 20
+
+// SessionCapability is the content of a SESSION_CAPABILITY message: the
+// versions a peer uses for a RunspacePool.
+type SessionCapability struct {
+	// ProtocolVersion is the PSRP protocol version (e.g., "2.3").
+	ProtocolVersion string
+
+	// PSVersion is the PowerShell version (e.g., "2.0").
+	PSVersion string
+
+	// SerializationVersion is the CLIXML serialization version (e.g., "1.1.0.1").
+	SerializationVersion string
+}
+
+// CapabilityReporter is implemented by backends that record the server's
+// SESSION_CAPABILITY message during the RunspacePool handshake (Init or
+// Reattach). go-psrpcore reads the message but does not expose it.
+type CapabilityReporter interface {
+	// ServerCapability returns the server's SESSION_CAPABILITY, or false
+	// if none was received in the last handshake.
+	ServerCapability() (SessionCapability, bool)
+}
+
+// capabilityWatch records the server's SESSION_CAPABILITY from the
+// fragments a RunspacePool reads during its handshake.
+type capabilityWatch struct {
+	mu         sync.Mutex
+	capability *SessionCapability
+	watching   bool
+	seen       int
+	buf        []byte
+	assembler  *fragments.Assembler
+}
+
+// start forgets the capability of the last handshake and watches for the
+// one of a new handshake.
+func (w *capabilityWatch) start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.capability = nil
+	w.watching = true
+	w.seen = 0
+	w.buf = nil
+	w.assembler = fragments.NewAssembler()
+}
+
+// watch starts watching a new handshake and returns t with its reads
+// observed. Close and the MultiplexedTransport methods of t are kept.
+func (w *capabilityWatch) watch(t io.ReadWriter) io.ReadWriter {
+	w.start()
+	return watchReads(t, w.observe)
+}
+
+// ServerCapability implements CapabilityReporter.
+func (w *capabilityWatch) ServerCapability() (SessionCapability, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.capability == nil {
+		return SessionCapability{}, false
+	}
+	return *w.capability, true
+}
+
+// observe searches data read from the server for SESSION_CAPABILITY. It
+// stops at the first one, at the RunspacePool state that ends the
+// handshake, or after maxCapabilityWatch bytes.
+func (w *capabilityWatch) observe(data []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.watching || len(data) == 0 {
+		return
+	}
+	w.seen += len(data)
+	if w.seen > maxCapabilityWatch {
+		w.stopLocked()
+		return
+	}
+	w.buf = append(w.buf, data...)
+
+	for w.watching && len(w.buf) >= fragments.HeaderSize {
+		frag, err := fragments.Decode(w.buf)
+		if err != nil {
+			return // Incomplete; wait for more
+		}
+		w.buf = w.buf[fragments.HeaderSize+len(frag.Data):]
+
+		complete, blob, err := w.assembler.Add(frag)
+		if err != nil {
+			w.stopLocked()
+			return
+		}
+		if !complete {
+			continue
+		}
+		msg, err := messages.Decode(blob)
+		if err != nil {
+			w.stopLocked()
+			return
+		}
+		switch msg.Type {
+		case messages.MessageTypeSessionCapability:
+			if capability, err := parseSessionCapability(msg.Data); err == nil {
+				w.capability = capability
+			}
+			w.stopLocked()
+		case messages.MessageTypeRunspacePoolState:
+			w.stopLocked()
+		}
+	}
+}
+
+func (w *capabilityWatch) stopLocked() {
+	w.watching = false
+	w.buf = nil
+	w.assembler = nil
+}
+
+// parseSessionCapability parses the CLIXML of a SESSION_CAPABILITY message.
+func parseSessionCapability(data []byte) (*SessionCapability, error) {
+	objs, err := serialization.NewDeserializer().Deserialize(data)
+	if err != nil {
+		return nil, fmt.Errorf("powershell: deserialize session capability: %w", err)
+	}
+	if len(objs) == 0 {
+		return nil, errors.New("powershell: empty session capability")
+	}
+	obj, ok := objs[0].(*serialization.PSObject)
+	if !ok {
+		return nil, fmt.Errorf("powershell: session capability is %T, not an object", objs[0])
+	}
+
+	version := func(name string) string {
+		if v, ok := obj.Properties[name]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	capability := &SessionCapability{
+		ProtocolVersion:      version("protocolversion"),
+		PSVersion:            version("PSVersion"),
+		SerializationVersion: version("SerializationVersion"),
+	}
+	if capability.ProtocolVersion == "" {
+		return nil, errors.New("powershell: session capability has no protocol version")
+	}
+	return capability, nil
+}
+
+// watchReads returns t with what is read from it passed to observe.
+// Close and the MultiplexedTransport methods of t are kept.
+func watchReads(t io.ReadWriter, observe func([]byte)) io.ReadWriter {
+	watched := &watchedTransport{ReadWriter: t, observe: observe}
+	if mux, ok := t.(runspace.MultiplexedTransport); ok {
+		return &watchedMuxTransport{watchedTransport: watched, mux: mux}
+	}
+	return watched
+}
+
+// watchedTransport passes the reads of a RunspacePool's transport to a
+// watch.
+type watchedTransport struct {
+	io.ReadWriter
+	observe func([]byte)
+}
+
+func (t *watchedTransport) Read(p []byte) (int, error) {
+	n, err := t.ReadWriter.Read(p)
+	t.observe(p[:n])
+	return n, err
+}
+
+// Close closes the transport if it is an io.Closer.
+func (t *watchedTransport) Close() error {
+	if c, ok := t.ReadWriter.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// watchedMuxTransport is a watchedTransport for a MultiplexedTransport.
+type watchedMuxTransport struct {
+	*watchedTransport
+	mux runspace.MultiplexedTransport
+}
+
+func (t *watchedMuxTransport) SendCommand(id uuid.UUID) error {
+	return t.mux.SendCommand(id)
+}
+
+func (t *watchedMuxTransport) SendPipelineData(id uuid.UUID, data []byte) error {
+	return t.mux.SendPipelineData(id, data)
+}
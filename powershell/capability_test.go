@@ -0,0 +1,79 @@
+package powershell
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/messages"
+	"github.com/smnsjas/go-psrpcore/runspace"
+)
+
+const testCapabilityXML = `<Obj RefId="0"><MS>` +
+	`<Version N="protocolversion">2.2</Version><Version N="PSVersion">2.0</Version>` +
+	`<Version N="SerializationVersion">1.1.0.1</Version></MS></Obj>`
+
+func TestCapabilityWatch_Observe(t *testing.T) {
+	msg := messages.NewSessionCapability(uuid.New(), []byte(testCapabilityXML))
+	msg.Destination = messages.DestinationClient
+	encoded, err := msg.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	// Several fragments, read a byte at a time
+	data, err := fragmentMessage(1, encoded, 64)
+	if err != nil {
+		t.Fatalf("fragmentMessage() error = %v", err)
+	}
+
+	var w capabilityWatch
+	if _, ok := w.ServerCapability(); ok {
+		t.Fatal("ServerCapability() ok before a handshake")
+	}
+	w.start()
+	for i := range data {
+		w.observe(data[i : i+1])
+	}
+
+	got, ok := w.ServerCapability()
+	want := SessionCapability{ProtocolVersion: "2.2", PSVersion: "2.0", SerializationVersion: "1.1.0.1"}
+	if !ok || got != want {
+		t.Errorf("ServerCapability() = %+v, %v; want %+v", got, ok, want)
+	}
+
+	// A new handshake forgets the old capability
+	w.start()
+	if _, ok := w.ServerCapability(); ok {
+		t.Error("ServerCapability() ok after start")
+	}
+}
+
+func TestLoopbackBackend_ServerCapability(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	poolID := uuid.New()
+	b := NewLoopbackBackend(poolID)
+	if err := b.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	pool := runspace.New(b.Transport(), poolID)
+	if err := b.Init(ctx, pool); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	pool.StartDispatchLoop()
+	defer b.Close(ctx)
+
+	var reporter CapabilityReporter = b
+	got, ok := reporter.ServerCapability()
+	want := SessionCapability{ProtocolVersion: "2.3", PSVersion: "2.0", SerializationVersion: "1.1.0.1"}
+	if !ok || got != want {
+		t.Errorf("ServerCapability() = %+v, %v; want %+v", got, ok, want)
+	}
+
+	// The pipelines still run over the watched transport
+	if out, err := runLoopbackPipeline(ctx, t, b, pool, "Hello"); err != nil || len(out) != 1 {
+		t.Errorf("echo = %v, %v; want [Hello]", out, err)
+	}
+}
@@ -0,0 +1,295 @@
+package powershell
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/smnsjas/go-psrp/psquote"
+	"github.com/smnsjas/go-psrpcore/pipeline"
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// parameterNamePattern matches valid PowerShell parameter names.
+var parameterNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Command builds a PowerShell command pipeline from a command name,
+// parameters and arguments, without hand-escaping. It mirrors the .NET
+// PowerShell.AddCommand/AddParameter/AddArgument API:
+//
+//	cmd := powershell.NewCommand("Get-Process").
+//		AddParameter("Name", name).
+//		AddCommand("Select-Object").
+//		AddParameter("Property", []string{"Name", "Id"})
+//
+// AddTo sends the commands and values as PSRP command parameters, as .NET
+// does, so values are never parsed as code. Script renders the same
+// pipeline as script text: the command name and parameter names are
+// validated or quoted, strings, numbers, arrays and hashtables become typed
+// literals, and other values are sent as CLIXML and rehydrated with
+// PSSerializer, so they keep their types.
+type Command struct {
+	steps []commandStep
+	err   error
+}
+
+type commandStep struct {
+	name   string
+	params []commandParam
+}
+
+// commandParam is a named parameter, a switch (no value) or a positional argument (no name).
+type commandParam struct {
+	name     string
+	value    interface{}
+	hasValue bool
+}
+
+// NewCommand returns a command pipeline starting with the command name
+// (a cmdlet, function, alias or executable path).
+func NewCommand(name string) *Command {
+	return (&Command{}).AddCommand(name)
+}
+
+// AddCommand appends a command that receives the output of the previous one.
+func (c *Command) AddCommand(name string) *Command {
+	if name == "" && c.err == nil {
+		c.err = errors.New("powershell: command name is empty")
+	}
+	c.steps = append(c.steps, commandStep{name: name})
+	return c
+}
+
+// AddParameter adds a named parameter to the last command.
+func (c *Command) AddParameter(name string, value interface{}) *Command {
+	return c.addParam(commandParam{name: name, value: value, hasValue: true})
+}
+
+// AddSwitch adds a switch parameter (e.g., -Force) to the last command.
+func (c *Command) AddSwitch(name string) *Command {
+	return c.addParam(commandParam{name: name})
+}
+
+// AddArgument adds a positional argument to the last command.
+func (c *Command) AddArgument(value interface{}) *Command {
+	return c.addParam(commandParam{value: value, hasValue: true})
+}
+
+func (c *Command) addParam(p commandParam) *Command {
+	if p.name != "" && !parameterNamePattern.MatchString(p.name) && c.err == nil {
+		c.err = fmt.Errorf("powershell: invalid parameter name %q", p.name)
+	}
+	if len(c.steps) == 0 {
+		if c.err == nil {
+			c.err = errors.New("powershell: no command to add the parameter to")
+		}
+		return c
+	}
+	last := &c.steps[len(c.steps)-1]
+	last.params = append(last.params, p)
+	return c
+}
+
+// Err returns the first error recorded while building the command.
+func (c *Command) Err() error {
+	return c.err
+}
+
+// Script renders the command pipeline as a PowerShell script.
+func (c *Command) Script() (string, error) {
+	return c.ScriptWithConverter(nil)
+}
+
+// ScriptWithConverter renders the command pipeline, passing every parameter
+// value through convert first (if not nil). Client.Invoke uses the script
+// for logging and policies only, and sends the command with AddTo.
+func (c *Command) ScriptWithConverter(convert func(interface{}) interface{}) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	if len(c.steps) == 0 {
+		return "", errors.New("powershell: empty command")
+	}
+
+	var sb strings.Builder
+	for i, step := range c.steps {
+		if i > 0 {
+			sb.WriteString(" | ")
+		}
+		sb.WriteString("& ")
+		sb.WriteString(QuoteString(step.name))
+
+		for _, p := range step.params {
+			sb.WriteByte(' ')
+			if p.name != "" {
+				sb.WriteByte('-')
+				sb.WriteString(p.name)
+				if !p.hasValue {
+					continue
+				}
+				sb.WriteByte(':')
+			}
+			value := p.value
+			if convert != nil {
+				value = convert(value)
+			}
+			literal, err := valueLiteral(value)
+			if err != nil {
+				if p.name == "" {
+					return "", fmt.Errorf("powershell: %s argument: %w", step.name, err)
+				}
+				return "", fmt.Errorf("powershell: %s parameter %s: %w", step.name, p.name, err)
+			}
+			sb.WriteString(literal)
+		}
+	}
+	return sb.String(), nil
+}
+
+// AddTo adds the command pipeline to p, a pipeline created with
+// runspace.Pool.CreatePipelineBuilder, so parameters and arguments are sent
+// as PSRP command parameters rather than as script text. Every value is
+// passed through convert first (if not nil); switches are sent as $true.
+// Nothing is added if an error is returned.
+func (c *Command) AddTo(p *pipeline.Pipeline, convert func(interface{}) interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	if len(c.steps) == 0 {
+		return errors.New("powershell: empty command")
+	}
+
+	// Convert everything first, so a bad value leaves p untouched
+	values := make([][]interface{}, len(c.steps))
+	for i, step := range c.steps {
+		values[i] = make([]interface{}, len(step.params))
+		for j, param := range step.params {
+			if !param.hasValue {
+				values[i][j] = true
+				continue
+			}
+			value := param.value
+			if convert != nil {
+				value = convert(value)
+			}
+			// go-psrpcore drops positional arguments without a value
+			if value == nil && param.name == "" {
+				return fmt.Errorf("powershell: %s argument: $null can only be sent as a named parameter", step.name)
+			}
+			values[i][j] = value
+		}
+	}
+
+	for i, step := range c.steps {
+		p.AddCommand(step.name, false)
+		for j, param := range step.params {
+			if param.name == "" {
+				p.AddArgument(values[i][j])
+			} else {
+				p.AddParameter(param.name, values[i][j])
+			}
+		}
+	}
+	return nil
+}
+
+// QuoteString returns s as a single-quoted PowerShell string literal.
+// It is psquote.Quote, kept for existing callers.
+func QuoteString(s string) string {
+	return psquote.Quote(s)
+}
+
+// valueLiteral renders a parameter value. Strings, booleans, numbers, arrays
+// and string-keyed maps become literals; anything else is serialized to CLIXML.
+func valueLiteral(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "$null", nil
+	case string:
+		return QuoteString(val), nil
+	case bool:
+		if val {
+			return "$true", nil
+		}
+		return "$false", nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return castLiteral(rv, strconv.FormatInt(rv.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return castLiteral(rv, strconv.FormatUint(rv.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return castLiteral(rv, strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case reflect.Slice, reflect.Array:
+		// Byte arrays are serialized natively
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		items := make([]string, rv.Len())
+		for i := range items {
+			item, err := valueLiteral(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		return "@(" + strings.Join(items, ", ") + ")", nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			item, err := valueLiteral(rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())).Interface())
+			if err != nil {
+				return "", err
+			}
+			entries[i] = QuoteString(k) + " = " + item
+		}
+		return "@{" + strings.Join(entries, "; ") + "}", nil
+	}
+	return clixmlLiteral(v)
+}
+
+// castLiteral casts a number to its .NET type so the parameter binder sees
+// the same type as the Go value.
+func castLiteral(rv reflect.Value, digits string) (string, error) {
+	// Named numeric types (e.g., enums, time.Duration) go through CLIXML
+	if rv.Type().PkgPath() != "" {
+		return clixmlLiteral(rv.Interface())
+	}
+	netType := map[reflect.Kind]string{
+		reflect.Int: "long", reflect.Int8: "sbyte", reflect.Int16: "short", reflect.Int32: "int", reflect.Int64: "long",
+		reflect.Uint: "ulong", reflect.Uint8: "byte", reflect.Uint16: "ushort", reflect.Uint32: "uint", reflect.Uint64: "ulong",
+		reflect.Float32: "float", reflect.Float64: "double",
+	}[rv.Kind()]
+	// Parenthesized: in argument mode a bare [type] is read as text
+	return "([" + netType + "]" + QuoteString(digits) + ")", nil
+}
+
+// clixmlLiteral serializes v to CLIXML and rehydrates it on the server.
+func clixmlLiteral(v interface{}) (string, error) {
+	data, err := serialization.NewSerializer().Serialize(v)
+	if err != nil {
+		return "", fmt.Errorf("serialize %T: %w", v, err)
+	}
+	xml := string(data)
+	if !strings.HasPrefix(xml, "<Objs") {
+		xml = `<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04">` + xml + `</Objs>`
+	}
+	return "([System.Management.Automation.PSSerializer]::Deserialize(" + QuoteString(xml) + "))", nil
+}
@@ -0,0 +1,190 @@
+package powershell
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/pipeline"
+	"github.com/smnsjas/go-psrpcore/runspace"
+)
+
+func TestCommand_Script(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  *Command
+		want string
+	}{
+		{
+			name: "parameters",
+			cmd:  NewCommand("Get-Process").AddParameter("Name", "pwsh").AddSwitch("IncludeUserName"),
+			want: `& 'Get-Process' -Name:'pwsh' -IncludeUserName`,
+		},
+		{
+			name: "arguments",
+			cmd:  NewCommand("Write-Output").AddArgument("a").AddArgument(int32(-5)).AddArgument(nil),
+			want: `& 'Write-Output' 'a' ([int]'-5') $null`,
+		},
+		{
+			name: "pipeline",
+			cmd: NewCommand("Get-Service").
+				AddCommand("Select-Object").AddParameter("First", 2).AddParameter("Property", []string{"Name", "Status"}),
+			want: `& 'Get-Service' | & 'Select-Object' -First:([long]'2') -Property:@('Name', 'Status')`,
+		},
+		{
+			name: "booleans and hashtables",
+			cmd: NewCommand("Set-Thing").AddParameter("Enabled", false).
+				AddParameter("Tags", map[string]interface{}{"b": 1.5, "a": true}),
+			want: `& 'Set-Thing' -Enabled:$false -Tags:@{'a' = $true; 'b' = ([double]'1.5')}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.Script()
+			if err != nil {
+				t.Fatalf("Script() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Script() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommand_ScriptInjection(t *testing.T) {
+	hostile := []string{
+		`x'; Remove-Item C:\ -Recurse; '`,
+		"x’; Stop-Computer; ‘",
+		"$(Stop-Computer)",
+		"x\n; Stop-Computer",
+	}
+	for _, value := range hostile {
+		got, err := NewCommand("Get-Item").AddParameter("Path", value).Script()
+		if err != nil {
+			t.Fatalf("Script() error = %v", err)
+		}
+		literal := strings.TrimPrefix(got, `& 'Get-Item' -Path:`)
+		if unquoted := unquoteString(t, literal); unquoted != value {
+			t.Errorf("value %q rendered as %s, which reads back as %q", value, literal, unquoted)
+		}
+	}
+}
+
+// unquoteString parses a single-quoted PowerShell literal, failing if it ends
+// before the last character.
+func unquoteString(t *testing.T, literal string) string {
+	t.Helper()
+	runes := []rune(literal)
+	isQuote := func(r rune) bool { return strings.ContainsRune("'‘’‚‛", r) }
+	if len(runes) < 2 || !isQuote(runes[0]) || !isQuote(runes[len(runes)-1]) {
+		t.Fatalf("not a quoted literal: %s", literal)
+	}
+	var sb strings.Builder
+	for i := 1; i < len(runes)-1; i++ {
+		if isQuote(runes[i]) {
+			if i+1 >= len(runes)-1 || !isQuote(runes[i+1]) {
+				t.Fatalf("literal %s terminates early at %d", literal, i)
+			}
+			i++
+		}
+		sb.WriteRune(runes[i])
+	}
+	return sb.String()
+}
+
+func TestCommand_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  *Command
+	}{
+		{"empty name", NewCommand("")},
+		{"bad parameter name", NewCommand("Get-Item").AddParameter("Path; rm", "x")},
+		{"parameter before command", (&Command{}).AddSwitch("Force")},
+		{"no command", &Command{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cmd.Script(); err == nil {
+				t.Error("Script() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestCommand_AddTo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	poolID := uuid.New()
+	b := NewLoopbackBackend(poolID)
+	var got []LoopbackCommand
+	b.Handle("Get-Item", func(_ context.Context, p *LoopbackPipeline) error {
+		got = p.Commands
+		return nil
+	})
+	if err := b.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	pool := runspace.New(b.Transport(), poolID)
+	if err := b.Init(ctx, pool); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	pool.StartDispatchLoop()
+	defer b.Close(ctx)
+
+	hostile := `x'; Remove-Item C:\ -Recurse; '`
+	cmd := NewCommand("Get-Item").AddParameter("Path", hostile).AddSwitch("Force").
+		AddCommand("Select-Object").AddArgument("Name")
+	p, err := pool.CreatePipelineBuilder()
+	if err != nil {
+		t.Fatalf("CreatePipelineBuilder() error = %v", err)
+	}
+	if err := cmd.AddTo(p, nil); err != nil {
+		t.Fatalf("AddTo() error = %v", err)
+	}
+	if _, err := invokeLoopbackPipeline(ctx, t, b, p); err != nil {
+		t.Fatalf("pipeline error = %v", err)
+	}
+
+	want := []LoopbackCommand{
+		{Name: "Get-Item", Parameters: []LoopbackParameter{{Name: "Path", Value: hostile}, {Name: "Force", Value: true}}},
+		{Name: "Select-Object", Parameters: []LoopbackParameter{{Value: "Name"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Commands = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestCommand_AddTo_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  *Command
+	}{
+		{"bad parameter name", NewCommand("Get-Item").AddParameter("Path; rm", "x")},
+		{"no command", &Command{}},
+		{"positional null", NewCommand("Write-Output").AddArgument("a").AddArgument(nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := pipeline.NewBuilder(nil, uuid.New())
+			if err := tt.cmd.AddTo(p, nil); err == nil {
+				t.Error("AddTo() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	if got, want := QuoteString("it's"), `'it''s'`; got != want {
+		t.Errorf("QuoteString() = %s, want %s", got, want)
+	}
+	if got, want := QuoteString("a‘b"), "'a‘‘b'"; got != want {
+		t.Errorf("QuoteString() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,143 @@
+package powershell
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Compressor compresses the PSRP payload of OutOfProc Data packets on
+// channels where bandwidth or latency, not CPU, limits throughput, such as
+// PowerShell Direct to a busy host. It is only used if the server agrees to
+// it; stock PowerShell does not, and the channel then stays uncompressed.
+type Compressor interface {
+	// Name identifies the algorithm in the negotiation, e.g. "deflate".
+	Name() string
+	Compress(p []byte) ([]byte, error)
+	Decompress(p []byte) ([]byte, error)
+}
+
+// DeflateCompressor is a Compressor using DEFLATE (RFC 1951). CLIXML
+// usually compresses to a tenth of its size or less.
+type DeflateCompressor struct {
+	// Level is the compression level; 0 uses flate.BestSpeed.
+	Level int
+}
+
+// Name returns "deflate".
+func (DeflateCompressor) Name() string { return "deflate" }
+
+// Compress compresses p.
+func (c DeflateCompressor) Compress(p []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = flate.BestSpeed
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(p); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress decompresses p.
+func (DeflateCompressor) Decompress(p []byte) ([]byte, error) {
+	return io.ReadAll(flate.NewReader(bytes.NewReader(p)))
+}
+
+// compressionProbeGUID is the pipeline GUID of the compression probe. The
+// client sends a Signal for it: a server that supports compression answers
+// with a Data packet for the GUID listing its algorithms, comma-separated,
+// before the SignalAck. PowerShell just acknowledges the Signal, as it does
+// for any unknown pipeline.
+var compressionProbeGUID = uuid.MustParse("6c0d3b5e-2f4a-4d8e-9a41-7f3c2b1e0d95")
+
+// Frame headers of Data payloads once compression is negotiated.
+const (
+	frameRaw        byte = 0
+	frameCompressed byte = 1
+)
+
+// minCompressSize is the smallest payload worth compressing.
+const minCompressSize = 1024
+
+// negotiateCompression probes the server and, if it supports one of
+// offered, enables it for all Data packets in both directions. It returns
+// the chosen compressor, or nil if the server supports none. It must run
+// before any other traffic.
+func (a *hvOutOfProcAdapter) negotiateCompression(ctx context.Context, offered []Compressor) (Compressor, error) {
+	if len(offered) == 0 {
+		return nil, nil
+	}
+	if err := a.Signal(ctx, compressionProbeGUID); err != nil {
+		return nil, fmt.Errorf("compression probe: %w", err)
+	}
+
+	a.readMu.Lock()
+	supported := a.probeReply
+	a.readMu.Unlock()
+	if supported == "" {
+		return nil, nil
+	}
+	for _, c := range offered {
+		for _, name := range strings.Split(supported, ",") {
+			if strings.EqualFold(strings.TrimSpace(name), c.Name()) {
+				a.compressor.Store(&c)
+				return c, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// encodeFrame returns the payload to send for p.
+func (a *hvOutOfProcAdapter) encodeFrame(p []byte) ([]byte, error) {
+	c := a.compressor.Load()
+	if c == nil {
+		return p, nil
+	}
+	if len(p) >= minCompressSize {
+		compressed, err := (*c).Compress(p)
+		if err != nil {
+			return nil, fmt.Errorf("compress: %w", err)
+		}
+		if len(compressed) < len(p) {
+			return append([]byte{frameCompressed}, compressed...), nil
+		}
+	}
+	return append([]byte{frameRaw}, p...), nil
+}
+
+// decodeFrame returns the PSRP data of a received payload.
+func (a *hvOutOfProcAdapter) decodeFrame(p []byte) ([]byte, error) {
+	c := a.compressor.Load()
+	if c == nil {
+		return p, nil
+	}
+	if len(p) == 0 {
+		return nil, fmt.Errorf("decompress: empty frame")
+	}
+	switch p[0] {
+	case frameRaw:
+		return p[1:], nil
+	case frameCompressed:
+		data, err := (*c).Decompress(p[1:])
+		if err != nil {
+			return nil, fmt.Errorf("decompress: %w", err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("decompress: unknown frame type %d", p[0])
+}
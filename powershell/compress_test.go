@@ -0,0 +1,129 @@
+package powershell
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/outofproc"
+)
+
+func TestDeflateCompressor_RoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("<Obj RefId=\"0\"><MS><S N=\"Name\">svchost</S></MS></Obj>", 200))
+	c := DeflateCompressor{}
+
+	compressed, err := c.Compress(data)
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	if len(compressed) >= len(data)/10 {
+		t.Errorf("Compress() = %d bytes from %d, want under a tenth", len(compressed), len(data))
+	}
+	got, err := c.Decompress(compressed)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Decompress() = %d bytes, %v; want the original %d bytes", len(got), err, len(data))
+	}
+}
+
+// probeServer answers the compression probe with reply (none if empty),
+// then acknowledges it and returns the server transport.
+func probeServer(t *testing.T, conn net.Conn, reply string) <-chan *outofproc.Transport {
+	t.Helper()
+	ready := make(chan *outofproc.Transport, 1)
+	server := outofproc.NewTransportFromReadWriter(conn)
+	go func() {
+		packet, err := server.ReceivePacket()
+		if err != nil || packet.Type != outofproc.PacketTypeSignal || packet.PSGuid != compressionProbeGUID {
+			close(ready)
+			return
+		}
+		if reply != "" {
+			_ = server.SendData(compressionProbeGUID, []byte(reply))
+			_, _ = server.ReceivePacket() // DataAck
+		}
+		_ = server.SendSignalAck(packet.PSGuid)
+		ready <- server
+	}()
+	return ready
+}
+
+func TestNegotiateCompression(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	a := newHvOutOfProcAdapter(outofproc.NewTransportFromReadWriter(clientConn), uuid.New(), time.Second)
+	defer a.Close()
+	defer clientConn.Close()
+
+	ready := probeServer(t, serverConn, "zstd, deflate")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := a.negotiateCompression(ctx, []Compressor{DeflateCompressor{}})
+	if err != nil || c == nil || c.Name() != "deflate" {
+		t.Fatalf("negotiateCompression() = %v, %v; want deflate", c, err)
+	}
+	server := <-ready
+
+	// Client to server: large payloads are compressed
+	payload := []byte(strings.Repeat("CLIXML ", 1000))
+	go func() { _, _ = a.Write(payload) }()
+	packet, err := server.ReceivePacket()
+	if err != nil {
+		t.Fatalf("server ReceivePacket() error = %v", err)
+	}
+	if len(packet.Data) == 0 || packet.Data[0] != frameCompressed {
+		t.Fatalf("server received an uncompressed frame of %d bytes", len(packet.Data))
+	}
+	if got, err := c.Decompress(packet.Data[1:]); err != nil || !bytes.Equal(got, payload) {
+		t.Errorf("server decompressed %d bytes, %v", len(got), err)
+	}
+
+	// Server to client: frames are decoded before Read
+	compressed, _ := c.Compress(payload)
+	go func() {
+		_ = server.SendData(outofproc.NullGUID, append([]byte{frameCompressed}, compressed...))
+		_, _ = server.ReceivePacket() // DataAck
+	}()
+	got := make([]byte, 0, len(payload))
+	buf := make([]byte, 4096)
+	for len(got) < len(payload) {
+		n, err := a.Read(buf)
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Error("Read() did not return the decompressed payload")
+	}
+}
+
+func TestNegotiateCompression_UnsupportedServer(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	a := newHvOutOfProcAdapter(outofproc.NewTransportFromReadWriter(clientConn), uuid.New(), time.Second)
+	defer a.Close()
+	defer clientConn.Close()
+
+	// Like PowerShell, the server only acknowledges the probe
+	ready := probeServer(t, serverConn, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if c, err := a.negotiateCompression(ctx, []Compressor{DeflateCompressor{}}); err != nil || c != nil {
+		t.Fatalf("negotiateCompression() = %v, %v; want none", c, err)
+	}
+	server := <-ready
+
+	payload := []byte(strings.Repeat("CLIXML ", 1000))
+	go func() { _, _ = a.Write(payload) }()
+	packet, err := server.ReceivePacket()
+	if err != nil {
+		t.Fatalf("server ReceivePacket() error = %v", err)
+	}
+	if !bytes.Equal(packet.Data, payload) {
+		t.Error("server did not receive the payload unchanged")
+	}
+}
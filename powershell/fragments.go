@@ -0,0 +1,86 @@
+package powershell
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/smnsjas/go-psrpcore/fragments"
+	"github.com/smnsjas/go-psrpcore/messages"
+)
+
+// maxFragmentBlob is the fragment blob size for messages built or rewritten
+// by this package.
+const maxFragmentBlob = 32 * 1024
+
+// rewriteMessages defragments data produced by go-psrpcore (handshake or
+// CreatePipeline fragments), passes each message to rewrite and fragments the
+// messages again with their original object IDs. rewrite reports whether it
+// changed the message; found is true if any message was changed.
+func rewriteMessages(data []byte, rewrite func(msg *messages.Message) bool) (out []byte, found bool, err error) {
+	var (
+		first *fragments.Fragment
+		blob  []byte
+	)
+	for rest := data; len(rest) > 0; {
+		frag, err := fragments.Decode(rest)
+		if err != nil {
+			return nil, false, fmt.Errorf("powershell: decode fragment: %w", err)
+		}
+		rest = rest[fragments.HeaderSize+len(frag.Data):]
+
+		if frag.Start {
+			first, blob = frag, nil
+		}
+		if first == nil {
+			return nil, false, errors.New("powershell: fragment without start")
+		}
+		blob = append(blob, frag.Data...)
+		if !frag.End {
+			continue
+		}
+
+		msg, err := messages.Decode(blob)
+		if err != nil {
+			return nil, false, fmt.Errorf("powershell: decode message: %w", err)
+		}
+		if rewrite(msg) {
+			found = true
+		}
+		encoded, err := msg.Encode()
+		if err != nil {
+			return nil, false, fmt.Errorf("powershell: encode message: %w", err)
+		}
+		frags, err := fragmentMessage(first.ObjectID, encoded, max(len(first.Data), maxFragmentBlob))
+		if err != nil {
+			return nil, false, err
+		}
+		out = append(out, frags...)
+		first = nil
+	}
+	if first != nil {
+		return nil, false, errors.New("powershell: incomplete message")
+	}
+	return out, found, nil
+}
+
+// fragmentMessage splits an encoded message into fragments of at most size bytes.
+func fragmentMessage(objectID uint64, msg []byte, size int) ([]byte, error) {
+	var out []byte
+	for i := 0; i == 0 || len(msg) > 0; i++ {
+		n := min(size, len(msg))
+		frag := &fragments.Fragment{
+			ObjectID:   objectID,
+			FragmentID: uint64(i), // #nosec G115 -- i is non-negative
+			Start:      i == 0,
+			End:        n == len(msg),
+			Data:       msg[:n],
+		}
+		encoded, err := frag.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("powershell: encode fragment: %w", err)
+		}
+		out = append(out, encoded...)
+		msg = msg[n:]
+	}
+	return out, nil
+}
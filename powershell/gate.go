@@ -0,0 +1,86 @@
+package powershell
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrShellAbandoned is returned to pipelines that were waiting for a
+// disconnected shell when the client gave it up without reconnecting.
+var ErrShellAbandoned = errors.New("disconnected shell was abandoned")
+
+// connGate holds pipeline I/O while the shell is disconnected. The backend
+// suspends it before disconnecting and resumes it after a reconnect, so the
+// transports of running pipelines wait instead of failing.
+type connGate struct {
+	mu      sync.Mutex
+	resumed chan struct{} // nil while connected; closed on resume
+	err     error         // set when the shell is abandoned; final
+}
+
+func newConnGate() *connGate {
+	return &connGate{}
+}
+
+// suspend makes wait block until resume or abandon.
+func (g *connGate) suspend() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		g.resumed = make(chan struct{})
+	}
+}
+
+// resume releases waiting pipelines.
+func (g *connGate) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		close(g.resumed)
+		g.resumed = nil
+	}
+}
+
+// abandon fails waiting and later I/O with ErrShellAbandoned. It is final:
+// a backend that goes on with a new shell uses a new gate.
+func (g *connGate) abandon() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.err = ErrShellAbandoned
+	if g.resumed != nil {
+		close(g.resumed)
+		g.resumed = nil
+	}
+}
+
+// suspended reports whether the shell is disconnected.
+func (g *connGate) suspended() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumed != nil
+}
+
+// wait blocks while the shell is disconnected. A nil gate never blocks.
+func (g *connGate) wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	ch, err := g.resumed, g.err
+	g.mu.Unlock()
+	if ch == nil {
+		return err
+	}
+	select {
+	case <-ch:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,566 @@
+package powershell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// PSRP host call message types (MS-PSRP 2.2.1).
+const (
+	MessageTypeRunspacePoolHostCall     = 0x00021100
+	MessageTypeRunspacePoolHostResponse = 0x00021101
+	MessageTypePipelineHostCall         = 0x00041100
+	MessageTypePipelineHostResponse     = 0x00041101
+)
+
+// ErrHostMethodNotSupported is returned in the host response for host
+// methods that the client does not implement.
+var ErrHostMethodNotSupported = errors.New("powershell: host method not supported")
+
+// ErrSecureStringNotSupported is returned in the host response for prompts
+// that must answer with a SecureString (Read-Host -AsSecureString,
+// Get-Credential). Those values are encrypted with a session key, which is
+// not negotiated.
+var ErrSecureStringNotSupported = errors.New("powershell: SecureString host responses are not supported")
+
+// HostMethodID identifies a remote host method (MS-PSRP 2.2.3.17).
+type HostMethodID int32
+
+// Host methods. The numbering follows MS-PSRP; the raw UI methods
+// (GetForegroundColor through GetRunspace) are not listed individually.
+const (
+	HostMethodGetName                          HostMethodID = 1
+	HostMethodGetVersion                       HostMethodID = 2
+	HostMethodGetInstanceID                    HostMethodID = 3
+	HostMethodGetCurrentCulture                HostMethodID = 4
+	HostMethodGetCurrentUICulture              HostMethodID = 5
+	HostMethodSetShouldExit                    HostMethodID = 6
+	HostMethodEnterNestedPrompt                HostMethodID = 7
+	HostMethodExitNestedPrompt                 HostMethodID = 8
+	HostMethodNotifyBeginApplication           HostMethodID = 9
+	HostMethodNotifyEndApplication             HostMethodID = 10
+	HostMethodReadLine                         HostMethodID = 11
+	HostMethodReadLineAsSecureString           HostMethodID = 12
+	HostMethodWrite1                           HostMethodID = 13
+	HostMethodWrite2                           HostMethodID = 14
+	HostMethodWriteLine1                       HostMethodID = 15
+	HostMethodWriteLine2                       HostMethodID = 16
+	HostMethodWriteLine3                       HostMethodID = 17
+	HostMethodWriteErrorLine                   HostMethodID = 18
+	HostMethodWriteDebugLine                   HostMethodID = 19
+	HostMethodWriteProgress                    HostMethodID = 20
+	HostMethodWriteVerboseLine                 HostMethodID = 21
+	HostMethodWriteWarningLine                 HostMethodID = 22
+	HostMethodPrompt                           HostMethodID = 23
+	HostMethodPromptForCredential1             HostMethodID = 24
+	HostMethodPromptForCredential2             HostMethodID = 25
+	HostMethodPromptForChoice                  HostMethodID = 26
+	HostMethodPromptForChoiceMultipleSelection HostMethodID = 56
+)
+
+var hostMethodNames = map[HostMethodID]string{
+	HostMethodGetName: "GetName", HostMethodGetVersion: "GetVersion", HostMethodGetInstanceID: "GetInstanceId",
+	HostMethodGetCurrentCulture: "GetCurrentCulture", HostMethodGetCurrentUICulture: "GetCurrentUICulture",
+	HostMethodSetShouldExit: "SetShouldExit", HostMethodEnterNestedPrompt: "EnterNestedPrompt",
+	HostMethodExitNestedPrompt: "ExitNestedPrompt", HostMethodNotifyBeginApplication: "NotifyBeginApplication",
+	HostMethodNotifyEndApplication: "NotifyEndApplication", HostMethodReadLine: "ReadLine",
+	HostMethodReadLineAsSecureString: "ReadLineAsSecureString", HostMethodWrite1: "Write1", HostMethodWrite2: "Write2",
+	HostMethodWriteLine1: "WriteLine1", HostMethodWriteLine2: "WriteLine2", HostMethodWriteLine3: "WriteLine3",
+	HostMethodWriteErrorLine: "WriteErrorLine", HostMethodWriteDebugLine: "WriteDebugLine",
+	HostMethodWriteProgress: "WriteProgress", HostMethodWriteVerboseLine: "WriteVerboseLine",
+	HostMethodWriteWarningLine: "WriteWarningLine", HostMethodPrompt: "Prompt",
+	HostMethodPromptForCredential1: "PromptForCredential1", HostMethodPromptForCredential2: "PromptForCredential2",
+	HostMethodPromptForChoice: "PromptForChoice", HostMethodPromptForChoiceMultipleSelection: "PromptForChoiceMultipleSelection",
+}
+
+// String returns the MS-PSRP name of the method.
+func (m HostMethodID) String() string {
+	if name, ok := hostMethodNames[m]; ok {
+		return name
+	}
+	return "HostMethod" + strconv.Itoa(int(m))
+}
+
+// hasResult reports whether the server waits for a response to the method.
+// Void methods (writes, notifications, setters) get no response.
+func (m HostMethodID) hasResult() bool {
+	switch m {
+	case HostMethodGetName, HostMethodGetVersion, HostMethodGetInstanceID,
+		HostMethodGetCurrentCulture, HostMethodGetCurrentUICulture,
+		HostMethodReadLine, HostMethodReadLineAsSecureString, HostMethodPrompt,
+		HostMethodPromptForCredential1, HostMethodPromptForCredential2,
+		HostMethodPromptForChoice, HostMethodPromptForChoiceMultipleSelection:
+		return true
+	}
+	// Raw UI getters: GetForegroundColor (27) through GetRunspace (55)
+	switch m {
+	case 27, 29, 31, 33, 35, 37, 39, 41, 43, 44, 45, 46, 50, 54, 55:
+		return true
+	}
+	return false
+}
+
+// HostOutput identifies the host method that produced text passed to
+// HostInterface.Write.
+type HostOutput int
+
+const (
+	// HostOutputDefault is Write-Host and other console output.
+	HostOutputDefault HostOutput = iota
+	// HostOutputError is WriteErrorLine.
+	HostOutputError
+	// HostOutputWarning is WriteWarningLine.
+	HostOutputWarning
+	// HostOutputVerbose is WriteVerboseLine.
+	HostOutputVerbose
+	// HostOutputDebug is WriteDebugLine.
+	HostOutputDebug
+)
+
+// FieldDescription describes a field requested by HostInterface.Prompt,
+// such as a missing mandatory parameter.
+type FieldDescription struct {
+	Name          string
+	Label         string
+	ParameterType string // Full .NET type name, e.g. System.String
+	HelpMessage   string
+	IsMandatory   bool
+	DefaultValue  interface{}
+}
+
+// ChoiceDescription is one option offered by HostInterface.PromptForChoice.
+// The label marks the hotkey with an ampersand (e.g., "&Yes").
+type ChoiceDescription struct {
+	Label       string
+	HelpMessage string
+}
+
+// HostInterface answers host calls from a remote script: Read-Host, prompts
+// for missing mandatory parameters, -Confirm and ShouldContinue choices, and
+// Write-Host output. Implementations may prompt a terminal or answer
+// programmatically. Errors are returned to the script as a host exception.
+type HostInterface interface {
+	// ReadLine returns a line of input (Read-Host).
+	ReadLine(ctx context.Context) (string, error)
+
+	// Write receives text written to the host. WriteLine variants include
+	// the trailing newline.
+	Write(ctx context.Context, kind HostOutput, text string)
+
+	// Prompt returns a value for each field, keyed by field name.
+	Prompt(ctx context.Context, caption, message string, fields []FieldDescription) (map[string]interface{}, error)
+
+	// PromptForChoice returns the index of the selected choice.
+	// defaultChoice is -1 if there is no default.
+	PromptForChoice(ctx context.Context, caption, message string, choices []ChoiceDescription, defaultChoice int) (int, error)
+}
+
+// HostCall is a decoded RUNSPACEPOOL_HOST_CALL or PIPELINE_HOST_CALL message.
+type HostCall struct {
+	CallID int64
+	Method HostMethodID
+	Params []interface{}
+}
+
+// DecodeHostCall decodes the CLIXML data of a host call message.
+func DecodeHostCall(data []byte) (*HostCall, error) {
+	objs, err := serialization.NewDeserializer().Deserialize(data)
+	if err != nil {
+		return nil, fmt.Errorf("powershell: decode host call: %w", err)
+	}
+	if len(objs) != 1 {
+		return nil, fmt.Errorf("powershell: decode host call: %d objects, want 1", len(objs))
+	}
+	obj, ok := objs[0].(*serialization.PSObject)
+	if !ok {
+		return nil, fmt.Errorf("powershell: decode host call: unexpected %T", objs[0])
+	}
+
+	callID, ok := hostInt(obj.Properties["ci"])
+	if !ok {
+		return nil, errors.New("powershell: decode host call: missing call ID")
+	}
+	method, ok := hostInt(obj.Properties["mi"])
+	if !ok {
+		return nil, errors.New("powershell: decode host call: missing method ID")
+	}
+	return &HostCall{
+		CallID: callID,
+		Method: HostMethodID(method),
+		Params: hostList(obj.Properties["mp"]),
+	}, nil
+}
+
+// HandleHostCall runs call against host. It returns the response data to send
+// back, or nil if the method has no result.
+func HandleHostCall(ctx context.Context, host HostInterface, call *HostCall) []byte {
+	result, err := dispatchHostCall(ctx, host, call)
+	if !call.Method.hasResult() {
+		return nil
+	}
+	return encodeHostResponse(call, result, err)
+}
+
+// dispatchHostCall calls the HostInterface method for call.
+func dispatchHostCall(ctx context.Context, host HostInterface, call *HostCall) (clixmlValue, error) {
+	p := call.Params
+	switch call.Method {
+	case HostMethodReadLine:
+		line, err := host.ReadLine(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return clixmlString(line), nil
+
+	case HostMethodWrite1:
+		host.Write(ctx, HostOutputDefault, hostString(p, 0))
+	case HostMethodWrite2:
+		host.Write(ctx, HostOutputDefault, hostString(p, 2))
+	case HostMethodWriteLine1:
+		host.Write(ctx, HostOutputDefault, "\n")
+	case HostMethodWriteLine2:
+		host.Write(ctx, HostOutputDefault, hostString(p, 0)+"\n")
+	case HostMethodWriteLine3:
+		host.Write(ctx, HostOutputDefault, hostString(p, 2)+"\n")
+	case HostMethodWriteErrorLine:
+		host.Write(ctx, HostOutputError, hostString(p, 0)+"\n")
+	case HostMethodWriteWarningLine:
+		host.Write(ctx, HostOutputWarning, hostString(p, 0)+"\n")
+	case HostMethodWriteVerboseLine:
+		host.Write(ctx, HostOutputVerbose, hostString(p, 0)+"\n")
+	case HostMethodWriteDebugLine:
+		host.Write(ctx, HostOutputDebug, hostString(p, 0)+"\n")
+
+	case HostMethodPrompt:
+		fields := hostFields(hostParam(p, 2))
+		for _, f := range fields {
+			if strings.HasSuffix(f.ParameterType, "SecureString") || strings.HasSuffix(f.ParameterType, "PSCredential") {
+				return nil, ErrSecureStringNotSupported
+			}
+		}
+		values, err := host.Prompt(ctx, hostString(p, 0), hostString(p, 1), fields)
+		if err != nil {
+			return nil, err
+		}
+		return clixmlDictionary(values), nil
+
+	case HostMethodPromptForChoice:
+		def, ok := hostInt(hostParam(p, 3))
+		if !ok {
+			def = -1
+		}
+		choice, err := host.PromptForChoice(ctx, hostString(p, 0), hostString(p, 1), hostChoices(hostParam(p, 2)), int(def))
+		if err != nil {
+			return nil, err
+		}
+		return clixmlInt32(choice), nil
+
+	case HostMethodPromptForChoiceMultipleSelection:
+		// Offered as a single selection, with the first default preselected
+		def := int64(-1)
+		if defaults := hostList(hostParam(p, 3)); len(defaults) > 0 {
+			if n, ok := hostInt(defaults[0]); ok {
+				def = n
+			}
+		}
+		choice, err := host.PromptForChoice(ctx, hostString(p, 0), hostString(p, 1), hostChoices(hostParam(p, 2)), int(def))
+		if err != nil {
+			return nil, err
+		}
+		return clixmlIntCollection([]int{choice}), nil
+
+	case HostMethodReadLineAsSecureString, HostMethodPromptForCredential1, HostMethodPromptForCredential2:
+		return nil, ErrSecureStringNotSupported
+
+	default:
+		if call.Method.hasResult() {
+			return nil, fmt.Errorf("%w: %s", ErrHostMethodNotSupported, call.Method)
+		}
+	}
+	return nil, nil
+}
+
+// hostParam returns parameter i, or nil if absent.
+func hostParam(params []interface{}, i int) interface{} {
+	if i < len(params) {
+		return params[i]
+	}
+	return nil
+}
+
+// hostString returns parameter i as a string.
+func hostString(params []interface{}, i int) string {
+	switch v := hostParam(params, i).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *serialization.PSObject:
+		if s, ok := v.Value.(string); ok {
+			return s
+		}
+		return v.ToString
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// hostInt returns an integer value, unwrapping enums.
+func hostInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case *serialization.PSObject:
+		return hostInt(n.Value)
+	default:
+		return 0, false
+	}
+}
+
+// hostList returns a list value, unwrapping collection objects.
+func hostList(v interface{}) []interface{} {
+	switch l := v.(type) {
+	case []interface{}:
+		return l
+	case *serialization.PSObject:
+		return hostList(l.Value)
+	default:
+		return nil
+	}
+}
+
+// hostProps returns the properties of an object parameter.
+func hostProps(v interface{}) map[string]interface{} {
+	switch o := v.(type) {
+	case *serialization.PSObject:
+		return o.Properties
+	case map[string]interface{}:
+		return o
+	default:
+		return nil
+	}
+}
+
+func hostFields(v interface{}) []FieldDescription {
+	items := hostList(v)
+	fields := make([]FieldDescription, 0, len(items))
+	for _, item := range items {
+		props := hostProps(item)
+		mandatory, _ := props["isMandatory"].(bool)
+		fields = append(fields, FieldDescription{
+			Name:          hostString([]interface{}{props["name"]}, 0),
+			Label:         hostString([]interface{}{props["label"]}, 0),
+			ParameterType: hostString([]interface{}{props["parameterTypeFullName"]}, 0),
+			HelpMessage:   hostString([]interface{}{props["helpMessage"]}, 0),
+			IsMandatory:   mandatory,
+			DefaultValue:  props["defaultValue"],
+		})
+	}
+	return fields
+}
+
+func hostChoices(v interface{}) []ChoiceDescription {
+	items := hostList(v)
+	choices := make([]ChoiceDescription, 0, len(items))
+	for _, item := range items {
+		props := hostProps(item)
+		choices = append(choices, ChoiceDescription{
+			Label:       hostString([]interface{}{props["label"]}, 0),
+			HelpMessage: hostString([]interface{}{props["helpMessage"]}, 0),
+		})
+	}
+	return choices
+}
+
+// clixmlValue renders a host method result as a CLIXML element named name.
+type clixmlValue func(name string, refs *int) string
+
+// encodeHostResponse builds the data of a host response message (MS-PSRP
+// 2.2.2.28): the call ID, the method and either the result or an error record.
+func encodeHostResponse(call *HostCall, result clixmlValue, callErr error) []byte {
+	refs := 1
+	var sb strings.Builder
+	sb.WriteString(`<Obj RefId="0"><MS>`)
+	sb.WriteString(`<I64 N="ci">` + strconv.FormatInt(call.CallID, 10) + `</I64>`)
+	sb.WriteString(`<Obj N="mi" RefId="` + nextRef(&refs) + `"><TN RefId="` + nextRef(&refs) + `">` +
+		`<T>System.Management.Automation.Remoting.RemoteHostMethodId</T><T>System.Enum</T><T>System.ValueType</T><T>System.Object</T></TN>` +
+		`<ToString>` + call.Method.String() + `</ToString><I32>` + strconv.Itoa(int(call.Method)) + `</I32></Obj>`)
+	switch {
+	case callErr != nil:
+		sb.WriteString(clixmlErrorRecord("me", callErr.Error(), &refs))
+	case result != nil:
+		sb.WriteString(result("mr", &refs))
+	}
+	sb.WriteString(`</MS></Obj>`)
+	return []byte(sb.String())
+}
+
+func nextRef(refs *int) string {
+	ref := strconv.Itoa(*refs)
+	*refs++
+	return ref
+}
+
+func nameAttr(name string) string {
+	if name == "" {
+		return ""
+	}
+	return ` N="` + escapeCLIXML(name) + `"`
+}
+
+func clixmlString(s string) clixmlValue {
+	return func(name string, _ *int) string {
+		return `<S` + nameAttr(name) + `>` + escapeCLIXML(s) + `</S>`
+	}
+}
+
+func clixmlInt32(n int) clixmlValue {
+	return func(name string, _ *int) string {
+		return `<I32` + nameAttr(name) + `>` + strconv.Itoa(n) + `</I32>`
+	}
+}
+
+// clixmlIntCollection renders a Collection<int>.
+func clixmlIntCollection(items []int) clixmlValue {
+	return func(name string, refs *int) string {
+		var sb strings.Builder
+		sb.WriteString(`<Obj` + nameAttr(name) + ` RefId="` + nextRef(refs) + `"><TN RefId="` + nextRef(refs) + `">` +
+			"<T>System.Collections.ObjectModel.Collection`1[[System.Int32, mscorlib, Version=4.0.0.0, Culture=neutral, PublicKeyToken=b77a5c561934e089]]</T>" +
+			`<T>System.Object</T></TN><LST>`)
+		for _, n := range items {
+			sb.WriteString(`<I32>` + strconv.Itoa(n) + `</I32>`)
+		}
+		sb.WriteString(`</LST></Obj>`)
+		return sb.String()
+	}
+}
+
+// clixmlDictionary renders a Dictionary<string, PSObject>, the result of Prompt.
+func clixmlDictionary(values map[string]interface{}) clixmlValue {
+	return func(name string, refs *int) string {
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var sb strings.Builder
+		sb.WriteString(`<Obj` + nameAttr(name) + ` RefId="` + nextRef(refs) + `"><TN RefId="` + nextRef(refs) + `">` +
+			"<T>System.Collections.Generic.Dictionary`2[[System.String, mscorlib, Version=4.0.0.0, Culture=neutral, PublicKeyToken=b77a5c561934e089],[System.Management.Automation.PSObject, System.Management.Automation, Version=3.0.0.0, Culture=neutral, PublicKeyToken=31bf3856ad364e35]]</T>" +
+			`<T>System.Object</T></TN><DCT>`)
+		for _, k := range keys {
+			sb.WriteString(`<En>` + clixmlString(k)("Key", refs) + clixmlPrimitive(values[k])("Value", refs) + `</En>`)
+		}
+		sb.WriteString(`</DCT></Obj>`)
+		return sb.String()
+	}
+}
+
+// clixmlPrimitive renders a prompt answer. Types other than strings, booleans
+// and numbers are sent as their string form.
+func clixmlPrimitive(v interface{}) clixmlValue {
+	return func(name string, refs *int) string {
+		switch val := v.(type) {
+		case nil:
+			return `<Nil` + nameAttr(name) + ` />`
+		case bool:
+			return `<B` + nameAttr(name) + `>` + strconv.FormatBool(val) + `</B>`
+		case int:
+			return `<I32` + nameAttr(name) + `>` + strconv.Itoa(val) + `</I32>`
+		case int32:
+			return `<I32` + nameAttr(name) + `>` + strconv.FormatInt(int64(val), 10) + `</I32>`
+		case int64:
+			return `<I64` + nameAttr(name) + `>` + strconv.FormatInt(val, 10) + `</I64>`
+		case float64:
+			return `<Db` + nameAttr(name) + `>` + strconv.FormatFloat(val, 'G', -1, 64) + `</Db>`
+		case []string:
+			var sb strings.Builder
+			sb.WriteString(`<Obj` + nameAttr(name) + ` RefId="` + nextRef(refs) + `"><TN RefId="` + nextRef(refs) + `">` +
+				`<T>System.Object[]</T><T>System.Array</T><T>System.Object</T></TN><LST>`)
+			for _, s := range val {
+				sb.WriteString(clixmlString(s)("", refs))
+			}
+			sb.WriteString(`</LST></Obj>`)
+			return sb.String()
+		default:
+			return clixmlString(fmt.Sprint(val))(name, refs)
+		}
+	}
+}
+
+// clixmlErrorRecord renders a minimal ErrorRecord wrapping a HostException.
+func clixmlErrorRecord(name, message string, refs *int) string {
+	return clixmlErrorRecordOf(name, message, "HostCallFailed", 0, hostExceptionTypes, refs)
+}
+
+// hostExceptionTypes is the type hierarchy of HostException.
+var hostExceptionTypes = []string{
+	"System.Management.Automation.Host.HostException",
+	"System.Management.Automation.RuntimeException",
+	"System.SystemException",
+	"System.Exception",
+}
+
+// clixmlErrorRecordOf renders a minimal ErrorRecord. exceptionTypes is the
+// type hierarchy of its exception, most derived first, without
+// System.Object; category is the ErrorCategory value.
+func clixmlErrorRecordOf(name, message, errorID string, category int, exceptionTypes []string, refs *int) string {
+	msg := escapeCLIXML(message)
+	reason := exceptionTypes[0][strings.LastIndex(exceptionTypes[0], ".")+1:]
+	var types strings.Builder
+	for _, t := range exceptionTypes {
+		types.WriteString(`<T>` + t + `</T>`)
+	}
+	return `<Obj` + nameAttr(name) + ` RefId="` + nextRef(refs) + `"><TN RefId="` + nextRef(refs) + `">` +
+		`<T>System.Management.Automation.ErrorRecord</T><T>System.Object</T></TN><ToString>` + msg + `</ToString><MS>` +
+		`<Obj N="Exception" RefId="` + nextRef(refs) + `"><TN RefId="` + nextRef(refs) + `">` +
+		types.String() + `<T>System.Object</T></TN>` +
+		`<ToString>` + msg + `</ToString><Props><S N="Message">` + msg + `</S></Props></Obj>` +
+		`<Nil N="TargetObject" />` +
+		`<S N="FullyQualifiedErrorId">` + escapeCLIXML(errorID) + `</S>` +
+		`<Nil N="InvocationInfo" />` +
+		`<I32 N="ErrorCategory_Category">` + strconv.Itoa(category) + `</I32>` +
+		`<S N="ErrorCategory_Activity"></S>` +
+		`<S N="ErrorCategory_Reason">` + reason + `</S>` +
+		`<S N="ErrorCategory_TargetName"></S>` +
+		`<S N="ErrorCategory_TargetType"></S>` +
+		`<S N="ErrorCategory_Message">` + msg + `</S>` +
+		`<B N="SerializeExtendedInfo">false</B>` +
+		`</MS></Obj>`
+}
+
+// escapeCLIXML escapes text for a CLIXML element. Characters that XML cannot
+// carry are written as _xHHHH_, and a literal "_x" is escaped so it is not
+// read as such a sequence.
+func escapeCLIXML(s string) string {
+	var sb strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '&':
+			sb.WriteString("&amp;")
+		case r == '<':
+			sb.WriteString("&lt;")
+		case r == '>':
+			sb.WriteString("&gt;")
+		case r == '"':
+			sb.WriteString("&quot;")
+		case r == '_' && strings.HasPrefix(s[i+1:], "x"):
+			sb.WriteString("_x005F_")
+		case r < 0x20 && r != '\t' && r != '\n' && r != '\r', r == 0xFFFE, r == 0xFFFF:
+			fmt.Fprintf(&sb, "_x%04X_", r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,231 @@
+package powershell
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// recordingHost answers prompts from fixed values and records writes.
+type recordingHost struct {
+	line    string
+	values  map[string]interface{}
+	choice  int
+	err     error
+	written []string
+
+	gotFields  []FieldDescription
+	gotChoices []ChoiceDescription
+	gotDefault int
+}
+
+func (h *recordingHost) ReadLine(_ context.Context) (string, error) {
+	return h.line, h.err
+}
+
+func (h *recordingHost) Write(_ context.Context, _ HostOutput, text string) {
+	h.written = append(h.written, text)
+}
+
+func (h *recordingHost) Prompt(_ context.Context, _, _ string, fields []FieldDescription) (map[string]interface{}, error) {
+	h.gotFields = fields
+	return h.values, h.err
+}
+
+func (h *recordingHost) PromptForChoice(_ context.Context, _, _ string, choices []ChoiceDescription, defaultChoice int) (int, error) {
+	h.gotChoices = choices
+	h.gotDefault = defaultChoice
+	return h.choice, h.err
+}
+
+func TestHandleHostCall_ReadLine(t *testing.T) {
+	host := &recordingHost{line: "a<b & _x"}
+	resp := string(HandleHostCall(context.Background(), host, &HostCall{CallID: 7, Method: HostMethodReadLine}))
+
+	for _, want := range []string{
+		`<I64 N="ci">7</I64>`,
+		`<ToString>ReadLine</ToString><I32>11</I32>`,
+		`<S N="mr">a&lt;b &amp; _x005F_x</S>`,
+	} {
+		if !strings.Contains(resp, want) {
+			t.Errorf("response missing %s:\n%s", want, resp)
+		}
+	}
+}
+
+func TestHandleHostCall_Error(t *testing.T) {
+	host := &recordingHost{err: errors.New("no input available")}
+	resp := string(HandleHostCall(context.Background(), host, &HostCall{CallID: 1, Method: HostMethodReadLine}))
+
+	if strings.Contains(resp, `N="mr"`) {
+		t.Error("error response has a result")
+	}
+	if !strings.Contains(resp, `<Obj N="me"`) || !strings.Contains(resp, "no input available") {
+		t.Errorf("response missing error record:\n%s", resp)
+	}
+}
+
+func TestHandleHostCall_Write(t *testing.T) {
+	host := &recordingHost{}
+	calls := []*HostCall{
+		{Method: HostMethodWrite1, Params: []interface{}{"a"}},
+		{Method: HostMethodWriteLine2, Params: []interface{}{"b"}},
+		{Method: HostMethodWriteLine3, Params: []interface{}{int32(1), int32(0), "c"}},
+		{Method: HostMethodWriteWarningLine, Params: []interface{}{"d"}},
+	}
+	for _, call := range calls {
+		if resp := HandleHostCall(context.Background(), host, call); resp != nil {
+			t.Errorf("%s: got response %s, want none", call.Method, resp)
+		}
+	}
+	if got := strings.Join(host.written, ""); got != "ab\nc\nd\n" {
+		t.Errorf("written = %q", got)
+	}
+}
+
+func TestHandleHostCall_PromptForChoice(t *testing.T) {
+	host := &recordingHost{choice: 1}
+	choices := &serialization.PSObject{Value: []interface{}{
+		&serialization.PSObject{Properties: map[string]interface{}{"label": "&Yes", "helpMessage": "Continue"}},
+		&serialization.PSObject{Properties: map[string]interface{}{"label": "&No", "helpMessage": "Stop"}},
+	}}
+	call := &HostCall{CallID: 3, Method: HostMethodPromptForChoice, Params: []interface{}{"Confirm", "Are you sure?", choices, int32(0)}}
+
+	resp := string(HandleHostCall(context.Background(), host, call))
+	if !strings.Contains(resp, `<I32 N="mr">1</I32>`) {
+		t.Errorf("response missing choice:\n%s", resp)
+	}
+	if len(host.gotChoices) != 2 || host.gotChoices[1].Label != "&No" || host.gotDefault != 0 {
+		t.Errorf("choices = %+v, default = %d", host.gotChoices, host.gotDefault)
+	}
+}
+
+func TestHandleHostCall_Prompt(t *testing.T) {
+	host := &recordingHost{values: map[string]interface{}{"Name": "svc", "Count": 2}}
+	fields := []interface{}{
+		&serialization.PSObject{Properties: map[string]interface{}{
+			"name": "Name", "parameterTypeFullName": "System.String", "isMandatory": true,
+		}},
+	}
+	call := &HostCall{CallID: 4, Method: HostMethodPrompt, Params: []interface{}{"", "Supply values", fields}}
+
+	resp := string(HandleHostCall(context.Background(), host, call))
+	for _, want := range []string{
+		`<En><S N="Key">Count</S><I32 N="Value">2</I32></En>`,
+		`<En><S N="Key">Name</S><S N="Value">svc</S></En>`,
+	} {
+		if !strings.Contains(resp, want) {
+			t.Errorf("response missing %s:\n%s", want, resp)
+		}
+	}
+	if len(host.gotFields) != 1 || host.gotFields[0].Name != "Name" || !host.gotFields[0].IsMandatory {
+		t.Errorf("fields = %+v", host.gotFields)
+	}
+}
+
+func TestHandleHostCall_Unsupported(t *testing.T) {
+	host := &recordingHost{}
+	tests := []struct {
+		method HostMethodID
+		want   string
+	}{
+		{HostMethodPromptForCredential1, ErrSecureStringNotSupported.Error()},
+		{HostMethodReadLineAsSecureString, ErrSecureStringNotSupported.Error()},
+		{HostMethodGetCurrentCulture, ErrHostMethodNotSupported.Error()},
+	}
+	for _, tt := range tests {
+		resp := string(HandleHostCall(context.Background(), host, &HostCall{Method: tt.method}))
+		if !strings.Contains(resp, tt.want) {
+			t.Errorf("%s: response missing %q:\n%s", tt.method, tt.want, resp)
+		}
+	}
+
+	// Void methods are ignored without a response
+	if resp := HandleHostCall(context.Background(), host, &HostCall{Method: HostMethodSetShouldExit}); resp != nil {
+		t.Errorf("SetShouldExit: got response %s", resp)
+	}
+}
+
+func TestEnableHostFlags(t *testing.T) {
+	data := `<Obj N="HostInfo" RefId="3"><MS><B N="_isHostNull">true</B><B N="_isHostUINull">true</B>` +
+		`<B N="_isHostRawUINull">true</B><B N="_useRunspaceHost">true</B></MS></Obj>`
+
+	got, ok := enableHostFlags([]byte(data))
+	if !ok {
+		t.Fatal("enableHostFlags() found no HostInfo")
+	}
+	want := `<Obj N="HostInfo" RefId="3"><MS><B N="_isHostNull">false</B><B N="_isHostUINull">false</B>` +
+		`<B N="_isHostRawUINull">true</B><B N="_useRunspaceHost">false</B></MS></Obj>`
+	if string(got) != want {
+		t.Errorf("enableHostFlags() =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, ok := enableHostFlags([]byte("<Obj />")); ok {
+		t.Error("enableHostFlags() reported HostInfo in data without it")
+	}
+}
+
+func TestWithHostDefaultData(t *testing.T) {
+	data := `<Obj N="HostInfo" RefId="3"><MS><B N="_isHostNull">true</B><B N="_isHostUINull">true</B>` +
+		`<B N="_isHostRawUINull">true</B><B N="_useRunspaceHost">true</B></MS></Obj>`
+
+	info := HostInfo{
+		BufferSize:  Size{Width: 200, Height: 9000},
+		WindowSize:  Size{Width: 200, Height: 60},
+		WindowTitle: "ops <prod>",
+	}
+	got, ok := withHostDefaultData([]byte(data), info)
+	if !ok {
+		t.Fatal("withHostDefaultData() found no HostInfo")
+	}
+	s := string(got)
+	for _, want := range []string{
+		`<Obj N="HostInfo" RefId="3"><MS><Obj N="_hostDefaultData" RefId="2000">`,
+		`<B N="_isHostNull">false</B><B N="_isHostUINull">false</B><B N="_isHostRawUINull">false</B>`,
+		`<B N="_useRunspaceHost">true</B>`,
+		`<En><I32 N="Key">5</I32><Obj N="Value" RefId="`,
+		`<S N="T">System.Management.Automation.Host.Size</S>`,
+		`<I32 N="width">200</I32><I32 N="height">9000</I32>`,
+		`<S N="V">ops &lt;prod&gt;</S>`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("withHostDefaultData() = %s\nmissing %s", s, want)
+		}
+	}
+	// MaxWindowSize defaults to WindowSize
+	if n := strings.Count(s, `<I32 N="width">200</I32><I32 N="height">60</I32>`); n != 3 {
+		t.Errorf("window size appears %d times, want 3 (WindowSize, MaxWindowSize, MaxPhysicalWindowSize)", n)
+	}
+
+	// A nil placeholder is replaced rather than duplicated
+	withNil := strings.Replace(data, "<MS>", `<MS><Nil N="_hostDefaultData" />`, 1)
+	got, _ = withHostDefaultData([]byte(withNil), info)
+	if strings.Contains(string(got), `<Nil N="_hostDefaultData"`) || strings.Count(string(got), `N="_hostDefaultData"`) != 1 {
+		t.Errorf("withHostDefaultData() with nil placeholder = %s", got)
+	}
+
+	if _, ok := withHostDefaultData([]byte("<Obj />"), info); ok {
+		t.Error("withHostDefaultData() reported HostInfo in data without it")
+	}
+}
+
+func TestHostInfo_WithWidth(t *testing.T) {
+	info := DefaultHostInfo.WithWidth(250)
+	if info.BufferSize != (Size{Width: 250, Height: 3000}) || info.WindowSize != (Size{Width: 250, Height: 50}) {
+		t.Errorf("WithWidth(250) sizes = %v, %v", info.BufferSize, info.WindowSize)
+	}
+	if info.MaxWindowSize != (Size{}) {
+		t.Errorf("WithWidth() set unset MaxWindowSize to %v", info.MaxWindowSize)
+	}
+	if DefaultHostInfo.BufferSize.Width != 120 {
+		t.Error("WithWidth() modified DefaultHostInfo")
+	}
+
+	info = HostInfo{MaxWindowSize: Size{Width: 100, Height: 50}}.WithWidth(250)
+	if info.MaxWindowSize.Width != 250 {
+		t.Errorf("MaxWindowSize.Width = %d, want 250", info.MaxWindowSize.Width)
+	}
+}
@@ -0,0 +1,80 @@
+package powershell
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/smnsjas/go-psrpcore/messages"
+)
+
+// hostFlagPattern matches the HostInfo flags that tell the server whether the
+// client has a host (MS-PSRP 2.2.3.14). _isHostRawUINull is left alone; the
+// raw UI is not supported.
+var hostFlagPattern = regexp.MustCompile(`(<B N="(?:_isHostNull|_isHostUINull|_useRunspaceHost)">)true(</B>)`)
+
+// HostObjectIDBase is the first PSRP object ID used for host responses.
+// Object IDs for other messages must stay below it so the two never
+// collide on the server.
+const HostObjectIDBase = 1 << 48
+
+// hostResponseObjectID numbers host response fragments, starting at
+// HostObjectIDBase.
+var hostResponseObjectID atomic.Uint64
+
+func init() {
+	hostResponseObjectID.Store(HostObjectIDBase)
+}
+
+// EnableHostInfo rewrites the HostInfo in CreatePipeline fragments so that
+// the server sends host calls (Read-Host, prompts) to the client instead of
+// failing them. data is the output of GetCreatePipelineData.
+func EnableHostInfo(data []byte) ([]byte, error) {
+	out, found, err := rewriteMessages(data, func(msg *messages.Message) bool {
+		if msg.Type != messages.MessageTypeCreatePipeline {
+			return false
+		}
+		var ok bool
+		msg.Data, ok = enableHostFlags(msg.Data)
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("powershell: CreatePipeline message has no HostInfo")
+	}
+	return out, nil
+}
+
+// enableHostFlags clears the null-host flags in serialized HostInfo.
+// ok is false if the data has no HostInfo flags.
+func enableHostFlags(data []byte) ([]byte, bool) {
+	if !hostFlagPattern.Match(data) {
+		return data, false
+	}
+	return hostFlagPattern.ReplaceAll(data, []byte("${1}false${2}")), true
+}
+
+// EncodeHostResponse wraps response data from HandleHostCall in a host
+// response message for the host call msg, and fragments it. Responses to
+// pipeline host calls are sent on the WSMan "pr" stream of the pipeline's
+// command (see WSManTransport.WritePriority).
+func EncodeHostResponse(msg *messages.Message, response []byte) ([]byte, error) {
+	reply := &messages.Message{
+		Destination: messages.DestinationServer,
+		Type:        MessageTypePipelineHostResponse,
+		RunspaceID:  msg.RunspaceID,
+		PipelineID:  msg.PipelineID,
+		Data:        response,
+	}
+	if msg.Type == MessageTypeRunspacePoolHostCall {
+		reply.Type = MessageTypeRunspacePoolHostResponse
+	}
+	encoded, err := reply.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("powershell: encode host response: %w", err)
+	}
+	return fragmentMessage(hostResponseObjectID.Add(1), encoded, maxFragmentBlob)
+}
@@ -0,0 +1,194 @@
+package powershell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/smnsjas/go-psrpcore/messages"
+)
+
+// Size is a console size in character cells.
+type Size struct {
+	Width  int
+	Height int
+}
+
+// HostInfo describes the client's console. The server answers
+// $Host.UI.RawUI with it (BufferSize, WindowSize, colors, title), and
+// Out-String and the format cmdlets wrap output at the buffer width.
+type HostInfo struct {
+	// BufferSize is the screen buffer size; its width is the line width
+	// used to format output.
+	BufferSize Size
+
+	// WindowSize is the visible part of the buffer.
+	WindowSize Size
+
+	// MaxWindowSize and MaxPhysicalWindowSize default to WindowSize.
+	MaxWindowSize         Size
+	MaxPhysicalWindowSize Size
+
+	// ForegroundColor and BackgroundColor are System.ConsoleColor values
+	// (0 Black ... 7 Gray ... 15 White).
+	ForegroundColor int
+	BackgroundColor int
+
+	// CursorSize is the cursor height in percent of a cell.
+	CursorSize int
+
+	// WindowTitle is returned by $Host.UI.RawUI.WindowTitle.
+	WindowTitle string
+}
+
+// DefaultHostInfo is a 120-column console with the classic gray on black.
+var DefaultHostInfo = HostInfo{
+	BufferSize:      Size{Width: 120, Height: 3000},
+	WindowSize:      Size{Width: 120, Height: 50},
+	ForegroundColor: 7, // Gray
+	BackgroundColor: 0, // Black
+	CursorSize:      25,
+	WindowTitle:     "PowerShell",
+}
+
+// WithWidth returns info with a buffer and window width of width columns,
+// raising the maximum window sizes to it if they are set and smaller, so
+// output formatted by the server wraps at width.
+func (info HostInfo) WithWidth(width int) HostInfo {
+	info.BufferSize.Width = width
+	info.WindowSize.Width = width
+	if info.MaxWindowSize != (Size{}) && info.MaxWindowSize.Width < width {
+		info.MaxWindowSize.Width = width
+	}
+	if info.MaxPhysicalWindowSize != (Size{}) && info.MaxPhysicalWindowSize.Width < width {
+		info.MaxPhysicalWindowSize.Width = width
+	}
+	return info
+}
+
+var (
+	// hostInfoStart matches the start of a HostInfo object's properties.
+	hostInfoStart = regexp.MustCompile(`<Obj N="HostInfo" RefId="\d+">\s*<MS>`)
+	// nilHostDefaultData matches an empty _hostDefaultData property.
+	nilHostDefaultData = regexp.MustCompile(`<Nil N="_hostDefaultData"\s*/>`)
+	// hostNullFlagPattern matches all HostInfo null-host flags, including
+	// the raw UI one that hostFlagPattern leaves alone.
+	hostNullFlagPattern = regexp.MustCompile(`(<B N="(?:_isHostNull|_isHostUINull|_isHostRawUINull)">)true(</B>)`)
+)
+
+// EnableHostRawUI adds info as the host default data (MS-PSRP 2.2.3.14)
+// to the HostInfo of the INIT_RUNSPACEPOOL and CreatePipeline messages in
+// data, and marks the host, its UI and raw UI as present. Without it the
+// server has no $Host.UI.RawUI. data is handshake or CreatePipeline
+// fragments.
+func EnableHostRawUI(data []byte, info HostInfo) ([]byte, error) {
+	out, found, err := rewriteMessages(data, func(msg *messages.Message) bool {
+		if msg.Type != messages.MessageTypeInitRunspacePool && msg.Type != messages.MessageTypeCreatePipeline {
+			return false
+		}
+		var ok bool
+		msg.Data, ok = withHostDefaultData(msg.Data, info)
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("powershell: message has no HostInfo")
+	}
+	return out, nil
+}
+
+// withHostDefaultData sets the host default data in serialized HostInfo.
+// ok is false if the data has no HostInfo.
+func withHostDefaultData(data []byte, info HostInfo) ([]byte, bool) {
+	loc := hostInfoStart.FindIndex(data)
+	if loc == nil {
+		return data, false
+	}
+	defaultData := []byte(hostDefaultDataXML(info))
+
+	var out []byte
+	if nilLoc := nilHostDefaultData.FindIndex(data[loc[1]:]); nilLoc != nil {
+		start, end := loc[1]+nilLoc[0], loc[1]+nilLoc[1]
+		out = append(append(append(out, data[:start]...), defaultData...), data[end:]...)
+	} else if bytes.Contains(data, []byte(`<Obj N="_hostDefaultData"`)) {
+		out = append(out, data...)
+	} else {
+		out = append(append(append(out, data[:loc[1]]...), defaultData...), data[loc[1]:]...)
+	}
+	return hostNullFlagPattern.ReplaceAll(out, []byte("${1}false${2}")), true
+}
+
+// Host default data keys (MS-PSRP 2.2.3.14).
+const (
+	hostDataForegroundColor = iota
+	hostDataBackgroundColor
+	hostDataCursorPosition
+	hostDataWindowPosition
+	hostDataCursorSize
+	hostDataBufferSize
+	hostDataWindowSize
+	hostDataMaxWindowSize
+	hostDataMaxPhysicalWindowSize
+	hostDataWindowTitle
+)
+
+// hostDefaultDataXML renders info as a _hostDefaultData object. The RefIds
+// are chosen well above those go-psrpcore uses in the same message, and
+// apart from those of applicationArgumentsXML.
+func hostDefaultDataXML(info HostInfo) string {
+	maxWindow := info.MaxWindowSize
+	if maxWindow == (Size{}) {
+		maxWindow = info.WindowSize
+	}
+	maxPhysical := info.MaxPhysicalWindowSize
+	if maxPhysical == (Size{}) {
+		maxPhysical = info.WindowSize
+	}
+
+	refID := 2001
+	value := func(typeName, v string) string {
+		refID++
+		return fmt.Sprintf(`<Obj N="Value" RefId="%d"><MS><S N="T">%s</S>%s</MS></Obj>`, refID, typeName, v)
+	}
+	object := func(props string) string {
+		refID++
+		return fmt.Sprintf(`<Obj N="V" RefId="%d"><MS>%s</MS></Obj>`, refID, props)
+	}
+	size := func(s Size) string {
+		return value("System.Management.Automation.Host.Size",
+			object(fmt.Sprintf(`<I32 N="width">%d</I32><I32 N="height">%d</I32>`, s.Width, s.Height)))
+	}
+	coordinates := func(x, y int) string {
+		return value("System.Management.Automation.Host.Coordinates",
+			object(fmt.Sprintf(`<I32 N="x">%d</I32><I32 N="y">%d</I32>`, x, y)))
+	}
+
+	entries := []struct {
+		key   int
+		value string
+	}{
+		{hostDataForegroundColor, value("System.ConsoleColor", fmt.Sprintf(`<I32 N="V">%d</I32>`, info.ForegroundColor))},
+		{hostDataBackgroundColor, value("System.ConsoleColor", fmt.Sprintf(`<I32 N="V">%d</I32>`, info.BackgroundColor))},
+		{hostDataCursorPosition, coordinates(0, 0)},
+		{hostDataWindowPosition, coordinates(0, 0)},
+		{hostDataCursorSize, value("System.Int32", fmt.Sprintf(`<I32 N="V">%d</I32>`, info.CursorSize))},
+		{hostDataBufferSize, size(info.BufferSize)},
+		{hostDataWindowSize, size(info.WindowSize)},
+		{hostDataMaxWindowSize, size(maxWindow)},
+		{hostDataMaxPhysicalWindowSize, size(maxPhysical)},
+		{hostDataWindowTitle, value("System.String", `<S N="V">`+escapeCLIXML(info.WindowTitle)+`</S>`)},
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<Obj N="_hostDefaultData" RefId="2000"><MS><Obj N="data" RefId="2001">` +
+		`<TN RefId="2000"><T>System.Collections.Hashtable</T><T>System.Object</T></TN><DCT>`)
+	for _, e := range entries {
+		fmt.Fprintf(&sb, `<En><I32 N="Key">%d</I32>%s</En>`, e.key, e.value)
+	}
+	sb.WriteString(`</DCT></Obj></MS></Obj>`)
+	return sb.String()
+}
@@ -1,18 +1,42 @@
-//go:build windows
-
 package powershell
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/smnsjas/go-psrpcore/outofproc"
 )
 
+// ErrPeerUnresponsive is returned by reads on an OutOfProc connection whose
+// server stopped answering keep-alive pings, e.g. because the PowerShell
+// process crashed or the VM was paused.
+var ErrPeerUnresponsive = errors.New("outofproc: server not responding")
+
+// OutOfProc keep-alive. When nothing has been received for pingInterval,
+// the adapter pings the server; if the ping is not answered within
+// pingTimeout the connection is declared dead.
+const (
+	outOfProcPingInterval = 5 * time.Second
+	outOfProcPingTimeout  = 10 * time.Second
+)
+
+// hvTransportAdapter is the OutOfProc transport used by go-psrpcore pools.
+type hvTransportAdapter interface {
+	io.ReadWriter
+	SendCommand(pipelineGUID uuid.UUID) error
+	SendPipelineData(pipelineGUID uuid.UUID, data []byte) error
+	Signal(ctx context.Context, pipelineGUID uuid.UUID) error
+	Close() error
+}
+
+// hvOutOfProcAdapter adapts an OutOfProc packet transport to the io.ReadWriter
+// expected by go-psrpcore. It is shared by the HvSocket and SSH backends.
 type hvOutOfProcAdapter struct {
 	transport    *outofproc.Transport
 	runspaceGUID uuid.UUID
@@ -29,12 +53,24 @@ type hvOutOfProcAdapter struct {
 
 	readLoopDone chan struct{}
 
+	lastRecv atomic.Int64  // UnixNano of the last packet received
+	dead     chan struct{} // closed when the server stops answering pings
+	deadOnce sync.Once
+
 	handlerMu    sync.RWMutex
 	onCommandAck func(pipelineGUID uuid.UUID)
 	onCloseAck   func(psGuid uuid.UUID)
 	onSignalAck  func(psGuid uuid.UUID)
 
+	signalMu   sync.Mutex
+	signalAcks map[uuid.UUID][]chan struct{} // waiters for a SignalAck, by pipeline
+
 	readTimeout time.Duration
+
+	// probeReply is the server's answer to the compression probe, and
+	// compressor the negotiated Compressor (nil for none).
+	probeReply string
+	compressor atomic.Pointer[Compressor]
 }
 
 func newHvOutOfProcAdapter(transport *outofproc.Transport, runspaceGUID uuid.UUID, readTimeout time.Duration) *hvOutOfProcAdapter {
@@ -47,10 +83,14 @@ func newHvOutOfProcAdapter(transport *outofproc.Transport, runspaceGUID uuid.UUI
 		ctx:          adapterCtx,
 		cancel:       cancel,
 		readLoopDone: make(chan struct{}),
+		signalAcks:   make(map[uuid.UUID][]chan struct{}),
 		readTimeout:  readTimeout,
+		dead:         make(chan struct{}),
 	}
+	a.lastRecv.Store(time.Now().UnixNano())
 
 	go a.readLoop()
+	go a.keepalive(outOfProcPingInterval, outOfProcPingTimeout)
 	return a
 }
 
@@ -84,14 +124,26 @@ func (a *hvOutOfProcAdapter) readLoop() {
 			}
 			return
 		}
+		a.lastRecv.Store(time.Now().UnixNano())
 
 		switch packet.Type {
 		case outofproc.PacketTypeData:
 			if err := a.transport.SendDataAck(packet.PSGuid); err != nil {
 				_ = err
 			}
+			if packet.PSGuid == compressionProbeGUID {
+				a.readMu.Lock()
+				a.probeReply = string(packet.Data)
+				a.readMu.Unlock()
+				continue
+			}
+			data, err := a.decodeFrame(packet.Data)
 			a.readMu.Lock()
-			a.pending = append(a.pending, packet.Data)
+			if err != nil {
+				a.readErr = err
+			} else {
+				a.pending = append(a.pending, data)
+			}
 			a.readMu.Unlock()
 			select {
 			case a.notifyCh <- struct{}{}:
@@ -112,6 +164,13 @@ func (a *hvOutOfProcAdapter) readLoop() {
 				handler(packet.PSGuid)
 			}
 		case outofproc.PacketTypeSignalAck:
+			a.signalMu.Lock()
+			for _, ack := range a.signalAcks[packet.PSGuid] {
+				close(ack)
+			}
+			delete(a.signalAcks, packet.PSGuid)
+			a.signalMu.Unlock()
+
 			a.handlerMu.RLock()
 			handler := a.onSignalAck
 			a.handlerMu.RUnlock()
@@ -130,6 +189,58 @@ func (a *hvOutOfProcAdapter) readLoop() {
 	}
 }
 
+// keepalive pings the server whenever it has been silent for interval, and
+// fails the connection if a ping goes unanswered for timeout. Without it a
+// crashed server leaves Read waiting until readTimeout.
+func (a *hvOutOfProcAdapter) keepalive(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-a.ctx.Done():
+			return
+		case <-a.readLoopDone:
+			return
+		}
+		if time.Since(time.Unix(0, a.lastRecv.Load())) < interval {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(a.ctx, timeout)
+		err := a.ping(ctx)
+		cancel()
+		if err != nil && a.ctx.Err() == nil {
+			a.markDead(err)
+			return
+		}
+	}
+}
+
+// ping sends a Signal for a GUID no pipeline uses and waits for the
+// SignalAck. PowerShell acknowledges signals for unknown pipelines without
+// acting on them, so this is a no-op round trip.
+func (a *hvOutOfProcAdapter) ping(ctx context.Context) error {
+	return a.Signal(ctx, uuid.New())
+}
+
+// markDead fails pending and future reads with ErrPeerUnresponsive.
+func (a *hvOutOfProcAdapter) markDead(cause error) {
+	a.deadOnce.Do(func() {
+		a.readMu.Lock()
+		if a.readErr == nil {
+			a.readErr = fmt.Errorf("%w: %v", ErrPeerUnresponsive, cause)
+		}
+		a.readMu.Unlock()
+		close(a.dead)
+		select {
+		case a.notifyCh <- struct{}{}:
+		default:
+		}
+	})
+}
+
 func (a *hvOutOfProcAdapter) Read(p []byte) (n int, err error) {
 	a.readMu.Lock()
 	defer a.readMu.Unlock()
@@ -185,10 +296,13 @@ func (a *hvOutOfProcAdapter) Read(p []byte) (n int, err error) {
 }
 
 func (a *hvOutOfProcAdapter) Write(p []byte) (int, error) {
-	err := a.transport.SendData(outofproc.NullGUID, p)
+	data, err := a.encodeFrame(p)
 	if err != nil {
 		return 0, err
 	}
+	if err := a.transport.SendData(outofproc.NullGUID, data); err != nil {
+		return 0, err
+	}
 	return len(p), nil
 }
 
@@ -198,11 +312,53 @@ func (a *hvOutOfProcAdapter) SendCommand(pipelineGUID uuid.UUID) error {
 
 func (a *hvOutOfProcAdapter) SendPipelineData(pipelineGUID uuid.UUID, data []byte) error {
 	time.Sleep(2 * time.Millisecond)
+	data, err := a.encodeFrame(data)
+	if err != nil {
+		return err
+	}
 	return a.transport.SendData(pipelineGUID, data)
 }
 
-func (a *hvOutOfProcAdapter) SendSignal(pipelineGUID uuid.UUID) error {
-	return a.transport.SendSignal(pipelineGUID)
+// Signal asks the server to stop the pipeline, as Ctrl+C does, and waits
+// until the server acknowledges it or ctx is done.
+func (a *hvOutOfProcAdapter) Signal(ctx context.Context, pipelineGUID uuid.UUID) error {
+	ack := make(chan struct{})
+	a.signalMu.Lock()
+	a.signalAcks[pipelineGUID] = append(a.signalAcks[pipelineGUID], ack)
+	a.signalMu.Unlock()
+	defer a.dropSignalAck(pipelineGUID, ack)
+
+	if err := a.transport.SendSignal(pipelineGUID); err != nil {
+		return fmt.Errorf("send signal: %w", err)
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-a.readLoopDone:
+		return fmt.Errorf("signal pipeline: %w", io.ErrClosedPipe)
+	case <-a.dead:
+		return ErrPeerUnresponsive
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dropSignalAck removes a waiter that Signal no longer waits on.
+func (a *hvOutOfProcAdapter) dropSignalAck(pipelineGUID uuid.UUID, ack chan struct{}) {
+	a.signalMu.Lock()
+	defer a.signalMu.Unlock()
+	waiters := a.signalAcks[pipelineGUID]
+	for i, w := range waiters {
+		if w == ack {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(a.signalAcks, pipelineGUID)
+	} else {
+		a.signalAcks[pipelineGUID] = waiters
+	}
 }
 
 func (a *hvOutOfProcAdapter) Close() error {
@@ -213,4 +369,3 @@ func (a *hvOutOfProcAdapter) Close() error {
 	}
 	return nil
 }
-
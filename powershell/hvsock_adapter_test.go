@@ -0,0 +1,119 @@
+package powershell
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/outofproc"
+)
+
+// TestHvOutOfProcAdapter_Signal verifies that Signal sends an OutOfProc
+// Signal for the pipeline and returns once the server acknowledges it.
+func TestHvOutOfProcAdapter_Signal(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	a := newHvOutOfProcAdapter(outofproc.NewTransportFromReadWriter(clientConn), uuid.New(), time.Second)
+	defer a.Close()
+	defer clientConn.Close()
+
+	pipelineID := uuid.New()
+	server := outofproc.NewTransportFromReadWriter(serverConn)
+	serverErr := make(chan error, 1)
+	go func() {
+		packet, err := server.ReceivePacket()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		if packet.Type != outofproc.PacketTypeSignal || packet.PSGuid != pipelineID {
+			serverErr <- errors.New("server did not receive a Signal for the pipeline")
+			return
+		}
+		serverErr <- server.SendSignalAck(packet.PSGuid)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.Signal(ctx, pipelineID); err != nil {
+		t.Fatalf("Signal() error = %v", err)
+	}
+	if err := <-serverErr; err != nil {
+		t.Fatal(err)
+	}
+
+	a.signalMu.Lock()
+	defer a.signalMu.Unlock()
+	if len(a.signalAcks) != 0 {
+		t.Errorf("signalAcks = %v, want none left", a.signalAcks)
+	}
+}
+
+// TestHvOutOfProcAdapter_SignalNoAck verifies that Signal gives up when ctx
+// ends without an acknowledgement.
+func TestHvOutOfProcAdapter_SignalNoAck(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	a := newHvOutOfProcAdapter(outofproc.NewTransportFromReadWriter(clientConn), uuid.New(), time.Second)
+	defer a.Close()
+	defer clientConn.Close()
+
+	server := outofproc.NewTransportFromReadWriter(serverConn)
+	go func() { _, _ = server.ReceivePacket() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := a.Signal(ctx, uuid.New()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Signal() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// TestHvOutOfProcAdapter_KeepaliveDeadPeer verifies that reads fail with
+// ErrPeerUnresponsive once the server stops answering pings, and keep
+// waiting while it answers them.
+func TestHvOutOfProcAdapter_KeepaliveDeadPeer(t *testing.T) {
+	for _, answer := range []bool{true, false} {
+		clientConn, serverConn := net.Pipe()
+		a := newHvOutOfProcAdapter(outofproc.NewTransportFromReadWriter(clientConn), uuid.New(), 0)
+
+		server := outofproc.NewTransportFromReadWriter(serverConn)
+		go func() {
+			for {
+				packet, err := server.ReceivePacket()
+				if err != nil {
+					return
+				}
+				if answer && packet.Type == outofproc.PacketTypeSignal {
+					_ = server.SendSignalAck(packet.PSGuid)
+				}
+			}
+		}()
+		go a.keepalive(20*time.Millisecond, 50*time.Millisecond)
+
+		readErr := make(chan error, 1)
+		go func() {
+			_, err := a.Read(make([]byte, 16))
+			readErr <- err
+		}()
+
+		select {
+		case err := <-readErr:
+			if answer {
+				t.Errorf("Read() with answered pings returned %v, want it to keep waiting", err)
+			} else if !errors.Is(err, ErrPeerUnresponsive) {
+				t.Errorf("Read() error = %v, want ErrPeerUnresponsive", err)
+			}
+		case <-time.After(300 * time.Millisecond):
+			if !answer {
+				t.Error("Read() still waiting after the server stopped answering")
+			}
+		}
+
+		_ = a.Close()
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	}
+}
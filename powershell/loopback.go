@@ -0,0 +1,799 @@
+package powershell
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/fragments"
+	"github.com/smnsjas/go-psrpcore/messages"
+	"github.com/smnsjas/go-psrpcore/pipeline"
+	"github.com/smnsjas/go-psrpcore/runspace"
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// PSRP message types the loopback server handles or sends (MS-PSRP 2.2.1).
+const (
+	msgSetMaxRunspaces        = 0x00021002
+	msgSetMinRunspaces        = 0x00021003
+	msgRunspaceAvailability   = 0x00021004
+	msgRunspacePoolState      = 0x00021005
+	msgGetAvailableRunspaces  = 0x00021007
+	msgApplicationPrivateData = 0x00021009
+	msgPipelineInput          = 0x00041002
+	msgEndOfPipelineInput     = 0x00041003
+	msgErrorRecord            = 0x00041005
+	msgVerboseRecord          = 0x00041008
+	msgWarningRecord          = 0x00041009
+)
+
+// RunspacePoolState and PSInvocationState values sent by the loopback
+// server.
+const (
+	loopbackPoolOpened      = 2
+	loopbackPoolBroken      = 5
+	loopbackPipelineStopped = 3
+	loopbackPipelineDone    = 4
+	loopbackPipelineFailed  = 5
+)
+
+// pipelineStoppedTypes is the type hierarchy of PipelineStoppedException.
+var pipelineStoppedTypes = []string{
+	"System.Management.Automation.PipelineStoppedException",
+	"System.Management.Automation.RuntimeException",
+	"System.SystemException",
+	"System.Exception",
+}
+
+// runtimeExceptionTypes is the type hierarchy of RuntimeException.
+var runtimeExceptionTypes = pipelineStoppedTypes[1:]
+
+// LoopbackHandler runs a pipeline on a LoopbackBackend. It writes the
+// pipeline's output and errors with the methods of p. Returning nil
+// completes the pipeline; returning an error fails it with that error, as
+// a terminating error does on a real server. ctx is cancelled when the
+// pipeline is stopped, the backend closed, or the context the pipeline was
+// prepared with ends.
+type LoopbackHandler func(ctx context.Context, p *LoopbackPipeline) error
+
+// LoopbackBackend is a RunspaceBackend that runs an in-memory PSRP server,
+// so applications can test the code that drives runspace pools and
+// pipelines without a Windows host or mocks of go-psrpcore types.
+//
+// The server answers the RunspacePool handshake, the runspace count
+// requests, and runs each pipeline with the handler registered for its
+// script (see Handle, Respond and Fail), or LoopbackEcho. Output, errors
+// and the final pipeline state are sent as PowerShell sends them.
+//
+//	b := powershell.NewLoopbackBackend(poolID)
+//	b.Respond("Get-Date", "2025-01-01")
+//	_ = b.Connect(ctx)
+//	pool := runspace.New(b.Transport(), poolID)
+//	_ = b.Init(ctx, pool)
+//	pool.StartDispatchLoop()
+type LoopbackBackend struct {
+	mu sync.Mutex
+
+	poolID   uuid.UUID
+	handlers map[string]LoopbackHandler
+	fallback LoopbackHandler
+	openErr  error
+	scripts  []string
+
+	conn      *loopbackConn
+	connected bool
+	closed    bool
+
+	// callers are the contexts of prepared pipelines, which their
+	// handlers run under
+	callers map[uuid.UUID]context.Context
+
+	// capability records the server's SESSION_CAPABILITY.
+	capability capabilityWatch
+
+	// failures records the terminating errors of failed pipelines.
+	failures failureWatch
+}
+
+// NewLoopbackBackend creates a loopback backend for the RunspacePool poolID.
+func NewLoopbackBackend(poolID uuid.UUID) *LoopbackBackend {
+	return &LoopbackBackend{
+		poolID:   poolID,
+		handlers: make(map[string]LoopbackHandler),
+		fallback: LoopbackEcho,
+		callers:  make(map[uuid.UUID]context.Context),
+	}
+}
+
+// Handle runs h for pipelines whose first command is script. Leading and
+// trailing white space is ignored.
+func (b *LoopbackBackend) Handle(script string, h LoopbackHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[strings.TrimSpace(script)] = h
+}
+
+// Respond makes pipelines of script output the given objects and complete.
+func (b *LoopbackBackend) Respond(script string, output ...interface{}) {
+	b.Handle(script, func(_ context.Context, p *LoopbackPipeline) error {
+		for _, v := range output {
+			if err := p.WriteOutput(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Fail makes pipelines of script fail with err. An *ErrorRecord keeps its
+// error ID and category.
+func (b *LoopbackBackend) Fail(script string, err error) {
+	b.Handle(script, func(context.Context, *LoopbackPipeline) error {
+		return err
+	})
+}
+
+// HandleDefault runs h for pipelines no handler is registered for.
+// Default: LoopbackEcho.
+func (b *LoopbackBackend) HandleDefault(h LoopbackHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fallback = h
+}
+
+// FailOpen makes the server refuse the next RunspacePool with err: the pool
+// ends in the Broken state and Init fails.
+func (b *LoopbackBackend) FailOpen(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.openErr = err
+}
+
+// Break drops the connection as a crashed server would: reads fail with
+// err once the data already sent is read, and running pipelines are
+// cancelled. Reattach connects again.
+func (b *LoopbackBackend) Break(err error) {
+	b.mu.Lock()
+	conn := b.conn
+	b.mu.Unlock()
+	if conn != nil {
+		conn.close(err)
+	}
+}
+
+// Scripts returns the scripts of the pipelines run so far, in order.
+func (b *LoopbackBackend) Scripts() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.scripts...)
+}
+
+// handler returns the handler for a pipeline and records its script.
+func (b *LoopbackBackend) handler(script string) LoopbackHandler {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scripts = append(b.scripts, script)
+	if h, ok := b.handlers[strings.TrimSpace(script)]; ok {
+		return h
+	}
+	return b.fallback
+}
+
+// takeCaller returns and forgets the context pipeline id was prepared
+// with, or nil.
+func (b *LoopbackBackend) takeCaller(id uuid.UUID) context.Context {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ctx := b.callers[id]
+	delete(b.callers, id)
+	return ctx
+}
+
+// takeOpenError returns and clears the error set by FailOpen.
+func (b *LoopbackBackend) takeOpenError() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.openErr
+	b.openErr = nil
+	return err
+}
+
+// Connect starts the in-memory server.
+func (b *LoopbackBackend) Connect(_ context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrPoolClosed
+	}
+	if b.connected {
+		return nil
+	}
+	b.conn = newLoopbackConn(b)
+	b.connected = true
+	return nil
+}
+
+// Transport returns the connection to the server.
+func (b *LoopbackBackend) Transport() io.ReadWriter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		return nil
+	}
+	return b.conn
+}
+
+// Init opens the RunspacePool on the server.
+func (b *LoopbackBackend) Init(ctx context.Context, pool *runspace.Pool) error {
+	b.mu.Lock()
+	connected := b.connected
+	b.mu.Unlock()
+	if !connected {
+		return errNotConnected
+	}
+	pool.SetTransport(b.failures.watch(b.capability.watch(b.Transport())))
+	return pool.Open(ctx)
+}
+
+// ServerCapability returns the server's SESSION_CAPABILITY from the last
+// Init or Reattach.
+func (b *LoopbackBackend) ServerCapability() (SessionCapability, bool) {
+	return b.capability.ServerCapability()
+}
+
+// PipelineFailure returns the terminating error of the failed pipeline id,
+// once.
+func (b *LoopbackBackend) PipelineFailure(id uuid.UUID) (*ErrorRecord, bool) {
+	return b.failures.PipelineFailure(id)
+}
+
+// PreparePipeline returns no per-pipeline transport; all pipelines share the
+// connection, as with the OutOfProc backends. The pipeline's handler runs
+// under ctx, so the caller's deadline also ends a handler that waits.
+func (b *LoopbackBackend) PreparePipeline(ctx context.Context, p *pipeline.Pipeline, _ string) (io.Reader, func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, ErrPoolClosed
+	}
+	if !b.connected {
+		return nil, nil, errNotConnected
+	}
+	id := p.ID()
+	b.callers[id] = ctx
+	cleanup := func() {
+		b.mu.Lock()
+		delete(b.callers, id)
+		b.mu.Unlock()
+	}
+	return nil, cleanup, nil
+}
+
+// StopPipeline cancels the pipeline's handler. The pipeline then ends in
+// the Stopped state.
+func (b *LoopbackBackend) StopPipeline(_ context.Context, p *pipeline.Pipeline) error {
+	b.mu.Lock()
+	conn := b.conn
+	b.mu.Unlock()
+	if conn == nil {
+		return errNotConnected
+	}
+	conn.stop(p.ID())
+	return nil
+}
+
+// ShellID returns the RunspacePool ID.
+func (b *LoopbackBackend) ShellID() string {
+	return b.poolID.String()
+}
+
+// Reattach connects again and opens a new RunspacePool; like a stream
+// transport, the server keeps nothing of the previous connection, so
+// shellID is ignored.
+func (b *LoopbackBackend) Reattach(ctx context.Context, pool *runspace.Pool, _ string) error {
+	b.mu.Lock()
+	if b.conn != nil {
+		b.conn.close(io.EOF)
+		b.conn = nil
+	}
+	b.connected = false
+	b.mu.Unlock()
+
+	if err := b.Connect(ctx); err != nil {
+		return err
+	}
+	pool.SetTransport(b.failures.watch(b.capability.watch(b.Transport())))
+
+	if err := pool.Open(ctx); err != nil {
+		return fmt.Errorf("pool open: %w", err)
+	}
+	return nil
+}
+
+// SupportsPSRPKeepalive returns true; the server answers
+// GET_AVAILABLE_RUNSPACES.
+func (b *LoopbackBackend) SupportsPSRPKeepalive() bool {
+	return true
+}
+
+// Capabilities returns LoopbackCapabilities.
+func (b *LoopbackBackend) Capabilities() Capabilities {
+	return LoopbackCapabilities
+}
+
+// Close stops the server. Running pipelines are cancelled and reads return
+// io.EOF.
+func (b *LoopbackBackend) Close(_ context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if b.conn != nil {
+		b.conn.close(io.EOF)
+		b.conn = nil
+	}
+	return nil
+}
+
+// LoopbackCommand is a command of a loopback pipeline.
+type LoopbackCommand struct {
+	// Name is the command name, or the script text if IsScript is set.
+	Name     string
+	IsScript bool
+
+	// Parameters are the command's parameters in order; positional
+	// arguments have no Name.
+	Parameters []LoopbackParameter
+}
+
+// LoopbackParameter is a parameter of a LoopbackCommand.
+type LoopbackParameter struct {
+	Name  string
+	Value interface{}
+}
+
+// LoopbackPipeline is a pipeline being run by a LoopbackBackend.
+type LoopbackPipeline struct {
+	// ID is the pipeline ID chosen by the client.
+	ID uuid.UUID
+
+	// Script is the text of the first command.
+	Script string
+
+	// Commands are the commands of the pipeline.
+	Commands []LoopbackCommand
+
+	conn   *loopbackConn
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	input     []interface{}
+	inputDone chan struct{}
+	stopped   bool
+}
+
+// Input waits until the client has sent all pipeline input and returns
+// it. Pipelines created with NoInput return nil at once; go-psrpcore does
+// not set NoInput, so clients close the input, as Client.Execute does.
+func (p *LoopbackPipeline) Input(ctx context.Context) ([]interface{}, error) {
+	select {
+	case <-p.inputDone:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]interface{}(nil), p.input...), nil
+}
+
+// WriteOutput writes v to the output stream.
+func (p *LoopbackPipeline) WriteOutput(v interface{}) error {
+	data, err := serialization.NewSerializer().Serialize(v)
+	if err != nil {
+		return fmt.Errorf("serialize %T: %w", v, err)
+	}
+	return p.conn.send(&messages.Message{Type: messages.MessageTypePipelineOutput, PipelineID: p.ID, Data: data})
+}
+
+// WriteError writes a non-terminating error, as Write-Error does. An
+// *ErrorRecord keeps its error ID and category.
+func (p *LoopbackPipeline) WriteError(err error) error {
+	refs := 0
+	return p.conn.send(&messages.Message{Type: msgErrorRecord, PipelineID: p.ID, Data: []byte(loopbackErrorRecord("", err, &refs))})
+}
+
+// WriteWarning writes to the warning stream.
+func (p *LoopbackPipeline) WriteWarning(message string) error {
+	return p.conn.send(&messages.Message{Type: msgWarningRecord, PipelineID: p.ID, Data: informationalRecord("WarningRecord", message)})
+}
+
+// WriteVerbose writes to the verbose stream.
+func (p *LoopbackPipeline) WriteVerbose(message string) error {
+	return p.conn.send(&messages.Message{Type: msgVerboseRecord, PipelineID: p.ID, Data: informationalRecord("VerboseRecord", message)})
+}
+
+// addInput appends pipeline input sent by the client.
+func (p *LoopbackPipeline) addInput(values []interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.inputDone:
+	default:
+		p.input = append(p.input, values...)
+	}
+}
+
+// endInput marks the end of the pipeline input.
+func (p *LoopbackPipeline) endInput() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.inputDone:
+	default:
+		close(p.inputDone)
+	}
+}
+
+// LoopbackEcho is the default LoopbackHandler: it writes the pipeline input
+// back to the output stream, or the script text if there is none.
+func LoopbackEcho(ctx context.Context, p *LoopbackPipeline) error {
+	input, err := p.Input(ctx)
+	if err != nil {
+		return err
+	}
+	if len(input) == 0 {
+		return p.WriteOutput(p.Script)
+	}
+	for _, v := range input {
+		if err := p.WriteOutput(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loopbackConn is the client's connection to the loopback server. Writes
+// carry PSRP fragments to the server; reads return the server's fragments.
+type loopbackConn struct {
+	backend *LoopbackBackend
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// writeMu serializes the fragments written by the client
+	writeMu sync.Mutex
+	partial []byte
+	blobs   map[uint64][]byte
+
+	mu           sync.Mutex
+	readable     *sync.Cond
+	out          []byte
+	err          error // returned by reads once out is drained
+	objectID     uint64
+	pipelines    map[uuid.UUID]*LoopbackPipeline
+	maxRunspaces int64
+}
+
+func newLoopbackConn(b *LoopbackBackend) *loopbackConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &loopbackConn{
+		backend:      b,
+		ctx:          ctx,
+		cancel:       cancel,
+		blobs:        make(map[uint64][]byte),
+		pipelines:    make(map[uuid.UUID]*LoopbackPipeline),
+		maxRunspaces: 1,
+	}
+	c.readable = sync.NewCond(&c.mu)
+	return c
+}
+
+// Read returns fragments sent by the server, blocking until there are some.
+func (c *loopbackConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.out) == 0 && c.err == nil {
+		c.readable.Wait()
+	}
+	if len(c.out) == 0 {
+		return 0, c.err
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// Write takes fragments from the client and handles each complete message.
+func (c *loopbackConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.mu.Lock()
+	closed := c.err != nil
+	c.mu.Unlock()
+	if closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	c.partial = append(c.partial, p...)
+	for len(c.partial) >= fragments.HeaderSize {
+		size := fragments.HeaderSize + int(binary.BigEndian.Uint32(c.partial[17:fragments.HeaderSize]))
+		if len(c.partial) < size {
+			break
+		}
+		frag, err := fragments.Decode(c.partial[:size])
+		if err != nil {
+			return 0, fmt.Errorf("loopback: decode fragment: %w", err)
+		}
+		c.partial = c.partial[size:]
+
+		if frag.Start {
+			c.blobs[frag.ObjectID] = nil
+		}
+		blob := append(c.blobs[frag.ObjectID], frag.Data...)
+		if !frag.End {
+			c.blobs[frag.ObjectID] = blob
+			continue
+		}
+		delete(c.blobs, frag.ObjectID)
+
+		msg, err := messages.Decode(blob)
+		if err != nil {
+			return 0, fmt.Errorf("loopback: decode message: %w", err)
+		}
+		c.handle(msg)
+	}
+	c.partial = append([]byte(nil), c.partial...)
+	return len(p), nil
+}
+
+// handle answers a message from the client. Messages the server has no
+// use for are ignored.
+func (c *loopbackConn) handle(msg *messages.Message) {
+	switch msg.Type {
+	case messages.MessageTypeSessionCapability:
+		c.sendPool(messages.Message{Type: messages.MessageTypeSessionCapability}, `<Obj RefId="0"><MS>`+
+			`<Version N="protocolversion">2.3</Version><Version N="PSVersion">2.0</Version>`+
+			`<Version N="SerializationVersion">1.1.0.1</Version></MS></Obj>`)
+	case messages.MessageTypeInitRunspacePool:
+		c.setMaxRunspaces(loopbackProps(msg.Data))
+		if err := c.backend.takeOpenError(); err != nil {
+			refs := 1
+			c.sendPool(messages.Message{Type: msgRunspacePoolState}, `<Obj RefId="0"><MS>`+
+				`<I32 N="RunspaceState">`+strconv.Itoa(loopbackPoolBroken)+`</I32>`+
+				loopbackErrorRecord("ExceptionAsErrorRecord", err, &refs)+`</MS></Obj>`)
+			return
+		}
+		c.sendPool(messages.Message{Type: msgApplicationPrivateData}, `<Obj RefId="0"><MS>`+
+			`<Obj N="ApplicationPrivateData" RefId="1"><TN RefId="0">`+
+			`<T>System.Management.Automation.PSPrimitiveDictionary</T><T>System.Collections.Hashtable</T><T>System.Object</T>`+
+			`</TN><DCT /></Obj></MS></Obj>`)
+		c.sendPool(messages.Message{Type: msgRunspacePoolState}, `<Obj RefId="0"><MS>`+
+			`<I32 N="RunspaceState">`+strconv.Itoa(loopbackPoolOpened)+`</I32></MS></Obj>`)
+	case msgSetMaxRunspaces, msgSetMinRunspaces:
+		props := loopbackProps(msg.Data)
+		c.setMaxRunspaces(props)
+		c.sendAvailability(props, `<B N="SetMinMaxRunspacesResponse">true</B>`)
+	case msgGetAvailableRunspaces:
+		c.mu.Lock()
+		available := max(c.maxRunspaces-int64(len(c.pipelines)), 0)
+		c.mu.Unlock()
+		c.sendAvailability(loopbackProps(msg.Data),
+			`<I64 N="SetMinMaxRunspacesResponse">`+strconv.FormatInt(available, 10)+`</I64>`)
+	case messages.MessageTypeCreatePipeline:
+		c.createPipeline(msg)
+	case msgPipelineInput:
+		if p := c.pipeline(msg.PipelineID); p != nil {
+			values, err := serialization.NewDeserializer().Deserialize(msg.Data)
+			if err == nil {
+				p.addInput(values)
+			}
+		}
+	case msgEndOfPipelineInput:
+		if p := c.pipeline(msg.PipelineID); p != nil {
+			p.endInput()
+		}
+	}
+}
+
+// setMaxRunspaces records the MaxRunspaces of a pool request, if it has one.
+func (c *loopbackConn) setMaxRunspaces(props map[string]interface{}) {
+	if n, ok := hostInt(unwrapRecord(props["MaxRunspaces"])); ok && n > 0 {
+		c.mu.Lock()
+		c.maxRunspaces = n
+		c.mu.Unlock()
+	}
+}
+
+// sendAvailability sends RUNSPACE_AVAILABILITY for the request with props.
+func (c *loopbackConn) sendAvailability(props map[string]interface{}, response string) {
+	callID, _ := hostInt(unwrapRecord(props["ci"]))
+	c.sendPool(messages.Message{Type: msgRunspaceAvailability}, `<Obj RefId="0"><MS>`+response+
+		`<I64 N="ci">`+strconv.FormatInt(callID, 10)+`</I64></MS></Obj>`)
+}
+
+// createPipeline starts the handler of a CREATE_PIPELINE message.
+func (c *loopbackConn) createPipeline(msg *messages.Message) {
+	props := loopbackProps(msg.Data)
+	p := &LoopbackPipeline{
+		ID:        msg.PipelineID,
+		conn:      c,
+		inputDone: make(chan struct{}),
+	}
+	for _, cmd := range hostList(recordProperties(props["PowerShell"])["Cmds"]) {
+		cmdProps := recordProperties(cmd)
+		isScript, _ := unwrapRecord(cmdProps["IsScript"]).(bool)
+		command := LoopbackCommand{Name: recordString(cmdProps, "Cmd"), IsScript: isScript}
+		for _, arg := range hostList(cmdProps["Args"]) {
+			argProps := recordProperties(arg)
+			command.Parameters = append(command.Parameters, LoopbackParameter{
+				Name:  recordString(argProps, "N"),
+				Value: unwrapRecord(argProps["V"]),
+			})
+		}
+		p.Commands = append(p.Commands, command)
+	}
+	if len(p.Commands) > 0 {
+		p.Script = p.Commands[0].Name
+	}
+	if noInput, _ := unwrapRecord(props["NoInput"]).(bool); noInput {
+		close(p.inputDone)
+	}
+
+	// The handler ends with the caller's context or the connection
+	parent := c.ctx
+	if caller := c.backend.takeCaller(p.ID); caller != nil {
+		parent = caller
+	}
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(c.ctx, cancel)
+	p.cancel = func() {
+		stop()
+		cancel()
+	}
+	c.mu.Lock()
+	c.pipelines[p.ID] = p
+	c.mu.Unlock()
+
+	h := c.backend.handler(p.Script)
+	go c.run(ctx, p, h)
+}
+
+// run runs a pipeline's handler and sends the pipeline's final state.
+func (c *loopbackConn) run(ctx context.Context, p *LoopbackPipeline, h LoopbackHandler) {
+	defer p.cancel()
+	err := h(ctx, p)
+
+	c.mu.Lock()
+	delete(c.pipelines, p.ID)
+	c.mu.Unlock()
+	p.mu.Lock()
+	stopped := p.stopped
+	p.mu.Unlock()
+
+	refs := 1
+	state := `<I32 N="PipelineState">` + strconv.Itoa(loopbackPipelineDone) + `</I32>`
+	switch {
+	case stopped:
+		state = `<I32 N="PipelineState">` + strconv.Itoa(loopbackPipelineStopped) + `</I32>` +
+			clixmlErrorRecordOf("ExceptionAsErrorRecord", "The pipeline has been stopped.",
+				"PipelineStopped", 14, pipelineStoppedTypes, &refs)
+	case err != nil:
+		state = `<I32 N="PipelineState">` + strconv.Itoa(loopbackPipelineFailed) + `</I32>` +
+			loopbackErrorRecord("ExceptionAsErrorRecord", err, &refs)
+	}
+	_ = c.send(&messages.Message{
+		Type:       messages.MessageTypePipelineState,
+		PipelineID: p.ID,
+		Data:       []byte(`<Obj RefId="0"><MS>` + state + `</MS></Obj>`),
+	})
+}
+
+// pipeline returns a running pipeline, or nil.
+func (c *loopbackConn) pipeline(id uuid.UUID) *LoopbackPipeline {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pipelines[id]
+}
+
+// stop cancels a running pipeline. Pipelines that already ended are left
+// alone, as PowerShell does.
+func (c *loopbackConn) stop(id uuid.UUID) {
+	p := c.pipeline(id)
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.stopped = true
+	p.mu.Unlock()
+	p.cancel()
+}
+
+// sendPool sends a RunspacePool message with the CLIXML data. Errors mean
+// the connection is closed, so there is no one to tell.
+func (c *loopbackConn) sendPool(msg messages.Message, data string) {
+	msg.Data = []byte(data)
+	_ = c.send(&msg)
+}
+
+// send fragments a message to the client. RunspacePool messages have no
+// PipelineID.
+func (c *loopbackConn) send(msg *messages.Message) error {
+	msg.Destination = messages.DestinationClient
+	msg.RunspaceID = c.backend.poolID
+	encoded, err := msg.Encode()
+	if err != nil {
+		return fmt.Errorf("loopback: encode message: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.objectID++
+	frags, err := fragmentMessage(c.objectID, encoded, maxFragmentBlob)
+	if err != nil {
+		return err
+	}
+	c.out = append(c.out, frags...)
+	c.readable.Broadcast()
+	return nil
+}
+
+// close ends the connection: reads return err once the pending data is
+// read, and running pipelines are cancelled.
+func (c *loopbackConn) close(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.readable.Broadcast()
+	c.mu.Unlock()
+	c.cancel()
+}
+
+// loopbackProps deserializes the object of a client message.
+func loopbackProps(data []byte) map[string]interface{} {
+	objs, err := serialization.NewDeserializer().Deserialize(data)
+	if err != nil || len(objs) == 0 {
+		return nil
+	}
+	return recordProperties(objs[0])
+}
+
+// loopbackErrorRecord renders err as an ErrorRecord. An *ErrorRecord keeps
+// its error ID and category; other errors become a RuntimeException.
+func loopbackErrorRecord(name string, err error, refs *int) string {
+	errorID, category := "RuntimeException", 0
+	var rec *ErrorRecord
+	if errors.As(err, &rec) {
+		if rec.FullyQualifiedErrorID != "" {
+			errorID = rec.FullyQualifiedErrorID
+		}
+		for i, c := range errorCategories {
+			if c == rec.CategoryInfo.Category {
+				category = i
+			}
+		}
+	}
+	return clixmlErrorRecordOf(name, err.Error(), errorID, category, runtimeExceptionTypes, refs)
+}
+
+// informationalRecord renders a warning, verbose or debug record.
+func informationalRecord(typeName, message string) []byte {
+	msg := escapeCLIXML(message)
+	return []byte(`<Obj RefId="0"><TN RefId="0"><T>System.Management.Automation.` + typeName + `</T>` +
+		`<T>System.Management.Automation.InformationalRecord</T><T>System.Object</T></TN>` +
+		`<ToString>` + msg + `</ToString><MS><S N="InformationalRecord_Message">` + msg + `</S>` +
+		`<B N="InformationalRecord_SerializeInvocationInfo">false</B></MS></Obj>`)
+}
@@ -0,0 +1,303 @@
+package powershell
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/fragments"
+	"github.com/smnsjas/go-psrpcore/messages"
+	"github.com/smnsjas/go-psrpcore/pipeline"
+	"github.com/smnsjas/go-psrpcore/runspace"
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// runLoopbackPipeline runs script in pool and returns its output.
+func runLoopbackPipeline(ctx context.Context, t *testing.T, b *LoopbackBackend, pool *runspace.Pool, script string) ([]interface{}, error) {
+	t.Helper()
+	p, err := pool.CreatePipeline(script)
+	if err != nil {
+		t.Fatalf("CreatePipeline() error = %v", err)
+	}
+	return invokeLoopbackPipeline(ctx, t, b, p)
+}
+
+// invokeLoopbackPipeline runs p and returns its output.
+func invokeLoopbackPipeline(ctx context.Context, t *testing.T, b *LoopbackBackend, p *pipeline.Pipeline) ([]interface{}, error) {
+	t.Helper()
+	if _, _, err := b.PreparePipeline(ctx, p, ""); err != nil {
+		t.Fatalf("PreparePipeline() error = %v", err)
+	}
+	if err := p.Invoke(ctx); err != nil {
+		t.Fatalf("Invoke() error = %v", err)
+	}
+	// No input, as Client.Execute sends; the pipeline may already be done
+	_ = p.CloseInput(ctx)
+
+	for _, ch := range []<-chan *messages.Message{p.Warning(), p.Verbose(), p.Debug(), p.Progress(), p.Information()} {
+		go func() {
+			for range ch {
+			}
+		}()
+	}
+	go func() {
+		for range p.Error() {
+		}
+	}()
+	var out []interface{}
+	for msg := range p.Output() {
+		values, err := serialization.NewDeserializer().Deserialize(msg.Data)
+		if err != nil {
+			t.Fatalf("Deserialize() error = %v", err)
+		}
+		out = append(out, values...)
+	}
+	return out, p.Wait()
+}
+
+func TestLoopbackBackend_Pool(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	poolID := uuid.New()
+	b := NewLoopbackBackend(poolID)
+	b.Respond("Get-Thing", "a", int32(2))
+	b.Fail("Remove-Thing", errors.New("access denied"))
+
+	if err := b.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	pool := runspace.New(b.Transport(), poolID)
+	if err := b.Init(ctx, pool); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	pool.StartDispatchLoop()
+	defer b.Close(ctx)
+
+	out, err := runLoopbackPipeline(ctx, t, b, pool, "Get-Thing")
+	if err != nil || len(out) != 2 || out[0] != "a" || out[1] != int32(2) {
+		t.Errorf("Get-Thing = %v, %v; want [a 2]", out, err)
+	}
+	out, err = runLoopbackPipeline(ctx, t, b, pool, "Hello")
+	if err != nil || len(out) != 1 || out[0] != "Hello" {
+		t.Errorf("echo = %v, %v; want [Hello]", out, err)
+	}
+	if _, err := runLoopbackPipeline(ctx, t, b, pool, "Remove-Thing"); err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("Remove-Thing error = %v, want access denied", err)
+	}
+	if got := strings.Join(b.Scripts(), ","); got != "Get-Thing,Hello,Remove-Thing" {
+		t.Errorf("Scripts() = %s", got)
+	}
+}
+
+// TestLoopbackBackend_CallerDeadline verifies that a handler waiting for
+// input that never ends stops with the context the pipeline was prepared
+// with.
+func TestLoopbackBackend_CallerDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	poolID := uuid.New()
+	b := NewLoopbackBackend(poolID)
+	if err := b.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	pool := runspace.New(b.Transport(), poolID)
+	if err := b.Init(ctx, pool); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	pool.StartDispatchLoop()
+	defer b.Close(ctx)
+
+	p, err := pool.CreatePipeline("$input")
+	if err != nil {
+		t.Fatalf("CreatePipeline() error = %v", err)
+	}
+	short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort()
+	if _, _, err := b.PreparePipeline(short, p, ""); err != nil {
+		t.Fatalf("PreparePipeline() error = %v", err)
+	}
+	if err := p.Invoke(ctx); err != nil {
+		t.Fatalf("Invoke() error = %v", err)
+	}
+	for _, ch := range []<-chan *messages.Message{p.Output(), p.Error(), p.Warning(), p.Verbose(), p.Debug(), p.Progress(), p.Information()} {
+		go func() {
+			for range ch {
+			}
+		}()
+	}
+	if err := p.Wait(); err == nil || !strings.Contains(err.Error(), "deadline") {
+		t.Errorf("Wait() error = %v, want the caller's deadline", err)
+	}
+}
+
+func TestLoopbackBackend_FailOpen(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	poolID := uuid.New()
+	b := NewLoopbackBackend(poolID)
+	b.FailOpen(errors.New("quota exceeded"))
+	if err := b.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer b.Close(ctx)
+	if err := b.Init(ctx, runspace.New(b.Transport(), poolID)); err == nil {
+		t.Error("Init() error = nil, want the pool refused")
+	}
+}
+
+// writeLoopbackMessage sends a client message to the loopback server.
+func writeLoopbackMessage(t *testing.T, w io.Writer, objectID uint64, msg *messages.Message) {
+	t.Helper()
+	msg.Destination = messages.DestinationServer
+	encoded, err := msg.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	frags, err := fragmentMessage(objectID, encoded, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Split writes mid-fragment, as a transport may
+	for len(frags) > 0 {
+		n := min(len(frags), 50)
+		if _, err := w.Write(frags[:n]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		frags = frags[n:]
+	}
+}
+
+// readLoopbackMessage reads the next message from the loopback server.
+func readLoopbackMessage(t *testing.T, r io.Reader) *messages.Message {
+	t.Helper()
+	var blob []byte
+	for {
+		header := make([]byte, fragments.HeaderSize)
+		if _, err := io.ReadFull(r, header); err != nil {
+			t.Fatalf("read fragment: %v", err)
+		}
+		frag := make([]byte, fragments.HeaderSize+int(binary.BigEndian.Uint32(header[17:])))
+		copy(frag, header)
+		if _, err := io.ReadFull(r, frag[fragments.HeaderSize:]); err != nil {
+			t.Fatalf("read fragment: %v", err)
+		}
+		decoded, err := fragments.Decode(frag)
+		if err != nil {
+			t.Fatalf("fragments.Decode() error = %v", err)
+		}
+		blob = append(blob, decoded.Data...)
+		if decoded.End {
+			break
+		}
+	}
+	msg, err := messages.Decode(blob)
+	if err != nil {
+		t.Fatalf("messages.Decode() error = %v", err)
+	}
+	return msg
+}
+
+// createPipelineData is the CREATE_PIPELINE data of a one-command pipeline.
+func createPipelineData(script string, noInput bool) []byte {
+	input := "false"
+	if noInput {
+		input = "true"
+	}
+	return []byte(`<Obj RefId="0"><MS><B N="NoInput">` + input + `</B>` +
+		`<Obj N="PowerShell" RefId="1"><MS><Obj N="Cmds" RefId="2"><TN RefId="0"><T>System.Collections.Generic.List` +
+		"`1[[System.Management.Automation.PSObject]]</T><T>System.Object</T></TN><LST>" +
+		`<Obj RefId="3"><MS><S N="Cmd">` + escapeCLIXML(script) + `</S><B N="IsScript">true</B>` +
+		`<Obj N="Args" RefId="4"><TNRef RefId="0" /><LST /></Obj></MS></Obj>` +
+		`</LST></Obj></MS></Obj></MS></Obj>`)
+}
+
+func TestLoopbackBackend_Protocol(t *testing.T) {
+	ctx := context.Background()
+	poolID := uuid.New()
+	b := NewLoopbackBackend(poolID)
+	b.Handle("Wait-Stop", func(ctx context.Context, p *LoopbackPipeline) error {
+		if err := p.WriteWarning("waiting"); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := b.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	conn := b.Transport()
+
+	writeLoopbackMessage(t, conn, 1, &messages.Message{
+		Type:       messages.MessageTypeSessionCapability,
+		RunspaceID: poolID,
+		Data:       []byte(`<Obj RefId="0"><MS><Version N="protocolversion">2.3</Version></MS></Obj>`),
+	})
+	writeLoopbackMessage(t, conn, 2, &messages.Message{
+		Type:       messages.MessageTypeInitRunspacePool,
+		RunspaceID: poolID,
+		Data:       []byte(`<Obj RefId="0"><MS><I32 N="MinRunspaces">1</I32><I32 N="MaxRunspaces">4</I32></MS></Obj>`),
+	})
+	for _, want := range []uint32{uint32(messages.MessageTypeSessionCapability), msgApplicationPrivateData, msgRunspacePoolState} {
+		if msg := readLoopbackMessage(t, conn); uint32(msg.Type) != want || msg.RunspaceID != poolID {
+			t.Fatalf("message type %#x for %s, want %#x", msg.Type, msg.RunspaceID, want)
+		}
+	}
+
+	// Echo of pipeline input
+	echoID := uuid.New()
+	writeLoopbackMessage(t, conn, 3, &messages.Message{
+		Type: messages.MessageTypeCreatePipeline, RunspaceID: poolID, PipelineID: echoID,
+		Data: createPipelineData("$input", false),
+	})
+	writeLoopbackMessage(t, conn, 4, &messages.Message{
+		Type: msgPipelineInput, RunspaceID: poolID, PipelineID: echoID, Data: []byte(`<S>piped</S>`),
+	})
+	writeLoopbackMessage(t, conn, 5, &messages.Message{
+		Type: msgEndOfPipelineInput, RunspaceID: poolID, PipelineID: echoID,
+	})
+	msg := readLoopbackMessage(t, conn)
+	if msg.Type != messages.MessageTypePipelineOutput || msg.PipelineID != echoID || !strings.Contains(string(msg.Data), "piped") {
+		t.Fatalf("echo output = %#x %q", msg.Type, msg.Data)
+	}
+	msg = readLoopbackMessage(t, conn)
+	if msg.Type != messages.MessageTypePipelineState || !strings.Contains(string(msg.Data), `<I32 N="PipelineState">4</I32>`) {
+		t.Fatalf("echo state = %#x %q", msg.Type, msg.Data)
+	}
+
+	// Stopping a running pipeline
+	stopID := uuid.New()
+	writeLoopbackMessage(t, conn, 6, &messages.Message{
+		Type: messages.MessageTypeCreatePipeline, RunspaceID: poolID, PipelineID: stopID,
+		Data: createPipelineData("Wait-Stop", true),
+	})
+	if msg := readLoopbackMessage(t, conn); uint32(msg.Type) != msgWarningRecord || !strings.Contains(string(msg.Data), "waiting") {
+		t.Fatalf("warning = %#x %q", msg.Type, msg.Data)
+	}
+	b.conn.stop(stopID)
+	msg = readLoopbackMessage(t, conn)
+	if msg.Type != messages.MessageTypePipelineState || !strings.Contains(string(msg.Data), `<I32 N="PipelineState">3</I32>`) ||
+		!strings.Contains(string(msg.Data), "PipelineStoppedException") {
+		t.Fatalf("stop state = %#x %q", msg.Type, msg.Data)
+	}
+
+	// Closing ends reads
+	broken := errors.New("server crashed")
+	b.Break(broken)
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, broken) {
+		t.Errorf("Read() after Break error = %v, want %v", err, broken)
+	}
+	if err := b.Close(ctx); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if err := b.Connect(ctx); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Connect() after Close error = %v, want ErrPoolClosed", err)
+	}
+}
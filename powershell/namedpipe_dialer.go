@@ -0,0 +1,54 @@
+package powershell
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// PipeDialer connects to a named pipe by its path, e.g. \\.\pipe\name on
+// Windows.
+type PipeDialer func(ctx context.Context, path string) (net.Conn, error)
+
+// ErrNoPipeDialer is returned when connecting to a named pipe on Windows
+// in a program built with the psrp_nowinio tag, unless a dialer was
+// registered with RegisterPipeDialer.
+var ErrNoPipeDialer = errors.New("powershell: no named pipe dialer (built with psrp_nowinio and no dialer registered)")
+
+var (
+	pipeDialerMu sync.RWMutex
+	// registeredPipeDialer is set by RegisterPipeDialer.
+	registeredPipeDialer PipeDialer
+	// builtinPipeDialer dials the platform's pipes: go-winio on Windows
+	// unless built with psrp_nowinio, Unix sockets elsewhere.
+	builtinPipeDialer PipeDialer
+)
+
+// RegisterPipeDialer makes d the dialer of the named pipe backend, in
+// place of go-winio on Windows. It lets programs built with psrp_nowinio
+// plug in another implementation. A nil d restores the built-in dialer.
+func RegisterPipeDialer(d PipeDialer) {
+	pipeDialerMu.Lock()
+	defer pipeDialerMu.Unlock()
+	registeredPipeDialer = d
+}
+
+// pipeDialer returns the registered dialer, else the built-in one, or nil.
+func pipeDialer() PipeDialer {
+	pipeDialerMu.RLock()
+	defer pipeDialerMu.RUnlock()
+	if registeredPipeDialer != nil {
+		return registeredPipeDialer
+	}
+	return builtinPipeDialer
+}
+
+// dialPipePath connects to the pipe at path with the current dialer.
+func dialPipePath(ctx context.Context, path string) (net.Conn, error) {
+	dial := pipeDialer()
+	if dial == nil {
+		return nil, ErrNoPipeDialer
+	}
+	return dial(ctx, path)
+}
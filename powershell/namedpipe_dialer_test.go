@@ -0,0 +1,34 @@
+package powershell
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRegisterPipeDialer(t *testing.T) {
+	defer RegisterPipeDialer(nil)
+
+	wantErr := errors.New("dialed")
+	var gotPath string
+	RegisterPipeDialer(func(_ context.Context, path string) (net.Conn, error) {
+		gotPath = path
+		return nil, wantErr
+	})
+	if _, err := dialPipe(context.Background(), "PSHost.1.2.pwsh"); !errors.Is(err, wantErr) {
+		t.Fatalf("dialPipe() error = %v, want the registered dialer's", err)
+	}
+	if !strings.HasSuffix(gotPath, pipePrefix+"PSHost.1.2.pwsh") {
+		t.Errorf("dialer got path %q", gotPath)
+	}
+
+	RegisterPipeDialer(nil)
+	if pipeDialer() == nil {
+		// Windows, built with psrp_nowinio
+		if _, err := dialPipe(context.Background(), "PSHost.1.2.pwsh"); !errors.Is(err, ErrNoPipeDialer) {
+			t.Errorf("dialPipe() error = %v, want ErrNoPipeDialer", err)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package powershell
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// .NET implements named pipes as Unix domain sockets named
+// CoreFxPipe_<name> in the temp directory.
+const pipePrefix = "CoreFxPipe_"
+
+var pipeDir = os.TempDir()
+
+func init() {
+	builtinPipeDialer = func(ctx context.Context, path string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
+// dialPipe connects to the socket backing the named pipe name.
+func dialPipe(ctx context.Context, name string) (net.Conn, error) {
+	return dialPipePath(ctx, filepath.Join(pipeDir, pipePrefix+name))
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package powershell
+
+import (
+	"context"
+	"net"
+)
+
+// Named pipes are listed under \\.\pipe\ with no name prefix.
+const pipePrefix = ""
+
+var pipeDir = `\\.\pipe\`
+
+// dialPipe connects to the named pipe name.
+func dialPipe(ctx context.Context, name string) (net.Conn, error) {
+	return dialPipePath(ctx, pipeDir+name)
+}
@@ -0,0 +1,9 @@
+//go:build windows && !psrp_nowinio
+
+package powershell
+
+import "github.com/Microsoft/go-winio"
+
+func init() {
+	builtinPipeDialer = winio.DialPipeContext
+}
@@ -0,0 +1,204 @@
+package powershell
+
+import (
+	"encoding/binary"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/fragments"
+	"github.com/smnsjas/go-psrpcore/messages"
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// pipelineStateFailed is the PSInvocationState of a pipeline that ended
+// with a terminating error.
+const pipelineStateFailed = 5
+
+// maxPipelineFailures bounds the failures kept for pipelines whose caller
+// never asks for them.
+const maxPipelineFailures = 64
+
+// PipelineFailureReporter is implemented by backends whose pipelines are
+// read by the RunspacePool's dispatch loop. They record the ErrorRecord of
+// the PIPELINE_STATE message that fails a pipeline, which go-psrpcore
+// reduces to the text of its error.
+type PipelineFailureReporter interface {
+	// PipelineFailure returns, and forgets, the terminating error of the
+	// pipeline id. It reports false if the pipeline did not fail or its
+	// PIPELINE_STATE message had no ErrorRecord.
+	PipelineFailure(id uuid.UUID) (*ErrorRecord, bool)
+}
+
+// ParsePipelineFailure returns the ErrorRecord of a PIPELINE_STATE
+// message whose state is Failed: the terminating error of the pipeline.
+// It reports false for the other states and for a message without one.
+func ParsePipelineFailure(data []byte) (*ErrorRecord, bool) {
+	objs, err := serialization.NewDeserializer().Deserialize(data)
+	if err != nil || len(objs) == 0 {
+		return nil, false
+	}
+	obj, ok := objs[0].(*serialization.PSObject)
+	if !ok {
+		return nil, false
+	}
+	member := func(name string) (interface{}, bool) {
+		if v, ok := recordValue(obj.Members, name); ok {
+			return v, true
+		}
+		return recordValue(obj.Properties, name)
+	}
+
+	state, ok := member("PipelineState")
+	if !ok {
+		return nil, false
+	}
+	if n, ok := recordNumber(unwrapRecord(state)); !ok || n != pipelineStateFailed {
+		return nil, false
+	}
+	record, ok := member("ExceptionAsErrorRecord")
+	if !ok {
+		return nil, false
+	}
+	return ParseErrorRecord(record)
+}
+
+// failureWatch records the terminating errors of failed pipelines from
+// what a RunspacePool reads. Only the fragments of PIPELINE_STATE messages
+// are kept; the others are skipped as they stream past.
+type failureWatch struct {
+	mu sync.Mutex
+
+	// header collects the header of the fragment being read, and
+	// remaining counts the bytes of its blob still to come
+	header    []byte
+	remaining int
+	objectID  uint64
+	end       bool
+
+	// pending holds the PIPELINE_STATE messages being assembled, and
+	// the first bytes of messages whose type is not known yet, by object ID
+	pending map[uint64][]byte
+
+	failures map[uuid.UUID]*ErrorRecord
+	order    []uuid.UUID
+}
+
+// watch returns t with its reads observed, for a new transport. Failures
+// already recorded are kept.
+func (w *failureWatch) watch(t io.ReadWriter) io.ReadWriter {
+	w.mu.Lock()
+	w.header = nil
+	w.remaining = 0
+	w.pending = nil
+	w.mu.Unlock()
+	return watchReads(t, w.observe)
+}
+
+// PipelineFailure implements PipelineFailureReporter.
+func (w *failureWatch) PipelineFailure(id uuid.UUID) (*ErrorRecord, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	rec, ok := w.failures[id]
+	if ok {
+		delete(w.failures, id)
+		w.order = slices.DeleteFunc(w.order, func(o uuid.UUID) bool { return o == id })
+	}
+	return rec, ok
+}
+
+// observe follows the fragments in data read from the server.
+func (w *failureWatch) observe(data []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(data) > 0 {
+		if len(w.header) < fragments.HeaderSize {
+			n := min(fragments.HeaderSize-len(w.header), len(data))
+			w.header = append(w.header, data[:n]...)
+			data = data[n:]
+			if len(w.header) < fragments.HeaderSize {
+				return
+			}
+			w.beginFragmentLocked()
+			if w.remaining == 0 {
+				w.endFragmentLocked()
+			}
+			continue
+		}
+
+		n := min(w.remaining, len(data))
+		w.blobLocked(data[:n])
+		data = data[n:]
+		w.remaining -= n
+		if w.remaining == 0 {
+			w.endFragmentLocked()
+		}
+	}
+}
+
+func (w *failureWatch) beginFragmentLocked() {
+	w.objectID = binary.BigEndian.Uint64(w.header[0:8])
+	flags := w.header[16]
+	w.end = flags&fragments.FlagEnd != 0
+	w.remaining = int(binary.BigEndian.Uint32(w.header[17:21]))
+	if flags&fragments.FlagStart != 0 {
+		if w.pending == nil {
+			w.pending = make(map[uint64][]byte)
+		}
+		w.pending[w.objectID] = nil
+	}
+}
+
+// blobLocked keeps the blob of a fragment if it belongs to a
+// PIPELINE_STATE message, deciding once the message type has arrived.
+func (w *failureWatch) blobLocked(chunk []byte) {
+	buf, ok := w.pending[w.objectID]
+	if !ok {
+		return
+	}
+	if len(buf) < 8 {
+		n := min(8-len(buf), len(chunk))
+		buf = append(buf, chunk[:n]...)
+		chunk = chunk[n:]
+		if len(buf) == 8 && messages.MessageType(binary.LittleEndian.Uint32(buf[4:8])) != messages.MessageTypePipelineState {
+			delete(w.pending, w.objectID)
+			return
+		}
+	}
+	w.pending[w.objectID] = append(buf, chunk...)
+}
+
+func (w *failureWatch) endFragmentLocked() {
+	w.header = w.header[:0]
+	if !w.end {
+		return
+	}
+	blob, ok := w.pending[w.objectID]
+	if !ok {
+		return
+	}
+	delete(w.pending, w.objectID)
+
+	msg, err := messages.Decode(blob)
+	if err != nil || msg.Type != messages.MessageTypePipelineState {
+		return
+	}
+	rec, ok := ParsePipelineFailure(msg.Data)
+	if !ok {
+		return
+	}
+	if w.failures == nil {
+		w.failures = make(map[uuid.UUID]*ErrorRecord)
+	}
+	if _, seen := w.failures[msg.PipelineID]; !seen {
+		w.order = append(w.order, msg.PipelineID)
+	}
+	w.failures[msg.PipelineID] = rec
+
+	// Forget the oldest failures nobody asked for
+	for len(w.order) > maxPipelineFailures {
+		delete(w.failures, w.order[0])
+		w.order = w.order[1:]
+	}
+}
@@ -0,0 +1,182 @@
+package powershell
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrpcore/messages"
+	"github.com/smnsjas/go-psrpcore/runspace"
+)
+
+// testPipelineState renders a PIPELINE_STATE message body; a non-nil err
+// is sent as its ExceptionAsErrorRecord.
+func testPipelineState(state int, err error) []byte {
+	refs := 1
+	body := `<I32 N="PipelineState">` + strconv.Itoa(state) + `</I32>`
+	if err != nil {
+		body += loopbackErrorRecord("ExceptionAsErrorRecord", err, &refs)
+	}
+	return []byte(`<Obj RefId="0"><MS>` + body + `</MS></Obj>`)
+}
+
+func TestParsePipelineFailure(t *testing.T) {
+	rec, ok := ParsePipelineFailure(testPipelineState(loopbackPipelineFailed, errors.New("disk full")))
+	if !ok {
+		t.Fatal("ParsePipelineFailure(Failed) ok = false")
+	}
+	if rec.Message != "disk full" || rec.FullyQualifiedErrorID != "RuntimeException" {
+		t.Errorf("record = %q, %q; want disk full, RuntimeException", rec.Message, rec.FullyQualifiedErrorID)
+	}
+
+	for name, data := range map[string][]byte{
+		"completed":        testPipelineState(loopbackPipelineDone, nil),
+		"failed no record": testPipelineState(loopbackPipelineFailed, nil),
+		"not clixml":       []byte("<Obj"),
+	} {
+		if _, ok := ParsePipelineFailure(data); ok {
+			t.Errorf("ParsePipelineFailure(%s) ok = true", name)
+		}
+	}
+}
+
+func TestFailureWatch_Observe(t *testing.T) {
+	failed, done := uuid.New(), uuid.New()
+	encode := func(objectID uint64, msg *messages.Message) []byte {
+		t.Helper()
+		msg.Destination = messages.DestinationClient
+		encoded, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+		data, err := fragmentMessage(objectID, encoded, 64)
+		if err != nil {
+			t.Fatalf("fragmentMessage() error = %v", err)
+		}
+		return data
+	}
+
+	var data []byte
+	data = append(data, encode(1, &messages.Message{
+		Type: messages.MessageTypePipelineOutput, PipelineID: failed,
+		Data: []byte(`<S>` + strings.Repeat("x", 500) + `</S>`),
+	})...)
+	data = append(data, encode(2, &messages.Message{
+		Type: messages.MessageTypePipelineState, PipelineID: failed,
+		Data: testPipelineState(loopbackPipelineFailed, errors.New("disk full")),
+	})...)
+	data = append(data, encode(3, &messages.Message{
+		Type: messages.MessageTypePipelineState, PipelineID: done,
+		Data: testPipelineState(loopbackPipelineDone, nil),
+	})...)
+
+	// Read a byte at a time
+	var w failureWatch
+	for i := range data {
+		w.observe(data[i : i+1])
+	}
+
+	rec, ok := w.PipelineFailure(failed)
+	if !ok || rec.Message != "disk full" {
+		t.Fatalf("PipelineFailure(failed) = %v, %v; want disk full", rec, ok)
+	}
+	if _, ok := w.PipelineFailure(failed); ok {
+		t.Error("PipelineFailure(failed) ok the second time")
+	}
+	if _, ok := w.PipelineFailure(done); ok {
+		t.Error("PipelineFailure(done) ok for a completed pipeline")
+	}
+	if len(w.pending) != 0 {
+		t.Errorf("pending = %d messages after all fragments ended", len(w.pending))
+	}
+}
+
+func TestFailureWatch_Bounded(t *testing.T) {
+	var w failureWatch
+	var ids []uuid.UUID
+	for i := 0; i < maxPipelineFailures+10; i++ {
+		id := uuid.New()
+		ids = append(ids, id)
+		msg := &messages.Message{
+			Destination: messages.DestinationClient,
+			Type:        messages.MessageTypePipelineState,
+			PipelineID:  id,
+			Data:        testPipelineState(loopbackPipelineFailed, errors.New("boom")),
+		}
+		encoded, err := msg.Encode()
+		if err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+		data, err := fragmentMessage(uint64(i+1), encoded, 1<<16) // #nosec G115 -- i is non-negative
+		if err != nil {
+			t.Fatalf("fragmentMessage() error = %v", err)
+		}
+		w.observe(data)
+	}
+
+	if len(w.failures) != maxPipelineFailures || len(w.order) != maxPipelineFailures {
+		t.Errorf("kept %d failures (%d ordered), want %d", len(w.failures), len(w.order), maxPipelineFailures)
+	}
+	if _, ok := w.PipelineFailure(ids[0]); ok {
+		t.Error("oldest failure kept")
+	}
+	if _, ok := w.PipelineFailure(ids[len(ids)-1]); !ok {
+		t.Error("newest failure dropped")
+	}
+}
+
+func TestLoopbackBackend_PipelineFailure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	poolID := uuid.New()
+	b := NewLoopbackBackend(poolID)
+	b.Fail("Remove-Thing", &ErrorRecord{
+		Message:               "access denied",
+		FullyQualifiedErrorID: "AccessDenied,Remove-Thing",
+		CategoryInfo:          CategoryInfo{Category: "PermissionDenied"},
+	})
+	if err := b.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	pool := runspace.New(b.Transport(), poolID)
+	if err := b.Init(ctx, pool); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	pool.StartDispatchLoop()
+	defer b.Close(ctx)
+
+	p, err := pool.CreatePipeline("Remove-Thing")
+	if err != nil {
+		t.Fatalf("CreatePipeline() error = %v", err)
+	}
+	if _, err := invokeLoopbackPipeline(ctx, t, b, p); err == nil {
+		t.Fatal("Remove-Thing succeeded, want a failure")
+	}
+
+	var reporter PipelineFailureReporter = b
+	rec, ok := reporter.PipelineFailure(p.ID())
+	if !ok {
+		t.Fatal("PipelineFailure() ok = false")
+	}
+	if rec.Message != "access denied" || rec.FullyQualifiedErrorID != "AccessDenied,Remove-Thing" ||
+		rec.CategoryInfo.Category != "PermissionDenied" {
+		t.Errorf("PipelineFailure() = %+v", rec)
+	}
+
+	// Pipelines that complete report nothing
+	p, err = pool.CreatePipeline("Hello")
+	if err != nil {
+		t.Fatalf("CreatePipeline() error = %v", err)
+	}
+	if _, err := invokeLoopbackPipeline(ctx, t, b, p); err != nil {
+		t.Fatalf("Hello error = %v", err)
+	}
+	if _, ok := reporter.PipelineFailure(p.ID()); ok {
+		t.Error("PipelineFailure() ok for a completed pipeline")
+	}
+}
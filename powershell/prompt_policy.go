@@ -0,0 +1,91 @@
+package powershell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrPromptRefused is returned to the script for prompts that a PromptPolicy
+// cannot answer.
+var ErrPromptRefused = errors.New("powershell: prompt refused by policy")
+
+// PromptPolicy is a HostInterface for unattended automation. It answers
+// prompts from fixed rules and never waits for input, so a script that
+// prompts unexpectedly fails fast instead of hanging.
+//
+// By default, choices (-Confirm, ShouldContinue) take their default, Prompt
+// fields take Values or their default, and Read-Host fails. Credential
+// prompts always fail; see ErrSecureStringNotSupported.
+type PromptPolicy struct {
+	// FailOnPrompt refuses every prompt, including choices with a default.
+	FailOnPrompt bool
+
+	// Input answers Read-Host. If nil, Read-Host fails with ErrPromptRefused.
+	Input func(ctx context.Context) (string, error)
+
+	// Values answers Prompt fields by name, e.g. missing mandatory parameters.
+	Values map[string]interface{}
+
+	// Output receives Write-Host and other host output. If nil, it is discarded.
+	Output func(kind HostOutput, text string)
+}
+
+var _ HostInterface = (*PromptPolicy)(nil)
+
+// NewFailOnPromptPolicy returns a policy that refuses every prompt.
+func NewFailOnPromptPolicy() *PromptPolicy {
+	return &PromptPolicy{FailOnPrompt: true}
+}
+
+// Write implements HostInterface.
+func (p *PromptPolicy) Write(_ context.Context, kind HostOutput, text string) {
+	if p.Output != nil {
+		p.Output(kind, text)
+	}
+}
+
+// ReadLine implements HostInterface using Input.
+func (p *PromptPolicy) ReadLine(ctx context.Context) (string, error) {
+	if p.FailOnPrompt || p.Input == nil {
+		return "", fmt.Errorf("%w: Read-Host", ErrPromptRefused)
+	}
+	return p.Input(ctx)
+}
+
+// Prompt implements HostInterface. Each field gets its entry in Values, or
+// its default value; a mandatory field without either is refused.
+func (p *PromptPolicy) Prompt(_ context.Context, _, _ string, fields []FieldDescription) (map[string]interface{}, error) {
+	if p.FailOnPrompt {
+		return nil, fmt.Errorf("%w: prompt for %s", ErrPromptRefused, fieldNames(fields))
+	}
+	values := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if v, ok := p.Values[f.Name]; ok {
+			values[f.Name] = v
+			continue
+		}
+		if f.DefaultValue == nil && f.IsMandatory {
+			return nil, fmt.Errorf("%w: no value for %s", ErrPromptRefused, f.Name)
+		}
+		values[f.Name] = f.DefaultValue
+	}
+	return values, nil
+}
+
+// PromptForChoice implements HostInterface by taking the default choice.
+// Choices without a default are refused.
+func (p *PromptPolicy) PromptForChoice(_ context.Context, caption, _ string, choices []ChoiceDescription, defaultChoice int) (int, error) {
+	if p.FailOnPrompt || defaultChoice < 0 || defaultChoice >= len(choices) {
+		return 0, fmt.Errorf("%w: choice %q", ErrPromptRefused, caption)
+	}
+	return defaultChoice, nil
+}
+
+func fieldNames(fields []FieldDescription) string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	return fmt.Sprint(names)
+}
@@ -0,0 +1,79 @@
+package powershell
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPromptPolicy_Defaults(t *testing.T) {
+	ctx := context.Background()
+	p := &PromptPolicy{Values: map[string]interface{}{"Name": "svc"}}
+
+	if _, err := p.ReadLine(ctx); !errors.Is(err, ErrPromptRefused) {
+		t.Errorf("ReadLine() error = %v, want ErrPromptRefused", err)
+	}
+
+	choices := []ChoiceDescription{{Label: "&Yes"}, {Label: "&No"}}
+	if got, err := p.PromptForChoice(ctx, "Confirm", "", choices, 1); err != nil || got != 1 {
+		t.Errorf("PromptForChoice() = %d, %v; want default 1", got, err)
+	}
+	if _, err := p.PromptForChoice(ctx, "Confirm", "", choices, -1); !errors.Is(err, ErrPromptRefused) {
+		t.Errorf("PromptForChoice() without default error = %v, want ErrPromptRefused", err)
+	}
+
+	values, err := p.Prompt(ctx, "", "", []FieldDescription{
+		{Name: "Name", IsMandatory: true},
+		{Name: "Count", DefaultValue: 3},
+	})
+	if err != nil {
+		t.Fatalf("Prompt() error = %v", err)
+	}
+	if values["Name"] != "svc" || values["Count"] != 3 {
+		t.Errorf("Prompt() = %v", values)
+	}
+
+	if _, err := p.Prompt(ctx, "", "", []FieldDescription{{Name: "Path", IsMandatory: true}}); !errors.Is(err, ErrPromptRefused) {
+		t.Errorf("Prompt() for unknown mandatory field error = %v, want ErrPromptRefused", err)
+	}
+}
+
+func TestPromptPolicy_FailOnPrompt(t *testing.T) {
+	ctx := context.Background()
+	p := NewFailOnPromptPolicy()
+	p.Input = func(context.Context) (string, error) { return "ignored", nil }
+
+	if _, err := p.ReadLine(ctx); !errors.Is(err, ErrPromptRefused) {
+		t.Errorf("ReadLine() error = %v, want ErrPromptRefused", err)
+	}
+	if _, err := p.PromptForChoice(ctx, "Confirm", "", []ChoiceDescription{{Label: "&Yes"}}, 0); !errors.Is(err, ErrPromptRefused) {
+		t.Errorf("PromptForChoice() error = %v, want ErrPromptRefused", err)
+	}
+	if _, err := p.Prompt(ctx, "", "", []FieldDescription{{Name: "Count", DefaultValue: 1}}); !errors.Is(err, ErrPromptRefused) {
+		t.Errorf("Prompt() error = %v, want ErrPromptRefused", err)
+	}
+}
+
+func TestPromptPolicy_InputAndOutput(t *testing.T) {
+	ctx := context.Background()
+	var written string
+	p := &PromptPolicy{
+		Input:  func(context.Context) (string, error) { return "y", nil },
+		Output: func(_ HostOutput, text string) { written += text },
+	}
+
+	if got, err := p.ReadLine(ctx); err != nil || got != "y" {
+		t.Errorf("ReadLine() = %q, %v", got, err)
+	}
+	p.Write(ctx, HostOutputDefault, "hello\n")
+	if written != "hello\n" {
+		t.Errorf("Output got %q", written)
+	}
+
+	// A refused prompt reaches the script as an error record
+	resp := string(HandleHostCall(ctx, NewFailOnPromptPolicy(), &HostCall{Method: HostMethodReadLine}))
+	if want := ErrPromptRefused.Error(); !strings.Contains(resp, want) {
+		t.Errorf("host response missing %q:\n%s", want, resp)
+	}
+}
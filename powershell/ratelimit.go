@@ -0,0 +1,18 @@
+package powershell
+
+import (
+	"context"
+	"io"
+
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+// rateLimited returns rw with its reads and writes paced by limiter, or rw
+// itself if limiter is nil. Waits end with ctx, which the backend cancels
+// when it closes the connection.
+func rateLimited(ctx context.Context, rw io.ReadWriter, limiter transport.RateLimiter) io.ReadWriter {
+	if limiter == nil {
+		return rw
+	}
+	return transport.NewRateLimitedReadWriter(ctx, rw, limiter)
+}
@@ -0,0 +1,194 @@
+package powershell
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+// coalescePollsBeforeProbe is how many shell-level polls in a row may bring
+// nothing for a waiting command before it probes with a receive of its own,
+// while it is not known whether the server coalesces.
+const coalescePollsBeforeProbe = 2
+
+// muxMode is what a receiveMux knows about the server.
+type muxMode int
+
+const (
+	// muxProbing: no command output has come from a shell-level receive yet.
+	muxProbing muxMode = iota
+	// muxCoalesced: shell-level receives carry command output.
+	muxCoalesced
+	// muxDirect: they do not; every transport receives on its own.
+	muxDirect
+)
+
+// receiveMux coalesces the Receive polls of the transports of one shell.
+// Instead of each idle pipeline long-polling with its own CommandId, one
+// shell-level Receive (a DesiredStream without CommandId) is outstanding at
+// a time, and the stdout chunks of its response are queued by their
+// CommandId for the transports to take. Chunks without one are the pool's.
+//
+// Not every endpoint returns command output to shell-level receives. Until
+// one does, a command that got nothing from coalescePollsBeforeProbe polls
+// probes with a receive of its own; output from the probe shows the server
+// does not coalesce, and the mux falls back to per-command receives.
+type receiveMux struct {
+	mu      sync.Mutex
+	mode    muxMode
+	polling bool          // a shell-level Receive is in flight
+	polled  chan struct{} // closed when it completes
+	// queues hold received output by upper-case command ID ("" for the shell)
+	queues map[string]*muxQueue
+}
+
+// muxQueue is the output received for one command and not yet taken.
+type muxQueue struct {
+	data     []byte
+	done     bool
+	state    string
+	exitCode int
+	// idlePolls counts the shell-level polls that brought nothing for it
+	idlePolls int
+}
+
+func newReceiveMux() *receiveMux {
+	return &receiveMux{queues: make(map[string]*muxQueue)}
+}
+
+// queue returns the queue of a command, creating it.
+func (m *receiveMux) queue(key string) *muxQueue {
+	q, ok := m.queues[key]
+	if !ok {
+		q = &muxQueue{}
+		m.queues[key] = q
+	}
+	return q
+}
+
+// register makes a command's output kept from the next poll on. It is
+// called before the command is created, so no early output is lost.
+func (m *receiveMux) register(commandID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue(strings.ToUpper(commandID))
+}
+
+// release drops the queue of a command that is no longer read.
+func (m *receiveMux) release(commandID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.queues, strings.ToUpper(commandID))
+}
+
+// receive returns the next output of a command (commandID "" for the
+// shell), like PoolClient.Receive, polling for the whole shell if no poll
+// is in flight and waiting for the one in flight otherwise.
+func (m *receiveMux) receive(ctx context.Context, client PoolClient, epr *wsman.EndpointReference, commandID string) (*wsman.ReceiveResult, error) {
+	key := strings.ToUpper(commandID)
+	for {
+		m.mu.Lock()
+		if m.mode == muxDirect {
+			m.mu.Unlock()
+			return client.Receive(ctx, epr, commandID)
+		}
+		q := m.queue(key)
+		if len(q.data) > 0 || q.done {
+			result := &wsman.ReceiveResult{
+				Stdout:       q.data,
+				CommandState: q.state,
+				ExitCode:     q.exitCode,
+				Done:         q.done,
+				CommandID:    commandID,
+			}
+			q.data = nil
+			if q.done {
+				delete(m.queues, key)
+			}
+			m.mu.Unlock()
+			return result, nil
+		}
+		if key != "" && m.mode == muxProbing && q.idlePolls >= coalescePollsBeforeProbe {
+			q.idlePolls = 0
+			m.mu.Unlock()
+			return m.probe(ctx, client, epr, commandID)
+		}
+		if m.polling {
+			polled := m.polled
+			m.mu.Unlock()
+			select {
+			case <-polled:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		m.polling = true
+		m.polled = make(chan struct{})
+		m.mu.Unlock()
+
+		result, err := client.Receive(ctx, epr, "")
+
+		m.mu.Lock()
+		m.polling = false
+		close(m.polled)
+		if err != nil {
+			// The waiting transports poll again themselves
+			m.mu.Unlock()
+			return nil, err
+		}
+		m.dispatch(result)
+		m.mu.Unlock()
+	}
+}
+
+// probe receives for one command on its own. Output means the server keeps
+// command output from shell-level receives.
+func (m *receiveMux) probe(ctx context.Context, client PoolClient, epr *wsman.EndpointReference, commandID string) (*wsman.ReceiveResult, error) {
+	result, err := client.Receive(ctx, epr, commandID)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Stdout) > 0 || result.Done {
+		m.mu.Lock()
+		if m.mode == muxProbing {
+			m.mode = muxDirect
+		}
+		m.mu.Unlock()
+	}
+	return result, nil
+}
+
+// dispatch queues the output of a shell-level poll by command. m.mu is held.
+func (m *receiveMux) dispatch(result *wsman.ReceiveResult) {
+	got := make(map[string]bool)
+	for _, chunk := range result.Streams {
+		if chunk.Name != wsman.StreamStdout || len(chunk.Data) == 0 {
+			continue
+		}
+		key := strings.ToUpper(chunk.CommandID)
+		q := m.queue(key)
+		q.data = append(q.data, chunk.Data...)
+		got[key] = true
+		if key != "" && m.mode == muxProbing {
+			m.mode = muxCoalesced
+		}
+	}
+	if result.Done {
+		key := strings.ToUpper(result.CommandID)
+		q := m.queue(key)
+		q.done = true
+		q.state = result.CommandState
+		q.exitCode = result.ExitCode
+		got[key] = true
+	}
+	for key, q := range m.queues {
+		if key == "" || got[key] {
+			q.idlePolls = 0
+		} else {
+			q.idlePolls++
+		}
+	}
+}
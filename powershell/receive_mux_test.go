@@ -0,0 +1,114 @@
+package powershell
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+// TestReceiveMux_Coalesces verifies one shell-level Receive serves the
+// output of several commands.
+func TestReceiveMux_Coalesces(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	mock := &mockTransportClient{
+		receiveFunc: func(_ context.Context, _ *wsman.EndpointReference, commandID string) (*wsman.ReceiveResult, error) {
+			mu.Lock()
+			calls[commandID]++
+			mu.Unlock()
+			return &wsman.ReceiveResult{
+				Streams: []wsman.StreamChunk{
+					{Name: wsman.StreamStdout, CommandID: "CMD-1", Data: []byte("one")},
+					{Name: wsman.StreamStdout, CommandID: "CMD-2", Data: []byte("two")},
+				},
+				CommandID: "CMD-2",
+				Done:      true,
+			}, nil
+		},
+	}
+
+	mux := newReceiveMux()
+	mux.register("CMD-1")
+	mux.register("CMD-2")
+	t1 := NewWSManTransport(mock, dummyPoolEPR(), "cmd-1")
+	t1.setMux(mux)
+	t2 := NewWSManTransport(mock, dummyPoolEPR(), "CMD-2")
+	t2.setMux(mux)
+
+	buf := make([]byte, 16)
+	n, err := t1.Read(buf)
+	if err != nil || string(buf[:n]) != "one" {
+		t.Fatalf("Read cmd-1 = %q, %v; want one", buf[:n], err)
+	}
+	n, err = t2.Read(buf)
+	if err != nil || string(buf[:n]) != "two" {
+		t.Fatalf("Read CMD-2 = %q, %v; want two", buf[:n], err)
+	}
+	if _, err := t2.Read(buf); err != io.EOF {
+		t.Errorf("Read CMD-2 after done = %v, want EOF", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls[""] != 1 || len(calls) != 1 {
+		t.Errorf("Receive calls = %v, want one shell-level receive", calls)
+	}
+	if mux.mode != muxCoalesced {
+		t.Errorf("mode = %v, want coalesced", mux.mode)
+	}
+}
+
+// TestReceiveMux_FallsBackToDirect verifies commands receive on their own
+// when shell-level receives bring no command output.
+func TestReceiveMux_FallsBackToDirect(t *testing.T) {
+	mock := &mockTransportClient{
+		receiveFunc: func(_ context.Context, _ *wsman.EndpointReference, commandID string) (*wsman.ReceiveResult, error) {
+			if commandID == "" {
+				return &wsman.ReceiveResult{}, nil
+			}
+			return &wsman.ReceiveResult{Stdout: []byte("direct")}, nil
+		},
+	}
+
+	mux := newReceiveMux()
+	mux.register("CMD-1")
+	transport := NewWSManTransport(mock, dummyPoolEPR(), "CMD-1")
+	transport.setMux(mux)
+
+	buf := make([]byte, 16)
+	n, err := transport.Read(buf)
+	if err != nil || string(buf[:n]) != "direct" {
+		t.Fatalf("Read = %q, %v; want direct", buf[:n], err)
+	}
+	if mux.mode != muxDirect {
+		t.Errorf("mode = %v, want direct", mux.mode)
+	}
+}
+
+// TestReceiveMux_ShellOutput verifies output without a CommandId goes to
+// the pool's transport.
+func TestReceiveMux_ShellOutput(t *testing.T) {
+	mock := &mockTransportClient{
+		receiveFunc: func(_ context.Context, _ *wsman.EndpointReference, _ string) (*wsman.ReceiveResult, error) {
+			return &wsman.ReceiveResult{
+				Streams: []wsman.StreamChunk{{Name: wsman.StreamStdout, Data: []byte("pool")}},
+			}, nil
+		},
+	}
+
+	mux := newReceiveMux()
+	transport := NewWSManTransport(mock, dummyPoolEPR(), "")
+	transport.setMux(mux)
+
+	buf := make([]byte, 16)
+	n, err := transport.Read(buf)
+	if err != nil || string(buf[:n]) != "pool" {
+		t.Fatalf("Read = %q, %v; want pool", buf[:n], err)
+	}
+	if mux.mode != muxProbing {
+		t.Errorf("mode = %v, want probing", mux.mode)
+	}
+}
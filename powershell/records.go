@@ -0,0 +1,337 @@
+package powershell
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+// ErrorRecord is an error written to the error stream, as serialized by
+// ErrorRecord.ToPSObjectForRemoting.
+type ErrorRecord struct {
+	// Message is the error message: ErrorDetails.Message if the error has
+	// one, else the message of the exception.
+	Message string
+
+	// FullyQualifiedErrorID identifies the error, e.g.
+	// "PathNotFound,Microsoft.PowerShell.Commands.GetChildItemCommand".
+	FullyQualifiedErrorID string
+
+	// CategoryInfo classifies the error.
+	CategoryInfo CategoryInfo
+
+	// ScriptStackTrace is the script call stack at the error.
+	ScriptStackTrace string
+
+	// PositionMessage locates the error in the script, e.g.
+	// "At line:1 char:1\n+ Get-ChildItem C:\missing".
+	PositionMessage string
+
+	// RecommendedAction is ErrorDetails.RecommendedAction.
+	RecommendedAction string
+
+	// TargetObject is the object the error is about, if any.
+	TargetObject interface{}
+
+	// Exception is the deserialized exception.
+	Exception interface{}
+
+	// Record is the deserialized ErrorRecord.
+	Record interface{}
+}
+
+// Error returns the message, so an ErrorRecord can be returned as an
+// error.
+func (e *ErrorRecord) Error() string {
+	return e.Message
+}
+
+// CategoryInfo is the ErrorCategoryInfo of an ErrorRecord.
+type CategoryInfo struct {
+	// Category is the name of the ErrorCategory, e.g. "ObjectNotFound".
+	Category string
+
+	Activity   string
+	Reason     string
+	TargetName string
+	TargetType string
+
+	// Message is the category as PowerShell prints it, e.g.
+	// "ObjectNotFound: (C:\missing:String) [Get-ChildItem], ItemNotFoundException".
+	Message string
+}
+
+// ProgressRecord is a record written to the progress stream by
+// Write-Progress.
+type ProgressRecord struct {
+	Activity          string
+	ActivityID        int
+	ParentActivityID  int
+	StatusDescription string
+	CurrentOperation  string
+
+	// PercentComplete is -1 if not reported.
+	PercentComplete int
+
+	// SecondsRemaining is -1 if not reported.
+	SecondsRemaining int
+
+	// Completed is set on the record that ends the activity.
+	Completed bool
+}
+
+// InformationRecord is a record written to the information stream by
+// Write-Information or Write-Host.
+type InformationRecord struct {
+	// MessageData is the object written; a HostInformationMessage for
+	// Write-Host.
+	MessageData interface{}
+
+	Source        string
+	TimeGenerated time.Time
+	Tags          []string
+	User          string
+	Computer      string
+	ProcessID     int
+
+	// Record is the deserialized InformationRecord.
+	Record interface{}
+}
+
+// Message returns the text of MessageData.
+func (r *InformationRecord) Message() string {
+	if props := recordProperties(r.MessageData); props != nil {
+		// HostInformationMessage
+		if msg, ok := recordValue(props, "Message"); ok {
+			return recordText(msg)
+		}
+	}
+	return recordText(r.MessageData)
+}
+
+// errorCategories are the names of System.Management.Automation.ErrorCategory
+// values, which are serialized as numbers.
+var errorCategories = []string{
+	"NotSpecified", "OpenError", "CloseError", "DeviceError",
+	"DeadlockDetected", "InvalidArgument", "InvalidData", "InvalidOperation",
+	"InvalidResult", "InvalidType", "MetadataError", "NotImplemented",
+	"NotInstalled", "ObjectNotFound", "OperationStopped", "OperationTimeout",
+	"SyntaxError", "ParserError", "PermissionDenied", "ResourceBusy",
+	"ResourceExists", "ResourceUnavailable", "ReadError", "WriteError",
+	"FromStdErr", "SecurityError", "ProtocolError", "ConnectionError",
+	"AuthenticationError", "LimitsExceeded", "QuotaExceeded", "NotEnabled",
+}
+
+// ParseErrorRecord converts an object of the error stream. It reports
+// false if v is not an object, e.g. a string a host wrote as an error.
+func ParseErrorRecord(v interface{}) (*ErrorRecord, bool) {
+	props := recordProperties(v)
+	if props == nil {
+		return nil, false
+	}
+	exception, _ := recordValue(props, "Exception")
+	target, _ := recordValue(props, "TargetObject")
+	rec := &ErrorRecord{
+		Message:               recordString(props, "ErrorDetails_Message"),
+		FullyQualifiedErrorID: recordString(props, "FullyQualifiedErrorId"),
+		CategoryInfo: CategoryInfo{
+			Category:   errorCategory(props),
+			Activity:   recordString(props, "ErrorCategory_Activity"),
+			Reason:     recordString(props, "ErrorCategory_Reason"),
+			TargetName: recordString(props, "ErrorCategory_TargetName"),
+			TargetType: recordString(props, "ErrorCategory_TargetType"),
+			Message:    recordString(props, "ErrorCategory_Message"),
+		},
+		ScriptStackTrace:  recordString(props, "ErrorDetails_ScriptStackTrace"),
+		PositionMessage:   strings.TrimSpace(recordString(props, "InvocationInfo_PositionMessage")),
+		RecommendedAction: recordString(props, "ErrorDetails_RecommendedAction"),
+		TargetObject:      target,
+		Exception:         exception,
+		Record:            v,
+	}
+	if rec.Message == "" {
+		if exProps := recordProperties(exception); exProps != nil {
+			rec.Message = recordString(exProps, "Message")
+		}
+	}
+	if rec.Message == "" {
+		rec.Message = recordText(v)
+	}
+	return rec, true
+}
+
+// ParseProgressRecord converts an object of the progress stream. It
+// reports false if v is not an object.
+func ParseProgressRecord(v interface{}) (*ProgressRecord, bool) {
+	props := recordProperties(v)
+	if props == nil {
+		return nil, false
+	}
+	rec := &ProgressRecord{
+		Activity:          recordString(props, "Activity"),
+		StatusDescription: recordString(props, "StatusDescription"),
+		CurrentOperation:  recordString(props, "CurrentOperation"),
+		PercentComplete:   -1,
+		SecondsRemaining:  -1,
+	}
+	rec.ActivityID, _ = recordInt(props, "ActivityId")
+	rec.ParentActivityID, _ = recordInt(props, "ParentActivityId")
+	if n, ok := recordInt(props, "PercentComplete"); ok {
+		rec.PercentComplete = n
+	}
+	if n, ok := recordInt(props, "SecondsRemaining"); ok {
+		rec.SecondsRemaining = n
+	}
+	// ProgressRecordType: Processing (0) or Completed (1)
+	if t, ok := recordValue(props, "Type"); ok {
+		if obj, isObj := t.(*serialization.PSObject); isObj && obj.ToString != "" {
+			rec.Completed = obj.ToString == "Completed"
+		} else if n, isInt := recordNumber(unwrapRecord(t)); isInt {
+			rec.Completed = n == 1
+		} else {
+			rec.Completed = recordText(t) == "Completed"
+		}
+	}
+	return rec, true
+}
+
+// ParseInformationRecord converts an object of the information stream. It
+// reports false if v is not an object.
+func ParseInformationRecord(v interface{}) (*InformationRecord, bool) {
+	props := recordProperties(v)
+	if props == nil {
+		return nil, false
+	}
+	data, _ := recordValue(props, "MessageData")
+	rec := &InformationRecord{
+		MessageData: data,
+		Source:      recordString(props, "Source"),
+		User:        recordString(props, "User"),
+		Computer:    recordString(props, "Computer"),
+		Record:      v,
+	}
+	if t, ok := recordValue(props, "TimeGenerated"); ok {
+		rec.TimeGenerated, _ = unwrapRecord(t).(time.Time)
+	}
+	if tags, ok := recordValue(props, "Tags"); ok {
+		if list, isList := unwrapRecord(tags).([]interface{}); isList {
+			for _, tag := range list {
+				rec.Tags = append(rec.Tags, recordText(tag))
+			}
+		}
+	}
+	if n, ok := recordInt(props, "ProcessId"); ok {
+		rec.ProcessID = n
+	}
+	return rec, true
+}
+
+// errorCategory returns the name of the ErrorCategory_Category number.
+func errorCategory(props map[string]interface{}) string {
+	v, ok := recordValue(props, "ErrorCategory_Category")
+	if !ok {
+		return ""
+	}
+	if obj, isObj := v.(*serialization.PSObject); isObj && obj.ToString != "" {
+		return obj.ToString
+	}
+	n, isInt := recordNumber(unwrapRecord(v))
+	if !isInt {
+		return recordText(v)
+	}
+	if n >= 0 && n < len(errorCategories) {
+		return errorCategories[n]
+	}
+	return fmt.Sprint(n)
+}
+
+// recordProperties returns the properties of a deserialized object, or nil.
+func recordProperties(v interface{}) map[string]interface{} {
+	switch obj := v.(type) {
+	case *serialization.PSObject:
+		if len(obj.Properties) > 0 {
+			return obj.Properties
+		}
+		if m, ok := obj.Value.(map[string]interface{}); ok {
+			return m
+		}
+	case map[string]interface{}:
+		return obj
+	}
+	return nil
+}
+
+// recordValue finds a property case-insensitively.
+func recordValue(props map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := props[name]; ok {
+		return v, true
+	}
+	for key, v := range props {
+		if strings.EqualFold(key, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// unwrapRecord returns the value of a primitive the deserializer kept in a
+// PSObject.
+func unwrapRecord(v interface{}) interface{} {
+	if obj, ok := v.(*serialization.PSObject); ok && obj.Value != nil {
+		return obj.Value
+	}
+	return v
+}
+
+func recordString(props map[string]interface{}, name string) string {
+	v, _ := recordValue(props, name)
+	return recordText(v)
+}
+
+// recordText returns v as text: strings as is, objects by their ToString.
+func recordText(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case *serialization.PSObject:
+		if val.ToString != "" {
+			return val.ToString
+		}
+		if val.Value != nil {
+			return recordText(val.Value)
+		}
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func recordInt(props map[string]interface{}, name string) (int, bool) {
+	v, ok := recordValue(props, name)
+	if !ok {
+		return 0, false
+	}
+	return recordNumber(unwrapRecord(v))
+}
+
+func recordNumber(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case int:
+		return n, true
+	case uint32:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case uint8:
+		return int(n), true
+	}
+	return 0, false
+}
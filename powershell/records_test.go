@@ -0,0 +1,163 @@
+package powershell
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrpcore/serialization"
+)
+
+func TestParseErrorRecord(t *testing.T) {
+	exception := &serialization.PSObject{
+		ToString: "System.Management.Automation.ItemNotFoundException: Cannot find path 'C:\\missing'.",
+		Properties: map[string]interface{}{
+			"Message": "Cannot find path 'C:\\missing' because it does not exist.",
+		},
+	}
+	record := &serialization.PSObject{
+		ToString: "Cannot find path 'C:\\missing' because it does not exist.",
+		Properties: map[string]interface{}{
+			"Exception":                      exception,
+			"TargetObject":                   "C:\\missing",
+			"FullyQualifiedErrorId":          "PathNotFound,Microsoft.PowerShell.Commands.GetChildItemCommand",
+			"ErrorCategory_Category":         int32(13),
+			"ErrorCategory_Activity":         "Get-ChildItem",
+			"ErrorCategory_Reason":           "ItemNotFoundException",
+			"ErrorCategory_TargetName":       "C:\\missing",
+			"ErrorCategory_TargetType":       "String",
+			"ErrorCategory_Message":          "ObjectNotFound: (C:\\missing:String) [Get-ChildItem], ItemNotFoundException",
+			"ErrorDetails_ScriptStackTrace":  "at <ScriptBlock>, <No file>: line 1",
+			"InvocationInfo_PositionMessage": "At line:1 char:1\n+ Get-ChildItem C:\\missing\n",
+		},
+	}
+
+	rec, ok := ParseErrorRecord(record)
+	if !ok {
+		t.Fatal("ParseErrorRecord() ok = false")
+	}
+	if rec.Message != "Cannot find path 'C:\\missing' because it does not exist." {
+		t.Errorf("Message = %q, want the exception message", rec.Message)
+	}
+	if rec.FullyQualifiedErrorID != "PathNotFound,Microsoft.PowerShell.Commands.GetChildItemCommand" {
+		t.Errorf("FullyQualifiedErrorID = %q", rec.FullyQualifiedErrorID)
+	}
+	want := CategoryInfo{
+		Category:   "ObjectNotFound",
+		Activity:   "Get-ChildItem",
+		Reason:     "ItemNotFoundException",
+		TargetName: "C:\\missing",
+		TargetType: "String",
+		Message:    "ObjectNotFound: (C:\\missing:String) [Get-ChildItem], ItemNotFoundException",
+	}
+	if rec.CategoryInfo != want {
+		t.Errorf("CategoryInfo = %+v, want %+v", rec.CategoryInfo, want)
+	}
+	if rec.ScriptStackTrace != "at <ScriptBlock>, <No file>: line 1" {
+		t.Errorf("ScriptStackTrace = %q", rec.ScriptStackTrace)
+	}
+	if rec.PositionMessage != "At line:1 char:1\n+ Get-ChildItem C:\\missing" {
+		t.Errorf("PositionMessage = %q, want it trimmed", rec.PositionMessage)
+	}
+	if rec.TargetObject != "C:\\missing" || rec.Exception != exception || rec.Record != record {
+		t.Errorf("TargetObject, Exception or Record not kept: %+v", rec)
+	}
+	if rec.Error() != rec.Message {
+		t.Errorf("Error() = %q, want the message", rec.Error())
+	}
+}
+
+func TestParseErrorRecord_Fallbacks(t *testing.T) {
+	rec, ok := ParseErrorRecord(&serialization.PSObject{
+		ToString: "boom",
+		Properties: map[string]interface{}{
+			"ErrorDetails_Message":   "details win",
+			"Exception":              &serialization.PSObject{Properties: map[string]interface{}{"Message": "boom"}},
+			"ErrorCategory_Category": &serialization.PSObject{ToString: "InvalidOperation", Value: int32(7)},
+		},
+	})
+	if !ok {
+		t.Fatal("ParseErrorRecord() ok = false")
+	}
+	if rec.Message != "details win" {
+		t.Errorf("Message = %q, want ErrorDetails_Message", rec.Message)
+	}
+	if rec.CategoryInfo.Category != "InvalidOperation" {
+		t.Errorf("Category = %q, want InvalidOperation", rec.CategoryInfo.Category)
+	}
+
+	rec, _ = ParseErrorRecord(map[string]interface{}{"ErrorCategory_Category": int32(99)})
+	if rec.CategoryInfo.Category != "99" {
+		t.Errorf("Category = %q, want the number of an unknown category", rec.CategoryInfo.Category)
+	}
+
+	if _, ok := ParseErrorRecord("plain text"); ok {
+		t.Error("ParseErrorRecord(string) ok = true")
+	}
+}
+
+func TestParseProgressRecord(t *testing.T) {
+	rec, ok := ParseProgressRecord(&serialization.PSObject{Properties: map[string]interface{}{
+		"Activity":          "Copying",
+		"ActivityId":        int32(1),
+		"ParentActivityId":  int32(-1),
+		"StatusDescription": "3 of 10",
+		"CurrentOperation":  "a.txt",
+		"PercentComplete":   int32(30),
+		"SecondsRemaining":  int32(-1),
+		"Type":              &serialization.PSObject{ToString: "Processing", Value: int32(0)},
+	}})
+	if !ok {
+		t.Fatal("ParseProgressRecord() ok = false")
+	}
+	want := ProgressRecord{
+		Activity:          "Copying",
+		ActivityID:        1,
+		ParentActivityID:  -1,
+		StatusDescription: "3 of 10",
+		CurrentOperation:  "a.txt",
+		PercentComplete:   30,
+		SecondsRemaining:  -1,
+	}
+	if *rec != want {
+		t.Errorf("ParseProgressRecord() = %+v, want %+v", *rec, want)
+	}
+
+	rec, _ = ParseProgressRecord(map[string]interface{}{"Activity": "Copying", "Type": int32(1)})
+	if !rec.Completed || rec.PercentComplete != -1 {
+		t.Errorf("ParseProgressRecord() = %+v, want a completed record without a percentage", *rec)
+	}
+}
+
+func TestParseInformationRecord(t *testing.T) {
+	generated := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	rec, ok := ParseInformationRecord(&serialization.PSObject{Properties: map[string]interface{}{
+		"MessageData": &serialization.PSObject{
+			ToString:   "hello",
+			Properties: map[string]interface{}{"Message": "hello", "NoNewLine": false},
+		},
+		"Source":        "Write-Host",
+		"TimeGenerated": generated,
+		"Tags":          &serialization.PSObject{Value: []interface{}{"PSHOST"}},
+		"User":          "CONTOSO\\svc",
+		"Computer":      "SERVER01",
+		"ProcessId":     uint32(4242),
+	}})
+	if !ok {
+		t.Fatal("ParseInformationRecord() ok = false")
+	}
+	if rec.Message() != "hello" || rec.Source != "Write-Host" || rec.User != "CONTOSO\\svc" || rec.Computer != "SERVER01" {
+		t.Errorf("ParseInformationRecord() = %+v", rec)
+	}
+	if !rec.TimeGenerated.Equal(generated) || rec.ProcessID != 4242 {
+		t.Errorf("TimeGenerated = %v, ProcessID = %d", rec.TimeGenerated, rec.ProcessID)
+	}
+	if !slices.Equal(rec.Tags, []string{"PSHOST"}) {
+		t.Errorf("Tags = %v, want [PSHOST]", rec.Tags)
+	}
+
+	rec, _ = ParseInformationRecord(map[string]interface{}{"MessageData": int32(42)})
+	if rec.Message() != "42" {
+		t.Errorf("Message() = %q, want 42", rec.Message())
+	}
+}
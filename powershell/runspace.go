@@ -24,8 +24,10 @@ type PoolClient interface {
 	Receive(ctx context.Context, epr *wsman.EndpointReference, commandID string) (*wsman.ReceiveResult, error)
 	Signal(ctx context.Context, epr *wsman.EndpointReference, commandID, code string) error
 	Disconnect(ctx context.Context, epr *wsman.EndpointReference) error
-	Reconnect(ctx context.Context, shellID string) error
-	Connect(ctx context.Context, shellID string, connectXML string) ([]byte, error)
+	DisconnectWithOptions(ctx context.Context, epr *wsman.EndpointReference, opts wsman.DisconnectOptions) error
+	ReconnectWithResourceURI(ctx context.Context, resourceURI, shellID string) error
+	ConnectWithResourceURI(ctx context.Context, resourceURI, shellID string, connectXML string) ([]byte, error)
+	ConnectCommand(ctx context.Context, epr *wsman.EndpointReference, commandID string) error
 	CloseIdleConnections()
 }
 
@@ -40,24 +42,46 @@ var (
 type WSManBackend struct {
 	mu sync.RWMutex
 
-	client    PoolClient
-	epr       *wsman.EndpointReference
-	shellID   string // Kept for ShellID() compatibility and Reconnect
-	opened    bool
-	closed    bool
-	transport *WSManTransport // Reference to the transport for configuration
+	client  PoolClient
+	epr     *wsman.EndpointReference
+	shellID string // Kept for ShellID() compatibility and Reconnect
+	opened  bool
+	closed  bool
+	// disconnected is set by Disconnect until the shell is reconnected.
+	disconnected bool
+	transport    *WSManTransport // Reference to the transport for configuration
+	// gate holds the I/O of running pipelines while disconnected.
+	gate *connGate
 	// idleTimeout is the WSMan shell idle timeout (ISO8601 duration string).
 	idleTimeout string
+	// bufferMode is sent with Disconnect (default: Block).
+	bufferMode wsman.BufferMode
+	// mux coalesces the receives of the pipelines (nil: each its own).
+	mux *receiveMux
 	// resourceURI is the WSMan Resource URI (default: Microsoft.PowerShell)
 	resourceURI string
+	// applicationArguments are sent with INIT_RUNSPACEPOOL.
+	applicationArguments map[string]interface{}
+
+	// hostInfo, if set, is sent as the pool's host in INIT_RUNSPACEPOOL.
+	hostInfo *HostInfo
+
+	// capability records the server's SESSION_CAPABILITY.
+	capability capabilityWatch
 }
 
 // NewWSManBackend creates a new WSManBackend using the given WSMan client.
 func NewWSManBackend(client PoolClient, transport *WSManTransport) *WSManBackend {
+	gate := newConnGate()
+	if transport != nil {
+		transport.setGate(gate)
+	}
 	return &WSManBackend{
 		client:      client,
 		transport:   transport,
+		gate:        gate,
 		resourceURI: wsman.ResourceURIPowerShell, // Default
+		bufferMode:  wsman.BufferModeBlock,
 	}
 }
 
@@ -96,6 +120,50 @@ func (b *WSManBackend) SetIdleTimeout(duration string) {
 	b.idleTimeout = duration
 }
 
+// SetBufferMode sets what the server does with the output of running
+// pipelines while the shell is disconnected (default: wsman.BufferModeBlock).
+func (b *WSManBackend) SetBufferMode(mode wsman.BufferMode) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bufferMode = mode
+}
+
+// SetReceiveCoalescing makes the pipelines of the shell share shell-level
+// Receive requests (a DesiredStream without CommandId) instead of each
+// long-polling for its own output, so dozens of idle pipelines cost one
+// outstanding request. If the server does not return command output to
+// shell-level receives, pipelines go back to receiving on their own.
+// Call it before Init.
+func (b *WSManBackend) SetReceiveCoalescing(enabled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.mux = nil
+	if enabled {
+		b.mux = newReceiveMux()
+	}
+	if b.transport != nil {
+		b.transport.setMux(b.mux)
+	}
+}
+
+// SetApplicationArguments sets values passed to the server when the pool
+// is created, visible there as $PSSenderInfo.ApplicationArguments. See
+// ValidateApplicationArguments for the types allowed.
+func (b *WSManBackend) SetApplicationArguments(args map[string]interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.applicationArguments = args
+}
+
+// SetHostInfo makes the pool's host present, with info as its console, so
+// the server has a $Host.UI.RawUI. Only set it if pipelines get their own
+// host (EnableHostInfo); host calls for the pool itself are not answered.
+func (b *WSManBackend) SetHostInfo(info HostInfo) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hostInfo = &info
+}
+
 // ShellID returns the WSMan shell ID for this pool.
 func (b *WSManBackend) ShellID() string {
 	b.mu.RLock()
@@ -137,6 +205,16 @@ func (b *WSManBackend) Init(ctx context.Context, pool *runspace.Pool) error {
 	if err != nil {
 		return err
 	}
+	if len(b.applicationArguments) > 0 {
+		if frags, err = withApplicationArguments(frags, b.applicationArguments); err != nil {
+			return err
+		}
+	}
+	if b.hostInfo != nil {
+		if frags, err = EnableHostRawUI(frags, *b.hostInfo); err != nil {
+			return err
+		}
+	}
 	creationXML := base64.StdEncoding.EncodeToString(frags)
 
 	// 2. Create WSMan Shell
@@ -179,9 +257,16 @@ func (b *WSManBackend) Init(ctx context.Context, pool *runspace.Pool) error {
 
 	// 3. Open PSRP pool (skip handshake send as we did it in Create)
 	pool.SkipHandshakeSend = true
+	pool.SetTransport(b.capability.watch(b.transport))
 	return pool.Open(ctx)
 }
 
+// ServerCapability returns the server's SESSION_CAPABILITY from the last
+// Init or Reattach.
+func (b *WSManBackend) ServerCapability() (SessionCapability, bool) {
+	return b.capability.ServerCapability()
+}
+
 // Close terminates all pipelines and closes the WSMan shell.
 func (b *WSManBackend) Close(ctx context.Context) error {
 	b.mu.Lock()
@@ -217,9 +302,16 @@ func (b *WSManBackend) SupportsPSRPKeepalive() bool {
 	return false
 }
 
+// Capabilities returns WSManCapabilities.
+func (b *WSManBackend) Capabilities() Capabilities {
+	return WSManCapabilities
+}
+
 // PreparePipeline creates the WSMan command and returns a per-pipeline transport.
 
-// Disconnect disconnects the WSMan shell.
+// Disconnect disconnects the WSMan shell. Running pipelines stay on the
+// server and buffer their output; their local transports wait until
+// Reconnect resumes them (or Abandon releases them).
 func (b *WSManBackend) Disconnect(ctx context.Context) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -231,22 +323,85 @@ func (b *WSManBackend) Disconnect(ctx context.Context) error {
 		return ErrPoolNotOpened
 	}
 
-	// Call WSMan Disconnect
-	if err := b.client.Disconnect(ctx, b.epr); err != nil {
+	// Hold pipeline I/O first, so receives cut short by the disconnect are retried
+	b.gate.suspend()
+	opts := wsman.DisconnectOptions{
+		IdleTimeout: b.idleTimeout,
+		BufferMode:  b.bufferMode,
+	}
+	if err := b.client.DisconnectWithOptions(ctx, b.epr, opts); err != nil {
+		b.gate.resume()
 		return err
 	}
 
 	// Mark as closed/disconnected locally (we can't use it anymore until reconnect)
 	b.closed = true
 	b.opened = false
+	b.disconnected = true
 	return nil
 }
 
-// Reconnect reconnects the WSMan shell.
-// This is a stub for the interface, but typically Reconnect creates a new client state.
-// Here we just proxy.
+// Disconnected reports whether the shell was disconnected by this backend
+// and not reconnected yet.
+func (b *WSManBackend) Disconnected() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.disconnected
+}
+
+// Reconnect reconnects a shell disconnected by this backend and resumes its
+// running pipelines. For any other shell it only sends WSMan Reconnect;
+// a new client uses Reattach instead.
 func (b *WSManBackend) Reconnect(ctx context.Context, shellID string) error {
-	return b.client.Reconnect(ctx, shellID)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.client.ReconnectWithResourceURI(ctx, b.resourceURI, shellID); err != nil {
+		return err
+	}
+	if b.disconnected && strings.EqualFold(shellID, b.shellID) {
+		b.disconnected = false
+		b.closed = false
+		b.opened = true
+		b.gate.resume()
+	}
+	return nil
+}
+
+// Abandon gives up the shell locally: pipelines waiting for a reconnect,
+// and any later pipeline I/O, fail with ErrShellAbandoned. A disconnected
+// shell stays on the server.
+func (b *WSManBackend) Abandon() {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	b.gate.abandon()
+}
+
+// ConnectCommand reattaches to a running command of a reattached shell so
+// its buffered output can be received.
+func (b *WSManBackend) ConnectCommand(ctx context.Context, commandID string) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if !b.opened {
+		return ErrPoolNotOpened
+	}
+	return b.client.ConnectCommand(ctx, b.epr, commandID)
+}
+
+// NewCommandTransport returns a transport for receiving the output of an
+// existing command, held like the pipeline transports while disconnected.
+func (b *WSManBackend) NewCommandTransport(commandID string) *WSManTransport {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	t := NewWSManTransport(b.client, b.epr, commandID)
+	t.setGate(b.gate)
+	if b.mux != nil {
+		b.mux.register(commandID)
+		t.setMux(b.mux)
+	}
+	return t
 }
 
 // Reattach connects to an existing disconnected shell using WSManConnectShellEx semantics.
@@ -267,14 +422,14 @@ func (b *WSManBackend) Reattach(ctx context.Context, pool *runspace.Pool, shellI
 	connectXML := base64.StdEncoding.EncodeToString(connectFrags)
 
 	// 2. Send WSMan Connect (NOT Reconnect) with PSRP data piggybacked
-	respData, err := b.client.Connect(ctx, shellID, connectXML)
+	respData, err := b.client.ConnectWithResourceURI(ctx, b.resourceURI, shellID, connectXML)
 	if err != nil {
 		return fmt.Errorf("wsman connect: %w", err)
 	}
 
 	// 3. Reconstruct EPR for this session
 	b.epr = &wsman.EndpointReference{
-		ResourceURI: wsman.ResourceURIPowerShell,
+		ResourceURI: b.resourceURI,
 		Selectors: []wsman.Selector{
 			{Name: "ShellId", Value: shellID},
 		},
@@ -285,8 +440,17 @@ func (b *WSManBackend) Reattach(ctx context.Context, pool *runspace.Pool, shellI
 	b.shellID = shellID
 	b.opened = true
 	b.closed = false
+	if b.disconnected {
+		// Pipelines of the old pool cannot be resumed by the new one
+		b.disconnected = false
+		b.gate.abandon()
+		b.gate = newConnGate()
+		b.transport.setGate(b.gate)
+	}
 
 	// 4. Process the PSRP response data (contains CONNECT_RUNSPACEPOOL response + state)
+	b.capability.start()
+	b.capability.observe(respData)
 	if len(respData) > 0 {
 		if err := pool.ProcessConnectResponse(respData); err != nil {
 			return fmt.Errorf("process connect response: %w", err)
@@ -316,9 +480,16 @@ func (b *WSManBackend) PreparePipeline(ctx context.Context, p *pipeline.Pipeline
 	// 1. Create WSMan Command (Pipeline)
 	// We use the ID from the pipeline to ensure proper routing of Receive responses
 	pipelineID := strings.ToUpper(p.ID().String())
+	if b.mux != nil {
+		// Keep output a shell-level poll gets before the command returns
+		b.mux.register(pipelineID)
+	}
 
 	returnedID, err := b.client.Command(ctx, b.epr, pipelineID, payload)
 	if err != nil {
+		if b.mux != nil {
+			b.mux.release(pipelineID)
+		}
 		return nil, nil, fmt.Errorf("create wsman command: %w", err)
 	}
 
@@ -326,7 +497,16 @@ func (b *WSManBackend) PreparePipeline(ctx context.Context, p *pipeline.Pipeline
 	// Each pipeline gets its own transport with its specific commandID
 	// This allows concurrent pipelines to receive independently
 	pipelineTransport := NewWSManTransport(b.client, b.epr, returnedID)
+	pipelineTransport.setGate(b.gate)
 	pipelineTransport.SetContext(ctx)
+	mux := b.mux
+	if mux != nil {
+		mux.register(returnedID)
+		if !strings.EqualFold(returnedID, pipelineID) {
+			mux.release(pipelineID)
+		}
+		pipelineTransport.setMux(mux)
+	}
 
 	// 3. Setup cleanup function
 	// 3. Setup cleanup function
@@ -341,6 +521,9 @@ func (b *WSManBackend) PreparePipeline(ctx context.Context, p *pipeline.Pipeline
 		cleanCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
 		_ = b.client.Signal(cleanCtx, b.epr, returnedID, wsman.SignalTerminate)
+		if mux != nil {
+			mux.release(returnedID)
+		}
 	}
 
 	// 4. Skip PSRP Invoke Send
@@ -350,3 +533,17 @@ func (b *WSManBackend) PreparePipeline(ctx context.Context, p *pipeline.Pipeline
 
 	return pipelineTransport, cleanup, nil
 }
+
+// StopPipeline sends the PowerShell Ctrl+C signal for the pipeline's command.
+func (b *WSManBackend) StopPipeline(ctx context.Context, p *pipeline.Pipeline) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if !b.opened {
+		return ErrPoolNotOpened
+	}
+	if b.closed {
+		return ErrPoolClosed
+	}
+	return b.client.Signal(ctx, b.epr, strings.ToUpper(p.ID().String()), wsman.SignalPSCtrlC)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/smnsjas/go-psrp/wsman"
@@ -14,11 +15,14 @@ import (
 // mockWSManClientForPool implements PoolClient for tests.
 // mockWSManClientForPool implements PoolClient for tests.
 type mockWSManClientForPool struct {
-	createEPR    *wsman.EndpointReference
-	createErr    error
-	deleteErr    error
-	deleteCalled bool
-	deletedEPR   *wsman.EndpointReference
+	createEPR      *wsman.EndpointReference
+	createErr      error
+	deleteErr      error
+	deleteCalled   bool
+	deletedEPR     *wsman.EndpointReference
+	disconnectOpts wsman.DisconnectOptions
+	resourceURI    string
+	receiveFunc    func() (*wsman.ReceiveResult, error)
 }
 
 func (m *mockWSManClientForPool) Create(_ context.Context, _ map[string]string, _ string) (*wsman.EndpointReference, error) {
@@ -42,6 +46,9 @@ func (m *mockWSManClientForPool) Send(_ context.Context, _ *wsman.EndpointRefere
 }
 
 func (m *mockWSManClientForPool) Receive(_ context.Context, _ *wsman.EndpointReference, _ string) (*wsman.ReceiveResult, error) {
+	if m.receiveFunc != nil {
+		return m.receiveFunc()
+	}
 	return &wsman.ReceiveResult{}, nil
 }
 
@@ -53,11 +60,22 @@ func (m *mockWSManClientForPool) Disconnect(_ context.Context, _ *wsman.Endpoint
 	return nil
 }
 
-func (m *mockWSManClientForPool) Reconnect(_ context.Context, _ string) error {
+func (m *mockWSManClientForPool) DisconnectWithOptions(_ context.Context, _ *wsman.EndpointReference, opts wsman.DisconnectOptions) error {
+	m.disconnectOpts = opts
+	return nil
+}
+
+func (m *mockWSManClientForPool) ReconnectWithResourceURI(_ context.Context, resourceURI, _ string) error {
+	m.resourceURI = resourceURI
+	return nil
+}
+
+func (m *mockWSManClientForPool) ConnectCommand(_ context.Context, _ *wsman.EndpointReference, _ string) error {
 	return nil
 }
 
-func (m *mockWSManClientForPool) Connect(_ context.Context, _ string, _ string) ([]byte, error) {
+func (m *mockWSManClientForPool) ConnectWithResourceURI(_ context.Context, resourceURI, _ string, _ string) ([]byte, error) {
+	m.resourceURI = resourceURI
 	return nil, nil
 }
 
@@ -219,6 +237,126 @@ func TestWSManBackend_Reconnect(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Reconnect failed: %v", err)
 	}
+	if mock.resourceURI != wsman.ResourceURIPowerShell {
+		t.Errorf("Reconnect used %q, want the default resource URI", mock.resourceURI)
+	}
+}
+
+func TestWSManBackend_ReconnectConfiguration(t *testing.T) {
+	const jea = "http://schemas.microsoft.com/powershell/JEAMaintenance"
+	mock := &mockWSManClientForPool{}
+	transport := NewWSManTransport(mock, nil, "")
+	backend := NewWSManBackend(mock, transport)
+	backend.SetResourceURI(jea)
+
+	if err := backend.Reconnect(context.Background(), "shell-id"); err != nil {
+		t.Fatalf("Reconnect failed: %v", err)
+	}
+	if mock.resourceURI != jea {
+		t.Errorf("Reconnect used %q, want %q", mock.resourceURI, jea)
+	}
+}
+
+func TestWSManBackend_DisconnectResume(t *testing.T) {
+	polling := make(chan struct{})
+	disconnected := make(chan struct{})
+	var calls int
+	mock := &mockWSManClientForPool{}
+	mock.receiveFunc = func() (*wsman.ReceiveResult, error) {
+		calls++
+		switch calls {
+		case 1:
+			// The poll in flight is cut short by the disconnect
+			close(polling)
+			<-disconnected
+			return nil, errors.New("shell disconnected")
+		default:
+			return &wsman.ReceiveResult{Stdout: []byte("buffered"), Done: true}, nil
+		}
+	}
+	backend := NewWSManBackend(mock, NewWSManTransport(mock, nil, ""))
+	backend.opened = true
+	backend.epr = dummyPoolEPR()
+	backend.shellID = "test-shell-id"
+	backend.SetIdleTimeout("PT2H")
+
+	pipelineTransport := backend.NewCommandTransport("cmd-id")
+	type readResult struct {
+		data string
+		err  error
+	}
+	read := make(chan readResult, 1)
+	go func() {
+		buf := make([]byte, 64)
+		n, err := pipelineTransport.Read(buf)
+		read <- readResult{string(buf[:n]), err}
+	}()
+
+	<-polling
+	ctx := context.Background()
+	if err := backend.Disconnect(ctx); err != nil {
+		t.Fatalf("Disconnect failed: %v", err)
+	}
+	close(disconnected)
+	if !backend.Disconnected() {
+		t.Error("Disconnected() = false after Disconnect")
+	}
+	want := wsman.DisconnectOptions{IdleTimeout: "PT2H", BufferMode: wsman.BufferModeBlock}
+	if mock.disconnectOpts != want {
+		t.Errorf("disconnect options = %+v, want %+v", mock.disconnectOpts, want)
+	}
+
+	select {
+	case r := <-read:
+		t.Fatalf("Read returned while disconnected: %q, %v", r.data, r.err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := backend.Reconnect(ctx, "TEST-SHELL-ID"); err != nil {
+		t.Fatalf("Reconnect failed: %v", err)
+	}
+	if backend.Disconnected() || !backend.opened || backend.closed {
+		t.Error("backend should be open after Reconnect")
+	}
+
+	select {
+	case r := <-read:
+		if r.err != nil || r.data != "buffered" {
+			t.Errorf("Read after reconnect = %q, %v", r.data, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read did not resume after Reconnect")
+	}
+}
+
+func TestWSManBackend_Abandon(t *testing.T) {
+	mock := &mockWSManClientForPool{}
+	backend := NewWSManBackend(mock, NewWSManTransport(mock, nil, ""))
+	backend.opened = true
+	backend.epr = dummyPoolEPR()
+	pipelineTransport := backend.NewCommandTransport("cmd-id")
+
+	if err := backend.Disconnect(context.Background()); err != nil {
+		t.Fatalf("Disconnect failed: %v", err)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := pipelineTransport.Write([]byte("input"))
+		errCh <- err
+	}()
+
+	backend.Abandon()
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, ErrShellAbandoned) {
+			t.Errorf("Write error = %v, want ErrShellAbandoned", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write did not return after Abandon")
+	}
+	if _, err := pipelineTransport.Write([]byte("more")); !errors.Is(err, ErrShellAbandoned) {
+		t.Errorf("Write after Abandon error = %v, want ErrShellAbandoned", err)
+	}
 }
 
 func TestWSManBackend_Reattach(t *testing.T) {
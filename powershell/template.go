@@ -0,0 +1,156 @@
+package powershell
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/smnsjas/go-psrp/psquote"
+)
+
+// placeholderPattern matches a {{name}} template placeholder.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// reservedNames are automatic variables a placeholder cannot shadow.
+var reservedNames = map[string]bool{
+	"_": true, "args": true, "input": true, "this": true, "psitem": true,
+	"null": true, "true": true, "false": true, "error": true, "host": true,
+	"home": true, "pid": true, "pwd": true, "matches": true, "ofs": true,
+	"lastexitcode": true, "myinvocation": true, "psboundparameters": true,
+	"pscmdlet": true, "executioncontext": true, "stacktrace": true,
+	"psscriptroot": true, "pscommandpath": true,
+}
+
+// Template is a script with {{name}} placeholders for values:
+//
+//	tmpl, err := powershell.ParseTemplate(`
+//		Get-ChildItem -Path {{dir}} -Filter {{pattern}} |
+//			Where-Object Length -gt {{minSize}}`)
+//	script, err := tmpl.Render(map[string]interface{}{
+//		"dir": userDir, "pattern": "*.log", "minSize": 1 << 20,
+//	})
+//
+// Values are never spliced into the script text. The template runs as a
+// script block whose parameters are the placeholders, and each placeholder
+// becomes a reference to its parameter (${name}). Strings are passed as
+// base64 blocks, other values as typed literals or CLIXML like
+// Command.AddParameter, so they keep their types.
+//
+// Because placeholders are variables, they expand in code and in
+// double-quoted strings ("{{name}}.txt"), but not in single-quoted strings
+// or comments. The template must not have a param() block of its own.
+type Template struct {
+	body  string
+	names []string
+}
+
+// ParseTemplate parses a template. Placeholder names are PowerShell
+// identifiers; automatic variables such as input or args are rejected.
+func ParseTemplate(text string) (*Template, error) {
+	seen := make(map[string]string)
+	var names []string
+	for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		name := m[1]
+		lower := strings.ToLower(name)
+		if reservedNames[lower] {
+			return nil, fmt.Errorf("powershell: template placeholder %q is a reserved variable name", name)
+		}
+		// PowerShell variables are case-insensitive
+		if prev, ok := seen[lower]; ok {
+			if prev != name {
+				return nil, fmt.Errorf("powershell: template placeholders %q and %q differ only in case", prev, name)
+			}
+			continue
+		}
+		seen[lower] = name
+		names = append(names, name)
+	}
+	body := placeholderPattern.ReplaceAllString(text, "$${$1}")
+	return &Template{body: body, names: names}, nil
+}
+
+// MustParseTemplate is like ParseTemplate but panics on error. It is meant
+// for templates that are constants in the program.
+func MustParseTemplate(text string) *Template {
+	t, err := ParseTemplate(text)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Names returns the placeholder names in order of first use.
+func (t *Template) Names() []string {
+	return append([]string(nil), t.names...)
+}
+
+// Render returns the script with params bound to the placeholders. Every
+// placeholder needs a value (nil is $null), and every value a placeholder.
+func (t *Template) Render(params map[string]interface{}) (string, error) {
+	return t.RenderWithConverter(params, nil)
+}
+
+// RenderWithConverter renders the template, passing every value through
+// convert first (if not nil).
+func (t *Template) RenderWithConverter(params map[string]interface{}, convert func(interface{}) interface{}) (string, error) {
+	var unknown []string
+	for name := range params {
+		if !t.has(name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("powershell: template has no placeholder %s", strings.Join(unknown, ", "))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("& {\nparam(")
+	for i, name := range t.names {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("$" + name)
+	}
+	sb.WriteString(")\n")
+	sb.WriteString(t.body)
+	sb.WriteString("\n}")
+
+	for _, name := range t.names {
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("powershell: template placeholder %s has no value", name)
+		}
+		if convert != nil {
+			value = convert(value)
+		}
+		literal, err := bindLiteral(value)
+		if err != nil {
+			return "", fmt.Errorf("powershell: template placeholder %s: %w", name, err)
+		}
+		sb.WriteString(" -" + name + ":" + literal)
+	}
+	return sb.String(), nil
+}
+
+func (t *Template) has(name string) bool {
+	for _, n := range t.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// bindLiteral renders a placeholder value. Strings are carried as base64 so
+// their text never appears in the script (or in logs and transcripts of it).
+func bindLiteral(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return psquote.Base64Expr(s), nil
+	}
+	if v == nil {
+		return "$null", nil
+	}
+	return valueLiteral(v)
+}
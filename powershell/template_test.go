@@ -0,0 +1,97 @@
+package powershell
+
+import (
+	"encoding/base64"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate(`Get-ChildItem -Path {{dir}} -Filter {{ pattern }}; "{{dir}}.bak"; @{a=1}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate() error = %v", err)
+	}
+	if got, want := tmpl.Names(), []string{"dir", "pattern"}; !slices.Equal(got, want) {
+		t.Errorf("Names() = %q, want %q", got, want)
+	}
+	if want := `Get-ChildItem -Path ${dir} -Filter ${pattern}; "${dir}.bak"; @{a=1}`; tmpl.body != want {
+		t.Errorf("body = %s, want %s", tmpl.body, want)
+	}
+
+	for _, bad := range []string{"{{input}}", "{{ARGS}}", "{{_}}", "{{x}} {{X}}"} {
+		if _, err := ParseTemplate(bad); err == nil {
+			t.Errorf("ParseTemplate(%q) error = nil", bad)
+		}
+	}
+}
+
+func TestTemplate_Render(t *testing.T) {
+	tmpl := MustParseTemplate(`Get-Item {{path}} | Where-Object Length -gt {{min}}; {{opt}}; {{tags}}`)
+	script, err := tmpl.Render(map[string]interface{}{
+		"path": `C:\O'Brien`,
+		"min":  int32(10),
+		"opt":  nil,
+		"tags": []string{"a", "b"},
+	})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := "& {\nparam($path, $min, $opt, $tags)\n" +
+		"Get-Item ${path} | Where-Object Length -gt ${min}; ${opt}; ${tags}\n}" +
+		" -path:([System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String('" +
+		base64.StdEncoding.EncodeToString([]byte(`C:\O'Brien`)) + "')))" +
+		" -min:([int]'10') -opt:$null -tags:@('a', 'b')"
+	if script != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", script, want)
+	}
+}
+
+func TestTemplate_RenderInjection(t *testing.T) {
+	tmpl := MustParseTemplate(`Get-Item -Path {{path}}`)
+	for _, value := range []string{
+		`x'; Remove-Item C:\ -Recurse; '`,
+		"x’; Stop-Computer; ‘",
+		"$(Stop-Computer)",
+		"}; Stop-Computer; & {",
+		"{{path}}",
+	} {
+		script, err := tmpl.Render(map[string]interface{}{"path": value})
+		if err != nil {
+			t.Fatalf("Render(%q) error = %v", value, err)
+		}
+		if strings.Contains(script, "Stop-Computer") || strings.Contains(script, "Remove-Item") {
+			t.Errorf("Render(%q) contains the value as text: %s", value, script)
+		}
+		// The only quoted text is base64
+		for _, m := range regexp.MustCompile(`'([^']*)'`).FindAllStringSubmatch(script, -1) {
+			if _, err := base64.StdEncoding.DecodeString(m[1]); err != nil {
+				t.Errorf("Render(%q) has non-base64 literal %q", value, m[1])
+			}
+		}
+	}
+}
+
+func TestTemplate_RenderErrors(t *testing.T) {
+	tmpl := MustParseTemplate(`Write-Output {{a}} {{b}}`)
+	if _, err := tmpl.Render(map[string]interface{}{"a": 1}); err == nil || !strings.Contains(err.Error(), "b has no value") {
+		t.Errorf("Render() missing value error = %v", err)
+	}
+	if _, err := tmpl.Render(map[string]interface{}{"a": 1, "b": 2, "c": 3}); err == nil || !strings.Contains(err.Error(), "no placeholder c") {
+		t.Errorf("Render() extra value error = %v", err)
+	}
+	if _, err := MustParseTemplate("Get-Date").Render(nil); err != nil {
+		t.Errorf("Render() without placeholders error = %v", err)
+	}
+}
+
+func TestTemplate_RenderWithConverter(t *testing.T) {
+	tmpl := MustParseTemplate(`Write-Output {{n}}`)
+	script, err := tmpl.RenderWithConverter(map[string]interface{}{"n": "x"}, func(v interface{}) interface{} {
+		return int32(len(v.(string)))
+	})
+	if err != nil || !strings.HasSuffix(script, " -n:([int]'1')") {
+		t.Errorf("RenderWithConverter() = %s, %v", script, err)
+	}
+}
@@ -1,7 +1,6 @@
 package powershell
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -17,19 +16,67 @@ import (
 // This is the bridge between go-psrpcore (which expects io.ReadWriter) and
 // our WSMan client (which provides HTTP-based Send/Receive).
 type WSManTransport struct {
-	mu      sync.Mutex
+	mu      sync.Mutex // Guards the configuration below, never held across requests
 	writeMu sync.Mutex // Serializes writes to ensure fragment order
+	prMu    sync.Mutex // Serializes priority writes, independently of stdin
+	readMu  sync.Mutex // Serializes reads and guards the read state
 
 	client    PoolClient
 	epr       *wsman.EndpointReference
 	commandID string
 	ctx       context.Context
-
-	// Buffered data from Receive
-	readBuf bytes.Buffer
+	// gate holds I/O while the shell is disconnected (nil: never held)
+	gate *connGate
+	// mux shares the receives of the shell's transports (nil: not shared)
+	mux *receiveMux
+
+	// Output of the last Receive not yet read. It is the decoded result
+	// itself, handed out without copying it into a buffer first.
+	pending []byte
 	done    bool
 }
 
+// transportState is a snapshot of the configuration of a WSManTransport,
+// taken so requests run without holding its lock.
+type transportState struct {
+	client    PoolClient
+	epr       *wsman.EndpointReference
+	commandID string
+	ctx       context.Context
+	gate      *connGate
+	mux       *receiveMux
+}
+
+func (t *WSManTransport) state() transportState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return transportState{client: t.client, epr: t.epr, commandID: t.commandID, ctx: t.ctx, gate: t.gate, mux: t.mux}
+}
+
+// receive polls for the output of the command, through the mux if the
+// shell's receives are coalesced.
+func (s transportState) receive() (*wsman.ReceiveResult, error) {
+	if s.mux != nil {
+		return s.mux.receive(s.ctx, s.client, s.epr, s.commandID)
+	}
+	return s.client.Receive(s.ctx, s.epr, s.commandID)
+}
+
+// send sends p on the given input stream of the command.
+func (t *WSManTransport) send(stream string, p []byte) (int, error) {
+	st := t.state()
+	if st.client == nil {
+		return 0, fmt.Errorf("transport not configured")
+	}
+	if err := st.gate.wait(st.ctx); err != nil {
+		return 0, err
+	}
+	if err := st.client.Send(st.ctx, st.epr, st.commandID, stream, p); err != nil {
+		return 0, fmt.Errorf("wsman send: %w", err)
+	}
+	return len(p), nil
+}
+
 // NewWSManTransport creates a transport that bridges WSMan to io.ReadWriter.
 // The client, epr, and commandID can be set later via Configure if needed.
 func NewWSManTransport(client PoolClient, epr *wsman.EndpointReference, commandID string) *WSManTransport {
@@ -57,41 +104,41 @@ func (t *WSManTransport) Write(p []byte) (int, error) {
 	t.writeMu.Lock()
 	defer t.writeMu.Unlock()
 
-	t.mu.Lock()
-	ctx := t.ctx
-	t.mu.Unlock()
+	// Send PSRP data to stdin stream
+	return t.send(wsman.StreamStdin, p)
+}
 
-	if t.client == nil {
-		return 0, fmt.Errorf("transport not configured")
-	}
+// WritePriority sends data to the command's "pr" (priority) stream, which
+// carries host responses while stdin may be busy with pipeline input. It
+// does not wait for a Write or Read in progress: the server may hold a
+// stdin Send or a Receive until it gets the host response.
+func (t *WSManTransport) WritePriority(p []byte) (int, error) {
+	t.prMu.Lock()
+	defer t.prMu.Unlock()
 
-	// Send PSRP data to stdin stream
-	err := t.client.Send(ctx, t.epr, t.commandID, "stdin", p)
-	if err != nil {
-		return 0, fmt.Errorf("wsman send: %w", err)
-	}
-	return len(p), nil
+	return t.send(wsman.StreamPR, p)
 }
 
 // Read receives data from the command's stdout via WSMan Receive.
 // Returns io.EOF when the command completes.
 // This method blocks until data is available or the context is cancelled.
 func (t *WSManTransport) Read(p []byte) (int, error) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
+	t.readMu.Lock()
+	defer t.readMu.Unlock()
 
-	if t.client == nil {
+	st := t.state()
+	if st.client == nil {
 		return 0, fmt.Errorf("transport not configured")
 	}
 
 	// Check context first
-	if err := t.ctx.Err(); err != nil {
+	if err := st.ctx.Err(); err != nil {
 		return 0, err
 	}
 
 	// Return buffered data if available
-	if t.readBuf.Len() > 0 {
-		return t.readBuf.Read(p)
+	if len(t.pending) > 0 {
+		return t.readPending(p), nil
 	}
 
 	// Already done
@@ -103,21 +150,30 @@ func (t *WSManTransport) Read(p []byte) (int, error) {
 	// WSMan Receive is a long-poll but may return empty on timeout
 	for {
 		// Check context before each poll
-		if err := t.ctx.Err(); err != nil {
+		if err := st.ctx.Err(); err != nil {
+			return 0, err
+		}
+		// While the shell is disconnected the server buffers our output;
+		// wait for the reconnect instead of polling
+		if err := st.gate.wait(st.ctx); err != nil {
 			return 0, err
 		}
+		// A reconnect or reattach may have reconfigured the transport
+		st = t.state()
 
 		// Receive output for this command.
 		// Note: For concurrent pipelines, the transport must be configured per-pipeline.
-		result, err := t.client.Receive(t.ctx, t.epr, t.commandID)
+		result, err := st.receive()
 		if err != nil {
+			// A poll cut short by our own Disconnect is resumed after reconnect
+			if st.gate.suspended() {
+				continue
+			}
 			return 0, fmt.Errorf("wsman receive: %w", err)
 		}
 
-		// Buffer the stdout (already decoded from base64 by wsman.Client)
-		if len(result.Stdout) > 0 {
-			t.readBuf.Write(result.Stdout)
-		}
+		// Keep the stdout (already decoded from base64 by wsman.Client)
+		t.pending = result.Stdout
 
 		// Mark done if command completed
 		if result.Done {
@@ -125,8 +181,8 @@ func (t *WSManTransport) Read(p []byte) (int, error) {
 		}
 
 		// Return buffered data if we got any
-		if t.readBuf.Len() > 0 {
-			return t.readBuf.Read(p)
+		if len(t.pending) > 0 {
+			return t.readPending(p), nil
 		}
 
 		// If done with no more data, return EOF
@@ -140,6 +196,16 @@ func (t *WSManTransport) Read(p []byte) (int, error) {
 	}
 }
 
+// readPending copies pending output into p. t.readMu is held.
+func (t *WSManTransport) readPending(p []byte) int {
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	if len(t.pending) == 0 {
+		t.pending = nil
+	}
+	return n
+}
+
 // Close signals the command to terminate.
 func (t *WSManTransport) Close() error {
 	t.mu.Lock()
@@ -159,6 +225,20 @@ func (t *WSManTransport) Configure(client PoolClient, epr *wsman.EndpointReferen
 	t.commandID = commandID
 }
 
+// setGate ties the transport to its backend's disconnect state.
+func (t *WSManTransport) setGate(g *connGate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.gate = g
+}
+
+// setMux makes the transport receive through the shell's receiveMux.
+func (t *WSManTransport) setMux(m *receiveMux) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mux = m
+}
+
 // CloseIdleConnections closes any idle connections in the underlying WSMan client.
 // This forces a fresh NTLM handshake for subsequent requests.
 func (t *WSManTransport) CloseIdleConnections() {
@@ -2,7 +2,9 @@ package powershell
 
 import (
 	"context"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/smnsjas/go-psrp/wsman"
 )
@@ -113,6 +115,35 @@ func TestWSManTransport_Read_Success(t *testing.T) {
 	}
 }
 
+// TestWSManTransport_Read_Partial verifies that output larger than the read
+// buffer is handed out over several reads before polling again.
+func TestWSManTransport_Read_Partial(t *testing.T) {
+	polls := 0
+	mock := &mockTransportClient{
+		receiveFunc: func(ctx context.Context, epr *wsman.EndpointReference, commandID string) (*wsman.ReceiveResult, error) {
+			polls++
+			return &wsman.ReceiveResult{Stdout: []byte("response"), Done: true}, nil
+		},
+	}
+
+	transport := NewWSManTransport(mock, dummyPoolEPR(), "cmd-1")
+	var got []byte
+	buf := make([]byte, 3)
+	for {
+		n, err := transport.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+	}
+	if string(got) != "response" || polls != 1 {
+		t.Errorf("read %q in %d polls, want response in 1", got, polls)
+	}
+}
+
 func TestWSManTransport_Close(t *testing.T) {
 	mock := &mockTransportClient{
 		// Default mock returns nil for everything relevant (Disconnect, Delete, Signal)
@@ -155,3 +186,62 @@ func TestPipeline_Methods(t *testing.T) {
 		t.Errorf("Close error = %v", err)
 	}
 }
+
+// TestWSManTransport_WritePriority_NotBlocked verifies that a host response
+// is sent while a Receive long-poll and a stdin Send are in progress: the
+// server may hold both until it gets the response.
+func TestWSManTransport_WritePriority_NotBlocked(t *testing.T) {
+	release := make(chan struct{})
+	blocked := make(chan struct{}, 2)
+	prSent := make(chan []byte, 1)
+	mock := &mockTransportClient{
+		sendFunc: func(ctx context.Context, epr *wsman.EndpointReference, commandID, stream string, data []byte) error {
+			if stream == wsman.StreamPR {
+				prSent <- data
+				return nil
+			}
+			blocked <- struct{}{}
+			<-release
+			return nil
+		},
+		receiveFunc: func(ctx context.Context, epr *wsman.EndpointReference, commandID string) (*wsman.ReceiveResult, error) {
+			blocked <- struct{}{}
+			<-release
+			return &wsman.ReceiveResult{Stdout: []byte("out")}, nil
+		},
+	}
+	transport := NewWSManTransport(mock, dummyPoolEPR(), "cmd-1")
+
+	readDone := make(chan error, 1)
+	go func() {
+		_, err := transport.Read(make([]byte, 10))
+		readDone <- err
+	}()
+	writeDone := make(chan error, 1)
+	go func() {
+		_, err := transport.Write([]byte("input"))
+		writeDone <- err
+	}()
+	<-blocked
+	<-blocked
+
+	go func() {
+		_, _ = transport.WritePriority([]byte("host-response"))
+	}()
+	select {
+	case data := <-prSent:
+		if string(data) != "host-response" {
+			t.Errorf("pr data = %q, want host-response", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("WritePriority blocked behind Read or Write")
+	}
+
+	close(release)
+	if err := <-readDone; err != nil {
+		t.Errorf("Read error = %v", err)
+	}
+	if err := <-writeDone; err != nil {
+		t.Errorf("Write error = %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+// Package psquote builds PowerShell script text from untrusted values.
+//
+// Scripts sent with Execute are parsed by PowerShell, so any value spliced
+// into them must be a literal that cannot end early or run code:
+//
+//	script := psquote.Sprintf("Get-ChildItem -Path %s -Filter %s", dir, pattern)
+//
+// The package provides:
+//   - Quote and Escape for single-quoted string literals
+//   - Literal and Sprintf for strings, numbers, booleans, arrays and hashtables
+//   - Base64Expr for values that should not appear in the script at all
+//     (e.g., paths that end up in logs or transcripts)
+//   - EncodeCommand for powershell.exe -EncodedCommand
+//
+// For commands with parameters, powershell.Command is usually simpler; it
+// also passes arbitrary objects as CLIXML.
+package psquote
@@ -0,0 +1,167 @@
+package psquote
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// isSingleQuote reports whether PowerShell treats r as a single quote.
+// Besides ' it accepts the typographic quotes ‘ ’ ‚ ‛.
+func isSingleQuote(r rune) bool {
+	switch r {
+	case '\'', '‘', '’', '‚', '‛':
+		return true
+	}
+	return false
+}
+
+// Quote returns s as a single-quoted PowerShell string literal. Nothing in a
+// single-quoted string is expanded, and every quote character in s is
+// doubled, so the literal always ends where Quote ends it.
+func Quote(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) + 2)
+	sb.WriteByte('\'')
+	writeEscaped(&sb, s)
+	sb.WriteByte('\'')
+	return sb.String()
+}
+
+// Escape returns s escaped for use between single quotes in a template,
+// e.g. fmt.Sprintf("Get-Item '%s'", psquote.Escape(path)). Prefer Quote,
+// which cannot be used with the wrong quotes.
+func Escape(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	writeEscaped(&sb, s)
+	return sb.String()
+}
+
+func writeEscaped(sb *strings.Builder, s string) {
+	for _, r := range s {
+		if isSingleQuote(r) {
+			sb.WriteRune(r)
+		}
+		sb.WriteRune(r)
+	}
+}
+
+// EncodeCommand returns script encoded for powershell.exe -EncodedCommand
+// (base64 of UTF-16LE).
+func EncodeCommand(script string) string {
+	u16 := utf16.Encode([]rune(script))
+	buf := make([]byte, len(u16)*2)
+	for i, u := range u16 {
+		binary.LittleEndian.PutUint16(buf[i*2:], u)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// Base64Expr returns a PowerShell expression that evaluates to s. The value
+// is carried as base64 of its UTF-8 bytes, so s's characters never appear
+// in the script text.
+func Base64Expr(s string) string {
+	return "([System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String('" +
+		base64.StdEncoding.EncodeToString([]byte(s)) + "')))"
+}
+
+// Literal returns v as a PowerShell literal that is valid both in
+// expressions and as a command argument:
+//
+//   - nil: $null
+//   - strings: single-quoted (see Quote)
+//   - booleans: $true, $false
+//   - integers and floats: decimal numbers
+//   - slices and arrays: @(...) of literals
+//   - maps with string keys: @{...} of literals, sorted by key
+//
+// Any other value, including fmt.Stringer, is formatted with fmt.Sprint and
+// quoted, so it is always passed as a string.
+func Literal(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "$null"
+	case string:
+		return Quote(val)
+	case bool:
+		if val {
+			return "$true"
+		}
+		return "$false"
+	case fmt.Stringer:
+		return Quote(val.String())
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		switch {
+		case math.IsNaN(f):
+			return "([double]::NaN)"
+		case math.IsInf(f, 1):
+			return "([double]::PositiveInfinity)"
+		case math.IsInf(f, -1):
+			return "([double]::NegativeInfinity)"
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case reflect.String:
+		return Quote(rv.String())
+	case reflect.Bool:
+		return Literal(rv.Bool())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return "@()"
+		}
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = Literal(rv.Index(i).Interface())
+		}
+		return "@(" + strings.Join(items, ", ") + ")"
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		keys := make([]reflect.Value, 0, rv.Len())
+		keys = append(keys, rv.MapKeys()...)
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			entries[i] = Quote(k.String()) + " = " + Literal(rv.MapIndex(k).Interface())
+		}
+		return "@{" + strings.Join(entries, "; ") + "}"
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return "$null"
+		}
+		return Literal(rv.Elem().Interface())
+	}
+	return Quote(fmt.Sprint(v))
+}
+
+// Sprintf formats like fmt.Sprintf, but every argument is first replaced
+// by its Literal, so format must use %s (or %v) for all of them:
+//
+//	psquote.Sprintf("Stop-Service -Name %s -Force:%s", name, force)
+//	// Stop-Service -Name 'Spooler' -Force:$true
+//
+// Only the arguments are made safe; format itself is used as is and must
+// not contain untrusted text.
+func Sprintf(format string, args ...any) string {
+	literals := make([]any, len(args))
+	for i, arg := range args {
+		literals[i] = Literal(arg)
+	}
+	return fmt.Sprintf(format, literals...)
+}
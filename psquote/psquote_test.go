@@ -0,0 +1,185 @@
+package psquote
+
+import (
+	"encoding/base64"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseSingleQuoted reads a PowerShell single-quoted string at the start of
+// script and returns its value and the rest of the script, following the
+// PowerShell tokenizer: any single-quote character ends the string unless
+// it is followed by another one.
+func parseSingleQuoted(t *testing.T, script string) (value, rest string) {
+	t.Helper()
+	runes := []rune(script)
+	if len(runes) == 0 || !isSingleQuote(runes[0]) {
+		t.Fatalf("%q does not start with a quote", script)
+	}
+	var sb strings.Builder
+	for i := 1; i < len(runes); i++ {
+		if !isSingleQuote(runes[i]) {
+			sb.WriteRune(runes[i])
+			continue
+		}
+		if i+1 < len(runes) && isSingleQuote(runes[i+1]) {
+			sb.WriteRune(runes[i+1])
+			i++
+			continue
+		}
+		return sb.String(), string(runes[i+1:])
+	}
+	t.Fatalf("%q is not terminated", script)
+	return "", ""
+}
+
+var hostile = []string{
+	"",
+	"plain",
+	"it's",
+	"'",
+	"''",
+	`x'; Remove-Item C:\ -Recurse; '`,
+	"x’; Stop-Computer; ‘",
+	"‚‛‘’'",
+	"$(Stop-Computer)",
+	"`$env:PATH",
+	"x\n; Stop-Computer\r\n",
+	`"; Stop-Computer; "`,
+	"trailing'",
+	"\x00\u2028é€😀",
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", "''"},
+		{"abc", "'abc'"},
+		{"it's", "'it''s'"},
+		{"a‘b’c‚d‛e", "'a‘‘b’’c‚‚d‛‛e'"},
+		{`C:\Users\O'Brien`, `'C:\Users\O''Brien'`},
+		{"$x `n", "'$x `n'"},
+	}
+	for _, tt := range tests {
+		if got := Quote(tt.in); got != tt.want {
+			t.Errorf("Quote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuote_RoundTrip(t *testing.T) {
+	for _, s := range hostile {
+		// Anything after the literal would run as code
+		value, rest := parseSingleQuoted(t, Quote(s)+"; after")
+		if value != s || rest != "; after" {
+			t.Errorf("Quote(%q) parses as %q followed by %q", s, value, rest)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for _, s := range hostile {
+		if got, want := "'"+Escape(s)+"'", Quote(s); got != want {
+			t.Errorf("Escape(%q) in quotes = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestEncodeCommand(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Get-Date", "RwBlAHQALQBEAGEAdABlAA=="},
+		// Non-BMP characters become surrogate pairs
+		{"é€😀", "6QCsID3YAN4="},
+	}
+	for _, tt := range tests {
+		if got := EncodeCommand(tt.in); got != tt.want {
+			t.Errorf("EncodeCommand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBase64Expr(t *testing.T) {
+	for _, s := range hostile {
+		expr := Base64Expr(s)
+		const prefix = "([System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String("
+		if !strings.HasPrefix(expr, prefix) || !strings.HasSuffix(expr, ")))") {
+			t.Fatalf("Base64Expr(%q) = %q", s, expr)
+		}
+		arg, rest := parseSingleQuoted(t, strings.TrimPrefix(expr, prefix))
+		if rest != ")))" {
+			t.Errorf("Base64Expr(%q) has %q after the literal", s, rest)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(arg)
+		if err != nil || string(decoded) != s {
+			t.Errorf("Base64Expr(%q) decodes to %q, %v", s, decoded, err)
+		}
+	}
+}
+
+type level int
+
+type named string
+
+func TestLiteral(t *testing.T) {
+	n := 7
+	var nilPtr *int
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"nil", nil, "$null"},
+		{"string", "it's", "'it''s'"},
+		{"named string", named("a'b"), "'a''b'"},
+		{"true", true, "$true"},
+		{"false", false, "$false"},
+		{"int", -42, "-42"},
+		{"named int", level(3), "3"},
+		{"uint64", uint64(math.MaxUint64), "18446744073709551615"},
+		{"float", 1.5, "1.5"},
+		{"big float", 1e21, "1e+21"},
+		{"NaN", math.NaN(), "([double]::NaN)"},
+		{"+Inf", math.Inf(1), "([double]::PositiveInfinity)"},
+		{"-Inf", math.Inf(-1), "([double]::NegativeInfinity)"},
+		{"stringer", 90 * time.Second, "'1m30s'"},
+		{"pointer", &n, "7"},
+		{"nil pointer", nilPtr, "$null"},
+		{"slice", []string{"a", "b'c"}, "@('a', 'b''c')"},
+		{"nil slice", []string(nil), "@()"},
+		{"mixed slice", []any{1, "x", nil, []int{2}}, "@(1, 'x', $null, @(2))"},
+		{"array", [2]bool{true, false}, "@($true, $false)"},
+		{"map", map[string]any{"b": 2, "a": "it's"}, "@{'a' = 'it''s'; 'b' = 2}"},
+		{"hostile key", map[string]int{"x'; Stop-Computer; '": 1}, "@{'x''; Stop-Computer; ''' = 1}"},
+		{"other", struct{ A int }{1}, "'{1}'"},
+		{"int map", map[int]string{1: "a"}, "'map[1:a]'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Literal(tt.in); got != tt.want {
+				t.Errorf("Literal(%#v) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	got := Sprintf("Stop-Service -Name %s -Force:%s -Tags %v", "Spooler", true, []string{"a", "b"})
+	want := "Stop-Service -Name 'Spooler' -Force:$true -Tags @('a', 'b')"
+	if got != want {
+		t.Errorf("Sprintf() = %q, want %q", got, want)
+	}
+
+	for _, s := range hostile {
+		script := Sprintf("Get-Item -Path %s; after", s)
+		value, rest := parseSingleQuoted(t, strings.TrimPrefix(script, "Get-Item -Path "))
+		if value != s || rest != "; after" {
+			t.Errorf("Sprintf() with %q parses as %q followed by %q", s, value, rest)
+		}
+	}
+}
@@ -15,4 +15,8 @@ var (
 
 	// ErrInvalidExecutable indicates the executable path is invalid.
 	ErrInvalidExecutable = errors.New("winrs: invalid executable")
+
+	// ErrUnexpectedOutput indicates a command succeeded but its output
+	// could not be parsed.
+	ErrUnexpectedOutput = errors.New("winrs: unexpected command output")
 )
@@ -0,0 +1,112 @@
+package winrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smnsjas/go-psrp/psquote"
+)
+
+// ErrInvalidComment indicates a shutdown comment contains characters that
+// cannot be passed safely through cmd.exe.
+var ErrInvalidComment = errors.New("winrs: invalid shutdown comment")
+
+// maxShutdownDelay is the maximum delay accepted by shutdown.exe /t (10 years).
+const maxShutdownDelay = 315360000 * time.Second
+
+// ShutdownOptions configures a remote shutdown or restart via shutdown.exe.
+type ShutdownOptions struct {
+	// Restart restarts the computer instead of shutting it down (/r vs /s).
+	Restart bool
+
+	// Delay is the time before shutdown begins (/t). Zero shuts down immediately.
+	Delay time.Duration
+
+	// Force closes running applications without warning users (/f).
+	Force bool
+
+	// Comment is recorded in the system event log (/c). Max 512 characters.
+	Comment string
+}
+
+// args builds the shutdown.exe argument list.
+func (o ShutdownOptions) args() ([]string, error) {
+	if o.Delay < 0 || o.Delay > maxShutdownDelay {
+		return nil, fmt.Errorf("winrs: shutdown delay out of range: %v", o.Delay)
+	}
+
+	mode := "/s"
+	if o.Restart {
+		mode = "/r"
+	}
+	args := []string{mode, "/t", strconv.Itoa(int(o.Delay / time.Second))}
+	if o.Force {
+		args = append(args, "/f")
+	}
+	if o.Comment != "" {
+		if len(o.Comment) > 512 || strings.ContainsAny(o.Comment, "\"&|<>^%\r\n") {
+			return nil, ErrInvalidComment
+		}
+		args = append(args, "/c", `"`+o.Comment+`"`)
+	}
+	return args, nil
+}
+
+// Shutdown shuts down or restarts the remote computer using shutdown.exe.
+// It only requires a WinRS shell, so it works even when the PowerShell
+// remoting endpoint is broken.
+func (s *Shell) Shutdown(ctx context.Context, opts ShutdownOptions) error {
+	args, err := opts.args()
+	if err != nil {
+		return err
+	}
+	return s.runChecked(ctx, "shutdown.exe", args...)
+}
+
+// AbortShutdown cancels a pending shutdown scheduled with a non-zero delay.
+func (s *Shell) AbortShutdown(ctx context.Context) error {
+	return s.runChecked(ctx, "shutdown.exe", "/a")
+}
+
+// lastBootTimeScript prints the boot time in UTC, in a format that does
+// not depend on the culture of the remote computer.
+const lastBootTimeScript = "(Get-CimInstance -ClassName Win32_OperatingSystem).LastBootUpTime.ToUniversalTime().ToString('o')"
+
+// LastBootTime returns the time the remote computer was last started. It
+// reads Win32_OperatingSystem with powershell.exe, as wmic.exe is
+// deprecated and no longer installed by current Windows versions.
+func (s *Shell) LastBootTime(ctx context.Context) (time.Time, error) {
+	proc, err := s.Run(ctx, "powershell.exe", "-NoProfile", "-NonInteractive",
+		"-EncodedCommand", psquote.EncodeCommand(lastBootTimeScript))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if proc.ExitCode() != 0 {
+		return time.Time{}, fmt.Errorf("%w: powershell.exe exit code %d: %s",
+			ErrCommandFailed, proc.ExitCode(), strings.TrimSpace(string(proc.Stderr())))
+	}
+
+	value := strings.TrimSpace(string(proc.Stdout()))
+	bootTime, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: LastBootUpTime %q", ErrUnexpectedOutput, value)
+	}
+	return bootTime, nil
+}
+
+// runChecked runs a command and returns an error if it exits non-zero.
+func (s *Shell) runChecked(ctx context.Context, executable string, args ...string) error {
+	proc, err := s.Run(ctx, executable, args...)
+	if err != nil {
+		return err
+	}
+	if proc.ExitCode() != 0 {
+		return fmt.Errorf("%w: %s exit code %d: %s", ErrCommandFailed, executable,
+			proc.ExitCode(), strings.TrimSpace(string(proc.Stderr())))
+	}
+	return nil
+}
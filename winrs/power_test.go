@@ -0,0 +1,167 @@
+package winrs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrp/psquote"
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+func TestShutdownOptions_Args(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ShutdownOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "shutdown immediately",
+			opts: ShutdownOptions{},
+			want: "/s /t 0",
+		},
+		{
+			name: "forced restart with comment",
+			opts: ShutdownOptions{Restart: true, Delay: 30 * time.Second, Force: true, Comment: "patching"},
+			want: `/r /t 30 /f /c "patching"`,
+		},
+		{
+			name:    "comment injection",
+			opts:    ShutdownOptions{Comment: `x" & del C:\*`},
+			wantErr: true,
+		},
+		{
+			name:    "negative delay",
+			opts:    ShutdownOptions{Delay: -time.Second},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.opts.args()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("args() = %v, want error", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("args() error = %v", err)
+			}
+			if got := strings.Join(args, " "); got != tt.want {
+				t.Errorf("args() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShell_Shutdown(t *testing.T) {
+	var gotCmd, gotArgs string
+	mock := &mockTransport{
+		commandFn: func(_ context.Context, _ *wsman.EndpointReference, cmdID, args string) (string, error) {
+			gotCmd, gotArgs = cmdID, args
+			return "cmd-id", nil
+		},
+	}
+	shell, err := NewShell(context.Background(), mock)
+	if err != nil {
+		t.Fatalf("NewShell() error = %v", err)
+	}
+
+	if err := shell.Shutdown(context.Background(), ShutdownOptions{Restart: true}); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if gotCmd != "shutdown.exe" || gotArgs != "/r /t 0" {
+		t.Errorf("command = %q %q, want %q %q", gotCmd, gotArgs, "shutdown.exe", "/r /t 0")
+	}
+}
+
+func TestShell_Shutdown_NonZeroExit(t *testing.T) {
+	mock := &mockTransport{
+		receiveFn: func(_ context.Context, _ *wsman.EndpointReference, _ string) (*wsman.ReceiveResult, error) {
+			return &wsman.ReceiveResult{Stderr: []byte("Access is denied.(5)"), ExitCode: 5, Done: true}, nil
+		},
+	}
+	shell, err := NewShell(context.Background(), mock)
+	if err != nil {
+		t.Fatalf("NewShell() error = %v", err)
+	}
+
+	err = shell.Shutdown(context.Background(), ShutdownOptions{})
+	if !errors.Is(err, ErrCommandFailed) {
+		t.Errorf("Shutdown() error = %v, want ErrCommandFailed", err)
+	}
+}
+
+func TestShell_LastBootTime(t *testing.T) {
+	var gotCmd, gotArgs string
+	mock := &mockTransport{
+		commandFn: func(_ context.Context, _ *wsman.EndpointReference, cmdID, args string) (string, error) {
+			gotCmd, gotArgs = cmdID, args
+			return "cmd-id", nil
+		},
+		receiveFn: func(_ context.Context, _ *wsman.EndpointReference, _ string) (*wsman.ReceiveResult, error) {
+			return &wsman.ReceiveResult{Stdout: []byte("2024-01-15T07:30:12.5000000Z\r\n"), Done: true}, nil
+		},
+	}
+	shell, err := NewShell(context.Background(), mock)
+	if err != nil {
+		t.Fatalf("NewShell() error = %v", err)
+	}
+
+	got, err := shell.LastBootTime(context.Background())
+	if err != nil {
+		t.Fatalf("LastBootTime() error = %v", err)
+	}
+	want := time.Date(2024, 1, 15, 7, 30, 12, 500000000, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("LastBootTime() = %v, want %v", got, want)
+	}
+	wantArgs := "-NoProfile -NonInteractive -EncodedCommand " + psquote.EncodeCommand(lastBootTimeScript)
+	if gotCmd != "powershell.exe" || gotArgs != wantArgs {
+		t.Errorf("command = %q %q, want powershell.exe %q", gotCmd, gotArgs, wantArgs)
+	}
+}
+
+func TestShell_LastBootTime_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *wsman.ReceiveResult
+		want   error
+	}{
+		{
+			name: "not found",
+			result: &wsman.ReceiveResult{
+				Stderr:   []byte("'powershell.exe' is not recognized as an internal or external command"),
+				ExitCode: 1, Done: true,
+			},
+			want: ErrCommandFailed,
+		},
+		{
+			name:   "unexpected output",
+			result: &wsman.ReceiveResult{Stdout: []byte("Monday, January 15, 2024 8:30:12 AM\r\n"), Done: true},
+			want:   ErrUnexpectedOutput,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockTransport{
+				receiveFn: func(_ context.Context, _ *wsman.EndpointReference, _ string) (*wsman.ReceiveResult, error) {
+					return tt.result, nil
+				},
+			}
+			shell, err := NewShell(context.Background(), mock)
+			if err != nil {
+				t.Fatalf("NewShell() error = %v", err)
+			}
+			if _, err := shell.LastBootTime(context.Background()); !errors.Is(err, tt.want) {
+				t.Errorf("LastBootTime() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -113,7 +113,7 @@ func (p *Process) Send(ctx context.Context, data []byte) error {
 	}
 	p.mu.Unlock()
 
-	if err := p.shell.transport.Send(ctx, p.shell.epr, p.commandID, "stdin", data); err != nil {
+	if err := p.shell.transport.Send(ctx, p.shell.epr, p.commandID, wsman.StreamStdin, data); err != nil {
 		return fmt.Errorf("winrs: send input: %w", err)
 	}
 	return nil
@@ -0,0 +1,56 @@
+package wsman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ActivityError is an error from a WSMan request, annotated with the
+// activity ID the request carried in its ActivityId header. WinRM records
+// the ID in its ETW traces (Microsoft-Windows-WinRM/Analytic), so a failing
+// request can be found on the server, or by Windows support, by ID.
+type ActivityError struct {
+	ActivityID string
+	Err        error
+}
+
+// Error implements the error interface.
+func (e *ActivityError) Error() string {
+	return fmt.Sprintf("%v (activity ID %s)", e.Err, e.ActivityID)
+}
+
+// Unwrap returns the underlying error.
+func (e *ActivityError) Unwrap() error {
+	return e.Err
+}
+
+// ActivityID returns the activity ID of the WSMan request that failed with
+// err, or "" if err did not come from a WSMan request.
+func ActivityID(err error) string {
+	var ae *ActivityError
+	if errors.As(err, &ae) {
+		return ae.ActivityID
+	}
+	return ""
+}
+
+type activityIDKey struct{}
+
+// ContextWithActivityID makes the requests sent with ctx carry id, instead
+// of a new activity ID per request, so that all requests of one operation
+// can be found in the server traces together. id should be a GUID.
+func ContextWithActivityID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, activityIDKey{}, id)
+}
+
+// activityID returns the activity ID set on ctx, or a new one.
+func activityID(ctx context.Context) string {
+	if id, ok := ctx.Value(activityIDKey{}).(string); ok && id != "" {
+		return id
+	}
+	return strings.ToUpper(uuid.New().String())
+}
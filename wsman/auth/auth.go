@@ -16,6 +16,44 @@ type Authenticator interface {
 	Name() string
 }
 
+// LoggerSetter is implemented by the authenticators and security providers
+// of this package. They log to slog.Default() until SetLogger is called,
+// which must happen before their first request.
+type LoggerSetter interface {
+	SetLogger(logger *slog.Logger)
+}
+
+// SetLogger sets the logger of v if it implements LoggerSetter, and does
+// nothing otherwise.
+func SetLogger(v any, logger *slog.Logger) {
+	if s, ok := v.(LoggerSetter); ok {
+		s.SetLogger(logger)
+	}
+}
+
+// ConnectionBound is implemented by authenticators and security providers
+// whose security context belongs to the connection it was established on.
+// A server keeps NTLM session keys with the connection that authenticated,
+// so messages sealed with them must all travel on that connection.
+type ConnectionBound interface {
+	ConnectionBound() bool
+}
+
+// IsConnectionBound reports whether v implements ConnectionBound and its
+// security context belongs to one connection.
+func IsConnectionBound(v any) bool {
+	b, ok := v.(ConnectionBound)
+	return ok && b.ConnectionBound()
+}
+
+// loggerOrDefault returns logger, or slog.Default() if it is nil.
+func loggerOrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
 // Credentials holds authentication credentials.
 type Credentials struct {
 	// Username is the user name for authentication.
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"encoding/base64"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -94,6 +95,34 @@ func TestBasicAuth_Transport(t *testing.T) {
 	}
 }
 
+// TestBasicAuth_SetLogger verifies that the warning about Basic auth over
+// plain HTTP goes to the authenticator's logger.
+func TestBasicAuth_SetLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var buf strings.Builder
+	auth := NewBasicAuth(Credentials{Username: "testuser", Password: "testpass"})
+	SetLogger(auth, slog.New(slog.NewTextHandler(&buf, nil)).With("component", "auth"))
+
+	client := &http.Client{Transport: auth.Transport(http.DefaultTransport)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	out := buf.String()
+	if !strings.Contains(out, "level=WARN") || !strings.Contains(out, "component=auth") {
+		t.Errorf("log = %q, want a warning from the auth component", out)
+	}
+	if strings.Contains(out, "testpass") {
+		t.Errorf("log = %q, contains the password", out)
+	}
+}
+
 // TestNTLMAuth_Name verifies the auth scheme name.
 func TestNTLMAuth_Name(t *testing.T) {
 	auth := NewNTLMAuth(Credentials{})
@@ -126,6 +155,9 @@ func TestNTLMAuth_Transport(t *testing.T) {
 func TestAuthenticator_Interface(_ *testing.T) {
 	var _ Authenticator = NewBasicAuth(Credentials{})
 	var _ Authenticator = NewNTLMAuth(Credentials{})
+	var _ LoggerSetter = NewBasicAuth(Credentials{})
+	var _ LoggerSetter = NewNTLMAuth(Credentials{})
+	var _ LoggerSetter = NewNegotiateAuth(NewNTLMProvider(Credentials{}))
 }
 
 func TestCredentials_Validate(t *testing.T) {
@@ -2,14 +2,15 @@ package auth
 
 import (
 	"encoding/base64"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
 )
 
 // BasicAuth implements HTTP Basic authentication.
 type BasicAuth struct {
-	creds Credentials
+	creds  Credentials
+	logger *slog.Logger
 }
 
 // NewBasicAuth creates a new Basic authentication handler.
@@ -22,11 +23,17 @@ func (a *BasicAuth) Name() string {
 	return "Basic"
 }
 
+// SetLogger sets the logger of the authenticator.
+func (a *BasicAuth) SetLogger(logger *slog.Logger) {
+	a.logger = logger
+}
+
 // Transport wraps an http.RoundTripper with Basic authentication.
 func (a *BasicAuth) Transport(base http.RoundTripper) http.RoundTripper {
 	return &basicTransport{
-		base:  base,
-		creds: a.creds,
+		base:   base,
+		creds:  a.creds,
+		logger: loggerOrDefault(a.logger),
 	}
 }
 
@@ -34,6 +41,7 @@ func (a *BasicAuth) Transport(base http.RoundTripper) http.RoundTripper {
 type basicTransport struct {
 	base     http.RoundTripper
 	creds    Credentials
+	logger   *slog.Logger
 	warnOnce sync.Once
 }
 
@@ -42,8 +50,7 @@ func (t *basicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	// Warn if using Basic auth over non-HTTPS (credentials are easily readable)
 	if req.URL.Scheme != "https" {
 		t.warnOnce.Do(func() {
-			log.Printf("WARNING: Basic auth over non-HTTPS to %s - credentials not encrypted",
-				req.URL.Host)
+			t.logger.Warn("Basic auth over non-HTTPS, credentials not encrypted", "host", req.URL.Host)
 		})
 	}
 
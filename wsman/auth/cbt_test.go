@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec // MD5 is mandated by MS-NLMP and RFC 4121
+	"encoding/binary"
+	"testing"
+)
+
+func TestGSSChannelBindings(t *testing.T) {
+	certHash := bytes.Repeat([]byte{0x42}, 32)
+	got := gssChannelBindings(certHash)
+
+	appData := append([]byte("tls-server-end-point:"), certHash...)
+	want := make([]byte, 16) // no initiator or acceptor address
+	want = binary.LittleEndian.AppendUint32(want, uint32(len(appData)))
+	want = append(want, appData...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("gssChannelBindings() = %x; want %x", got, want)
+	}
+
+	sum := md5.Sum(want) //nolint:gosec // see import
+	if hash := channelBindingsHash(certHash); !bytes.Equal(hash, sum[:]) {
+		t.Errorf("channelBindingsHash() = %x; want %x", hash, sum)
+	}
+}
+
+func TestAuthenticatorChecksum(t *testing.T) {
+	certHash := bytes.Repeat([]byte{0x42}, 32)
+	got := authenticatorChecksum(certHash, []int{2, 16, 32})
+
+	if len(got) != 24 {
+		t.Fatalf("len = %d; want 24", len(got))
+	}
+	if n := binary.LittleEndian.Uint32(got[0:4]); n != 16 {
+		t.Errorf("Lgth = %d; want 16", n)
+	}
+	if !bytes.Equal(got[4:20], channelBindingsHash(certHash)) {
+		t.Errorf("Bnd = %x; want the channel bindings hash", got[4:20])
+	}
+	if f := binary.LittleEndian.Uint32(got[20:24]); f != 2|16|32 {
+		t.Errorf("Flags = %#x; want %#x", f, 2|16|32)
+	}
+
+	if unbound := authenticatorChecksum(nil, nil); !bytes.Equal(unbound[4:20], make([]byte, 16)) {
+		t.Errorf("Bnd without bindings = %x; want zeros", unbound[4:20])
+	}
+}
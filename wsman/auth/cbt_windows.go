@@ -13,9 +13,6 @@ const (
 	// secChannelBindingsHeaderSize is the size of SEC_CHANNEL_BINDINGS structure.
 	// 8 fields × 4 bytes = 32 bytes
 	secChannelBindingsHeaderSize = 32
-
-	// tlsServerEndPointPrefix is the channel binding type prefix per RFC 5929.
-	tlsServerEndPointPrefix = "tls-server-end-point:"
 )
 
 // makeChannelBindings creates a SEC_CHANNEL_BINDINGS structure with the given certificate hash.
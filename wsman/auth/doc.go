@@ -15,6 +15,16 @@
 // On other platforms (macOS, Linux), explicit credentials are required:
 // password, keytab file, or credential cache (ccache from kinit).
 //
+// # Slim Builds
+//
+// The psrp_nokrb5 build tag leaves out gokrb5. NewKerberosProvider then
+// returns ErrKerberosUnavailable on platforms without SSPI, unless another
+// implementation was registered with RegisterKerberosProvider:
+//
+//	auth.RegisterKerberosProvider(func(cfg auth.KerberosProviderConfig) (auth.SecurityProvider, error) {
+//	    return gssapi.NewProvider(cfg.TargetSPN) // your own SecurityProvider
+//	})
+//
 // # Usage
 //
 // NTLM authentication:
@@ -25,6 +35,11 @@
 //	    Domain:   "DOMAIN",
 //	})
 //
+// NTLM over HTTP with message encryption (required when the server has
+// AllowUnencrypted=false, the WinRM default):
+//
+//	auth := auth.NewNegotiateAuth(auth.NewNTLMProvider(creds))
+//
 // Kerberos authentication:
 //
 //	provider, _ := auth.NewKerberosProvider(auth.KerberosProviderConfig{
@@ -3,6 +3,8 @@
 
 package auth
 
+import "errors"
+
 // KerberosProviderConfig holds unified config for any Kerberos provider.
 // This type is shared across all platforms.
 type KerberosProviderConfig struct {
@@ -28,4 +30,15 @@ type KerberosProviderConfig struct {
 
 	// Credentials are username/password credentials (optional).
 	Credentials *Credentials
+
+	// Delegate requests credential delegation: the server receives a
+	// forwarded TGT and can use it for second-hop access (file shares, SQL
+	// Server) from the remote session. The server's computer account must be
+	// trusted for delegation. Only supported with SSPI (Windows); other
+	// platforms return ErrDelegationNotSupported.
+	Delegate bool
 }
+
+// ErrDelegationNotSupported is returned by NewKerberosProvider when Delegate
+// is set on a platform without delegation support.
+var ErrDelegationNotSupported = errors.New("auth: Kerberos credential delegation requires SSPI (Windows)")
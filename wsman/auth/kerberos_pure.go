@@ -1,3 +1,5 @@
+//go:build !psrp_nokrb5
+
 package auth
 
 import (
@@ -10,29 +12,67 @@ import (
 	"math"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-krb5/krb5/client"
 	"github.com/go-krb5/krb5/config"
 	"github.com/go-krb5/krb5/credentials"
 	"github.com/go-krb5/krb5/gssapi"
+	"github.com/go-krb5/krb5/iana/chksumtype"
 	"github.com/go-krb5/krb5/iana/flags"
 	"github.com/go-krb5/krb5/iana/msgtype"
 	"github.com/go-krb5/krb5/keytab"
 	"github.com/go-krb5/krb5/messages"
 	"github.com/go-krb5/krb5/spnego"
+	"github.com/go-krb5/krb5/types"
+	"github.com/go-krb5/x/encoding/asn1"
 )
 
-// ContextKeyIsHTTPS is the context key for detecting HTTPS transport.
-const ContextKeyIsHTTPS = contextKey("isHTTPS")
+// ticketRenewMargin is how long before the TGT expires a handshake gets a
+// new one, so a long-lived client never presents an expired ticket.
+const ticketRenewMargin = 5 * time.Minute
+
+func init() {
+	builtinKerberos = newPureKerberosFromConfig
+}
+
+// newPureKerberosFromConfig creates a PureKerberosProvider for
+// NewKerberosProvider.
+func newPureKerberosFromConfig(cfg KerberosProviderConfig) (SecurityProvider, error) {
+	// gokrb5 cannot put a forwarded TGT (KRB-CRED) in the authenticator
+	if cfg.Delegate {
+		return nil, ErrDelegationNotSupported
+	}
+	p, err := NewPureKerberosProvider(PureKerberosConfig{
+		Realm:        cfg.Realm,
+		Krb5ConfPath: cfg.Krb5ConfPath,
+		KeytabPath:   cfg.KeytabPath,
+		CCachePath:   cfg.CCachePath,
+		Credentials:  cfg.Credentials,
+	}, cfg.TargetSPN)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
 
 // PureKerberosProvider implements SecurityProvider using the pure Go gokrb5 library.
 type PureKerberosProvider struct {
-	client *client.Client
+	cfg  PureKerberosConfig
+	conf *config.Config
+
+	mu        sync.Mutex // guards client and tgtExpiry, which RefreshCredentials replaces
+	client    *client.Client
+	tgtExpiry time.Time
 
 	clientContext *spnego.ClientContext // For HTTP: strict GSS-API context from fork
 	targetSPN     string
 	isComplete    bool
 	isHTTPS       bool
+	certHash      []byte // For HTTPS: tls-server-end-point hash to bind to
+
+	logger *slog.Logger // set by SetLogger
 }
 
 // PureKerberosConfig holds the configuration for the PureKerberosProvider.
@@ -58,13 +98,31 @@ func NewPureKerberosProvider(cfg PureKerberosConfig, targetSPN string) (*PureKer
 		return nil, fmt.Errorf("load krb5.conf from %s: %w", cfg.Krb5ConfPath, err)
 	}
 
+	cl, tgtExpiry, err := newKerberosClient(cfg, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PureKerberosProvider{
+		cfg:       cfg,
+		conf:      conf,
+		client:    cl,
+		tgtExpiry: tgtExpiry,
+		targetSPN: targetSPN,
+	}, nil
+}
+
+// newKerberosClient creates a gokrb5 client for cfg and logs in. It returns
+// when the TGT expires, or the zero time if that is unknown.
+func newKerberosClient(cfg PureKerberosConfig, conf *config.Config) (*client.Client, time.Time, error) {
 	var cl *client.Client
+	var tgtExpiry time.Time
 
 	// 1. Try Keytab
 	if cfg.KeytabPath != "" {
 		kt, err := keytab.Load(cfg.KeytabPath)
 		if err != nil {
-			return nil, fmt.Errorf("load keytab from %s: %w", cfg.KeytabPath, err)
+			return nil, time.Time{}, fmt.Errorf("load keytab from %s: %w", cfg.KeytabPath, err)
 		}
 		// Need username from somewhere. Credentials?
 		username := ""
@@ -72,16 +130,18 @@ func NewPureKerberosProvider(cfg PureKerberosConfig, targetSPN string) (*PureKer
 			username = cfg.Credentials.Username
 		}
 		cl = client.NewWithKeytab(username, cfg.Realm, kt, conf, client.DisablePAFXFAST(true))
+		tgtExpiry = time.Now().Add(conf.LibDefaults.TicketLifetime)
 	} else if cfg.CCachePath != "" {
 		// 2. Try CCache
 		cc, err := credentials.LoadCCache(cfg.CCachePath)
 		if err != nil {
-			return nil, fmt.Errorf("load ccache from %s: %w", cfg.CCachePath, err)
+			return nil, time.Time{}, fmt.Errorf("load ccache from %s: %w", cfg.CCachePath, err)
 		}
 		cl, err = client.NewFromCCache(cc, conf, client.DisablePAFXFAST(true))
 		if err != nil {
-			return nil, fmt.Errorf("create client from ccache: %w", err)
+			return nil, time.Time{}, fmt.Errorf("create client from ccache: %w", err)
 		}
+		tgtExpiry = ccacheTGTExpiry(cc)
 	} else if cfg.Credentials != nil {
 		// 3. Password
 		cl = client.NewWithPassword(
@@ -91,19 +151,92 @@ func NewPureKerberosProvider(cfg PureKerberosConfig, targetSPN string) (*PureKer
 			conf,
 			client.DisablePAFXFAST(true),
 		)
+		tgtExpiry = time.Now().Add(conf.LibDefaults.TicketLifetime)
 	} else {
-		return nil, fmt.Errorf("no credentials provided (keytab, ccache, or password required)")
+		return nil, time.Time{}, fmt.Errorf("no credentials provided (keytab, ccache, or password required)")
 	}
 
 	// Login to get TGT
 	if err := cl.Login(); err != nil {
-		return nil, fmt.Errorf("kerberos login: %w", err)
+		return nil, time.Time{}, fmt.Errorf("kerberos login: %w", err)
 	}
+	return cl, tgtExpiry, nil
+}
 
-	return &PureKerberosProvider{
-		client:    cl,
-		targetSPN: targetSPN,
-	}, nil
+// ccacheTGTExpiry returns the end time of the TGT in cc, or the zero time
+// if cc holds none.
+func ccacheTGTExpiry(cc *credentials.CCache) time.Time {
+	for _, cred := range cc.GetEntries() {
+		names := cred.Server.PrincipalName.NameString
+		if len(names) > 0 && names[0] == "krbtgt" {
+			return cred.EndTime
+		}
+	}
+	return time.Time{}
+}
+
+// RefreshCredentials replaces the TGT: it logs in again with the password
+// or keytab, or reloads the credential cache, which kinit or a renewal
+// daemon (k5start, sssd) may have updated. Handshakes call it themselves
+// shortly before the TGT expires and when the KDC reports it expired.
+func (p *PureKerberosProvider) RefreshCredentials() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.refreshLocked()
+}
+
+// refreshLocked implements RefreshCredentials. p.mu must be held.
+func (p *PureKerberosProvider) refreshLocked() error {
+	cl, tgtExpiry, err := newKerberosClient(p.cfg, p.conf)
+	if err != nil {
+		return fmt.Errorf("refresh kerberos credentials: %w", err)
+	}
+	p.client.Destroy()
+	p.client = cl
+	p.tgtExpiry = tgtExpiry
+	p.log().Debug("Kerberos: credentials refreshed", "tgtExpiry", tgtExpiry)
+	return nil
+}
+
+// serviceTicket gets a ticket for the target SPN, first refreshing a TGT
+// that expires within ticketRenewMargin, and retrying once with a fresh TGT
+// if the KDC reports the ticket expired. It returns the client it used.
+func (p *PureKerberosProvider) serviceTicket() (*client.Client, messages.Ticket, types.EncryptionKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.tgtExpiry.IsZero() && time.Until(p.tgtExpiry) < ticketRenewMargin {
+		if err := p.refreshLocked(); err != nil {
+			return nil, messages.Ticket{}, types.EncryptionKey{}, err
+		}
+	}
+
+	tkt, sessionKey, err := p.client.GetServiceTicket(p.targetSPN)
+	if err != nil && isTicketExpired(err) {
+		if rerr := p.refreshLocked(); rerr != nil {
+			return nil, messages.Ticket{}, types.EncryptionKey{}, fmt.Errorf("%w (%v)", err, rerr)
+		}
+		tkt, sessionKey, err = p.client.GetServiceTicket(p.targetSPN)
+	}
+	if err != nil {
+		return nil, messages.Ticket{}, types.EncryptionKey{}, err
+	}
+	return p.client, tkt, sessionKey, nil
+}
+
+// isTicketExpired reports whether err is a KRB_AP_ERR_TKT_EXPIRED error.
+func isTicketExpired(err error) bool {
+	return strings.Contains(err.Error(), "KRB_AP_ERR_TKT_EXPIRED")
+}
+
+// SetLogger sets the logger of the provider.
+func (p *PureKerberosProvider) SetLogger(logger *slog.Logger) {
+	p.logger = logger
+}
+
+// log returns the logger of the provider.
+func (p *PureKerberosProvider) log() *slog.Logger {
+	return loggerOrDefault(p.logger)
 }
 
 // Complete returns true if the authentication handshake is complete.
@@ -116,7 +249,8 @@ func (p *PureKerberosProvider) Step(ctx context.Context, inputToken []byte) ([]b
 	// Detect HTTPS vs HTTP on first call
 	if len(inputToken) == 0 && !p.isComplete {
 		isHTTPS, _ := ctx.Value(ContextKeyIsHTTPS).(bool)
-		p.isHTTPS = isHTTPS
+		p.certHash, _ = ctx.Value(ContextKeyChannelBindings).([]byte)
+		p.isHTTPS = isHTTPS || len(p.certHash) > 0
 	}
 
 	// 1. Initial Request (No input token)
@@ -132,12 +266,12 @@ func (p *PureKerberosProvider) Step(ctx context.Context, inputToken []byte) ([]b
 func (p *PureKerberosProvider) generateInitialToken() ([]byte, bool, error) {
 	// HTTPS Logic (TLS handles encryption, so standard SPNEGO header)
 	if p.isHTTPS {
-		return nil, false, fmt.Errorf("HTTPS authentication temporarily disabled due to library mismatch (SetSPNEGOHeader undefined)")
+		return p.generateHTTPSToken()
 	}
 
 	// HTTP Logic (Application Layer Encryption)
 	// 1. Get service ticket
-	tkt, sessionKey, err := p.client.GetServiceTicket(p.targetSPN)
+	cl, tkt, sessionKey, err := p.serviceTicket()
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to get service ticket: %w", err)
 	}
@@ -154,7 +288,7 @@ func (p *PureKerberosProvider) generateInitialToken() ([]byte, bool, error) {
 
 	// 4. Create NegTokenInit with KRB5 AP-REQ
 	negTokenInit, err := spnego.NewNegTokenInitKRB5WithFlags(
-		p.client, tkt, sessionKey, gssFlags, apOptions)
+		cl, tkt, sessionKey, gssFlags, apOptions)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to create NegTokenInit: %w", err)
 	}
@@ -193,6 +327,67 @@ func (p *PureKerberosProvider) generateInitialToken() ([]byte, bool, error) {
 	return tokenBytes, true, nil // continueNeeded=true
 }
 
+// generateHTTPSToken creates the NegTokenInit for HTTPS. TLS protects the
+// messages, so the GSS-API context only verifies the server's AP-REP; the
+// AP-REQ authenticator carries the TLS channel bindings so that servers with
+// Extended Protection accept it.
+func (p *PureKerberosProvider) generateHTTPSToken() ([]byte, bool, error) {
+	cl, tkt, sessionKey, err := p.serviceTicket()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get service ticket: %w", err)
+	}
+
+	gssFlags := []int{gssapi.ContextFlagInteg, gssapi.ContextFlagConf, gssapi.ContextFlagMutual}
+	result, err := spnego.NewKRB5TokenAPREQ(cl, tkt, sessionKey, gssFlags, []int{flags.APOptionMutualRequired})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create KRB5 token: %w", err)
+	}
+	krb5Token := result.Token
+
+	// Replace the AP-REQ with one whose authenticator checksum binds the channel
+	authenticator, err := types.NewAuthenticator(cl.Credentials.Domain(), cl.Credentials.CName())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create authenticator: %w", err)
+	}
+	authenticator.Cksum = types.Checksum{
+		CksumType: chksumtype.GSSAPI,
+		Checksum:  authenticatorChecksum(p.certHash, gssFlags),
+	}
+	apReq, err := messages.NewAPReq(tkt, sessionKey, authenticator)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create AP-REQ: %w", err)
+	}
+	types.SetFlag(&apReq.APOptions, flags.APOptionMutualRequired)
+	krb5Token.APReq = apReq
+
+	mechToken, err := krb5Token.Marshal()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal KRB5 token: %w", err)
+	}
+
+	// The server must still prove itself with an AP-REP, as over HTTP
+	gssFlagBits := uint32(gssapi.ContextFlagInteg | gssapi.ContextFlagConf | gssapi.ContextFlagMutual)
+	clientCtx := spnego.NewClientContext(sessionKey, gssFlagBits, authenticator.SeqNumber)
+	clientCtx.SetMutualAuthRequired(true)
+	if err := clientCtx.SetInProgress(); err != nil {
+		return nil, false, fmt.Errorf("failed to set context in progress: %w", err)
+	}
+	p.clientContext = clientCtx
+
+	spnegoToken := &spnego.SPNEGOToken{
+		Init: true,
+		NegTokenInit: spnego.NegTokenInit{
+			MechTypes:      []asn1.ObjectIdentifier{gssapi.OIDKRB5.OID()},
+			MechTokenBytes: mechToken,
+		},
+	}
+	tokenBytes, err := spnegoToken.Marshal()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal SPNEGO token: %w", err)
+	}
+	return tokenBytes, true, nil
+}
+
 // processServerToken handles the server's NegTokenResp (AP-REP)
 func (p *PureKerberosProvider) processServerToken(input []byte) ([]byte, bool, error) {
 	// Legacy format check: "Negotiate <b64>"?
@@ -209,6 +404,9 @@ func (p *PureKerberosProvider) processServerToken(input []byte) ([]byte, bool, e
 	// Note: negResp.State is a method in standard lib/fork usually, or a field.
 	// Compiler said "mismatched types func() ... and State". So it IT IS A FUNCTION.
 	if negResp.State() == spnego.NegStateAcceptCompleted {
+		if p.clientContext == nil {
+			return nil, false, fmt.Errorf("client context not initialized")
+		}
 		if len(negResp.ResponseToken) > 0 {
 			// Extract AP-REP
 			// We can use ProcessAPRep directly if we had a helper, but `GetKRB5Token` works.
@@ -217,11 +415,17 @@ func (p *PureKerberosProvider) processServerToken(input []byte) ([]byte, bool, e
 			// Actually `processServerToken` snippet from user suggests `GetKRB5Token`.
 			// Since I don't see `GetKRB5Token` in standard, I'll assume it exists in fork or fallback.
 
-			// FALLBACK to simple AP-REP Unmarshal:
-			var apRep messages.APRep
-			if err := apRep.Unmarshal(negResp.ResponseToken); err == nil {
-				if err := p.clientContext.ProcessAPRep(&apRep); err != nil {
-					return nil, false, fmt.Errorf("AP-REP process failed: %w", err)
+			// The AP-REP comes in a GSS-API KRB5 token, or bare
+			if krb5Token, err := negResp.GetKRB5Token(); err == nil && krb5Token.IsAPRep() {
+				if ok, status := krb5Token.VerifyAPRep(p.clientContext); !ok {
+					return nil, false, fmt.Errorf("AP-REP process failed: %s", status.Message)
+				}
+			} else {
+				var apRep messages.APRep
+				if err := apRep.Unmarshal(negResp.ResponseToken); err == nil {
+					if err := p.clientContext.ProcessAPRep(&apRep); err != nil {
+						return nil, false, fmt.Errorf("AP-REP process failed: %w", err)
+					}
 				}
 			}
 		}
@@ -230,7 +434,7 @@ func (p *PureKerberosProvider) processServerToken(input []byte) ([]byte, bool, e
 			return nil, false, err
 		}
 		p.isComplete = true
-		slog.Info("Negotiate: Handshake complete", "authComplete", true)
+		p.log().Info("Negotiate: Handshake complete", "authComplete", true)
 		return nil, true, nil
 	}
 
@@ -239,9 +443,9 @@ func (p *PureKerberosProvider) processServerToken(input []byte) ([]byte, bool, e
 
 // ProcessResponse processes the final mutual authentication token (AP-REP)
 func (p *PureKerberosProvider) ProcessResponse(ctx context.Context, authHeader string) error {
-	slog.Debug("Negotiate: ProcessResponse called", "headerLen", len(authHeader))
+	p.log().Debug("Negotiate: ProcessResponse called", "headerLen", len(authHeader))
 	if p.clientContext == nil {
-		slog.Debug("Negotiate: ProcessResponse skipped - clientContext nil")
+		p.log().Debug("Negotiate: ProcessResponse skipped - clientContext nil")
 		return fmt.Errorf("client context not initialized")
 	}
 
@@ -253,28 +457,28 @@ func (p *PureKerberosProvider) ProcessResponse(ctx context.Context, authHeader s
 	}
 
 	if len(token) == 0 {
-		slog.Debug("Negotiate: ProcessResponse skipped - no token found in header")
+		p.log().Debug("Negotiate: ProcessResponse skipped - no token found in header")
 		return nil // No token to process
 	}
 
-	slog.Debug("Negotiate: Processing authentication token", "tokenLen", len(token))
+	p.log().Debug("Negotiate: Processing authentication token", "tokenLen", len(token))
 
 	// 1. Unmarshal NegTokenResp
 	// 1. Unmarshal NegTokenResp
 	var negTokenResp spnego.NegTokenResp
 	if err := negTokenResp.Unmarshal(token); err != nil {
-		slog.Warn("Negotiate: NegTokenResp unmarshal failed", "error", err)
+		p.log().Warn("Negotiate: NegTokenResp unmarshal failed", "error", err)
 		return nil
 	}
 
 	// Check if there is a MechToken (the inner Kerberos AP-REP)
 	if len(negTokenResp.ResponseToken) == 0 {
-		slog.Debug("Negotiate: NegTokenResp has no ResponseToken (MechToken)")
+		p.log().Debug("Negotiate: NegTokenResp has no ResponseToken (MechToken)")
 		return nil
 	}
 
 	// 2. Unmarshal AP-REP
-	slog.Debug("Negotiate: Found ResponseToken", "hexPrefix", fmt.Sprintf("%x", negTokenResp.ResponseToken[:min(8, len(negTokenResp.ResponseToken))]))
+	p.log().Debug("Negotiate: Found ResponseToken", "hexPrefix", fmt.Sprintf("%x", negTokenResp.ResponseToken[:min(8, len(negTokenResp.ResponseToken))]))
 
 	// Handle GSS-API wrapping if present (Tag 60)
 	payload := negTokenResp.ResponseToken
@@ -289,24 +493,24 @@ func (p *PureKerberosProvider) ProcessResponse(ctx context.Context, authHeader s
 				// Potential start of AP-REP
 				// Verify if it looks valid? Just try unmarshalling
 				payload = payload[i:]
-				slog.Debug("Negotiate: Found AP-REP tag (0x6f)", "offset", i)
+				p.log().Debug("Negotiate: Found AP-REP tag (0x6f)", "offset", i)
 				found = true
 				break
 			}
 		}
 		if !found {
-			slog.Debug("Negotiate: GSS-API tag found but could not locate AP-REP (0x6f) in payload")
+			p.log().Debug("Negotiate: GSS-API tag found but could not locate AP-REP (0x6f) in payload")
 		}
 	}
 
 	var apRep messages.APRep
 	if err := apRep.Unmarshal(payload); err != nil {
-		slog.Debug("Negotiate: AP-REP unmarshal failed, token likely not AP-REP", "error", err)
+		p.log().Debug("Negotiate: AP-REP unmarshal failed, token likely not AP-REP", "error", err)
 		return nil // Not valid AP-REP
 	}
 
 	if apRep.MsgType != msgtype.KRB_AP_REP {
-		slog.Debug("Negotiate: MsgType mismatch", "expected", msgtype.KRB_AP_REP, "got", apRep.MsgType)
+		p.log().Debug("Negotiate: MsgType mismatch", "expected", msgtype.KRB_AP_REP, "got", apRep.MsgType)
 		return nil // Not AP-REP
 	}
 
@@ -325,9 +529,9 @@ func (p *PureKerberosProvider) ProcessResponse(ctx context.Context, authHeader s
 	// DEBUG: Verify subkey extraction
 	if p.clientContext.HasAcceptorSubkey() {
 		key := p.clientContext.GetKey()
-		slog.Debug("Negotiate: HasAcceptorSubkey=TRUE", "keyType", key.KeyType, "keyLen", len(key.KeyValue))
+		p.log().Debug("Negotiate: HasAcceptorSubkey=TRUE", "keyType", key.KeyType, "keyLen", len(key.KeyValue))
 	} else {
-		slog.Debug("Negotiate: HasAcceptorSubkey=FALSE", "using", "Session Key")
+		p.log().Debug("Negotiate: HasAcceptorSubkey=FALSE", "using", "Session Key")
 	}
 
 	return nil
@@ -335,7 +539,9 @@ func (p *PureKerberosProvider) ProcessResponse(ctx context.Context, authHeader s
 
 // Close releases resources.
 func (p *PureKerberosProvider) Close() error {
+	p.mu.Lock()
 	p.client.Destroy()
+	p.mu.Unlock()
 	p.clientContext = nil
 	return nil
 }
@@ -418,7 +624,7 @@ func (p *PureKerberosProvider) Wrap(inputData []byte) ([]byte, error) {
 	// EncryptedData (remaining ciphertext) - NO length prefix
 	output.Write(encryptedData)
 
-	slog.Debug("NEGOTIATE: Wrapped data",
+	p.log().Debug("NEGOTIATE: Wrapped data",
 		"signatureLen", signatureLen,
 		"encryptedDataLen", len(encryptedData),
 		"totalLen", output.Len())
@@ -469,7 +675,7 @@ func (p *PureKerberosProvider) Unwrap(data []byte) ([]byte, error) {
 	// to skip the 4-byte length prefix.
 	gssToken := data[4:]
 
-	slog.Debug("Negotiate: Unwrapping data (Zero-Copy)",
+	p.log().Debug("Negotiate: Unwrapping data (Zero-Copy)",
 		"signatureLen", signatureLen,
 		"gssTokenLen", len(gssToken))
 
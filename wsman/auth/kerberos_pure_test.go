@@ -0,0 +1,60 @@
+//go:build !psrp_nokrb5
+
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/go-krb5/krb5/gssapi"
+	"github.com/go-krb5/krb5/iana/etypeID"
+	"github.com/go-krb5/krb5/spnego"
+	"github.com/go-krb5/krb5/types"
+	"github.com/go-krb5/x/encoding/asn1"
+)
+
+func TestIsTicketExpired(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("[Root cause: KDC_Error] KDC_Error: TGS Exchange Error: kerberos error response from KDC: KRB Error: (32) KRB_AP_ERR_TKT_EXPIRED Ticket expired"), true},
+		{errors.New("KRB Error: (24) KDC_ERR_PREAUTH_FAILED Pre-authentication information was invalid"), false},
+		{errors.New("dial tcp 10.0.0.1:88: connect: connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := isTicketExpired(tt.err); got != tt.want {
+			t.Errorf("isTicketExpired(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestPureKerberos_HTTPSRequiresAPRep verifies that over HTTPS the handshake
+// only completes once the server proved itself with an AP-REP.
+func TestPureKerberos_HTTPSRequiresAPRep(t *testing.T) {
+	sessionKey := types.EncryptionKey{KeyType: etypeID.AES256_CTS_HMAC_SHA1_96, KeyValue: make([]byte, 32)}
+	clientCtx := spnego.NewClientContext(sessionKey, uint32(gssapi.ContextFlagMutual), 1)
+	clientCtx.SetMutualAuthRequired(true)
+	if err := clientCtx.SetInProgress(); err != nil {
+		t.Fatal(err)
+	}
+	p := &PureKerberosProvider{isHTTPS: true, clientContext: clientCtx}
+
+	resp := spnego.NegTokenResp{NegState: asn1.Enumerated(spnego.NegStateAcceptCompleted)}
+	token, err := (&spnego.SPNEGOToken{Resp: true, NegTokenResp: resp}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := p.Step(context.Background(), token); err == nil {
+		t.Error("Step() accepted a completed negotiation without an AP-REP")
+	}
+	if err := p.ProcessResponse(context.Background(), "Negotiate "+base64.StdEncoding.EncodeToString(token)); err != nil {
+		t.Errorf("ProcessResponse() error = %v", err)
+	}
+	if p.Complete() {
+		t.Error("Complete() = true without an AP-REP")
+	}
+}
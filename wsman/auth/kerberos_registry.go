@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+)
+
+// KerberosFactory creates a Kerberos SecurityProvider from cfg.
+type KerberosFactory func(cfg KerberosProviderConfig) (SecurityProvider, error)
+
+// ErrKerberosUnavailable is returned by NewKerberosProvider when the
+// program was built with the psrp_nokrb5 tag and no provider was
+// registered with RegisterKerberosProvider.
+var ErrKerberosUnavailable = errors.New("auth: Kerberos is not available (built with psrp_nokrb5 and no provider registered)")
+
+var (
+	kerberosMu sync.RWMutex
+	// registeredKerberos is set by RegisterKerberosProvider.
+	registeredKerberos KerberosFactory
+	// builtinKerberos is the pure Go (gokrb5) provider, unless built with
+	// psrp_nokrb5.
+	builtinKerberos KerberosFactory
+)
+
+// RegisterKerberosProvider makes f the Kerberos implementation used by
+// NewKerberosProvider on every platform, in place of gokrb5 and SSPI. It
+// lets programs built with psrp_nokrb5 plug in another implementation,
+// such as a GSSAPI binding. A nil f restores the built-in provider.
+func RegisterKerberosProvider(f KerberosFactory) {
+	kerberosMu.Lock()
+	defer kerberosMu.Unlock()
+	registeredKerberos = f
+}
+
+// KerberosAvailable reports whether NewKerberosProvider can create a
+// provider on this platform and build.
+func KerberosAvailable() bool {
+	return kerberosFactory() != nil || SupportsSSO()
+}
+
+// kerberosFactory returns the registered provider, else the built-in pure
+// Go one, or nil.
+func kerberosFactory() KerberosFactory {
+	kerberosMu.RLock()
+	defer kerberosMu.RUnlock()
+	if registeredKerberos != nil {
+		return registeredKerberos
+	}
+	return builtinKerberos
+}
+
+// registeredKerberosFactory returns the provider set with
+// RegisterKerberosProvider, or nil.
+func registeredKerberosFactory() KerberosFactory {
+	kerberosMu.RLock()
+	defer kerberosMu.RUnlock()
+	return registeredKerberos
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegisterKerberosProvider(t *testing.T) {
+	defer RegisterKerberosProvider(nil)
+
+	want := &MockSecurityProvider{}
+	var got KerberosProviderConfig
+	RegisterKerberosProvider(func(cfg KerberosProviderConfig) (SecurityProvider, error) {
+		got = cfg
+		return want, nil
+	})
+	if !KerberosAvailable() {
+		t.Error("KerberosAvailable() = false with a registered provider")
+	}
+	p, err := NewKerberosProvider(KerberosProviderConfig{TargetSPN: "HTTP/server.example.com"})
+	if err != nil {
+		t.Fatalf("NewKerberosProvider() error = %v", err)
+	}
+	if p != want || got.TargetSPN != "HTTP/server.example.com" {
+		t.Errorf("NewKerberosProvider() = %v with %+v, want the registered provider", p, got)
+	}
+
+	RegisterKerberosProvider(nil)
+	if builtinKerberos == nil && !SupportsSSO() {
+		// Built with psrp_nokrb5
+		if _, err := NewKerberosProvider(KerberosProviderConfig{}); !errors.Is(err, ErrKerberosUnavailable) {
+			t.Errorf("NewKerberosProvider() error = %v, want ErrKerberosUnavailable", err)
+		}
+		if KerberosAvailable() {
+			t.Error("KerberosAvailable() = true without a provider")
+		}
+	}
+}
@@ -19,21 +19,34 @@ import (
 
 const secEInvalidToken = 0x80090308
 
+// iscRetDelegate is the ISC_RET_DELEGATE context attribute.
+const iscRetDelegate = 0x00000001
+
 // SSPIConfig holds configuration for the SSPI provider.
 type SSPIConfig struct {
 	UseDefaultCreds bool
 	Username        string
 	Password        string
 	Domain          string
+
+	// Delegate requests credential delegation (ISC_REQ_DELEGATE).
+	Delegate bool
+
+	// Package is the SSPI security package: SSPIPackageNegotiate (the
+	// default) or SSPIPackageNTLM.
+	Package SSPIPackage
 }
 
 // SSPIProvider implements the SecurityProvider interface using Windows SSPI.
-// It uses the Negotiate security package (SPNEGO) with Channel Binding Token support.
+// It uses the Negotiate security package (SPNEGO), or NTLM, with Channel
+// Binding Token support.
 type SSPIProvider struct {
+	pkg             string
 	username        string
 	password        string
 	domain          string
 	targetSPN       string
+	delegate        bool
 	complete        bool
 	channelBindings []byte // Store CBT for reuse in Update calls
 
@@ -42,25 +55,44 @@ type SSPIProvider struct {
 	ctx        *sspi.Context
 	targetName *uint16
 	maxToken   uint32
+
+	logger *slog.Logger // set by SetLogger
 }
 
 // NewSSPIProvider creates a new SSPI-based provider.
 func NewSSPIProvider(config SSPIConfig, targetSPN string) (*SSPIProvider, error) {
+	pkg := string(config.Package)
+	if pkg == "" {
+		pkg = sspi.NEGOSSP_NAME
+	}
+
 	// Query package info for max token size
-	pkgInfo, err := sspi.QueryPackageInfo(sspi.NEGOSSP_NAME)
+	pkgInfo, err := sspi.QueryPackageInfo(pkg)
 	if err != nil {
-		return nil, fmt.Errorf("query negotiate package: %w", err)
+		return nil, fmt.Errorf("query %s package: %w", pkg, err)
 	}
 
 	return &SSPIProvider{
+		pkg:       pkg,
 		username:  config.Username,
 		password:  config.Password,
 		domain:    config.Domain,
 		targetSPN: targetSPN,
+		delegate:  config.Delegate,
 		maxToken:  pkgInfo.MaxToken,
 	}, nil
 }
 
+// SetLogger sets the logger of the provider.
+func (p *SSPIProvider) SetLogger(logger *slog.Logger) {
+	p.logger = logger
+}
+
+// log returns the logger of the provider.
+func (p *SSPIProvider) log() *slog.Logger {
+	return loggerOrDefault(p.logger)
+}
+
 // Complete returns true if the authentication exchange is complete.
 func (p *SSPIProvider) Complete() bool {
 	return p.complete
@@ -169,7 +201,7 @@ func (p *SSPIProvider) ProcessResponse(ctx context.Context, authHeader string) e
 		return nil
 	}
 
-	slog.Debug("SSPI ProcessResponse: Processing final token", "len", len(serverToken))
+	p.log().Debug("SSPI ProcessResponse: Processing final token", "len", len(serverToken))
 
 	// Update context with the final token
 	_, authCompleted, err := p.updateContextWithChannelBindings(serverToken)
@@ -180,7 +212,7 @@ func (p *SSPIProvider) ProcessResponse(ctx context.Context, authHeader string) e
 	// Update completion state
 	if authCompleted {
 		p.complete = true
-		slog.Debug("SSPI ProcessResponse: Authentication complete")
+		p.log().Debug("SSPI ProcessResponse: Authentication complete")
 	}
 
 	return nil
@@ -192,7 +224,7 @@ func (p *SSPIProvider) Step(ctx context.Context, serverToken []byte) ([]byte, bo
 		return nil, true, nil
 	}
 
-	slog.Debug("SSPI Step called", "serverTokenLen", len(serverToken))
+	p.log().Debug("SSPI Step called", "serverTokenLen", len(serverToken))
 
 	// First call: Acquire credentials and create context
 	if p.cred == nil {
@@ -210,12 +242,12 @@ func (p *SSPIProvider) Step(ctx context.Context, serverToken []byte) ([]byte, bo
 		// Store CBT from context for reuse
 		if cbtHash, ok := ctx.Value(ContextKeyChannelBindings).([]byte); ok && len(cbtHash) > 0 {
 			p.channelBindings = makeChannelBindings(cbtHash)
-			slog.Debug("SSPI Negotiate with CBT", "hashLen", len(cbtHash), "structLen", len(p.channelBindings))
+			p.log().Debug("SSPI Negotiate with CBT", "hashLen", len(cbtHash), "structLen", len(p.channelBindings))
 		} else {
-			slog.Debug("SSPI Negotiate without CBT")
+			p.log().Debug("SSPI Negotiate without CBT")
 		}
 
-		slog.Debug("SSPI configuration", "targetSPN", p.targetSPN)
+		p.log().Debug("SSPI configuration", "targetSPN", p.targetSPN)
 	}
 
 	// Call UpdateContextWithChannelBindings
@@ -223,7 +255,7 @@ func (p *SSPIProvider) Step(ctx context.Context, serverToken []byte) ([]byte, bo
 	if err != nil {
 		var sspiErr sspiStatusError
 		if errors.As(err, &sspiErr) && sspiErr.code == secEInvalidToken && len(serverToken) == 0 {
-			slog.Debug("SSPI invalid token on initial step, resetting context", "code", fmt.Sprintf("0x%x", sspiErr.code))
+			p.log().Debug("SSPI invalid token on initial step, resetting context", "code", fmt.Sprintf("0x%x", sspiErr.code))
 			if p.ctx != nil {
 				_ = p.ctx.Release()
 				p.ctx = nil
@@ -242,6 +274,10 @@ func (p *SSPIProvider) Step(ctx context.Context, serverToken []byte) ([]byte, bo
 	}
 
 	p.complete = authCompleted
+	if authCompleted && p.delegate && p.ctx.EstablishedFlags&iscRetDelegate == 0 {
+		// Authentication still works; second-hop access will not
+		p.log().Warn("SSPI: credential delegation was not granted; is the server trusted for delegation?", "targetSPN", p.targetSPN)
+	}
 	return outputToken, authCompleted, nil
 }
 
@@ -249,19 +285,19 @@ func (p *SSPIProvider) Step(ctx context.Context, serverToken []byte) ([]byte, bo
 func (p *SSPIProvider) initializeCredentials() error {
 	var err error
 
-	// Acquire credentials using NEGOSSP_NAME for SPNEGO
+	// Acquire credentials for the package (Negotiate for SPNEGO)
 	if p.username == "" {
 		// Use current user (SSO)
-		slog.Debug("Acquiring current user credentials (SSO)")
-		p.cred, err = sspi.AcquireCredentials("", sspi.NEGOSSP_NAME, sspi.SECPKG_CRED_OUTBOUND, nil)
+		p.log().Debug("Acquiring current user credentials (SSO)")
+		p.cred, err = sspi.AcquireCredentials("", p.pkg, sspi.SECPKG_CRED_OUTBOUND, nil)
 	} else {
 		// Build auth identity for explicit credentials
-		slog.Debug("Acquiring user credentials", "domain", p.domain, "username", p.username)
+		p.log().Debug("Acquiring user credentials", "domain", p.domain, "username", p.username)
 		identity, identityErr := buildAuthIdentity(p.domain, p.username, p.password)
 		if identityErr != nil {
 			return fmt.Errorf("build auth identity: %w", identityErr)
 		}
-		p.cred, err = sspi.AcquireCredentials("", sspi.NEGOSSP_NAME, sspi.SECPKG_CRED_OUTBOUND, identity)
+		p.cred, err = sspi.AcquireCredentials("", p.pkg, sspi.SECPKG_CRED_OUTBOUND, identity)
 	}
 	if err != nil {
 		return fmt.Errorf("acquire SSPI credentials: %w", err)
@@ -270,11 +306,13 @@ func (p *SSPIProvider) initializeCredentials() error {
 	// Create client context with standard flags
 	flags := sspi.ISC_REQ_CONNECTION |
 		sspi.ISC_REQ_MUTUAL_AUTH |
-		sspi.ISC_REQ_DELEGATE |
 		sspi.ISC_REQ_INTEGRITY |
 		sspi.ISC_REQ_CONFIDENTIALITY |
 		sspi.ISC_REQ_REPLAY_DETECT |
 		sspi.ISC_REQ_SEQUENCE_DETECT
+	if p.delegate {
+		flags |= sspi.ISC_REQ_DELEGATE
+	}
 
 	p.ctx = sspi.NewClientContext(p.cred, uint32(flags))
 	return nil
@@ -334,7 +372,7 @@ func (p *SSPIProvider) updateContextWithChannelBindings(serverToken []byte) ([]b
 	ret := p.ctx.Update(p.targetName, outBufs, inBufs)
 	n := int(outBuf[0].BufferSize)
 
-	slog.Debug("SSPI Update result", "returnCode", fmt.Sprintf("0x%x", uint32(ret)), "tokenLen", n)
+	p.log().Debug("SSPI Update result", "returnCode", fmt.Sprintf("0x%x", uint32(ret)), "tokenLen", n)
 
 	switch ret {
 	case sspi.SEC_E_OK:
@@ -361,7 +399,7 @@ func (p *SSPIProvider) Close() error {
 
 	if p.ctx != nil {
 		if err := p.ctx.Release(); err != nil {
-			slog.Warn("SSPI Release Context failed", "error", err)
+			p.log().Warn("SSPI Release Context failed", "error", err)
 			errs = append(errs, fmt.Sprintf("context release: %v", err))
 		}
 		p.ctx = nil
@@ -7,21 +7,19 @@ package auth
 //   - SSPI handles Kerberos natively via the Negotiate package
 //   - SSPI integrates with Windows credential store (LSA)
 //   - pure Go Kerberos (gokrb5) doesn't work on Windows (no krb5.conf, no MSLSA ccache support)
+//
+// A provider registered with RegisterKerberosProvider is used instead.
 func NewKerberosProvider(cfg KerberosProviderConfig) (SecurityProvider, error) {
-	sspiCfg := SSPIConfig{
-		// Default to SSO (use current Windows user credentials)
-		UseDefaultCreds: true,
+	if f := registeredKerberosFactory(); f != nil {
+		return f(cfg)
 	}
-
-	// If explicit credentials provided, use them instead of SSO
-	if cfg.Credentials != nil && cfg.Credentials.Username != "" {
-		sspiCfg.UseDefaultCreds = false
-		sspiCfg.Username = cfg.Credentials.Username
-		sspiCfg.Password = cfg.Credentials.Password
-		sspiCfg.Domain = cfg.Credentials.Domain
-	}
-
-	return NewSSPIProvider(sspiCfg, cfg.TargetSPN)
+	// Without explicit credentials, SSO with the current Windows user
+	return NewSSPISecurityProvider(SSPIProviderConfig{
+		Package:     SSPIPackageNegotiate,
+		TargetSPN:   cfg.TargetSPN,
+		Credentials: cfg.Credentials,
+		Delegate:    cfg.Delegate,
+	})
 }
 
 // SupportsSSO returns true if the platform supports SSO.
@@ -25,6 +25,7 @@ const maxNegotiateRetries = 5
 // NegotiateAuth implements SPNEGO authentication using a pluggable SecurityProvider.
 type NegotiateAuth struct {
 	provider SecurityProvider
+	logger   *slog.Logger
 }
 
 // NewNegotiateAuth creates a new Negotiate authenticator.
@@ -39,27 +40,53 @@ func (a *NegotiateAuth) Name() string {
 	return "Negotiate"
 }
 
+// SetLogger sets the logger of the authenticator and its provider.
+func (a *NegotiateAuth) SetLogger(logger *slog.Logger) {
+	a.logger = logger
+	SetLogger(a.provider, logger)
+}
+
 // Transport wraps the base transport with Negotiate authentication logic.
 func (a *NegotiateAuth) Transport(base http.RoundTripper) http.RoundTripper {
 	return &negotiateRoundTripper{
 		base:     base,
 		provider: a.provider,
+		logger:   loggerOrDefault(a.logger),
+	}
+}
+
+// ConnectionBound implements ConnectionBound for the provider.
+func (a *NegotiateAuth) ConnectionBound() bool {
+	return IsConnectionBound(a.provider)
+}
+
+// RefreshCredentials refreshes the provider's credentials if it implements
+// CredentialRefresher, and does nothing otherwise.
+func (a *NegotiateAuth) RefreshCredentials() error {
+	if r, ok := a.provider.(CredentialRefresher); ok {
+		return r.RefreshCredentials()
 	}
+	return nil
 }
 
 type negotiateRoundTripper struct {
 	base     http.RoundTripper
 	provider SecurityProvider
+	logger   *slog.Logger
 	mu       sync.Mutex
 }
 
 // contextKey is a context key type.
 type contextKey string
 
-// ContextKeyChannelBindings is the context key for Channel Binding Token (CBT) data.
-// ContextKeyChannelBindings is the context key for Channel Binding Token (CBT) data.
+// ContextKeyChannelBindings is the context key for Channel Binding Token (CBT)
+// data: the tls-server-end-point hash of the server certificate (RFC 5929).
+// Providers bind their tokens to it for Extended Protection.
 const ContextKeyChannelBindings = contextKey("ChannelBindings")
 
+// ContextKeyIsHTTPS is the context key for detecting HTTPS transport.
+const ContextKeyIsHTTPS = contextKey("isHTTPS")
+
 // WinRM multipart encryption constants
 const (
 	// winrmBoundary includes the -- prefix per pypsrp format
@@ -196,7 +223,7 @@ func (rt *negotiateRoundTripper) RoundTrip(req *http.Request) (*http.Response, e
 		}
 	}
 
-	slog.Debug("Negotiate: Request details", "scheme", req.URL.Scheme, "authComplete", rt.provider.Complete(), "hasBody", len(bodyBytes) > 0)
+	rt.logger.Debug("Negotiate: Request details", "scheme", req.URL.Scheme, "authComplete", rt.provider.Complete(), "hasBody", len(bodyBytes) > 0)
 
 	// SPECIAL HANDLING FOR HTTP KERBEROS:
 	// We cannot simply encrypt headers and send. We must establish the security context first.
@@ -204,7 +231,7 @@ func (rt *negotiateRoundTripper) RoundTrip(req *http.Request) (*http.Response, e
 	// This is because the server sends the AP-REP (needed for mutual auth & session key) in the response.
 	// Only AFTER processing the AP-REP can we encrypt the real payload.
 	if isHTTP && !authComplete && len(bodyBytes) > 0 {
-		slog.Debug("Negotiate: HTTP pre-auth with body - Initiating HANDSHAKE-FIRST flow")
+		rt.logger.Debug("Negotiate: HTTP pre-auth with body - Initiating HANDSHAKE-FIRST flow")
 
 		// 1. Create a "Handshake" request (shallow copy, empty body)
 		handshakeReq := req.Clone(req.Context())
@@ -223,14 +250,14 @@ func (rt *negotiateRoundTripper) RoundTrip(req *http.Request) (*http.Response, e
 		// If status is 200/OK, we discard it and proceed to send the REAL encrypted payload
 		if handshakeResp != nil {
 			if handshakeResp.StatusCode >= 400 {
-				slog.Error("Negotiate: Handshake returned error", "status", handshakeResp.Status)
+				rt.logger.Error("Negotiate: Handshake returned error", "status", handshakeResp.Status)
 				return handshakeResp, nil
 			}
 			if handshakeResp.Body != nil {
 				_ = handshakeResp.Body.Close()
 			}
 		}
-		slog.Debug("Negotiate: Handshake complete", "authComplete", rt.provider.Complete())
+		rt.logger.Debug("Negotiate: Handshake complete", "authComplete", rt.provider.Complete())
 
 		// 4. Now that auth is supposedly complete, encrypt and send the real body
 		// We fall through to the logic below, which checks rt.provider.Complete()
@@ -253,14 +280,14 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 		// Encrypt
 		rt.mu.Lock()
 		defer rt.mu.Unlock()
-		slog.Debug("Negotiate: POST-AUTH HTTP REQUEST - Encrypting body", "plainLen", len(bodyBytes))
+		rt.logger.Debug("Negotiate: POST-AUTH HTTP REQUEST - Encrypting body", "plainLen", len(bodyBytes))
 
 		encrypted, err := rt.provider.Wrap(bodyBytes)
 		if err != nil {
-			slog.Error("Negotiate: Encryption FAILED", "error", err)
+			rt.logger.Error("Negotiate: Encryption FAILED", "error", err)
 			return nil, fmt.Errorf("encrypt request body: %w", err)
 		}
-		slog.Debug("Negotiate: Encryption SUCCESS", "encryptedLen", len(encrypted))
+		rt.logger.Debug("Negotiate: Encryption SUCCESS", "encryptedLen", len(encrypted))
 
 		// Wrap in WinRM multipart/encrypted format
 		multipartBody, contentType := wrapWinRMMultipart(encrypted, len(bodyBytes))
@@ -273,7 +300,7 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 			return io.NopCloser(bytes.NewReader(multipartBody)), nil
 		}
 
-		slog.Debug("Negotiate: Sending encrypted multipart request", "multipartLen", len(multipartBody))
+		rt.logger.Debug("Negotiate: Sending encrypted multipart request", "multipartLen", len(multipartBody))
 
 		// Send the encrypted request directly (skip auth loop)
 		resp, err := rt.base.RoundTrip(req)
@@ -284,9 +311,9 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 		// Check for error response
 		if resp.StatusCode >= 400 {
 			if resp.StatusCode == 500 {
-				slog.Debug("Negotiate: HTTP 500 response (possible timeout)", "status", resp.Status)
+				rt.logger.Debug("Negotiate: HTTP 500 response (possible timeout)", "status", resp.Status)
 			} else {
-				slog.Error("Negotiate: HTTP error response", "status", resp.Status)
+				rt.logger.Error("Negotiate: HTTP error response", "status", resp.Status)
 			}
 		}
 
@@ -295,7 +322,7 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 		isEncryptedResponse := strings.Contains(respContentType, "multipart/encrypted")
 
 		if isEncryptedResponse {
-			slog.Debug("Negotiate: Received encrypted response", "contentType", respContentType)
+			rt.logger.Debug("Negotiate: Received encrypted response", "contentType", respContentType)
 			respBody, err := io.ReadAll(resp.Body)
 			_ = resp.Body.Close()
 			if err != nil {
@@ -307,22 +334,22 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 			if err != nil {
 				return nil, fmt.Errorf("unwrap multipart: %w", err)
 			}
-			slog.Debug("Negotiate: Unwrapped multipart", "payloadLen", len(encryptedPayload))
+			rt.logger.Debug("Negotiate: Unwrapped multipart", "payloadLen", len(encryptedPayload))
 
 			// Decrypt payload
 			decrypted, err := rt.provider.Unwrap(encryptedPayload)
 			if err != nil {
-				slog.Error("Negotiate: Decryption FAILED", "error", err)
+				rt.logger.Error("Negotiate: Decryption FAILED", "error", err)
 				return nil, fmt.Errorf("decrypt response body: %w", err)
 			}
-			slog.Debug("Negotiate: Decryption SUCCESS", "decryptedLen", len(decrypted))
+			rt.logger.Debug("Negotiate: Decryption SUCCESS", "decryptedLen", len(decrypted))
 
 			// Restore original response body
 			resp.Body = io.NopCloser(bytes.NewReader(decrypted))
 			resp.ContentLength = int64(len(decrypted))
 			resp.Header.Set("Content-Type", winrmOriginalType)
 		} else {
-			slog.Info("Negotiate: Received UNENCRYPTED response (unexpected for established Kerberos context?)", "status", resp.Status)
+			rt.logger.Info("Negotiate: Received UNENCRYPTED response (unexpected for established Kerberos context?)", "status", resp.Status)
 		}
 
 		return resp, nil
@@ -335,7 +362,7 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 	trace := &httptrace.ClientTrace{
 		GotConn: func(info httptrace.GotConnInfo) {
 			if info.Conn == nil {
-				slog.Warn("GotConn returned nil connection")
+				rt.logger.Warn("GotConn returned nil connection")
 				return
 			}
 			if tlsConn, ok := info.Conn.(*tls.Conn); ok {
@@ -345,7 +372,7 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 		},
 		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
 			if err != nil {
-				slog.Warn("TLS handshake failed", "error", err)
+				rt.logger.Warn("TLS handshake failed", "error", err)
 				return
 			}
 			cbtData = getCBTHash(&state)
@@ -374,7 +401,7 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 		// Add auth header if we have a token from provider
 		if clientToken != nil {
 			authHeaderValue := fmt.Sprintf("Negotiate %s", base64.StdEncoding.EncodeToString(clientToken))
-			slog.Debug("Sending Authorization header", "length", len(authHeaderValue), "tokenB64Len", len(base64.StdEncoding.EncodeToString(clientToken)))
+			rt.logger.Debug("Sending Authorization header", "length", len(authHeaderValue), "tokenB64Len", len(base64.StdEncoding.EncodeToString(clientToken)))
 			reqClone.Header.Set("Authorization", authHeaderValue)
 		}
 
@@ -386,7 +413,7 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 		if len(cbtData) == 0 && resp != nil && resp.TLS != nil {
 			cbtData = getCBTHash(resp.TLS)
 		}
-		slog.Debug("Negotiate: CBT status", "cbtLen", len(cbtData), "respTLS", resp != nil && resp.TLS != nil)
+		rt.logger.Debug("Negotiate: CBT status", "cbtLen", len(cbtData), "respTLS", resp != nil && resp.TLS != nil)
 
 		// Success - return response
 		if resp.StatusCode != http.StatusUnauthorized {
@@ -399,9 +426,9 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 					if len(parts) == 2 {
 						serverTokenBytes, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
 						if len(serverTokenBytes) > 0 {
-							slog.Debug("Negotiate: Processing final mutual auth token")
+							rt.logger.Debug("Negotiate: Processing final mutual auth token")
 							if err := rt.provider.ProcessResponse(ctx, authHeader); err != nil {
-								slog.Warn("Negotiate: Failed to process final mutual auth token", "error", err)
+								rt.logger.Warn("Negotiate: Failed to process final mutual auth token", "error", err)
 							}
 						}
 					}
@@ -435,7 +462,7 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 
 		// Generate our response token (after capturing CBT)
 		var continueNeeded bool
-		stepCtx := ctx
+		stepCtx := context.WithValue(ctx, ContextKeyIsHTTPS, req.URL.Scheme == "https")
 		if len(cbtData) > 0 {
 			stepCtx = context.WithValue(ctx, ContextKeyChannelBindings, cbtData)
 		}
@@ -448,8 +475,9 @@ func (rt *negotiateRoundTripper) roundTripInternal(req *http.Request, bodyBytes
 		// Close response body before retry
 		_ = resp.Body.Close()
 
-		// If no more steps needed and we already sent a token, we're done
-		if !continueNeeded && attempt > 0 {
+		// If no more steps are needed and there is no final token to send
+		// (NTLM completes with the AUTHENTICATE message still unsent), we're done
+		if !continueNeeded && attempt > 0 && len(clientToken) == 0 {
 			break
 		}
 	}
@@ -257,3 +257,81 @@ func TestNegotiateRoundTrip_MaxRetries(t *testing.T) {
 		t.Errorf("Error = %v; want max retries error", err)
 	}
 }
+
+func TestNegotiateRoundTrip_NTLMThreeLeg(t *testing.T) {
+	// NTLM finishes with continueNeeded=false while the AUTHENTICATE token is
+	// still unsent, so the loop must send it before stopping.
+	provider := &MockSecurityProvider{
+		StepFunc: func(ctx context.Context, serverToken []byte) ([]byte, bool, error) {
+			if len(serverToken) == 0 {
+				return []byte("negotiate"), true, nil
+			}
+			return []byte("authenticate"), false, nil
+		},
+	}
+
+	requests := 0
+	transport := &MockRoundTripper{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			requests++
+			switch req.Header.Get("Authorization") {
+			case "":
+				return &http.Response{
+					StatusCode: 401,
+					Header:     http.Header{"Www-Authenticate": []string{"Negotiate"}},
+					Body:       io.NopCloser(strings.NewReader("")),
+				}, nil
+			case "Negotiate " + base64.StdEncoding.EncodeToString([]byte("negotiate")):
+				challenge := base64.StdEncoding.EncodeToString([]byte("challenge"))
+				return &http.Response{
+					StatusCode: 401,
+					Header:     http.Header{"Www-Authenticate": []string{"Negotiate " + challenge}},
+					Body:       io.NopCloser(strings.NewReader("")),
+				}, nil
+			case "Negotiate " + base64.StdEncoding.EncodeToString([]byte("authenticate")):
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("success"))}, nil
+			}
+			return nil, errors.New("unexpected authorization header")
+		},
+	}
+
+	rt := NewNegotiateAuth(provider).Transport(transport)
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("StatusCode = %d; want 200", resp.StatusCode)
+	}
+	if requests != 3 {
+		t.Errorf("requests = %d; want 3", requests)
+	}
+}
+
+// refreshingProvider is a MockSecurityProvider with expiring credentials.
+type refreshingProvider struct {
+	MockSecurityProvider
+	refreshed int
+}
+
+func (p *refreshingProvider) RefreshCredentials() error {
+	p.refreshed++
+	return nil
+}
+
+func TestNegotiateAuth_RefreshCredentials(t *testing.T) {
+	provider := &refreshingProvider{}
+	if err := NewNegotiateAuth(provider).RefreshCredentials(); err != nil {
+		t.Fatalf("RefreshCredentials() error = %v", err)
+	}
+	if provider.refreshed != 1 {
+		t.Errorf("provider refreshed %d times, want 1", provider.refreshed)
+	}
+
+	// Providers without expiring credentials have nothing to refresh
+	if err := NewNegotiateAuth(&MockSecurityProvider{}).RefreshCredentials(); err != nil {
+		t.Errorf("RefreshCredentials() without refresher error = %v", err)
+	}
+}
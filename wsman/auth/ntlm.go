@@ -16,6 +16,7 @@ import (
 type NTLMAuth struct {
 	creds     Credentials
 	enableCBT bool
+	logger    *slog.Logger
 }
 
 // NTLMAuthOption configures NTLM authentication.
@@ -46,6 +47,11 @@ func (a *NTLMAuth) Name() string {
 	return "NTLM"
 }
 
+// SetLogger sets the logger of the authenticator.
+func (a *NTLMAuth) SetLogger(logger *slog.Logger) {
+	a.logger = logger
+}
+
 // Transport wraps an http.RoundTripper with NTLM authentication.
 // Uses github.com/Azure/go-ntlmssp for the NTLM handshake logic (connection management),
 // and a custom injector to add CBT support if enabled.
@@ -57,8 +63,9 @@ func (a *NTLMAuth) Transport(base http.RoundTripper) http.RoundTripper {
 	// client -> credentialsRT -> go-ntlmssp -> cbtInjector -> base
 	if a.enableCBT {
 		injector := &cbtInjectorRoundTripper{
-			base:  base,
-			creds: a.creds,
+			base:   base,
+			creds:  a.creds,
+			logger: loggerOrDefault(a.logger).With("scheme", "NTLM"),
 		}
 		ntlmTransport = injector
 	} else {
@@ -102,8 +109,9 @@ func (c *credentialsRoundTripper) RoundTrip(req *http.Request) (*http.Response,
 // cbtInjectorRoundTripper intercepts NTLM messages to inject Channel Binding Tokens.
 // It relies on go-ntlmssp to drive the handshake state machine.
 type cbtInjectorRoundTripper struct {
-	base  http.RoundTripper
-	creds Credentials
+	base   http.RoundTripper
+	creds  Credentials
+	logger *slog.Logger
 
 	// State for the handshake (protected by lock if reused, but RoundTrippers are usually per-client)
 	mu            sync.Mutex
@@ -153,9 +161,9 @@ func (rt *cbtInjectorRoundTripper) RoundTrip(req *http.Request) (*http.Response,
 					if err == nil {
 						// Replace the header use correct prefix
 						req.Header.Set("Authorization", prefix+base64.StdEncoding.EncodeToString(newType3))
-						slog.Debug("Injected NTLM CBT into Type 3 message", "component", "ntlm", "cbt_md5", fmt.Sprintf("%x", cbt.MD5Hash()))
+						rt.logger.Debug("Injected NTLM CBT into Type 3 message", "cbt_md5", fmt.Sprintf("%x", cbt.MD5Hash()))
 					} else {
-						slog.Warn("Failed to generate CBT Type 3 message", "component", "ntlm", "error", err)
+						rt.logger.Warn("Failed to generate CBT Type 3 message", "error", err)
 					}
 				}
 			}
@@ -191,7 +199,7 @@ func (rt *cbtInjectorRoundTripper) RoundTrip(req *http.Request) (*http.Response,
 							var cb *ntlmcbt.GSSChannelBindings
 							if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
 								cb = ntlmcbt.ComputeTLSServerEndpoint(resp.TLS.PeerCertificates[0])
-								slog.Debug("Captured NTLM Type 2 Challenge, computed CBT", "component", "ntlm", "cbt_md5", fmt.Sprintf("%x", cb.MD5Hash()))
+								rt.logger.Debug("Captured NTLM Type 2 Challenge, computed CBT", "cbt_md5", fmt.Sprintf("%x", cb.MD5Hash()))
 							}
 
 							rt.mu.Lock()
@@ -0,0 +1,499 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5" //nolint:gosec // MD5 is mandated by MS-NLMP
+	"crypto/rand"
+	"crypto/rc4" //nolint:gosec // RC4 is mandated by MS-NLMP
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4" //nolint:staticcheck // MD4 is mandated by MS-NLMP
+)
+
+// NTLM negotiate flags (MS-NLMP 2.2.2.5).
+const (
+	ntlmFlagUnicode                 uint32 = 0x00000001
+	ntlmFlagRequestTarget           uint32 = 0x00000004
+	ntlmFlagSign                    uint32 = 0x00000010
+	ntlmFlagSeal                    uint32 = 0x00000020
+	ntlmFlagNTLM                    uint32 = 0x00000200
+	ntlmFlagAlwaysSign              uint32 = 0x00008000
+	ntlmFlagExtendedSessionSecurity uint32 = 0x00080000
+	ntlmFlagTargetInfo              uint32 = 0x00800000
+	ntlmFlagVersion                 uint32 = 0x02000000
+	ntlmFlag128                     uint32 = 0x20000000
+	ntlmFlagKeyExch                 uint32 = 0x40000000
+	ntlmFlag56                      uint32 = 0x80000000
+
+	ntlmClientFlags = ntlmFlagUnicode | ntlmFlagRequestTarget | ntlmFlagSign | ntlmFlagSeal |
+		ntlmFlagNTLM | ntlmFlagAlwaysSign | ntlmFlagExtendedSessionSecurity | ntlmFlagTargetInfo |
+		ntlmFlagVersion | ntlmFlag128 | ntlmFlagKeyExch | ntlmFlag56
+)
+
+// ntlmSignature is the NTLMSSP message signature.
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmVersion advertises Windows 10.0 build 19041, NTLM revision 15.
+var ntlmVersion = []byte{10, 0, 0x61, 0x4a, 0, 0, 0, 15}
+
+// Key derivation magic constants (MS-NLMP 3.4.5.2, 3.4.5.3).
+const (
+	clientSigningMagic = "session key to client-to-server signing key magic constant\x00"
+	serverSigningMagic = "session key to server-to-client signing key magic constant\x00"
+	clientSealingMagic = "session key to client-to-server sealing key magic constant\x00"
+	serverSealingMagic = "session key to server-to-client sealing key magic constant\x00"
+)
+
+// ntlmSignatureSize is the size of an NTLMSSP_MESSAGE_SIGNATURE.
+const ntlmSignatureSize = 16
+
+// ErrNTLMSignatureMismatch indicates a sealed message from the server failed verification.
+var ErrNTLMSignatureMismatch = errors.New("ntlm: message signature mismatch")
+
+// NTLMProvider implements SecurityProvider using NTLMv2 with session security.
+//
+// Unlike NTLMAuth, which only authenticates, NTLMProvider keeps the negotiated
+// session keys so that Wrap/Unwrap can seal WinRM messages. Use it with
+// NewNegotiateAuth to talk to servers over HTTP with AllowUnencrypted=false
+// (the default):
+//
+//	authenticator := auth.NewNegotiateAuth(auth.NewNTLMProvider(creds))
+//
+// Over HTTPS, NegotiateAuth passes the TLS channel bindings, and the
+// AUTHENTICATE message carries them for Extended Protection.
+type NTLMProvider struct {
+	creds Credentials
+
+	// certHash is the tls-server-end-point certificate hash from
+	// ContextKeyChannelBindings, if any.
+	certHash []byte
+
+	// now and random are overridable for tests.
+	now    func() time.Time
+	random func([]byte) error
+
+	// negotiate is the NEGOTIATE message sent, which the MIC covers.
+	negotiate []byte
+
+	flags      uint32
+	isComplete bool
+
+	clientSigningKey []byte
+	serverSigningKey []byte
+	clientSealer     *rc4.Cipher
+	serverSealer     *rc4.Cipher
+	clientSeq        uint32
+	serverSeq        uint32
+}
+
+// NewNTLMProvider creates a new NTLM security provider.
+func NewNTLMProvider(creds Credentials) *NTLMProvider {
+	return &NTLMProvider{
+		creds: creds,
+		now:   time.Now,
+		random: func(b []byte) error {
+			_, err := rand.Read(b)
+			return err
+		},
+	}
+}
+
+// Step produces the NEGOTIATE message on the first call and the AUTHENTICATE
+// message in response to the server's CHALLENGE.
+func (p *NTLMProvider) Step(ctx context.Context, inputToken []byte) ([]byte, bool, error) {
+	if certHash, ok := ctx.Value(ContextKeyChannelBindings).([]byte); ok {
+		p.certHash = certHash
+	}
+	if len(inputToken) == 0 {
+		p.isComplete = false
+		p.negotiate = p.negotiateMessage()
+		return p.negotiate, true, nil
+	}
+
+	token, err := p.authenticateMessage(inputToken)
+	if err != nil {
+		return nil, false, err
+	}
+	p.isComplete = true
+	return token, false, nil
+}
+
+// Complete returns true once the AUTHENTICATE message has been produced.
+func (p *NTLMProvider) Complete() bool {
+	return p.isComplete
+}
+
+// Wrap seals data for the server in MS-WSMV format:
+// [SignatureLength 4][Signature 16][SealedData].
+func (p *NTLMProvider) Wrap(data []byte) ([]byte, error) {
+	if !p.isComplete || p.clientSealer == nil {
+		return nil, errors.New("ntlm: security context not established")
+	}
+
+	out := make([]byte, 4+ntlmSignatureSize+len(data))
+	binary.LittleEndian.PutUint32(out[0:4], ntlmSignatureSize)
+
+	// The message is encrypted before the checksum, both with the same RC4 stream.
+	p.clientSealer.XORKeyStream(out[4+ntlmSignatureSize:], data)
+	copy(out[4:4+ntlmSignatureSize], p.sign(p.clientSealer, p.clientSigningKey, p.clientSeq, data))
+	p.clientSeq++
+
+	return out, nil
+}
+
+// Unwrap unseals and verifies data from the server in MS-WSMV format.
+func (p *NTLMProvider) Unwrap(data []byte) ([]byte, error) {
+	if !p.isComplete || p.serverSealer == nil {
+		return nil, errors.New("ntlm: security context not established")
+	}
+	if len(data) < 4 {
+		return nil, errors.New("ntlm: sealed message too short")
+	}
+	sigLen := binary.LittleEndian.Uint32(data[0:4])
+	if sigLen != ntlmSignatureSize || len(data) < 4+ntlmSignatureSize {
+		return nil, fmt.Errorf("ntlm: invalid signature length %d", sigLen)
+	}
+	signature := data[4 : 4+ntlmSignatureSize]
+	sealed := data[4+ntlmSignatureSize:]
+
+	plain := make([]byte, len(sealed))
+	p.serverSealer.XORKeyStream(plain, sealed)
+	expected := p.sign(p.serverSealer, p.serverSigningKey, p.serverSeq, plain)
+	p.serverSeq++
+
+	if subtle.ConstantTimeCompare(signature, expected) != 1 {
+		return nil, ErrNTLMSignatureMismatch
+	}
+	return plain, nil
+}
+
+// ProcessResponse is a no-op; NTLM has no final server token.
+func (p *NTLMProvider) ProcessResponse(_ context.Context, _ string) error {
+	return nil
+}
+
+// ConnectionBound implements ConnectionBound: the server keeps the
+// session keys with the connection that authenticated.
+func (p *NTLMProvider) ConnectionBound() bool {
+	return true
+}
+
+// Close clears the session keys.
+func (p *NTLMProvider) Close() error {
+	p.isComplete = false
+	p.clientSigningKey = nil
+	p.serverSigningKey = nil
+	p.clientSealer = nil
+	p.serverSealer = nil
+	return nil
+}
+
+// negotiateMessage builds the NEGOTIATE_MESSAGE (MS-NLMP 2.2.1.1).
+func (p *NTLMProvider) negotiateMessage() []byte {
+	msg := make([]byte, 40)
+	copy(msg[0:8], ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:12], 1)
+	binary.LittleEndian.PutUint32(msg[12:16], ntlmClientFlags)
+	// DomainNameFields and WorkstationFields are left empty.
+	copy(msg[32:40], ntlmVersion)
+	return msg
+}
+
+// ntlmChallenge holds the fields of a CHALLENGE_MESSAGE used by the client.
+type ntlmChallenge struct {
+	flags      uint32
+	challenge  []byte
+	targetInfo []byte
+}
+
+// parseNTLMChallenge parses a CHALLENGE_MESSAGE (MS-NLMP 2.2.1.2).
+func parseNTLMChallenge(msg []byte) (*ntlmChallenge, error) {
+	if len(msg) < 48 || !bytes.Equal(msg[0:8], ntlmSignature) {
+		return nil, errors.New("ntlm: invalid challenge message")
+	}
+	if msgType := binary.LittleEndian.Uint32(msg[8:12]); msgType != 2 {
+		return nil, fmt.Errorf("ntlm: unexpected message type %d", msgType)
+	}
+
+	c := &ntlmChallenge{
+		flags:     binary.LittleEndian.Uint32(msg[20:24]),
+		challenge: msg[24:32],
+	}
+	if c.flags&ntlmFlagTargetInfo != 0 {
+		length := int(binary.LittleEndian.Uint16(msg[40:42]))
+		offset := int(binary.LittleEndian.Uint32(msg[44:48]))
+		if offset+length > len(msg) {
+			return nil, errors.New("ntlm: target info out of range")
+		}
+		c.targetInfo = msg[offset : offset+length]
+	}
+	return c, nil
+}
+
+// AV pair IDs (MS-NLMP 2.2.2.1).
+const (
+	avEOL             = 0
+	avFlags           = 6
+	avTimestamp       = 7
+	avChannelBindings = 10
+)
+
+// avFlagMIC is the MsvAvFlags bit telling the server that the
+// AUTHENTICATE message carries a MIC.
+const avFlagMIC uint32 = 0x00000002
+
+// ntlmAVPair returns the value of the AV pair id from target info, if present.
+func ntlmAVPair(targetInfo []byte, id uint16) ([]byte, bool) {
+	for len(targetInfo) >= 4 {
+		pairID := binary.LittleEndian.Uint16(targetInfo[0:2])
+		length := int(binary.LittleEndian.Uint16(targetInfo[2:4]))
+		if pairID == avEOL || 4+length > len(targetInfo) {
+			break
+		}
+		if pairID == id {
+			return targetInfo[4 : 4+length], true
+		}
+		targetInfo = targetInfo[4+length:]
+	}
+	return nil, false
+}
+
+// ntlmTimestamp returns the MsvAvTimestamp value from target info, if present.
+func ntlmTimestamp(targetInfo []byte) ([]byte, bool) {
+	if value, ok := ntlmAVPair(targetInfo, avTimestamp); ok && len(value) == 8 {
+		return value, true
+	}
+	return nil, false
+}
+
+// ntlmFileTime returns t as a FILETIME: 100ns intervals since 1601-01-01.
+func ntlmFileTime(t time.Time) uint64 {
+	return uint64(t.Unix()+11644473600)*1e7 + uint64(t.Nanosecond()/100) // #nosec G115 -- times after 1601
+}
+
+// ntlmUserDomain returns the user and domain names sent for creds. Like
+// go-ntlmssp, a DOMAIN\user name is split; a user@domain name is sent
+// whole, with an empty domain. An explicit Domain takes precedence.
+func ntlmUserDomain(creds Credentials) (user, domain string) {
+	user, domain = creds.Username, creds.Domain
+	if d, u, ok := strings.Cut(user, `\`); ok {
+		user = u
+		if domain == "" {
+			domain = d
+		}
+	}
+	return user, domain
+}
+
+// withAVPair returns targetInfo with the AV pair id set to value, replacing
+// any existing pair with that id, and terminated by MsvAvEOL.
+func withAVPair(targetInfo []byte, id uint16, value []byte) []byte {
+	out := make([]byte, 0, len(targetInfo)+8+len(value))
+	for len(targetInfo) >= 4 {
+		pairID := binary.LittleEndian.Uint16(targetInfo[0:2])
+		length := int(binary.LittleEndian.Uint16(targetInfo[2:4]))
+		if pairID == avEOL || 4+length > len(targetInfo) {
+			break
+		}
+		if pairID != id {
+			out = append(out, targetInfo[:4+length]...)
+		}
+		targetInfo = targetInfo[4+length:]
+	}
+	out = binary.LittleEndian.AppendUint16(out, id)
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(value))) // #nosec G115 -- AV values are short
+	out = append(out, value...)
+	return append(out, 0, 0, 0, 0) // MsvAvEOL
+}
+
+// authenticateMessage builds the AUTHENTICATE_MESSAGE (MS-NLMP 2.2.1.3) using
+// NTLMv2 and derives the session keys.
+func (p *NTLMProvider) authenticateMessage(challengeMsg []byte) ([]byte, error) {
+	challenge, err := parseNTLMChallenge(challengeMsg)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.flags&ntlmFlagSeal == 0 || challenge.flags&ntlmFlagExtendedSessionSecurity == 0 {
+		return nil, errors.New("ntlm: server does not support sealing with extended session security")
+	}
+	p.flags = challenge.flags & ntlmClientFlags
+
+	// NTLMv2 response (MS-NLMP 3.3.2)
+	username, domainName := ntlmUserDomain(p.creds)
+	responseKey := ntowfv2(username, p.creds.Password, domainName)
+
+	timestamp, serverTime := ntlmTimestamp(challenge.targetInfo)
+	if !serverTime {
+		timestamp = binary.LittleEndian.AppendUint64(nil, ntlmFileTime(p.now()))
+	}
+
+	// A server that sends a timestamp checks the MIC, which protects the
+	// three messages from tampering (MS-NLMP 3.1.5.1.2)
+	targetInfo := challenge.targetInfo
+	if serverTime {
+		var flags uint32
+		if value, ok := ntlmAVPair(targetInfo, avFlags); ok && len(value) == 4 {
+			flags = binary.LittleEndian.Uint32(value)
+		}
+		targetInfo = withAVPair(targetInfo, avFlags, binary.LittleEndian.AppendUint32(nil, flags|avFlagMIC))
+	}
+
+	// Extended Protection: bind the response to the TLS channel
+	if len(p.certHash) > 0 {
+		targetInfo = withAVPair(targetInfo, avChannelBindings, channelBindingsHash(p.certHash))
+	}
+
+	clientChallenge := make([]byte, 8)
+	if err := p.random(clientChallenge); err != nil {
+		return nil, fmt.Errorf("ntlm: generate client challenge: %w", err)
+	}
+
+	var temp bytes.Buffer
+	temp.Write([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	temp.Write(timestamp)
+	temp.Write(clientChallenge)
+	temp.Write([]byte{0, 0, 0, 0})
+	temp.Write(targetInfo)
+	temp.Write([]byte{0, 0, 0, 0})
+
+	ntProof := hmacMD5(responseKey, challenge.challenge, temp.Bytes())
+	ntResponse := append(append([]byte{}, ntProof...), temp.Bytes()...)
+
+	// LMv2 must be zeroed when the server supplied a timestamp.
+	lmResponse := make([]byte, 24)
+	if !serverTime {
+		lmResponse = append(hmacMD5(responseKey, challenge.challenge, clientChallenge), clientChallenge...)
+	}
+
+	// Session keys (MS-NLMP 3.4.5)
+	keyExchangeKey := hmacMD5(responseKey, ntProof)
+	sessionKey := keyExchangeKey
+	var encryptedSessionKey []byte
+	if p.flags&ntlmFlagKeyExch != 0 {
+		sessionKey = make([]byte, 16)
+		if err := p.random(sessionKey); err != nil {
+			return nil, fmt.Errorf("ntlm: generate session key: %w", err)
+		}
+		cipher, err := rc4.NewCipher(keyExchangeKey)
+		if err != nil {
+			return nil, err
+		}
+		encryptedSessionKey = make([]byte, 16)
+		cipher.XORKeyStream(encryptedSessionKey, sessionKey)
+	}
+	if err := p.deriveKeys(sessionKey); err != nil {
+		return nil, err
+	}
+
+	domain := encodeUTF16LE(domainName)
+	user := encodeUTF16LE(username)
+
+	// The header ends with the version and the 16-byte MIC
+	const micOffset, headerLen = 72, 88
+	payload := [][]byte{lmResponse, ntResponse, domain, user, nil, encryptedSessionKey}
+	msg := make([]byte, headerLen)
+	copy(msg[0:8], ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:12], 3)
+	offset := headerLen
+	for i, field := range payload {
+		pos := 12 + i*8
+		binary.LittleEndian.PutUint16(msg[pos:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(msg[pos+2:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(msg[pos+4:], uint32(offset))
+		offset += len(field)
+	}
+	binary.LittleEndian.PutUint32(msg[60:64], p.flags)
+	copy(msg[64:72], ntlmVersion)
+	for _, field := range payload {
+		msg = append(msg, field...)
+	}
+	if serverTime {
+		mic := hmacMD5(sessionKey, p.negotiate, challengeMsg, msg)
+		copy(msg[micOffset:headerLen], mic)
+	}
+	return msg, nil
+}
+
+// deriveKeys derives the signing and sealing keys from the exported session key.
+func (p *NTLMProvider) deriveKeys(sessionKey []byte) error {
+	sealKey := sessionKey
+	switch {
+	case p.flags&ntlmFlag128 != 0:
+	case p.flags&ntlmFlag56 != 0:
+		sealKey = sessionKey[:7]
+	default:
+		sealKey = sessionKey[:5]
+	}
+
+	p.clientSigningKey = md5Sum(sessionKey, []byte(clientSigningMagic))
+	p.serverSigningKey = md5Sum(sessionKey, []byte(serverSigningMagic))
+
+	var err error
+	if p.clientSealer, err = rc4.NewCipher(md5Sum(sealKey, []byte(clientSealingMagic))); err != nil {
+		return err
+	}
+	if p.serverSealer, err = rc4.NewCipher(md5Sum(sealKey, []byte(serverSealingMagic))); err != nil {
+		return err
+	}
+	p.clientSeq = 0
+	p.serverSeq = 0
+	return nil
+}
+
+// sign computes an NTLMSSP_MESSAGE_SIGNATURE with extended session security
+// (MS-NLMP 3.4.4.2).
+func (p *NTLMProvider) sign(sealer *rc4.Cipher, signingKey []byte, seq uint32, msg []byte) []byte {
+	seqBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(seqBytes, seq)
+
+	checksum := hmacMD5(signingKey, seqBytes, msg)[:8]
+	if p.flags&ntlmFlagKeyExch != 0 {
+		sealer.XORKeyStream(checksum, checksum)
+	}
+
+	sig := make([]byte, ntlmSignatureSize)
+	binary.LittleEndian.PutUint32(sig[0:4], 1)
+	copy(sig[4:12], checksum)
+	copy(sig[12:16], seqBytes)
+	return sig
+}
+
+// ntowfv2 computes the NTLMv2 response key (MS-NLMP 3.3.2).
+func ntowfv2(username, password, domain string) []byte {
+	h := md4.New()
+	h.Write(encodeUTF16LE(password))
+	return hmacMD5(h.Sum(nil), encodeUTF16LE(strings.ToUpper(username)+domain))
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+func md5Sum(data ...[]byte) []byte {
+	h := md5.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func encodeUTF16LE(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
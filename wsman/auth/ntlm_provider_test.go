@@ -0,0 +1,373 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testNTLMChallenge builds a CHALLENGE_MESSAGE with the given flags and target info.
+func testNTLMChallenge(flags uint32, targetInfo []byte) []byte {
+	msg := make([]byte, 48)
+	copy(msg[0:8], ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:12], 2)
+	binary.LittleEndian.PutUint32(msg[20:24], flags)
+	copy(msg[24:32], []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef})
+	binary.LittleEndian.PutUint16(msg[40:42], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(msg[42:44], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(msg[44:48], 48)
+	return append(msg, targetInfo...)
+}
+
+// testNTLMPair returns a client provider and a mirrored server-side provider
+// sharing the same session key.
+func testNTLMPair(t *testing.T) (client, server *NTLMProvider) {
+	t.Helper()
+	sessionKey := bytes.Repeat([]byte{0x55}, 16)
+
+	client = NewNTLMProvider(Credentials{})
+	client.flags = ntlmClientFlags
+	client.isComplete = true
+	if err := client.deriveKeys(sessionKey); err != nil {
+		t.Fatalf("deriveKeys() error = %v", err)
+	}
+
+	server = NewNTLMProvider(Credentials{})
+	server.flags = ntlmClientFlags
+	server.isComplete = true
+	if err := server.deriveKeys(sessionKey); err != nil {
+		t.Fatalf("deriveKeys() error = %v", err)
+	}
+	server.clientSigningKey, server.serverSigningKey = server.serverSigningKey, server.clientSigningKey
+	server.clientSealer, server.serverSealer = server.serverSealer, server.clientSealer
+	return client, server
+}
+
+func TestNTOWFv2(t *testing.T) {
+	// MS-NLMP 4.2.4.1.1
+	got := hex.EncodeToString(ntowfv2("User", "Password", "Domain"))
+	if want := "0c868a403bfd7a93a3001ef22ef02e3f"; got != want {
+		t.Errorf("ntowfv2() = %s; want %s", got, want)
+	}
+}
+
+// testNTLMSpecProvider returns a provider with the inputs of MS-NLMP 4.2.1:
+// Time 0, client challenge 0xaa * 8 and RandomSessionKey 0x55 * 16.
+func testNTLMSpecProvider(creds Credentials) *NTLMProvider {
+	p := NewNTLMProvider(creds)
+	p.now = func() time.Time { return time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC) }
+	p.random = func(b []byte) error {
+		fill := byte(0xaa)
+		if len(b) == 16 {
+			fill = 0x55
+		}
+		copy(b, bytes.Repeat([]byte{fill}, len(b)))
+		return nil
+	}
+	return p
+}
+
+// ntlmAuthenticateField returns payload field index of an AUTHENTICATE message.
+func ntlmAuthenticateField(msg []byte, index int) []byte {
+	pos := 12 + index*8
+	length := int(binary.LittleEndian.Uint16(msg[pos:]))
+	offset := int(binary.LittleEndian.Uint32(msg[pos+4:]))
+	return msg[offset : offset+length]
+}
+
+func TestNTLMProvider_Step_SpecVector(t *testing.T) {
+	// MS-NLMP 4.2.4: the server sends NbDomainName "Domain" and
+	// NbComputerName "Server", and no timestamp
+	targetInfo := []byte{2, 0, 12, 0}
+	targetInfo = append(targetInfo, encodeUTF16LE("Domain")...)
+	targetInfo = append(targetInfo, 1, 0, 12, 0)
+	targetInfo = append(targetInfo, encodeUTF16LE("Server")...)
+	targetInfo = append(targetInfo, 0, 0, 0, 0)
+
+	for _, creds := range []Credentials{
+		{Username: "User", Password: "Password", Domain: "Domain"},
+		{Username: `Domain\User`, Password: "Password"},
+	} {
+		t.Run(creds.Username, func(t *testing.T) {
+			p := testNTLMSpecProvider(creds)
+			authenticate, _, err := p.Step(context.Background(), testNTLMChallenge(0xe28a8233, targetInfo))
+			if err != nil {
+				t.Fatalf("Step(challenge) error = %v", err)
+			}
+
+			if got, want := hex.EncodeToString(ntlmAuthenticateField(authenticate, 0)),
+				"86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa"; got != want {
+				t.Errorf("LMv2 response = %s; want %s", got, want)
+			}
+			if got, want := hex.EncodeToString(ntlmAuthenticateField(authenticate, 1)[:16]),
+				"68cd0ab851e51c96aabc927bebef6a1c"; got != want {
+				t.Errorf("NTProofStr = %s; want %s", got, want)
+			}
+			// RC4 of the RandomSessionKey with the SessionBaseKey 8de40ccadbc14a82f15cb0ad0de95ca3
+			if got, want := hex.EncodeToString(ntlmAuthenticateField(authenticate, 5)),
+				"c5dad2544fc9799094ce1ce90bc9d03e"; got != want {
+				t.Errorf("EncryptedRandomSessionKey = %s; want %s", got, want)
+			}
+			if got := ntlmAuthenticateField(authenticate, 2); !bytes.Equal(got, encodeUTF16LE("Domain")) {
+				t.Errorf("DomainName = %x; want %x", got, encodeUTF16LE("Domain"))
+			}
+			if got := ntlmAuthenticateField(authenticate, 3); !bytes.Equal(got, encodeUTF16LE("User")) {
+				t.Errorf("UserName = %x; want %x", got, encodeUTF16LE("User"))
+			}
+
+			// MS-NLMP 4.2.4.4 with the keys of the exchange
+			out, err := p.Wrap(encodeUTF16LE("Plaintext"))
+			if err != nil {
+				t.Fatalf("Wrap() error = %v", err)
+			}
+			if got, want := hex.EncodeToString(out[4:]),
+				"010000007fb38ec5c55d49760000000054e50165bf1936dc996020c1811b0f06fb5f"; got != want {
+				t.Errorf("Wrap() = %s; want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestNTLMUserDomain(t *testing.T) {
+	tests := []struct {
+		creds        Credentials
+		user, domain string
+	}{
+		{Credentials{Username: "user", Domain: "CORP"}, "user", "CORP"},
+		{Credentials{Username: `CORP\user`}, "user", "CORP"},
+		{Credentials{Username: `OTHER\user`, Domain: "CORP"}, "user", "CORP"},
+		{Credentials{Username: "user@corp.example.com"}, "user@corp.example.com", ""},
+		{Credentials{Username: "user"}, "user", ""},
+	}
+	for _, tt := range tests {
+		user, domain := ntlmUserDomain(tt.creds)
+		if user != tt.user || domain != tt.domain {
+			t.Errorf("ntlmUserDomain(%q, %q) = %q, %q; want %q, %q",
+				tt.creds.Username, tt.creds.Domain, user, domain, tt.user, tt.domain)
+		}
+	}
+}
+
+func TestNTLMProvider_Step_MIC(t *testing.T) {
+	p := testNTLMSpecProvider(Credentials{Username: "user", Password: "pass", Domain: "DOMAIN"})
+	negotiate, _, err := p.Step(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Step(nil) error = %v", err)
+	}
+	// MsvAvTimestamp and MsvAvFlags with another bit set
+	targetInfo := []byte{7, 0, 8, 0, 1, 2, 3, 4, 5, 6, 7, 8, 6, 0, 4, 0, 1, 0, 0, 0, 0, 0, 0, 0}
+	challenge := testNTLMChallenge(ntlmClientFlags, targetInfo)
+	authenticate, _, err := p.Step(context.Background(), challenge)
+	if err != nil {
+		t.Fatalf("Step(challenge) error = %v", err)
+	}
+
+	nt := ntlmAuthenticateField(authenticate, 1)
+	flags, ok := ntlmAVPair(nt[44:len(nt)-4], avFlags)
+	if !ok || binary.LittleEndian.Uint32(flags) != 0x3 {
+		t.Errorf("MsvAvFlags = %x, %v; want 03000000", flags, ok)
+	}
+
+	// The MIC covers the three messages with its own field zeroed
+	mic := append([]byte{}, authenticate[72:88]...)
+	zeroed := append([]byte{}, authenticate...)
+	copy(zeroed[72:88], make([]byte, 16))
+	want := hmacMD5(bytes.Repeat([]byte{0x55}, 16), negotiate, challenge, zeroed)
+	if !bytes.Equal(mic, want) {
+		t.Errorf("MIC = %x; want %x", mic, want)
+	}
+}
+
+func TestNTLMProvider_Step_NoMICWithoutTimestamp(t *testing.T) {
+	p := testNTLMSpecProvider(Credentials{Username: "user", Password: "pass"})
+	if _, _, err := p.Step(context.Background(), nil); err != nil {
+		t.Fatalf("Step(nil) error = %v", err)
+	}
+	authenticate, _, err := p.Step(context.Background(), testNTLMChallenge(ntlmClientFlags, []byte{0, 0, 0, 0}))
+	if err != nil {
+		t.Fatalf("Step(challenge) error = %v", err)
+	}
+	if mic := authenticate[72:88]; !bytes.Equal(mic, make([]byte, 16)) {
+		t.Errorf("MIC = %x; want zeros when the server sends no timestamp", mic)
+	}
+	nt := ntlmAuthenticateField(authenticate, 1)
+	if _, ok := ntlmAVPair(nt[44:len(nt)-4], avFlags); ok {
+		t.Error("MsvAvFlags sent without a server timestamp")
+	}
+}
+
+func TestNTLMProvider_Wrap_SpecVector(t *testing.T) {
+	// MS-NLMP 4.2.4.4: RandomSessionKey 0x55 * 16, "Plaintext", SeqNum 0
+	client, _ := testNTLMPair(t)
+
+	out, err := client.Wrap(encodeUTF16LE("Plaintext"))
+	if err != nil {
+		t.Fatalf("Wrap() error = %v", err)
+	}
+	if got := binary.LittleEndian.Uint32(out[0:4]); got != 16 {
+		t.Errorf("signature length = %d; want 16", got)
+	}
+	if got, want := hex.EncodeToString(out[4:20]), "010000007fb38ec5c55d497600000000"; got != want {
+		t.Errorf("signature = %s; want %s", got, want)
+	}
+	if got, want := hex.EncodeToString(out[20:]), "54e50165bf1936dc996020c1811b0f06fb5f"; got != want {
+		t.Errorf("sealed = %s; want %s", got, want)
+	}
+}
+
+func TestNTLMProvider_Unwrap(t *testing.T) {
+	client, server := testNTLMPair(t)
+
+	for _, msg := range []string{"first response", "second response"} {
+		sealed, err := server.Wrap([]byte(msg))
+		if err != nil {
+			t.Fatalf("server Wrap() error = %v", err)
+		}
+		plain, err := client.Unwrap(sealed)
+		if err != nil {
+			t.Fatalf("Unwrap() error = %v", err)
+		}
+		if string(plain) != msg {
+			t.Errorf("Unwrap() = %q; want %q", plain, msg)
+		}
+	}
+}
+
+func TestNTLMProvider_Unwrap_Tampered(t *testing.T) {
+	client, server := testNTLMPair(t)
+
+	sealed, err := server.Wrap([]byte("<s:Envelope/>"))
+	if err != nil {
+		t.Fatalf("server Wrap() error = %v", err)
+	}
+	sealed[len(sealed)-1] ^= 0xff
+
+	if _, err := client.Unwrap(sealed); !errors.Is(err, ErrNTLMSignatureMismatch) {
+		t.Errorf("Unwrap() error = %v; want ErrNTLMSignatureMismatch", err)
+	}
+}
+
+func TestNTLMProvider_WrapBeforeComplete(t *testing.T) {
+	p := NewNTLMProvider(Credentials{Username: "user", Password: "pass"})
+	if _, err := p.Wrap([]byte("data")); err == nil {
+		t.Error("Wrap() before authentication should fail")
+	}
+}
+
+func TestNTLMProvider_Step(t *testing.T) {
+	p := NewNTLMProvider(Credentials{Username: "user", Password: "pass", Domain: "DOMAIN"})
+
+	negotiate, continueNeeded, err := p.Step(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Step(nil) error = %v", err)
+	}
+	if !continueNeeded || p.Complete() {
+		t.Errorf("after NEGOTIATE: continueNeeded = %v, Complete() = %v", continueNeeded, p.Complete())
+	}
+	if !bytes.HasPrefix(negotiate, ntlmSignature) || binary.LittleEndian.Uint32(negotiate[8:12]) != 1 {
+		t.Fatalf("Step(nil) did not return a NEGOTIATE message: %x", negotiate)
+	}
+
+	// Target info with MsvAvTimestamp followed by MsvAvEOL
+	targetInfo := []byte{7, 0, 8, 0, 1, 2, 3, 4, 5, 6, 7, 8, 0, 0, 0, 0}
+	authenticate, continueNeeded, err := p.Step(context.Background(), testNTLMChallenge(ntlmClientFlags, targetInfo))
+	if err != nil {
+		t.Fatalf("Step(challenge) error = %v", err)
+	}
+	if continueNeeded || !p.Complete() {
+		t.Errorf("after AUTHENTICATE: continueNeeded = %v, Complete() = %v", continueNeeded, p.Complete())
+	}
+	if binary.LittleEndian.Uint32(authenticate[8:12]) != 3 {
+		t.Fatalf("Step(challenge) did not return an AUTHENTICATE message")
+	}
+
+	field := func(index int) []byte {
+		pos := 12 + index*8
+		length := int(binary.LittleEndian.Uint16(authenticate[pos:]))
+		offset := int(binary.LittleEndian.Uint32(authenticate[pos+4:]))
+		return authenticate[offset : offset+length]
+	}
+	if lm := field(0); !bytes.Equal(lm, make([]byte, 24)) {
+		t.Errorf("LmChallengeResponse = %x; want zeros when server sends a timestamp", lm)
+	}
+	if nt := field(1); !bytes.Contains(nt, targetInfo[:12]) {
+		t.Error("NtChallengeResponse does not contain the server target info")
+	}
+	if user := field(3); !bytes.Equal(user, encodeUTF16LE("user")) {
+		t.Errorf("UserName = %x; want %x", user, encodeUTF16LE("user"))
+	}
+	if key := field(5); len(key) != 16 {
+		t.Errorf("EncryptedRandomSessionKey length = %d; want 16", len(key))
+	}
+
+	if _, err := p.Wrap([]byte("payload")); err != nil {
+		t.Errorf("Wrap() after authentication error = %v", err)
+	}
+}
+
+func TestNTLMProvider_Step_NoSealing(t *testing.T) {
+	p := NewNTLMProvider(Credentials{Username: "user", Password: "pass"})
+	flags := ntlmClientFlags &^ ntlmFlagSeal
+
+	if _, _, err := p.Step(context.Background(), testNTLMChallenge(flags, nil)); err == nil {
+		t.Error("Step() should fail when the server does not support sealing")
+	}
+}
+
+func TestNTLMProvider_Step_ChannelBindings(t *testing.T) {
+	p := NewNTLMProvider(Credentials{Username: "user", Password: "pass", Domain: "DOMAIN"})
+	certHash := bytes.Repeat([]byte{0xab}, 32)
+	ctx := context.WithValue(context.Background(), ContextKeyChannelBindings, certHash)
+
+	if _, _, err := p.Step(ctx, nil); err != nil {
+		t.Fatalf("Step(nil) error = %v", err)
+	}
+	// A stale MsvAvChannelBindings from the server is replaced
+	targetInfo := []byte{
+		7, 0, 8, 0, 1, 2, 3, 4, 5, 6, 7, 8,
+		10, 0, 16, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0,
+	}
+	authenticate, _, err := p.Step(ctx, testNTLMChallenge(ntlmClientFlags, targetInfo))
+	if err != nil {
+		t.Fatalf("Step(challenge) error = %v", err)
+	}
+
+	pos := 12 + 8 // NtChallengeResponseFields
+	length := int(binary.LittleEndian.Uint16(authenticate[pos:]))
+	offset := int(binary.LittleEndian.Uint32(authenticate[pos+4:]))
+	nt := authenticate[offset : offset+length]
+
+	// NTProofStr(16), header(8), timestamp(8), client challenge(8), reserved(4), AV pairs, reserved(4).
+	// The timestamp from the server adds MsvAvFlags for the MIC.
+	pairs := nt[44 : len(nt)-4]
+	want := append([]byte{7, 0, 8, 0, 1, 2, 3, 4, 5, 6, 7, 8, 6, 0, 4, 0, 2, 0, 0, 0, 10, 0, 16, 0}, channelBindingsHash(certHash)...)
+	want = append(want, 0, 0, 0, 0)
+	if !bytes.Equal(pairs, want) {
+		t.Errorf("AV pairs = %x; want %x", pairs, want)
+	}
+}
+
+func TestWithAVPair(t *testing.T) {
+	tests := []struct {
+		name       string
+		targetInfo []byte
+		want       []byte
+	}{
+		{"empty", nil, []byte{10, 0, 2, 0, 0xaa, 0xbb, 0, 0, 0, 0}},
+		{"appended before EOL", []byte{1, 0, 1, 0, 0x11, 0, 0, 0, 0}, []byte{1, 0, 1, 0, 0x11, 10, 0, 2, 0, 0xaa, 0xbb, 0, 0, 0, 0}},
+		{"replaced", []byte{10, 0, 1, 0, 0x11, 0, 0, 0, 0}, []byte{10, 0, 2, 0, 0xaa, 0xbb, 0, 0, 0, 0}},
+		{"truncated", []byte{1, 0, 9, 0, 0x11}, []byte{10, 0, 2, 0, 0xaa, 0xbb, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withAVPair(tt.targetInfo, avChannelBindings, []byte{0xaa, 0xbb}); !bytes.Equal(got, tt.want) {
+				t.Errorf("withAVPair() = %x; want %x", got, tt.want)
+			}
+		})
+	}
+}
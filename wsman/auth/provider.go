@@ -49,3 +49,11 @@ type SecurityProvider interface {
 	// Close releases any resources associated with the context (e.g. handles).
 	Close() error
 }
+
+// CredentialRefresher is implemented by providers and authenticators whose
+// credentials expire, such as Kerberos tickets. RefreshCredentials gets
+// fresh credentials for later handshakes; callers use it before retrying
+// after an authentication failure, e.g. when reconnecting.
+type CredentialRefresher interface {
+	RefreshCredentials() error
+}
@@ -0,0 +1,34 @@
+package auth
+
+import "errors"
+
+// SSPIPackage is a Windows SSPI security package.
+type SSPIPackage string
+
+const (
+	// SSPIPackageNegotiate negotiates Kerberos, falling back to NTLM (SPNEGO).
+	SSPIPackageNegotiate SSPIPackage = "Negotiate"
+
+	// SSPIPackageNTLM uses NTLM only.
+	SSPIPackageNTLM SSPIPackage = "NTLM"
+)
+
+// SSPIProviderConfig holds configuration for NewSSPISecurityProvider.
+type SSPIProviderConfig struct {
+	// Package is the security package; empty means SSPIPackageNegotiate.
+	Package SSPIPackage
+
+	// TargetSPN is the Service Principal Name (e.g., "WSMAN/server.domain.com").
+	TargetSPN string
+
+	// Credentials are explicit credentials. If nil or without a username,
+	// the logged-on user's credentials are used (SSO).
+	Credentials *Credentials
+
+	// Delegate requests credential delegation; only Kerberos can delegate.
+	Delegate bool
+}
+
+// ErrSSPINotSupported is returned by NewSSPISecurityProvider on platforms
+// other than Windows.
+var ErrSSPINotSupported = errors.New("auth: SSPI is only available on Windows")
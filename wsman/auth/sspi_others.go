@@ -0,0 +1,8 @@
+//go:build !windows
+
+package auth
+
+// NewSSPISecurityProvider returns ErrSSPINotSupported: SSPI is Windows only.
+func NewSSPISecurityProvider(cfg SSPIProviderConfig) (SecurityProvider, error) {
+	return nil, ErrSSPINotSupported
+}
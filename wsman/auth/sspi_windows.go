@@ -0,0 +1,21 @@
+//go:build windows
+
+package auth
+
+// NewSSPISecurityProvider creates an SSPI provider for the package in cfg,
+// for use with NegotiateAuth. Without explicit credentials it uses the
+// logged-on user's (SSO).
+func NewSSPISecurityProvider(cfg SSPIProviderConfig) (SecurityProvider, error) {
+	sspiCfg := SSPIConfig{
+		UseDefaultCreds: true,
+		Delegate:        cfg.Delegate,
+		Package:         cfg.Package,
+	}
+	if cfg.Credentials != nil && cfg.Credentials.Username != "" {
+		sspiCfg.UseDefaultCreds = false
+		sspiCfg.Username = cfg.Credentials.Username
+		sspiCfg.Password = cfg.Credentials.Password
+		sspiCfg.Domain = cfg.Credentials.Domain
+	}
+	return NewSSPIProvider(sspiCfg, cfg.TargetSPN)
+}
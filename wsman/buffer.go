@@ -0,0 +1,81 @@
+package wsman
+
+import (
+	"bytes"
+	"encoding/base64"
+	"sync"
+)
+
+// maxPooledBufferSize caps the buffers kept for reuse, so one oversized
+// Send does not pin its memory for the life of the process.
+const maxPooledBufferSize = 4 << 20
+
+// bufferPool holds the buffers that Send bodies are built in. Large
+// transfers send one envelope after another, so reusing the buffers saves
+// allocating (and collecting) several payload-sized strings per call.
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns buf to the pool. Nothing may use its bytes afterwards.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// writeBase64 base64-encodes data straight into buf, without the
+// intermediate string of EncodeToString.
+func writeBase64(buf *bytes.Buffer, data []byte) {
+	n := base64.StdEncoding.EncodedLen(len(data))
+	buf.Grow(n)
+	dst := buf.AvailableBuffer()[:n]
+	base64.StdEncoding.Encode(dst, data)
+	buf.Write(dst)
+}
+
+// decodeStreams base64-decodes the contents of a Receive response's
+// streams into one shared allocation. The result holds a cap-limited slice
+// per stream, nil where the content is not valid base64, so appending to
+// one never overwrites the next.
+func decodeStreams(contents [][]byte) [][]byte {
+	total := 0
+	for i, content := range contents {
+		contents[i] = bytes.TrimSpace(content)
+		total += base64.StdEncoding.DecodedLen(len(contents[i]))
+	}
+
+	arena := make([]byte, total)
+	decoded := make([][]byte, len(contents))
+	for i, content := range contents {
+		n, err := base64.StdEncoding.Decode(arena, content)
+		if err != nil {
+			continue
+		}
+		decoded[i] = arena[:n:n]
+		arena = arena[n:]
+	}
+	return decoded
+}
+
+// appendChunk appends a decoded chunk to dst. The first chunk is used as
+// is rather than copied; its capacity is limited, so a later append moves
+// dst out of the shared allocation.
+func appendChunk(dst, chunk []byte) []byte {
+	if len(chunk) == 0 {
+		return dst
+	}
+	if len(dst) == 0 {
+		return chunk
+	}
+	return append(dst, chunk...)
+}
@@ -0,0 +1,110 @@
+package wsman
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+func TestWriteBase64(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("a"), []byte("ab"), []byte("abc"), bytes.Repeat([]byte{0xff}, 1000)} {
+		var buf bytes.Buffer
+		buf.WriteString("<x>")
+		writeBase64(&buf, data)
+		if want := "<x>" + base64.StdEncoding.EncodeToString(data); buf.String() != want {
+			t.Errorf("writeBase64(%d bytes) = %q, want %q", len(data), buf.String(), want)
+		}
+	}
+}
+
+func TestDecodeStreams(t *testing.T) {
+	decoded := decodeStreams([][]byte{
+		[]byte("Zmlyc3Q="),
+		[]byte("\n  ZXJy\n"),
+		[]byte("not base64!"),
+		nil,
+		[]byte("c2Vjb25k"),
+	})
+
+	want := []string{"first", "err", "", "", "second"}
+	for i, d := range decoded {
+		if string(d) != want[i] {
+			t.Errorf("decoded[%d] = %q, want %q", i, d, want[i])
+		}
+	}
+	if decoded[2] != nil {
+		t.Error("invalid base64 decoded to non-nil")
+	}
+	if decoded[3] == nil {
+		t.Error("empty stream decoded to nil")
+	}
+
+	// Appending to a chunk must not overwrite the next one
+	stdout := appendChunk(nil, decoded[0])
+	stdout = appendChunk(stdout, decoded[4])
+	if string(stdout) != "firstsecond" || string(decoded[1]) != "err" || string(decoded[4]) != "second" {
+		t.Errorf("stdout = %q, chunks = %q", stdout, decoded)
+	}
+}
+
+// benchmarkPayload is about the payload of a full default-size envelope.
+var benchmarkPayload = bytes.Repeat([]byte("PSRP fragment data "), 256<<10/19)
+
+// benchmarkClient returns a client whose transport answers every request
+// with response and discards the request.
+func benchmarkClient(response string) *Client {
+	tr := transport.NewHTTPTransport()
+	tr.Client().Transport = &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			_, _ = io.Copy(io.Discard, req.Body)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(response)),
+			}, nil
+		},
+	}
+	return NewClient("http://server:5985/wsman", tr)
+}
+
+func BenchmarkClient_Send(b *testing.B) {
+	c := benchmarkClient(soapBody(`<rsp:SendResponse xmlns:rsp="` + NsShell + `"/>`))
+	ctx := context.Background()
+	epr := dummyEPR()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkPayload)))
+	for b.Loop() {
+		if err := c.Send(ctx, epr, "cmd-id", StreamStdin, benchmarkPayload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClient_Receive(b *testing.B) {
+	encoded := base64.StdEncoding.EncodeToString(benchmarkPayload)
+	half := len(encoded) / 2 / 4 * 4
+	c := benchmarkClient(soapBody(`<rsp:ReceiveResponse xmlns:rsp="` + NsShell + `">` +
+		`<rsp:Stream Name="stdout" CommandId="cmd-id">` + encoded[:half] + `</rsp:Stream>` +
+		`<rsp:Stream Name="stdout" CommandId="cmd-id">` + encoded[half:] + `</rsp:Stream>` +
+		`<rsp:CommandState CommandId="cmd-id" State="Running"/></rsp:ReceiveResponse>`))
+	ctx := context.Background()
+	epr := dummyEPR()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkPayload)))
+	for b.Loop() {
+		result, err := c.Receive(ctx, epr, "cmd-id")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(result.Stdout) != len(benchmarkPayload) {
+			b.Fatalf("Stdout = %d bytes, want %d", len(result.Stdout), len(benchmarkPayload))
+		}
+	}
+}
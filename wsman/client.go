@@ -5,8 +5,11 @@ import (
 	"encoding/base64"
 	"encoding/xml"
 	"fmt"
-	"os"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/smnsjas/go-psrp/wsman/transport"
@@ -17,6 +20,10 @@ type Client struct {
 	endpoint  string
 	transport *transport.HTTPTransport
 	sessionID string
+
+	optsMu sync.RWMutex
+	opts   ClientOptions // envelope size and timeouts, see SetOptions
+	tel    *telemetry    // from opts; nil when disabled
 }
 
 // NewClient creates a new WSMan client.
@@ -25,6 +32,7 @@ func NewClient(endpoint string, tr *transport.HTTPTransport) *Client {
 		endpoint:  endpoint,
 		transport: tr,
 		sessionID: "uuid:" + strings.ToUpper(uuid.New().String()),
+		opts:      ClientOptions{}.withDefaults(),
 	}
 }
 
@@ -38,6 +46,12 @@ func (c *Client) SetSessionID(sessionID string) {
 	c.sessionID = sessionID
 }
 
+// SessionID returns the WSMan session ID sent with every request. A client
+// that reconnects a disconnected shell should reuse it.
+func (c *Client) SessionID() string {
+	return c.sessionID
+}
+
 // ReceiveResult contains the result of a Receive operation.
 type ReceiveResult struct {
 	Stdout       []byte
@@ -45,6 +59,27 @@ type ReceiveResult struct {
 	CommandState string
 	ExitCode     int
 	Done         bool
+
+	// CommandID is the command CommandState is of. It tells commands apart
+	// in the response to a shell-level Receive (commandID ""), which may
+	// carry the output of several.
+	CommandID string
+
+	// Streams are the stream chunks of the response in the order the
+	// server sent them, with the command each belongs to. Stdout and
+	// Stderr are the concatenated chunks of each stream; with a single
+	// chunk they share its memory.
+	Streams []StreamChunk
+}
+
+// StreamChunk is one Stream element of a Receive response.
+type StreamChunk struct {
+	Name      string
+	CommandID string
+	Data      []byte
+
+	// End is set on the last chunk of the stream.
+	End bool
 }
 
 // Create creates a new shell (RunspacePool) and returns the shell ID.
@@ -72,18 +107,19 @@ func (c *Client) Create(ctx context.Context, options map[string]string, creation
 		WithAction(ActionCreate).
 		WithTo(c.endpoint).
 		WithResourceURI(resourceURI).
-		WithMaxEnvelopeSize(512000).
-		WithOperationTimeout("PT60S").
+		WithMaxEnvelopeSize(c.maxEnvelopeSize()).
+		WithOperationTimeout(c.operationTimeout(ctx)).
 		WithMessageID("uuid:" + strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
 		WithSessionID(c.sessionID).
-		WithLocale("en-US").
-		WithDataLocale("en-US").
+		WithLocale(c.locale()).
+		WithDataLocale(c.dataLocale()).
 		WithShellNamespace()
 
-	// Add shell options
+	// Add shell options, in a stable order
 	idleTimeout := "PT30M" // default
-	for name, value := range options {
+	for _, name := range slices.Sorted(maps.Keys(options)) {
+		value := options[name]
 		if name == "protocolversion" {
 			env.WithOptionMustComply(name, value)
 		} else if name == "IdleTimeout" {
@@ -153,10 +189,10 @@ func (c *Client) Command(ctx context.Context, epr *EndpointReference, commandID,
 		WithMessageID("uuid:" + strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
 		WithSessionID(c.sessionID).
-		WithLocale("en-US").
-		WithDataLocale("en-US").
-		WithMaxEnvelopeSize(512000).
-		WithOperationTimeout("PT60S").
+		WithLocale(c.locale()).
+		WithDataLocale(c.dataLocale()).
+		WithMaxEnvelopeSize(c.maxEnvelopeSize()).
+		WithOperationTimeout(c.operationTimeout(ctx)).
 		WithShellNamespace()
 
 	// Add all selectors
@@ -219,35 +255,39 @@ func (c *Client) Command(ctx context.Context, epr *EndpointReference, commandID,
 
 // Send sends data to a command's input stream.
 func (c *Client) Send(ctx context.Context, epr *EndpointReference, commandID, stream string, data []byte) error {
-	encoded := base64.StdEncoding.EncodeToString(data)
-
 	env := NewEnvelope().
 		WithAction(ActionSend).
 		WithTo(c.endpoint).
 		WithResourceURI(epr.ResourceURI).
 		WithMessageID("uuid:" + strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
-		WithMaxEnvelopeSize(512000).
-		WithOperationTimeout("PT60S").
+		WithMaxEnvelopeSize(c.maxEnvelopeSize()).
+		WithOperationTimeout(c.operationTimeout(ctx)).
 		WithSessionID(c.sessionID).
-		WithLocale("en-US").
-		WithDataLocale("en-US").
+		WithLocale(c.locale()).
+		WithDataLocale(c.dataLocale()).
 		WithShellNamespace()
 
 	for _, s := range epr.Selectors {
 		env.WithSelector(s.Name, s.Value)
 	}
 
-	var streamNode string
+	// The payload is encoded straight into a pooled buffer: the body is
+	// only read while the envelope is marshaled
+	body := getBuffer()
+	defer putBuffer(body)
+	body.WriteString(`<rsp:Send xmlns:rsp="` + NsShell + `">
+  <rsp:Stream Name="`)
+	body.WriteString(stream)
 	if commandID != "" {
-		streamNode = `<rsp:Stream Name="` + stream + `" CommandId="` + commandID + `">` + encoded + `</rsp:Stream>`
-	} else {
-		streamNode = `<rsp:Stream Name="` + stream + `">` + encoded + `</rsp:Stream>`
+		body.WriteString(`" CommandId="`)
+		body.WriteString(commandID)
 	}
-
-	env.WithBody([]byte(`<rsp:Send xmlns:rsp="` + NsShell + `">
-  ` + streamNode + `
-</rsp:Send>`))
+	body.WriteString(`">`)
+	writeBase64(body, data)
+	body.WriteString(`</rsp:Stream>
+</rsp:Send>`)
+	env.WithBody(body.Bytes())
 
 	_, err := c.sendEnvelope(ctx, env)
 	if err != nil {
@@ -265,11 +305,11 @@ func (c *Client) Receive(ctx context.Context, epr *EndpointReference, commandID
 		WithResourceURI(epr.ResourceURI).
 		WithMessageID("uuid:"+strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
-		WithMaxEnvelopeSize(512000).
-		WithOperationTimeout("PT1S").
+		WithMaxEnvelopeSize(c.maxEnvelopeSize()).
+		WithOperationTimeout(c.receiveTimeout(ctx)).
 		WithSessionID(c.sessionID).
-		WithLocale("en-US").
-		WithDataLocale("en-US").
+		WithLocale(c.locale()).
+		WithDataLocale(c.dataLocale()).
 		WithOption("WSMAN_CMDSHELL_OPTION_KEEPALIVE", "True").
 		WithShellNamespace()
 
@@ -280,20 +320,20 @@ func (c *Client) Receive(ctx context.Context, epr *EndpointReference, commandID
 	// Match pypsrp format: CommandId IS required on DesiredStream IF it's a command receive
 	var streamNode string
 	if commandID != "" {
-		streamNode = `<rsp:DesiredStream CommandId="` + commandID + `">stdout</rsp:DesiredStream>`
+		streamNode = `<rsp:DesiredStream CommandId="` + commandID + `">` + StreamStdout + `</rsp:DesiredStream>`
 	} else {
-		streamNode = `<rsp:DesiredStream>stdout</rsp:DesiredStream>`
+		streamNode = `<rsp:DesiredStream>` + StreamStdout + `</rsp:DesiredStream>`
 	}
 
 	body := []byte(`<rsp:Receive xmlns:rsp="` + NsShell + `">
   ` + streamNode + `
 </rsp:Receive>`)
 
-	respBody, err := c.sendEnvelope(ctx, env.WithBody(body))
+	respBody, err := c.sendIdempotent(ctx, env.WithBody(body))
 	if err != nil {
 		// If the operation timed out, it just means no data was available.
 		// We should return an empty result so the caller can poll again.
-		if strings.Contains(err.Error(), "w:TimedOut") || strings.Contains(err.Error(), "OperationTimeout") {
+		if f, ok := AsFault(err); (ok && f.IsTimeout()) || strings.Contains(err.Error(), "OperationTimeout") {
 			return &ReceiveResult{}, nil
 		}
 		return nil, fmt.Errorf("receive: %w", err)
@@ -306,23 +346,35 @@ func (c *Client) Receive(ctx context.Context, epr *EndpointReference, commandID
 
 	result := &ReceiveResult{}
 
-	// Decode streams
-	for _, stream := range resp.Body.ReceiveResponse.Streams {
-		decoded, err := base64.StdEncoding.DecodeString(stream.Content)
-		if err != nil {
+	// Decode streams, keeping their order
+	streams := resp.Body.ReceiveResponse.Streams
+	contents := make([][]byte, len(streams))
+	for i, stream := range streams {
+		contents[i] = stream.Content
+	}
+	for i, decoded := range decodeStreams(contents) {
+		if decoded == nil {
 			continue // Skip invalid base64
 		}
+		stream := streams[i]
+		result.Streams = append(result.Streams, StreamChunk{
+			Name:      stream.Name,
+			CommandID: stream.CommandID,
+			Data:      decoded,
+			End:       stream.End,
+		})
 
 		switch stream.Name {
-		case "stdout":
-			result.Stdout = append(result.Stdout, decoded...)
-		case "stderr":
-			result.Stderr = append(result.Stderr, decoded...)
+		case StreamStdout:
+			result.Stdout = appendChunk(result.Stdout, decoded)
+		case StreamStderr:
+			result.Stderr = appendChunk(result.Stderr, decoded)
 		}
 	}
 
 	// Check command state
 	result.CommandState = resp.Body.ReceiveResponse.CommandState.State
+	result.CommandID = resp.Body.ReceiveResponse.CommandState.CommandID
 	if resp.Body.ReceiveResponse.CommandState.ExitCode != nil {
 		result.ExitCode = *resp.Body.ReceiveResponse.CommandState.ExitCode
 		result.Done = true
@@ -340,8 +392,8 @@ func (c *Client) Signal(ctx context.Context, epr *EndpointReference, commandID,
 		WithMessageID("uuid:" + strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
 		WithSessionID(c.sessionID).
-		WithLocale("en-US").
-		WithDataLocale("en-US").
+		WithLocale(c.locale()).
+		WithDataLocale(c.dataLocale()).
 		WithShellNamespace()
 
 	for _, s := range epr.Selectors {
@@ -352,7 +404,7 @@ func (c *Client) Signal(ctx context.Context, epr *EndpointReference, commandID,
   <rsp:Code>` + code + `</rsp:Code>
 </rsp:Signal>`))
 
-	_, err := c.sendEnvelope(ctx, env)
+	_, err := c.sendIdempotent(ctx, env)
 	if err != nil {
 		return fmt.Errorf("signal: %w", err)
 	}
@@ -374,7 +426,7 @@ func (c *Client) Delete(ctx context.Context, epr *EndpointReference) error {
 		env.WithSelector(s.Name, s.Value)
 	}
 
-	_, err := c.sendEnvelope(ctx, env)
+	_, err := c.sendIdempotent(ctx, env)
 	if err != nil {
 		return fmt.Errorf("delete shell: %w", err)
 	}
@@ -383,23 +435,84 @@ func (c *Client) Delete(ctx context.Context, epr *EndpointReference) error {
 }
 
 // sendEnvelope marshals and sends a SOAP envelope, returning the response body.
+// Request failures are returned as an *ActivityError with the request's activity ID.
 func (c *Client) sendEnvelope(ctx context.Context, env *Envelope) ([]byte, error) {
+	if env.Header.ActivityID == nil {
+		env.WithActivityID(activityID(ctx))
+	}
+	id := env.Header.ActivityID.Value
+
+	opts := c.Options()
+	var action string
+	if env.Header.Action != nil {
+		action = env.Header.Action.Value
+	}
+	ctx, endSpan := c.telemetry().startRequest(ctx, env, action)
+
 	body, err := env.Marshal()
 	if err != nil {
+		endSpan(0, 0, 0, err)
 		return nil, fmt.Errorf("marshal envelope: %w", err)
 	}
-
-	respBody, err := c.transport.Post(ctx, c.endpoint, body)
-	if err != nil {
+	if len(body) > opts.MaxEnvelopeSize {
+		err := &EnvelopeTooLargeError{
+			Action:      action,
+			Size:        len(body),
+			PayloadSize: len(env.Body.Content),
+			Limit:       opts.MaxEnvelopeSize,
+		}
+		endSpan(0, 0, 0, err)
 		return nil, err
 	}
+	if opts.Tracer != nil {
+		opts.Tracer.OnRequest(ctx, &TraceRequest{
+			ActivityID: id,
+			Action:     action,
+			URL:        c.endpoint,
+			Body:       TruncatePayloads(body, opts.TracePayloadLimit),
+		})
+	}
 
-	// Check for SOAP Fault even in successful HTTP responses
-	if err := CheckFault(respBody); err != nil {
-		return nil, fmt.Errorf("wsman: %w", err)
+	start := time.Now()
+	resp, err := c.transport.Exchange(ctx, c.endpoint, body)
+	switch {
+	case err == nil:
+		// Check for SOAP Fault even in successful HTTP responses
+		if fault, parseErr := ParseFault(resp.Body); parseErr != nil {
+			err = fmt.Errorf("wsman: %w", parseErr)
+		} else if fault != nil {
+			fault.StatusCode = resp.StatusCode
+			err = fmt.Errorf("wsman: %w", fault)
+		}
+	case resp != nil:
+		// WinRM sends most faults with HTTP 500: return the fault, which
+		// unwraps to the transport error
+		if fault, _ := ParseFault(resp.Body); fault != nil {
+			fault.StatusCode = resp.StatusCode
+			fault.transportErr = err
+			err = fmt.Errorf("wsman: %w", fault)
+		}
 	}
 
-	return respBody, nil
+	if opts.Tracer != nil {
+		traced := &TraceResponse{ActivityID: id, Action: action, Duration: time.Since(start), Err: err}
+		if resp != nil {
+			traced.StatusCode = resp.StatusCode
+			traced.Header = RedactHeader(resp.Header)
+			traced.Body = TruncatePayloads(resp.Body, opts.TracePayloadLimit)
+		}
+		opts.Tracer.OnResponse(ctx, traced)
+	}
+	if resp != nil {
+		endSpan(len(body), len(resp.Body), resp.StatusCode, err)
+	} else {
+		endSpan(len(body), 0, 0, err)
+	}
+
+	if err != nil {
+		return nil, &ActivityError{ActivityID: id, Err: err}
+	}
+	return resp.Body, nil
 }
 
 // CloseIdleConnections closes any idle connections in the underlying transport.
@@ -463,7 +576,8 @@ type receiveResponse struct {
 			Streams []struct {
 				Name      string `xml:"Name,attr"`
 				CommandID string `xml:"CommandId,attr"`
-				Content   string `xml:",chardata"`
+				End       bool   `xml:"End,attr"`
+				Content   []byte `xml:",chardata"`
 			} `xml:"Stream"`
 			CommandState struct {
 				CommandID string `xml:"CommandId,attr"`
@@ -483,9 +597,39 @@ type connectResponse struct {
 	} `xml:"Body"`
 }
 
+// BufferMode is what a disconnected shell does with pipeline output once
+// its output buffer is full (MS-WSMV OutputBufferingMode).
+type BufferMode string
+
+const (
+	// BufferModeBlock suspends the pipeline until the output is received
+	// after a reconnect, so no output is lost.
+	BufferModeBlock BufferMode = "Block"
+	// BufferModeDrop keeps the pipeline running and discards the oldest
+	// output.
+	BufferModeDrop BufferMode = "Drop"
+)
+
+// DisconnectOptions controls how the server keeps a disconnected shell.
+type DisconnectOptions struct {
+	// IdleTimeout is how long the server keeps the disconnected shell
+	// (ISO8601 duration, e.g. "PT2H"). Empty keeps the shell's timeout.
+	IdleTimeout string
+	// BufferMode is applied to the shell's running pipelines. Empty uses
+	// the server default.
+	BufferMode BufferMode
+}
+
 // Disconnect disconnects the shell on the server without closing it.
 // The shell remains active and can be reconnected to later.
 func (c *Client) Disconnect(ctx context.Context, epr *EndpointReference) error {
+	return c.DisconnectWithOptions(ctx, epr, DisconnectOptions{})
+}
+
+// DisconnectWithOptions disconnects the shell on the server without closing
+// it. Running pipelines keep running and buffer their output as set by
+// opts.BufferMode until the shell is reconnected.
+func (c *Client) DisconnectWithOptions(ctx context.Context, epr *EndpointReference, opts DisconnectOptions) error {
 	env := NewEnvelope().
 		WithAction(ActionDisconnect).
 		WithTo(c.endpoint).
@@ -499,14 +643,16 @@ func (c *Client) Disconnect(ctx context.Context, epr *EndpointReference) error {
 		env.WithSelector(s.Name, s.Value)
 	}
 
-	// Payload is empty for Disconnect
-	// But we need a Body with Disconnect element
-	// <w:Disconnect />
+	// <rsp:Disconnect> with optional BufferMode and IdleTimeOut
 	body := struct {
-		XMLName xml.Name `xml:"rsp:Disconnect"`
-		Rsp     string   `xml:"xmlns:rsp,attr"`
+		XMLName     xml.Name `xml:"rsp:Disconnect"`
+		Rsp         string   `xml:"xmlns:rsp,attr"`
+		BufferMode  string   `xml:"rsp:BufferMode,omitempty"`
+		IdleTimeOut string   `xml:"rsp:IdleTimeOut,omitempty"`
 	}{
-		Rsp: NsShell,
+		Rsp:         NsShell,
+		BufferMode:  string(opts.BufferMode),
+		IdleTimeOut: opts.IdleTimeout,
 	}
 	bodyBytes, err := xml.Marshal(body)
 	if err != nil {
@@ -514,25 +660,24 @@ func (c *Client) Disconnect(ctx context.Context, epr *EndpointReference) error {
 	}
 	env.WithBody(bodyBytes)
 
-	respBody, err := c.sendEnvelope(ctx, env)
-	if err != nil {
+	// Response should be just Empty or DisconnectResponse; we only care about faults
+	if _, err := c.sendEnvelope(ctx, env); err != nil {
 		return fmt.Errorf("disconnect: %w", err)
 	}
-
-	// Response should be just Empty or DisconnectResponse
-	fmt.Fprintf(os.Stderr, "DEBUG: Disconnect Response: %s\n", string(respBody))
-
-	if len(respBody) == 0 {
-		return nil
-	}
-	// We could parse it, but we mainly care strictly about error (fault).
 	return nil
 }
 
-// Reconnect reconnects to a disconnected shell.
+// Reconnect reconnects to a disconnected shell of the default
+// Microsoft.PowerShell configuration.
 func (c *Client) Reconnect(ctx context.Context, shellID string) error {
+	return c.ReconnectWithResourceURI(ctx, ResourceURIPowerShell, shellID)
+}
+
+// ReconnectWithResourceURI reconnects to a disconnected shell of the
+// session configuration with the given resource URI, e.g. a JEA endpoint.
+func (c *Client) ReconnectWithResourceURI(ctx context.Context, resourceURI, shellID string) error {
 	// Reconnect is special: We only have the ShellID from the user.
-	// We must assume standard ResourceURI and just ShellID selector.
+	// We use just the ShellID selector.
 	// NOTE: This assumes Reconnect doesn't require extra selectors the server generated during Create.
 	// If it DOES, then the user must provide ALL selectors, which is hard.
 	// For now, valid assumption is ShellID is unique enough for Reconnect.
@@ -540,7 +685,7 @@ func (c *Client) Reconnect(ctx context.Context, shellID string) error {
 	env := NewEnvelope().
 		WithAction(ActionReconnect).
 		WithTo(c.endpoint).
-		WithResourceURI(ResourceURIPowerShell).
+		WithResourceURI(resourceURI).
 		WithMessageID("uuid:"+strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
 		WithSessionID(c.sessionID).
@@ -574,18 +719,24 @@ func (c *Client) Reconnect(ctx context.Context, shellID string) error {
 // (SessionCapability + ConnectRunspacePool messages).
 // Returns the base64-decoded response data from the server.
 func (c *Client) Connect(ctx context.Context, shellID string, connectXML string) ([]byte, error) {
+	return c.ConnectWithResourceURI(ctx, ResourceURIPowerShell, shellID, connectXML)
+}
+
+// ConnectWithResourceURI is Connect for a shell of the session
+// configuration with the given resource URI, e.g. a JEA endpoint.
+func (c *Client) ConnectWithResourceURI(ctx context.Context, resourceURI, shellID string, connectXML string) ([]byte, error) {
 	env := NewEnvelope().
 		WithAction(ActionConnect).
 		WithTo(c.endpoint).
-		WithResourceURI(ResourceURIPowerShell).
+		WithResourceURI(resourceURI).
 		WithMessageID("uuid:"+strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
 		WithSessionID(c.sessionID).
 		WithShellNamespace().
-		WithMaxEnvelopeSize(512000).
-		WithOperationTimeout("PT60S").
-		WithLocale("en-US").
-		WithDataLocale("en-US").
+		WithMaxEnvelopeSize(c.maxEnvelopeSize()).
+		WithOperationTimeout(c.operationTimeout(ctx)).
+		WithLocale(c.locale()).
+		WithDataLocale(c.dataLocale()).
 		WithSelector("ShellId", shellID)
 
 	// Build body with connectXml containing PSRP handshake data
@@ -619,12 +770,54 @@ func (c *Client) Connect(ctx context.Context, shellID string, connectXML string)
 	return respBody, nil
 }
 
+// ConnectCommand reattaches to a running command of a shell that was
+// reconnected or connected by this client, so its buffered output can be
+// received. The server must have the command in the shell's command list.
+func (c *Client) ConnectCommand(ctx context.Context, epr *EndpointReference, commandID string) error {
+	env := NewEnvelope().
+		WithAction(ActionConnect).
+		WithTo(c.endpoint).
+		WithResourceURI(epr.ResourceURI).
+		WithMessageID("uuid:" + strings.ToUpper(uuid.New().String())).
+		WithReplyTo(AddressAnonymous).
+		WithSessionID(c.sessionID).
+		WithShellNamespace().
+		WithOperationTimeout(c.operationTimeout(ctx))
+
+	for _, s := range epr.Selectors {
+		env.WithSelector(s.Name, s.Value)
+	}
+
+	body := struct {
+		XMLName   xml.Name `xml:"rsp:Connect"`
+		Rsp       string   `xml:"xmlns:rsp,attr"`
+		CommandID string   `xml:"CommandId,attr"`
+	}{
+		Rsp:       NsShell,
+		CommandID: strings.ToUpper(commandID),
+	}
+	bodyBytes, err := xml.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal connect body: %w", err)
+	}
+	env.WithBody(bodyBytes)
+
+	if _, err := c.sendEnvelope(ctx, env); err != nil {
+		return fmt.Errorf("connect command: %w", err)
+	}
+	return nil
+}
+
 // EnumerateShell represents a shell discovered via WSMan Enumerate.
 type EnumerateShell struct {
 	ShellID string
 	Name    string
 	State   string
 	Owner   string
+
+	// ResourceURI identifies the session configuration of the shell,
+	// e.g. "http://schemas.microsoft.com/powershell/Microsoft.PowerShell".
+	ResourceURI string
 }
 
 // enumerateResponse is for parsing WSMan Enumerate response.
@@ -634,10 +827,11 @@ type enumerateResponse struct {
 		EnumerateResponse struct {
 			Items struct {
 				Shells []struct {
-					ShellID string `xml:"ShellId"`
-					Name    string `xml:"Name"`
-					State   string `xml:"State"`
-					Owner   string `xml:"Owner"`
+					ShellID     string `xml:"ShellId"`
+					Name        string `xml:"Name"`
+					State       string `xml:"State"`
+					Owner       string `xml:"Owner"`
+					ResourceURI string `xml:"ResourceUri"`
 				} `xml:"Shell"`
 			} `xml:"Items"`
 		} `xml:"EnumerateResponse"`
@@ -654,8 +848,8 @@ func (c *Client) Enumerate(ctx context.Context) ([]EnumerateShell, error) {
 		WithMessageID("uuid:" + strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
 		WithSessionID(c.sessionID).
-		WithMaxEnvelopeSize(512000).
-		WithOperationTimeout("PT60S")
+		WithMaxEnvelopeSize(c.maxEnvelopeSize()).
+		WithOperationTimeout(c.operationTimeout(ctx))
 
 	// Body with OptimizeEnumeration and MaxElements
 	body := fmt.Sprintf(`<wsen:Enumerate xmlns:wsen="%s" xmlns:wsman="%s">
@@ -672,10 +866,7 @@ func (c *Client) Enumerate(ctx context.Context) ([]EnumerateShell, error) {
 	// Parse response
 	var resp enumerateResponse
 	if err := xml.Unmarshal(respBody, &resp); err != nil {
-		// Return empty list on parse error, log for debugging
-		if os.Getenv("PSRP_DEBUG") != "" {
-			fmt.Fprintf(os.Stderr, "DEBUG: Enumerate response parse error: %v\nResponse: %s\n", err, string(respBody))
-		}
+		// Return empty list on parse error; a Tracer sees the response
 		return nil, nil
 	}
 
@@ -683,10 +874,11 @@ func (c *Client) Enumerate(ctx context.Context) ([]EnumerateShell, error) {
 	var shells []EnumerateShell
 	for _, s := range resp.Body.EnumerateResponse.Items.Shells {
 		shells = append(shells, EnumerateShell{
-			ShellID: s.ShellID,
-			Name:    s.Name,
-			State:   s.State,
-			Owner:   s.Owner,
+			ShellID:     s.ShellID,
+			Name:        s.Name,
+			State:       s.State,
+			Owner:       s.Owner,
+			ResourceURI: s.ResourceURI,
 		})
 	}
 
@@ -703,8 +895,8 @@ func (c *Client) EnumerateCommands(ctx context.Context, shellID string) ([]strin
 		WithMessageID("uuid:" + strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
 		WithSessionID(c.sessionID).
-		WithMaxEnvelopeSize(512000).
-		WithOperationTimeout("PT60S")
+		WithMaxEnvelopeSize(c.maxEnvelopeSize()).
+		WithOperationTimeout(c.operationTimeout(ctx))
 
 	// Body with filter by ShellId
 	body := fmt.Sprintf(`<wsen:Enumerate xmlns:wsen="%s" xmlns:wsman="%s">
@@ -739,9 +931,7 @@ func (c *Client) EnumerateCommands(ctx context.Context, shellID string) ([]strin
 
 	var resp commandEnumerateResp
 	if err := xml.Unmarshal(respBody, &resp); err != nil {
-		if os.Getenv("PSRP_DEBUG") != "" {
-			fmt.Fprintf(os.Stderr, "DEBUG: EnumerateCommands response parse error: %v\nResponse: %s\n", err, string(respBody))
-		}
+		// Return empty list on parse error; a Tracer sees the response
 		return nil, nil
 	}
 
@@ -753,8 +943,33 @@ func (c *Client) EnumerateCommands(ctx context.Context, shellID string) ([]strin
 	return commandIDs, nil
 }
 
-// Subscribe subscribes to an event source.
+// SubscribeOptions configures SubscribeWithOptions.
+type SubscribeOptions struct {
+	// Filter selects the events: a WQL query, or a <QueryList> for
+	// DialectEventQuery (see EventLogQuery).
+	Filter string
+
+	// Dialect is the filter dialect. If empty, DialectWQL is used.
+	Dialect string
+
+	// Expires is the subscription lifetime as an ISO 8601 duration.
+	// If empty, PT10M is used. Extend it with Renew.
+	Expires string
+
+	// ReadExistingEvents delivers the events already in an event log
+	// channel, not just new ones.
+	ReadExistingEvents bool
+}
+
+// Subscribe subscribes to an event source with a WQL filter.
 func (c *Client) Subscribe(ctx context.Context, resourceURI string, filter string) (*Subscription, error) {
+	return c.SubscribeWithOptions(ctx, resourceURI, SubscribeOptions{Filter: filter})
+}
+
+// SubscribeWithOptions subscribes to an event source in pull mode
+// (WS-Eventing Subscribe). Retrieve events with Pull, extend the
+// subscription with Renew, and end it with Unsubscribe.
+func (c *Client) SubscribeWithOptions(ctx context.Context, resourceURI string, opts SubscribeOptions) (*Subscription, error) {
 	env := NewEnvelope().
 		WithAction(ActionSubscribe).
 		WithTo(c.endpoint).
@@ -762,9 +977,26 @@ func (c *Client) Subscribe(ctx context.Context, resourceURI string, filter strin
 		WithMessageID("uuid:"+strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
 		WithSessionID(c.sessionID).
-		WithMaxEnvelopeSize(512000).
-		WithOperationTimeout("PT60S").
+		WithMaxEnvelopeSize(c.maxEnvelopeSize()).
+		WithOperationTimeout(c.operationTimeout(ctx)).
 		WithOption("WSMAN_CMDSHELL_OPTION_KEEPALIVE", "True")
+	if opts.ReadExistingEvents {
+		env.WithOption("ReadExistingEvents", "true")
+	}
+
+	expires := opts.Expires
+	if expires == "" {
+		expires = "PT10M"
+	}
+	filter := Filter{Dialect: opts.Dialect}
+	if filter.Dialect == "" {
+		filter.Dialect = DialectWQL
+	}
+	if filter.Dialect == DialectEventQuery {
+		filter.XML = opts.Filter
+	} else {
+		filter.Query = opts.Filter
+	}
 
 	// Subscribe Body
 	body := Subscribe{
@@ -772,11 +1004,8 @@ func (c *Client) Subscribe(ctx context.Context, resourceURI string, filter strin
 		Delivery: Delivery{
 			Mode: DeliveryModePull,
 		},
-		Expires: "PT10M", // Default expiration, maybe make configurable later
-		Filter: Filter{
-			Dialect: "http://schemas.microsoft.com/wbem/wsman/1/WQL",
-			Query:   filter,
-		},
+		Expires: expires,
+		Filter:  filter,
 	}
 
 	bodyBytes, err := xml.Marshal(body)
@@ -802,7 +1031,6 @@ func (c *Client) Subscribe(ctx context.Context, resourceURI string, filter strin
 	}
 
 	sub := &Subscription{
-		// Extract SubscriptionID if possible, usually in ReferenceParameters of Manager
 		EnumerationContext: resp.Body.SubscribeResponse.EnumerationContext,
 		Expires:            resp.Body.SubscribeResponse.Expires,
 		Manager:            &resp.Body.SubscribeResponse.SubscriptionManager,
@@ -815,31 +1043,95 @@ func (c *Client) Subscribe(ctx context.Context, resourceURI string, filter strin
 			break
 		}
 	}
-	// Fallback: Check ReferenceParameters specific field if not in Selectors (some implementations vary)
+	// Fallback: the WS-Eventing Identifier reference parameter
+	if sub.SubscriptionID == "" {
+		sub.SubscriptionID = sub.Manager.Identifier
+	}
 
 	return sub, nil
 }
 
-// Unsubscribe cancels a subscription.
-func (c *Client) Unsubscribe(ctx context.Context, sub *Subscription) error {
+// newManagerEnvelope creates an envelope addressed to a subscription's
+// manager, carrying its selectors and Identifier.
+func (c *Client) newManagerEnvelope(ctx context.Context, action string, sub *Subscription) (*Envelope, error) {
 	if sub.Manager == nil {
-		return fmt.Errorf("missing subscription manager endpoint")
+		return nil, fmt.Errorf("missing subscription manager endpoint")
 	}
 
 	env := NewEnvelope().
-		WithAction(ActionUnsubscribe).
+		WithAction(action).
 		WithTo(sub.Manager.Address). // Send to Manager Address
 		WithResourceURI(sub.Manager.ResourceURI).
 		WithMessageID("uuid:" + strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
 		WithSessionID(c.sessionID).
-		WithMaxEnvelopeSize(512000).
-		WithOperationTimeout("PT60S")
+		WithMaxEnvelopeSize(c.maxEnvelopeSize()).
+		WithOperationTimeout(c.operationTimeout(ctx))
 
 	// Add Selectors from Manager
 	for _, s := range sub.Manager.Selectors {
 		env.WithSelector(s.Name, s.Value)
 	}
+	if sub.Manager.Identifier != "" {
+		env.WithIdentifier(sub.Manager.Identifier)
+	}
+	return env, nil
+}
+
+// Renew extends a subscription by expires, an ISO 8601 duration such as
+// "PT10M" (WS-Eventing Renew). sub.Expires is updated to the expiration
+// granted by the server.
+func (c *Client) Renew(ctx context.Context, sub *Subscription, expires string) error {
+	env, err := c.newManagerEnvelope(ctx, ActionRenew, sub)
+	if err != nil {
+		return err
+	}
+
+	bodyBytes, err := xml.Marshal(Renew{Wse: NsEventing, Expires: expires})
+	if err != nil {
+		return fmt.Errorf("marshal renew body: %w", err)
+	}
+	env.WithBody(bodyBytes)
+
+	respBody, err := c.sendEnvelope(ctx, env)
+	if err != nil {
+		return fmt.Errorf("renew: %w", err)
+	}
+
+	var resp struct {
+		XMLName xml.Name `xml:"Envelope"`
+		Body    struct {
+			RenewResponse RenewResponse `xml:"RenewResponse"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("parse renew response: %w", err)
+	}
+	if resp.Body.RenewResponse.Expires != "" {
+		sub.Expires = resp.Body.RenewResponse.Expires
+	} else {
+		sub.Expires = expires
+	}
+	return nil
+}
+
+// EventLogQuery returns a DialectEventQuery filter that selects the events
+// of an event log channel, e.g. "Security", matching xpath, e.g.
+// "*[System[(EventID=4625)]]". An empty xpath selects all events.
+func EventLogQuery(channel, xpath string) string {
+	if xpath == "" {
+		xpath = "*"
+	}
+	return fmt.Sprintf(`<QueryList><Query Id="0"><Select Path="%s">%s</Select></Query></QueryList>`,
+		xmlEscape(channel), xmlEscape(xpath))
+}
+
+// Unsubscribe cancels a subscription.
+func (c *Client) Unsubscribe(ctx context.Context, sub *Subscription) error {
+	env, err := c.newManagerEnvelope(ctx, ActionUnsubscribe, sub)
+	if err != nil {
+		return err
+	}
 
 	// Unsubscribe Body
 	body := Unsubscribe{
@@ -873,8 +1165,8 @@ func (c *Client) Pull(ctx context.Context, resourceURI string, enumContext strin
 		WithMessageID("uuid:" + strings.ToUpper(uuid.New().String())).
 		WithReplyTo(AddressAnonymous).
 		WithSessionID(c.sessionID).
-		WithMaxEnvelopeSize(512000).
-		WithOperationTimeout("PT20S")
+		WithMaxEnvelopeSize(c.maxEnvelopeSize()).
+		WithOperationTimeout(c.operationTimeout(ctx))
 
 	// Pull Body
 	body := Pull{
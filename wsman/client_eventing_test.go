@@ -166,3 +166,75 @@ func TestClient_Unsubscribe(t *testing.T) {
 		t.Fatalf("Unsubscribe failed: %v", err)
 	}
 }
+
+func TestClient_SubscribeWithOptions_EventLog(t *testing.T) {
+	var request string
+	c := newMockClient(func(body string) string {
+		request = body
+		return soapBody(`<wse:SubscribeResponse xmlns:wse="` + NsEventing + `" xmlns:a="` + NsAddressing + `">` +
+			`<wse:SubscriptionManager><a:Address>http://server:5985/wsman</a:Address><a:ReferenceParameters>` +
+			`<wse:Identifier>uuid:AB-12</wse:Identifier></a:ReferenceParameters></wse:SubscriptionManager>` +
+			`<wse:EnumerationContext>ctx-1</wse:EnumerationContext><wse:Expires>PT30M</wse:Expires></wse:SubscribeResponse>`)
+	})
+
+	sub, err := c.SubscribeWithOptions(context.Background(), ResourceURIEventLog, SubscribeOptions{
+		Filter:             EventLogQuery("Security", "*[System[(EventID=4625)]]"),
+		Dialect:            DialectEventQuery,
+		Expires:            "PT30M",
+		ReadExistingEvents: true,
+	})
+	if err != nil {
+		t.Fatalf("SubscribeWithOptions() error = %v", err)
+	}
+	for _, want := range []string{
+		`Dialect="` + DialectEventQuery + `"><QueryList><Query Id="0"><Select Path="Security">*[System[(EventID=4625)]]</Select></Query></QueryList>`,
+		`Name="ReadExistingEvents">true<`,
+		`<wse:Expires>PT30M</wse:Expires>`,
+	} {
+		if !strings.Contains(request, want) {
+			t.Errorf("Subscribe request missing %s:\n%s", want, request)
+		}
+	}
+	if sub.SubscriptionID != "uuid:AB-12" || sub.Manager.Identifier != "uuid:AB-12" || sub.Expires != "PT30M" {
+		t.Errorf("subscription = %+v, manager = %+v", sub, sub.Manager)
+	}
+}
+
+func TestClient_Renew(t *testing.T) {
+	var request string
+	c := newMockClient(func(body string) string {
+		request = body
+		return soapBody(`<wse:RenewResponse xmlns:wse="` + NsEventing + `"><wse:Expires>PT15M</wse:Expires></wse:RenewResponse>`)
+	})
+
+	sub := &Subscription{Expires: "PT10M", Manager: &EndpointReference{
+		Address:    "http://server:5985/wsman",
+		Identifier: "uuid:AB-12",
+	}}
+	if err := c.Renew(context.Background(), sub, "PT15M"); err != nil {
+		t.Fatalf("Renew() error = %v", err)
+	}
+	for _, want := range []string{ActionRenew, `<wse:Identifier xmlns:wse="` + NsEventing + `">uuid:AB-12</wse:Identifier>`, `<wse:Expires>PT15M</wse:Expires>`} {
+		if !strings.Contains(request, want) {
+			t.Errorf("Renew request missing %s:\n%s", want, request)
+		}
+	}
+	if sub.Expires != "PT15M" {
+		t.Errorf("Expires = %q, want PT15M", sub.Expires)
+	}
+
+	if err := c.Renew(context.Background(), &Subscription{}, "PT1M"); err == nil {
+		t.Error("Renew() without a manager error = nil")
+	}
+}
+
+func TestEventLogQuery(t *testing.T) {
+	got := EventLogQuery("Microsoft-Windows-PowerShell/Operational", "")
+	want := `<QueryList><Query Id="0"><Select Path="Microsoft-Windows-PowerShell/Operational">*</Select></Query></QueryList>`
+	if got != want {
+		t.Errorf("EventLogQuery() = %q, want %q", got, want)
+	}
+	if got := EventLogQuery(`a"b`, "x<y"); !strings.Contains(got, `Path="a&#34;b">x&lt;y<`) {
+		t.Errorf("EventLogQuery() does not escape: %q", got)
+	}
+}
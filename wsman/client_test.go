@@ -3,6 +3,8 @@ package wsman
 import (
 	"context"
 	"encoding/xml"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -162,6 +164,38 @@ func TestClient_Send(t *testing.T) {
 	}
 }
 
+func TestClient_Send_EnvelopeTooLarge(t *testing.T) {
+	c, bodies := capturingClient(ClientOptions{MaxEnvelopeSize: 4096})
+
+	err := c.Send(context.Background(), dummyEPR(), "command-id", "stdin", make([]byte, 8192))
+	if !errors.Is(err, ErrEnvelopeTooLarge) {
+		t.Fatalf("Send() error = %v, want ErrEnvelopeTooLarge", err)
+	}
+	if len(*bodies) != 0 {
+		t.Error("oversized envelope was sent")
+	}
+
+	var tooLarge *EnvelopeTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Send() error = %T, want *EnvelopeTooLargeError", err)
+	}
+	if tooLarge.Action != ActionSend || tooLarge.Limit != 4096 {
+		t.Errorf("error = %+v, want the Send action and limit 4096", tooLarge)
+	}
+	// 8192 bytes are 10924 in base64
+	if tooLarge.PayloadSize <= 10924 || tooLarge.Size <= tooLarge.PayloadSize {
+		t.Errorf("Size = %d, PayloadSize = %d", tooLarge.Size, tooLarge.PayloadSize)
+	}
+	if got, want := tooLarge.MaxPayload(), 4096-(tooLarge.Size-tooLarge.PayloadSize); got != want {
+		t.Errorf("MaxPayload() = %d, want %d", got, want)
+	}
+
+	// A small payload still fits
+	if err := c.Send(context.Background(), dummyEPR(), "command-id", "stdin", []byte("data")); err != nil {
+		t.Errorf("Send() of a small payload error = %v", err)
+	}
+}
+
 // TestClient_Receive verifies the Receive operation.
 func TestClient_Receive(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
@@ -195,6 +229,77 @@ func TestClient_Receive(t *testing.T) {
 	}
 }
 
+func TestClient_Receive_Streams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		response := `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"
+            xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">
+  <s:Body>
+    <rsp:ReceiveResponse>
+      <rsp:Stream Name="stdout" CommandId="cmd-id">Zmlyc3Q=</rsp:Stream>
+      <rsp:Stream Name="stderr" CommandId="cmd-id">
+        ZXJy
+      </rsp:Stream>
+      <rsp:Stream Name="stdout" CommandId="cmd-id" End="true">c2Vjb25k</rsp:Stream>
+      <rsp:CommandState CommandId="cmd-id" State="http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done">
+        <rsp:ExitCode>0</rsp:ExitCode>
+      </rsp:CommandState>
+    </rsp:ReceiveResponse>
+  </s:Body>
+</s:Envelope>`
+		w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, transport.NewHTTPTransport())
+	result, err := client.Receive(context.Background(), dummyEPR(), "cmd-id")
+	if err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+
+	if string(result.Stdout) != "firstsecond" || string(result.Stderr) != "err" {
+		t.Errorf("Stdout = %q, Stderr = %q", result.Stdout, result.Stderr)
+	}
+	want := []StreamChunk{
+		{Name: StreamStdout, CommandID: "cmd-id", Data: []byte("first")},
+		{Name: StreamStderr, CommandID: "cmd-id", Data: []byte("err")},
+		{Name: StreamStdout, CommandID: "cmd-id", Data: []byte("second"), End: true},
+	}
+	if len(result.Streams) != len(want) {
+		t.Fatalf("Streams = %+v, want %d chunks", result.Streams, len(want))
+	}
+	for i, chunk := range result.Streams {
+		if chunk.Name != want[i].Name || chunk.CommandID != want[i].CommandID || string(chunk.Data) != string(want[i].Data) || chunk.End != want[i].End {
+			t.Errorf("Streams[%d] = %+v, want %+v", i, chunk, want[i])
+		}
+	}
+	if !result.Done {
+		t.Error("Done = false with an exit code")
+	}
+	if result.CommandID != "cmd-id" {
+		t.Errorf("CommandID = %q, want cmd-id", result.CommandID)
+	}
+}
+
+func TestClient_Send_PriorityStream(t *testing.T) {
+	var receivedBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receivedBody = string(body)
+		w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+		_, _ = w.Write([]byte(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body/></s:Envelope>`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, transport.NewHTTPTransport())
+	if err := client.Send(context.Background(), dummyEPR(), "cmd-id", StreamPR, []byte("reply")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if !strings.Contains(receivedBody, `<rsp:Stream Name="pr" CommandId="cmd-id">cmVwbHk=</rsp:Stream>`) {
+		t.Errorf("request missing pr stream: %s", receivedBody)
+	}
+}
+
 // TestClient_Signal verifies the Signal operation.
 func TestClient_Signal(t *testing.T) {
 	var receivedBody string
@@ -263,5 +368,211 @@ func TestClient_Delete(t *testing.T) {
 	}
 }
 
+func TestClient_DisconnectWithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    DisconnectOptions
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "defaults",
+			notWant: []string{"BufferMode", "IdleTimeOut"},
+		},
+		{
+			name: "block with timeout",
+			opts: DisconnectOptions{IdleTimeout: "PT2H", BufferMode: BufferModeBlock},
+			want: []string{"<rsp:BufferMode>Block</rsp:BufferMode>", "<rsp:IdleTimeOut>PT2H</rsp:IdleTimeOut>"},
+		},
+		{
+			name:    "drop",
+			opts:    DisconnectOptions{BufferMode: BufferModeDrop},
+			want:    []string{"<rsp:BufferMode>Drop</rsp:BufferMode>"},
+			notWant: []string{"IdleTimeOut"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var receivedBody string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body := make([]byte, r.ContentLength)
+				_, _ = r.Body.Read(body)
+				receivedBody = string(body)
+				w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body/></s:Envelope>`))
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL, transport.NewHTTPTransport())
+			if err := client.DisconnectWithOptions(context.Background(), dummyEPR(), tt.opts); err != nil {
+				t.Fatalf("DisconnectWithOptions failed: %v", err)
+			}
+
+			if !strings.Contains(receivedBody, ActionDisconnect) {
+				t.Errorf("request missing Disconnect action")
+			}
+			if !strings.Contains(receivedBody, client.SessionID()) {
+				t.Errorf("request missing session ID %s", client.SessionID())
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(receivedBody, w) {
+					t.Errorf("request missing %s", w)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(receivedBody, w) {
+					t.Errorf("request should not contain %s", w)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_ConnectCommand(t *testing.T) {
+	var receivedBody string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, r.ContentLength)
+		_, _ = r.Body.Read(body)
+		receivedBody = string(body)
+		w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body/></s:Envelope>`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, transport.NewHTTPTransport())
+	client.SetSessionID("uuid:11111111-2222-3333-4444-555555555555")
+
+	err := client.ConnectCommand(context.Background(), dummyEPR(), "7c9e6679-7425-40de-944b-e07fc1f90ae7")
+	if err != nil {
+		t.Fatalf("ConnectCommand failed: %v", err)
+	}
+
+	if !strings.Contains(receivedBody, ActionConnect) {
+		t.Errorf("request missing Connect action")
+	}
+	if !strings.Contains(receivedBody, `CommandId="7C9E6679-7425-40DE-944B-E07FC1F90AE7"`) {
+		t.Errorf("request missing CommandId: %s", receivedBody)
+	}
+	if !strings.Contains(receivedBody, "uuid:11111111-2222-3333-4444-555555555555") {
+		t.Errorf("request missing restored session ID")
+	}
+	if !strings.Contains(receivedBody, "test-shell-id") {
+		t.Errorf("request missing ShellId selector")
+	}
+}
+
+func TestClient_ReconnectWithResourceURI(t *testing.T) {
+	var receivedBody string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receivedBody = string(body)
+		w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+		_, _ = w.Write([]byte(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body/></s:Envelope>`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, transport.NewHTTPTransport())
+	const jea = "http://schemas.microsoft.com/powershell/JEAMaintenance"
+
+	if err := client.ReconnectWithResourceURI(context.Background(), jea, "test-shell-id"); err != nil {
+		t.Fatalf("ReconnectWithResourceURI failed: %v", err)
+	}
+	if !strings.Contains(receivedBody, ">"+jea+"</") {
+		t.Errorf("request missing configuration resource URI: %s", receivedBody)
+	}
+
+	if _, err := client.ConnectWithResourceURI(context.Background(), jea, "test-shell-id", ""); err != nil {
+		t.Fatalf("ConnectWithResourceURI failed: %v", err)
+	}
+	if !strings.Contains(receivedBody, ">"+jea+"</") {
+		t.Errorf("request missing configuration resource URI: %s", receivedBody)
+	}
+
+	if err := client.Reconnect(context.Background(), "test-shell-id"); err != nil {
+		t.Fatalf("Reconnect failed: %v", err)
+	}
+	if !strings.Contains(receivedBody, ">"+ResourceURIPowerShell+"</") {
+		t.Errorf("Reconnect missing default resource URI: %s", receivedBody)
+	}
+}
+
+func TestClient_Enumerate_ResourceURI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+		_, _ = w.Write([]byte(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body>
+  <n:EnumerateResponse xmlns:n="http://schemas.xmlsoap.org/ws/2004/09/enumeration"><w:Items xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd">
+    <rsp:Shell xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">
+      <rsp:ShellId>test-id</rsp:ShellId>
+      <rsp:ResourceUri>http://schemas.microsoft.com/powershell/JEAMaintenance</rsp:ResourceUri>
+      <rsp:Owner>CONTOSO\svc</rsp:Owner>
+      <rsp:State>Disconnected</rsp:State>
+    </rsp:Shell>
+  </w:Items></n:EnumerateResponse>
+</s:Body></s:Envelope>`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, transport.NewHTTPTransport())
+	shells, err := client.Enumerate(context.Background())
+	if err != nil {
+		t.Fatalf("Enumerate failed: %v", err)
+	}
+	if len(shells) != 1 {
+		t.Fatalf("Enumerate returned %d shells, want 1", len(shells))
+	}
+	if got := shells[0].ResourceURI; got != "http://schemas.microsoft.com/powershell/JEAMaintenance" {
+		t.Errorf("ResourceURI = %q", got)
+	}
+}
+
 // Suppress unused import warning for xml package.
 var _ = xml.Name{}
+
+// TestClient_ActivityID verifies that each request carries an ActivityId
+// header and that failures report it.
+func TestClient_ActivityID(t *testing.T) {
+	var ids []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env struct {
+			Header struct {
+				ActivityID string `xml:"ActivityId"`
+			} `xml:"Header"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		_ = xml.Unmarshal(body, &env)
+		ids = append(ids, env.Header.ActivityID)
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body><s:Fault>` +
+			`<s:Code><s:Value>s:Receiver</s:Value></s:Code><s:Reason><s:Text>boom</s:Text></s:Reason>` +
+			`</s:Fault></s:Body></s:Envelope>`))
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, transport.NewHTTPTransport())
+
+	err1 := client.Delete(context.Background(), dummyEPR())
+	err2 := client.Delete(context.Background(), dummyEPR())
+	if err1 == nil || err2 == nil {
+		t.Fatal("Delete() did not fail")
+	}
+	if len(ids) != 2 || ids[0] == "" || ids[0] == ids[1] {
+		t.Fatalf("activity IDs sent = %q, want two different IDs", ids)
+	}
+	if got := ActivityID(err1); got != ids[0] {
+		t.Errorf("ActivityID(err) = %q, want %q", got, ids[0])
+	}
+	if !strings.Contains(err1.Error(), ids[0]) {
+		t.Errorf("error %q does not mention the activity ID", err1)
+	}
+
+	// A context can pin the ID for all requests of an operation
+	ctx := ContextWithActivityID(context.Background(), "0F8FAD5B-D9CB-469F-A165-70867728950E")
+	err := client.Delete(ctx, dummyEPR())
+	if got := ActivityID(err); got != "0F8FAD5B-D9CB-469F-A165-70867728950E" || ids[2] != got {
+		t.Errorf("ActivityID(err) = %q, sent %q; want the context's ID", got, ids[2])
+	}
+}
@@ -9,6 +9,7 @@
 //
 //   - auth: Authentication handlers (Basic, NTLM)
 //   - transport: HTTP/TLS transport layer
+//   - wsmantest: Fake WinRM server for tests
 //
 // # WSMan Operations
 //
@@ -35,6 +35,13 @@ type Header struct {
 	Locale           *Locale                `xml:"w:Locale,omitempty"`
 	DataLocale       *DataLocale            `xml:"p:DataLocale,omitempty"`
 	SessionID        string                 `xml:"p:SessionId,omitempty"`
+	ActivityID       *ActivityIDHeader      `xml:"p:ActivityId,omitempty"`
+
+	// WS-Eventing subscription identifier (Renew, Unsubscribe)
+	Identifier *IdentifierHeader `xml:"wse:Identifier,omitempty"`
+
+	// W3C Trace Context of the client span
+	TraceParent *TraceParentHeader `xml:"tc:traceparent,omitempty"`
 
 	// Shell-specific headers
 	SelectorSet *SelectorSet `xml:"w:SelectorSet,omitempty"`
@@ -47,6 +54,29 @@ type ActionHeader struct {
 	Value          string `xml:",chardata"`
 }
 
+// ActivityIDHeader represents the ActivityId element, which correlates a
+// request with the server's ETW traces.
+type ActivityIDHeader struct {
+	MustUnderstand string `xml:"s:mustUnderstand,attr,omitempty"`
+	Value          string `xml:",chardata"`
+}
+
+// IdentifierHeader represents the WS-Eventing Identifier element that
+// addresses a subscription at its subscription manager.
+type IdentifierHeader struct {
+	Wse   string `xml:"xmlns:wse,attr"`
+	Value string `xml:",chardata"`
+}
+
+// TraceParentHeader carries a W3C traceparent value, so a server or proxy
+// that logs SOAP headers can join the request to the client's trace.
+// Servers that do not know it ignore it.
+type TraceParentHeader struct {
+	Tc             string `xml:"xmlns:tc,attr"`
+	MustUnderstand string `xml:"s:mustUnderstand,attr,omitempty"`
+	Value          string `xml:",chardata"`
+}
+
 // ResourceURIHeader represents ResourceURI element with mustUnderstand attribute.
 type ResourceURIHeader struct {
 	MustUnderstand string `xml:"s:mustUnderstand,attr,omitempty"`
@@ -214,6 +244,12 @@ func (e *Envelope) WithOptionMustComply(name, value string) *Envelope {
 	return e
 }
 
+// WithIdentifier sets the WS-Eventing Identifier header.
+func (e *Envelope) WithIdentifier(id string) *Envelope {
+	e.Header.Identifier = &IdentifierHeader{Wse: NsEventing, Value: id}
+	return e
+}
+
 // WithBody sets the SOAP body content.
 func (e *Envelope) WithBody(content []byte) *Envelope {
 	e.Body.Content = content
@@ -226,6 +262,27 @@ func (e *Envelope) WithSessionID(sessionID string) *Envelope {
 	return e
 }
 
+// WithActivityID sets the ActivityId header. Servers that do not know it
+// ignore it.
+func (e *Envelope) WithActivityID(id string) *Envelope {
+	e.Header.ActivityID = &ActivityIDHeader{
+		MustUnderstand: "false",
+		Value:          id,
+	}
+	return e
+}
+
+// WithTraceParent sets the W3C traceparent header, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func (e *Envelope) WithTraceParent(value string) *Envelope {
+	e.Header.TraceParent = &TraceParentHeader{
+		Tc:             NsTraceContext,
+		MustUnderstand: "false",
+		Value:          value,
+	}
+	return e
+}
+
 // WithLocale sets the WS-Management Locale header.
 func (e *Envelope) WithLocale(lang string) *Envelope {
 	e.Header.Locale = &Locale{
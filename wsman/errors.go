@@ -7,7 +7,49 @@ import (
 	"strings"
 )
 
-// Fault represents a WSMan SOAP fault.
+// ErrorInvalidSelectors is the WSMan error code of a request for a shell or
+// resource that does not exist (ERROR_WSMAN_INVALID_SELECTORS).
+const ErrorInvalidSelectors = 2150858843
+
+// ErrEnvelopeTooLarge matches an *EnvelopeTooLargeError with errors.Is.
+var ErrEnvelopeTooLarge = errors.New("wsman: envelope too large")
+
+// EnvelopeTooLargeError is returned, before anything is sent, for a
+// request whose envelope exceeds ClientOptions.MaxEnvelopeSize. The server
+// would reject it with a fault that does not say by how much.
+type EnvelopeTooLargeError struct {
+	// Action is the WS-Addressing action of the request.
+	Action string
+
+	// Size is the size of the envelope in bytes, PayloadSize that of its
+	// body, such as the base64 stream data of a Send.
+	Size        int
+	PayloadSize int
+
+	// Limit is the MaxEnvelopeSize in effect, negotiated with the server
+	// if ClientOptions were adopted from its configuration.
+	Limit int
+}
+
+// Error implements the error interface.
+func (e *EnvelopeTooLargeError) Error() string {
+	return fmt.Sprintf("wsman: envelope of %d bytes (payload %d bytes) exceeds MaxEnvelopeSize %d", e.Size, e.PayloadSize, e.Limit)
+}
+
+// Is reports whether target is ErrEnvelopeTooLarge.
+func (e *EnvelopeTooLargeError) Is(target error) bool {
+	return target == ErrEnvelopeTooLarge
+}
+
+// MaxPayload returns the largest body that fits the limit with the
+// headers of this request, or 0 if even the headers do not fit.
+func (e *EnvelopeTooLargeError) MaxPayload() int {
+	return max(e.Limit-(e.Size-e.PayloadSize), 0)
+}
+
+// Fault represents a WSMan SOAP fault. It is returned in the error chain of
+// every request the server answers with a fault; use AsFault or errors.As
+// to inspect it.
 type Fault struct {
 	// Code is the SOAP fault code (e.g., "s:Sender", "s:Receiver").
 	Code string
@@ -24,7 +66,35 @@ type Fault struct {
 	// Machine is the machine that generated the fault.
 	Machine string
 
-	// Message is the WSMan fault message.
+	// Message is the WSMan fault message, or the provider's message if the
+	// fault carries only that.
+	Message string
+
+	// Provider is the fault reported by the plugin that handled the
+	// request, or nil.
+	Provider *ProviderFault
+
+	// StatusCode is the HTTP status of the response; WinRM sends most
+	// faults with 500.
+	StatusCode int
+
+	// transportErr is the *transport.TransportError of a fault sent with
+	// an error status.
+	transportErr error
+}
+
+// ProviderFault is a fault raised by a WSMan plugin, such as the
+// PowerShell plugin, rather than by the WSMan service.
+type ProviderFault struct {
+	// Name is the provider name (e.g., "microsoft.powershell").
+	Name string
+
+	// Path is the plugin DLL.
+	Path string
+
+	// WSManCode is the provider's WSMan error code, if it gave one.
+	WSManCode int
+
 	Message string
 }
 
@@ -46,6 +116,12 @@ func (f *Fault) Error() string {
 	return "wsman fault: " + strings.Join(parts, ": ")
 }
 
+// Unwrap returns the transport error of a fault sent with an HTTP error
+// status, so errors.As still finds the *transport.TransportError.
+func (f *Fault) Unwrap() error {
+	return f.transportErr
+}
+
 // IsAccessDenied returns true if the fault indicates access was denied.
 func (f *Fault) IsAccessDenied() bool {
 	if strings.Contains(f.Subcode, "AccessDenied") {
@@ -61,15 +137,39 @@ func (f *Fault) IsAccessDenied() bool {
 // IsShellNotFound returns true if the fault indicates the shell was not found.
 func (f *Fault) IsShellNotFound() bool {
 	return strings.Contains(f.Subcode, "InvalidSelectors") ||
+		f.WSManCode == ErrorInvalidSelectors ||
 		strings.Contains(f.Reason, "shell was not found")
 }
 
+// IsQuotaExceeded returns true if the fault indicates a server quota, such
+// as MaxShellsPerUser or MaxConcurrentOperationsPerUser, was exceeded. The
+// request may succeed once other shells or operations finish.
+func (f *Fault) IsQuotaExceeded() bool {
+	return strings.Contains(f.Subcode, "QuotaLimit") ||
+		strings.Contains(strings.ToLower(f.Reason), "quota")
+}
+
 // IsTimeout returns true if the fault indicates a timeout.
 func (f *Fault) IsTimeout() bool {
 	return strings.Contains(f.Subcode, "TimedOut") ||
 		strings.Contains(f.Reason, "timed out")
 }
 
+// Temporary returns true if the fault may clear up on its own, so the
+// request is worth retrying after a delay: timeouts and exceeded quotas.
+func (f *Fault) Temporary() bool {
+	return f.IsTimeout() || f.IsQuotaExceeded()
+}
+
+// AsFault returns the WSMan Fault in err's chain, if any.
+func AsFault(err error) (*Fault, bool) {
+	var f *Fault
+	if errors.As(err, &f) {
+		return f, true
+	}
+	return nil, false
+}
+
 // IsFault returns true if the error is a WSMan Fault.
 func IsFault(err error) bool {
 	var f *Fault
@@ -95,13 +195,29 @@ func ParseFault(data []byte) (*Fault, error) {
 		return nil, nil
 	}
 
+	detail := env.Body.Fault.Detail.WSManFault
 	fault := &Fault{
 		Code:      env.Body.Fault.Code.Value,
 		Subcode:   env.Body.Fault.Code.Subcode.Value,
-		Reason:    env.Body.Fault.Reason.Text,
-		WSManCode: env.Body.Fault.Detail.WSManFault.Code,
-		Machine:   env.Body.Fault.Detail.WSManFault.Machine,
-		Message:   env.Body.Fault.Detail.WSManFault.Message,
+		Reason:    strings.TrimSpace(env.Body.Fault.Reason.Text),
+		WSManCode: detail.Code,
+		Machine:   detail.Machine,
+		Message:   strings.TrimSpace(detail.Message.Text),
+	}
+
+	if pf := detail.Message.ProviderFault; pf.Provider != "" || pf.Path != "" {
+		fault.Provider = &ProviderFault{
+			Name:      pf.Provider,
+			Path:      pf.Path,
+			WSManCode: pf.WSManFault.Code,
+			Message:   strings.TrimSpace(pf.Text),
+		}
+		if fault.Provider.Message == "" {
+			fault.Provider.Message = strings.TrimSpace(pf.WSManFault.Message)
+		}
+		if fault.Message == "" {
+			fault.Message = fault.Provider.Message
+		}
 	}
 
 	return fault, nil
@@ -137,7 +253,18 @@ type faultEnvelope struct {
 				WSManFault struct {
 					Code    int    `xml:"Code,attr"`
 					Machine string `xml:"Machine,attr"`
-					Message string `xml:"Message"`
+					Message struct {
+						Text          string `xml:",chardata"`
+						ProviderFault struct {
+							Provider   string `xml:"provider,attr"`
+							Path       string `xml:"path,attr"`
+							Text       string `xml:",chardata"`
+							WSManFault struct {
+								Code    int    `xml:"Code,attr"`
+								Message string `xml:"Message"`
+							} `xml:"WSManFault"`
+						} `xml:"ProviderFault"`
+					} `xml:"Message"`
 				} `xml:"WSManFault"`
 			} `xml:"Detail"`
 		} `xml:"Fault"`
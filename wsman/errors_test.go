@@ -1,9 +1,14 @@
 package wsman
 
 import (
+	"context"
 	"errors"
+	"io"
+	"net/http"
 	"strings"
 	"testing"
+
+	"github.com/smnsjas/go-psrp/wsman/transport"
 )
 
 // TestParseFault verifies SOAP fault parsing.
@@ -140,3 +145,130 @@ func TestFault_IsAccessDenied(t *testing.T) {
 		})
 	}
 }
+
+// TestParseFault_ProviderFault verifies parsing of plugin faults.
+func TestParseFault_ProviderFault(t *testing.T) {
+	faultXML := `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope">
+  <s:Body>
+    <s:Fault>
+      <s:Code><s:Value>s:Receiver</s:Value><s:Subcode><s:Value>w:InternalError</s:Value></s:Subcode></s:Code>
+      <s:Reason><s:Text xml:lang="en-US">The WS-Management service cannot process the request.</s:Text></s:Reason>
+      <s:Detail>
+        <f:WSManFault xmlns:f="http://schemas.microsoft.com/wbem/wsman/1/wsmanfault" Code="2150859046" Machine="server01">
+          <f:Message><f:ProviderFault provider="microsoft.powershell" path="C:\Windows\system32\pwrshplugin.dll">` +
+		`<f:WSManFault xmlns:f="http://schemas.microsoft.com/wbem/wsman/1/wsmanfault" Code="2150859046" Machine="server01">` +
+		`<f:Message>The PowerShell configuration Custom was not found.</f:Message></f:WSManFault></f:ProviderFault></f:Message>
+        </f:WSManFault>
+      </s:Detail>
+    </s:Fault>
+  </s:Body>
+</s:Envelope>`
+
+	fault, err := ParseFault([]byte(faultXML))
+	if err != nil {
+		t.Fatalf("ParseFault failed: %v", err)
+	}
+	if fault.Machine != "server01" || fault.WSManCode != 2150859046 {
+		t.Errorf("Machine = %q, WSManCode = %d", fault.Machine, fault.WSManCode)
+	}
+	want := &ProviderFault{
+		Name:      "microsoft.powershell",
+		Path:      `C:\Windows\system32\pwrshplugin.dll`,
+		WSManCode: 2150859046,
+		Message:   "The PowerShell configuration Custom was not found.",
+	}
+	if fault.Provider == nil || *fault.Provider != *want {
+		t.Errorf("Provider = %+v, want %+v", fault.Provider, want)
+	}
+	if fault.Message != want.Message {
+		t.Errorf("Message = %q, want the provider message", fault.Message)
+	}
+}
+
+// TestFault_Classification verifies the retry-related helpers.
+func TestFault_Classification(t *testing.T) {
+	tests := []struct {
+		name          string
+		fault         *Fault
+		shellNotFound bool
+		quota         bool
+		temporary     bool
+	}{
+		{
+			name:          "shell not found by code",
+			fault:         &Fault{Subcode: "w:InternalError", WSManCode: ErrorInvalidSelectors},
+			shellNotFound: true,
+		},
+		{
+			name:      "quota by subcode",
+			fault:     &Fault{Subcode: "w:QuotaLimit"},
+			quota:     true,
+			temporary: true,
+		},
+		{
+			name: "quota by reason",
+			fault: &Fault{Reason: "This user is allowed a maximum number of 5 concurrent shells, " +
+				"which has been exceeded. Close existing shells or raise the quota for this user."},
+			quota:     true,
+			temporary: true,
+		},
+		{
+			name:      "timeout",
+			fault:     &Fault{Subcode: "w:TimedOut"},
+			temporary: true,
+		},
+		{
+			name:  "access denied",
+			fault: &Fault{Subcode: "w:AccessDenied"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fault.IsShellNotFound(); got != tt.shellNotFound {
+				t.Errorf("IsShellNotFound() = %v, want %v", got, tt.shellNotFound)
+			}
+			if got := tt.fault.IsQuotaExceeded(); got != tt.quota {
+				t.Errorf("IsQuotaExceeded() = %v, want %v", got, tt.quota)
+			}
+			if got := tt.fault.Temporary(); got != tt.temporary {
+				t.Errorf("Temporary() = %v, want %v", got, tt.temporary)
+			}
+		})
+	}
+}
+
+// TestSendEnvelope_FaultWithErrorStatus verifies that a fault sent with
+// HTTP 500 is returned as a Fault that still unwraps to the transport error.
+func TestSendEnvelope_FaultWithErrorStatus(t *testing.T) {
+	tr := transport.NewHTTPTransport()
+	tr.Client().Transport = &MockTransport{
+		RoundTripFunc: func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Header:     http.Header{"Content-Type": {"application/soap+xml"}},
+				Body: io.NopCloser(strings.NewReader(soapBody(`<s:Fault><s:Code><s:Value>s:Sender</s:Value>` +
+					`<s:Subcode><s:Value>w:InvalidSelectors</s:Value></s:Subcode></s:Code>` +
+					`<s:Reason><s:Text>The request for the Windows Remote Shell failed because the shell was not found.</s:Text></s:Reason>` +
+					`</s:Fault>`))),
+			}, nil
+		},
+	}
+	c := NewClient("http://server:5985/wsman", tr)
+
+	err := c.Delete(context.Background(), &EndpointReference{Selectors: []Selector{{Name: "ShellId", Value: "gone"}}})
+	fault, ok := AsFault(err)
+	if !ok {
+		t.Fatalf("Delete() error = %v, want a Fault", err)
+	}
+	if !fault.IsShellNotFound() || fault.StatusCode != http.StatusInternalServerError {
+		t.Errorf("fault = %+v", fault)
+	}
+	var te *transport.TransportError
+	if !errors.As(err, &te) || te.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Delete() error = %v, want it to unwrap to the *TransportError", err)
+	}
+	if _, ok := AsFault(errors.New("other")); ok {
+		t.Error("AsFault(non-fault) = true")
+	}
+}
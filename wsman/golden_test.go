@@ -0,0 +1,159 @@
+package wsman
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden envelopes in testdata/golden")
+
+const (
+	goldenEndpoint  = "http://winrm01.example.com:5985/wsman"
+	goldenSessionID = "uuid:11111111-1111-1111-1111-111111111111"
+	goldenShellID   = "22222222-2222-2222-2222-222222222222"
+	goldenCommandID = "33333333-3333-3333-3333-333333333333"
+)
+
+// uuidPattern matches the generated IDs (MessageID, ActivityId, ShellId).
+var uuidPattern = regexp.MustCompile(`[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}`)
+
+// normalizeEnvelope replaces the generated IDs of an envelope with
+// placeholders numbered by first appearance, keeping the fixed test IDs.
+func normalizeEnvelope(body string) string {
+	fixed := map[string]bool{
+		strings.TrimPrefix(goldenSessionID, "uuid:"): true,
+		goldenShellID:   true,
+		goldenCommandID: true,
+	}
+	seen := make(map[string]string)
+	return uuidPattern.ReplaceAllStringFunc(body, func(id string) string {
+		if fixed[strings.ToUpper(id)] {
+			return id
+		}
+		if _, ok := seen[id]; !ok {
+			seen[id] = fmt.Sprintf("GENERATED-ID-%d", len(seen)+1)
+		}
+		return seen[id]
+	})
+}
+
+// TestGoldenEnvelopes renders every shell envelope and compares it with the
+// vetted XML in testdata/golden, so namespace, header and attribute changes
+// are deliberate. Run with -update to rewrite the files after a reviewed change.
+func TestGoldenEnvelopes(t *testing.T) {
+	var lastBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lastBody = string(body)
+		w.Header().Set("Content-Type", transport.ContentTypeSOAP)
+		switch {
+		case strings.Contains(lastBody, ActionCreate):
+			_, _ = io.WriteString(w, `<s:Envelope xmlns:s="`+NsSoap+`" xmlns:a="`+NsAddressing+`" xmlns:w="`+NsWsman+`"><s:Body>`+
+				`<w:ResourceCreated><a:Address>`+goldenEndpoint+`</a:Address><a:ReferenceParameters>`+
+				`<w:ResourceURI>`+ResourceURIPowerShell+`</w:ResourceURI>`+
+				`<w:SelectorSet><w:Selector Name="ShellId">`+goldenShellID+`</w:Selector></w:SelectorSet>`+
+				`</a:ReferenceParameters></w:ResourceCreated></s:Body></s:Envelope>`)
+		default:
+			_, _ = io.WriteString(w, `<s:Envelope xmlns:s="`+NsSoap+`"><s:Body/></s:Envelope>`)
+		}
+	}))
+	defer server.Close()
+
+	// The endpoint in the envelopes stays fixed; requests go to the test server
+	client := NewClient(goldenEndpoint, transport.NewHTTPTransport(
+		transport.WithDialAddress(server.Listener.Addr().String()),
+	))
+	client.SetSessionID(goldenSessionID)
+
+	epr := &EndpointReference{
+		Address:     goldenEndpoint,
+		ResourceURI: ResourceURIPowerShell,
+		Selectors:   []Selector{{Name: "ShellId", Value: goldenShellID}},
+	}
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"create", func(ctx context.Context) error {
+			_, err := client.Create(ctx, map[string]string{
+				"protocolversion": "2.3",
+				"IdleTimeout":     "PT1H",
+			}, "AAAAAAAAAAEAAAAAAAAAAAMAAADJAgAAAAIAAQ==")
+			return err
+		}},
+		{"create_winrs", func(ctx context.Context) error {
+			_, err := client.Create(ctx, map[string]string{
+				"ResourceURI":             ResourceURIWinRS,
+				"WINRS_CODEPAGE":          "65001",
+				"WINRS_NOPROFILE":         "FALSE",
+				"WINRS_CONSOLEMODE_STDIN": "TRUE",
+			}, "")
+			return err
+		}},
+		{"command", func(ctx context.Context) error {
+			_, err := client.Command(ctx, epr, goldenCommandID, "AAAAAAAAAAIAAAAAAAAAAAMAAAAKAgAAAAYQAgA=")
+			return err
+		}},
+		{"send", func(ctx context.Context) error {
+			return client.Send(ctx, epr, goldenCommandID, "stdin", []byte("input data"))
+		}},
+		{"receive", func(ctx context.Context) error {
+			_, err := client.Receive(ctx, epr, goldenCommandID)
+			return err
+		}},
+		{"signal", func(ctx context.Context) error {
+			return client.Signal(ctx, epr, goldenCommandID, SignalTerminate)
+		}},
+		{"delete", func(ctx context.Context) error {
+			return client.Delete(ctx, epr)
+		}},
+		{"disconnect", func(ctx context.Context) error {
+			return client.Disconnect(ctx, epr)
+		}},
+		{"disconnect_options", func(ctx context.Context) error {
+			return client.DisconnectWithOptions(ctx, epr, DisconnectOptions{IdleTimeout: "PT4H", BufferMode: BufferModeDrop})
+		}},
+		{"reconnect", func(ctx context.Context) error {
+			return client.Reconnect(ctx, goldenShellID)
+		}},
+		{"connect_command", func(ctx context.Context) error {
+			return client.ConnectCommand(ctx, epr, goldenCommandID)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(context.Background()); err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			got := normalizeEnvelope(lastBody) + "\n"
+
+			path := filepath.Join("testdata", "golden", tt.name+".xml")
+			if *updateGolden {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden file (run with -update to create it): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("envelope differs from %s\n got: %s\nwant: %s", path, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package wsman
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// IdentifyResponse describes a WS-Management service.
+type IdentifyResponse struct {
+	// ProtocolVersion is the WS-Management protocol namespace, e.g.
+	// "http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd".
+	ProtocolVersion string `xml:"ProtocolVersion"`
+
+	// ProductVendor is e.g. "Microsoft Corporation".
+	ProductVendor string `xml:"ProductVendor"`
+
+	// ProductVersion is e.g. "OS: 10.0.20348 SP: 0.0 Stack: 3.0".
+	ProductVersion string `xml:"ProductVersion"`
+
+	// SecurityProfiles are the URIs of the authentication profiles the
+	// service supports, if it lists them.
+	SecurityProfiles []string `xml:"SecurityProfiles>SecurityProfileName"`
+}
+
+// Identify asks the service for its protocol version and product
+// (WS-Management Identify). It needs no shell and no resource, which makes
+// it a cheap check that the endpoint is a WS-Management service and that
+// authentication works.
+func (c *Client) Identify(ctx context.Context) (*IdentifyResponse, error) {
+	env := NewEnvelope().WithBody([]byte(`<wsmid:Identify xmlns:wsmid="` + NsIdentity + `"/>`))
+	respBody, err := c.sendIdempotent(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("identify: %w", err)
+	}
+	var resp struct {
+		Body struct {
+			Identify *IdentifyResponse `xml:"IdentifyResponse"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("parse identify response: %w", err)
+	}
+	id := resp.Body.Identify
+	if id == nil {
+		return nil, fmt.Errorf("parse identify response: no IdentifyResponse")
+	}
+	id.ProtocolVersion = strings.TrimSpace(id.ProtocolVersion)
+	id.ProductVendor = strings.TrimSpace(id.ProductVendor)
+	id.ProductVersion = strings.TrimSpace(id.ProductVersion)
+	return id, nil
+}
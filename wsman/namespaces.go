@@ -32,6 +32,13 @@ const (
 
 	// NsXsi is the XML Schema Instance namespace.
 	NsXsi = "http://www.w3.org/2001/XMLSchema-instance"
+
+	// NsIdentity is the namespace of the WS-Management Identify operation.
+	NsIdentity = "http://schemas.dmtf.org/wbem/wsman/identity/1/wsmanidentity.xsd"
+
+	// NsTraceContext is the namespace of the W3C Trace Context header the
+	// client can add to requests (see ClientOptions.PropagateTraceContext).
+	NsTraceContext = "https://www.w3.org/TR/trace-context/"
 )
 
 // WS-Addressing constants.
@@ -91,6 +98,22 @@ const (
 	ResourceURIWinRS = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/cmd"
 )
 
+// Stream names of PowerShell and WinRS shells.
+const (
+	// StreamStdin carries PSRP fragments and pipeline input to a command.
+	StreamStdin = "stdin"
+
+	// StreamPR is the priority input stream. It carries host responses,
+	// which must reach the server while stdin may be blocked.
+	StreamPR = "pr"
+
+	// StreamStdout carries PSRP fragments from the server.
+	StreamStdout = "stdout"
+
+	// StreamStderr carries the error output of WinRS commands.
+	StreamStderr = "stderr"
+)
+
 // Signal codes for the Signal action.
 const (
 	// SignalTerminate terminates a command.
@@ -101,6 +124,10 @@ const (
 
 	// SignalCtrlBreak sends Ctrl+Break to a command.
 	SignalCtrlBreak = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/signal/ctrl_break"
+
+	// SignalPSCtrlC stops a PowerShell pipeline (MS-PSRP). The misspelling
+	// is part of the protocol.
+	SignalPSCtrlC = "powershell/signal/crtl_c"
 )
 
 // WSMan Action URIs for Enumeration.
@@ -147,6 +174,12 @@ const (
 
 	// ActionUnsubscribeResponse is the response to Unsubscribe.
 	ActionUnsubscribeResponse = "http://schemas.xmlsoap.org/ws/2004/08/eventing/UnsubscribeResponse"
+
+	// ActionRenew extends a subscription's expiration.
+	ActionRenew = "http://schemas.xmlsoap.org/ws/2004/08/eventing/Renew"
+
+	// ActionRenewResponse is the response to Renew.
+	ActionRenewResponse = "http://schemas.xmlsoap.org/ws/2004/08/eventing/RenewResponse"
 )
 
 // WSMan Action URIs for WS-Enumeration (Pull).
@@ -163,3 +196,14 @@ const (
 	// DeliveryModePull indicates pull-based event delivery.
 	DeliveryModePull = "http://schemas.dmtf.org/wbem/wsman/1/wsman/Pull"
 )
+
+// Windows Event Log subscriptions.
+const (
+	// ResourceURIEventLog is the resource URI for subscribing to Windows
+	// Event Log channels.
+	ResourceURIEventLog = "http://schemas.microsoft.com/wbem/wsman/1/windows/EventLog"
+
+	// DialectEventQuery filters events with a structured XML query
+	// (<QueryList>), as built by EventLogQuery.
+	DialectEventQuery = "http://schemas.microsoft.com/win/2004/08/events/eventquery"
+)
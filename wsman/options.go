@@ -0,0 +1,319 @@
+package wsman
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+// Default envelope limit and timeouts, matching WinRM's defaults.
+const (
+	// DefaultMaxEnvelopeSize is the envelope size limit in bytes: the
+	// default MaxEnvelopeSizekb of 500.
+	DefaultMaxEnvelopeSize = 512000
+
+	// DefaultOperationTimeout is the server-side timeout of an operation.
+	DefaultOperationTimeout = 60 * time.Second
+
+	// DefaultReceiveTimeout is how long the server holds a Receive open
+	// while waiting for output.
+	DefaultReceiveTimeout = time.Second
+
+	// DefaultLocale is the language of messages and the culture of
+	// formatted data the server is asked for.
+	DefaultLocale = "en-US"
+)
+
+// ResourceURIConfig is the resource URI of the server's WinRM
+// configuration (winrm/config).
+const ResourceURIConfig = "http://schemas.microsoft.com/wbem/wsman/1/config"
+
+// ClientOptions sets the envelope size, timeouts and locales a Client
+// sends with its requests, how they are traced and retried. Zero values
+// use the defaults.
+type ClientOptions struct {
+	// MaxEnvelopeSize is the largest envelope, in bytes, the server may
+	// send. It cannot exceed the server's MaxEnvelopeSizekb, which also
+	// limits requests: a larger request fails with ErrEnvelopeTooLarge
+	// without being sent.
+	MaxEnvelopeSize int
+
+	// OperationTimeout is the server-side timeout of an operation. It
+	// cannot exceed the server's MaxTimeoutms.
+	OperationTimeout time.Duration
+
+	// ReceiveTimeout is how long the server holds a Receive open while
+	// waiting for output. Longer values mean fewer requests on slow links
+	// but later stop and disconnect handling.
+	ReceiveTimeout time.Duration
+
+	// Locale is the language of error and status messages (the Locale
+	// header), e.g. "de-DE". PowerShell uses it as the UI culture of the
+	// session. Default: DefaultLocale.
+	Locale string
+
+	// DataLocale is the culture of formatted dates and numbers (the
+	// DataLocale header). PowerShell uses it as the culture of the
+	// session. Default: Locale.
+	DataLocale string
+
+	// Tracer, if set, receives every request and response. Nil disables
+	// tracing.
+	Tracer Tracer
+
+	// TracePayloadLimit is how many characters of each stream payload a
+	// trace keeps: 0 uses DefaultTracePayloadLimit, negative keeps them
+	// whole.
+	TracePayloadLimit int
+
+	// Retry, if set, retries idempotent operations after transient
+	// failures. Nil disables retries.
+	Retry *RetryPolicy
+
+	// TracerProvider, if set, creates a client span for every request,
+	// named after the operation (e.g. "WSMan Receive").
+	TracerProvider TracerProvider
+
+	// MeterProvider, if set, records request duration, bytes sent and
+	// received, and retries.
+	MeterProvider MeterProvider
+
+	// PropagateTraceContext adds the W3C traceparent of each request span
+	// to the SOAP header (see NsTraceContext). It needs TracerProvider.
+	PropagateTraceContext bool
+
+	// Logger, if set, receives retries and other recoverable problems.
+	// Nil disables logging; see Tracer for logging requests.
+	Logger *slog.Logger
+}
+
+// withDefaults returns o with zero values replaced by the defaults.
+func (o ClientOptions) withDefaults() ClientOptions {
+	if o.MaxEnvelopeSize <= 0 {
+		o.MaxEnvelopeSize = DefaultMaxEnvelopeSize
+	}
+	if o.OperationTimeout <= 0 {
+		o.OperationTimeout = DefaultOperationTimeout
+	}
+	if o.ReceiveTimeout <= 0 {
+		o.ReceiveTimeout = DefaultReceiveTimeout
+	}
+	if o.Locale == "" {
+		o.Locale = DefaultLocale
+	}
+	if o.DataLocale == "" {
+		o.DataLocale = o.Locale
+	}
+	return o
+}
+
+// NewClientWithOptions creates a new WSMan client with the given envelope
+// size and timeouts.
+func NewClientWithOptions(endpoint string, tr *transport.HTTPTransport, opts ClientOptions) *Client {
+	c := NewClient(endpoint, tr)
+	c.SetOptions(opts)
+	return c
+}
+
+// SetOptions sets the envelope size and timeouts of subsequent requests.
+func (c *Client) SetOptions(opts ClientOptions) {
+	c.optsMu.Lock()
+	defer c.optsMu.Unlock()
+	c.opts = opts.withDefaults()
+	c.tel = newTelemetry(c.endpoint, c.opts)
+}
+
+// telemetry returns the OpenTelemetry instruments, or nil if disabled.
+func (c *Client) telemetry() *telemetry {
+	c.optsMu.RLock()
+	defer c.optsMu.RUnlock()
+	return c.tel
+}
+
+// Options returns the envelope size and timeouts in use.
+func (c *Client) Options() ClientOptions {
+	c.optsMu.RLock()
+	defer c.optsMu.RUnlock()
+	return c.opts.withDefaults()
+}
+
+// maxEnvelopeSize returns the MaxEnvelopeSize header value.
+func (c *Client) maxEnvelopeSize() int {
+	return c.Options().MaxEnvelopeSize
+}
+
+// locale returns the Locale header value.
+func (c *Client) locale() string {
+	return c.Options().Locale
+}
+
+// dataLocale returns the DataLocale header value.
+func (c *Client) dataLocale() string {
+	return c.Options().DataLocale
+}
+
+// operationTimeout returns the OperationTimeout header value of a
+// request made with ctx.
+func (c *Client) operationTimeout(ctx context.Context) string {
+	return isoDuration(headerTimeout(ctx, c.Options().OperationTimeout))
+}
+
+// receiveTimeout returns the OperationTimeout header value for a Receive
+// made with ctx.
+func (c *Client) receiveTimeout(ctx context.Context) string {
+	return isoDuration(headerTimeout(ctx, c.Options().ReceiveTimeout))
+}
+
+// minHeaderTimeout is the shortest OperationTimeout sent.
+const minHeaderTimeout = 100 * time.Millisecond
+
+// headerTimeout shortens the server-side timeout d so that it ends before
+// the deadline of ctx, leaving time for the reply. The server then gives
+// up first: a Receive returns no output and can be polled again, and
+// other operations fail with a timeout fault instead of a canceled
+// request.
+func headerTimeout(ctx context.Context, d time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return d
+	}
+	remaining := time.Until(deadline)
+	margin := min(time.Second, remaining/5)
+	return max(min(d, (remaining-margin).Truncate(time.Millisecond)), minHeaderTimeout)
+}
+
+// isoDuration formats d as an ISO 8601 duration such as "PT60S" or
+// "PT1.5S".
+func isoDuration(d time.Duration) string {
+	return "PT" + strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+}
+
+// ServerConfig is the part of the server's winrm/config that limits
+// requests.
+type ServerConfig struct {
+	// MaxEnvelopeSize is MaxEnvelopeSizekb in bytes.
+	MaxEnvelopeSize int
+
+	// MaxTimeout is MaxTimeoutms, the longest OperationTimeout accepted.
+	MaxTimeout time.Duration
+
+	// MaxBatchItems is the most items returned in one enumeration Pull.
+	MaxBatchItems int
+
+	// MaxConcurrentOperationsPerUser is Service/MaxConcurrentOperationsPerUser,
+	// the most operations, such as running commands, one user may have at
+	// once.
+	MaxConcurrentOperationsPerUser int
+
+	// MaxShellsPerUser is Winrs/MaxShellsPerUser, the most shells one user
+	// may have open at once.
+	MaxShellsPerUser int
+}
+
+// ServerConfig reads the server's winrm/config. Reading it usually
+// requires administrator rights on the server.
+func (c *Client) ServerConfig(ctx context.Context) (*ServerConfig, error) {
+	instance, err := c.Get(ctx, ResourceURIConfig, nil)
+	if err != nil {
+		return nil, fmt.Errorf("read server config: %w", err)
+	}
+	props, err := ParseInstance(instance)
+	if err != nil {
+		return nil, fmt.Errorf("read server config: %w", err)
+	}
+
+	cfg := &ServerConfig{}
+	if kb, err := strconv.Atoi(props["MaxEnvelopeSizekb"]); err == nil {
+		cfg.MaxEnvelopeSize = kb * 1024
+	}
+	if ms, err := strconv.Atoi(props["MaxTimeoutms"]); err == nil {
+		cfg.MaxTimeout = time.Duration(ms) * time.Millisecond
+	}
+	if n, err := strconv.Atoi(props["MaxBatchItems"]); err == nil {
+		cfg.MaxBatchItems = n
+	}
+
+	// The per-user quotas are in the nested Service and Winrs sections
+	service, err := configSection(instance, "Service")
+	if err != nil {
+		return nil, fmt.Errorf("read server config: %w", err)
+	}
+	if n, err := strconv.Atoi(service["MaxConcurrentOperationsPerUser"]); err == nil {
+		cfg.MaxConcurrentOperationsPerUser = n
+	}
+	winrs, err := configSection(instance, "Winrs")
+	if err != nil {
+		return nil, fmt.Errorf("read server config: %w", err)
+	}
+	if n, err := strconv.Atoi(winrs["MaxShellsPerUser"]); err == nil {
+		cfg.MaxShellsPerUser = n
+	}
+	return cfg, nil
+}
+
+// configSection returns the properties of a section of a winrm/config
+// instance, such as Service, as ParseInstance does for the instance. A
+// missing section has no properties.
+func configSection(instance []byte, name string) (map[string]string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(instance))
+	depth := 0
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return map[string]string{}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse section %s: %w", name, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 && t.Name.Local == name {
+				if err := dec.Skip(); err != nil {
+					return nil, fmt.Errorf("parse section %s: %w", name, err)
+				}
+				return ParseInstance(instance[offset:dec.InputOffset()])
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// NegotiateOptions reads the server's winrm/config and adapts the client
+// to it: MaxEnvelopeSize is raised or lowered to the server's limit, and
+// OperationTimeout and ReceiveTimeout are capped at MaxTimeoutms. If the
+// configuration cannot be read, the options are left unchanged.
+func (c *Client) NegotiateOptions(ctx context.Context) (*ServerConfig, error) {
+	cfg, err := c.ServerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.AdoptServerConfig(cfg)
+	return cfg, nil
+}
+
+// AdoptServerConfig adapts the client to cfg as NegotiateOptions does,
+// without reading it from the server, e.g. for a configuration cached from
+// an earlier NegotiateOptions.
+func (c *Client) AdoptServerConfig(cfg *ServerConfig) {
+	c.optsMu.Lock()
+	defer c.optsMu.Unlock()
+	opts := c.opts.withDefaults()
+	if cfg.MaxEnvelopeSize > 0 {
+		opts.MaxEnvelopeSize = cfg.MaxEnvelopeSize
+	}
+	if cfg.MaxTimeout > 0 {
+		opts.OperationTimeout = min(opts.OperationTimeout, cfg.MaxTimeout)
+		opts.ReceiveTimeout = min(opts.ReceiveTimeout, cfg.MaxTimeout)
+	}
+	c.opts = opts
+}
@@ -0,0 +1,154 @@
+package wsman
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+// capturingClient returns a client that records request bodies.
+func capturingClient(opts ClientOptions) (*Client, *[]string) {
+	var bodies []string
+	tr := transport.NewHTTPTransport()
+	tr.Client().Transport = &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			bodies = append(bodies, string(body))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(soapBody(""))),
+			}, nil
+		},
+	}
+	return NewClientWithOptions("http://server:5985/wsman", tr, opts), &bodies
+}
+
+func TestClient_Options(t *testing.T) {
+	c := NewClient("http://server:5985/wsman", nil)
+	if got := c.Options(); got.MaxEnvelopeSize != DefaultMaxEnvelopeSize || got.OperationTimeout != DefaultOperationTimeout {
+		t.Errorf("default Options() = %+v", got)
+	}
+	ctx := context.Background()
+	if c.operationTimeout(ctx) != "PT60S" || c.receiveTimeout(ctx) != "PT1S" {
+		t.Errorf("default timeouts = %s, %s", c.operationTimeout(ctx), c.receiveTimeout(ctx))
+	}
+
+	c.SetOptions(ClientOptions{OperationTimeout: 1500 * time.Millisecond})
+	if c.operationTimeout(ctx) != "PT1.5S" || c.maxEnvelopeSize() != DefaultMaxEnvelopeSize {
+		t.Errorf("operationTimeout() = %s, maxEnvelopeSize() = %d", c.operationTimeout(ctx), c.maxEnvelopeSize())
+	}
+}
+
+func TestClient_Locales(t *testing.T) {
+	c, bodies := capturingClient(ClientOptions{})
+	if _, err := c.Receive(context.Background(), dummyEPR(), "cmd-id"); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if !strings.Contains((*bodies)[0], `<w:Locale xml:lang="en-US"></w:Locale>`) || !strings.Contains((*bodies)[0], `<p:DataLocale xml:lang="en-US"></p:DataLocale>`) {
+		t.Errorf("request without the default locales: %s", (*bodies)[0])
+	}
+
+	c.SetOptions(ClientOptions{Locale: "de-DE"})
+	if _, err := c.Command(context.Background(), dummyEPR(), "", "-NoLogo"); err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+	if !strings.Contains((*bodies)[1], `<w:Locale xml:lang="de-DE"></w:Locale>`) || !strings.Contains((*bodies)[1], `<p:DataLocale xml:lang="de-DE"></p:DataLocale>`) {
+		t.Errorf("DataLocale does not default to Locale: %s", (*bodies)[1])
+	}
+
+	c.SetOptions(ClientOptions{Locale: "en-US", DataLocale: "fr-FR"})
+	if err := c.Signal(context.Background(), dummyEPR(), "cmd-id", SignalTerminate); err != nil {
+		t.Fatalf("Signal() error = %v", err)
+	}
+	if !strings.Contains((*bodies)[2], `<w:Locale xml:lang="en-US"></w:Locale>`) || !strings.Contains((*bodies)[2], `<p:DataLocale xml:lang="fr-FR"></p:DataLocale>`) {
+		t.Errorf("request without the configured locales: %s", (*bodies)[2])
+	}
+}
+
+func TestHeaderTimeout(t *testing.T) {
+	if got := headerTimeout(context.Background(), time.Minute); got != time.Minute {
+		t.Errorf("without deadline = %v, want 1m", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	if got := headerTimeout(ctx, time.Minute); got < 18*time.Second || got > 19*time.Second {
+		t.Errorf("with 20s deadline = %v, want about 19s", got)
+	}
+	if got := headerTimeout(ctx, 5*time.Second); got != 5*time.Second {
+		t.Errorf("shorter than deadline = %v, want 5s", got)
+	}
+
+	expired, cancelExpired := context.WithTimeout(context.Background(), -time.Second)
+	defer cancelExpired()
+	if got := headerTimeout(expired, time.Minute); got != minHeaderTimeout {
+		t.Errorf("expired deadline = %v, want %v", got, minHeaderTimeout)
+	}
+}
+
+func TestClient_ReceiveTimeoutFollowsDeadline(t *testing.T) {
+	var body string
+	c := newMockClient(func(b string) string {
+		body = b
+		return soapBody(`<rsp:ReceiveResponse xmlns:rsp="` + NsShell + `"/>`)
+	})
+	c.SetOptions(ClientOptions{ReceiveTimeout: 30 * time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := c.Receive(ctx, shellEPR, "cmd"); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if strings.Contains(body, "PT30S") || !strings.Contains(body, "<w:OperationTimeout>PT") {
+		t.Errorf("OperationTimeout not shortened to the deadline:\n%s", body)
+	}
+}
+
+func TestClient_NegotiateOptions(t *testing.T) {
+	var requests []string
+	c := newMockClient(func(body string) string {
+		requests = append(requests, body)
+		if strings.Contains(body, ResourceURIConfig) {
+			return soapBody(`<cfg:Config xmlns:cfg="` + ResourceURIConfig + `">` +
+				`<cfg:MaxEnvelopeSizekb>8192</cfg:MaxEnvelopeSizekb><cfg:MaxTimeoutms>30000</cfg:MaxTimeoutms>` +
+				`<cfg:MaxBatchItems>32000</cfg:MaxBatchItems><cfg:Service><cfg:MaxConcurrentOperations>4294967295</cfg:MaxConcurrentOperations>` +
+				`<cfg:MaxConcurrentOperationsPerUser>1500</cfg:MaxConcurrentOperationsPerUser></cfg:Service>` +
+				`<cfg:Winrs><cfg:AllowRemoteShellAccess>true</cfg:AllowRemoteShellAccess><cfg:MaxShellsPerUser>30</cfg:MaxShellsPerUser></cfg:Winrs>` +
+				`</cfg:Config>`)
+		}
+		return soapBody(serviceInstance)
+	})
+	c.SetOptions(ClientOptions{ReceiveTimeout: 5 * time.Second})
+
+	cfg, err := c.NegotiateOptions(context.Background())
+	if err != nil {
+		t.Fatalf("NegotiateOptions() error = %v", err)
+	}
+	want := ServerConfig{
+		MaxEnvelopeSize:                8192 * 1024,
+		MaxTimeout:                     30 * time.Second,
+		MaxBatchItems:                  32000,
+		MaxConcurrentOperationsPerUser: 1500,
+		MaxShellsPerUser:               30,
+	}
+	if *cfg != want {
+		t.Errorf("ServerConfig = %+v, want %+v", *cfg, want)
+	}
+	opts := c.Options()
+	if opts.MaxEnvelopeSize != 8192*1024 || opts.OperationTimeout != 30*time.Second || opts.ReceiveTimeout != 5*time.Second {
+		t.Errorf("negotiated Options() = %+v", opts)
+	}
+
+	if _, err := c.Get(context.Background(), "root/cimv2/Win32_Service", nil); err != nil {
+		t.Fatal(err)
+	}
+	last := requests[len(requests)-1]
+	if !strings.Contains(last, ">8388608</w:MaxEnvelopeSize>") || !strings.Contains(last, "<w:OperationTimeout>PT30S</w:OperationTimeout>") {
+		t.Errorf("request does not use the negotiated options:\n%s", last)
+	}
+}
@@ -0,0 +1,364 @@
+package wsman
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ResourceURIWMIPrefix is the prefix of WMI resource URIs. A resource URI
+// without a scheme, such as "root/cimv2/Win32_Service", is taken relative
+// to it.
+const ResourceURIWMIPrefix = "http://schemas.microsoft.com/wbem/wsman/1/wmi/"
+
+// Filter dialects for EnumerateResources.
+const (
+	// DialectWQL filters with a WQL query, e.g.
+	// "SELECT * FROM Win32_Service WHERE State = 'Running'".
+	DialectWQL = "http://schemas.microsoft.com/wbem/wsman/1/WQL"
+
+	// DialectSelector filters by key properties (a SelectorSet).
+	DialectSelector = "http://schemas.dmtf.org/wbem/wsman/1/wsman/SelectorFilter"
+)
+
+// WS-Transfer Action URIs for resource access.
+const (
+	// ActionGet retrieves a resource instance.
+	ActionGet = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Get"
+
+	// ActionPut updates a resource instance.
+	ActionPut = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Put"
+)
+
+// ResourceURI returns the full resource URI for uri: uri itself if it has a
+// scheme, else the WMI resource URI for the class path, e.g.
+// "root/cimv2/Win32_Service".
+func ResourceURI(uri string) string {
+	if strings.Contains(uri, "://") {
+		return uri
+	}
+	return ResourceURIWMIPrefix + strings.TrimPrefix(uri, "/")
+}
+
+// newResourceEnvelope returns a request envelope for action on resourceURI
+// with the given selectors, in a stable order.
+func (c *Client) newResourceEnvelope(ctx context.Context, action, resourceURI string, selectors map[string]string) *Envelope {
+	env := NewEnvelope().
+		WithAction(action).
+		WithTo(c.endpoint).
+		WithResourceURI(ResourceURI(resourceURI)).
+		WithMessageID("uuid:" + strings.ToUpper(uuid.New().String())).
+		WithReplyTo(AddressAnonymous).
+		WithSessionID(c.sessionID).
+		WithMaxEnvelopeSize(c.maxEnvelopeSize()).
+		WithOperationTimeout(c.operationTimeout(ctx))
+	for _, name := range slices.Sorted(maps.Keys(selectors)) {
+		env.WithSelector(name, selectors[name])
+	}
+	return env
+}
+
+// Get retrieves the resource instance identified by selectors (WS-Transfer
+// Get), e.g. resourceURI "root/cimv2/Win32_Service" with selectors
+// {"Name": "WinRM"}. It returns the instance XML; see ParseInstance.
+func (c *Client) Get(ctx context.Context, resourceURI string, selectors map[string]string) ([]byte, error) {
+	env := c.newResourceEnvelope(ctx, ActionGet, resourceURI, selectors)
+	respBody, err := c.sendIdempotent(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("get: %w", err)
+	}
+	instance, err := bodyContent(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("parse get response: %w", err)
+	}
+	return instance, nil
+}
+
+// Put replaces the resource instance identified by selectors with instance,
+// the instance XML as returned by Get with properties changed (WS-Transfer
+// Put). It returns the updated instance XML, or nil if the server sends
+// none.
+func (c *Client) Put(ctx context.Context, resourceURI string, selectors map[string]string, instance []byte) ([]byte, error) {
+	env := c.newResourceEnvelope(ctx, ActionPut, resourceURI, selectors)
+	env.WithBody(instance)
+	respBody, err := c.sendEnvelope(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("put: %w", err)
+	}
+	updated, err := bodyContent(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("parse put response: %w", err)
+	}
+	return updated, nil
+}
+
+// Invoke calls method on the resource instance identified by selectors,
+// e.g. "StopService" on a Win32_Service, or a static method with no
+// selectors. params are the method's input parameters. It returns the
+// method's output XML (<method>_OUTPUT), with ReturnValue for WMI methods.
+func (c *Client) Invoke(ctx context.Context, resourceURI, method string, selectors map[string]string, params map[string]string) ([]byte, error) {
+	uri := ResourceURI(resourceURI)
+	env := c.newResourceEnvelope(ctx, uri+"/"+method, uri, selectors)
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, `<p:%s_INPUT xmlns:p="%s">`, method, xmlEscape(uri))
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		fmt.Fprintf(&body, `<p:%s>%s</p:%s>`, name, xmlEscape(params[name]), name)
+	}
+	fmt.Fprintf(&body, `</p:%s_INPUT>`, method)
+	env.WithBody(body.Bytes())
+
+	respBody, err := c.sendEnvelope(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("invoke %s: %w", method, err)
+	}
+	output, err := bodyContent(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("parse invoke response: %w", err)
+	}
+	return output, nil
+}
+
+// EnumerateOptions configures EnumerateResources.
+type EnumerateOptions struct {
+	// Filter restricts the instances returned, e.g. a WQL query. If empty,
+	// all instances of the resource are returned.
+	Filter string
+
+	// Dialect is the filter dialect. If empty, DialectWQL is used. With
+	// DialectSelector, set Selectors instead of Filter.
+	Dialect string
+
+	// Selectors are the key properties for DialectSelector.
+	Selectors map[string]string
+
+	// MaxElements is the number of instances per response. If 0, 100.
+	MaxElements int
+}
+
+// Enumeration is a batch of enumerated resource instances.
+type Enumeration struct {
+	// Items are the instances, each the XML of one element.
+	Items [][]byte
+
+	// Context is the EnumerationContext for pulling the next batch.
+	Context string
+
+	// Done is set after the last batch.
+	Done bool
+}
+
+// enumerationResponse is an EnumerateResponse or PullResponse body.
+type enumerationResponse struct {
+	EnumerationContext string  `xml:"EnumerationContext"`
+	Items              Items   `xml:"Items"`
+	EndOfSequence      *string `xml:"EndOfSequence"`
+}
+
+// EnumerateResources starts enumerating the instances of resourceURI
+// (WS-Enumeration Enumerate), e.g. "root/cimv2/Win32_Service", or
+// "root/cimv2/*" with a WQL filter. It returns the first batch; pass
+// Context to PullResources for the rest until Done, or use EnumerateAll.
+func (c *Client) EnumerateResources(ctx context.Context, resourceURI string, opts EnumerateOptions) (*Enumeration, error) {
+	maxElements := opts.MaxElements
+	if maxElements <= 0 {
+		maxElements = 100
+	}
+	env := c.newResourceEnvelope(ctx, ActionEnumerate, resourceURI, nil)
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, `<wsen:Enumerate xmlns:wsen="%s" xmlns:wsman="%s"><wsman:OptimizeEnumeration/><wsman:MaxElements>%d</wsman:MaxElements>`,
+		NsEnumeration, NsWsman, maxElements)
+	dialect := opts.Dialect
+	if dialect == "" {
+		dialect = DialectWQL
+	}
+	switch {
+	case dialect == DialectSelector:
+		fmt.Fprintf(&body, `<wsman:Filter Dialect="%s"><wsman:SelectorSet>`, DialectSelector)
+		for _, name := range slices.Sorted(maps.Keys(opts.Selectors)) {
+			fmt.Fprintf(&body, `<wsman:Selector Name="%s">%s</wsman:Selector>`, xmlEscape(name), xmlEscape(opts.Selectors[name]))
+		}
+		body.WriteString(`</wsman:SelectorSet></wsman:Filter>`)
+	case opts.Filter != "":
+		fmt.Fprintf(&body, `<wsman:Filter Dialect="%s">%s</wsman:Filter>`, xmlEscape(dialect), xmlEscape(opts.Filter))
+	}
+	body.WriteString(`</wsen:Enumerate>`)
+	env.WithBody(body.Bytes())
+
+	respBody, err := c.sendEnvelope(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("enumerate: %w", err)
+	}
+	return parseEnumeration(respBody)
+}
+
+// PullResources pulls the next batch of an enumeration started by
+// EnumerateResources (WS-Enumeration Pull).
+func (c *Client) PullResources(ctx context.Context, resourceURI, enumContext string, maxElements int) (*Enumeration, error) {
+	if maxElements <= 0 {
+		maxElements = 100
+	}
+	env := c.newResourceEnvelope(ctx, ActionPull, resourceURI, nil)
+	body, err := xml.Marshal(Pull{
+		Wsen:               NsEnumeration,
+		EnumerationContext: enumContext,
+		MaxElements:        maxElements,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal pull body: %w", err)
+	}
+	env.WithBody(body)
+
+	respBody, err := c.sendEnvelope(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("pull: %w", err)
+	}
+	return parseEnumeration(respBody)
+}
+
+// EnumerateAll enumerates all instances of resourceURI, pulling batches
+// until the end of the sequence.
+func (c *Client) EnumerateAll(ctx context.Context, resourceURI string, opts EnumerateOptions) ([][]byte, error) {
+	e, err := c.EnumerateResources(ctx, resourceURI, opts)
+	if err != nil {
+		return nil, err
+	}
+	items := e.Items
+	for !e.Done {
+		if e.Context == "" {
+			return nil, errors.New("wsman: enumeration has no context and no end of sequence")
+		}
+		if e, err = c.PullResources(ctx, resourceURI, e.Context, opts.MaxElements); err != nil {
+			return nil, err
+		}
+		items = append(items, e.Items...)
+	}
+	return items, nil
+}
+
+// parseEnumeration parses an EnumerateResponse or PullResponse envelope.
+func parseEnumeration(respBody []byte) (*Enumeration, error) {
+	content, err := bodyContent(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("parse enumeration response: %w", err)
+	}
+	var resp enumerationResponse
+	if err := xml.Unmarshal(content, &resp); err != nil {
+		return nil, fmt.Errorf("parse enumeration response: %w", err)
+	}
+	items, err := splitElements(resp.Items.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse enumeration items: %w", err)
+	}
+	return &Enumeration{
+		Items:   items,
+		Context: resp.EnumerationContext,
+		Done:    resp.EndOfSequence != nil,
+	}, nil
+}
+
+// bodyContent returns the content of the SOAP Body of an envelope, or nil
+// if the body is empty.
+func bodyContent(envelope []byte) ([]byte, error) {
+	var env struct {
+		Body struct {
+			Content []byte `xml:",innerxml"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(envelope, &env); err != nil {
+		return nil, err
+	}
+	content := bytes.TrimSpace(env.Body.Content)
+	if len(content) == 0 {
+		return nil, nil
+	}
+	return content, nil
+}
+
+// splitElements splits XML content into its top-level elements.
+func splitElements(content []byte) ([][]byte, error) {
+	var elements [][]byte
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	depth := 0
+	var start int64
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			return elements, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				start = offset
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				elements = append(elements, content[start:dec.InputOffset()])
+			}
+		}
+	}
+}
+
+// ParseInstance returns the properties of a resource instance as returned
+// by Get or enumerated: each child element's text by local name. Nil
+// properties (xsi:nil) are omitted; array properties keep their last value.
+func ParseInstance(instance []byte) (map[string]string, error) {
+	props := make(map[string]string)
+	dec := xml.NewDecoder(bytes.NewReader(instance))
+	depth := 0
+	var name string
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return props, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse instance: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 {
+				name = t.Name.Local
+				text.Reset()
+				for _, a := range t.Attr {
+					if a.Name.Local == "nil" && a.Value == "true" {
+						name = ""
+					}
+				}
+			}
+		case xml.CharData:
+			if depth == 2 {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if depth == 2 && name != "" {
+				props[name] = text.String()
+			}
+			depth--
+		}
+	}
+}
+
+// xmlEscape escapes s for XML text and attribute values.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
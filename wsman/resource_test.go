@@ -0,0 +1,164 @@
+package wsman
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+// newMockClient returns a client whose requests are answered by respond.
+func newMockClient(respond func(body string) string) *Client {
+	tr := transport.NewHTTPTransport()
+	tr.Client().Transport = &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(respond(string(body)))),
+			}, nil
+		},
+	}
+	return NewClient("http://server:5985/wsman", tr)
+}
+
+func soapBody(content string) string {
+	return `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body>` + content + `</s:Body></s:Envelope>`
+}
+
+const serviceInstance = `<p:Win32_Service xmlns:p="http://schemas.microsoft.com/wbem/wsman/1/wmi/root/cimv2/Win32_Service" ` +
+	`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+	`<p:Name>WinRM</p:Name><p:State>Running</p:State><p:Description xsi:nil="true"/></p:Win32_Service>`
+
+func TestResourceURI(t *testing.T) {
+	if got := ResourceURI("root/cimv2/Win32_Service"); got != ResourceURIWMIPrefix+"root/cimv2/Win32_Service" {
+		t.Errorf("ResourceURI(class path) = %q", got)
+	}
+	if got := ResourceURI(ResourceURIPowerShell); got != ResourceURIPowerShell {
+		t.Errorf("ResourceURI(full URI) = %q", got)
+	}
+}
+
+func TestClient_Get(t *testing.T) {
+	var request string
+	c := newMockClient(func(body string) string {
+		request = body
+		return soapBody(serviceInstance)
+	})
+
+	instance, err := c.Get(context.Background(), "root/cimv2/Win32_Service", map[string]string{"Name": "WinRM"})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	for _, want := range []string{ActionGet, ResourceURIWMIPrefix + "root/cimv2/Win32_Service", `Name="Name">WinRM<`} {
+		if !strings.Contains(request, want) {
+			t.Errorf("Get request missing %s:\n%s", want, request)
+		}
+	}
+
+	props, err := ParseInstance(instance)
+	if err != nil {
+		t.Fatalf("ParseInstance() error = %v", err)
+	}
+	if props["Name"] != "WinRM" || props["State"] != "Running" {
+		t.Errorf("ParseInstance() = %v", props)
+	}
+	if _, ok := props["Description"]; ok {
+		t.Error("ParseInstance() kept a nil property")
+	}
+}
+
+func TestClient_Invoke(t *testing.T) {
+	var request string
+	c := newMockClient(func(body string) string {
+		request = body
+		return soapBody(`<p:StopService_OUTPUT xmlns:p="x"><p:ReturnValue>0</p:ReturnValue></p:StopService_OUTPUT>`)
+	})
+
+	out, err := c.Invoke(context.Background(), "root/cimv2/Win32_Service", "StopService",
+		map[string]string{"Name": "Spooler"}, map[string]string{"Reason": "a<b"})
+	if err != nil {
+		t.Fatalf("Invoke() error = %v", err)
+	}
+	uri := ResourceURIWMIPrefix + "root/cimv2/Win32_Service"
+	for _, want := range []string{uri + "/StopService", `<p:StopService_INPUT xmlns:p="` + uri + `">`, `<p:Reason>a&lt;b</p:Reason>`} {
+		if !strings.Contains(request, want) {
+			t.Errorf("Invoke request missing %s:\n%s", want, request)
+		}
+	}
+	if props, _ := ParseInstance(out); props["ReturnValue"] != "0" {
+		t.Errorf("Invoke() output = %s", out)
+	}
+}
+
+func TestClient_EnumerateAll(t *testing.T) {
+	var requests []string
+	c := newMockClient(func(body string) string {
+		requests = append(requests, body)
+		if strings.Contains(body, ActionPull) {
+			return soapBody(`<n:PullResponse xmlns:n="` + NsEnumeration + `"><n:Items>` + serviceInstance +
+				`</n:Items><n:EndOfSequence/></n:PullResponse>`)
+		}
+		return soapBody(`<n:EnumerateResponse xmlns:n="` + NsEnumeration + `" xmlns:w="` + NsWsman + `">` +
+			`<n:EnumerationContext>ctx-1</n:EnumerationContext><w:Items>` + serviceInstance + serviceInstance +
+			`</w:Items></n:EnumerateResponse>`)
+	})
+
+	items, err := c.EnumerateAll(context.Background(), "root/cimv2/*", EnumerateOptions{
+		Filter:      "SELECT * FROM Win32_Service WHERE State = 'Running'",
+		MaxElements: 2,
+	})
+	if err != nil {
+		t.Fatalf("EnumerateAll() error = %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("EnumerateAll() returned %d items, want 3", len(items))
+	}
+	if string(items[0]) != serviceInstance {
+		t.Errorf("item = %s, want %s", items[0], serviceInstance)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("sent %d requests, want Enumerate and Pull", len(requests))
+	}
+	wantFilter := `<wsman:Filter Dialect="` + DialectWQL + `">SELECT * FROM Win32_Service WHERE State = &#39;Running&#39;</wsman:Filter>`
+	if !strings.Contains(requests[0], wantFilter) || !strings.Contains(requests[0], "<wsman:MaxElements>2<") {
+		t.Errorf("Enumerate request = %s", requests[0])
+	}
+	if !strings.Contains(requests[1], "ctx-1") {
+		t.Errorf("Pull request does not carry the enumeration context: %s", requests[1])
+	}
+}
+
+func TestClient_Identify(t *testing.T) {
+	var request string
+	c := newMockClient(func(body string) string {
+		request = body
+		return soapBody(`<wsmid:IdentifyResponse xmlns:wsmid="` + NsIdentity + `">` +
+			`<wsmid:ProtocolVersion>` + NsWsman + `</wsmid:ProtocolVersion>` +
+			`<wsmid:ProductVendor>Microsoft Corporation</wsmid:ProductVendor>` +
+			`<wsmid:ProductVersion>OS: 10.0.20348 SP: 0.0 Stack: 3.0</wsmid:ProductVersion>` +
+			`<wsmid:SecurityProfiles><wsmid:SecurityProfileName>http://schemas.dmtf.org/wbem/wsman/1/wsman/secprofile/http/spnego-kerberos</wsmid:SecurityProfileName></wsmid:SecurityProfiles>` +
+			`</wsmid:IdentifyResponse>`)
+	})
+
+	id, err := c.Identify(context.Background())
+	if err != nil {
+		t.Fatalf("Identify() error = %v", err)
+	}
+	if !strings.Contains(request, `Identify xmlns:wsmid="`+NsIdentity+`"`) {
+		t.Errorf("Identify request missing the Identify body:\n%s", request)
+	}
+	if id.ProtocolVersion != NsWsman || id.ProductVendor != "Microsoft Corporation" ||
+		id.ProductVersion != "OS: 10.0.20348 SP: 0.0 Stack: 3.0" || len(id.SecurityProfiles) != 1 {
+		t.Errorf("Identify() = %+v", id)
+	}
+
+	c = newMockClient(func(string) string { return soapBody("") })
+	if _, err := c.Identify(context.Background()); err == nil {
+		t.Error("Identify() without IdentifyResponse succeeded")
+	}
+}
@@ -0,0 +1,132 @@
+package wsman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+// ErrorOperationTimedOut is the WSMan error code of an operation that did
+// not complete within its OperationTimeout (ERROR_WSMAN_OPERATION_TIMEDOUT).
+const ErrorOperationTimedOut = 2150858793
+
+// RetryPolicy retries idempotent operations (Receive, Signal, Delete and
+// Get) after transient failures: network errors, HTTP 502, 503 and 504, and
+// faults with one of FaultCodes. Operations that change state, such as
+// Create, Command and Send, are never retried. This is separate from the
+// session-level reconnection in package client.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, including the first.
+	// Default: 3.
+	MaxAttempts int
+
+	// InitialDelay is the delay before the first retry; it doubles with
+	// every retry. Default: 200ms.
+	InitialDelay time.Duration
+
+	// MaxDelay caps the delay between retries. Default: 5s.
+	MaxDelay time.Duration
+
+	// FaultCodes are the WSMan error codes to retry.
+	// Default: ErrorOperationTimedOut.
+	FaultCodes []int
+}
+
+// withDefaults returns p with zero values replaced by the defaults.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = 200 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 5 * time.Second
+	}
+	if p.FaultCodes == nil {
+		p.FaultCodes = []int{ErrorOperationTimedOut}
+	}
+	return p
+}
+
+// retryable reports whether a failed request with action is worth
+// retrying.
+func (p RetryPolicy) retryable(action string, err error) bool {
+	if f, ok := AsFault(err); ok {
+		// A timed out Receive only means there was no output yet
+		if action == ActionReceive && f.IsTimeout() {
+			return false
+		}
+		return slices.Contains(p.FaultCodes, f.WSManCode)
+	}
+
+	var te *transport.TransportError
+	if errors.As(err, &te) {
+		switch te.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// Dial, read and write failures, but not TLS or URL errors
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// sendIdempotent sends env like sendEnvelope, retrying transient failures
+// as configured by ClientOptions.Retry. Retries keep the activity ID but
+// get a new MessageID.
+func (c *Client) sendIdempotent(ctx context.Context, env *Envelope) ([]byte, error) {
+	retry := c.Options().Retry
+	if retry == nil {
+		return c.sendEnvelope(ctx, env)
+	}
+	policy := retry.withDefaults()
+	var action string
+	if env.Header.Action != nil {
+		action = env.Header.Action.Value
+	}
+
+	delay := policy.InitialDelay
+	for attempt := 1; ; attempt++ {
+		respBody, err := c.sendEnvelope(ctx, env)
+		if err == nil || ctx.Err() != nil || !policy.retryable(action, err) {
+			return respBody, err
+		}
+		if attempt >= policy.MaxAttempts {
+			return nil, fmt.Errorf("after %d attempts: %w", attempt, err)
+		}
+
+		if logger := c.Options().Logger; logger != nil {
+			logger.WarnContext(ctx, "retrying WSMan request after transient failure",
+				"action", action, "attempt", attempt, "delay", delay, "error", err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		delay = min(2*delay, policy.MaxDelay)
+		c.telemetry().retried(ctx, action)
+		env.WithMessageID("uuid:" + strings.ToUpper(uuid.New().String()))
+	}
+}
@@ -0,0 +1,182 @@
+package wsman
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+// flakyClient returns a client whose first failures requests fail with
+// fail, and the counts of requests and distinct MessageIDs sent.
+func flakyClient(failures int, fail func() (*http.Response, error), opts ClientOptions) (*Client, *int, map[string]bool) {
+	requests := 0
+	messageIDs := make(map[string]bool)
+	tr := transport.NewHTTPTransport()
+	tr.Client().Transport = &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			_, rest, _ := strings.Cut(string(body), "<a:MessageID>")
+			id, _, _ := strings.Cut(rest, "<")
+			messageIDs[id] = true
+			requests++
+			if requests <= failures {
+				return fail()
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(soapBody(""))),
+			}, nil
+		},
+	}
+	return NewClientWithOptions("http://server:5985/wsman", tr, opts), &requests, messageIDs
+}
+
+func connectionReset() (*http.Response, error) {
+	return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+}
+
+func timedOutFault() (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusInternalServerError,
+		Body: io.NopCloser(strings.NewReader(soapBody(`<s:Fault><s:Code><s:Value>s:Receiver</s:Value>` +
+			`<s:Subcode><s:Value>w:TimedOut</s:Value></s:Subcode></s:Code>` +
+			`<s:Reason><s:Text>The WS-Management service cannot complete the operation within the time specified in OperationTimeout.</s:Text></s:Reason>` +
+			`<s:Detail><f:WSManFault xmlns:f="http://schemas.microsoft.com/wbem/wsman/1/wsmanfault" Code="2150858793"/></s:Detail>` +
+			`</s:Fault>`))),
+	}, nil
+}
+
+var fastRetry = &RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+
+var shellEPR = &EndpointReference{Selectors: []Selector{{Name: "ShellId", Value: "shell-1"}}}
+
+func TestRetry_TransientNetworkError(t *testing.T) {
+	c, requests, messageIDs := flakyClient(2, connectionReset, ClientOptions{Retry: fastRetry})
+
+	if err := c.Delete(context.Background(), shellEPR); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if *requests != 3 {
+		t.Errorf("requests = %d, want 3", *requests)
+	}
+	if len(messageIDs) != 3 {
+		t.Errorf("distinct MessageIDs = %d, want a new one per attempt", len(messageIDs))
+	}
+}
+
+func TestRetry_Logged(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	c, _, _ := flakyClient(1, connectionReset, ClientOptions{Retry: fastRetry, Logger: logger})
+
+	if err := c.Delete(context.Background(), shellEPR); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if out := buf.String(); strings.Count(out, "retrying WSMan request") != 1 || !strings.Contains(out, "attempt=1") {
+		t.Errorf("log = %q, want one retry of attempt 1", out)
+	}
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	c, requests, _ := flakyClient(10, connectionReset, ClientOptions{Retry: fastRetry})
+
+	err := c.Signal(context.Background(), shellEPR, "cmd-1", "terminate")
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("Signal() error = %v, want failure after 3 attempts", err)
+	}
+	if *requests != 3 {
+		t.Errorf("requests = %d, want 3", *requests)
+	}
+}
+
+func TestRetry_FaultCodes(t *testing.T) {
+	c, requests, _ := flakyClient(1, timedOutFault, ClientOptions{Retry: fastRetry})
+	if err := c.Signal(context.Background(), shellEPR, "cmd-1", "terminate"); err != nil {
+		t.Fatalf("Signal() error = %v", err)
+	}
+	if *requests != 2 {
+		t.Errorf("requests = %d, want 2", *requests)
+	}
+
+	// A timed out Receive is an empty result, not a failure
+	c, requests, _ = flakyClient(1, timedOutFault, ClientOptions{Retry: fastRetry})
+	if _, err := c.Receive(context.Background(), shellEPR, "cmd-1"); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if *requests != 1 {
+		t.Errorf("Receive requests = %d, want 1", *requests)
+	}
+}
+
+func TestRetry_NotRetried(t *testing.T) {
+	accessDenied := func() (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusUnauthorized, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+
+	tests := []struct {
+		name string
+		opts ClientOptions
+		fail func() (*http.Response, error)
+		call func(*Client) error
+	}{
+		{
+			name: "no policy",
+			fail: connectionReset,
+			call: func(c *Client) error { return c.Delete(context.Background(), shellEPR) },
+		},
+		{
+			name: "not idempotent",
+			opts: ClientOptions{Retry: fastRetry},
+			fail: connectionReset,
+			call: func(c *Client) error { return c.Send(context.Background(), shellEPR, "cmd-1", "stdin", []byte("x")) },
+		},
+		{
+			name: "unauthorized",
+			opts: ClientOptions{Retry: fastRetry},
+			fail: accessDenied,
+			call: func(c *Client) error { return c.Delete(context.Background(), shellEPR) },
+		},
+		{
+			name: "other fault code",
+			opts: ClientOptions{Retry: &RetryPolicy{InitialDelay: time.Millisecond, FaultCodes: []int{5}}},
+			fail: timedOutFault,
+			call: func(c *Client) error { return c.Delete(context.Background(), shellEPR) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, requests, _ := flakyClient(1, tt.fail, tt.opts)
+			if err := tt.call(c); err == nil {
+				t.Fatal("call succeeded, want the first failure")
+			}
+			if *requests != 1 {
+				t.Errorf("requests = %d, want 1", *requests)
+			}
+		})
+	}
+}
+
+func TestRetry_ContextCancelled(t *testing.T) {
+	c, _, _ := flakyClient(10, connectionReset, ClientOptions{Retry: &RetryPolicy{MaxAttempts: 5, InitialDelay: time.Hour}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var opErr *net.OpError
+	if err := c.Delete(ctx, shellEPR); !errors.As(err, &opErr) {
+		t.Fatalf("Delete() error = %v, want the last network error", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Delete() waited %v, want it to stop when the context ends", time.Since(start))
+	}
+}
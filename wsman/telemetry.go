@@ -0,0 +1,162 @@
+//go:build !psrp_nootel
+
+package wsman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+// InstrumentationName is the OpenTelemetry instrumentation scope of the
+// spans and metrics of this package.
+const InstrumentationName = "github.com/smnsjas/go-psrp/wsman"
+
+// TracerProvider is the OpenTelemetry TracerProvider of ClientOptions. In
+// programs built with psrp_nootel, which leave out OpenTelemetry, nothing
+// implements it.
+type TracerProvider = trace.TracerProvider
+
+// MeterProvider is the OpenTelemetry MeterProvider of ClientOptions.
+type MeterProvider = metric.MeterProvider
+
+// telemetry holds the OpenTelemetry instruments of a Client.
+type telemetry struct {
+	tracer      trace.Tracer
+	propagate   bool
+	duration    metric.Float64Histogram
+	sentBytes   metric.Int64Counter
+	recvBytes   metric.Int64Counter
+	retries     metric.Int64Counter
+	serverAttrs []attribute.KeyValue
+}
+
+// newTelemetry returns the instruments for opts, or nil if neither a
+// TracerProvider nor a MeterProvider is set.
+func newTelemetry(endpoint string, opts ClientOptions) *telemetry {
+	if opts.TracerProvider == nil && opts.MeterProvider == nil {
+		return nil
+	}
+	tp := opts.TracerProvider
+	if tp == nil {
+		tp = tracenoop.NewTracerProvider()
+	}
+	mp := opts.MeterProvider
+	if mp == nil {
+		mp = metricnoop.NewMeterProvider()
+	}
+
+	t := &telemetry{
+		tracer:    tp.Tracer(InstrumentationName),
+		propagate: opts.PropagateTraceContext,
+	}
+	if u, err := url.Parse(endpoint); err == nil {
+		t.serverAttrs = []attribute.KeyValue{attribute.String("server.address", u.Hostname())}
+	}
+
+	// Instrument errors only occur for invalid names; the instruments are
+	// then no-ops
+	meter := mp.Meter(InstrumentationName)
+	t.duration, _ = meter.Float64Histogram("wsman.client.request.duration",
+		metric.WithDescription("Duration of WSMan requests"), metric.WithUnit("s"))
+	t.sentBytes, _ = meter.Int64Counter("wsman.client.sent_bytes",
+		metric.WithDescription("SOAP bytes sent"), metric.WithUnit("By"))
+	t.recvBytes, _ = meter.Int64Counter("wsman.client.received_bytes",
+		metric.WithDescription("SOAP bytes received"), metric.WithUnit("By"))
+	t.retries, _ = meter.Int64Counter("wsman.client.retries",
+		metric.WithDescription("Retries of idempotent WSMan requests"))
+	return t
+}
+
+// operationName returns the short name of action, e.g. "Receive".
+func operationName(action string) string {
+	if action == "" {
+		return "Unknown"
+	}
+	return path.Base(action)
+}
+
+// startRequest starts the span of a request with action and, if enabled,
+// adds its trace context to env. end finishes the span and records the
+// metrics.
+func (t *telemetry) startRequest(ctx context.Context, env *Envelope, action string) (context.Context, func(sent, received, status int, err error)) {
+	if t == nil {
+		return ctx, func(int, int, int, error) {}
+	}
+
+	op := operationName(action)
+	attrs := append([]attribute.KeyValue{attribute.String("wsman.operation", op)}, t.serverAttrs...)
+	ctx, span := t.tracer.Start(ctx, "WSMan "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(attribute.String("wsman.action", action)))
+	if sc := span.SpanContext(); t.propagate && sc.IsValid() {
+		env.WithTraceParent(fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags()))
+	}
+	if env.Header.ActivityID != nil {
+		span.SetAttributes(attribute.String("wsman.activity_id", env.Header.ActivityID.Value))
+	}
+
+	start := time.Now()
+	return ctx, func(sent, received, status int, err error) {
+		if status != 0 {
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+			attrs = append(attrs, attribute.Int("http.response.status_code", status))
+		}
+		if f, ok := AsFault(err); ok && f.WSManCode != 0 {
+			span.SetAttributes(attribute.Int("wsman.fault_code", f.WSManCode))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			attrs = append(attrs, attribute.String("error.type", errorType(err)))
+		}
+		span.End()
+
+		set := metric.WithAttributes(attrs...)
+		t.duration.Record(ctx, time.Since(start).Seconds(), set)
+		t.sentBytes.Add(ctx, int64(sent), set)
+		t.recvBytes.Add(ctx, int64(received), set)
+	}
+}
+
+// retried records a retry of a request with action.
+func (t *telemetry) retried(ctx context.Context, action string) {
+	if t == nil {
+		return
+	}
+	attrs := append([]attribute.KeyValue{attribute.String("wsman.operation", operationName(action))}, t.serverAttrs...)
+	t.retries.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// errorType is the low-cardinality error.type attribute of err.
+func errorType(err error) string {
+	if f, ok := AsFault(err); ok {
+		if f.Subcode != "" {
+			return f.Subcode
+		}
+		return "fault"
+	}
+	var te *transport.TransportError
+	switch {
+	case errors.As(err, &te):
+		return "http"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "network"
+}
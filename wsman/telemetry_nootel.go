@@ -0,0 +1,31 @@
+//go:build psrp_nootel
+
+package wsman
+
+import "context"
+
+// TracerProvider stands in for the OpenTelemetry TracerProvider in
+// programs built with psrp_nootel. Nothing implements it, so
+// ClientOptions.TracerProvider is always nil.
+type TracerProvider interface {
+	noTracing()
+}
+
+// MeterProvider stands in for the OpenTelemetry MeterProvider in programs
+// built with psrp_nootel. Nothing implements it.
+type MeterProvider interface {
+	noMetrics()
+}
+
+// telemetry records nothing in programs built with psrp_nootel.
+type telemetry struct{}
+
+func newTelemetry(string, ClientOptions) *telemetry {
+	return nil
+}
+
+func (t *telemetry) startRequest(ctx context.Context, _ *Envelope, _ string) (context.Context, func(sent, received, status int, err error)) {
+	return ctx, func(int, int, int, error) {}
+}
+
+func (t *telemetry) retried(context.Context, string) {}
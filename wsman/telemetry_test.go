@@ -0,0 +1,77 @@
+//go:build !psrp_nootel
+
+package wsman
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+func TestTelemetry_PropagateTraceContext(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	tests := []struct {
+		name string
+		opts ClientOptions
+		want bool
+	}{
+		{"disabled", ClientOptions{}, false},
+		{"spans only", ClientOptions{TracerProvider: tracenoop.NewTracerProvider()}, false},
+		{"propagated", ClientOptions{TracerProvider: tracenoop.NewTracerProvider(), PropagateTraceContext: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, bodies := capturingClient(tt.opts)
+			if err := c.Delete(ctx, shellEPR); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			// The no-op tracer's span keeps the parent's context
+			header := `<tc:traceparent xmlns:tc="` + NsTraceContext + `" s:mustUnderstand="false">` +
+				`00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01</tc:traceparent>`
+			if got := strings.Contains((*bodies)[0], header); got != tt.want {
+				t.Errorf("request has traceparent = %v, want %v\n%s", got, tt.want, (*bodies)[0])
+			}
+		})
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	if got := operationName(ActionReceive); got != "Receive" {
+		t.Errorf("operationName(ActionReceive) = %q", got)
+	}
+	if got := operationName(""); got != "Unknown" {
+		t.Errorf("operationName(\"\") = %q", got)
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&Fault{Subcode: "w:InvalidSelectors"}, "w:InvalidSelectors"},
+		{&Fault{}, "fault"},
+		{&transport.TransportError{StatusCode: 503}, "http"},
+		{context.DeadlineExceeded, "timeout"},
+		{io.EOF, "network"},
+	}
+	for _, tt := range tests {
+		if got := errorType(tt.err); got != tt.want {
+			t.Errorf("errorType(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package wsman
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultTracePayloadLimit is how many characters of each stream payload
+// a trace keeps.
+const DefaultTracePayloadLimit = 256
+
+// Tracer receives every request a Client sends and its response, e.g. to
+// diagnose a failing exchange with a server. Set it with
+// ClientOptions.Tracer. The methods are called synchronously on the
+// request path and must not modify the events.
+type Tracer interface {
+	OnRequest(ctx context.Context, req *TraceRequest)
+	OnResponse(ctx context.Context, resp *TraceResponse)
+}
+
+// TraceRequest is a request as seen by a Tracer.
+type TraceRequest struct {
+	ActivityID string
+	Action     string
+	URL        string
+
+	// Body is the SOAP envelope with stream payloads truncated (see
+	// TruncatePayloads).
+	Body []byte
+}
+
+// TraceResponse is a response as seen by a Tracer.
+type TraceResponse struct {
+	ActivityID string
+	Action     string
+
+	// StatusCode is 0 if no response was received.
+	StatusCode int
+
+	// Header is the response header with credentials redacted (see
+	// RedactHeader).
+	Header http.Header
+
+	// Body is the SOAP envelope with stream payloads truncated.
+	Body []byte
+
+	Duration time.Duration
+
+	// Err is the transport error or SOAP fault, if any.
+	Err error
+}
+
+// sensitiveHeaders carry credentials or authentication tokens.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"WWW-Authenticate",
+	"Proxy-Authenticate",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// RedactHeader returns a copy of h with the values of authentication and
+// cookie headers replaced. The scheme of Authorization-style values is kept,
+// so "Negotiate oXcwdaADCgEA..." becomes "Negotiate [REDACTED]".
+func RedactHeader(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	out := h.Clone()
+	for _, name := range sensitiveHeaders {
+		values := out.Values(name)
+		for i, v := range values {
+			values[i] = redactHeaderValue(v)
+		}
+	}
+	return out
+}
+
+// redactHeaderValue keeps the scheme of "Scheme token" values.
+func redactHeaderValue(v string) string {
+	if scheme, _, found := strings.Cut(v, " "); found {
+		return scheme + " [REDACTED]"
+	}
+	if v == "" || !isAuthScheme(v) {
+		return "[REDACTED]"
+	}
+	return v
+}
+
+// isAuthScheme reports whether v is a bare scheme name such as "Negotiate",
+// which carries no token.
+func isAuthScheme(v string) bool {
+	for _, r := range v {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// payloadPattern matches the base64 content of stream, creationXml and
+// connectXml elements.
+var payloadPattern = regexp.MustCompile(`(<(?:[\w-]+:)?(?:Stream|creationXml|connectXml|connectResponseXml)\b[^>]*>)([^<]+)`)
+
+// TruncatePayloads returns body with the content of stream, creationXml and
+// connectXml elements cut to limit characters, followed by the original
+// length. These hold base64 PSRP fragments, which are long and of little
+// use in a trace. A limit of 0 uses DefaultTracePayloadLimit; a negative
+// limit returns body unchanged.
+func TruncatePayloads(body []byte, limit int) []byte {
+	if limit < 0 {
+		return body
+	}
+	if limit == 0 {
+		limit = DefaultTracePayloadLimit
+	}
+	return payloadPattern.ReplaceAllFunc(body, func(m []byte) []byte {
+		sub := payloadPattern.FindSubmatch(m)
+		content := sub[2]
+		if len(content) <= limit {
+			return m
+		}
+		out := append([]byte(nil), sub[1]...)
+		out = append(out, content[:limit]...)
+		return fmt.Appendf(out, "...(%d bytes)", len(content))
+	})
+}
+
+// SlogTracer is a Tracer that logs requests and responses to a
+// slog.Logger at debug level.
+type SlogTracer struct {
+	logger *slog.Logger
+}
+
+// NewSlogTracer creates a tracer that logs to logger, or to slog.Default()
+// if logger is nil.
+func NewSlogTracer(logger *slog.Logger) *SlogTracer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogTracer{logger: logger}
+}
+
+// OnRequest logs the request.
+func (t *SlogTracer) OnRequest(ctx context.Context, req *TraceRequest) {
+	t.logger.DebugContext(ctx, "wsman request",
+		"activity_id", req.ActivityID,
+		"action", req.Action,
+		"url", req.URL,
+		"body", string(req.Body))
+}
+
+// OnResponse logs the response.
+func (t *SlogTracer) OnResponse(ctx context.Context, resp *TraceResponse) {
+	attrs := []any{
+		"activity_id", resp.ActivityID,
+		"action", resp.Action,
+		"status", resp.StatusCode,
+		"duration", resp.Duration,
+	}
+	if len(resp.Header) > 0 {
+		attrs = append(attrs, "header", resp.Header)
+	}
+	if resp.Err != nil {
+		attrs = append(attrs, "error", resp.Err)
+	}
+	attrs = append(attrs, "body", string(resp.Body))
+	t.logger.DebugContext(ctx, "wsman response", attrs...)
+}
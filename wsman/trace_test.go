@@ -0,0 +1,113 @@
+package wsman
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+type recordingTracer struct {
+	requests  []*TraceRequest
+	responses []*TraceResponse
+}
+
+func (t *recordingTracer) OnRequest(_ context.Context, req *TraceRequest) {
+	t.requests = append(t.requests, req)
+}
+
+func (t *recordingTracer) OnResponse(_ context.Context, resp *TraceResponse) {
+	t.responses = append(t.responses, resp)
+}
+
+func TestRedactHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Type", "application/soap+xml")
+	h.Set("WWW-Authenticate", "Negotiate oYGcMIGZoAMKAQChCwYJKoZIgvcSAQICooGE")
+	h.Add("Proxy-Authenticate", "Basic")
+	h.Set("Authorization", "token-without-scheme1")
+	h.Set("Set-Cookie", "session=abc")
+
+	got := RedactHeader(h)
+	want := map[string]string{
+		"Content-Type":       "application/soap+xml",
+		"WWW-Authenticate":   "Negotiate [REDACTED]",
+		"Proxy-Authenticate": "Basic",
+		"Authorization":      "[REDACTED]",
+		"Set-Cookie":         "[REDACTED]",
+	}
+	for name, v := range want {
+		if got.Get(name) != v {
+			t.Errorf("%s = %q, want %q", name, got.Get(name), v)
+		}
+	}
+	if !strings.HasPrefix(h.Get("WWW-Authenticate"), "Negotiate oYG") {
+		t.Error("RedactHeader modified its argument")
+	}
+	if RedactHeader(nil) != nil {
+		t.Error("RedactHeader(nil) != nil")
+	}
+}
+
+func TestTruncatePayloads(t *testing.T) {
+	payload := strings.Repeat("QUJD", 100)
+	body := []byte(`<rsp:ReceiveResponse><rsp:Stream Name="stdout" CommandId="1">` + payload + `</rsp:Stream>` +
+		`<rsp:Stream Name="stdout" End="true"/><rsp:CommandState State="Done"/></rsp:ReceiveResponse>` +
+		`<creationXml xmlns="http://schemas.microsoft.com/powershell">short</creationXml>`)
+
+	got := string(TruncatePayloads(body, 8))
+	if !strings.Contains(got, `<rsp:Stream Name="stdout" CommandId="1">QUJDQUJD...(400 bytes)</rsp:Stream>`) {
+		t.Errorf("stream not truncated: %s", got)
+	}
+	if !strings.Contains(got, `<rsp:Stream Name="stdout" End="true"/><rsp:CommandState State="Done"/>`) {
+		t.Errorf("other elements changed: %s", got)
+	}
+	if !strings.Contains(got, `>short</creationXml>`) {
+		t.Errorf("short payload changed: %s", got)
+	}
+
+	if got := TruncatePayloads(body, 0); !strings.Contains(string(got), "...(400 bytes)") {
+		t.Errorf("default limit did not truncate: %s", got)
+	}
+	if got := TruncatePayloads(body, -1); string(got) != string(body) {
+		t.Errorf("negative limit changed body: %s", got)
+	}
+}
+
+func TestClient_Tracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	tr := transport.NewHTTPTransport()
+	tr.Client().Transport = &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			_, _ = io.ReadAll(req.Body)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Www-Authenticate": {"Negotiate oRQwEqADCgEAoQsGCSqGSIb3EgECAg=="}},
+				Body:       io.NopCloser(strings.NewReader(soapBody(""))),
+			}, nil
+		},
+	}
+	c := NewClientWithOptions("http://server:5985/wsman", tr, ClientOptions{Tracer: tracer, TracePayloadLimit: 4})
+
+	epr := &EndpointReference{Selectors: []Selector{{Name: "ShellId", Value: "shell-1"}}}
+	if err := c.Send(context.Background(), epr, "cmd-1", "stdin", []byte(strings.Repeat("x", 100))); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(tracer.requests) != 1 || len(tracer.responses) != 1 {
+		t.Fatalf("traced %d requests, %d responses, want 1 each", len(tracer.requests), len(tracer.responses))
+	}
+	req, resp := tracer.requests[0], tracer.responses[0]
+	if req.Action != ActionSend || req.ActivityID == "" || resp.ActivityID != req.ActivityID {
+		t.Errorf("request = %+v, response = %+v", req, resp)
+	}
+	if !strings.Contains(string(req.Body), "...(136 bytes)") {
+		t.Errorf("request payload not truncated: %s", req.Body)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("WWW-Authenticate") != "Negotiate [REDACTED]" {
+		t.Errorf("response = %+v", resp)
+	}
+}
@@ -0,0 +1,166 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ErrForbidden is returned when the server responds with 403 Forbidden.
+// Use errors.Is(err, ErrForbidden) to check for it.
+var ErrForbidden = errors.New("transport: access denied (403 Forbidden)")
+
+// maxErrorBody is the number of bytes of an error response body kept in a
+// TransportError.
+const maxErrorBody = 3000
+
+// maxErrorDetail is the length of the body summary in an error message.
+const maxErrorDetail = 300
+
+// TransportError is returned by Post for HTTP error responses (status 400
+// and up). Use errors.As to inspect it; errors.Is(err, ErrUnauthorized) and
+// errors.Is(err, ErrForbidden) still match 401 and 403 responses.
+type TransportError struct {
+	// StatusCode is the HTTP status code, e.g. 401.
+	StatusCode int
+
+	// Status is the HTTP status line, e.g. "401 Unauthorized".
+	Status string
+
+	// AuthSchemes are the authentication schemes the server offers in
+	// WWW-Authenticate (e.g. "Negotiate", "Kerberos"), most useful on 401.
+	AuthSchemes []string
+
+	// ContentType is the content type of the response body.
+	ContentType string
+
+	// Body is the response body (an HTML page or a SOAP fault), cut to
+	// 3000 bytes. Truncated is set if it was longer.
+	Body      string
+	Truncated bool
+}
+
+func newTransportError(resp *http.Response, body []byte) *TransportError {
+	e := &TransportError{
+		StatusCode:  resp.StatusCode,
+		Status:      resp.Status,
+		AuthSchemes: AuthSchemes(resp.Header),
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        string(body),
+	}
+	if e.Status == "" {
+		e.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	if len(e.Body) > maxErrorBody {
+		e.Body = strings.ToValidUTF8(e.Body[:maxErrorBody], "")
+		e.Truncated = true
+	}
+	return e
+}
+
+// Error implements the error interface. For statuses other than 401 and
+// 403 it includes the raw body, so the WSMan fault codes in it can be
+// matched.
+func (e *TransportError) Error() string {
+	var msg string
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		msg = ErrUnauthorized.Error()
+		if len(e.AuthSchemes) > 0 {
+			msg += "; server offers " + strings.Join(e.AuthSchemes, ", ")
+		}
+	case http.StatusForbidden:
+		msg = ErrForbidden.Error()
+	default:
+		body := e.Body
+		if e.Truncated {
+			body += "..."
+		}
+		return fmt.Sprintf("transport: HTTP %d: %s", e.StatusCode, body)
+	}
+	if detail := e.Detail(); detail != "" {
+		if len(detail) > maxErrorDetail {
+			detail = strings.ToValidUTF8(detail[:maxErrorDetail], "") + "..."
+		}
+		msg += ": " + detail
+	}
+	return msg
+}
+
+// Unwrap returns ErrUnauthorized for 401 and ErrForbidden for 403.
+func (e *TransportError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	}
+	return nil
+}
+
+var (
+	// soapTextPattern matches the fault reason and WSMan message of a SOAP fault.
+	soapTextPattern = regexp.MustCompile(`(?s)<(?:\w+:)?(?:Text|Message)\b[^>]*>(.*?)</(?:\w+:)?(?:Text|Message)>`)
+	// htmlDropPattern matches HTML elements whose content is not text.
+	htmlDropPattern = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)>`)
+	// htmlTitlePattern matches the title of an HTML page.
+	htmlTitlePattern = regexp.MustCompile(`(?is)<title>(.*?)</title>`)
+	tagPattern       = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// Detail returns a readable one-line summary of the body: the reason and
+// message of a SOAP fault, or the text of an HTML error page. It is empty
+// if the body has no text.
+func (e *TransportError) Detail() string {
+	body := e.Body
+	switch {
+	case strings.Contains(body, ":Fault") || strings.Contains(body, "<Fault"):
+		var texts []string
+		for _, m := range soapTextPattern.FindAllStringSubmatch(body, -1) {
+			if text := cleanText(m[1]); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		if len(texts) > 0 {
+			return strings.Join(texts, " ")
+		}
+	case strings.Contains(strings.ToLower(body), "<html"):
+		var title string
+		if m := htmlTitlePattern.FindStringSubmatch(body); m != nil {
+			title = cleanText(m[1])
+		}
+		text := cleanText(htmlDropPattern.ReplaceAllString(body, " "))
+		if title != "" && !strings.HasPrefix(text, title) {
+			text = title + ": " + text
+		}
+		return text
+	}
+	return cleanText(body)
+}
+
+// cleanText strips tags, decodes entities and collapses whitespace.
+func cleanText(s string) string {
+	s = html.UnescapeString(tagPattern.ReplaceAllString(s, " "))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// AuthSchemes returns the schemes offered in WWW-Authenticate headers,
+// without their parameters or tokens.
+func AuthSchemes(h http.Header) []string {
+	var schemes []string
+	seen := make(map[string]bool)
+	for _, value := range h.Values("WWW-Authenticate") {
+		for _, challenge := range strings.Split(value, ",") {
+			scheme, _, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+			if scheme == "" || strings.ContainsAny(scheme, `="`) || seen[strings.ToLower(scheme)] {
+				continue
+			}
+			seen[strings.ToLower(scheme)] = true
+			schemes = append(schemes, scheme)
+		}
+	}
+	return schemes
+}
@@ -0,0 +1,319 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrProxyAuth is returned when the proxy rejects the proxy credentials.
+// Use errors.Is(err, ErrProxyAuth) to check for it.
+var ErrProxyAuth = errors.New("transport: proxy authentication failed")
+
+// proxyDialer connects to targets through a proxy itself, instead of
+// leaving the proxy to http.Transport, so that every connection is a tunnel
+// to the target: HTTP proxies get a CONNECT for http:// targets too, not
+// just for https:// ones. NTLM and Kerberos authenticate the connection, not
+// the request; a forwarding proxy may send the requests of one handshake
+// over different upstream connections, and the handshake then fails.
+type proxyDialer struct {
+	proxy func(*http.Request) (*url.URL, error)
+	t     *HTTPTransport // for proxyAuth and dial, which may be set after the proxy
+}
+
+// install makes transport dial through d.
+func (d *proxyDialer) install(transport *http.Transport) {
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.dial(ctx, "http", network, addr)
+	}
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := d.dial(ctx, "https", network, addr)
+		if err != nil {
+			return nil, err
+		}
+		var cfg *tls.Config
+		if transport.TLSClientConfig != nil {
+			cfg = transport.TLSClientConfig.Clone()
+		} else {
+			cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// dial connects to addr, through the proxy for a request of the given
+// scheme to addr, if there is one.
+func (d *proxyDialer) dial(ctx context.Context, scheme, network, addr string) (net.Conn, error) {
+	proxyURL, err := d.proxy(&http.Request{URL: &url.URL{Scheme: scheme, Host: addr}})
+	if err != nil {
+		return nil, fmt.Errorf("transport: proxy: %w", err)
+	}
+	if proxyURL == nil {
+		return d.t.dialContext(ctx, network, addr)
+	}
+
+	user := proxyURL.User
+	if d.t.proxyAuth != nil {
+		user = d.t.proxyAuth
+	}
+
+	conn, err := d.t.dialContext(ctx, "tcp", proxyAddr(proxyURL))
+	if err != nil {
+		return nil, fmt.Errorf("transport: connect to proxy %s: %w", proxyURL.Host, err)
+	}
+
+	// The proxy handshake must not outlive ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+
+	switch proxyURL.Scheme {
+	case "http", "https":
+		conn, err = dialConnect(ctx, conn, proxyURL, user, addr)
+	case "socks5", "socks5h":
+		err = dialSOCKS5(ctx, conn, proxyURL.Scheme == "socks5h", user, addr)
+	default:
+		err = fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = conn.Close()
+		if errors.Is(err, ErrProxyAuth) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("transport: proxy %s: %w", proxyURL.Host, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// proxyAddr returns the host:port of a proxy URL, adding the default port.
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// dialConnect opens a CONNECT tunnel to addr through an HTTP(S) proxy
+// connection. Proxy credentials are sent with Basic authentication.
+func dialConnect(ctx context.Context, conn net.Conn, proxyURL *url.URL, user *url.Userinfo, addr string) (net.Conn, error) {
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return conn, err
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return conn, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return conn, err
+	}
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return conn, fmt.Errorf("%w (%s)", ErrProxyAuth, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return conn, fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		// Do not lose bytes the target sent right after the proxy's reply
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose first bytes were read into r.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// SOCKS5 protocol values (RFC 1928, RFC 1929).
+const (
+	socks5Version      = 0x05
+	socks5NoAuth       = 0x00
+	socks5UserPass     = 0x02
+	socks5NoAcceptable = 0xff
+	socks5Connect      = 0x01
+	socks5IPv4         = 0x01
+	socks5Domain       = 0x03
+	socks5IPv6         = 0x04
+)
+
+// socks5Replies describes the SOCKS5 reply codes.
+var socks5Replies = map[byte]string{
+	0x01: "general failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// dialSOCKS5 asks a SOCKS5 proxy connection to connect to addr. With
+// remoteDNS (socks5h) the proxy resolves the host name; otherwise it is
+// resolved here.
+func dialSOCKS5(ctx context.Context, conn net.Conn, remoteDNS bool, user *url.Userinfo, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port in %q", addr)
+	}
+
+	methods := []byte{socks5NoAuth}
+	if user != nil {
+		methods = append(methods, socks5UserPass)
+	}
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("not a SOCKS5 proxy (version %d)", reply[0])
+	}
+	switch reply[1] {
+	case socks5NoAuth:
+	case socks5UserPass:
+		if user == nil {
+			return fmt.Errorf("%w (SOCKS5 proxy requires a username and password)", ErrProxyAuth)
+		}
+		password, _ := user.Password()
+		if len(user.Username()) > 255 || len(password) > 255 {
+			return errors.New("SOCKS5 username and password must be at most 255 bytes")
+		}
+		msg := []byte{0x01, byte(len(user.Username()))}
+		msg = append(msg, user.Username()...)
+		msg = append(msg, byte(len(password)))
+		msg = append(msg, password...)
+		if _, err := conn.Write(msg); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return fmt.Errorf("%w (SOCKS5 proxy rejected the username and password)", ErrProxyAuth)
+		}
+	case socks5NoAcceptable:
+		return fmt.Errorf("%w (SOCKS5 proxy accepts none of the offered authentication methods)", ErrProxyAuth)
+	default:
+		return fmt.Errorf("SOCKS5 proxy chose unsupported authentication method %d", reply[1])
+	}
+
+	req := []byte{socks5Version, socks5Connect, 0x00}
+	ip := net.ParseIP(host)
+	if ip == nil && !remoteDNS {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			return err
+		}
+		ip = ips[0]
+	}
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q is too long for SOCKS5", host)
+		}
+		req = append(req, socks5Domain, byte(len(host)))
+		req = append(req, host...)
+	case ip.To4() != nil:
+		req = append(req, socks5IPv4)
+		req = append(req, ip.To4()...)
+	default:
+		req = append(req, socks5IPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// Reply: version, status, reserved, bound address type, address, port
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0x00 {
+		msg, ok := socks5Replies[head[1]]
+		if !ok {
+			msg = fmt.Sprintf("reply code %d", head[1])
+		}
+		return fmt.Errorf("SOCKS5 connect to %s: %s", addr, msg)
+	}
+	var skip int
+	switch head[3] {
+	case socks5IPv4:
+		skip = net.IPv4len + 2
+	case socks5IPv6:
+		skip = net.IPv6len + 2
+	case socks5Domain:
+		if _, err := io.ReadFull(conn, head[:1]); err != nil {
+			return err
+		}
+		skip = int(head[0]) + 2
+	default:
+		return fmt.Errorf("SOCKS5 reply has unknown address type %d", head[3])
+	}
+	_, err = io.CopyN(io.Discard, conn, int64(skip))
+	return err
+}
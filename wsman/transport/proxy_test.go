@@ -0,0 +1,248 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeProxy is an HTTP CONNECT or SOCKS5 proxy that forwards every tunnel
+// to target and records what it was asked.
+type fakeProxy struct {
+	ln     net.Listener
+	target string
+	socks  bool
+	auth   string // required Proxy-Authorization, or SOCKS5 "user:pass"
+
+	mu      sync.Mutex
+	tunnels []string // requested target addresses
+}
+
+func newFakeProxy(t *testing.T, target string, socks bool, auth string) *fakeProxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProxy{ln: ln, target: target, socks: socks, auth: auth}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *fakeProxy) Tunnels() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.tunnels...)
+}
+
+func (p *fakeProxy) serve(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+
+	var addr string
+	var ok bool
+	if p.socks {
+		addr, ok = p.handshakeSOCKS5(br, conn)
+	} else {
+		addr, ok = p.handshakeConnect(br, conn)
+	}
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	p.tunnels = append(p.tunnels, addr)
+	p.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	go func() { _, _ = io.Copy(upstream, br) }()
+	_, _ = io.Copy(conn, upstream)
+}
+
+func (p *fakeProxy) handshakeConnect(br *bufio.Reader, conn net.Conn) (string, bool) {
+	req, err := http.ReadRequest(br)
+	if err != nil || req.Method != http.MethodConnect {
+		_, _ = io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+		return "", false
+	}
+	if p.auth != "" && req.Header.Get("Proxy-Authorization") != p.auth {
+		_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+		return "", false
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	return req.Host, true
+}
+
+func (p *fakeProxy) handshakeSOCKS5(br *bufio.Reader, conn net.Conn) (string, bool) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return "", false
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(br, methods); err != nil {
+		return "", false
+	}
+	if p.auth == "" {
+		_, _ = conn.Write([]byte{5, 0})
+	} else {
+		_, _ = conn.Write([]byte{5, 2})
+		var creds []string
+		_, _ = br.ReadByte() // sub-negotiation version
+		for range 2 {
+			n, _ := br.ReadByte()
+			b := make([]byte, n)
+			_, _ = io.ReadFull(br, b)
+			creds = append(creds, string(b))
+		}
+		if strings.Join(creds, ":") != p.auth {
+			_, _ = conn.Write([]byte{1, 1})
+			return "", false
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(br, req); err != nil {
+		return "", false
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		b := make([]byte, 4)
+		_, _ = io.ReadFull(br, b)
+		host = net.IP(b).String()
+	case 3:
+		n, _ := br.ReadByte()
+		b := make([]byte, n)
+		_, _ = io.ReadFull(br, b)
+		host = string(b)
+	default:
+		return "", false
+	}
+	port := make([]byte, 2)
+	_, _ = io.ReadFull(br, port)
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), true
+}
+
+// TestProxy_HTTPTargetTunneled verifies that plain-HTTP requests go through
+// an HTTP proxy in a CONNECT tunnel, so the requests of a connection-based
+// handshake stay on one upstream connection.
+func TestProxy_HTTPTargetTunneled(t *testing.T) {
+	var mu sync.Mutex
+	remotes := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remotes[r.RemoteAddr] = true
+		mu.Unlock()
+		_, _ = w.Write([]byte("<ok/>"))
+	}))
+	defer server.Close()
+
+	proxy := newFakeProxy(t, server.Listener.Addr().String(), false, "")
+	tr := NewHTTPTransport(WithProxy("http://" + proxy.ln.Addr().String()))
+
+	for range 3 {
+		if _, err := tr.Post(context.Background(), "http://winrm01.example.com:5985/wsman", []byte("<test/>")); err != nil {
+			t.Fatalf("Post() error = %v", err)
+		}
+	}
+
+	if got := proxy.Tunnels(); len(got) != 1 || got[0] != "winrm01.example.com:5985" {
+		t.Errorf("tunnels = %v, want one to winrm01.example.com:5985", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(remotes) != 1 {
+		t.Errorf("server saw %d connections, want 1", len(remotes))
+	}
+}
+
+// TestProxy_Auth verifies proxy credentials from the URL and WithProxyAuth.
+func TestProxy_Auth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<ok/>"))
+	}))
+	defer server.Close()
+
+	// "proxyuser:s3cret"
+	proxy := newFakeProxy(t, server.Listener.Addr().String(), false, "Basic cHJveHl1c2VyOnMzY3JldA==")
+	addr := proxy.ln.Addr().String()
+
+	tests := []struct {
+		name    string
+		opts    []HTTPTransportOption
+		wantErr error
+	}{
+		{"no credentials", []HTTPTransportOption{WithProxy("http://" + addr)}, ErrProxyAuth},
+		{"credentials in URL", []HTTPTransportOption{WithProxy("http://proxyuser:s3cret@" + addr)}, nil},
+		{"WithProxyAuth", []HTTPTransportOption{WithProxy("http://" + addr), WithProxyAuth("proxyuser", "s3cret")}, nil},
+		{"WithProxyAuth overrides URL", []HTTPTransportOption{WithProxy("http://proxyuser:wrong@" + addr), WithProxyAuth("proxyuser", "s3cret")}, nil},
+		{"wrong password", []HTTPTransportOption{WithProxyAuth("proxyuser", "wrong"), WithProxy("http://" + addr)}, ErrProxyAuth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewHTTPTransport(tt.opts...)
+			_, err := tr.Post(context.Background(), "http://winrm01.example.com:5985/wsman", []byte("<test/>"))
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Post() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestProxy_SOCKS5 verifies HTTPS through a SOCKS5 proxy with
+// username/password authentication and remote name resolution.
+func TestProxy_SOCKS5(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<ok/>"))
+	}))
+	defer server.Close()
+
+	proxy := newFakeProxy(t, server.Listener.Addr().String(), true, "bastion:s3cret")
+	tr := NewHTTPTransport(
+		WithInsecureSkipVerify(true),
+		WithProxy("socks5h://"+proxy.ln.Addr().String()),
+		WithProxyAuth("bastion", "s3cret"),
+	)
+
+	resp, err := tr.Post(context.Background(), "https://winrm01.example.com:5986/wsman", []byte("<test/>"))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if string(resp) != "<ok/>" {
+		t.Errorf("response = %q", resp)
+	}
+	if got := proxy.Tunnels(); len(got) != 1 || got[0] != "winrm01.example.com:5986" {
+		t.Errorf("tunnels = %v, want one to winrm01.example.com:5986", got)
+	}
+
+	bad := NewHTTPTransport(WithProxy("socks5h://bastion:wrong@" + proxy.ln.Addr().String()))
+	if _, err := bad.Post(context.Background(), "https://winrm01.example.com:5986/wsman", nil); !errors.Is(err, ErrProxyAuth) {
+		t.Errorf("Post() with wrong password error = %v, want ErrProxyAuth", err)
+	}
+}
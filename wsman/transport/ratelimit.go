@@ -0,0 +1,143 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter paces traffic, e.g. to keep bulk operations from saturating
+// a WAN link shared with production traffic. Implementations must be safe
+// for concurrent use.
+type RateLimiter interface {
+	// WaitN blocks until n bytes may pass, or until ctx ends.
+	WaitN(ctx context.Context, n int) error
+}
+
+// TokenBucket is a RateLimiter that lets through a steady number of bytes
+// per second, with bursts of up to its capacity. It starts empty, so the
+// first bytes are paced too.
+type TokenBucket struct {
+	rate       float64 // bytes per second
+	capacity   float64 // max burst bytes
+	tokens     float64
+	lastRefill time.Time
+	mu         sync.Mutex
+}
+
+// NewTokenBucket creates a TokenBucket that allows bytesPerSecond, with
+// bursts of up to burst bytes. A burst below one byte is one second's
+// worth.
+func NewTokenBucket(bytesPerSecond, burst float64) *TokenBucket {
+	if burst < 1 {
+		burst = bytesPerSecond
+	}
+	return &TokenBucket{
+		rate:       bytesPerSecond,
+		capacity:   max(burst, 1),
+		tokens:     0, // Start EMPTY (Strict Pacing / Slow Start)
+		lastRefill: time.Now(),
+	}
+}
+
+// WaitN blocks until n bytes can be consumed, or until ctx ends. Requests
+// larger than the capacity are paced in capacity-sized parts. A rate of
+// zero or less does not limit.
+func (tb *TokenBucket) WaitN(ctx context.Context, n int) error {
+	if tb.rate <= 0 {
+		return nil
+	}
+	for remaining := float64(n); ; {
+		needed := min(remaining, tb.capacity)
+		wait := tb.reserve(needed)
+		if wait == 0 {
+			remaining -= needed
+			if remaining <= 0 {
+				return nil
+			}
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve takes needed tokens and returns 0, or returns how long to wait
+// until there are enough.
+func (tb *TokenBucket) reserve(needed float64) time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(tb.lastRefill).Seconds()
+	tb.tokens += elapsed * tb.rate
+	if tb.tokens > tb.capacity {
+		tb.tokens = tb.capacity
+	}
+	tb.lastRefill = now
+
+	if tb.tokens >= needed {
+		tb.tokens -= needed
+		return 0
+	}
+
+	// Not enough tokens, sleep until we have enough
+	missing := needed - tb.tokens
+	wait := time.Duration(missing / tb.rate * float64(time.Second))
+	if wait < time.Millisecond {
+		wait = time.Millisecond
+	}
+	return wait
+}
+
+// WithRateLimiter paces every request and response body through limiter,
+// so all WSMan traffic of the transport shares its budget. A nil limiter
+// keeps the transport unlimited.
+func WithRateLimiter(limiter RateLimiter) HTTPTransportOption {
+	return func(t *HTTPTransport) {
+		t.limiter = limiter
+	}
+}
+
+// RateLimitedReadWriter paces the reads and writes of a stream transport,
+// such as a Hyper-V socket or an SSH channel, through a RateLimiter.
+type RateLimitedReadWriter struct {
+	ctx     context.Context
+	rw      io.ReadWriter
+	limiter RateLimiter
+}
+
+// NewRateLimitedReadWriter returns rw with its reads and writes paced by
+// limiter. ctx is the context of the connection: when it ends, waits for
+// the budget fail with its error, so closing the connection does not wait
+// for the limiter.
+func NewRateLimitedReadWriter(ctx context.Context, rw io.ReadWriter, limiter RateLimiter) *RateLimitedReadWriter {
+	return &RateLimitedReadWriter{ctx: ctx, rw: rw, limiter: limiter}
+}
+
+// Read reads from the stream, then waits until the bytes read fit in the
+// budget, so the peer is slowed by flow control.
+func (r *RateLimitedReadWriter) Read(p []byte) (int, error) {
+	n, err := r.rw.Read(p)
+	if n > 0 {
+		if werr := r.limiter.WaitN(r.ctx, n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
+
+// Write waits until p fits in the budget, then writes it.
+func (r *RateLimitedReadWriter) Write(p []byte) (int, error) {
+	if err := r.limiter.WaitN(r.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return r.rw.Write(p)
+}
@@ -0,0 +1,165 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestTokenBucket_RateLimit verifies that the token bucket enforces the rate limit.
+func TestTokenBucket_RateLimit(t *testing.T) {
+	rate := 1024.0     // 1024 bytes/sec
+	capacity := 1024.0 // Capacity must be >= needed for a single wait
+	tb := NewTokenBucket(rate, capacity)
+
+	start := time.Now()
+	needed := 512
+	if err := tb.WaitN(context.Background(), needed); err != nil {
+		t.Fatalf("WaitN() error = %v", err)
+	}
+	elapsed := time.Since(start)
+
+	// Since it starts empty, the first WaitN(needed) should block until 'needed' tokens are generated.
+	// rate = 1024 tokens/sec. Needed = 512.
+	// Expected delay = 512 / 1024 = 0.5s.
+	expectedDelay := 500 * time.Millisecond
+	margin := 100 * time.Millisecond // Allow margin for OS scheduling and test execution time
+
+	if elapsed < expectedDelay-margin {
+		t.Errorf("Rate limit too fast. Elapsed: %v, Expected at least: ~%v", elapsed, expectedDelay-margin)
+	}
+}
+
+// TestTokenBucket_SlowStart verifies that the bucket starts empty (0 tokens).
+func TestTokenBucket_SlowStart(t *testing.T) {
+	tb := NewTokenBucket(1000, 1000)
+	if tb.tokens != 0 {
+		t.Errorf("TokenBucket should start empty (0 tokens), got %f", tb.tokens)
+	}
+}
+
+// TestTokenBucket_Burst verifies capacity capping.
+func TestTokenBucket_Burst(t *testing.T) {
+	rate := 10000.0
+	capacity := 100.0
+	tb := NewTokenBucket(rate, capacity)
+
+	// Wait long enough to overfill
+	time.Sleep(100 * time.Millisecond)
+
+	// WaitN(0) triggers the refill logic.
+	if err := tb.WaitN(context.Background(), 0); err != nil {
+		t.Fatalf("WaitN() error = %v", err)
+	}
+
+	if tb.tokens > capacity {
+		t.Errorf("Tokens %f exceeded capacity %f", tb.tokens, capacity)
+	}
+	// Should be close to capacity (full)
+	if tb.tokens < capacity-1.0 {
+		t.Errorf("Tokens %f should be full (capacity %f)", tb.tokens, capacity)
+	}
+}
+
+// TestTokenBucket_LargerThanCapacity verifies that a request larger than the
+// burst is paced in parts instead of waiting forever.
+func TestTokenBucket_LargerThanCapacity(t *testing.T) {
+	tb := NewTokenBucket(10000, 100)
+
+	start := time.Now()
+	if err := tb.WaitN(context.Background(), 2000); err != nil {
+		t.Fatalf("WaitN() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("WaitN(2000) at 10000 B/s took %v, want about 200ms", elapsed)
+	}
+}
+
+// TestTokenBucket_ContextCancelled verifies that a wait ends with its context.
+func TestTokenBucket_ContextCancelled(t *testing.T) {
+	tb := NewTokenBucket(1, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := tb.WaitN(ctx, 100); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitN() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// countingLimiter records the bytes it is asked to pace.
+type countingLimiter struct {
+	bytes atomic.Int64
+}
+
+func (l *countingLimiter) WaitN(_ context.Context, n int) error {
+	l.bytes.Add(int64(n))
+	return nil
+}
+
+// TestHTTPTransport_WithRateLimiter verifies that requests and responses are
+// paced through the limiter.
+func TestHTTPTransport_WithRateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("r", 300)))
+	}))
+	defer server.Close()
+
+	limiter := &countingLimiter{}
+	tr := NewHTTPTransport(WithRateLimiter(limiter))
+	if _, err := tr.Post(context.Background(), server.URL, []byte(strings.Repeat("q", 200))); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if got := limiter.bytes.Load(); got != 500 {
+		t.Errorf("paced %d bytes, want 500", got)
+	}
+}
+
+// TestRateLimitedReadWriter verifies that reads and writes are paced.
+func TestRateLimitedReadWriter(t *testing.T) {
+	limiter := &countingLimiter{}
+	var buf bytes.Buffer
+	rw := NewRateLimitedReadWriter(context.Background(), &buf, limiter)
+
+	if _, err := rw.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if _, err := rw.Read(make([]byte, 3)); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if got := limiter.bytes.Load(); got != 8 {
+		t.Errorf("paced %d bytes, want 8", got)
+	}
+}
+
+// TestRateLimitedReadWriter_ContextEnds verifies that waits end with the
+// context of the connection.
+func TestRateLimitedReadWriter_ContextEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var buf bytes.Buffer
+	rw := NewRateLimitedReadWriter(ctx, &buf, NewTokenBucket(1, 1))
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := rw.Write([]byte("a long write at one byte per second"))
+		errCh <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Write() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write() still waiting after the context ended")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %d bytes after the context ended", buf.Len())
+	}
+}
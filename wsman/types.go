@@ -8,6 +8,10 @@ type EndpointReference struct {
 	Address     string     `xml:"Address"`
 	ResourceURI string     `xml:"ReferenceParameters>ResourceURI"`
 	Selectors   []Selector `xml:"ReferenceParameters>SelectorSet>Selector"`
+
+	// Identifier is the WS-Eventing subscription identifier of a
+	// subscription manager EPR.
+	Identifier string `xml:"ReferenceParameters>Identifier"`
 }
 
 // Selector represents a WS-Management selector.
@@ -34,6 +38,7 @@ type Delivery struct {
 type Filter struct {
 	Dialect string `xml:"Dialect,attr"` // e.g. http://schemas.microsoft.com/wbem/wsman/1/WQL
 	Query   string `xml:",chardata"`
+	XML     string `xml:",innerxml"` // XML filter content, e.g. for DialectEventQuery
 }
 
 // SubscribeResponse represents the response to a Subscribe request.
@@ -50,6 +55,19 @@ type Unsubscribe struct {
 	Wse     string   `xml:"xmlns:wse,attr"`
 }
 
+// Renew represents the body of a Renew request.
+type Renew struct {
+	XMLName xml.Name `xml:"wse:Renew"`
+	Wse     string   `xml:"xmlns:wse,attr"`
+	Expires string   `xml:"wse:Expires,omitempty"` // ISO 8601 Duration (e.g. PT10M)
+}
+
+// RenewResponse represents the response to a Renew request.
+type RenewResponse struct {
+	XMLName xml.Name `xml:"RenewResponse"`
+	Expires string   `xml:"Expires"`
+}
+
 // Pull represents the body of a Pull request (Enumeration or Eventing).
 type Pull struct {
 	XMLName            xml.Name `xml:"wsen:Pull"`
@@ -0,0 +1,34 @@
+// Package wsmantest provides a fake WinRM server for tests, so that code
+// built on wsman, winrs or client can be tested without a Windows host.
+//
+// NewServer serves shells whose behavior a Script decides: it implements
+// Create, Command, Send, Receive, Signal and Delete, and calls the Script
+// with what the client sent. Commands answer by writing output and
+// exiting:
+//
+//	srv := wsmantest.NewServer(wsmantest.Script{
+//	    Command: func(cmd *wsmantest.Command, command string, args []string) error {
+//	        cmd.Write(wsman.StreamStdout, []byte("hello\r\n"))
+//	        cmd.Exit(0)
+//	        return nil
+//	    },
+//	})
+//	defer srv.Close()
+//
+//	c := wsman.NewClient(srv.Endpoint(), transport.NewHTTPTransport())
+//
+// The PSRP messages of PowerShell shells are easier recorded than
+// scripted. A Recorder, set as the Tracer of a client that talks to a
+// real server, captures the exchanges into a Fixture, with the server's
+// host name and any other given strings masked:
+//
+//	rec := wsmantest.NewRecorder("CONTOSO", "alice")
+//	cfg.WSManOptions.Tracer = rec
+//	cfg.WSManOptions.TracePayloadLimit = -1 // keep payloads whole
+//	// ... run the commands to record ...
+//	err := rec.Fixture().Save("testdata/get-service.json")
+//
+// NewReplayServer then answers a client with the recorded responses,
+// mapping the shell, command and RunspacePool IDs of the recording to
+// those of the client, so tests run the same code paths offline.
+package wsmantest
@@ -0,0 +1,75 @@
+package wsmantest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Fixture is a recorded conversation of clients with a server, in the
+// order the responses came.
+type Fixture struct {
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Exchange is a request and the response of the server, as whole SOAP
+// envelopes.
+type Exchange struct {
+	Action     string `json:"action"`
+	Request    string `json:"request"`
+	StatusCode int    `json:"status_code"`
+	Response   string `json:"response"`
+}
+
+// LoadFixture reads a fixture saved with Fixture.Save.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- test fixtures are given by the caller
+	if err != nil {
+		return nil, fmt.Errorf("wsmantest: %w", err)
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("wsmantest: parse fixture %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Save writes the fixture to path as indented JSON.
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("wsmantest: %w", err)
+	}
+	return nil
+}
+
+// payloadPattern matches the base64 content of the elements that carry
+// stream data and PSRP fragments.
+var payloadPattern = regexp.MustCompile(`(<(?:[\w-]+:)?(?:Stream|creationXml|connectXml|connectResponseXml|Arguments)\b[^>]*>)([^<]+)`)
+
+// rewriteEnvelope returns envelope with text applied to its markup and
+// data to the decoded content of its payloads, which are encoded again.
+// Content that is not base64 (the arguments of a cmd shell) is markup.
+func rewriteEnvelope(envelope string, text func(string) string, data func([]byte) []byte) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range payloadPattern.FindAllStringSubmatchIndex(envelope, -1) {
+		start, end := m[4], m[5]
+		b.WriteString(text(envelope[last:start]))
+		content := envelope[start:end]
+		if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content)); err == nil {
+			b.WriteString(base64.StdEncoding.EncodeToString(data(decoded)))
+		} else {
+			b.WriteString(text(content))
+		}
+		last = end
+	}
+	b.WriteString(text(envelope[last:]))
+	return b.String()
+}
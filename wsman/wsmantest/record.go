@@ -0,0 +1,153 @@
+package wsmantest
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+// messageIDPattern and relatesToPattern find the IDs that pair a response
+// with its request.
+var (
+	messageIDPattern = regexp.MustCompile(`<(?:[\w-]+:)?MessageID>([^<]+)<`)
+	relatesToPattern = regexp.MustCompile(`<(?:[\w-]+:)?RelatesTo>([^<]+)<`)
+)
+
+// Recorder is a wsman.Tracer that records the exchanges of a client into
+// a Fixture. Set it as ClientOptions.Tracer with a TracePayloadLimit of -1:
+// truncated payloads cannot be replayed.
+//
+// Recorded envelopes are sanitized: the host name of the endpoint and the
+// strings given to NewRecorder are masked with X's wherever they appear,
+// also inside stream payloads, matching case-insensitively. Masks keep the
+// length of what they hide, so the lengths in PSRP fragments stay right.
+// Authentication headers are never recorded.
+type Recorder struct {
+	mu        sync.Mutex
+	redact    []string
+	mask      *regexp.Regexp
+	pending   []pendingRequest
+	exchanges []Exchange
+}
+
+// pendingRequest is a request waiting for its response.
+type pendingRequest struct {
+	activityID string
+	action     string
+	messageID  string
+	body       string
+}
+
+// NewRecorder creates a recorder that also masks the given strings, such
+// as user, domain and computer names.
+func NewRecorder(redact ...string) *Recorder {
+	r := &Recorder{}
+	for _, s := range redact {
+		r.addRedaction(s)
+	}
+	return r
+}
+
+// addRedaction adds a string to mask. r.mu is held or r is not shared yet.
+func (r *Recorder) addRedaction(s string) {
+	if s == "" {
+		return
+	}
+	for _, existing := range r.redact {
+		if strings.EqualFold(existing, s) {
+			return
+		}
+	}
+	r.redact = append(r.redact, s)
+	quoted := make([]string, len(r.redact))
+	for i, s := range r.redact {
+		quoted[i] = regexp.QuoteMeta(s)
+	}
+	r.mask = regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+}
+
+// sanitize masks the redacted strings of an envelope. r.mu is held.
+func (r *Recorder) sanitize(envelope string) string {
+	if r.mask == nil {
+		return envelope
+	}
+	mask := r.mask
+	return rewriteEnvelope(envelope,
+		func(s string) string {
+			return mask.ReplaceAllStringFunc(s, func(m string) string { return strings.Repeat("X", len(m)) })
+		},
+		func(b []byte) []byte {
+			return mask.ReplaceAllFunc(b, func(m []byte) []byte { return []byte(strings.Repeat("X", len(m))) })
+		})
+}
+
+// OnRequest implements wsman.Tracer.
+func (r *Recorder) OnRequest(_ context.Context, req *wsman.TraceRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, err := url.Parse(req.URL); err == nil {
+		r.addRedaction(u.Hostname())
+	}
+	body := string(req.Body)
+	p := pendingRequest{activityID: req.ActivityID, action: req.Action, body: body}
+	if m := messageIDPattern.FindStringSubmatch(body); m != nil {
+		p.messageID = strings.TrimSpace(m[1])
+	}
+	r.pending = append(r.pending, p)
+}
+
+// OnResponse implements wsman.Tracer. Requests that got no response are
+// not recorded.
+func (r *Recorder) OnResponse(_ context.Context, resp *wsman.TraceResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	body := string(resp.Body)
+	var relatesTo string
+	if m := relatesToPattern.FindStringSubmatch(body); m != nil {
+		relatesTo = strings.TrimSpace(m[1])
+	}
+	i := r.match(relatesTo, resp.ActivityID, resp.Action)
+	if i < 0 {
+		return
+	}
+	req := r.pending[i]
+	r.pending = append(r.pending[:i], r.pending[i+1:]...)
+	if resp.StatusCode == 0 {
+		return
+	}
+	r.exchanges = append(r.exchanges, Exchange{
+		Action:     req.action,
+		Request:    r.sanitize(req.body),
+		StatusCode: resp.StatusCode,
+		Response:   r.sanitize(body),
+	})
+}
+
+// match returns the index of the pending request a response is for: the
+// one it relates to, or else the oldest of the same activity and action.
+func (r *Recorder) match(relatesTo, activityID, action string) int {
+	if relatesTo != "" {
+		for i, p := range r.pending {
+			if strings.EqualFold(p.messageID, relatesTo) {
+				return i
+			}
+		}
+	}
+	for i, p := range r.pending {
+		if p.activityID == activityID && p.action == action {
+			return i
+		}
+	}
+	return -1
+}
+
+// Fixture returns the exchanges recorded so far.
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Fixture{Exchanges: append([]Exchange(nil), r.exchanges...)}
+}
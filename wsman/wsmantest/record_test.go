@@ -0,0 +1,135 @@
+package wsmantest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/wsman"
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+// creationXML returns a base64 PSRP message of the RunspacePool rpid.
+func creationXML(rpid uuid.UUID) string {
+	msg := make([]byte, 21+4+4)
+	msg = append(msg, psrpGUID(rpid)...)
+	msg = append(msg, "<Obj/>"...)
+	return base64.StdEncoding.EncodeToString(msg)
+}
+
+// runSession creates a shell and runs one command, returning the output
+// of the shell and of the command.
+func runSession(t *testing.T, client *wsman.Client, rpid uuid.UUID, commandID string) (shellOut, commandOut []byte) {
+	t.Helper()
+	ctx := context.Background()
+	epr, err := client.Create(ctx, nil, creationXML(rpid))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	result, err := client.Receive(ctx, epr, "")
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	shellOut = result.Stdout
+
+	id, err := client.Command(ctx, epr, commandID, "")
+	if err != nil {
+		t.Fatalf("Command: %v", err)
+	}
+	if !strings.EqualFold(id, commandID) {
+		t.Errorf("CommandId = %s, want %s", id, commandID)
+	}
+	for {
+		result, err := client.Receive(ctx, epr, commandID)
+		if err != nil {
+			t.Fatalf("Receive command: %v", err)
+		}
+		commandOut = append(commandOut, result.Stdout...)
+		if result.Done {
+			break
+		}
+	}
+	if err := client.Delete(ctx, epr); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	return shellOut, commandOut
+}
+
+func TestRecorder_Replay(t *testing.T) {
+	srv := NewServer(Script{
+		CreateShell: func(sh *Shell, creationData []byte) error {
+			sh.Write(wsman.StreamStdout, creationData)
+			return nil
+		},
+		Command: func(cmd *Command, _ string, _ []string) error {
+			id := uuid.MustParse(cmd.ID())
+			cmd.Write(wsman.StreamStdout, append([]byte("pipeline "+cmd.ID()+" of s3cret:"), psrpGUID(id)...))
+			cmd.Exit(0)
+			return nil
+		},
+	})
+	defer srv.Close()
+
+	rec := NewRecorder("S3CRET")
+	recording := wsman.NewClientWithOptions(srv.Endpoint(), transport.NewHTTPTransport(), wsman.ClientOptions{
+		Tracer:            rec,
+		TracePayloadLimit: -1,
+	})
+	rpid1, cmd1 := uuid.New(), strings.ToUpper(uuid.New().String())
+	runSession(t, recording, rpid1, cmd1)
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := rec.Fixture().Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		t.Fatalf("LoadFixture: %v", err)
+	}
+	if len(fixture.Exchanges) != 5 {
+		t.Fatalf("recorded %d exchanges, want 5", len(fixture.Exchanges))
+	}
+	for _, ex := range fixture.Exchanges {
+		for _, envelope := range []string{ex.Request, ex.Response} {
+			if strings.Contains(envelope, "127.0.0.1") {
+				t.Errorf("%s envelope keeps the host: %s", ex.Action, envelope)
+			}
+		}
+	}
+
+	replay := NewReplayServer(fixture)
+	defer replay.Close()
+	client := wsman.NewClient(replay.Endpoint(), transport.NewHTTPTransport())
+	rpid2, cmd2 := uuid.New(), strings.ToUpper(uuid.New().String())
+	shellOut, commandOut := runSession(t, client, rpid2, cmd2)
+
+	if !bytes.Contains(shellOut, psrpGUID(rpid2)) || bytes.Contains(shellOut, psrpGUID(rpid1)) {
+		t.Errorf("shell output %x does not carry the replayed RunspacePool ID", shellOut)
+	}
+	want := append([]byte("pipeline "+cmd2+" of XXXXXX:"), psrpGUID(uuid.MustParse(cmd2))...)
+	if !bytes.Equal(commandOut, want) {
+		t.Errorf("command output = %q, want %q", commandOut, want)
+	}
+}
+
+func TestReplayServer_Unmatched(t *testing.T) {
+	srv := NewReplayServer(&Fixture{})
+	defer srv.Close()
+
+	client := wsman.NewClient(srv.Endpoint(), transport.NewHTTPTransport())
+	_, err := client.Create(context.Background(), nil, "")
+	if _, ok := wsman.AsFault(err); !ok {
+		t.Fatalf("Create error = %v, want a fault", err)
+	}
+	epr := &wsman.EndpointReference{
+		ResourceURI: wsman.ResourceURIPowerShell,
+		Selectors:   []wsman.Selector{{Name: "ShellId", Value: "SHELL"}},
+	}
+	if err := client.Delete(context.Background(), epr); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+}
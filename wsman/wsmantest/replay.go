@@ -0,0 +1,194 @@
+package wsmantest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"html"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+// NewReplayServer starts a server that answers requests with the responses
+// recorded in f. Close it when done.
+//
+// A request gets the response of the first unused exchange with the same
+// action, shell and command, so the commands of a recording must be
+// created in the same order on replay. The command and RunspacePool IDs a
+// client chooses differ from those of the recording: the server maps the
+// recorded IDs to the client's, in the envelopes and in the PSRP messages
+// of the payloads, while shell IDs are replayed as recorded.
+//
+// Requests without a recorded exchange get what an idle server answers:
+// a Receive times out, Signal and Delete succeed, and anything else gets a
+// fault. Exchanges whose request cannot be parsed are never replayed.
+func NewReplayServer(f *Fixture) *Server {
+	rp := &replayer{ids: make(map[uuid.UUID]uuid.UUID)}
+	for _, ex := range f.Exchanges {
+		req, err := parseRequest([]byte(ex.Request))
+		if err != nil {
+			continue
+		}
+		rp.exchanges = append(rp.exchanges, &replayExchange{Exchange: ex, req: req})
+	}
+	return newServer(rp.serve)
+}
+
+// replayExchange is an exchange of a fixture being replayed.
+type replayExchange struct {
+	Exchange
+	req  *request
+	used bool
+}
+
+// replayer serves the exchanges of a fixture.
+type replayer struct {
+	mu        sync.Mutex
+	exchanges []*replayExchange
+	// ids maps recorded command and RunspacePool IDs to the client's
+	ids map[uuid.UUID]uuid.UUID
+}
+
+func (rp *replayer) serve(ctx context.Context, req *request) (int, []byte) {
+	rp.mu.Lock()
+	ex := rp.find(req)
+	if ex == nil {
+		rp.mu.Unlock()
+		return rp.unmatched(ctx, req)
+	}
+	ex.used = true
+	rp.learn(ex.req, req)
+	resp := rp.rewrite(ex.Response)
+	rp.mu.Unlock()
+
+	resp = relatesToPattern.ReplaceAllStringFunc(resp, func(m string) string {
+		return m[:strings.Index(m, ">")+1] + html.EscapeString(req.env.Header.MessageID) + "<"
+	})
+	return ex.StatusCode, []byte(resp)
+}
+
+// find returns the first unused exchange for a request. rp.mu is held.
+func (rp *replayer) find(req *request) *replayExchange {
+	action := req.env.Header.Action
+	for _, ex := range rp.exchanges {
+		if ex.used || ex.req.env.Header.Action != action {
+			continue
+		}
+		if action == wsman.ActionCreate {
+			return ex
+		}
+		if !strings.EqualFold(ex.req.shellID(), req.shellID()) {
+			continue
+		}
+		if action == wsman.ActionCommand || strings.EqualFold(rp.mapID(ex.req.commandID()), req.commandID()) {
+			return ex
+		}
+	}
+	return nil
+}
+
+// learn maps the IDs of a recorded request to those of the client's
+// request it is replayed for. rp.mu is held.
+func (rp *replayer) learn(recorded, req *request) {
+	switch {
+	case recorded.env.Body.Shell != nil && req.env.Body.Shell != nil:
+		from, ok1 := runspacePoolID(recorded.env.Body.Shell.CreationXML)
+		to, ok2 := runspacePoolID(req.env.Body.Shell.CreationXML)
+		if ok1 && ok2 {
+			rp.ids[from] = to
+		}
+	case recorded.env.Body.CommandLine != nil && req.env.Body.CommandLine != nil:
+		from, err1 := uuid.Parse(recorded.env.Body.CommandLine.CommandID)
+		to, err2 := uuid.Parse(req.env.Body.CommandLine.CommandID)
+		if err1 == nil && err2 == nil {
+			rp.ids[from] = to
+		}
+	}
+}
+
+// mapID returns the client's ID for a recorded one. rp.mu is held.
+func (rp *replayer) mapID(id string) string {
+	if from, err := uuid.Parse(id); err == nil {
+		if to, ok := rp.ids[from]; ok {
+			return to.String()
+		}
+	}
+	return id
+}
+
+// rewrite replaces the recorded IDs of a response envelope with the
+// client's: as text in the envelope and the PSRP messages, and in their
+// binary form in PSRP message headers. rp.mu is held.
+func (rp *replayer) rewrite(envelope string) string {
+	return rewriteEnvelope(envelope,
+		func(s string) string {
+			for from, to := range rp.ids {
+				s = strings.ReplaceAll(s, strings.ToUpper(from.String()), strings.ToUpper(to.String()))
+				s = strings.ReplaceAll(s, from.String(), to.String())
+			}
+			return s
+		},
+		func(b []byte) []byte {
+			for from, to := range rp.ids {
+				b = bytes.ReplaceAll(b, []byte(strings.ToUpper(from.String())), []byte(strings.ToUpper(to.String())))
+				b = bytes.ReplaceAll(b, []byte(from.String()), []byte(to.String()))
+				b = bytes.ReplaceAll(b, psrpGUID(from), psrpGUID(to))
+			}
+			return b
+		})
+}
+
+// unmatched answers a request the fixture has no exchange for.
+func (rp *replayer) unmatched(ctx context.Context, req *request) (int, []byte) {
+	switch action := req.env.Header.Action; action {
+	case wsman.ActionReceive:
+		timer := time.NewTimer(req.operationTimeout(defaultReceiveWait))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		return timedOut(req)
+	case wsman.ActionSignal:
+		return http.StatusOK, response(wsman.ActionSignalResponse, req, `<rsp:SignalResponse/>`)
+	case wsman.ActionDelete:
+		return http.StatusOK, response(wsman.ActionDeleteResponse, req, "")
+	default:
+		return http.StatusInternalServerError, faultResponse(req, "w:InvalidParameter",
+			"wsmantest: no recorded exchange for "+action, 0)
+	}
+}
+
+// psrpGUID returns id as PSRP message headers hold it: the first three
+// groups little-endian. The conversion is its own inverse.
+func psrpGUID(id uuid.UUID) []byte {
+	return []byte{
+		id[3], id[2], id[1], id[0],
+		id[5], id[4],
+		id[7], id[6],
+		id[8], id[9], id[10], id[11], id[12], id[13], id[14], id[15],
+	}
+}
+
+// runspacePoolID returns the RPID of the first PSRP message in the base64
+// creationXml of a shell.
+func runspacePoolID(creationXML string) (uuid.UUID, bool) {
+	// The fragment header is 21 bytes, then destination and message type
+	const offset = 21 + 4 + 4
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(creationXML))
+	if err != nil || len(data) < offset+16 {
+		return uuid.Nil, false
+	}
+	var raw uuid.UUID
+	copy(raw[:], data[offset:offset+16])
+	id, err := uuid.FromBytes(psrpGUID(raw))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,534 @@
+package wsmantest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smnsjas/go-psrp/wsman"
+)
+
+// errorTimedOut is the WSMan code of an operation that timed out.
+const errorTimedOut = 2150858793
+
+// defaultReceiveWait is how long a Receive waits for output when the
+// request has no OperationTimeout.
+const defaultReceiveWait = wsman.DefaultReceiveTimeout
+
+// Server is a fake WinRM endpoint on an httptest.Server. It does not
+// authenticate: clients connect over HTTP without credentials.
+type Server struct {
+	*httptest.Server
+	serve func(ctx context.Context, req *request) (int, []byte)
+}
+
+// newServer starts a Server that answers requests with serve.
+func newServer(serve func(ctx context.Context, req *request) (int, []byte)) *Server {
+	s := &Server{serve: serve}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Endpoint returns the WSMan endpoint of the server, for wsman.NewClient.
+func (s *Server) Endpoint() string {
+	return s.URL + "/wsman"
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := parseRequest(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status, resp := s.serve(r.Context(), req)
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(resp)
+}
+
+// Script decides the behavior of the shells of a Server. Every function
+// is optional; an error it returns is sent to the client as a fault.
+type Script struct {
+	// CreateShell is called for a new shell with its creationXml decoded:
+	// the PSRP handshake of a PowerShell shell, nil for a cmd shell.
+	CreateShell func(sh *Shell, creationData []byte) error
+
+	// Command is called for a new command with its CommandLine. For cmd
+	// shells command is the executable; PowerShell sends no command and
+	// the base64 CREATE_PIPELINE fragments as the only argument.
+	Command func(cmd *Command, command string, args []string) error
+
+	// Input is called with the data sent to a stream of a command, or of
+	// the shell if cmd is nil.
+	Input func(sh *Shell, cmd *Command, stream string, data []byte) error
+
+	// Signal is called for a signal to a command. A terminate signal
+	// removes the command afterwards.
+	Signal func(cmd *Command, code string) error
+}
+
+// NewServer starts a server whose shells behave as script says. Close it
+// when done.
+func NewServer(script Script) *Server {
+	sc := &scripted{script: script, shells: make(map[string]*Shell)}
+	return newServer(sc.serve)
+}
+
+// Shell is a shell of a scripted Server.
+type Shell struct {
+	id          string
+	resourceURI string
+
+	mu       sync.Mutex
+	output   []wsman.StreamChunk // output not of any command
+	commands map[string]*Command
+	changed  chan struct{} // closed when output is written
+}
+
+// ID returns the ShellId.
+func (sh *Shell) ID() string { return sh.id }
+
+// ResourceURI returns the resource URI the shell was created with.
+func (sh *Shell) ResourceURI() string { return sh.resourceURI }
+
+// Write queues output of the shell itself, such as the PSRP messages of
+// a RunspacePool, for a Receive without CommandId.
+func (sh *Shell) Write(stream string, data []byte) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.output = append(sh.output, wsman.StreamChunk{Name: stream, Data: append([]byte(nil), data...)})
+	sh.notifyLocked()
+}
+
+// notifyLocked wakes the Receives waiting for output. sh.mu is held.
+func (sh *Shell) notifyLocked() {
+	close(sh.changed)
+	sh.changed = make(chan struct{})
+}
+
+// Command is a command of a scripted Server.
+type Command struct {
+	id    string
+	shell *Shell
+
+	// The fields below are guarded by shell.mu
+	output   []wsman.StreamChunk
+	exited   bool
+	exitCode int
+}
+
+// ID returns the CommandId.
+func (c *Command) ID() string { return c.id }
+
+// Shell returns the shell of the command.
+func (c *Command) Shell() *Shell { return c.shell }
+
+// Write queues output of the command for Receive.
+func (c *Command) Write(stream string, data []byte) {
+	c.shell.mu.Lock()
+	defer c.shell.mu.Unlock()
+	c.output = append(c.output, wsman.StreamChunk{Name: stream, CommandID: c.id, Data: append([]byte(nil), data...)})
+	c.shell.notifyLocked()
+}
+
+// Exit ends the command: once its output is received, Receive reports it
+// done with exitCode.
+func (c *Command) Exit(exitCode int) {
+	c.shell.mu.Lock()
+	defer c.shell.mu.Unlock()
+	c.exited = true
+	c.exitCode = exitCode
+	c.shell.notifyLocked()
+}
+
+// scripted serves the shells of a Script.
+type scripted struct {
+	script Script
+
+	mu     sync.Mutex
+	shells map[string]*Shell
+}
+
+func (s *scripted) serve(ctx context.Context, req *request) (int, []byte) {
+	switch req.env.Header.Action {
+	case wsman.ActionCreate:
+		return s.create(req)
+	case wsman.ActionDelete:
+		sh, status, fault := s.shell(req)
+		if sh == nil {
+			return status, fault
+		}
+		s.mu.Lock()
+		delete(s.shells, strings.ToUpper(sh.id))
+		s.mu.Unlock()
+		return http.StatusOK, response(wsman.ActionDeleteResponse, req, "")
+	case wsman.ActionCommand:
+		return s.command(req)
+	case wsman.ActionSend:
+		return s.send(req)
+	case wsman.ActionReceive:
+		return s.receive(ctx, req)
+	case wsman.ActionSignal:
+		return s.signal(req)
+	}
+	return http.StatusInternalServerError, faultResponse(req, "w:ActionNotSupported",
+		"wsmantest: action not supported: "+req.env.Header.Action, 0)
+}
+
+func (s *scripted) create(req *request) (int, []byte) {
+	shellXML := req.env.Body.Shell
+	if shellXML == nil {
+		return http.StatusInternalServerError, faultResponse(req, "w:InvalidRepresentation", "wsmantest: Create without a Shell", 0)
+	}
+	var creationData []byte
+	if shellXML.CreationXML != "" {
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(shellXML.CreationXML))
+		if err != nil {
+			return http.StatusInternalServerError, faultResponse(req, "w:InvalidRepresentation", "wsmantest: creationXml: "+err.Error(), 0)
+		}
+		creationData = data
+	}
+
+	sh := &Shell{
+		id:          strings.ToUpper(uuid.New().String()),
+		resourceURI: req.env.Header.ResourceURI,
+		commands:    make(map[string]*Command),
+		changed:     make(chan struct{}),
+	}
+	if s.script.CreateShell != nil {
+		if err := s.script.CreateShell(sh, creationData); err != nil {
+			return scriptFault(req, err)
+		}
+	}
+	s.mu.Lock()
+	s.shells[sh.id] = sh
+	s.mu.Unlock()
+
+	body := `<w:ResourceCreated><a:Address>` + html.EscapeString(req.env.Header.To) + `</a:Address>` +
+		`<a:ReferenceParameters><w:ResourceURI>` + html.EscapeString(sh.resourceURI) + `</w:ResourceURI>` +
+		`<w:SelectorSet><w:Selector Name="ShellId">` + sh.id + `</w:Selector></w:SelectorSet>` +
+		`</a:ReferenceParameters></w:ResourceCreated>`
+	return http.StatusOK, response(wsman.ActionCreateResponse, req, body)
+}
+
+// shell returns the shell a request is for, or the fault to answer with.
+func (s *scripted) shell(req *request) (*Shell, int, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sh, ok := s.shells[strings.ToUpper(req.shellID())]; ok {
+		return sh, 0, nil
+	}
+	return nil, http.StatusInternalServerError, faultResponse(req, "w:InvalidSelectors",
+		"wsmantest: no shell "+req.shellID(), wsman.ErrorInvalidSelectors)
+}
+
+// commandOf returns a command of sh, or the fault to answer with. sh.mu is
+// held.
+func commandOf(sh *Shell, req *request, commandID string) (*Command, int, []byte) {
+	if cmd, ok := sh.commands[strings.ToUpper(commandID)]; ok {
+		return cmd, 0, nil
+	}
+	return nil, http.StatusInternalServerError, faultResponse(req, "w:InvalidParameter",
+		"wsmantest: no command "+commandID, 0)
+}
+
+func (s *scripted) command(req *request) (int, []byte) {
+	sh, status, fault := s.shell(req)
+	if sh == nil {
+		return status, fault
+	}
+	line := req.env.Body.CommandLine
+	if line == nil {
+		return http.StatusInternalServerError, faultResponse(req, "w:InvalidRepresentation", "wsmantest: Command without a CommandLine", 0)
+	}
+	id := strings.ToUpper(line.CommandID)
+	if id == "" {
+		id = strings.ToUpper(uuid.New().String())
+	}
+	cmd := &Command{id: id, shell: sh}
+	if s.script.Command != nil {
+		if err := s.script.Command(cmd, line.Command, line.Arguments); err != nil {
+			return scriptFault(req, err)
+		}
+	}
+	sh.mu.Lock()
+	sh.commands[id] = cmd
+	sh.mu.Unlock()
+
+	body := `<rsp:CommandResponse><rsp:CommandId>` + id + `</rsp:CommandId></rsp:CommandResponse>`
+	return http.StatusOK, response(wsman.ActionCommandResponse, req, body)
+}
+
+func (s *scripted) send(req *request) (int, []byte) {
+	sh, status, fault := s.shell(req)
+	if sh == nil {
+		return status, fault
+	}
+	if req.env.Body.Send == nil {
+		return http.StatusInternalServerError, faultResponse(req, "w:InvalidRepresentation", "wsmantest: Send without streams", 0)
+	}
+	for _, stream := range req.env.Body.Send.Streams {
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stream.Content))
+		if err != nil {
+			return http.StatusInternalServerError, faultResponse(req, "w:InvalidRepresentation", "wsmantest: stream: "+err.Error(), 0)
+		}
+		var cmd *Command
+		if stream.CommandID != "" {
+			sh.mu.Lock()
+			cmd, status, fault = commandOf(sh, req, stream.CommandID)
+			sh.mu.Unlock()
+			if cmd == nil {
+				return status, fault
+			}
+		}
+		if s.script.Input != nil {
+			if err := s.script.Input(sh, cmd, stream.Name, data); err != nil {
+				return scriptFault(req, err)
+			}
+		}
+	}
+	return http.StatusOK, response(wsman.ActionSendResponse, req, `<rsp:SendResponse/>`)
+}
+
+func (s *scripted) signal(req *request) (int, []byte) {
+	sh, status, fault := s.shell(req)
+	if sh == nil {
+		return status, fault
+	}
+	signal := req.env.Body.Signal
+	if signal == nil {
+		return http.StatusInternalServerError, faultResponse(req, "w:InvalidRepresentation", "wsmantest: Signal without a code", 0)
+	}
+	sh.mu.Lock()
+	cmd, status, fault := commandOf(sh, req, signal.CommandID)
+	sh.mu.Unlock()
+	if cmd == nil {
+		return status, fault
+	}
+	code := strings.TrimSpace(signal.Code)
+	if s.script.Signal != nil {
+		if err := s.script.Signal(cmd, code); err != nil {
+			return scriptFault(req, err)
+		}
+	}
+	if code == wsman.SignalTerminate {
+		sh.mu.Lock()
+		delete(sh.commands, cmd.id)
+		sh.notifyLocked()
+		sh.mu.Unlock()
+	}
+	return http.StatusOK, response(wsman.ActionSignalResponse, req, `<rsp:SignalResponse/>`)
+}
+
+// receive answers with the queued output of the shell or a command,
+// waiting for some up to the OperationTimeout of the request.
+func (s *scripted) receive(ctx context.Context, req *request) (int, []byte) {
+	sh, status, fault := s.shell(req)
+	if sh == nil {
+		return status, fault
+	}
+	var commandID string
+	if req.env.Body.Receive != nil {
+		commandID = req.env.Body.Receive.DesiredStream.CommandID
+	}
+	timer := time.NewTimer(req.operationTimeout(defaultReceiveWait))
+	defer timer.Stop()
+
+	sh.mu.Lock()
+	for {
+		var (
+			chunks []wsman.StreamChunk
+			state  string
+		)
+		if commandID == "" {
+			chunks, sh.output = sh.output, nil
+		} else {
+			cmd, status, fault := commandOf(sh, req, commandID)
+			if cmd == nil {
+				sh.mu.Unlock()
+				return status, fault
+			}
+			chunks, cmd.output = cmd.output, nil
+			if cmd.exited {
+				state = `<rsp:CommandState CommandId="` + cmd.id + `" State="` + wsman.NsShell + `/CommandState/Done">` +
+					`<rsp:ExitCode>` + strconv.Itoa(cmd.exitCode) + `</rsp:ExitCode></rsp:CommandState>`
+			} else if len(chunks) > 0 {
+				state = `<rsp:CommandState CommandId="` + cmd.id + `" State="` + wsman.NsShell + `/CommandState/Running"/>`
+			}
+		}
+		if len(chunks) > 0 || state != "" {
+			sh.mu.Unlock()
+			var b strings.Builder
+			b.WriteString(`<rsp:ReceiveResponse>`)
+			for _, chunk := range chunks {
+				b.WriteString(`<rsp:Stream Name="` + html.EscapeString(chunk.Name) + `"`)
+				if chunk.CommandID != "" {
+					b.WriteString(` CommandId="` + chunk.CommandID + `"`)
+				}
+				b.WriteString(`>` + base64.StdEncoding.EncodeToString(chunk.Data) + `</rsp:Stream>`)
+			}
+			b.WriteString(state + `</rsp:ReceiveResponse>`)
+			return http.StatusOK, response(wsman.ActionReceiveResponse, req, b.String())
+		}
+
+		changed := sh.changed
+		sh.mu.Unlock()
+		select {
+		case <-changed:
+		case <-timer.C:
+			return timedOut(req)
+		case <-ctx.Done():
+			return timedOut(req)
+		}
+		sh.mu.Lock()
+	}
+}
+
+// timedOut is the fault WinRM answers a Receive with when no output came
+// in time.
+func timedOut(req *request) (int, []byte) {
+	return http.StatusInternalServerError, faultResponse(req, "w:TimedOut",
+		"The WS-Management service cannot complete the operation within the time specified in OperationTimeout.", errorTimedOut)
+}
+
+// scriptFault answers with the error of a Script function.
+func scriptFault(req *request, err error) (int, []byte) {
+	return http.StatusInternalServerError, faultResponse(req, "w:InternalError", err.Error(), 0)
+}
+
+// request is a parsed WSMan request.
+type request struct {
+	body []byte
+	env  requestEnvelope
+}
+
+// requestEnvelope is the part of a request envelope the server reads.
+type requestEnvelope struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Header  struct {
+		Action           string           `xml:"Action"`
+		To               string           `xml:"To"`
+		MessageID        string           `xml:"MessageID"`
+		ResourceURI      string           `xml:"ResourceURI"`
+		OperationTimeout string           `xml:"OperationTimeout"`
+		Selectors        []wsman.Selector `xml:"SelectorSet>Selector"`
+	} `xml:"Header"`
+	Body struct {
+		Shell *struct {
+			CreationXML string `xml:"creationXml"`
+		} `xml:"Shell"`
+		CommandLine *struct {
+			CommandID string   `xml:"CommandId,attr"`
+			Command   string   `xml:"Command"`
+			Arguments []string `xml:"Arguments"`
+		} `xml:"CommandLine"`
+		Send *struct {
+			Streams []struct {
+				Name      string `xml:"Name,attr"`
+				CommandID string `xml:"CommandId,attr"`
+				Content   string `xml:",chardata"`
+			} `xml:"Stream"`
+		} `xml:"Send"`
+		Receive *struct {
+			DesiredStream struct {
+				CommandID string `xml:"CommandId,attr"`
+			} `xml:"DesiredStream"`
+		} `xml:"Receive"`
+		Signal *struct {
+			CommandID string `xml:"CommandId,attr"`
+			Code      string `xml:"Code"`
+		} `xml:"Signal"`
+	} `xml:"Body"`
+}
+
+func parseRequest(body []byte) (*request, error) {
+	req := &request{body: body}
+	if err := xml.Unmarshal(body, &req.env); err != nil {
+		return nil, fmt.Errorf("wsmantest: parse request: %w", err)
+	}
+	req.env.Header.Action = strings.TrimSpace(req.env.Header.Action)
+	return req, nil
+}
+
+// shellID returns the ShellId selector of the request.
+func (r *request) shellID() string {
+	for _, s := range r.env.Header.Selectors {
+		if s.Name == "ShellId" {
+			return strings.TrimSpace(s.Value)
+		}
+	}
+	return ""
+}
+
+// commandID returns the command the request is for, if any.
+func (r *request) commandID() string {
+	body := r.env.Body
+	switch {
+	case body.CommandLine != nil:
+		return body.CommandLine.CommandID
+	case body.Receive != nil:
+		return body.Receive.DesiredStream.CommandID
+	case body.Signal != nil:
+		return body.Signal.CommandID
+	case body.Send != nil && len(body.Send.Streams) > 0:
+		return body.Send.Streams[0].CommandID
+	}
+	return ""
+}
+
+// operationTimeout returns the OperationTimeout of the request, or def if
+// it has none in seconds ("PT60S", "PT1.5S").
+func (r *request) operationTimeout(def time.Duration) time.Duration {
+	v := strings.TrimSpace(r.env.Header.OperationTimeout)
+	if !strings.HasPrefix(v, "PT") || !strings.HasSuffix(v, "S") {
+		return def
+	}
+	seconds, err := strconv.ParseFloat(v[2:len(v)-1], 64)
+	if err != nil || seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// response builds a response envelope with body.
+func response(action string, req *request, body string) []byte {
+	return []byte(`<s:Envelope xmlns:s="` + wsman.NsSoap + `" xmlns:a="` + wsman.NsAddressing +
+		`" xmlns:w="` + wsman.NsWsman + `" xmlns:rsp="` + wsman.NsShell + `"><s:Header>` +
+		`<a:Action>` + action + `</a:Action>` +
+		`<a:MessageID>uuid:` + strings.ToUpper(uuid.New().String()) + `</a:MessageID>` +
+		`<a:To>` + wsman.AddressAnonymous + `</a:To>` +
+		`<a:RelatesTo>` + html.EscapeString(req.env.Header.MessageID) + `</a:RelatesTo>` +
+		`</s:Header><s:Body>` + body + `</s:Body></s:Envelope>`)
+}
+
+// faultResponse builds a SOAP fault with a WSMan subcode and, if not 0, a
+// WSManFault code.
+func faultResponse(req *request, subcode, reason string, code int) []byte {
+	body := `<s:Fault><s:Code><s:Value>s:Receiver</s:Value><s:Subcode><s:Value>` + subcode + `</s:Value></s:Subcode></s:Code>` +
+		`<s:Reason><s:Text xml:lang="en-US">` + html.EscapeString(reason) + `</s:Text></s:Reason>`
+	if code != 0 {
+		body += `<s:Detail><f:WSManFault xmlns:f="http://schemas.microsoft.com/wbem/wsman/1/wsmanfault" Code="` +
+			strconv.Itoa(code) + `" Machine="localhost"><f:Message>` + html.EscapeString(reason) + `</f:Message></f:WSManFault></s:Detail>`
+	}
+	body += `</s:Fault>`
+	return response(wsman.NsAddressing+"/fault", req, body)
+}
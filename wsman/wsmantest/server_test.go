@@ -0,0 +1,146 @@
+package wsmantest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smnsjas/go-psrp/winrs"
+	"github.com/smnsjas/go-psrp/wsman"
+	"github.com/smnsjas/go-psrp/wsman/transport"
+)
+
+func TestServer_Script(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		input   []string
+		signals []string
+	)
+	srv := NewServer(Script{
+		Command: func(cmd *Command, command string, args []string) error {
+			if command != "whoami" || len(args) != 1 || args[0] != "/all" {
+				t.Errorf("Command(%q, %q)", command, args)
+			}
+			cmd.Write(wsman.StreamStdout, []byte("contoso\\alice\r\n"))
+			cmd.Write(wsman.StreamStderr, []byte("warning\r\n"))
+			cmd.Exit(3)
+			return nil
+		},
+		Input: func(_ *Shell, cmd *Command, stream string, data []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			input = append(input, cmd.ID()+" "+stream+" "+string(data))
+			return nil
+		},
+		Signal: func(cmd *Command, code string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			signals = append(signals, code)
+			return nil
+		},
+	})
+	defer srv.Close()
+
+	ctx := context.Background()
+	shell, err := winrs.NewShell(ctx, wsman.NewClient(srv.Endpoint(), transport.NewHTTPTransport()))
+	if err != nil {
+		t.Fatalf("NewShell: %v", err)
+	}
+	proc, err := shell.Start(ctx, "whoami", "/all")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := proc.Send(ctx, []byte("y\r\n")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := proc.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if string(proc.Stdout()) != "contoso\\alice\r\n" || string(proc.Stderr()) != "warning\r\n" || proc.ExitCode() != 3 {
+		t.Errorf("stdout %q, stderr %q, exit code %d", proc.Stdout(), proc.Stderr(), proc.ExitCode())
+	}
+	if err := proc.Signal(ctx, wsman.SignalTerminate); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+	if err := shell.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(input) != 1 || input[0] != proc.CommandID()+" stdin y\r\n" {
+		t.Errorf("input = %q", input)
+	}
+	if len(signals) != 1 || signals[0] != wsman.SignalTerminate {
+		t.Errorf("signals = %q", signals)
+	}
+}
+
+func TestServer_ShellNotFound(t *testing.T) {
+	srv := NewServer(Script{})
+	defer srv.Close()
+
+	client := wsman.NewClient(srv.Endpoint(), transport.NewHTTPTransport())
+	epr := &wsman.EndpointReference{
+		ResourceURI: wsman.ResourceURIPowerShell,
+		Selectors:   []wsman.Selector{{Name: "ShellId", Value: "MISSING"}},
+	}
+	_, err := client.Receive(context.Background(), epr, "")
+	fault, ok := wsman.AsFault(err)
+	if !ok || !fault.IsShellNotFound() {
+		t.Fatalf("Receive error = %v, want shell not found", err)
+	}
+}
+
+func TestServer_ReceiveTimesOut(t *testing.T) {
+	srv := NewServer(Script{
+		CreateShell: func(sh *Shell, creationData []byte) error {
+			if string(creationData) != "handshake" {
+				t.Errorf("creationData = %q", creationData)
+			}
+			return nil
+		},
+	})
+	defer srv.Close()
+
+	client := wsman.NewClientWithOptions(srv.Endpoint(), transport.NewHTTPTransport(), wsman.ClientOptions{
+		ReceiveTimeout: 100 * time.Millisecond,
+	})
+	ctx := context.Background()
+	epr, err := client.Create(ctx, nil, "aGFuZHNoYWtl")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	start := time.Now()
+	result, err := client.Receive(ctx, epr, "")
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if len(result.Streams) != 0 || time.Since(start) < 100*time.Millisecond {
+		t.Errorf("Receive = %+v after %v, want nothing after the timeout", result, time.Since(start))
+	}
+}
+
+func TestServer_ScriptError(t *testing.T) {
+	srv := NewServer(Script{
+		Command: func(*Command, string, []string) error {
+			return errFake
+		},
+	})
+	defer srv.Close()
+
+	ctx := context.Background()
+	shell, err := winrs.NewShell(ctx, wsman.NewClient(srv.Endpoint(), transport.NewHTTPTransport()))
+	if err != nil {
+		t.Fatalf("NewShell: %v", err)
+	}
+	if _, err := shell.Run(ctx, "cmd"); err == nil || !strings.Contains(err.Error(), errFake.Error()) {
+		t.Errorf("Run error = %v, want the script's", err)
+	}
+}
+
+var errFake = errors.New("access to the fake denied")